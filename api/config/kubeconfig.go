@@ -1,29 +1,121 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"kgent-api/pkg/cachestats"
+	"kgent-api/pkg/dyninformer"
+	"kgent-api/pkg/index"
+	"kgent-api/pkg/nsinformer"
+	"kgent-api/pkg/version"
+
 	"github.com/pkg/errors"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
 	"k8s.io/client-go/util/homedir"
 )
 
+// K8sConfig creates the clients and caches of the server from the REST config. Each is
+// created on the first call of its getter, such as ClientSet or InformerFactory, which are
+// safe for concurrent use and return the error of the first call on every call.
 type K8sConfig struct {
 	*rest.Config
+	// Clientset, DynamicClient and SharedInformerFactory are set by their getters, which
+	// should be used instead
 	*kubernetes.Clientset
 	*dynamic.DynamicClient
-	meta.RESTMapper
 	informers.SharedInformerFactory
-	e error
+	// Informers records every informer started by InitInformer for debugging
+	Informers *cachestats.Registry
+	// DynamicInformers are started and stopped at runtime through the admin API
+	DynamicInformers *dyninformer.Registry
+	// Shards cache namespaced resources per namespace when sharding is enabled, in place of
+	// the shared factory
+	Shards *nsinformer.Registry
+
+	clientSet        lazy[*kubernetes.Clientset]
+	dynamicClient    lazy[*dynamic.DynamicClient]
+	restMapper       lazy[meta.RESTMapper]
+	informerFactory  lazy[informers.SharedInformerFactory]
+	dynamicInformers lazy[*dyninformer.Registry]
+
+	// mu guards e, the first error met
+	mu sync.Mutex
+	e  error
+
+	// keepManagedFields and keepLastApplied disable stripping those fields from cached objects
+	keepManagedFields bool
+	keepLastApplied   bool
+	// podLabelIndexes are the label keys pods are indexed by, in addition to their node
+	podLabelIndexes []string
+	// userAgent prefixes the per-feature user agents, kgent-api/<version> by default
+	userAgent string
+	// informerQPS and informerBurst limit the informers' clients when set
+	informerQPS   float32
+	informerBurst int
+	// cacheSyncTimeout bounds the wait for the informer caches, zero waits until they sync
+	cacheSyncTimeout time.Duration
+	// informerNamespace limits the shared informers' namespaced caches to one namespace
+	informerNamespace string
+	// shards, when set, caches namespaced resources in per-namespace factories
+	shards *nsinformer.Options
+}
+
+// InformerResources are the resources InitInformer caches, which the credentials must be
+// allowed to list and watch in every namespace
+var InformerResources = []schema.GroupVersionResource{
+	corev1.SchemeGroupVersion.WithResource("pods"),
+	corev1.SchemeGroupVersion.WithResource("nodes"),
+	corev1.SchemeGroupVersion.WithResource("services"),
+	corev1.SchemeGroupVersion.WithResource("configmaps"),
+	corev1.SchemeGroupVersion.WithResource("secrets"),
+	corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"),
+	appsv1.SchemeGroupVersion.WithResource("deployments"),
+	appsv1.SchemeGroupVersion.WithResource("replicasets"),
+	appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	batchv1.SchemeGroupVersion.WithResource("jobs"),
+	batchv1.SchemeGroupVersion.WithResource("cronjobs"),
+	policyv1.SchemeGroupVersion.WithResource("poddisruptionbudgets"),
+}
+
+// clusterScopedInformerResources are the InformerResources that are not namespaced
+var clusterScopedInformerResources = map[schema.GroupVersionResource]bool{
+	corev1.SchemeGroupVersion.WithResource("nodes"): true,
+}
+
+// lazy creates a value on the first call of get. Every call returns the value and error of
+// the first, whichever goroutines they are made from.
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+func (l *lazy[T]) get(create func() (T, error)) (T, error) {
+	l.once.Do(func() {
+		l.value, l.err = create()
+	})
+	return l.value, l.err
 }
 
 func NewK8sConfig() *K8sConfig {
@@ -39,7 +131,7 @@ func (k *K8sConfig) InitRestConfig(optfuncs ...K8sConfigOptionFunc) *K8sConfig {
 
 	config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
 	if err != nil {
-		k.e = errors.Wrap(err, "failed to build config from flags")
+		k.fail(errors.Wrap(err, "failed to build config from flags"))
 		return k
 	}
 
@@ -47,6 +139,7 @@ func (k *K8sConfig) InitRestConfig(optfuncs ...K8sConfigOptionFunc) *K8sConfig {
 	for _, optfunc := range optfuncs {
 		optfunc(k)
 	}
+	k.UserAgent = k.userAgentFor("requests")
 	return k
 }
 
@@ -54,90 +147,297 @@ func (k *K8sConfig) InitRestConfig(optfuncs ...K8sConfigOptionFunc) *K8sConfig {
 func (k *K8sConfig) InitConfigInCluster() *K8sConfig {
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		k.e = errors.Wrap(err, "failed to get in-cluster config")
+		k.fail(errors.Wrap(err, "failed to get in-cluster config"))
 		return k
 	}
 	k.Config = config
+	k.UserAgent = k.userAgentFor("requests")
 	return k
 }
 
+// userAgentFor suffixes the user agent with feature, so API server audit logs and metrics
+// tell cache relists apart from requests made on behalf of API users
+func (k *K8sConfig) userAgentFor(feature string) string {
+	if k.userAgent == "" {
+		return version.UserAgent(feature)
+	}
+	return fmt.Sprintf("%s (%s)", k.userAgent, feature)
+}
+
+// configFor returns a copy of the REST config identifying itself as feature
+func (k *K8sConfig) configFor(feature string) *rest.Config {
+	config := rest.CopyConfig(k.Config)
+	config.UserAgent = k.userAgentFor(feature)
+	return config
+}
+
+// informerConfigFor is configFor with the informer rate limits applied. Clients created
+// from it get a rate limiter of their own either way, so a relist storm only throttles
+// the informers and never the requests made on behalf of API users.
+func (k *K8sConfig) informerConfigFor(feature string) *rest.Config {
+	config := k.configFor(feature)
+	if k.informerQPS > 0 {
+		config.QPS = k.informerQPS
+	}
+	if k.informerBurst > 0 {
+		config.Burst = k.informerBurst
+	}
+	return config
+}
+
+// Error returns the first error met creating the config, its clients and caches
 func (k *K8sConfig) Error() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
 	return k.e
 }
 
-// InitClientSet initializes Kubernetes clientset
-func (k *K8sConfig) InitClientSet() *kubernetes.Clientset {
-	if k.Config == nil {
-		k.e = errors.New("k8s config is nil")
-		return nil
+// fail records err as the config's error unless one was met before, and returns it
+func (k *K8sConfig) fail(err error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.e == nil {
+		k.e = err
 	}
+	return err
+}
 
-	clientSet, err := kubernetes.NewForConfig(k.Config)
+var errNilConfig = errors.New("k8s config is nil")
+
+// ClientSet returns the Kubernetes clientset, created on the first call
+func (k *K8sConfig) ClientSet() (kubernetes.Interface, error) {
+	clientSet, err := k.clientSet.get(func() (*kubernetes.Clientset, error) {
+		if k.Config == nil {
+			return nil, errNilConfig
+		}
+		clientSet, err := kubernetes.NewForConfig(k.Config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create clientset")
+		}
+		k.Clientset = clientSet
+		return clientSet, nil
+	})
 	if err != nil {
-		k.e = errors.Wrap(err, "failed to create clientset")
-		return nil
+		return nil, k.fail(err)
 	}
-	k.Clientset = clientSet
-	return clientSet
+	return clientSet, nil
 }
 
-// InitDynamicClient initializes dynamic client
-func (k *K8sConfig) InitDynamicClient() *dynamic.DynamicClient {
-	if k.Config == nil {
-		k.e = errors.New("k8s config is nil")
-		return nil
+// Dynamic returns the dynamic client, created on the first call
+func (k *K8sConfig) Dynamic() (dynamic.Interface, error) {
+	dynamicClient, err := k.dynamicClient.get(func() (*dynamic.DynamicClient, error) {
+		if k.Config == nil {
+			return nil, errNilConfig
+		}
+		dynamicClient, err := dynamic.NewForConfig(k.Config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create dynamic client")
+		}
+		k.DynamicClient = dynamicClient
+		return dynamicClient, nil
+	})
+	if err != nil {
+		return nil, k.fail(err)
 	}
+	return dynamicClient, nil
+}
 
-	dynamicClient, err := dynamic.NewForConfig(k.Config)
+// RESTMapper returns the REST mapper for the API resources discovered on the first call
+func (k *K8sConfig) RESTMapper() (meta.RESTMapper, error) {
+	mapper, err := k.restMapper.get(func() (meta.RESTMapper, error) {
+		clientSet, err := k.ClientSet()
+		if err != nil {
+			return nil, err
+		}
+		gr, err := restmapper.GetAPIGroupResources(clientSet.Discovery())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get API group resources")
+		}
+		return restmapper.NewDiscoveryRESTMapper(gr), nil
+	})
 	if err != nil {
-		k.e = errors.Wrap(err, "failed to create dynamic client")
-		return nil
+		return nil, k.fail(err)
 	}
-	k.DynamicClient = dynamicClient
-	return dynamicClient
+	return mapper, nil
 }
 
-// InitRestMapper initializes REST mapper for API resources
-func (k *K8sConfig) InitRestMapper() meta.RESTMapper {
-	if k.Clientset == nil {
-		k.InitClientSet()
-		if k.e != nil {
-			return nil
-		}
+// InformerFactory returns the shared informer factory, started and synced on the first call,
+// which blocks until then. When sharding is enabled, Shards is set by then too.
+func (k *K8sConfig) InformerFactory() (informers.SharedInformerFactory, error) {
+	fact, err := k.informerFactory.get(k.newInformerFactory)
+	if err != nil {
+		return nil, k.fail(err)
 	}
+	return fact, nil
+}
 
-	gr, err := restmapper.GetAPIGroupResources(k.Clientset.Discovery())
+// DynamicInformerRegistry returns the registry of runtime-managed dynamic informers, which
+// stops informers that were not queried for idleTimeout. The idleTimeout of the first call
+// is kept.
+func (k *K8sConfig) DynamicInformerRegistry(idleTimeout time.Duration) (*dyninformer.Registry, error) {
+	registry, err := k.dynamicInformers.get(func() (*dyninformer.Registry, error) {
+		if k.Config == nil {
+			return nil, errNilConfig
+		}
+		informerClient, err := dynamic.NewForConfig(k.informerConfigFor("dynamic-informers"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create dynamic informer client")
+		}
+		k.DynamicInformers = dyninformer.NewRegistry(informerClient, idleTimeout,
+			stripTransform(k.keepManagedFields, k.keepLastApplied))
+		return k.DynamicInformers, nil
+	})
 	if err != nil {
-		k.e = errors.Wrap(err, "failed to get API group resources")
-		return nil
+		return nil, k.fail(err)
 	}
-	mapper := restmapper.NewDiscoveryRESTMapper(gr)
-	k.RESTMapper = mapper
+	return registry, nil
+}
+
+// InitClientSet initializes Kubernetes clientset. It wraps ClientSet, leaving the error to Error.
+func (k *K8sConfig) InitClientSet() kubernetes.Interface {
+	clientSet, _ := k.ClientSet()
+	return clientSet
+}
+
+// InitDynamicClient initializes dynamic client. It wraps Dynamic, leaving the error to Error.
+func (k *K8sConfig) InitDynamicClient() dynamic.Interface {
+	dynamicClient, _ := k.Dynamic()
+	return dynamicClient
+}
+
+// InitRestMapper initializes REST mapper for API resources. It wraps RESTMapper, leaving the
+// error to Error.
+func (k *K8sConfig) InitRestMapper() meta.RESTMapper {
+	mapper, _ := k.RESTMapper()
 	return mapper
 }
 
-// InitInformer initializes shared informer factory
+// InitInformer initializes shared informer factory. It wraps InformerFactory, leaving the
+// error to Error.
 func (k *K8sConfig) InitInformer() informers.SharedInformerFactory {
-	if k.Clientset == nil {
-		k.InitClientSet()
-		if k.e != nil {
-			return nil
-		}
+	fact, _ := k.InformerFactory()
+	return fact
+}
+
+// InitDynamicInformers creates the registry of runtime-managed dynamic informers. It wraps
+// DynamicInformerRegistry, leaving the error to Error.
+func (k *K8sConfig) InitDynamicInformers(idleTimeout time.Duration) *dyninformer.Registry {
+	registry, _ := k.DynamicInformerRegistry(idleTimeout)
+	return registry
+}
+
+// newInformerFactory creates the shared informer factory and the shards, starts them and
+// waits for their caches to sync
+func (k *K8sConfig) newInformerFactory() (informers.SharedInformerFactory, error) {
+	if k.Config == nil {
+		return nil, errNilConfig
 	}
 
-	fact := informers.NewSharedInformerFactory(k.Clientset, 0)
+	// The factory's list and watch requests get their own user agent
+	informerClient, err := kubernetes.NewForConfig(k.informerConfigFor("informers"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create informer clientset")
+	}
 
-	// Initialize default informers as needed
-	fact.Core().V1().Pods().Informer()
-	fact.Core().V1().Services().Informer()
-	fact.Apps().V1().Deployments().Informer()
+	factoryOptions := []informers.SharedInformerOption{
+		informers.WithTransform(stripTransform(k.keepManagedFields, k.keepLastApplied)),
+	}
+	if k.informerNamespace != "" {
+		factoryOptions = append(factoryOptions, informers.WithNamespace(k.informerNamespace))
+	}
+	fact := informers.NewSharedInformerFactoryWithOptions(informerClient, 0, factoryOptions...)
+	shared := fact
+
+	// Sharded, the shards of the namespaces in use cache the namespaced resources and the
+	// factory only the cluster-scoped ones. Every reader gets a view of both, so a namespaced
+	// lister or event handler never makes the factory cache its resource cluster-wide.
+	podIndexers := index.PodIndexers(k.podLabelIndexes)
+	if k.shards != nil {
+		var namespaced []schema.GroupVersionResource
+		for _, gvr := range InformerResources {
+			if !clusterScopedInformerResources[gvr] {
+				namespaced = append(namespaced, gvr)
+			}
+		}
+		options := *k.shards
+		options.Resources = namespaced
+		options.FactoryOptions = []informers.SharedInformerOption{
+			informers.WithTransform(stripTransform(k.keepManagedFields, k.keepLastApplied)),
+		}
+		options.Indexers = map[schema.GroupVersionResource]cache.Indexers{
+			corev1.SchemeGroupVersion.WithResource("pods"): podIndexers,
+		}
+		if k.Shards, err = nsinformer.NewRegistry(informerClient, options); err != nil {
+			return nil, errors.Wrap(err, "failed to start informer shards")
+		}
+		fact = k.Shards.Factory(shared)
+	} else if err := fact.Core().V1().Pods().Informer().AddIndexers(podIndexers); err != nil {
+		return nil, errors.Wrap(err, "failed to add pod indexers")
+	}
+
+	// The factory returns the same informers for their resources, the pod indexers included
+	k.Informers = cachestats.NewRegistry()
+	for _, gvr := range InformerResources {
+		informer, err := fact.ForResource(gvr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create informer for %s", gvr.Resource)
+		}
+		if err := k.Informers.Register(gvr, informer.Informer()); err != nil {
+			return nil, errors.Wrapf(err, "failed to register informer for %s", gvr.Resource)
+		}
+	}
 
 	ch := make(chan struct{})
-	fact.Start(ch)
-	fact.WaitForCacheSync(ch)
+	shared.Start(ch)
+
+	// Without a timeout a cache that can't list, e.g. for lack of RBAC, blocks startup forever
+	wait := make(chan struct{})
+	if k.cacheSyncTimeout > 0 {
+		timer := time.AfterFunc(k.cacheSyncTimeout, func() { close(wait) })
+		defer timer.Stop()
+	}
+	var unsynced []string
+	for informerType, synced := range shared.WaitForCacheSync(wait) {
+		if !synced {
+			unsynced = append(unsynced, informerType.String())
+		}
+	}
+	if len(unsynced) > 0 {
+		log.Printf("Informer caches not synced after %s, serving them partially until they are: %s",
+			k.cacheSyncTimeout, strings.Join(unsynced, ", "))
+	}
+	if k.Shards != nil {
+		if unsynced := k.Shards.WaitForCacheSync(wait); len(unsynced) > 0 {
+			log.Printf("Informer shards not synced after %s, serving them partially until they are: %s",
+				k.cacheSyncTimeout, strings.Join(unsynced, ", "))
+		}
+	}
 
 	k.SharedInformerFactory = fact
-	return fact
+	return fact, nil
+}
+
+// stripTransform drops managedFields and the last-applied-configuration annotation from objects
+// before they enter the informer cache, where they often account for a third of the memory
+func stripTransform(keepManagedFields, keepLastApplied bool) cache.TransformFunc {
+	return func(obj interface{}) (interface{}, error) {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			// Tombstones and other non-objects are passed through untouched
+			return obj, nil
+		}
+
+		if !keepManagedFields {
+			accessor.SetManagedFields(nil)
+		}
+		if annotations := accessor.GetAnnotations(); !keepLastApplied && annotations != nil {
+			if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+				delete(annotations, corev1.LastAppliedConfigAnnotation)
+				accessor.SetAnnotations(annotations)
+			}
+		}
+		return obj, nil
+	}
 }
 
 type K8sConfigOptionFunc func(k *K8sConfig)
@@ -158,6 +458,42 @@ func WithBurst(b int) K8sConfigOptionFunc {
 	}
 }
 
+// WithWarningHandler routes API server warnings, such as deprecated API versions, to handler
+func WithWarningHandler(handler rest.WarningHandler) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		if k.Config != nil {
+			k.WarningHandler = handler
+		}
+	}
+}
+
+// WithTransportWrapper wraps the transport of every client built from the config, after
+// any wrapper already set
+func WithTransportWrapper(fn transport.WrapperFunc) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		if k.Config != nil {
+			k.Wrap(fn)
+		}
+	}
+}
+
+// WithInformerRateLimits sets the QPS and burst of the informers' clients, which default
+// to those of the request clients. Zero keeps the default.
+func WithInformerRateLimits(qps float32, burst int) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.informerQPS = qps
+		k.informerBurst = burst
+	}
+}
+
+// WithUserAgent replaces the kgent-api/<version> prefix of the user agents, which are
+// suffixed with the feature making the requests, e.g. "my-agent (informers)"
+func WithUserAgent(ua string) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.userAgent = ua
+	}
+}
+
 func WithTimeout(timeout int) K8sConfigOptionFunc {
 	return func(k *K8sConfig) {
 		if k.Config != nil {
@@ -165,3 +501,54 @@ func WithTimeout(timeout int) K8sConfigOptionFunc {
 		}
 	}
 }
+
+// WithManagedFields keeps managedFields on cached objects, for features that inspect field ownership
+func WithManagedFields(keep bool) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.keepManagedFields = keep
+	}
+}
+
+// WithLastAppliedConfig keeps the kubectl last-applied-configuration annotation on cached objects
+func WithLastAppliedConfig(keep bool) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.keepLastApplied = keep
+	}
+}
+
+// WithCacheSyncTimeout stops waiting for the informer caches to sync after timeout, logging
+// the ones still syncing. Zero waits until every cache has synced.
+func WithCacheSyncTimeout(timeout time.Duration) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.cacheSyncTimeout = timeout
+	}
+}
+
+// WithInformerNamespace limits the caches of namespaced resources to namespace, so the
+// credentials only need to list and watch them there. Empty caches every namespace.
+func WithInformerNamespace(namespace string) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.informerNamespace = namespace
+	}
+}
+
+// WithInformerShards caches namespaced resources in a factory per namespace instead of the
+// shared factory: namespaces get one at startup that is kept, and with lazy any other
+// namespace gets one on its first query, stopped after idleTimeout without queries. The
+// shared factory only caches the cluster-scoped resources then.
+func WithInformerShards(namespaces []string, lazy bool, idleTimeout time.Duration) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		if len(namespaces) == 0 && !lazy {
+			k.shards = nil
+			return
+		}
+		k.shards = &nsinformer.Options{Namespaces: namespaces, Lazy: lazy, IdleTimeout: idleTimeout}
+	}
+}
+
+// WithPodLabelIndexes indexes cached pods by the values of the given label keys
+func WithPodLabelIndexes(keys ...string) K8sConfigOptionFunc {
+	return func(k *K8sConfig) {
+		k.podLabelIndexes = keys
+	}
+}
@@ -0,0 +1,433 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kgent-api/pkg/index"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fixturePod is a pod as a Deployment creates and kubectl applies it, with the managedFields
+// of its controllers and the kubelet
+func fixturePod(i int) *corev1.Pod {
+	labels := map[string]string{"app": "web", "pod-template-hash": "5d9c8b7f6"}
+	container := corev1.Container{
+		Name:  "web",
+		Image: "registry.example.com/team/web:1.24.3",
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+		Env: []corev1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "info"},
+			{Name: "DB_URL", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "db"}, Key: "url"}}},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}
+	spec := corev1.PodSpec{Containers: []corev1.Container{container}, NodeName: fmt.Sprintf("node-%d", i%50)}
+	lastApplied, _ := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": fmt.Sprintf("web-%d", i), "labels": labels},
+		"spec":       spec,
+	})
+
+	managed := func(manager, operation, fields string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationType(operation),
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+		}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("web-%d", i),
+			Namespace:   "default",
+			Labels:      labels,
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: string(lastApplied)},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				managed("kube-controller-manager", "Update", `{"f:metadata":{"f:generateName":{},"f:labels":{".":{},"f:app":{},"f:pod-template-hash":{}},"f:ownerReferences":{".":{},"k:{\"uid\":\"0c4e1a\"}":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:env":{".":{},"k:{\"name\":\"DB_URL\"}":{".":{},"f:name":{},"f:valueFrom":{".":{},"f:secretKeyRef":{}}},"k:{\"name\":\"LOG_LEVEL\"}":{".":{},"f:name":{},"f:value":{}}},"f:image":{},"f:imagePullPolicy":{},"f:name":{},"f:ports":{".":{},"k:{\"containerPort\":8080,\"protocol\":\"TCP\"}":{".":{},"f:containerPort":{},"f:name":{},"f:protocol":{}}},"f:resources":{".":{},"f:limits":{".":{},"f:memory":{}},"f:requests":{".":{},"f:cpu":{},"f:memory":{}}},"f:terminationMessagePath":{},"f:terminationMessagePolicy":{}}},"f:dnsPolicy":{},"f:enableServiceLinks":{},"f:restartPolicy":{},"f:schedulerName":{},"f:securityContext":{},"f:terminationGracePeriodSeconds":{}}}`),
+				managed("kubelet", "Update", `{"f:status":{"f:conditions":{"k:{\"type\":\"ContainersReady\"}":{".":{},"f:lastProbeTime":{},"f:lastTransitionTime":{},"f:status":{},"f:type":{}},"k:{\"type\":\"Initialized\"}":{".":{},"f:lastProbeTime":{},"f:lastTransitionTime":{},"f:status":{},"f:type":{}},"k:{\"type\":\"Ready\"}":{".":{},"f:lastProbeTime":{},"f:lastTransitionTime":{},"f:status":{},"f:type":{}}},"f:containerStatuses":{},"f:hostIP":{},"f:hostIPs":{},"f:phase":{},"f:podIP":{},"f:podIPs":{".":{},"k:{\"ip\":\"10.0.0.1\"}":{".":{},"f:ip":{}}},"f:startTime":{}}}`),
+			},
+		},
+		Spec: spec,
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "10.0.0.1",
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+}
+
+func serializedSize(t *testing.T, objects []runtime.Object) int {
+	t.Helper()
+	total := 0
+	for _, obj := range objects {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(data)
+	}
+	return total
+}
+
+func TestStripTransform(t *testing.T) {
+	tests := []struct {
+		name              string
+		keepManagedFields bool
+		keepLastApplied   bool
+	}{
+		{name: "strip both"},
+		{name: "keep managed fields", keepManagedFields: true},
+		{name: "keep last applied", keepLastApplied: true},
+		{name: "keep both", keepManagedFields: true, keepLastApplied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformed, err := stripTransform(tt.keepManagedFields, tt.keepLastApplied)(fixturePod(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pod := transformed.(*corev1.Pod)
+			if got := len(pod.ManagedFields) > 0; got != tt.keepManagedFields {
+				t.Errorf("managedFields kept = %v, want %v", got, tt.keepManagedFields)
+			}
+			if _, got := pod.Annotations[corev1.LastAppliedConfigAnnotation]; got != tt.keepLastApplied {
+				t.Errorf("last-applied-configuration kept = %v, want %v", got, tt.keepLastApplied)
+			}
+			if pod.Labels["app"] != "web" || len(pod.Spec.Containers) != 1 {
+				t.Errorf("transform changed more than the stripped fields: %+v", pod.ObjectMeta)
+			}
+		})
+	}
+}
+
+func TestStripTransformPassesNonObjectsThrough(t *testing.T) {
+	tombstone := "default/web-0"
+	got, err := stripTransform(false, false)(tombstone)
+	if err != nil || got != tombstone {
+		t.Errorf("stripTransform(%q) = %v, %v, want it unchanged", tombstone, got, err)
+	}
+}
+
+// TestStripTransformMemory compares the size of a cache of realistic pods with and without
+// the transform, serialized size standing in for the memory the objects hold
+func TestStripTransformMemory(t *testing.T) {
+	const pods = 1000
+	transform := stripTransform(false, false)
+	var full, stripped []runtime.Object
+	for i := 0; i < pods; i++ {
+		full = append(full, fixturePod(i))
+		obj, err := transform(fixturePod(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stripped = append(stripped, obj.(runtime.Object))
+	}
+
+	fullSize, strippedSize := serializedSize(t, full), serializedSize(t, stripped)
+	saved := 1 - float64(strippedSize)/float64(fullSize)
+	t.Logf("%d pods: %d bytes cached in full, %d stripped, %.0f%% saved", pods, fullSize, strippedSize, saved*100)
+	if saved < 0.3 {
+		t.Errorf("stripping saved %.0f%% of the cache, want at least 30%%", saved*100)
+	}
+}
+
+// apiResources are the resources the fake API server serves, by group version
+var apiResources = map[string][]metav1.APIResource{
+	"v1": {
+		{Name: "pods", Namespaced: true, Kind: "Pod"},
+		{Name: "nodes", Kind: "Node"},
+		{Name: "services", Namespaced: true, Kind: "Service"},
+		{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"},
+		{Name: "secrets", Namespaced: true, Kind: "Secret"},
+		{Name: "persistentvolumeclaims", Namespaced: true, Kind: "PersistentVolumeClaim"},
+	},
+	"apps/v1": {
+		{Name: "deployments", Namespaced: true, Kind: "Deployment"},
+		{Name: "replicasets", Namespaced: true, Kind: "ReplicaSet"},
+		{Name: "statefulsets", Namespaced: true, Kind: "StatefulSet"},
+		{Name: "daemonsets", Namespaced: true, Kind: "DaemonSet"},
+	},
+	"batch/v1":  {{Name: "jobs", Namespaced: true, Kind: "Job"}, {Name: "cronjobs", Namespaced: true, Kind: "CronJob"}},
+	"policy/v1": {{Name: "poddisruptionbudgets", Namespaced: true, Kind: "PodDisruptionBudget"}},
+}
+
+// fakeAPIServer serves discovery for apiResources, empty lists of them in every namespace or
+// in one, and watches that stay open without events, counting the requests by method and path
+type fakeAPIServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	t.Helper()
+	s := &fakeAPIServer{requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(func() {
+		// The informers are never stopped, drop their watches so Close doesn't wait on them
+		s.CloseClientConnections()
+		s.Close()
+	})
+	return s
+}
+
+func (s *fakeAPIServer) count(request string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[request]
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	watching := r.URL.Query().Get("watch") == "true"
+	request := r.Method + " " + r.URL.Path
+	if watching {
+		request = "WATCH " + r.URL.Path
+	}
+	s.mu.Lock()
+	s.requests[request]++
+	s.mu.Unlock()
+
+	respond := func(body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	groupVersion := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/"), "/apis/")
+	switch {
+	case r.URL.Path == "/api":
+		respond(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case r.URL.Path == "/apis":
+		groups := metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+		for groupVersion := range apiResources {
+			if group, version, ok := strings.Cut(groupVersion, "/"); ok {
+				gv := metav1.GroupVersionForDiscovery{GroupVersion: groupVersion, Version: version}
+				groups.Groups = append(groups.Groups, metav1.APIGroup{Name: group, Versions: []metav1.GroupVersionForDiscovery{gv}, PreferredVersion: gv})
+			}
+		}
+		respond(groups)
+		return
+	case apiResources[groupVersion] != nil:
+		list := metav1.APIResourceList{TypeMeta: metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}, GroupVersion: groupVersion}
+		for _, resource := range apiResources[groupVersion] {
+			resource.Verbs = metav1.Verbs{"get", "list", "watch"}
+			list.APIResources = append(list.APIResources, resource)
+		}
+		respond(list)
+		return
+	}
+
+	// Lists in a namespace are served like those of every namespace
+	if prefix, rest, ok := strings.Cut(groupVersion, "/namespaces/"); ok {
+		_, resource, _ := strings.Cut(rest, "/")
+		groupVersion = prefix + "/" + resource
+	}
+	groupVersion, resource := path.Split(groupVersion)
+	groupVersion = strings.TrimSuffix(groupVersion, "/")
+	for _, apiResource := range apiResources[groupVersion] {
+		if apiResource.Name != resource {
+			continue
+		}
+		if watching {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		respond(map[string]interface{}{
+			"apiVersion": groupVersion,
+			"kind":       apiResource.Kind + "List",
+			"metadata":   map[string]string{"resourceVersion": "1"},
+			"items":      []interface{}{},
+		})
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// TestGettersConcurrent hammers the getters from many goroutines, each calling them in its
+// own order. Run it with -race.
+func TestGettersConcurrent(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := NewK8sConfig()
+	k.Config = &rest.Config{Host: server.URL}
+	k.cacheSyncTimeout = 10 * time.Second
+
+	type clients struct {
+		clientSet kubernetes.Interface
+		dynamic   dynamic.Interface
+		mapper    meta.RESTMapper
+		factory   informers.SharedInformerFactory
+	}
+	const goroutines = 32
+	results := make([]clients, goroutines)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			getters := []func() error{
+				func() (err error) { results[i].clientSet, err = k.ClientSet(); return },
+				func() (err error) { results[i].dynamic, err = k.Dynamic(); return },
+				func() (err error) { results[i].mapper, err = k.RESTMapper(); return },
+				func() (err error) { results[i].factory, err = k.InformerFactory(); return },
+			}
+			for j := range getters {
+				if err := getters[(i+j)%len(getters)](); err != nil {
+					t.Errorf("goroutine %d: %v", i, err)
+				}
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if err := k.Error(); err != nil {
+		t.Fatalf("Error() = %v", err)
+	}
+	for i, got := range results {
+		if got.clientSet == nil || got.dynamic == nil || got.mapper == nil || got.factory == nil {
+			t.Fatalf("goroutine %d got %+v, want every client", i, got)
+		}
+		if got.clientSet != results[0].clientSet || got.dynamic != results[0].dynamic || got.factory != results[0].factory {
+			t.Errorf("goroutine %d got other clients than goroutine 0", i)
+		}
+	}
+
+	// Discovery and the informers' lists ran once
+	for _, request := range []string{"GET /apis", "GET /apis/apps/v1", "GET /api/v1/pods", "GET /apis/apps/v1/deployments"} {
+		if got := server.count(request); got != 1 {
+			t.Errorf("%s requested %d times, want once", request, got)
+		}
+	}
+	mapping, err := results[0].mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "v1")
+	if err != nil || mapping.Resource.Resource != "deployments" {
+		t.Errorf("RESTMapping(Deployment) = %v, %v, want deployments", mapping, err)
+	}
+	if stats := k.Informers.Stats(); len(stats) != len(InformerResources) {
+		t.Errorf("%d informers registered, want %d", len(stats), len(InformerResources))
+	}
+}
+
+// TestGettersConcurrentError checks every getter returns the error of the first call, and
+// Error reports it, however many goroutines race for it
+func TestGettersConcurrentError(t *testing.T) {
+	k := NewK8sConfig()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errClientSet := k.ClientSet()
+			_, errDynamic := k.Dynamic()
+			_, errMapper := k.RESTMapper()
+			_, errFactory := k.InformerFactory()
+			_, errRegistry := k.DynamicInformerRegistry(time.Minute)
+			for _, err := range []error{errClientSet, errDynamic, errMapper, errFactory, errRegistry} {
+				if !errors.Is(err, errNilConfig) {
+					t.Errorf("getter error = %v, want %v", err, errNilConfig)
+				}
+			}
+			if k.InitClientSet() != nil || k.InitInformer() != nil {
+				t.Error("Init wrappers returned a client without a config")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := k.Error(); !errors.Is(err, errNilConfig) {
+		t.Errorf("Error() = %v, want %v", err, errNilConfig)
+	}
+}
+
+// TestInformerFactorySharded checks the shared factory only caches the cluster-scoped
+// resources when sharded, whatever the readers of the returned factory ask for
+func TestInformerFactorySharded(t *testing.T) {
+	server := newFakeAPIServer(t)
+	k := NewK8sConfig()
+	k.Config = &rest.Config{Host: server.URL}
+	k.cacheSyncTimeout = 10 * time.Second
+	WithInformerShards([]string{"default"}, false, 0)(k)
+
+	fact, err := k.InformerFactory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go k.Shards.Run(ctx)
+
+	// The readers of the services, the tracker and the recorders
+	if _, err := fact.Core().V1().Pods().Lister().Pods("default").List(labels.Everything()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fact.Apps().V1().ReplicaSets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, gvr := range InformerResources {
+		if _, err := fact.ForResource(gvr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if indexers := fact.Core().V1().Pods().Informer().GetIndexer().GetIndexers(); indexers[index.NodeIndex] == nil {
+		t.Errorf("pod indexers = %v, want the node index", indexers)
+	}
+
+	fact.Start(ctx.Done())
+	var cached []string
+	for informerType := range fact.WaitForCacheSync(ctx.Done()) {
+		cached = append(cached, informerType.String())
+	}
+	if fmt.Sprint(cached) != "[*v1.Node]" {
+		t.Errorf("shared factory caches %v, want nodes only", cached)
+	}
+	for request, want := range map[string]int{
+		"GET /api/v1/pods":                    0,
+		"GET /apis/apps/v1/replicasets":       0,
+		"GET /api/v1/namespaces/default/pods": 1,
+		"GET /api/v1/nodes":                   1,
+	} {
+		if got := server.count(request); got != want {
+			t.Errorf("%s requested %d times, want %d", request, got, want)
+		}
+	}
+	if stats := k.Informers.Stats(); len(stats) != len(InformerResources) {
+		t.Errorf("%d informers registered, want %d", len(stats), len(InformerResources))
+	}
+}
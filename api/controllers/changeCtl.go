@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"expvar"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/changes"
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/stream"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// openStreams counts the server-sent event connections currently being served
+var openStreams = expvar.NewInt("open_streams")
+
+type ChangeCtl struct {
+	changeService *services.ChangeService
+}
+
+func NewChangeCtl(service *services.ChangeService) *ChangeCtl {
+	return &ChangeCtl{changeService: service}
+}
+
+func (ch *ChangeCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		since, err := time.ParseDuration(c.DefaultQuery("since", "1h"))
+		if err != nil || since < 0 {
+			respondMessage(c, http.StatusBadRequest, "since must be a positive duration such as 30m or 1h")
+			return
+		}
+
+		records, err := ch.changeService.List(c.Query("ns"), c.Query("kind"), since)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		records = nsscope.Filter(middlewares.NamespaceScope(c), records, func(record changes.Record) string { return record.Namespace })
+		respond(c, http.StatusOK, gin.H{"data": records})
+	}
+}
+
+// Stream tails new changes as server-sent events until the client disconnects. Records a
+// slow client couldn't keep up with are dropped oldest first and reported in a "dropped"
+// event; with resync=true a "resync" event also tells the client to re-list /changes.
+// Each change carries its resourceVersion as the event ID, so a client reconnecting with
+// Last-Event-ID first gets the recorded changes it missed, or a "resync" event when the
+// buffer no longer reaches back to that ID.
+func (ch *ChangeCtl) Stream() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resync, _ := strconv.ParseBool(c.Query("resync"))
+		scope := middlewares.NamespaceScope(c)
+		lastEventID := c.GetHeader("Last-Event-ID")
+
+		var buffer *stream.Buffer[changes.Record]
+		var cancel func()
+		var missed []changes.Record
+		found := true
+		var err error
+		if lastEventID == "" {
+			buffer, cancel, err = ch.changeService.Subscribe(c.Query("ns"), c.Query("kind"))
+		} else {
+			missed, found, buffer, cancel, err = ch.changeService.Resume(c.Query("ns"), c.Query("kind"), lastEventID)
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		defer cancel()
+
+		openStreams.Add(1)
+		defer openStreams.Add(-1)
+
+		sendChanges := func(records []changes.Record) {
+			for _, record := range nsscope.Filter(scope, records, func(record changes.Record) string { return record.Namespace }) {
+				c.Render(-1, sse.Event{Id: record.ResourceVersion, Event: "change", Data: record})
+			}
+		}
+		if !found {
+			c.SSEvent("resync", gin.H{"reason": "the last event is no longer recorded, list /api/v1/changes to catch up"})
+		}
+		if !found || len(missed) > 0 {
+			sendChanges(missed)
+			c.Writer.Flush()
+		}
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-buffer.Ready():
+				records, dropped := buffer.Drain()
+				if dropped > 0 {
+					c.SSEvent("dropped", gin.H{"dropped": dropped})
+					if resync {
+						c.SSEvent("resync", gin.H{"reason": "records were dropped, list /api/v1/changes to catch up"})
+					}
+				}
+				sendChanges(records)
+				return true
+			}
+		})
+	}
+}
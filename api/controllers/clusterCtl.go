@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClusterCtl struct {
+	clusterService *services.ClusterService
+}
+
+func NewClusterCtl(service *services.ClusterService) *ClusterCtl {
+	return &ClusterCtl{clusterService: service}
+}
+
+func (cl *ClusterCtl) Capacity() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		threshold, err := strconv.ParseFloat(c.DefaultQuery("threshold", "80"), 64)
+		if err != nil || threshold <= 0 {
+			respondMessage(c, http.StatusBadRequest, "threshold must be a positive percentage")
+			return
+		}
+
+		report, err := cl.clusterService.Capacity(threshold)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": report})
+	}
+}
+
+func (cl *ClusterCtl) Health() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"data": cl.clusterService.Health(c.Request.Context())})
+	}
+}
+
+func (cl *ClusterCtl) Deprecations() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"data": cl.clusterService.Deprecations()})
+	}
+}
+
+// FlowControl returns the API Priority and Fairness configuration and the 429 responses
+// kgent-api has received, to tell API server throttling apart from other slowness
+func (cl *ClusterCtl) FlowControl() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		flowControl, err := cl.clusterService.FlowControl(c.Request.Context())
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": flowControl})
+	}
+}
+
+// Webhooks lists the admission webhooks, flagging those that fail closed with no backend
+func (cl *ClusterCtl) Webhooks() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		webhooks, err := cl.clusterService.AdmissionWebhooks(c.Request.Context())
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": webhooks})
+	}
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConsumerCtl struct {
+	consumerService *services.ConsumerService
+}
+
+func NewConsumerCtl(service *services.ConsumerService) *ConsumerCtl {
+	return &ConsumerCtl{consumerService: service}
+}
+
+// Secret lists everything referencing a Secret, by kind, with the path of each reference
+func (co *ConsumerCtl) Secret() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		consumers, err := co.consumerService.SecretConsumers(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": consumers})
+	}
+}
+
+// ConfigMap lists everything referencing a ConfigMap, by kind, with the path of each reference
+func (co *ConsumerCtl) ConfigMap() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		consumers, err := co.consumerService.ConfigMapConsumers(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": consumers})
+	}
+}
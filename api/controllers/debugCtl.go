@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/nsscope"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DebugCtl struct {
+	debugService *services.DebugService
+}
+
+func NewDebugCtl(service *services.DebugService) *DebugCtl {
+	return &DebugCtl{debugService: service}
+}
+
+func (d *DebugCtl) Informers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"data": d.debugService.Informers()})
+	}
+}
+
+func (d *DebugCtl) InformerKeys() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		keys, err := d.debugService.InformerKeys(c.Param("resource"), c.Query("ns"))
+		if err != nil {
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		}
+
+		keys = nsscope.Filter(middlewares.NamespaceScope(c), keys, func(key string) string {
+			ns, _, found := strings.Cut(key, "/")
+			if !found {
+				return ""
+			}
+			return ns
+		})
+		respond(c, http.StatusOK, gin.H{"data": keys})
+	}
+}
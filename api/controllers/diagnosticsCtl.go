@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiagnosticsCtl struct {
+	diagnosticsService *services.DiagnosticsService
+}
+
+func NewDiagnosticsCtl(service *services.DiagnosticsService) *DiagnosticsCtl {
+	return &DiagnosticsCtl{diagnosticsService: service}
+}
+
+func (d *DiagnosticsCtl) Unhealthy() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ns := namespace(c)
+
+		pendingThreshold, err := time.ParseDuration(c.DefaultQuery("pendingThreshold", "5m"))
+		if err != nil {
+			respondMessage(c, http.StatusBadRequest, "pendingThreshold must be a duration such as 5m")
+			return
+		}
+
+		pods, err := d.diagnosticsService.UnhealthyPods(c.Request.Context(), ns, pendingThreshold)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": pods})
+	}
+}
+
+func (d *DiagnosticsCtl) Orphans() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ns := namespace(c)
+
+		days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+		if err != nil || days < 0 {
+			respondMessage(c, http.StatusBadRequest, "days must be a non-negative integer")
+			return
+		}
+
+		findings, err := d.diagnosticsService.Orphans(ns, time.Duration(days)*24*time.Hour)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": findings})
+	}
+}
+
+// Restarts lists the pods whose containers restarted within the since window
+func (d *DiagnosticsCtl) Restarts() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		since, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+		if err != nil || since <= 0 {
+			respondMessage(c, http.StatusBadRequest, "since must be a positive duration such as 1h or 24h")
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": d.diagnosticsService.Restarts(namespace(c), since)})
+	}
+}
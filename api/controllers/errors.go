@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/guard"
+	"kgent-api/pkg/kustomize"
+	"kgent-api/pkg/policy"
+	"kgent-api/pkg/rawproxy"
+	"kgent-api/pkg/render"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// respondFailure writes apiErr with status, stamped with the request ID. Every error
+// response goes through it so clients can rely on the APIError body.
+func respondFailure(c *gin.Context, status int, apiErr *apierror.APIError) {
+	apiErr.RequestID = middlewares.RequestID(c)
+	respond(c, status, apiErr)
+}
+
+// respondMessage writes a failure the handler found itself, such as a missing or malformed
+// parameter, with the code of status
+func respondMessage(c *gin.Context, status int, message string) {
+	respondFailure(c, status, apierror.New(apierror.CodeForStatus(status), message))
+}
+
+// respondError writes err with the status and code of its kind, as an internal error when
+// it is of no known kind
+func respondError(c *gin.Context, err error) {
+	status, apiErr, _ := failure(err)
+	respondFailure(c, status, apiErr)
+}
+
+// respondErrorOr writes err like respondError, but with status and its code when err is of
+// no known kind
+func respondErrorOr(c *gin.Context, status int, err error) {
+	known, apiErr, ok := failure(err)
+	if !ok {
+		apiErr.Code = apierror.CodeForStatus(status)
+		known = status
+	}
+	respondFailure(c, known, apiErr)
+}
+
+// failure classifies err by the typed and sentinel errors of the services and the
+// Kubernetes API, adding the details clients need to act on it. ok is false for errors of
+// no known kind, returned as internal errors.
+func failure(err error) (status int, apiErr *apierror.APIError, ok bool) {
+	fail := func(status int, code apierror.Code) (int, *apierror.APIError, bool) {
+		return status, apierror.New(code, err.Error()), true
+	}
+
+	var preconditionErr *services.PreconditionFailedError
+	var verbErr *services.UnsupportedVerbError
+	var emptyErr *services.EmptyArgumentError
+	var invalidErr *services.InvalidArgumentError
+	var unknownErr *services.UnknownResourceError
+	var ambiguousErr *services.AmbiguousResourceError
+	var selectorErr *services.SelectorError
+	var templateErr *render.Error
+	var buildErr *kustomize.BuildError
+	var violationErr *policy.ViolationError
+	var limitErr *services.LimitBelowRequestError
+	var limitRangeErr *services.LimitRangeError
+	var deniedErr *rawproxy.DeniedError
+	switch {
+	case errors.As(err, &preconditionErr):
+		status, apiErr, ok = fail(http.StatusPreconditionFailed, apierror.PreconditionFailed)
+		apiErr.With("resourceVersion", preconditionErr.ResourceVersion).With("uid", preconditionErr.UID)
+		return
+	case errors.As(err, &verbErr):
+		status, apiErr, ok = fail(http.StatusMethodNotAllowed, apierror.MethodNotAllowed)
+		apiErr.With("verbs", verbErr.Supported)
+		return
+	case errors.As(err, &emptyErr):
+		status, apiErr, ok = fail(http.StatusBadRequest, apierror.InvalidArgument)
+		apiErr.With("argument", emptyErr.Argument)
+		return
+	case errors.As(err, &invalidErr):
+		status, apiErr, ok = fail(http.StatusBadRequest, apierror.InvalidArgument)
+		apiErr.With("argument", invalidErr.Argument)
+		return
+	case errors.As(err, &unknownErr):
+		status, apiErr, ok = fail(http.StatusNotFound, apierror.MappingNotFound)
+		apiErr.With("resource", unknownErr.Resource)
+		return
+	case errors.As(err, &ambiguousErr):
+		status, apiErr, ok = fail(http.StatusBadRequest, apierror.MappingAmbiguous)
+		apiErr.With("resource", ambiguousErr.Resource).With("candidates", ambiguousErr.Candidates)
+		return
+	case errors.As(err, &selectorErr):
+		status, apiErr, ok = fail(http.StatusBadRequest, apierror.SelectorInvalid)
+		apiErr.With("selector", selectorErr.Selector)
+		return
+	case errors.As(err, &templateErr):
+		status, apiErr, ok = fail(http.StatusBadRequest, apierror.TemplateInvalid)
+		apiErr.With("line", templateErr.Line).With("column", templateErr.Column)
+		return
+	case errors.As(err, &buildErr):
+		return fail(http.StatusUnprocessableEntity, apierror.BuildFailed)
+	case errors.As(err, &violationErr):
+		status, apiErr, ok = fail(http.StatusUnprocessableEntity, apierror.PolicyViolation)
+		apiErr.With("violations", violationErr.Violations)
+		return
+	case errors.As(err, &limitErr):
+		status, apiErr, ok = fail(http.StatusUnprocessableEntity, apierror.Invalid)
+		apiErr.With("resource", limitErr.Resource).With("request", limitErr.Request).With("limit", limitErr.Limit)
+		return
+	case errors.As(err, &limitRangeErr):
+		status, apiErr, ok = fail(http.StatusUnprocessableEntity, apierror.LimitRangeViolation)
+		apiErr.With("violations", limitRangeErr.Violations)
+		return
+	case errors.As(err, &deniedErr):
+		status, apiErr, ok = fail(http.StatusForbidden, apierror.Forbidden)
+		apiErr.With("path", deniedErr.Path)
+		return
+	case errors.Is(err, guard.ErrConfirmationRequired):
+		return fail(http.StatusPreconditionRequired, apierror.ConfirmationRequired)
+	case errors.Is(err, guard.ErrInvalidToken), errors.Is(err, guard.ErrTokenExpired):
+		return fail(http.StatusForbidden, apierror.ConfirmationRequired)
+	case errors.Is(err, services.ErrChangeRecorderDisabled),
+		errors.Is(err, services.ErrUsageHistoryDisabled),
+		errors.Is(err, services.ErrRuntimeInformersDisabled):
+		return fail(http.StatusServiceUnavailable, apierror.Unavailable)
+	case apierrors.IsNotFound(err):
+		return fail(http.StatusNotFound, apierror.ResourceNotFound)
+	case apierrors.IsAlreadyExists(err):
+		return fail(http.StatusConflict, apierror.AlreadyExists)
+	case apierrors.IsConflict(err):
+		return fail(http.StatusConflict, apierror.Conflict)
+	case apierrors.IsInvalid(err):
+		return fail(http.StatusBadRequest, apierror.Invalid)
+	case apierrors.IsBadRequest(err):
+		return fail(http.StatusBadRequest, apierror.InvalidArgument)
+	case apierrors.IsUnauthorized(err):
+		return fail(http.StatusUnauthorized, apierror.Unauthorized)
+	case apierrors.IsForbidden(err):
+		return fail(http.StatusForbidden, apierror.Forbidden)
+	case apierrors.IsRequestEntityTooLargeError(err):
+		return fail(http.StatusRequestEntityTooLarge, apierror.PayloadTooLarge)
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return fail(http.StatusGatewayTimeout, apierror.Timeout)
+	}
+	status, apiErr, _ = fail(http.StatusInternalServerError, apierror.Internal)
+	return status, apiErr, false
+}
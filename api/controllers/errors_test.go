@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/guard"
+	"kgent-api/pkg/kustomize"
+	"kgent-api/pkg/policy"
+	"kgent-api/pkg/rawproxy"
+	"kgent-api/pkg/render"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// serveFailure serves a request to a handler failing with respond, behind AssignRequestID
+func serveFailure(respond gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(middlewares.AssignRequestID())
+	router.GET("/fail", respond)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	return rec
+}
+
+// checkFailure checks rec holds an APIError body with code, stamped with the request ID
+// of the response, and returns it
+func checkFailure(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int, wantCode apierror.Code) apierror.APIError {
+	t.Helper()
+	if rec.Code != wantStatus {
+		t.Errorf("status = %d, want %d: %s", rec.Code, wantStatus, rec.Body)
+	}
+	var apiErr apierror.APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("body %s is not an APIError: %v", rec.Body, err)
+	}
+	if apiErr.Code != wantCode {
+		t.Errorf("code = %q, want %q", apiErr.Code, wantCode)
+	}
+	if header := rec.Header().Get(middlewares.RequestIDHeader); apiErr.RequestID == "" || apiErr.RequestID != header {
+		t.Errorf("requestId = %q, want the %s header %q", apiErr.RequestID, middlewares.RequestIDHeader, header)
+	}
+	if apiErr.Message == "" || apiErr.Error != apiErr.Message {
+		t.Errorf("message = %q, error = %q, want the deprecated error to repeat the message", apiErr.Message, apiErr.Error)
+	}
+	return apiErr
+}
+
+func TestRespondError(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	deployments := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apierror.Code
+		// wantDetails are the details the body carries, as JSON with sorted keys
+		wantDetails string
+	}{
+		{
+			name:        "precondition failed",
+			err:         &services.PreconditionFailedError{ResourceVersion: "42", UID: "6f1d", Err: apierrors.NewConflict(pods, "web-0", errors.New("modified"))},
+			wantStatus:  http.StatusPreconditionFailed,
+			wantCode:    apierror.PreconditionFailed,
+			wantDetails: `{"resourceVersion":"42","uid":"6f1d"}`,
+		},
+		{
+			name:        "unsupported verb",
+			err:         &services.UnsupportedVerbError{Resource: "pods/log", Verb: "delete", Supported: []string{"get"}},
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantCode:    apierror.MethodNotAllowed,
+			wantDetails: `{"verbs":["get"]}`,
+		},
+		{
+			name:        "empty argument",
+			err:         &services.EmptyArgumentError{Argument: "name"},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.InvalidArgument,
+			wantDetails: `{"argument":"name"}`,
+		},
+		{
+			name:        "invalid argument",
+			err:         &services.InvalidArgumentError{Argument: "expiration", Reason: "must be at least 10m0s"},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.InvalidArgument,
+			wantDetails: `{"argument":"expiration"}`,
+		},
+		{
+			name:        "unknown resource, wrapped",
+			err:         fmt.Errorf("failed to list: %w", &services.UnknownResourceError{Resource: "widgets"}),
+			wantStatus:  http.StatusNotFound,
+			wantCode:    apierror.MappingNotFound,
+			wantDetails: `{"resource":"widgets"}`,
+		},
+		{
+			name:        "ambiguous resource",
+			err:         &services.AmbiguousResourceError{Resource: "certificates", Candidates: []string{"certificates.cert-manager.io", "certificates.networking.internal"}, Err: errors.New("ambiguous")},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.MappingAmbiguous,
+			wantDetails: `{"candidates":["certificates.cert-manager.io","certificates.networking.internal"],"resource":"certificates"}`,
+		},
+		{
+			name:        "invalid selector",
+			err:         &services.SelectorError{Selector: "app==", Err: errors.New("found '==', expected: identifier")},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.SelectorInvalid,
+			wantDetails: `{"selector":"app=="}`,
+		},
+		{
+			name:        "invalid template",
+			err:         &render.Error{Line: 3, Column: 7, Message: "unexpected }"},
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apierror.TemplateInvalid,
+			wantDetails: `{"column":7,"line":3}`,
+		},
+		{
+			name:       "kustomize build",
+			err:        &kustomize.BuildError{Err: errors.New("accumulating resources")},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apierror.BuildFailed,
+		},
+		{
+			name:        "policy violation",
+			err:         &policy.ViolationError{Violations: []policy.Violation{{Rule: "no-latest", Path: "spec.containers[0].image", Message: "pin the image"}}},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    apierror.PolicyViolation,
+			wantDetails: `{"violations":[{"message":"pin the image","path":"spec.containers[0].image","rule":"no-latest"}]}`,
+		},
+		{
+			name:        "limit below request",
+			err:         &services.LimitBelowRequestError{Resource: "cpu", Request: "500m", Limit: "250m"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    apierror.Invalid,
+			wantDetails: `{"limit":"250m","request":"500m","resource":"cpu"}`,
+		},
+		{
+			name:        "limit range",
+			err:         &services.LimitRangeError{Violations: []services.LimitRangeViolation{{LimitRange: "defaults", Resource: "memory", Constraint: "max", Bound: "1Gi", Value: "2Gi"}}},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    apierror.LimitRangeViolation,
+			wantDetails: `{"violations":[{"bound":"1Gi","constraint":"max","limitRange":"defaults","message":"","resource":"memory","value":"2Gi"}]}`,
+		},
+		{
+			name:        "raw path denied",
+			err:         &rawproxy.DeniedError{Path: "/api/v1/watch/secrets"},
+			wantStatus:  http.StatusForbidden,
+			wantCode:    apierror.Forbidden,
+			wantDetails: `{"path":"/api/v1/watch/secrets"}`,
+		},
+		{name: "confirmation required", err: guard.ErrConfirmationRequired, wantStatus: http.StatusPreconditionRequired, wantCode: apierror.ConfirmationRequired},
+		{name: "invalid confirmation", err: guard.ErrInvalidToken, wantStatus: http.StatusForbidden, wantCode: apierror.ConfirmationRequired},
+		{name: "expired confirmation", err: guard.ErrTokenExpired, wantStatus: http.StatusForbidden, wantCode: apierror.ConfirmationRequired},
+		{name: "change recorder disabled", err: services.ErrChangeRecorderDisabled, wantStatus: http.StatusServiceUnavailable, wantCode: apierror.Unavailable},
+		{name: "usage history disabled", err: services.ErrUsageHistoryDisabled, wantStatus: http.StatusServiceUnavailable, wantCode: apierror.Unavailable},
+		{name: "runtime informers disabled", err: services.ErrRuntimeInformersDisabled, wantStatus: http.StatusServiceUnavailable, wantCode: apierror.Unavailable},
+		{name: "not found", err: apierrors.NewNotFound(pods, "web-0"), wantStatus: http.StatusNotFound, wantCode: apierror.ResourceNotFound},
+		{name: "already exists", err: apierrors.NewAlreadyExists(pods, "web-0"), wantStatus: http.StatusConflict, wantCode: apierror.AlreadyExists},
+		{name: "conflict", err: apierrors.NewConflict(pods, "web-0", errors.New("modified")), wantStatus: http.StatusConflict, wantCode: apierror.Conflict},
+		{
+			name:       "invalid",
+			err:        apierrors.NewInvalid(deployments, "web", field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), -1, "must be non-negative")}),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.Invalid,
+		},
+		{name: "bad request", err: apierrors.NewBadRequest("invalid patch"), wantStatus: http.StatusBadRequest, wantCode: apierror.InvalidArgument},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("token expired"), wantStatus: http.StatusUnauthorized, wantCode: apierror.Unauthorized},
+		{name: "forbidden", err: apierrors.NewForbidden(pods, "web-0", errors.New("RBAC")), wantStatus: http.StatusForbidden, wantCode: apierror.Forbidden},
+		{name: "too large", err: apierrors.NewRequestEntityTooLargeError("limit is 3MB"), wantStatus: http.StatusRequestEntityTooLarge, wantCode: apierror.PayloadTooLarge},
+		{name: "timeout", err: apierrors.NewTimeoutError("list timed out", 0), wantStatus: http.StatusGatewayTimeout, wantCode: apierror.Timeout},
+		{name: "server timeout", err: apierrors.NewServerTimeout(pods, "list", 0), wantStatus: http.StatusGatewayTimeout, wantCode: apierror.Timeout},
+		{name: "unknown", err: errors.New("connection reset by peer"), wantStatus: http.StatusInternalServerError, wantCode: apierror.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveFailure(func(c *gin.Context) { respondError(c, tt.err) })
+			apiErr := checkFailure(t, rec, tt.wantStatus, tt.wantCode)
+			if apiErr.Message != tt.err.Error() {
+				t.Errorf("message = %q, want %q", apiErr.Message, tt.err.Error())
+			}
+
+			details := ""
+			if apiErr.Details != nil {
+				data, _ := json.Marshal(apiErr.Details)
+				details = string(data)
+			}
+			if details != tt.wantDetails {
+				t.Errorf("details = %s, want %s", details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestRespondErrorOr(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apierror.Code
+	}{
+		{name: "known kind", err: apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web-0"), wantStatus: http.StatusNotFound, wantCode: apierror.ResourceNotFound},
+		{name: "unknown kind", err: errors.New("invalid duration \"5x\""), wantStatus: http.StatusBadRequest, wantCode: apierror.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveFailure(func(c *gin.Context) { respondErrorOr(c, http.StatusBadRequest, tt.err) })
+			checkFailure(t, rec, tt.wantStatus, tt.wantCode)
+		})
+	}
+}
+
+func TestRespondMessage(t *testing.T) {
+	tests := []struct {
+		status   int
+		wantCode apierror.Code
+	}{
+		{status: http.StatusBadRequest, wantCode: apierror.InvalidArgument},
+		{status: http.StatusNotFound, wantCode: apierror.ResourceNotFound},
+		{status: http.StatusConflict, wantCode: apierror.Conflict},
+		{status: http.StatusUnprocessableEntity, wantCode: apierror.Invalid},
+		{status: http.StatusServiceUnavailable, wantCode: apierror.Unavailable},
+		{status: http.StatusTeapot, wantCode: apierror.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			rec := serveFailure(func(c *gin.Context) { respondMessage(c, tt.status, "name is required") })
+			apiErr := checkFailure(t, rec, tt.status, tt.wantCode)
+			if apiErr.Message != "name is required" {
+				t.Errorf("message = %q, want %q", apiErr.Message, "name is required")
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/firehose"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EventCtl struct {
+	eventService *services.EventService
+}
+
+func NewEventCtl(service *services.EventService) *EventCtl {
+	return &EventCtl{eventService: service}
+}
+
+// List returns the events of a namespace grouped by involved object and reason, most recent first
+func (e *EventCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		since, err := time.ParseDuration(c.DefaultQuery("since", "1h"))
+		if err != nil || since < 0 {
+			respondMessage(c, http.StatusBadRequest, "since must be a positive duration such as 30m or 1h")
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 {
+			respondMessage(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+
+		groups, total, err := e.eventService.Events(c.Request.Context(), services.EventQuery{
+			Namespace: namespace(c),
+			Type:      c.Query("type"),
+			Since:     since,
+			GroupBy:   c.Query("groupBy"),
+			Limit:     limit,
+		})
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": groups, "total": total})
+	}
+}
+
+// Firehose streams new Warning events from every namespace as server-sent events until the
+// client disconnects. reasons limits the reasons sent, repeats of an object's reason are sent
+// once per cooldown and sample=N sends every Nth event.
+func (e *EventCtl) Firehose() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		cooldown, err := time.ParseDuration(c.DefaultQuery("cooldown", "1m"))
+		if err != nil {
+			respondMessage(c, http.StatusBadRequest, "cooldown must be a duration such as 30s or 5m")
+			return
+		}
+		sample, err := strconv.Atoi(c.DefaultQuery("sample", "1"))
+		if err != nil {
+			respondMessage(c, http.StatusBadRequest, "sample must be a positive integer")
+			return
+		}
+		var reasons []string
+		for _, reason := range strings.Split(c.Query("reasons"), ",") {
+			if reason = strings.TrimSpace(reason); reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+
+		filter := firehose.Filter{Reasons: reasons, Cooldown: cooldown, Sample: sample}
+		if scope := middlewares.NamespaceScope(c); scope.Restricted() {
+			filter.Namespaces = scope.Allows
+		}
+		subscription, cancel, err := e.eventService.Firehose(filter)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		defer cancel()
+
+		openStreams.Add(1)
+		defer openStreams.Add(-1)
+
+		buffer := subscription.Buffer()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-buffer.Ready():
+				events, dropped := buffer.Drain()
+				if dropped > 0 {
+					c.SSEvent("dropped", gin.H{"dropped": dropped})
+				}
+				for _, event := range events {
+					c.SSEvent("warning", event)
+				}
+				return true
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"kgent-api/api/services"
+	"kgent-api/pkg/helm"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HelmCtl struct {
+	helmService *services.HelmService
+}
+
+func NewHelmCtl(service *services.HelmService) *HelmCtl {
+	return &HelmCtl{helmService: service}
+}
+
+// Releases lists the latest revision of each Helm release in the namespace, every revision with history=true
+func (h *HelmCtl) Releases() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		history, _ := strconv.ParseBool(c.Query("history"))
+
+		releases, errs, err := h.helmService.Releases(namespace(c), history)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		body := gin.H{"data": releases}
+		if len(errs) > 0 {
+			body["errors"] = errs
+		}
+		respond(c, http.StatusOK, body)
+	}
+}
+
+// Manifest returns the rendered manifest of a release revision, the latest by default
+func (h *HelmCtl) Manifest() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		release, ok := h.release(c)
+		if !ok {
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": gin.H{"name": release.Name, "revision": release.Version, "manifest": release.Manifest}})
+	}
+}
+
+// Values returns the values supplied by the user for a release revision, the latest by default
+func (h *HelmCtl) Values() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		release, ok := h.release(c)
+		if !ok {
+			return
+		}
+		values := release.Config
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		respond(c, http.StatusOK, gin.H{"data": gin.H{"name": release.Name, "revision": release.Version, "values": values}})
+	}
+}
+
+// release looks up the release named in the path at the revision query, responding with the
+// error when it can't
+func (h *HelmCtl) release(c *gin.Context) (*helm.Release, bool) {
+	revision := 0
+	if v := c.Query("revision"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondMessage(c, http.StatusBadRequest, "revision must be a positive integer")
+			return nil, false
+		}
+		revision = n
+	}
+
+	release, err := h.helmService.Release(namespace(c), c.Param("name"), revision)
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return release, true
+}
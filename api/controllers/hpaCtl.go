@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HPACtl struct {
+	hpaService *services.HPAService
+}
+
+func NewHPACtl(service *services.HPAService) *HPACtl {
+	return &HPACtl{hpaService: service}
+}
+
+func (h *HPACtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hpas, err := h.hpaService.ListHPAs(c.Request.Context(), namespace(c))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": hpas})
+	}
+}
+
+// SetRange overrides the min and max replicas of an HPA
+func (h *HPACtl) SetRange() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type RangeParam struct {
+			MinReplicas *int32 `json:"minReplicas"`
+			MaxReplicas *int32 `json:"maxReplicas"`
+		}
+
+		var param RangeParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		if param.MinReplicas == nil && param.MaxReplicas == nil {
+			respondMessage(c, http.StatusBadRequest, "minReplicas or maxReplicas is required")
+			return
+		}
+
+		hpa, err := h.hpaService.SetReplicaRange(c.Request.Context(), namespace(c), c.Param("name"), param.MinReplicas, param.MaxReplicas)
+		switch {
+		case errors.Is(err, services.ErrInvalidReplicaRange):
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		case err != nil:
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": hpa})
+	}
+}
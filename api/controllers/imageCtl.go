@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/nsscope"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageCtl struct {
+	imageService *services.ImageService
+}
+
+func NewImageCtl(service *services.ImageService) *ImageCtl {
+	return &ImageCtl{imageService: service}
+}
+
+func (i *ImageCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// An empty namespace lists images across all namespaces
+		ns := c.Query("ns")
+		image := c.Query("image")
+
+		if c.Query("digestOnly") == "true" {
+			imageIDs, err := i.imageService.ListImageIDs(ns, image)
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+			scope := middlewares.NamespaceScope(c)
+			for imageID, usages := range imageIDs {
+				if imageIDs[imageID] = usagesInScope(scope, usages); len(imageIDs[imageID]) == 0 {
+					delete(imageIDs, imageID)
+				}
+			}
+			respond(c, http.StatusOK, gin.H{"data": imageIDs})
+			return
+		}
+
+		images, err := i.imageService.ListImages(ns, image)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		scope := middlewares.NamespaceScope(c)
+		for key, entry := range images {
+			if entry.Usages = usagesInScope(scope, entry.Usages); len(entry.Usages) == 0 {
+				delete(images, key)
+			}
+		}
+		respond(c, http.StatusOK, gin.H{"data": images})
+	}
+}
+
+// Consumers lists the workloads and standalone pods whose pod templates reference an image,
+// matched by match=exact (default), repository or digest
+func (i *ImageCtl) Consumers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// An empty namespace searches all namespaces
+		consumers, err := i.imageService.FindImageConsumers(c.Query("ns"), c.Query("image"), c.DefaultQuery("match", services.ImageMatchExact))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		consumers = nsscope.Filter(middlewares.NamespaceScope(c), consumers, func(consumer services.ImageConsumer) string { return consumer.Namespace })
+		respond(c, http.StatusOK, gin.H{"data": consumers})
+	}
+}
+
+// usagesInScope drops the usages in namespaces outside the namespace scope
+func usagesInScope(scope *nsscope.Scope, usages []services.ImageUsage) []services.ImageUsage {
+	return nsscope.Filter(scope, usages, func(usage services.ImageUsage) string { return usage.Namespace })
+}
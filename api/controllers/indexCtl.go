@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IndexCtl struct {
+	indexService *services.IndexService
+}
+
+func NewIndexCtl(service *services.IndexService) *IndexCtl {
+	return &IndexCtl{indexService: service}
+}
+
+// Query looks objects up by index. Without a by parameter it lists the available indexes.
+func (i *IndexCtl) Query() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resource := c.Param("resource")
+		by := c.Query("by")
+
+		if by == "" {
+			indexes, err := i.indexService.Indexes(resource)
+			if err != nil {
+				respondErrorOr(c, http.StatusBadRequest, err)
+				return
+			}
+			respond(c, http.StatusOK, gin.H{"data": gin.H{"indexes": indexes}})
+			return
+		}
+
+		key := c.Query("key")
+		if key == "" {
+			respondMessage(c, http.StatusBadRequest, "key parameter is required")
+			return
+		}
+		ns := namespace(c)
+
+		objects, err := i.indexService.Query(resource, by, key, ns)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": objects})
+	}
+}
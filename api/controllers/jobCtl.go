@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobCtl struct {
+	jobService *services.JobService
+}
+
+func NewJobCtl(service *services.JobService) *JobCtl {
+	return &JobCtl{jobService: service}
+}
+
+func (j *JobCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		jobs, err := j.jobService.ListJobs(namespace(c))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": jobs})
+	}
+}
+
+// CronJobs lists CronJobs with their next fire times, next of them (default 5)
+func (j *JobCtl) CronJobs() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		next, err := strconv.Atoi(c.DefaultQuery("next", "5"))
+		if err != nil || next < 1 || next > 100 {
+			respondMessage(c, http.StatusBadRequest, "next must be between 1 and 100")
+			return
+		}
+
+		cronJobs, err := j.jobService.ListCronJobs(namespace(c), next)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": cronJobs})
+	}
+}
+
+// Retry creates a new Job from a failed Job's spec
+func (j *JobCtl) Retry() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		job, err := j.jobService.RetryJob(c.Request.Context(), namespace(c), c.Param("name"))
+		switch {
+		case errors.Is(err, services.ErrJobNotFailed):
+			respondErrorOr(c, http.StatusConflict, err)
+			return
+		case err != nil:
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusCreated, gin.H{"data": job})
+	}
+}
+
+// Logs returns the logs of every pod the Job ran
+func (j *JobCtl) Logs() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		tailLine, err := strconv.ParseInt(c.DefaultQuery("tailLine", "100"), 10, 64)
+		if err != nil {
+			tailLine = 100
+		}
+
+		logs, err := j.jobService.JobLogs(c.Request.Context(), namespace(c), c.Param("name"), tailLine)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": logs})
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NodeCtl struct {
+	nodeService *services.NodeService
+}
+
+func NewNodeCtl(service *services.NodeService) *NodeCtl {
+	return &NodeCtl{nodeService: service}
+}
+
+// List summarizes every node the way kubectl get nodes -o wide does
+func (n *NodeCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		nodes, err := n.nodeService.Nodes()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": nodes})
+	}
+}
+
+// Get describes a node with its conditions, resources and pods
+func (n *NodeCtl) Get() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		node, err := n.nodeService.Node(c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": node})
+	}
+}
+
+// DrainPlan lists what draining a node would do with each of its pods, whether the other
+// nodes can fit them, and a planId to drain only if that hasn't changed
+func (n *NodeCtl) DrainPlan() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		plan, err := n.nodeService.DrainPlan(c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": plan})
+	}
+}
+
+// Drain cordons a node and evicts its pods. With planId it fails with 412 and the current
+// plan when the plan has changed; force=true evicts pods without a controller.
+func (n *NodeCtl) Drain() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+		if err != nil {
+			respondMessage(c, http.StatusBadRequest, "invalid force value: "+err.Error())
+			return
+		}
+
+		result, err := n.nodeService.Drain(c.Request.Context(), c.Param("name"), services.DrainOptions{
+			PlanID: c.Query("planId"),
+			Force:  force,
+			Scope:  middlewares.NamespaceScope(c),
+		})
+		var changedErr *services.PlanChangedError
+		if errors.As(err, &changedErr) {
+			respondFailure(c, http.StatusPreconditionFailed, apierror.New(apierror.PreconditionFailed, err.Error()).With("plan", changedErr.Plan))
+			return
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Per-pod outcomes are reported in the body, so the drain itself always succeeds
+		respond(c, http.StatusOK, gin.H{"data": result})
+	}
+}
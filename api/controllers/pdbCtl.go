@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PDBCtl struct {
+	pdbService *services.PDBService
+}
+
+func NewPDBCtl(service *services.PDBService) *PDBCtl {
+	return &PDBCtl{pdbService: service}
+}
+
+func (p *PDBCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ns := namespace(c)
+
+		budgets, err := p.pdbService.ListBudgets(ns)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": budgets})
+	}
+}
@@ -1,17 +1,18 @@
 package controllers
 
 import (
-	"context"
 	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	"kgent-api/api/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ContainerHeader names the container logs were read from, picked by the server when not given
+const ContainerHeader = "X-Kgent-Container"
+
 type PodLogEventCtl struct {
 	podLogEventService *services.PodLogEventService
 }
@@ -22,7 +23,7 @@ func NewPodLogEventCtl(service *services.PodLogEventService) *PodLogEventCtl {
 
 func (p *PodLogEventCtl) GetLog() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		ns := c.DefaultQuery("ns", "default")
+		ns := namespace(c)
 		podname := c.DefaultQuery("podname", "")
 		container := c.DefaultQuery("container", "")
 
@@ -32,22 +33,18 @@ func (p *PodLogEventCtl) GetLog() func(c *gin.Context) {
 			tailLine = 100
 		}
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-		defer cancel()
+		ctx := c.Request.Context()
 
-		req, err := p.podLogEventService.GetLogs(ctx, ns, podname, container, tailLine)
+		req, container, err := p.podLogEventService.GetLogs(ctx, ns, podname, container, tailLine)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
+			respondErrorOr(c, http.StatusBadRequest, err)
 			return
 		}
+		c.Header(ContainerHeader, container)
 
 		rc, err := req.Stream(ctx)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 
@@ -55,32 +52,40 @@ func (p *PodLogEventCtl) GetLog() func(c *gin.Context) {
 
 		logData, err := io.ReadAll(rc)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": string(logData)})
+		respond(c, http.StatusOK, gin.H{"data": string(logData)})
 	}
 }
 
 func (p *PodLogEventCtl) GetEvent() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		ns := c.DefaultQuery("ns", "default")
+		ns := namespace(c)
 		podname := c.DefaultQuery("podname", "")
 
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-		defer cancel()
+		ctx := c.Request.Context()
 
 		e, err := p.podLogEventService.GetEvents(ctx, ns, podname)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": e})
+	}
+}
+
+// Containers lists the containers of a pod with their states, for picking whose logs to read
+func (p *PodLogEventCtl) Containers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		containers, err := p.podLogEventService.Containers(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": e})
+		respond(c, http.StatusOK, gin.H{"data": containers})
 	}
 }
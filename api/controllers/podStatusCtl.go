@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PodStatusCtl struct {
+	podStatusService *services.PodStatusService
+}
+
+func NewPodStatusCtl(service *services.PodStatusService) *PodStatusCtl {
+	return &PodStatusCtl{podStatusService: service}
+}
+
+// Get returns the container states, probe results, taint matches and scheduling failures of a pod
+func (p *PodStatusCtl) Get() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		status, err := p.podStatusService.PodStatus(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": status})
+	}
+}
+
+// Scheduling returns the FailedScheduling events of a pod, broken down, and an approximate
+// verdict of whether it fits on each node
+func (p *PodStatusCtl) Scheduling() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		explanation, err := p.podStatusService.Scheduling(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": explanation})
+	}
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"kgent-api/pkg/rawproxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RawCtl struct {
+	proxy *rawproxy.Proxy
+}
+
+func NewRawCtl(proxy *rawproxy.Proxy) *RawCtl {
+	return &RawCtl{proxy: proxy}
+}
+
+// Proxy forwards the request to the API server path in the *path parameter and streams
+// back the response as it is, content type and status included. Denied paths are answered
+// here, with an error body like every other endpoint's.
+func (r *RawCtl) Proxy() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if err := r.proxy.Forward(c.Writer, c.Request, c.Param("path")); err != nil {
+			respondError(c, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/rawproxy"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/rest"
+)
+
+func TestRawProxyDenied(t *testing.T) {
+	proxy, err := rawproxy.New(&rest.Config{Host: "https://127.0.0.1:1"}, nil, rawproxy.DefaultDenied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl := NewRawCtl(proxy)
+
+	for _, path := range []string{"/api/v1/namespaces/default/secrets", "/api/v1/watch/secrets"} {
+		t.Run(path, func(t *testing.T) {
+			rec := serveFailure(func(c *gin.Context) {
+				c.Params = gin.Params{{Key: "path", Value: path}}
+				ctl.Proxy()(c)
+			})
+			apiErr := checkFailure(t, rec, http.StatusForbidden, apierror.Forbidden)
+			if apiErr.Details["path"] != path {
+				t.Errorf("details = %v, want the denied path %s", apiErr.Details, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RBACCtl struct {
+	rbacService *services.RBACService
+}
+
+func NewRBACCtl(service *services.RBACService) *RBACCtl {
+	return &RBACCtl{rbacService: service}
+}
+
+func (r *RBACCtl) Subjects() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		verb := c.Query("verb")
+		resource := c.Query("resource")
+		if verb == "" || resource == "" {
+			respondMessage(c, http.StatusBadRequest, "verb and resource parameters are required")
+			return
+		}
+
+		// An empty namespace checks cluster-wide access
+		namespace := c.Query("namespace")
+		resourceName := c.Query("resourceName")
+
+		grants, err := r.rbacService.Subjects(c.Request.Context(), verb, resource, resourceName, namespace)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": grants})
+	}
+}
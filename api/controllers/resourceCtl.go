@@ -1,63 +1,152 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"kgent-api/api/middlewares"
 	"kgent-api/api/services"
+	"kgent-api/pkg/audit"
+	"kgent-api/pkg/dyninformer"
+	"kgent-api/pkg/guard"
+	"kgent-api/pkg/manifest"
+	"kgent-api/pkg/warnings"
 
 	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+// ServedFromHeader tells whether an object was read from an informer cache or the API server
+const ServedFromHeader = "X-Kgent-Served-From"
+
 type ResourceCtl struct {
 	resourceService *services.ResourceService
+	// guard asks for confirmation of destructive operations, disabled when nil
+	guard *guard.Guard
+	// audit records mutating operations, only to the process log when nil
+	audit *audit.Store
 }
 
-func NewResourceCtl(service *services.ResourceService) *ResourceCtl {
-	return &ResourceCtl{resourceService: service}
+func NewResourceCtl(service *services.ResourceService, guard *guard.Guard, audit *audit.Store) *ResourceCtl {
+	return &ResourceCtl{resourceService: service, guard: guard, audit: audit}
 }
 
 func (r *ResourceCtl) List() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		if resource == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "resource parameter is required"})
+			respondMessage(c, http.StatusBadRequest, "resource parameter is required")
 			return
 		}
 
-		ns := c.DefaultQuery("ns", "default")
+		ns := namespace(c)
 
 		resourceList, err := r.resourceService.ListResource(c.Request.Context(), resource, ns)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
+			return
+		}
+
+		respondList(c, resourceList)
+	}
+}
+
+func (r *ResourceCtl) Get() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resource := c.Param("resource")
+		name := c.Param("name")
+
+		// Objects come from an informer cache when one holds the resource, live=true reads the API server
+		live, _ := strconv.ParseBool(c.Query("live"))
+		obj, servedFrom, err := r.resourceService.GetResource(c.Request.Context(), resource, namespace(c), name, live)
+		if err != nil {
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": resourceList})
+		c.Header(ServedFromHeader, servedFrom)
+		respondObject(c, obj, gin.H{"servedFrom": servedFrom})
 	}
 }
 
+// Delete removes an object named by the :name path parameter. The older form with the
+// name in the query string still works but is deprecated. See preconditions for
+// conditional deletes and guarded for deletes in protected namespaces.
 func (r *ResourceCtl) Delete() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		if resource == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "resource parameter is required"})
+			respondMessage(c, http.StatusBadRequest, "resource parameter is required")
 			return
 		}
 
-		ns := c.DefaultQuery("ns", "default")
-		name := c.Query("name")
+		name := c.Param("name")
 		if name == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "name parameter is required"})
+			name = c.Query("name")
+			c.Header("Deprecation", "true")
+			c.Header("Warning", `299 - "DELETE /resources/:resource?name= is deprecated, use DELETE /resources/:resource/:name"`)
+		}
+		if name == "" {
+			respondMessage(c, http.StatusBadRequest, "name parameter is required")
+			return
+		}
+
+		if !r.guarded(c, guard.ActionDelete, resource, namespace(c), []string{name}) {
 			return
 		}
 
-		err := r.resourceService.DeleteResource(c.Request.Context(), resource, ns, name)
+		err := r.resourceService.DeleteResource(c.Request.Context(), resource, namespace(c), name, preconditions(c))
+		r.audited(c, "delete", r.resourceService.AuditKey(resource, namespace(c), name), false, nil, err)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": "resource deleted successfully"})
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": "resource deleted successfully"}))
+	}
+}
+
+// Patch applies the request body as a patch whose type follows the Content-Type header,
+// defaulting to a JSON merge patch. See preconditions for conditional patches. Patches scaling
+// to zero or removing finalizers are guarded.
+func (r *ResourceCtl) Patch() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resource := c.Param("resource")
+		name := c.Param("name")
+
+		patchType := types.MergePatchType
+		switch c.ContentType() {
+		case string(types.JSONPatchType):
+			patchType = types.JSONPatchType
+		case string(types.StrategicMergePatchType):
+			patchType = types.StrategicMergePatchType
+		}
+
+		patch, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if action := guard.PatchAction(patchType, patch); action != "" {
+			if !r.guarded(c, action, resource, namespace(c), []string{name}) {
+				return
+			}
+		}
+
+		obj, err := r.resourceService.PatchResource(c.Request.Context(), resource, namespace(c), name, patchType, patch, preconditions(c))
+		r.audited(c, "patch", r.resourceService.AuditKey(resource, namespace(c), name), false, patch, err)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": obj}))
 	}
 }
 
@@ -65,27 +154,397 @@ func (r *ResourceCtl) Create() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var resource = c.Param("resource")
 		if resource == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "resource parameter is required"})
+			respondMessage(c, http.StatusBadRequest, "resource parameter is required")
 			return
 		}
 
 		type ResourceParam struct {
-			Yaml string `json:"yaml" binding:"required"`
+			Yaml   string                 `json:"yaml" binding:"required"`
+			Values map[string]interface{} `json:"values"`
 		}
 
 		var param ResourceParam
 		if err := c.ShouldBindJSON(&param); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// With render=true the yaml is a template expanded with values before it is created
+		manifest := param.Yaml
+		if renderFirst, _ := strconv.ParseBool(c.Query("render")); renderFirst {
+			rendered, err := r.resourceService.RenderManifest(c.Request.Context(), param.Yaml, param.Values, false)
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+			manifest = rendered
+		}
+
+		violations, err := r.resourceService.CreateResource(c.Request.Context(), resource, manifest)
+		r.auditedManifest(c, "create", manifest, false, err)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		response := gin.H{"data": "resource created successfully"}
+		if len(violations) > 0 {
+			response["violations"] = violations
+		}
+		respond(c, http.StatusCreated, withWarnings(c, response))
+	}
+}
+
+// Render expands a manifest template and optionally validates the result with a dry-run create
+func (r *ResourceCtl) Render() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type RenderParam struct {
+			Template string                 `json:"template" binding:"required"`
+			Values   map[string]interface{} `json:"values"`
+			DryRun   bool                   `json:"dryRun"`
+		}
+
+		var param RenderParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		rendered, err := r.resourceService.RenderManifest(c.Request.Context(), param.Template, param.Values, param.DryRun)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		// Failed dry runs can't be told apart from templates that failed to render
+		if param.DryRun {
+			r.auditedManifest(c, "create", rendered, true, nil)
+		}
+
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": gin.H{"yaml": rendered}}))
+	}
+}
+
+// Validate checks a manifest against the cluster's OpenAPI schema without creating it
+func (r *ResourceCtl) Validate() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type ValidateParam struct {
+			Yaml string `json:"yaml" binding:"required"`
+		}
+
+		var param ValidateParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := r.resourceService.ValidateManifest(param.Yaml)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": result})
+	}
+}
+
+// Identify resolves what each document of a manifest would become, resource, namespace and
+// name, without creating anything. checkExists=true also looks each object up.
+func (r *ResourceCtl) Identify() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type IdentifyParam struct {
+			Yaml string `json:"yaml" binding:"required"`
+		}
+
+		var param IdentifyParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		checkExists, _ := strconv.ParseBool(c.Query("checkExists"))
+
+		identities, err := r.resourceService.IdentifyManifest(c.Request.Context(), param.Yaml, namespace(c), checkExists)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": identities}))
+	}
+}
+
+func (r *ResourceCtl) CRDInstances() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		instances, err := r.resourceService.ListCRDInstances(c.Request.Context(), c.Param("crdName"), namespace(c))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": instances}))
+	}
+}
+
+// AuditTrail lists the recorded mutating operations on an object, newest first, up to limit
+// (default 50)
+func (r *ResourceCtl) AuditTrail() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if r.audit == nil {
+			respondMessage(c, http.StatusServiceUnavailable, "audit history is disabled, AUDIT_HISTORY_OBJECTS is 0")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 {
+			respondMessage(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+
+		key := r.resourceService.AuditKey(c.Param("resource"), namespace(c), c.Param("name"))
+		respond(c, http.StatusOK, gin.H{"data": r.audit.Query(key, limit)})
+	}
+}
+
+func (r *ResourceCtl) Bulk() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var resource = c.Param("resource")
+		if resource == "" {
+			respondMessage(c, http.StatusBadRequest, "resource parameter is required")
+			return
+		}
+
+		var param services.BulkRequest
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		// The namespace comes with the body rather than the ns query, defaulting and
+		// restricted alike
+		scope := middlewares.NamespaceScope(c)
+		if param.Namespace == "" {
+			param.Namespace = scope.Default()
+		}
+		if !scope.Allows(param.Namespace) {
+			respondMessage(c, http.StatusForbidden, "namespace "+param.Namespace+" is outside the namespaces this server is limited to: "+scope.String())
+			return
+		}
+
+		if param.Action == "delete" {
+			if !r.guarded(c, guard.ActionDeleteCollection, resource, param.Namespace, param.Names) {
+				return
+			}
+		}
+
+		results, err := r.resourceService.BulkAction(c.Request.Context(), resource, param)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		body, _ := json.Marshal(param)
+		for _, result := range results {
+			var itemErr error
+			if result.Error != "" {
+				itemErr = errors.New(result.Error)
+			}
+			r.audited(c, "bulk-"+param.Action, r.resourceService.AuditKey(resource, param.Namespace, result.Name), false, body, itemErr)
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Error != "" {
+				failed++
+			}
+		}
+
+		// Per-item outcomes are reported in the body, so the batch itself always succeeds
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": results, "failed": failed}))
+	}
+}
+
+// ApplyArchive server-side applies the manifests of a gzipped tarball, sent as the request body
+// or as the archive field of a multipart form
+func (r *ResourceCtl) ApplyArchive() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		force, _ := strconv.ParseBool(c.Query("force"))
+		prune, _ := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+		opts := services.ApplyOptions{
+			Namespace:     namespace(c),
+			Force:         force,
+			Prune:         prune,
+			PruneSelector: c.Query("selector"),
+		}
+
+		archive, closeArchive, ok := uploadedArchive(c)
+		if !ok {
+			return
+		}
+		defer closeArchive()
+
+		report, err := r.resourceService.ApplyArchive(c.Request.Context(), archive, opts)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		r.auditedApply(c, report)
+
+		// Per-object outcomes are reported in the body, so the apply itself always succeeds
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": report}))
+	}
+}
+
+// KustomizeBuild builds a kustomization and returns the resources as multi-document YAML.
+// The tree is a gzipped tarball, sent as the request body or as the archive field of a
+// multipart form, with the kustomization's directory in the path query, or a JSON body of
+// files by path. With apply=true the resources are applied as ApplyArchive does instead.
+func (r *ResourceCtl) KustomizeBuild() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		apply, err := strconv.ParseBool(c.DefaultQuery("apply", "false"))
+		if err != nil {
+			respondMessage(c, http.StatusBadRequest, "invalid apply value: "+err.Error())
 			return
 		}
 
-		err := r.resourceService.CreateResource(c.Request.Context(), resource, param.Yaml)
+		var files []manifest.File
+		dir := c.DefaultQuery("path", ".")
+		if c.ContentType() == "application/json" {
+			var body struct {
+				Files map[string]string `json:"files" binding:"required"`
+				Path  string            `json:"path"`
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, manifest.DefaultMaxTotalSize)
+			if err := c.ShouldBindJSON(&body); err != nil {
+				respondErrorOr(c, http.StatusBadRequest, err)
+				return
+			}
+			for name, content := range body.Files {
+				files = append(files, manifest.File{Path: name, Data: []byte(content)})
+			}
+			if body.Path != "" {
+				dir = body.Path
+			}
+		} else {
+			archive, closeArchive, ok := uploadedArchive(c)
+			if !ok {
+				return
+			}
+			defer closeArchive()
+
+			files, err = manifest.ReadTree(archive, manifest.Limits{})
+			if errors.Is(err, manifest.ErrTooLarge) {
+				respondErrorOr(c, http.StatusRequestEntityTooLarge, err)
+				return
+			}
+			if err != nil {
+				respondErrorOr(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		out, err := r.resourceService.BuildKustomization(files, dir)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if !apply {
+			c.Data(http.StatusOK, yamlContentType, out)
+			return
+		}
+
+		force, _ := strconv.ParseBool(c.Query("force"))
+		prune, _ := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+		opts := services.ApplyOptions{
+			Namespace:     namespace(c),
+			Force:         force,
+			Prune:         prune,
+			PruneSelector: c.Query("selector"),
+		}
+		report, err := r.resourceService.ApplyFiles(c.Request.Context(), []manifest.File{{Path: "kustomize build " + dir, Data: out}}, opts)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		r.auditedApply(c, report)
+		respond(c, http.StatusOK, withWarnings(c, gin.H{"data": report}))
+	}
+}
+
+// uploadedArchive returns the gzipped tarball sent as the request body or as the archive
+// field of a multipart form, and a function closing it. It responds and returns false when
+// there is none.
+func uploadedArchive(c *gin.Context) (io.Reader, func(), bool) {
+	// The compressed upload can't be larger than what it may extract to
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, manifest.DefaultMaxTotalSize)
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return uploadReader{c.Request.Body}, func() {}, true
+	}
+
+	header, err := c.FormFile("archive")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondErrorOr(c, http.StatusRequestEntityTooLarge, err)
+		return nil, nil, false
+	}
+	if err != nil {
+		respondMessage(c, http.StatusBadRequest, "archive form file is required")
+		return nil, nil, false
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondErrorOr(c, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+	return file, func() { file.Close() }, true
+}
+
+// DeletePlan lists what deleting an object would remove along with it under each propagation
+// policy, with a planId to delete it only if it hasn't changed since
+func (r *ResourceCtl) DeletePlan() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		plan, err := r.resourceService.DeletePlan(c.Request.Context(), c.Param("resource"), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": plan})
+	}
+}
+
+// uploadReader reports a request body over its size limit as manifest.ErrTooLarge
+type uploadReader struct {
+	io.Reader
+}
+
+func (u uploadReader) Read(p []byte) (int, error) {
+	n, err := u.Reader.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = fmt.Errorf("%w: upload exceeds %d bytes", manifest.ErrTooLarge, maxBytesErr.Limit)
+	}
+	return n, err
+}
+
+func (r *ResourceCtl) Describe() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resource := c.Param("resource")
+		name := c.Param("name")
+		ns := namespace(c)
+
+		format := c.DefaultQuery("format", "text")
+		if format != "text" && format != "json" {
+			respondMessage(c, http.StatusBadRequest, "format must be text or json")
+			return
+		}
+
+		description, err := r.resourceService.DescribeResource(c.Request.Context(), resource, ns, name)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"data": "resource created successfully"})
+		if format == "json" {
+			respond(c, http.StatusOK, withWarnings(c, gin.H{"data": description}))
+			return
+		}
+		c.String(http.StatusOK, description.Text())
 	}
 }
 
@@ -93,16 +552,270 @@ func (r *ResourceCtl) GetGVR() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var resource = c.Query("resource")
 		if resource == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "resource parameter is required"})
+			respondMessage(c, http.StatusBadRequest, "resource parameter is required")
+			return
+		}
+
+		resolved, err := r.resourceService.ResolveResource(resource)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": resolved})
+	}
+}
+
+// Resolve maps several resource or kind arguments in one call. Each argument resolves
+// independently, so one unknown kind does not fail the batch.
+func (r *ResourceCtl) Resolve() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type ResolveParam struct {
+			Resources []string `json:"resources" binding:"required"`
+		}
+
+		var param ResolveParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		type ResolveResult struct {
+			Resource string                     `json:"resource"`
+			Resolved *services.ResolvedResource `json:"resolved,omitempty"`
+			Error    string                     `json:"error,omitempty"`
+		}
+
+		results := make([]ResolveResult, 0, len(param.Resources))
+		for _, resource := range param.Resources {
+			result := ResolveResult{Resource: resource}
+			resolved, err := r.resourceService.ResolveResource(resource)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Resolved = resolved
+			}
+			results = append(results, result)
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": results})
+	}
+}
+
+// APIResources lists the resources served by the cluster, optionally only those supporting ?verb=
+func (r *ResourceCtl) APIResources() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		resources, err := r.resourceService.APIResources(c.Query("verb"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": resources})
+	}
+}
+
+func (r *ResourceCtl) Search() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			respondMessage(c, http.StatusBadRequest, "q parameter is required")
+			return
+		}
+
+		ns := namespace(c)
+
+		var kinds []string
+		if kindsParam := c.Query("kinds"); kindsParam != "" {
+			for _, kind := range strings.Split(kindsParam, ",") {
+				if kind = strings.TrimSpace(kind); kind != "" {
+					kinds = append(kinds, kind)
+				}
+			}
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 0 {
+			respondMessage(c, http.StatusBadRequest, "limit must be a non-negative integer")
 			return
 		}
 
-		gvr, err := r.resourceService.GetGVR(resource)
+		groups, err := r.resourceService.Search(c.Request.Context(), query, ns, kinds, limit)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": groups})
+	}
+}
+
+// Informers lists the informers started through the admin API
+func (r *ResourceCtl) Informers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"data": r.resourceService.Informers()})
+	}
+}
+
+// StartInformer starts caching a resource, in one namespace or, without one, in all namespaces
+func (r *ResourceCtl) StartInformer() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type StartInformerParam struct {
+			Resource  string `json:"resource" binding:"required"`
+			Namespace string `json:"namespace"`
+		}
+
+		var param StartInformerParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		info, err := r.resourceService.StartInformer(param.Resource, param.Namespace)
+		if errors.Is(err, dyninformer.ErrAlreadyRunning) {
+			respondErrorOr(c, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusCreated, gin.H{"data": info})
+	}
+}
+
+// StopInformer stops the informer for the :gvr resource in ?ns, or the all-namespaces one
+func (r *ResourceCtl) StopInformer() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		err := r.resourceService.StopInformer(c.Param("gvr"), c.Query("ns"))
+		if errors.Is(err, dyninformer.ErrNotRunning) {
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": *gvr})
+		respond(c, http.StatusOK, gin.H{"data": "informer stopped successfully"})
+	}
+}
+
+// withWarnings adds the API server warnings collected while serving the request to the response
+func withWarnings(c *gin.Context, response gin.H) gin.H {
+	if collected := warnings.FromContext(c.Request.Context()).Warnings(); len(collected) > 0 {
+		response["warnings"] = collected
+	}
+	return response
+}
+
+// namespace reads the namespace from the /namespaces/:ns route form, falling back to ?ns=
+// and then to the default namespace of the namespace scope
+func namespace(c *gin.Context) string {
+	if ns := c.Param("ns"); ns != "" {
+		return ns
+	}
+	return c.DefaultQuery("ns", middlewares.NamespaceScope(c).Default())
+}
+
+// guarded applies the guard rails to a destructive action on the named objects and reports
+// whether the handler may go ahead. With plan=true it responds with what the action would do
+// and, when it needs confirmation, a token to repeat it with as confirm=. Actions needing
+// confirmation are rejected without a valid token.
+func (r *ResourceCtl) guarded(c *gin.Context, action, resource, ns string, names []string) bool {
+	if r.guard == nil {
+		return true
+	}
+
+	op, err := r.resourceService.GuardedOperation(action, resource, ns, names)
+	if err != nil {
+		respondError(c, err)
+		return false
+	}
+	required := r.guard.Requires(op)
+
+	if plan, _ := strconv.ParseBool(c.Query("plan")); plan {
+		response := gin.H{"operation": op, "effect": op.Describe(), "confirmationRequired": required}
+		if required {
+			response["token"], response["expiresAt"] = r.guard.Token(op)
+		}
+		respond(c, http.StatusOK, gin.H{"data": response})
+		return false
+	}
+	if !required {
+		return true
+	}
+
+	if err := r.guard.Verify(op, c.Query("confirm")); err != nil {
+		status, apiErr, _ := failure(err)
+		respondFailure(c, status, apiErr.With("operation", op).With("effect", op.Describe()))
+		return false
+	}
+	return true
+}
+
+// audited records a mutating operation on the object of key, carried by body. err is its outcome.
+func (r *ResourceCtl) audited(c *gin.Context, action string, key audit.Key, dryRun bool, body []byte, err error) {
+	entry := audit.Entry{
+		Action:     "resource." + action,
+		Caller:     middlewares.Caller(c),
+		Group:      key.Group,
+		Resource:   key.Resource,
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+		DryRun:     dryRun,
+		Outcome:    "succeeded",
+		BodyDigest: audit.Digest(body),
+	}
+	if err != nil {
+		entry.Outcome = "failed: " + err.Error()
+	}
+	r.audit.Log(entry)
+}
+
+// auditedManifest records an operation on every named object of a manifest, defaulting
+// their namespace as creates do
+func (r *ResourceCtl) auditedManifest(c *gin.Context, action string, yaml string, dryRun bool, err error) {
+	identities, identifyErr := r.resourceService.IdentifyManifest(c.Request.Context(), yaml, "default", false)
+	if identifyErr != nil {
+		return
+	}
+	for _, identity := range identities {
+		if identity.Name == "" || identity.GVR == nil {
+			continue
+		}
+		key := audit.Key{Group: identity.GVR.Group, Resource: identity.GVR.Resource, Namespace: identity.Namespace, Name: identity.Name}
+		r.audited(c, action, key, dryRun, []byte(yaml), err)
+	}
+}
+
+// auditedApply records the outcome of every object an apply touched
+func (r *ResourceCtl) auditedApply(c *gin.Context, report *services.ApplyReport) {
+	record := func(action string, object services.ApplyObjectResult) {
+		var err error
+		if object.Error != "" {
+			err = errors.New(object.Error)
+		}
+		r.audited(c, action, r.resourceService.AuditKeyForKind(object.APIVersion, object.Kind, object.Namespace, object.Name), false, nil, err)
+	}
+	for _, file := range report.Files {
+		for _, object := range file.Objects {
+			record("apply", object)
+		}
+	}
+	for _, object := range report.Pruned {
+		record("prune", object)
+	}
+}
+
+// preconditions reads the resourceVersion a write is conditional on from the If-Match header,
+// as an entity tag, the resourceVersion query parameter or the planId of a delete plan, and
+// the UID from the uid parameter
+func preconditions(c *gin.Context) services.Preconditions {
+	rv := c.DefaultQuery("resourceVersion", c.Query("planId"))
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != "*" {
+		rv = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
 	}
+	return services.Preconditions{ResourceVersion: rv, UID: types.UID(c.Query("uid"))}
 }
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestResourceCtl serves objects from a fake clientset, with the pod informer synced, and
+// the fake dynamic client it returns
+func newTestResourceCtl(t *testing.T, objects ...runtime.Object) (*ResourceCtl, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	var restMapper meta.RESTMapper = mapper
+
+	fact := informers.NewSharedInformerFactory(fake.NewSimpleClientset(objects...), 0)
+	if _, err := fact.ForResource(corev1.SchemeGroupVersion.WithResource("pods")); err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	fact.Start(stopCh)
+	fact.WaitForCacheSync(stopCh)
+
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, objects...)
+	return NewResourceCtl(services.NewResourceService(&restMapper, client, fact), nil, nil), client
+}
+
+// newTestRouter routes the resource endpoints like the server, behind AssignRequestID
+func newTestRouter(ctl *ResourceCtl) *gin.Engine {
+	router := gin.New()
+	router.Use(middlewares.AssignRequestID())
+	router.GET("/resources/:resource", ctl.List())
+	router.GET("/resources/:resource/:name", ctl.Get())
+	router.POST("/resources/:resource", ctl.Create())
+	router.DELETE("/resources/:resource", ctl.Delete())
+	router.DELETE("/resources/:resource/:name", ctl.Delete())
+	router.GET("/namespaces/:ns/resources/:resource/:name", ctl.Get())
+	router.DELETE("/namespaces/:ns/resources/:resource/:name", ctl.Delete())
+	router.PATCH("/resources/:resource/:name", ctl.Patch())
+	return router
+}
+
+func testPod(ns, name string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+	}
+}
+
+func TestResourceCtl(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		// wantCode is the APIError code of failures
+		wantCode apierror.Code
+	}{
+		{name: "list", method: http.MethodGet, target: "/resources/pods", wantStatus: http.StatusOK},
+		{name: "list unknown resource", method: http.MethodGet, target: "/resources/widgets", wantStatus: http.StatusNotFound, wantCode: apierror.MappingNotFound},
+		{name: "get", method: http.MethodGet, target: "/resources/pods/web-0", wantStatus: http.StatusOK},
+		{name: "get from namespace path", method: http.MethodGet, target: "/namespaces/prod/resources/pods/api-0", wantStatus: http.StatusOK},
+		{name: "get missing", method: http.MethodGet, target: "/resources/pods/web-9", wantStatus: http.StatusNotFound, wantCode: apierror.ResourceNotFound},
+		{
+			name:       "create",
+			method:     http.MethodPost,
+			target:     "/resources/configmaps",
+			body:       `{"yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "create without yaml",
+			method:     http.MethodPost,
+			target:     "/resources/configmaps",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.InvalidArgument,
+		},
+		{name: "delete", method: http.MethodDelete, target: "/resources/pods/web-0", wantStatus: http.StatusOK},
+		{name: "delete missing", method: http.MethodDelete, target: "/resources/pods/web-9", wantStatus: http.StatusNotFound, wantCode: apierror.ResourceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl, _ := newTestResourceCtl(t, testPod("default", "web-0"), testPod("prod", "api-0"))
+			router := newTestRouter(ctl)
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var apiErr apierror.APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", apiErr.Code, tt.wantCode)
+			}
+			if apiErr.RequestID == "" || apiErr.RequestID != rec.Header().Get(middlewares.RequestIDHeader) {
+				t.Errorf("requestId = %q, want the %s header %q", apiErr.RequestID, middlewares.RequestIDHeader, rec.Header().Get(middlewares.RequestIDHeader))
+			}
+		})
+	}
+}
+
+func TestResourceCtlDelete(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		wantStatus     int
+		wantDeprecated bool
+		// wantDeleted is the namespace and name of the pod deleted
+		wantDeleted [2]string
+	}{
+		{
+			name:           "name query",
+			target:         "/resources/pods?name=web-0",
+			wantStatus:     http.StatusOK,
+			wantDeprecated: true,
+			wantDeleted:    [2]string{"default", "web-0"},
+		},
+		{
+			name:           "name and namespace queries",
+			target:         "/resources/pods?ns=prod&name=api-0",
+			wantStatus:     http.StatusOK,
+			wantDeprecated: true,
+			wantDeleted:    [2]string{"prod", "api-0"},
+		},
+		{name: "no name", target: "/resources/pods", wantStatus: http.StatusBadRequest, wantDeprecated: true},
+		{name: "name path", target: "/resources/pods/web-0", wantStatus: http.StatusOK, wantDeleted: [2]string{"default", "web-0"}},
+		{name: "name path and namespace query", target: "/resources/pods/api-0?ns=prod", wantStatus: http.StatusOK, wantDeleted: [2]string{"prod", "api-0"}},
+		{name: "namespace path", target: "/namespaces/prod/resources/pods/api-0", wantStatus: http.StatusOK, wantDeleted: [2]string{"prod", "api-0"}},
+		// The path namespace wins over the query
+		{name: "namespace path over query", target: "/namespaces/prod/resources/pods/api-0?ns=default", wantStatus: http.StatusOK, wantDeleted: [2]string{"prod", "api-0"}},
+		{name: "namespace path, other namespace's pod", target: "/namespaces/prod/resources/pods/web-0", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl, _ := newTestResourceCtl(t, testPod("default", "web-0"), testPod("prod", "api-0"))
+			rec := httptest.NewRecorder()
+			newTestRouter(ctl).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("DELETE %s = %d, want %d: %s", tt.target, rec.Code, tt.wantStatus, rec.Body)
+			}
+			deprecated := rec.Header().Get("Deprecation") == "true"
+			if deprecated != tt.wantDeprecated {
+				t.Errorf("Deprecation header set = %v, want %v", deprecated, tt.wantDeprecated)
+			}
+			if warning := rec.Header().Get("Warning"); deprecated && !strings.HasPrefix(warning, "299 - ") {
+				t.Errorf("Warning = %q, want a 299 deprecation warning", warning)
+			}
+
+			if tt.wantDeleted[1] == "" {
+				return
+			}
+			ns, name := tt.wantDeleted[0], tt.wantDeleted[1]
+			_, _, err := ctl.resourceService.GetResource(context.Background(), "pods", ns, name, true)
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("pod %s/%s still there after delete: %v", ns, name, err)
+			}
+		})
+	}
+}
+
+func TestResourceCtlPreconditionFailed(t *testing.T) {
+	stored := testPod("default", "web-0")
+	stored.ResourceVersion = "42"
+	stored.UID = "6f1d"
+
+	tests := []struct {
+		name   string
+		header string
+		target string
+		// wantSent is the precondition the patch sent to the API server carries
+		wantSent string
+	}{
+		{name: "If-Match", header: `"41"`, target: "/resources/pods/web-0", wantSent: `"resourceVersion":"41"`},
+		{name: "weak If-Match", header: `W/"41"`, target: "/resources/pods/web-0", wantSent: `"resourceVersion":"41"`},
+		{name: "resourceVersion query", target: "/resources/pods/web-0?resourceVersion=41", wantSent: `"resourceVersion":"41"`},
+		{name: "uid query", target: "/resources/pods/web-0?uid=0c4e", wantSent: `"uid":"0c4e"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl, client := newTestResourceCtl(t, stored)
+			// The API server rejects patches carrying preconditions that no longer match
+			var sent []byte
+			client.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				sent = action.(k8stesting.PatchAction).GetPatch()
+				return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "web-0", errors.New("the object has been modified"))
+			})
+
+			req := httptest.NewRequest(http.MethodPatch, tt.target, strings.NewReader(`{"metadata":{"labels":{"tier":"web"}}}`))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			newTestRouter(ctl).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusPreconditionFailed {
+				t.Fatalf("PATCH %s = %d, want %d: %s", tt.target, rec.Code, http.StatusPreconditionFailed, rec.Body)
+			}
+			var apiErr apierror.APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if apiErr.Code != apierror.PreconditionFailed {
+				t.Errorf("code = %q, want %q", apiErr.Code, apierror.PreconditionFailed)
+			}
+			// The details tell the client the version to retry against
+			if apiErr.Details["resourceVersion"] != "42" || apiErr.Details["uid"] != "6f1d" {
+				t.Errorf("details = %v, want resourceVersion 42 and uid 6f1d", apiErr.Details)
+			}
+			if !strings.Contains(string(sent), tt.wantSent) {
+				t.Errorf("patch sent = %s, want it to carry %s", sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/warnings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const yamlContentType = "application/yaml; charset=utf-8"
+
+// yamlMediaTypes are the Accept media types answered with YAML
+var yamlMediaTypes = map[string]bool{
+	"application/yaml":   true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+}
+
+// wantsYAML reports whether the client asked for YAML, with ?output=yaml or an Accept header
+// naming a YAML media type before any JSON one. JSON stays the default.
+func wantsYAML(c *gin.Context) bool {
+	if output := c.Query("output"); output != "" {
+		return output == "yaml"
+	}
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if yamlMediaTypes[mediaType] {
+			return true
+		}
+		if mediaType == "application/json" {
+			return false
+		}
+	}
+	return false
+}
+
+// respond writes body with code as JSON, or as YAML when the client asks for it. Every
+// controller responds through it so new endpoints negotiate the format for free.
+func respond(c *gin.Context, code int, body any) {
+	if !wantsYAML(c) {
+		c.JSON(code, body)
+		return
+	}
+
+	out, err := yaml.Marshal(body)
+	if err != nil {
+		apiErr := apierror.New(apierror.Internal, fmt.Sprintf("failed to encode YAML: %v", err))
+		apiErr.RequestID = middlewares.RequestID(c)
+		c.JSON(http.StatusInternalServerError, apiErr)
+		return
+	}
+	c.Data(code, yamlContentType, out)
+}
+
+// respondObject writes a Kubernetes object. JSON keeps the data envelope, along with fields,
+// while YAML is the object alone so it can be piped back into kubectl; API warnings then go
+// in Warning headers.
+func respondObject(c *gin.Context, obj *unstructured.Unstructured, fields gin.H) {
+	if !wantsYAML(c) {
+		response := gin.H{"data": obj}
+		for key, value := range fields {
+			response[key] = value
+		}
+		c.JSON(http.StatusOK, withWarnings(c, response))
+		return
+	}
+
+	setWarningHeaders(c)
+	respond(c, http.StatusOK, obj.Object)
+}
+
+// respondList writes Kubernetes objects. In YAML they form a v1 List, or documents separated
+// by --- with ?listFormat=docs.
+func respondList(c *gin.Context, items []runtime.Object) {
+	if !wantsYAML(c) {
+		c.JSON(http.StatusOK, withWarnings(c, gin.H{"data": items}))
+		return
+	}
+	setWarningHeaders(c)
+
+	objects := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		obj, err := toUnstructured(item)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		objects = append(objects, obj)
+	}
+
+	if c.Query("listFormat") != "docs" {
+		respond(c, http.StatusOK, map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": objects})
+		return
+	}
+
+	docs := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			respondError(c, fmt.Errorf("failed to encode YAML: %w", err))
+			return
+		}
+		docs = append(docs, string(out))
+	}
+	c.Data(http.StatusOK, yamlContentType, []byte(strings.Join(docs, "---\n")))
+}
+
+// toUnstructured converts a listed object to its unstructured content. Typed objects from the
+// informer caches have no apiVersion and kind, which are restored from the client-go scheme.
+func toUnstructured(item runtime.Object) (map[string]interface{}, error) {
+	if u, ok := item.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %T: %w", item, err)
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	if obj.GetKind() == "" {
+		if gvks, _, err := scheme.Scheme.ObjectKinds(item); err == nil && len(gvks) > 0 {
+			obj.SetGroupVersionKind(gvks[0])
+		}
+	}
+	return obj.Object, nil
+}
+
+// setWarningHeaders reports the collected API warnings the way the API server does
+func setWarningHeaders(c *gin.Context) {
+	for _, warning := range warnings.FromContext(c.Request.Context()).Warnings() {
+		c.Writer.Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+	"kgent-api/pkg/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountCtl struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+func NewServiceAccountCtl(service *services.ServiceAccountService) *ServiceAccountCtl {
+	return &ServiceAccountCtl{serviceAccountService: service}
+}
+
+func (s *ServiceAccountCtl) CreateToken() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		ns := namespace(c)
+
+		type TokenParam struct {
+			Expiration string   `json:"expiration"`
+			Audiences  []string `json:"audiences"`
+			Kubeconfig bool     `json:"kubeconfig"`
+		}
+
+		var param TokenParam
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&param); err != nil {
+				respondErrorOr(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		var expiration time.Duration
+		if param.Expiration != "" {
+			var err error
+			expiration, err = time.ParseDuration(param.Expiration)
+			if err != nil {
+				respondMessage(c, http.StatusBadRequest, "expiration must be a duration such as 1h")
+				return
+			}
+		}
+
+		entry := audit.Entry{
+			Action:    "serviceaccount.token.create",
+			Caller:    middlewares.Caller(c),
+			Resource:  "serviceaccounts",
+			Namespace: ns,
+			Name:      name,
+			Details:   map[string]string{"expiration": param.Expiration},
+		}
+
+		token, err := s.serviceAccountService.CreateToken(c.Request.Context(), ns, name, expiration, param.Audiences, param.Kubeconfig)
+		if err != nil {
+			entry.Outcome = "failed: " + err.Error()
+			audit.Log(entry)
+			respondError(c, err)
+			return
+		}
+
+		entry.Outcome = "issued"
+		entry.Details["expiresAt"] = token.ExpirationTimestamp.Format(time.RFC3339)
+		audit.Log(entry)
+
+		respond(c, http.StatusCreated, gin.H{"data": token})
+	}
+}
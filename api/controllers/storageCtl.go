@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StorageCtl struct {
+	storageService *services.StorageService
+}
+
+func NewStorageCtl(service *services.StorageService) *StorageCtl {
+	return &StorageCtl{storageService: service}
+}
+
+func (s *StorageCtl) Volumes() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		volumes, err := s.storageService.ListVolumes(c.Request.Context())
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": volumes})
+	}
+}
+
+func (s *StorageCtl) ClaimTimeline() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		timeline, err := s.storageService.ClaimTimeline(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": timeline})
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageCtl struct {
+	usageService *services.UsageService
+}
+
+func NewUsageCtl(service *services.UsageService) *UsageCtl {
+	return &UsageCtl{usageService: service}
+}
+
+// PodHistory returns the sampled CPU and memory usage of a pod, oldest first
+func (u *UsageCtl) PodHistory() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		history, err := u.usageService.PodHistory(namespace(c), c.Param("name"))
+		if err != nil {
+			respondUsageError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": history})
+	}
+}
+
+// NodeHistory returns the sampled CPU and memory usage of a node, oldest first
+func (u *UsageCtl) NodeHistory() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		history, err := u.usageService.NodeHistory(c.Param("name"))
+		if err != nil {
+			respondUsageError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": history})
+	}
+}
+
+func respondUsageError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrUsageHistoryDisabled) {
+		respondErrorOr(c, http.StatusServiceUnavailable, err)
+		return
+	}
+	respondError(c, err)
+}
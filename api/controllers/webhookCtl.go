@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookCtl struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookCtl(service *services.WebhookService) *WebhookCtl {
+	return &WebhookCtl{webhookService: service}
+}
+
+func (w *WebhookCtl) Create() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var param services.WebhookRequest
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		// Subscriptions without a namespace would deliver events from every namespace
+		if scope := middlewares.NamespaceScope(c); scope.Restricted() && !scope.Allows(param.Namespace) {
+			respondMessage(c, http.StatusForbidden, "subscriptions must name one of "+scope.String())
+			return
+		}
+
+		sub, err := w.webhookService.Create(param)
+		if err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		respond(c, http.StatusCreated, gin.H{"data": sub})
+	}
+}
+
+func (w *WebhookCtl) List() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, gin.H{"data": w.webhookService.List()})
+	}
+}
+
+func (w *WebhookCtl) Delete() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if err := w.webhookService.Delete(c.Param("id")); err != nil {
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": "webhook deleted successfully"})
+	}
+}
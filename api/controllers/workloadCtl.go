@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"kgent-api/api/services"
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type WorkloadCtl struct {
+	workloadService *services.WorkloadService
+}
+
+func NewWorkloadCtl(service *services.WorkloadService) *WorkloadCtl {
+	return &WorkloadCtl{workloadService: service}
+}
+
+// RolloutStatus reports the rollout state of a Deployment, including whether it is paused
+func (w *WorkloadCtl) RolloutStatus() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		status, err := w.workloadService.DeploymentRolloutStatus(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": status})
+	}
+}
+
+// RolloutStream follows a Deployment rollout as server-sent events: a status event with the
+// rollout status on every change to the Deployment, its ReplicaSets or their pods, then a
+// done event once the rollout completes, stalls, is paused or the Deployment is deleted
+func (w *WorkloadCtl) RolloutStream() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ns, name := namespace(c), c.Param("name")
+		changes, stop, err := w.workloadService.WatchRollout(ns, name)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		defer stop()
+
+		openStreams.Add(1)
+		defer openStreams.Add(-1)
+
+		// The first status goes out right away, the rollout may be over already
+		ready := make(chan struct{}, 1)
+		ready <- struct{}{}
+		c.Stream(func(_ io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ready:
+			case <-changes.Ready():
+				changes.Drain()
+			}
+
+			status, err := w.workloadService.CachedRolloutStatus(ns, name)
+			if apierrors.IsNotFound(err) {
+				c.SSEvent("done", gin.H{"outcome": services.RolloutOutcomeDeleted})
+				return false
+			}
+			if err != nil {
+				c.SSEvent("error", gin.H{"error": err.Error()})
+				return false
+			}
+			c.SSEvent("status", status)
+			if outcome := services.RolloutOutcome(status); outcome != "" {
+				c.SSEvent("done", gin.H{"outcome": outcome, "data": status})
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// Pause stops a Deployment from rolling out template changes
+func (w *WorkloadCtl) Pause() func(c *gin.Context) {
+	return w.setPaused(true)
+}
+
+// Resume continues a paused Deployment rollout
+func (w *WorkloadCtl) Resume() func(c *gin.Context) {
+	return w.setPaused(false)
+}
+
+func (w *WorkloadCtl) setPaused(paused bool) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type PauseParam struct {
+			ChangeCause string `json:"changeCause"`
+		}
+
+		var param PauseParam
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&param); err != nil {
+				respondErrorOr(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		status, changed, err := w.workloadService.SetDeploymentPaused(c.Request.Context(), namespace(c), c.Param("name"), paused, param.ChangeCause)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		message := "deployment resumed"
+		switch {
+		case paused && changed:
+			message = "deployment paused"
+		case paused:
+			message = "deployment is already paused"
+		case !changed:
+			message = "deployment is not paused"
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": status, "message": message})
+	}
+}
+
+// StatefulSetStatus reports how many pods are on the update revision versus the current one
+func (w *WorkloadCtl) StatefulSetStatus() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		status, err := w.workloadService.StatefulSetRolloutStatus(c.Request.Context(), namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": status})
+	}
+}
+
+// Partition returns the rolling update partition of a StatefulSet along with its rollout status
+func (w *WorkloadCtl) Partition() func(c *gin.Context) {
+	return w.StatefulSetStatus()
+}
+
+// SetPartition moves the rolling update partition of a StatefulSet to step a canary rollout
+func (w *WorkloadCtl) SetPartition() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		type PartitionParam struct {
+			Partition *int32 `json:"partition" binding:"required"`
+		}
+
+		var param PartitionParam
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+
+		status, err := w.workloadService.SetStatefulSetPartition(c.Request.Context(), namespace(c), c.Param("name"), *param.Partition)
+		var invalid *services.InvalidPartitionError
+		switch {
+		case errors.As(err, &invalid):
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, services.ErrOnDeleteStrategy):
+			respondErrorOr(c, http.StatusConflict, err)
+			return
+		case err != nil:
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": status})
+	}
+}
+
+// SetResources changes the requests and limits of a container in a workload's pod template,
+// only checking the change with dryRun=true
+func (w *WorkloadCtl) SetResources() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var param services.ContainerResourcesRequest
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+		change, err := w.workloadService.SetContainerResources(c.Request.Context(), c.Param("kind"), namespace(c), c.Param("name"), param, dryRun)
+		if errors.Is(err, services.ErrContainerNotFound) {
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": change})
+	}
+}
+
+// SetEnv changes the environment variables of a container in a workload's pod template.
+// strict=true fails on removing missing variables and overrideValueFrom=true lets plain
+// values replace variables taken from secrets.
+func (w *WorkloadCtl) SetEnv() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var param services.ContainerEnvRequest
+		if err := c.ShouldBindJSON(&param); err != nil {
+			respondErrorOr(c, http.StatusBadRequest, err)
+			return
+		}
+		strict, _ := strconv.ParseBool(c.Query("strict"))
+		override, _ := strconv.ParseBool(c.Query("overrideValueFrom"))
+
+		change, err := w.workloadService.SetContainerEnv(c.Request.Context(), c.Param("kind"), namespace(c), c.Param("name"), param,
+			services.EnvOptions{Strict: strict, OverrideValueFrom: override})
+		switch {
+		case errors.Is(err, services.ErrContainerNotFound), errors.Is(err, services.ErrEnvNotFound):
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		case errors.Is(err, services.ErrEnvValueFrom):
+			respondErrorOr(c, http.StatusConflict, err)
+			return
+		case err != nil:
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": change})
+	}
+}
+
+// Restart triggers a rolling restart of the named workload of the given resource type
+func (w *WorkloadCtl) Restart(resource string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ns := namespace(c)
+		name := c.Param("name")
+
+		if err := w.workloadService.RestartWorkload(c.Request.Context(), resource, ns, name); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": resource + "/" + name + " restarted"})
+	}
+}
+
+// RestartOnNode bounces the DaemonSet's pod on a single node
+func (w *WorkloadCtl) RestartOnNode() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		node := c.Query("node")
+		if node == "" {
+			respondMessage(c, http.StatusBadRequest, "node parameter is required")
+			return
+		}
+
+		pod, err := w.workloadService.RestartDaemonSetOnNode(c.Request.Context(), namespace(c), c.Param("name"), node)
+		var ambiguous *services.AmbiguousPodsError
+		switch {
+		case errors.As(err, &ambiguous):
+			respondFailure(c, http.StatusConflict, apierror.New(apierror.Conflict, err.Error()).With("pods", ambiguous.Pods))
+			return
+		case errors.Is(err, services.ErrNoPodOnNode):
+			respondErrorOr(c, http.StatusNotFound, err)
+			return
+		case err != nil:
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": gin.H{"deleted": pod}})
+	}
+}
+
+// PodOwner returns the chain of controllers owning a pod, up to its workload
+func (w *WorkloadCtl) PodOwner() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		owners, err := w.workloadService.PodOwners(namespace(c), c.Param("name"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": owners})
+	}
+}
+
+// Pods lists the pods owned by a Deployment, ReplicaSet, StatefulSet, DaemonSet, Job or CronJob
+func (w *WorkloadCtl) Pods() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		byRevision, _ := strconv.ParseBool(c.Query("byRevision"))
+		pods, err := w.workloadService.WorkloadPods(c.Param("kind"), namespace(c), c.Param("name"), byRevision)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": pods})
+	}
+}
@@ -2,79 +2,463 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"kgent-api/api/config"
 	"kgent-api/api/controllers"
+	"kgent-api/api/middlewares"
 	"kgent-api/api/services"
+	"kgent-api/pkg/audit"
+	"kgent-api/pkg/changes"
+	"kgent-api/pkg/clientcache"
+	"kgent-api/pkg/clientutil"
+	"kgent-api/pkg/firehose"
+	"kgent-api/pkg/guard"
+	"kgent-api/pkg/kustomize"
+	"kgent-api/pkg/leader"
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/objdiff"
+	"kgent-api/pkg/policy"
+	"kgent-api/pkg/preflight"
+	"kgent-api/pkg/profiling"
+	"kgent-api/pkg/rawproxy"
+	"kgent-api/pkg/restarts"
+	"kgent-api/pkg/usage"
+	"kgent-api/pkg/version"
+	"kgent-api/pkg/warnings"
+	"kgent-api/pkg/webhook"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	log.Printf("Starting kgent-api %s", version.Version)
+
+	// Server warnings (e.g. deprecated APIs) are counted for the deprecations report
+	warningRecorder := warnings.NewRecorder(256)
+	// 429 responses are recorded to show when API Priority and Fairness throttles kgent-api
+	throttling := clientutil.NewThrottling()
+
+	// ALLOWED_NAMESPACES and DENIED_NAMESPACES limit every endpoint to a set of namespaces
+	namespaceScope, err := nsscope.New(
+		splitList(os.Getenv("ALLOWED_NAMESPACES")),
+		splitList(os.Getenv("DENIED_NAMESPACES")),
+		envBool("ALLOW_CLUSTER_SCOPED"),
+	)
+	if err != nil {
+		log.Fatalf("Invalid namespace scope: %v", err)
+	}
+	informerNamespace, _ := namespaceScope.Single()
+
+	// KUSTOMIZE_ALLOWED_HOSTS lists the hosts kustomizations may pull remote bases from
+	kustomizeBuilder, err := kustomize.NewBuilder(splitList(os.Getenv("KUSTOMIZE_ALLOWED_HOSTS")))
+	if err != nil {
+		log.Fatalf("Invalid KUSTOMIZE_ALLOWED_HOSTS: %v", err)
+	}
+
+	// Client metrics must be registered before the first client is created
+	clientutil.RegisterMetrics()
+
 	// Initialize Kubernetes configuration and clients
 	k8sconfig := config.NewK8sConfig().InitRestConfig(
-		config.WithQps(100),
-		config.WithBurst(200),
+		config.WithQps(float32(envFloat("K8S_CLIENT_QPS", 100))),
+		config.WithBurst(envInt("K8S_CLIENT_BURST", 200)),
+		config.WithInformerRateLimits(float32(envFloat("K8S_INFORMER_QPS", 0)), envInt("K8S_INFORMER_BURST", 0)),
 		config.WithTimeout(30),
+		config.WithWarningHandler(warningRecorder),
+		config.WithTransportWrapper(throttling.WrapTransport),
+		config.WithUserAgent(os.Getenv("K8S_USER_AGENT")),
+		config.WithManagedFields(envBool("INFORMER_KEEP_MANAGED_FIELDS")),
+		config.WithLastAppliedConfig(envBool("INFORMER_KEEP_LAST_APPLIED")),
+		config.WithPodLabelIndexes(strings.Split(envOrDefault("POD_INDEX_LABELS", "app,app.kubernetes.io/name"), ",")...),
+		config.WithCacheSyncTimeout(envDuration("INFORMER_SYNC_TIMEOUT", 0)),
+		config.WithInformerNamespace(informerNamespace),
+		// On very large clusters INFORMER_SHARD_NAMESPACES and INFORMER_SHARD_LAZY cache
+		// namespaced resources per namespace instead of cluster-wide
+		config.WithInformerShards(splitList(os.Getenv("INFORMER_SHARD_NAMESPACES")), envBool("INFORMER_SHARD_LAZY"),
+			envDuration("INFORMER_SHARD_IDLE_TIMEOUT", 30*time.Minute)),
 	)
 	if err := k8sconfig.Error(); err != nil {
 		log.Fatalf("Failed to initialize Kubernetes config: %v", err)
 	}
+	clientSet, err := k8sconfig.ClientSet()
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	// Preflight checks catch an unreachable API server, broken discovery and missing RBAC
+	// before the informers wait on them. PREFLIGHT_FAILURE=degraded serves reads despite them.
+	preflightReport := preflight.Run(context.Background(), clientSet, envDuration("PREFLIGHT_TIMEOUT", 10*time.Second), informerNamespace, config.InformerResources)
+	degraded := checkPreflight(preflightReport, envOrDefault("PREFLIGHT_FAILURE", "exit"))
+	if degraded && os.Getenv("INFORMER_SYNC_TIMEOUT") == "" {
+		// Caches lacking permissions would never sync, so don't wait for them
+		config.WithCacheSyncTimeout(time.Minute)(k8sconfig)
+	}
+
+	restMapper, err := k8sconfig.RESTMapper()
+	if err != nil {
+		log.Fatalf("Failed to discover API resources: %v", err)
+	}
+	dynamicClient, err := k8sconfig.Dynamic()
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes dynamic client: %v", err)
+	}
+	informer, err := k8sconfig.InformerFactory()
+	if err != nil {
+		log.Fatalf("Failed to start informers: %v", err)
+	}
 
-	restMapper := k8sconfig.InitRestMapper()
-	dynamicClient := k8sconfig.InitDynamicClient()
-	informer := k8sconfig.InitInformer()
-	clientSet := k8sconfig.InitClientSet()
+	// Informers for other resources are started at runtime and stopped when left idle
+	dynamicInformers, err := k8sconfig.DynamicInformerRegistry(envDuration("INFORMER_IDLE_TIMEOUT", 30*time.Minute))
+	if err != nil {
+		log.Fatalf("Failed to create dynamic informers: %v", err)
+	}
+
+	// Load admission policy checks from config if provided
+	policyConfig := &policy.Config{}
+	if path := os.Getenv("POLICY_CONFIG"); path != "" {
+		cfg, err := policy.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("Failed to load policy config: %v", err)
+		}
+		policyConfig = cfg
+	}
+	policyEvaluator, err := policy.NewEvaluator(policyConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize policy checks: %v", err)
+	}
+
+	// Requests acting as their own identity reuse that identity's clients
+	clientCache := clientcache.New(k8sconfig.Config, envInt("CLIENT_CACHE_SIZE", 100), envDuration("CLIENT_CACHE_TTL", 15*time.Minute))
+
+	// Destructive operations in protected namespaces must be planned and confirmed when GUARD_RAILS is set
+	var guardRails *guard.Guard
+	if envBool("GUARD_RAILS") {
+		guardRails, err = guard.New(
+			splitList(envOrDefault("GUARD_RAILS_NAMESPACES", "kube-system,kube-public,kube-node-lease")),
+			[]byte(os.Getenv("GUARD_RAILS_KEY")),
+			envDuration("GUARD_RAILS_TOKEN_TTL", 2*time.Minute),
+		)
+		if err != nil {
+			log.Fatalf("Failed to initialize guard rails: %v", err)
+		}
+	}
+
+	// Mutating operations on objects are kept for AUDIT_HISTORY_RETENTION, at most
+	// AUDIT_HISTORY_PER_OBJECT for each of AUDIT_HISTORY_OBJECTS objects; zero objects disables it
+	var auditStore *audit.Store
+	if objects := envInt("AUDIT_HISTORY_OBJECTS", 10000); objects > 0 {
+		auditStore = audit.NewStore(envInt("AUDIT_HISTORY_PER_OBJECT", 20), objects, envDuration("AUDIT_HISTORY_RETENTION", 24*time.Hour))
+	}
 
 	// Initialize services and controllers
 	resourceCtl := controllers.NewResourceCtl(
-		services.NewResourceService(&restMapper, dynamicClient, informer),
+		services.NewResourceService(&restMapper, dynamicClient, informer,
+			services.WithPolicy(policyEvaluator),
+			services.WithRequestWarnings(k8sconfig.Config),
+			services.WithDiscovery(clientSet.Discovery()),
+			services.WithDynamicInformers(dynamicInformers),
+			services.WithInformerShards(k8sconfig.Shards, envBool("INFORMER_SHARD_FAN_OUT")),
+			services.WithClientCache(clientCache),
+			services.WithNamespaceScope(namespaceScope),
+			services.WithKustomize(kustomizeBuilder),
+		),
+		guardRails,
+		auditStore,
 	)
 	podLogCtl := controllers.NewPodLogEventCtl(
-		services.NewPodLogEventService(clientSet),
-	)
-
-	// Setup Gin with middleware
-	r := gin.New()
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-
-	// Configure CORS
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// API versioning with v1 group
-	v1 := r.Group("/api/v1")
-	{
-		// Resource endpoints
-		v1.GET("/resources/:resource", resourceCtl.List())
-		v1.DELETE("/resources/:resource", resourceCtl.Delete())
-		v1.POST("/resources/:resource", resourceCtl.Create())
-		v1.GET("/resources/gvr", resourceCtl.GetGVR())
-
-		// Pod logs and events
-		v1.GET("/pods/logs", podLogCtl.GetLog())
-		v1.GET("/pods/events", podLogCtl.GetEvent())
-	}
-
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		services.NewPodLogEventService(clientSet, clientCache),
+	)
+	podStatusCtl := controllers.NewPodStatusCtl(
+		services.NewPodStatusService(clientSet, informer),
+	)
+	eventCtl := controllers.NewEventCtl(
+		services.NewEventService(clientSet, firehose.NewHub(clientSet, informerNamespace, envInt("EVENT_FIREHOSE_BUFFER", 256))),
+	)
+	nodeCtl := controllers.NewNodeCtl(
+		services.NewNodeService(informer, clientSet),
+	)
+	imageCtl := controllers.NewImageCtl(
+		services.NewImageService(informer),
+	)
+	// Restart counts are followed to attribute restarts to a window, keeping the changes of
+	// the last RESTART_HISTORY_RETENTION, at most RESTART_HISTORY_SAMPLES per container
+	restartTracker := restarts.NewTracker(envDuration("RESTART_HISTORY_RETENTION", 72*time.Hour), envInt("RESTART_HISTORY_SAMPLES", 32))
+	if err := restartTracker.Watch(informer.Core().V1().Pods().Informer()); err != nil {
+		log.Fatalf("Failed to watch pods for restart history: %v", err)
+	}
+	diagnosticsCtl := controllers.NewDiagnosticsCtl(
+		services.NewDiagnosticsService(clientSet, informer, restartTracker),
+	)
+	consumerCtl := controllers.NewConsumerCtl(
+		services.NewConsumerService(clientSet, informer),
+	)
+	clusterCtl := controllers.NewClusterCtl(
+		services.NewClusterService(clientSet, informer, warningRecorder, throttling),
+	)
+	debugCtl := controllers.NewDebugCtl(
+		services.NewDebugService(k8sconfig.Informers),
+	)
+	indexCtl := controllers.NewIndexCtl(
+		services.NewIndexService(informer),
+	)
+	pdbCtl := controllers.NewPDBCtl(
+		services.NewPDBService(informer),
+	)
+	storageCtl := controllers.NewStorageCtl(
+		services.NewStorageService(clientSet, informer),
+	)
+	rbacCtl := controllers.NewRBACCtl(
+		services.NewRBACService(clientSet),
+	)
+	hpaCtl := controllers.NewHPACtl(
+		services.NewHPAService(clientSet),
+	)
+	jobCtl := controllers.NewJobCtl(
+		services.NewJobService(clientSet, informer),
+	)
+	workloadCtl := controllers.NewWorkloadCtl(
+		services.NewWorkloadService(clientSet, informer),
+	)
+	helmCtl := controllers.NewHelmCtl(
+		services.NewHelmService(informer),
+	)
+
+	// Raw API server paths are proxied with the server's credentials, denying secrets by default
+	deniedRawPaths := rawproxy.DefaultDenied
+	if v, ok := os.LookupEnv("RAW_PROXY_DENY"); ok {
+		deniedRawPaths = splitList(v)
+	}
+	rawProxy, err := rawproxy.New(k8sconfig.Config, splitList(os.Getenv("RAW_PROXY_ALLOW")), deniedRawPaths)
+	if err != nil {
+		log.Fatalf("Failed to initialize raw proxy: %v", err)
+	}
+	rawCtl := controllers.NewRawCtl(rawProxy)
+
+	// Service account tokens are capped to a server-side maximum lifetime
+	tokenMaxExpiration := 24 * time.Hour
+	if v := os.Getenv("TOKEN_MAX_EXPIRATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid TOKEN_MAX_EXPIRATION: %v", err)
+		}
+		tokenMaxExpiration = d
+	}
+	serviceAccountCtl := controllers.NewServiceAccountCtl(
+		services.NewServiceAccountService(clientSet, k8sconfig.Config, tokenMaxExpiration),
+	)
+
+	// Change history is opt-in and bounded to CHANGE_RECORDER_CAPACITY records
+	var changeRecorder *changes.Recorder
+	if envBool("CHANGE_RECORDER") {
+		capacity := 5000
+		if v := os.Getenv("CHANGE_RECORDER_CAPACITY"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid CHANGE_RECORDER_CAPACITY: %q", v)
+			}
+			capacity = n
+		}
+		// CHANGE_DIFF_IGNORE adds paths to leave out of update diffs to objdiff.DefaultIgnore
+		ignore, err := objdiff.ParsePaths(append(objdiff.DefaultIgnore, splitList(os.Getenv("CHANGE_DIFF_IGNORE"))...))
+		if err != nil {
+			log.Fatalf("Invalid CHANGE_DIFF_IGNORE: %v", err)
+		}
+		changeRecorder = changes.NewRecorder(capacity, envInt("CHANGE_STREAM_BUFFER", changes.DefaultSubscriptionBuffer), objdiff.Options{
+			Ignore:     ignore,
+			MaxChanges: envInt("CHANGE_DIFF_MAX_CHANGES", objdiff.DefaultMaxChanges),
+		})
+	}
+	changeService := services.NewChangeService(changeRecorder)
+	changeExcluded := changes.DefaultExcluded
+	if v, ok := os.LookupEnv("CHANGE_RECORDER_EXCLUDE"); ok {
+		changeExcluded = strings.Split(v, ",")
+	}
+	changeCtl := controllers.NewChangeCtl(changeService)
+
+	// Usage history is opt-in, polling the metrics API every METRICS_HISTORY_INTERVAL and
+	// keeping METRICS_HISTORY_SAMPLES samples per pod and node
+	var usageSampler *usage.Sampler
+	if envBool("METRICS_HISTORY") {
+		usageSampler = usage.NewSampler(dynamicClient, informerNamespace, namespaceScope.ClusterScoped(),
+			envDuration("METRICS_HISTORY_INTERVAL", 15*time.Second), envInt("METRICS_HISTORY_SAMPLES", 60))
+		if err := usageSampler.WatchPods(informer.Core().V1().Pods().Informer()); err != nil {
+			log.Fatalf("Failed to watch pods for usage history: %v", err)
+		}
+		if namespaceScope.ClusterScoped() {
+			if err := usageSampler.WatchNodes(informer.Core().V1().Nodes().Informer()); err != nil {
+				log.Fatalf("Failed to watch nodes for usage history: %v", err)
+			}
+		}
+	}
+	usageCtl := controllers.NewUsageCtl(services.NewUsageService(usageSampler))
+
+	// Webhook deliveries are queued so slow receivers never block the informers
+	webhookQueueSize := 1000
+	if v := os.Getenv("WEBHOOK_QUEUE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid WEBHOOK_QUEUE_SIZE: %q", v)
+		}
+		webhookQueueSize = n
+	}
+	dispatcher := webhook.NewDispatcher(webhookQueueSize)
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	webhookCtl := controllers.NewWebhookCtl(
+		services.NewWebhookService(dispatcher, informer, backgroundCtx.Done()),
+	)
+
+	// READ_ONLY guarantees the server can't change the cluster: every route but GET, HEAD and
+	// OPTIONS is rejected, and background components that write are not started
+	readOnly := envBool("READ_ONLY")
+
+	// Background components run only on the elected leader when LEADER_ELECTION is enabled
+	identity, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to determine leader election identity: %v", err)
+	}
+	elector := leader.NewElector(clientSet, leader.Options{
+		// Holding the lease means writing it, read-only replicas all act alone
+		Enabled:   envBool("LEADER_ELECTION") && !readOnly,
+		Namespace: envOrDefault("LEADER_ELECTION_NAMESPACE", "default"),
+		LeaseName: envOrDefault("LEADER_ELECTION_LEASE", "kgent-api"),
+		Identity:  identity,
+	})
+
+	go dynamicInformers.Run(backgroundCtx)
+	if k8sconfig.Shards != nil {
+		go k8sconfig.Shards.Run(backgroundCtx)
+	}
+	// Every replica serves history from its own memory, so every replica samples
+	if usageSampler != nil {
+		go usageSampler.Run(backgroundCtx)
+	}
+	electionDone := make(chan struct{})
+	go func() {
+		defer close(electionDone)
+		elector.Run(backgroundCtx, func(ctx context.Context) {
+			if changeRecorder != nil {
+				if err := changeService.Record(informer, changeExcluded); err != nil {
+					log.Printf("Failed to start change recorder: %v", err)
+				}
+			}
+			if !readOnly {
+				go dispatcher.Run(ctx, 4)
+			}
+		})
+	}()
+
+	// Admin-only endpoints require this bearer token and are disabled when it is unset
+	adminAuth := middlewares.AdminAuth(os.Getenv("ADMIN_TOKEN"))
+
+	// IDENTITY_TOKENS and IDENTITY_IMPERSONATION make requests act as the identity they carry
+	var requestIdentity gin.HandlerFunc
+	if tokens, impersonation := envBool("IDENTITY_TOKENS"), envBool("IDENTITY_IMPERSONATION"); tokens || impersonation {
+		requestIdentity = middlewares.RequestIdentity(tokens, impersonation, os.Getenv("ADMIN_TOKEN"))
+	}
+
+	// Readiness endpoint, reporting leadership, read-only mode and preflight results with ?verbose
+	readyz := func(c *gin.Context) {
+		if _, verbose := c.GetQuery("verbose"); !verbose {
+			c.String(http.StatusOK, "ok")
+			return
+		}
+
+		role := "follower"
+		if elector.IsLeader() {
+			role = "leader"
+		}
+		lines := []string{"[+]ping ok"}
+		if elector.Enabled() {
+			lines = append(lines, fmt.Sprintf("[+]leader-election ok: %s %s, current leader %q", identity, role, elector.Leader()))
+		} else {
+			lines = append(lines, "[+]leader-election ok: disabled")
+		}
+		if readOnly {
+			lines = append(lines, "[+]read-only ok: enabled, mutating requests are rejected")
+		} else {
+			lines = append(lines, "[+]read-only ok: disabled")
+		}
+		for _, result := range preflightReport.Results {
+			if result.OK {
+				lines = append(lines, fmt.Sprintf("[+]preflight-%s ok: %s", result.Name, result.Message))
+			} else if result.Hint != "" {
+				lines = append(lines, fmt.Sprintf("[-]preflight-%s failed: %s (%s)", result.Name, result.Message, result.Hint))
+			} else {
+				lines = append(lines, fmt.Sprintf("[-]preflight-%s failed: %s", result.Name, result.Message))
+			}
+		}
+		if degraded {
+			lines = append(lines, "readyz check passed, degraded: read-only until the preflight failures are fixed and the server restarted")
+		} else {
+			lines = append(lines, "readyz check passed")
+		}
+		c.String(http.StatusOK, strings.Join(lines, "\n")+"\n")
+	}
+
+	r := newRouter(routes{
+		readOnly:          readOnly,
+		degraded:          degraded,
+		namespaceScope:    namespaceScope,
+		warningRecorder:   warningRecorder,
+		adminAuth:         adminAuth,
+		requestIdentity:   requestIdentity,
+		readyz:            readyz,
+		resourceCtl:       resourceCtl,
+		podLogCtl:         podLogCtl,
+		podStatusCtl:      podStatusCtl,
+		workloadCtl:       workloadCtl,
+		eventCtl:          eventCtl,
+		indexCtl:          indexCtl,
+		imageCtl:          imageCtl,
+		diagnosticsCtl:    diagnosticsCtl,
+		consumerCtl:       consumerCtl,
+		clusterCtl:        clusterCtl,
+		nodeCtl:           nodeCtl,
+		usageCtl:          usageCtl,
+		changeCtl:         changeCtl,
+		pdbCtl:            pdbCtl,
+		storageCtl:        storageCtl,
+		rbacCtl:           rbacCtl,
+		webhookCtl:        webhookCtl,
+		debugCtl:          debugCtl,
+		hpaCtl:            hpaCtl,
+		jobCtl:            jobCtl,
+		helmCtl:           helmCtl,
+		serviceAccountCtl: serviceAccountCtl,
+		rawCtl:            rawCtl,
 	})
 
+	// pprof and expvar are served on their own listener when DEBUG_PPROF holds an address
+	if addr := os.Getenv("DEBUG_PPROF"); addr != "" {
+		profiling.Publish("goroutines", func() any {
+			return runtime.NumGoroutine()
+		})
+		profiling.Publish("informer_objects", func() any {
+			objects := map[string]int{}
+			for _, stats := range k8sconfig.Informers.Stats() {
+				objects[stats.Resource] = stats.Objects
+			}
+			return objects
+		})
+		go func() {
+			log.Printf("Debug server listening on %s", addr)
+			if err := profiling.Serve(addr); err != nil {
+				log.Printf("Debug server stopped: %v", err)
+			}
+		}()
+	}
+
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -101,6 +485,14 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
+	// Stopping the background context releases the leader lease
+	stopBackground()
+	select {
+	case <-electionDone:
+	case <-time.After(5 * time.Second):
+		log.Println("Timed out waiting for background components to stop")
+	}
+
 	// Set shutdown timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -111,3 +503,93 @@ func main() {
 
 	log.Println("Server exited properly")
 }
+
+// checkPreflight logs the preflight results and exits on failures unless onFailure is
+// "degraded", reporting whether to serve read-only. An unreachable API server always exits,
+// there is nothing to serve without it.
+func checkPreflight(report *preflight.Report, onFailure string) bool {
+	if onFailure != "exit" && onFailure != "degraded" {
+		log.Fatalf("Invalid PREFLIGHT_FAILURE: %q, must be exit or degraded", onFailure)
+	}
+
+	for _, result := range report.Results {
+		switch {
+		case result.OK && result.Hint != "":
+			log.Printf("Preflight %s: %s. Hint: %s", result.Name, result.Message, result.Hint)
+		case result.OK:
+			log.Printf("Preflight %s: %s", result.Name, result.Message)
+		case result.Hint != "":
+			log.Printf("Preflight %s failed: %s. Hint: %s", result.Name, result.Message, result.Hint)
+		default:
+			log.Printf("Preflight %s failed: %s", result.Name, result.Message)
+		}
+	}
+	if report.Passed() {
+		return false
+	}
+
+	if onFailure == "exit" || !report.Reachable() {
+		log.Fatal("Preflight checks failed, fix the errors above or set PREFLIGHT_FAILURE=degraded to serve reads regardless")
+	}
+	log.Printf("Preflight checks failed, serving read-only")
+	return true
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid %s: %q", key, v)
+	}
+	return n
+}
+
+func envFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		log.Fatalf("Invalid %s: %q", key, v)
+	}
+	return f
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid %s: %q", key, v)
+	}
+	return d
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallerKey is the gin context key holding the authenticated caller name
+const CallerKey = "caller"
+
+// AdminAuth only lets through requests carrying the configured admin bearer token.
+// When no token is configured the protected routes are disabled entirely.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			abort(c, http.StatusForbidden, apierror.New(apierror.Forbidden, "admin access is not configured"))
+			return
+		}
+
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			abort(c, http.StatusUnauthorized, apierror.New(apierror.Unauthorized, "valid admin bearer token required"))
+			return
+		}
+
+		c.Set(CallerKey, "admin")
+		c.Next()
+	}
+}
+
+// Caller returns the authenticated caller name, falling back to the client IP
+func Caller(c *gin.Context) string {
+	if caller := c.GetString(CallerKey); caller != "" {
+		return caller + "@" + c.ClientIP()
+	}
+	return "anonymous@" + c.ClientIP()
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kgent-api/pkg/apierror"
+)
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+		wantCode      apierror.Code
+	}{
+		{name: "valid token", token: "s3cret", authorization: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "wrong token", token: "s3cret", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized, wantCode: apierror.Unauthorized},
+		{name: "not a bearer token", token: "s3cret", authorization: "Basic s3cret", wantStatus: http.StatusUnauthorized, wantCode: apierror.Unauthorized},
+		{name: "no token", token: "s3cret", wantStatus: http.StatusUnauthorized, wantCode: apierror.Unauthorized},
+		{name: "not configured", authorization: "Bearer ", wantStatus: http.StatusForbidden, wantCode: apierror.Forbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/informers", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := serve(http.MethodGet, "/admin/informers", req, AdminAuth(tt.token))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantCode != "" {
+				if apiErr := decodeError(t, rec); apiErr.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", apiErr.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
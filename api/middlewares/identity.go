@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"strings"
+
+	"kgent-api/pkg/clientcache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers an authenticating proxy sets to name the user a request acts as
+const (
+	ImpersonateUserHeader  = "Impersonate-User"
+	ImpersonateGroupHeader = "Impersonate-Group"
+)
+
+// RequestIdentity makes requests act as the identity they carry, for the endpoints whose
+// clients come from a clientcache.Cache. With tokens, a bearer token other than adminToken
+// is the caller's own Kubernetes token. With impersonation, the Impersonate-User and
+// Impersonate-Group headers name a user the server impersonates; only enable it behind a
+// proxy that authenticates callers and sets them, anyone could claim any user otherwise.
+// Requests carrying neither act as the server.
+func RequestIdentity(tokens, impersonation bool, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var id clientcache.Identity
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && tokens && token != "" && token != adminToken {
+			id.Token = token
+		}
+		if impersonation {
+			id.User = c.GetHeader(ImpersonateUserHeader)
+			if id.User != "" {
+				id.Groups = c.Request.Header.Values(ImpersonateGroupHeader)
+			}
+		}
+
+		if id.Token != "" || id.User != "" {
+			c.Request = c.Request.WithContext(clientcache.NewContext(c.Request.Context(), id))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"kgent-api/pkg/clientcache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIdentity(t *testing.T) {
+	tests := []struct {
+		name          string
+		tokens        bool
+		impersonation bool
+		header        http.Header
+		// want is the identity the request acts as, nil when it acts as the server
+		want *clientcache.Identity
+	}{
+		{
+			name:   "token",
+			tokens: true,
+			header: http.Header{"Authorization": {"Bearer caller-token"}},
+			want:   &clientcache.Identity{Token: "caller-token"},
+		},
+		{name: "tokens disabled", header: http.Header{"Authorization": {"Bearer caller-token"}}},
+		{name: "admin token", tokens: true, header: http.Header{"Authorization": {"Bearer s3cret"}}},
+		{name: "not a bearer token", tokens: true, header: http.Header{"Authorization": {"Basic Y2FsbGVy"}}},
+		{
+			name:          "impersonation",
+			impersonation: true,
+			header:        http.Header{ImpersonateUserHeader: {"jane"}, ImpersonateGroupHeader: {"dev", "oncall"}},
+			want:          &clientcache.Identity{User: "jane", Groups: []string{"dev", "oncall"}},
+		},
+		{name: "impersonation disabled", tokens: true, header: http.Header{ImpersonateUserHeader: {"jane"}}},
+		{name: "groups without a user", impersonation: true, header: http.Header{ImpersonateGroupHeader: {"system:masters"}}},
+		{
+			name:          "token impersonating",
+			tokens:        true,
+			impersonation: true,
+			header:        http.Header{"Authorization": {"Bearer caller-token"}, ImpersonateUserHeader: {"jane"}},
+			want:          &clientcache.Identity{Token: "caller-token", User: "jane"},
+		},
+		{name: "neither", tokens: true, impersonation: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resources/pods", nil)
+			req.Header = tt.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+
+			var got *clientcache.Identity
+			rec := serve(http.MethodGet, "/resources/pods", req, RequestIdentity(tt.tokens, tt.impersonation, "s3cret"), func(c *gin.Context) {
+				if id, ok := clientcache.FromContext(c.Request.Context()); ok {
+					got = &id
+				}
+			})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("identity = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
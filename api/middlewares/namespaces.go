@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/nsscope"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NamespaceScopeKey is the gin context key holding the request's *nsscope.Scope
+const NamespaceScopeKey = "namespaceScope"
+
+// Namespaces rejects requests naming a namespace outside scope, in the :ns path parameter
+// or the ns query, with 403. An empty ns query asks for every namespace, which only the
+// routes in filtered support in restricted mode, by filtering what they list down to scope.
+func Namespaces(scope *nsscope.Scope, filtered ...string) gin.HandlerFunc {
+	filters := map[string]bool{}
+	for _, route := range filtered {
+		filters[route] = true
+	}
+
+	return func(c *gin.Context) {
+		c.Set(NamespaceScopeKey, scope)
+		if !scope.Restricted() {
+			c.Next()
+			return
+		}
+
+		ns, named := c.Param("ns"), true
+		if ns == "" {
+			ns, named = c.GetQuery("ns")
+		}
+		switch {
+		case ns != "" && !scope.Allows(ns):
+			abort(c, http.StatusForbidden, apierror.New(apierror.Forbidden, "namespace "+ns+" is outside the namespaces this server is limited to: "+scope.String()))
+			return
+		case ns == "" && named && !filters[c.FullPath()]:
+			abort(c, http.StatusForbidden, apierror.New(apierror.Forbidden, "listing every namespace is not available on this endpoint, name one of "+scope.String()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ClusterScoped rejects requests with 403 unless scope allows cluster-scoped resources
+func ClusterScoped(scope *nsscope.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !scope.ClusterScoped() {
+			abort(c, http.StatusForbidden, apierror.New(apierror.Forbidden, "cluster-scoped resources are disabled while the server is limited to "+scope.String()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// NamespaceScope returns the scope set by Namespaces, nil when unrestricted
+func NamespaceScope(c *gin.Context) *nsscope.Scope {
+	scope, _ := c.Get(NamespaceScopeKey)
+	s, _ := scope.(*nsscope.Scope)
+	return s
+}
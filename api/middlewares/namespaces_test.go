@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/nsscope"
+)
+
+func TestNamespaces(t *testing.T) {
+	scope, err := nsscope.New([]string{"team-*"}, []string{"team-secret"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		scope      *nsscope.Scope
+		path       string
+		target     string
+		filtered   []string
+		wantStatus int
+	}{
+		{name: "unrestricted", path: "/resources/:resource", target: "/resources/pods?ns=kube-system", wantStatus: http.StatusOK},
+		{name: "unrestricted all namespaces", path: "/resources/:resource", target: "/resources/pods?ns=", wantStatus: http.StatusOK},
+		{name: "allowed query", scope: scope, path: "/resources/:resource", target: "/resources/pods?ns=team-a", wantStatus: http.StatusOK},
+		{name: "allowed path", scope: scope, path: "/namespaces/:ns/pods", target: "/namespaces/team-a/pods", wantStatus: http.StatusOK},
+		{name: "outside query", scope: scope, path: "/resources/:resource", target: "/resources/pods?ns=kube-system", wantStatus: http.StatusForbidden},
+		{name: "outside path", scope: scope, path: "/namespaces/:ns/pods", target: "/namespaces/kube-system/pods", wantStatus: http.StatusForbidden},
+		{name: "denied", scope: scope, path: "/resources/:resource", target: "/resources/pods?ns=team-secret", wantStatus: http.StatusForbidden},
+		// Handlers default the namespace when none is named
+		{name: "no namespace", scope: scope, path: "/resources/:resource", target: "/resources/pods", wantStatus: http.StatusOK},
+		{name: "all namespaces", scope: scope, path: "/resources/:resource", target: "/resources/pods?ns=", wantStatus: http.StatusForbidden},
+		{
+			name:       "all namespaces filtered",
+			scope:      scope,
+			path:       "/resources/:resource",
+			target:     "/resources/pods?ns=",
+			filtered:   []string{"/resources/:resource"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rec := serve(http.MethodGet, tt.path, req, Namespaces(tt.scope, tt.filtered...))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s = %d, want %d: %s", tt.target, rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusForbidden {
+				if apiErr := decodeError(t, rec); apiErr.Code != apierror.Forbidden {
+					t.Errorf("code = %q, want %q", apiErr.Code, apierror.Forbidden)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterScoped(t *testing.T) {
+	namespacedOnly, err := nsscope.New([]string{"team-a"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withClusterScoped, err := nsscope.New([]string{"team-a"}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		scope      *nsscope.Scope
+		wantStatus int
+	}{
+		{name: "unrestricted", wantStatus: http.StatusOK},
+		{name: "cluster-scoped allowed", scope: withClusterScoped, wantStatus: http.StatusOK},
+		{name: "cluster-scoped disabled", scope: namespacedOnly, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+			if rec := serve(http.MethodGet, "/nodes", req, ClusterScoped(tt.scope)); rec.Code != tt.wantStatus {
+				t.Errorf("GET /nodes = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
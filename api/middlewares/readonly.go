@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyMethods are the methods that can't change anything
+var readOnlyMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+// ReadOnly rejects every request but GET, HEAD and OPTIONS with status, giving reason
+func ReadOnly(status int, reason string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if status == http.StatusMethodNotAllowed {
+			c.Header("Allow", strings.Join(readOnlyMethods, ", "))
+		}
+		abort(c, status, apierror.New(apierror.ReadOnly, "the server is read-only: "+reason))
+	}
+}
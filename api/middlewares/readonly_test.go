@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kgent-api/pkg/apierror"
+)
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		method     string
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "get", status: http.StatusForbidden, method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "head", status: http.StatusForbidden, method: http.MethodHead, wantStatus: http.StatusOK},
+		{name: "options", status: http.StatusForbidden, method: http.MethodOptions, wantStatus: http.StatusOK},
+		{name: "post forbidden", status: http.StatusForbidden, method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "delete forbidden", status: http.StatusForbidden, method: http.MethodDelete, wantStatus: http.StatusForbidden},
+		{
+			name:       "patch not allowed",
+			status:     http.StatusMethodNotAllowed,
+			method:     http.MethodPatch,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "GET, HEAD, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/resources/pods", nil)
+			rec := serve(tt.method, "/resources/pods", req, ReadOnly(tt.status, "maintenance"))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s = %d, want %d", tt.method, rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if rec.Code == http.StatusOK {
+				return
+			}
+			apiErr := decodeError(t, rec)
+			if apiErr.Code != apierror.ReadOnly {
+				t.Errorf("code = %q, want %q", apiErr.Code, apierror.ReadOnly)
+			}
+			if apiErr.RequestID != rec.Header().Get(RequestIDHeader) {
+				t.Errorf("requestId = %q, want the %s header", apiErr.RequestID, RequestIDHeader)
+			}
+		})
+	}
+}
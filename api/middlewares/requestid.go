@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, given by the client or assigned by the server
+const RequestIDHeader = "X-Request-Id"
+
+// requestIDKey is the gin context key holding the request ID
+const requestIDKey = "requestId"
+
+// maxRequestIDLength bounds the client-provided IDs echoed back
+const maxRequestIDLength = 128
+
+// AssignRequestID keeps the X-Request-Id of the request, or generates one, and returns it
+// in the response header so error bodies can be matched with the logs
+func AssignRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			raw := make([]byte, 8)
+			_, _ = rand.Read(raw)
+			id = hex.EncodeToString(raw)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID of the request, empty without AssignRequestID
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// abort ends the request with an APIError
+func abort(c *gin.Context, status int, apiErr *apierror.APIError) {
+	apiErr.RequestID = RequestID(c)
+	c.AbortWithStatusJSON(status, apiErr)
+}
@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve routes method path through AssignRequestID and handlers, ending in a handler
+// answering 200, and sends req to it
+func serve(method, path string, req *http.Request, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(AssignRequestID())
+	handlers = append(handlers, func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.Handle(method, path, handlers...)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeError decodes the APIError of rec, failing the test without one
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) apierror.APIError {
+	t.Helper()
+	var apiErr apierror.APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("response %q is no APIError: %v", rec.Body, err)
+	}
+	return apiErr
+}
+
+func TestAssignRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		// want is the ID echoed back, any generated one when empty
+		want string
+	}{
+		{name: "client ID kept", header: "client-id-1", want: "client-id-1"},
+		{name: "generated without one"},
+		{name: "generated for oversized IDs", header: strings.Repeat("x", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			var seen string
+			rec := serve(http.MethodGet, "/", req, func(c *gin.Context) { seen = RequestID(c) })
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got != seen {
+				t.Errorf("header %q differs from the context's request ID %q", got, seen)
+			}
+			switch {
+			case tt.want != "" && got != tt.want:
+				t.Errorf("request ID = %q, want %q", got, tt.want)
+			case tt.want == "" && (len(got) != 16 || got == tt.header):
+				t.Errorf("request ID = %q, want a generated one", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"kgent-api/pkg/retry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetriesHeader reports how many Kubernetes API calls were retried while serving the request
+const RetriesHeader = "X-Kgent-Retries"
+
+// retriesWriter adds the retries header just before the response headers are sent
+type retriesWriter struct {
+	gin.ResponseWriter
+	counter *retry.Counter
+}
+
+func (w *retriesWriter) setHeader() {
+	if !w.ResponseWriter.Written() {
+		if n := w.counter.Count(); n > 0 {
+			w.Header().Set(RetriesHeader, strconv.FormatInt(n, 10))
+		}
+	}
+}
+
+func (w *retriesWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *retriesWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *retriesWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// CountRetries attaches a retry.Counter to each request and reports a non-zero count
+// in the X-Kgent-Retries response header
+func CountRetries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		counter := &retry.Counter{}
+		c.Request = c.Request.WithContext(retry.NewContext(c.Request.Context(), counter))
+		c.Writer = &retriesWriter{ResponseWriter: c.Writer, counter: counter}
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bufferedWriter holds the handler's response until the timeout middleware decides
+// whether to send it or replace it with a 504. Headers are held too, so a 504 doesn't
+// inherit the handler's Content-Type.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header  http.Header
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+	w.written = true
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Timeout bounds the request context to d and answers 504 when the handler returns past the
+// deadline without a response, or with a server error. Any other response is sent even past
+// the deadline, so a write that succeeded is never reported as timed out and retried.
+// Responses are buffered, so streaming endpoints must not use it.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, header: original.Header().Clone(), status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		timedOut := !buffered.written || buffered.status >= http.StatusInternalServerError
+		if timedOut && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			abort(c, http.StatusGatewayTimeout, apierror.New(apierror.Timeout, "request timed out").With("timeout", d.String()))
+			return
+		}
+
+		header := original.Header()
+		for key := range header {
+			delete(header, key)
+		}
+		for key, values := range buffered.header {
+			header[key] = values
+		}
+		original.WriteHeader(buffered.status)
+		original.WriteHeaderNow()
+		original.Write(buffered.body.Bytes())
+	}
+}
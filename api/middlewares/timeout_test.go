@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kgent-api/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	// pastDeadline waits for the request deadline, as a handler stuck on the API server would
+	pastDeadline := func(c *gin.Context) { <-c.Request.Context().Done() }
+
+	tests := []struct {
+		name       string
+		handler    gin.HandlerFunc
+		wantStatus int
+		wantBody   string
+		// wantHeader is the X-Kgent-Test header the response carries
+		wantHeader string
+	}{
+		{
+			name: "in time",
+			handler: func(c *gin.Context) {
+				c.Header("X-Kgent-Test", "set")
+				c.String(http.StatusCreated, "created")
+				c.Abort()
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "created",
+			wantHeader: "set",
+		},
+		{
+			name:       "no response past the deadline",
+			handler:    func(c *gin.Context) { pastDeadline(c); c.Abort() },
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "headers set before the deadline",
+			handler: func(c *gin.Context) {
+				c.Header("Content-Type", "application/yaml")
+				c.Header("X-Kgent-Test", "set")
+				pastDeadline(c)
+				c.Abort()
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "server error past the deadline",
+			handler:    func(c *gin.Context) { pastDeadline(c); c.AbortWithStatus(http.StatusInternalServerError) },
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "success past the deadline",
+			handler:    func(c *gin.Context) { pastDeadline(c); c.String(http.StatusOK, "deleted"); c.Abort() },
+			wantStatus: http.StatusOK,
+			wantBody:   "deleted",
+		},
+		{
+			name:       "client error past the deadline",
+			handler:    func(c *gin.Context) { pastDeadline(c); c.AbortWithStatus(http.StatusConflict) },
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/resources/pods", nil)
+			rec := serve(http.MethodPost, "/resources/pods", req, Timeout(20*time.Millisecond), tt.handler)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if header := rec.Header().Get("X-Kgent-Test"); header != tt.wantHeader {
+				t.Errorf("X-Kgent-Test = %q, want %q", header, tt.wantHeader)
+			}
+			if rec.Code == http.StatusGatewayTimeout {
+				if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+					t.Errorf("Content-Type = %q, want JSON", contentType)
+				}
+				apiErr := decodeError(t, rec)
+				if apiErr.Code != apierror.Timeout || apiErr.Details["timeout"] != "20ms" {
+					t.Errorf("code = %q, details = %v, want %q with the timeout", apiErr.Code, apiErr.Details, apierror.Timeout)
+				}
+			}
+		})
+	}
+}
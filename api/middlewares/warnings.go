@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"kgent-api/pkg/warnings"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/rest"
+)
+
+// CollectWarnings attaches a warnings.Collector to each request so handlers can return
+// the API server warnings raised while serving it. Collected warnings are forwarded to next.
+func CollectWarnings(next rest.WarningHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		collector := warnings.NewCollector(next)
+		c.Request = c.Request.WithContext(warnings.NewContext(c.Request.Context(), collector))
+		c.Next()
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"kgent-api/api/controllers"
+	"kgent-api/api/middlewares"
+	"kgent-api/pkg/metrics"
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/version"
+	"kgent-api/pkg/warnings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// routes holds the settings and controllers the server's routes are built from
+type routes struct {
+	// readOnly rejects mutating requests on every route, degraded only under /api/v1
+	readOnly        bool
+	degraded        bool
+	namespaceScope  *nsscope.Scope
+	warningRecorder *warnings.Recorder
+	adminAuth       gin.HandlerFunc
+	// requestIdentity attaches the identity requests act as, nil when they act as the server
+	requestIdentity gin.HandlerFunc
+	readyz          gin.HandlerFunc
+
+	resourceCtl       *controllers.ResourceCtl
+	podLogCtl         *controllers.PodLogEventCtl
+	podStatusCtl      *controllers.PodStatusCtl
+	workloadCtl       *controllers.WorkloadCtl
+	eventCtl          *controllers.EventCtl
+	indexCtl          *controllers.IndexCtl
+	imageCtl          *controllers.ImageCtl
+	diagnosticsCtl    *controllers.DiagnosticsCtl
+	consumerCtl       *controllers.ConsumerCtl
+	clusterCtl        *controllers.ClusterCtl
+	nodeCtl           *controllers.NodeCtl
+	usageCtl          *controllers.UsageCtl
+	changeCtl         *controllers.ChangeCtl
+	pdbCtl            *controllers.PDBCtl
+	storageCtl        *controllers.StorageCtl
+	rbacCtl           *controllers.RBACCtl
+	webhookCtl        *controllers.WebhookCtl
+	debugCtl          *controllers.DebugCtl
+	hpaCtl            *controllers.HPACtl
+	jobCtl            *controllers.JobCtl
+	helmCtl           *controllers.HelmCtl
+	serviceAccountCtl *controllers.ServiceAccountCtl
+	rawCtl            *controllers.RawCtl
+}
+
+// newRouter registers every route of the server on a new engine
+func newRouter(rt routes) *gin.Engine {
+	// Setup Gin with middleware
+	r := gin.New()
+	r.Use(middlewares.AssignRequestID())
+	r.Use(gin.Logger())
+	r.Use(gin.Recovery())
+
+	// Enforced on the engine, so routes added later can't bypass it
+	if rt.readOnly {
+		r.Use(middlewares.ReadOnly(http.StatusMethodNotAllowed, "READ_ONLY is set"))
+	}
+
+	// Configure CORS
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middlewares.RequestIDHeader, middlewares.ImpersonateUserHeader, middlewares.ImpersonateGroupHeader},
+		ExposeHeaders:    []string{"Content-Length", middlewares.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	// Route timeouts: short for single-object CRUD, longer for lists, none for streams
+	crudTimeout := middlewares.Timeout(envDuration("TIMEOUT_CRUD", 15*time.Second))
+	listTimeout := middlewares.Timeout(envDuration("TIMEOUT_LIST", 60*time.Second))
+
+	// API versioning with v1 group
+	v1 := r.Group("/api/v1")
+	v1.Use(middlewares.CollectWarnings(rt.warningRecorder))
+	v1.Use(middlewares.CountRetries())
+	if rt.requestIdentity != nil {
+		v1.Use(rt.requestIdentity)
+	}
+	// Routes listed here filter what they list across namespaces down to the namespace scope
+	v1.Use(middlewares.Namespaces(rt.namespaceScope,
+		"/api/v1/resources/:resource",
+		"/api/v1/crds/:crdName/instances",
+		"/api/v1/search",
+		"/api/v1/images",
+		"/api/v1/images/consumers",
+		"/api/v1/changes",
+		"/api/v1/changes/stream",
+		"/api/v1/debug/informers/:resource/keys",
+	))
+	clusterScoped := middlewares.ClusterScoped(rt.namespaceScope)
+	if rt.degraded {
+		v1.Use(middlewares.ReadOnly(http.StatusServiceUnavailable, "startup preflight checks failed, see /readyz?verbose"))
+	}
+	{
+		// Resource endpoints
+		v1.GET("/resources/:resource", listTimeout, rt.resourceCtl.List())
+		v1.DELETE("/resources/:resource", crudTimeout, rt.resourceCtl.Delete())
+		v1.GET("/resources/:resource/:name", crudTimeout, rt.resourceCtl.Get())
+		v1.DELETE("/resources/:resource/:name", crudTimeout, rt.resourceCtl.Delete())
+		v1.PATCH("/resources/:resource/:name", crudTimeout, rt.resourceCtl.Patch())
+		v1.GET("/namespaces/:ns/resources/:resource/:name", crudTimeout, rt.resourceCtl.Get())
+		v1.DELETE("/namespaces/:ns/resources/:resource/:name", crudTimeout, rt.resourceCtl.Delete())
+		v1.PATCH("/namespaces/:ns/resources/:resource/:name", crudTimeout, rt.resourceCtl.Patch())
+		v1.POST("/resources/:resource", crudTimeout, rt.resourceCtl.Create())
+		v1.POST("/resources/:resource/bulk", listTimeout, rt.resourceCtl.Bulk())
+		v1.GET("/resources/:resource/:name/describe", crudTimeout, rt.resourceCtl.Describe())
+		v1.GET("/resources/:resource/:name/delete-plan", listTimeout, rt.resourceCtl.DeletePlan())
+		v1.GET("/namespaces/:ns/resources/:resource/:name/delete-plan", listTimeout, rt.resourceCtl.DeletePlan())
+		v1.GET("/resources/gvr", crudTimeout, rt.resourceCtl.GetGVR())
+		v1.POST("/resources/resolve", crudTimeout, rt.resourceCtl.Resolve())
+		v1.POST("/resources/render", crudTimeout, rt.resourceCtl.Render())
+		v1.POST("/resources/validate", crudTimeout, rt.resourceCtl.Validate())
+		v1.POST("/resources/identify", listTimeout, rt.resourceCtl.Identify())
+		v1.GET("/crds/:crdName/instances", listTimeout, rt.resourceCtl.CRDInstances())
+		v1.POST("/apply/archive", listTimeout, rt.resourceCtl.ApplyArchive())
+		v1.POST("/kustomize/build", listTimeout, rt.resourceCtl.KustomizeBuild())
+		v1.GET("/search", listTimeout, rt.resourceCtl.Search())
+		v1.GET("/discovery/resources", crudTimeout, rt.resourceCtl.APIResources())
+
+		// Pod logs and events, logs and the event firehose (admin only) are streamed and exempt from timeouts
+		v1.GET("/pods/logs", rt.podLogCtl.GetLog())
+		v1.GET("/pods/events", listTimeout, rt.podLogCtl.GetEvent())
+		v1.GET("/pods/:name/status", crudTimeout, rt.podStatusCtl.Get())
+		v1.GET("/pods/:name/scheduling", listTimeout, rt.podStatusCtl.Scheduling())
+		v1.GET("/pods/:name/owner", crudTimeout, rt.workloadCtl.PodOwner())
+		v1.GET("/pods/:name/containers", crudTimeout, rt.podLogCtl.Containers())
+		v1.GET("/events", listTimeout, rt.eventCtl.List())
+		v1.GET("/events/firehose", rt.adminAuth, rt.eventCtl.Firehose())
+
+		// Cache index lookups
+		v1.GET("/index/:resource", listTimeout, rt.indexCtl.Query())
+
+		// Image inventory
+		v1.GET("/images", listTimeout, rt.imageCtl.List())
+		v1.GET("/images/consumers", listTimeout, rt.imageCtl.Consumers())
+
+		// Diagnostics
+		v1.GET("/diagnostics/unhealthy", listTimeout, rt.diagnosticsCtl.Unhealthy())
+		v1.GET("/diagnostics/orphans", listTimeout, rt.diagnosticsCtl.Orphans())
+		v1.GET("/diagnostics/restarts", listTimeout, rt.diagnosticsCtl.Restarts())
+		v1.GET("/secrets/:name/consumers", listTimeout, rt.consumerCtl.Secret())
+		v1.GET("/configmaps/:name/consumers", listTimeout, rt.consumerCtl.ConfigMap())
+
+		// Cluster overview
+		v1.GET("/cluster/capacity", clusterScoped, listTimeout, rt.clusterCtl.Capacity())
+		v1.GET("/cluster/health", clusterScoped, crudTimeout, rt.clusterCtl.Health())
+		v1.GET("/cluster/deprecations", clusterScoped, crudTimeout, rt.clusterCtl.Deprecations())
+		v1.GET("/cluster/flowcontrol", clusterScoped, listTimeout, rt.clusterCtl.FlowControl())
+		v1.GET("/cluster/webhooks", clusterScoped, listTimeout, rt.clusterCtl.Webhooks())
+
+		// Nodes
+		v1.GET("/nodes", clusterScoped, listTimeout, rt.nodeCtl.List())
+		v1.GET("/nodes/:name", clusterScoped, crudTimeout, rt.nodeCtl.Get())
+		v1.GET("/nodes/:name/drain-plan", clusterScoped, listTimeout, rt.nodeCtl.DrainPlan())
+		v1.POST("/nodes/:name/drain", clusterScoped, listTimeout, rt.nodeCtl.Drain())
+
+		// Usage history sampled from the metrics API
+		v1.GET("/metrics/pods/:name/history", crudTimeout, rt.usageCtl.PodHistory())
+		v1.GET("/metrics/nodes/:name/history", clusterScoped, crudTimeout, rt.usageCtl.NodeHistory())
+
+		// Change history, the stream is exempt from timeouts
+		v1.GET("/changes", listTimeout, rt.changeCtl.List())
+		v1.GET("/changes/stream", rt.changeCtl.Stream())
+
+		// Pod disruption budgets
+		v1.GET("/pdbs", listTimeout, rt.pdbCtl.List())
+		v1.GET("/storage/pvs", clusterScoped, listTimeout, rt.storageCtl.Volumes())
+		v1.GET("/storage/pvcs/:name/timeline", listTimeout, rt.storageCtl.ClaimTimeline())
+
+		// RBAC analysis
+		v1.GET("/rbac/subjects", clusterScoped, listTimeout, rt.rbacCtl.Subjects())
+
+		// Webhook subscriptions (admin only)
+		v1.POST("/webhooks", rt.adminAuth, crudTimeout, rt.webhookCtl.Create())
+		v1.GET("/webhooks", rt.adminAuth, crudTimeout, rt.webhookCtl.List())
+		v1.DELETE("/webhooks/:id", rt.adminAuth, crudTimeout, rt.webhookCtl.Delete())
+
+		// Runtime informers (admin only)
+		v1.GET("/admin/informers", rt.adminAuth, crudTimeout, rt.resourceCtl.Informers())
+		v1.POST("/admin/informers", rt.adminAuth, crudTimeout, rt.resourceCtl.StartInformer())
+		v1.DELETE("/admin/informers/:gvr", rt.adminAuth, crudTimeout, rt.resourceCtl.StopInformer())
+		v1.GET("/audit/resources/:resource/:name", rt.adminAuth, crudTimeout, rt.resourceCtl.AuditTrail())
+
+		// Informer cache debugging (admin only, enabled with DEBUG_ENDPOINTS)
+		if envBool("DEBUG_ENDPOINTS") {
+			debug := v1.Group("/debug", rt.adminAuth)
+			debug.GET("/informers", listTimeout, rt.debugCtl.Informers())
+			debug.GET("/informers/:resource/keys", listTimeout, rt.debugCtl.InformerKeys())
+		}
+
+		// Horizontal pod autoscalers
+		v1.GET("/hpas", listTimeout, rt.hpaCtl.List())
+		v1.PUT("/hpas/:name/range", crudTimeout, rt.hpaCtl.SetRange())
+
+		// Jobs
+		v1.GET("/jobs", listTimeout, rt.jobCtl.List())
+		v1.POST("/jobs/:name/retry", crudTimeout, rt.jobCtl.Retry())
+		v1.GET("/jobs/:name/logs", listTimeout, rt.jobCtl.Logs())
+		v1.GET("/cronjobs", listTimeout, rt.jobCtl.CronJobs())
+
+		// Workload rollouts and restarts
+		v1.GET("/workloads/deployments/:name/status", crudTimeout, rt.workloadCtl.RolloutStatus())
+		v1.GET("/workloads/deployments/:name/rollout/stream", rt.workloadCtl.RolloutStream())
+		v1.POST("/workloads/deployments/:name/pause", crudTimeout, rt.workloadCtl.Pause())
+		v1.POST("/workloads/deployments/:name/resume", crudTimeout, rt.workloadCtl.Resume())
+		v1.POST("/workloads/deployments/:name/restart", crudTimeout, rt.workloadCtl.Restart("deployments"))
+		v1.POST("/workloads/statefulsets/:name/restart", crudTimeout, rt.workloadCtl.Restart("statefulsets"))
+		v1.POST("/workloads/daemonsets/:name/restart", crudTimeout, rt.workloadCtl.Restart("daemonsets"))
+		v1.POST("/workloads/daemonsets/:name/restart-on-node", crudTimeout, rt.workloadCtl.RestartOnNode())
+		v1.GET("/workloads/statefulsets/:name/status", crudTimeout, rt.workloadCtl.StatefulSetStatus())
+		v1.GET("/workloads/statefulsets/:name/partition", crudTimeout, rt.workloadCtl.Partition())
+		v1.PUT("/workloads/statefulsets/:name/partition", crudTimeout, rt.workloadCtl.SetPartition())
+		v1.GET("/workloads/:kind/:name/pods", listTimeout, rt.workloadCtl.Pods())
+		v1.PUT("/workloads/:kind/:name/resources", crudTimeout, rt.workloadCtl.SetResources())
+		v1.PUT("/workloads/:kind/:name/env", crudTimeout, rt.workloadCtl.SetEnv())
+
+		// Helm releases, decoded from their release secrets
+		v1.GET("/helm/releases", listTimeout, rt.helmCtl.Releases())
+		v1.GET("/helm/releases/:name/manifest", crudTimeout, rt.helmCtl.Manifest())
+		v1.GET("/helm/releases/:name/values", crudTimeout, rt.helmCtl.Values())
+
+		// Service account tokens (admin only)
+		v1.POST("/serviceaccounts/:name/token", rt.adminAuth, crudTimeout, rt.serviceAccountCtl.CreateToken())
+
+		// Raw API server passthrough (admin only), streamed and exempt from timeouts
+		v1.Any("/raw/*path", rt.adminAuth, clusterScoped, rt.rawCtl.Proxy())
+
+		// Build version, set with -ldflags
+		v1.GET("/version", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"data": version.Get()})
+		})
+	}
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Readiness endpoint, reporting leadership, read-only mode and preflight results with ?verbose
+	r.GET("/readyz", rt.readyz)
+
+	// Prometheus metrics
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	return r
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"kgent-api/api/middlewares"
+	"kgent-api/pkg/apierror"
+	"kgent-api/pkg/nsscope"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// routeParams matches the parameters and wildcards of route paths
+var routeParams = regexp.MustCompile(`[:*]\w+`)
+
+// TestRouterReadOnly walks every route the server registers and sends the mutating ones.
+// The controllers are nil, so a request that got past the middleware would panic and be
+// recovered as a 500.
+func TestRouterReadOnly(t *testing.T) {
+	scope, err := nsscope.New(nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DEBUG_ENDPOINTS", "true")
+
+	tests := []struct {
+		name       string
+		rt         routes
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "read-only", rt: routes{readOnly: true}, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "degraded", rt: routes{degraded: true}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rt.namespaceScope = scope
+			tt.rt.adminAuth = middlewares.AdminAuth("token")
+			router := newRouter(tt.rt)
+
+			mutating := 0
+			for _, route := range router.Routes() {
+				switch route.Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+					continue
+				}
+				mutating++
+				target := routeParams.ReplaceAllString(route.Path, "x")
+				req := httptest.NewRequest(route.Method, target, nil)
+				req.Header.Set("Authorization", "Bearer token")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != tt.wantStatus {
+					t.Errorf("%s %s = %d, want %d: %s", route.Method, route.Path, rec.Code, tt.wantStatus, rec.Body)
+					continue
+				}
+				var apiErr apierror.APIError
+				if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+					t.Fatal(err)
+				}
+				if apiErr.Code != apierror.ReadOnly {
+					t.Errorf("%s %s code = %q, want %q", route.Method, route.Path, apiErr.Code, apierror.ReadOnly)
+				}
+				if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+					t.Errorf("%s %s Allow = %q, want %q", route.Method, route.Path, got, tt.wantAllow)
+				}
+			}
+			if mutating == 0 {
+				t.Fatal("no mutating routes registered")
+			}
+			t.Logf("%d mutating routes rejected", mutating)
+		})
+	}
+}
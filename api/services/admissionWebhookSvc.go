@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/retry"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Types of AdmissionWebhook.Type
+const (
+	WebhookTypeValidating = "Validating"
+	WebhookTypeMutating   = "Mutating"
+)
+
+// Outcomes of WebhookBackend.Reachability
+const (
+	// ReachabilityReady backends have at least one ready endpoint
+	ReachabilityReady = "ready"
+	// ReachabilityNoEndpoints backends have no ready endpoint, every call to them fails
+	ReachabilityNoEndpoints = "no-endpoints"
+	// ReachabilityURL backends are called by URL, which isn't checked
+	ReachabilityURL = "url"
+	// ReachabilityUnknown backends' endpoints couldn't be listed
+	ReachabilityUnknown = "unknown"
+)
+
+// AdmissionWebhook is one webhook of a ValidatingWebhookConfiguration or
+// MutatingWebhookConfiguration
+type AdmissionWebhook struct {
+	Type              string                                       `json:"type"`
+	Configuration     string                                       `json:"configuration"`
+	Name              string                                       `json:"name"`
+	Rules             []admissionregistrationv1.RuleWithOperations `json:"rules"`
+	NamespaceSelector *metav1.LabelSelector                        `json:"namespaceSelector,omitempty"`
+	ObjectSelector    *metav1.LabelSelector                        `json:"objectSelector,omitempty"`
+	FailurePolicy     admissionregistrationv1.FailurePolicyType    `json:"failurePolicy"`
+	TimeoutSeconds    int32                                        `json:"timeoutSeconds"`
+	SideEffects       admissionregistrationv1.SideEffectClass      `json:"sideEffects,omitempty"`
+	Backend           WebhookBackend                               `json:"backend"`
+	// Blocking is set for webhooks that fail closed with no ready endpoint: every request
+	// they match is rejected until the backend comes back
+	Blocking bool `json:"blocking"`
+}
+
+// WebhookBackend is where the API server calls a webhook, and whether anything answers there
+type WebhookBackend struct {
+	URL              string `json:"url,omitempty"`
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
+	ServiceName      string `json:"serviceName,omitempty"`
+	ServicePath      string `json:"servicePath,omitempty"`
+	ServicePort      int32  `json:"servicePort,omitempty"`
+	Reachability     string `json:"reachability"`
+	ReadyEndpoints   int    `json:"readyEndpoints"`
+	Error            string `json:"error,omitempty"`
+}
+
+// AdmissionWebhooks lists the webhooks of every ValidatingWebhookConfiguration and
+// MutatingWebhookConfiguration, checking that the Service behind each has ready endpoints.
+// Blocking webhooks are listed first.
+func (s *ClusterService) AdmissionWebhooks(ctx context.Context) ([]AdmissionWebhook, error) {
+	var validating *admissionregistrationv1.ValidatingWebhookConfigurationList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		validating, err = s.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list validating webhook configurations: %w", err)
+	}
+	var mutating *admissionregistrationv1.MutatingWebhookConfigurationList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		mutating, err = s.client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+
+	webhooks := []AdmissionWebhook{}
+	for _, config := range validating.Items {
+		for _, w := range config.Webhooks {
+			webhooks = append(webhooks, AdmissionWebhook{
+				Type: WebhookTypeValidating, Configuration: config.Name, Name: w.Name,
+				Rules: w.Rules, NamespaceSelector: w.NamespaceSelector, ObjectSelector: w.ObjectSelector,
+				FailurePolicy: failurePolicy(w.FailurePolicy), TimeoutSeconds: timeoutSeconds(w.TimeoutSeconds),
+				SideEffects: sideEffects(w.SideEffects), Backend: webhookBackend(w.ClientConfig),
+			})
+		}
+	}
+	for _, config := range mutating.Items {
+		for _, w := range config.Webhooks {
+			webhooks = append(webhooks, AdmissionWebhook{
+				Type: WebhookTypeMutating, Configuration: config.Name, Name: w.Name,
+				Rules: w.Rules, NamespaceSelector: w.NamespaceSelector, ObjectSelector: w.ObjectSelector,
+				FailurePolicy: failurePolicy(w.FailurePolicy), TimeoutSeconds: timeoutSeconds(w.TimeoutSeconds),
+				SideEffects: sideEffects(w.SideEffects), Backend: webhookBackend(w.ClientConfig),
+			})
+		}
+	}
+
+	// Several webhooks are often served by the same Service
+	checked := map[string]WebhookBackend{}
+	for i := range webhooks {
+		backend := &webhooks[i].Backend
+		if backend.ServiceName == "" {
+			continue
+		}
+		key := backend.ServiceNamespace + "/" + backend.ServiceName
+		result, ok := checked[key]
+		if !ok {
+			result = s.serviceReachability(ctx, backend.ServiceNamespace, backend.ServiceName)
+			checked[key] = result
+		}
+		backend.Reachability, backend.ReadyEndpoints, backend.Error = result.Reachability, result.ReadyEndpoints, result.Error
+		webhooks[i].Blocking = webhooks[i].FailurePolicy == admissionregistrationv1.Fail && backend.Reachability == ReachabilityNoEndpoints
+	}
+
+	sort.SliceStable(webhooks, func(i, j int) bool {
+		if webhooks[i].Blocking != webhooks[j].Blocking {
+			return webhooks[i].Blocking
+		}
+		if webhooks[i].Configuration != webhooks[j].Configuration {
+			return webhooks[i].Configuration < webhooks[j].Configuration
+		}
+		return webhooks[i].Name < webhooks[j].Name
+	})
+	return webhooks, nil
+}
+
+// serviceReachability counts the ready endpoints of a Service from its EndpointSlices
+func (s *ClusterService) serviceReachability(ctx context.Context, namespace, name string) WebhookBackend {
+	var slices *discoveryv1.EndpointSliceList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		slices, err = s.client.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: discoveryv1.LabelServiceName + "=" + name,
+		})
+		return err
+	})
+	if err != nil {
+		return WebhookBackend{Reachability: ReachabilityUnknown, Error: fmt.Sprintf("failed to list endpoint slices: %v", err)}
+	}
+
+	ready := 0
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			// A nil ready condition means ready
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready += len(endpoint.Addresses)
+			}
+		}
+	}
+	if ready == 0 {
+		return WebhookBackend{Reachability: ReachabilityNoEndpoints}
+	}
+	return WebhookBackend{Reachability: ReachabilityReady, ReadyEndpoints: ready}
+}
+
+func webhookBackend(config admissionregistrationv1.WebhookClientConfig) WebhookBackend {
+	if config.Service == nil {
+		backend := WebhookBackend{Reachability: ReachabilityURL}
+		if config.URL != nil {
+			backend.URL = *config.URL
+		}
+		return backend
+	}
+	backend := WebhookBackend{
+		ServiceNamespace: config.Service.Namespace,
+		ServiceName:      config.Service.Name,
+		// The API server defaults the port to 443
+		ServicePort: 443,
+	}
+	if config.Service.Path != nil {
+		backend.ServicePath = *config.Service.Path
+	}
+	if config.Service.Port != nil {
+		backend.ServicePort = *config.Service.Port
+	}
+	return backend
+}
+
+// failurePolicy and timeoutSeconds fill in the API server's defaults for unset fields
+func failurePolicy(policy *admissionregistrationv1.FailurePolicyType) admissionregistrationv1.FailurePolicyType {
+	if policy == nil {
+		return admissionregistrationv1.Fail
+	}
+	return *policy
+}
+
+func timeoutSeconds(timeout *int32) int32 {
+	if timeout == nil {
+		return 10
+	}
+	return *timeout
+}
+
+func sideEffects(class *admissionregistrationv1.SideEffectClass) admissionregistrationv1.SideEffectClass {
+	if class == nil {
+		return ""
+	}
+	return *class
+}
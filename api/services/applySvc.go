@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"kgent-api/pkg/manifest"
+	"kgent-api/pkg/policy"
+	"kgent-api/pkg/retry"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// applyFieldManager owns the fields applied from archives
+const applyFieldManager = "kgent-api"
+
+// Outcomes of ApplyObjectResult.Status
+const (
+	ApplyStatusApplied = "applied"
+	ApplyStatusPruned  = "pruned"
+	ApplyStatusFailed  = "failed"
+)
+
+// ApplyOptions controls how an archive of manifests is applied
+type ApplyOptions struct {
+	// Namespace is set on namespaced objects that don't name one
+	Namespace string
+	// Force takes over fields owned by other field managers instead of failing on conflicts
+	Force bool
+	// Prune deletes the objects matching PruneSelector that the archive no longer contains,
+	// among the kinds and namespaces it applied to, like kubectl apply --prune -l
+	Prune         bool
+	PruneSelector string
+	Limits        manifest.Limits
+}
+
+// ApplyReport is the outcome of applying an archive, file by file
+type ApplyReport struct {
+	Files  []ApplyFileResult   `json:"files"`
+	Pruned []ApplyObjectResult `json:"pruned,omitempty"`
+	// Applied and Failed count objects, Failed also counts files that could not be parsed
+	Applied int `json:"applied"`
+	Failed  int `json:"failed"`
+}
+
+// ApplyFileResult lists what happened to the objects of a file, or why it was skipped
+type ApplyFileResult struct {
+	Path    string              `json:"path"`
+	Note    string              `json:"note,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Objects []ApplyObjectResult `json:"objects,omitempty"`
+}
+
+// ApplyObjectResult is the outcome of applying or pruning one object
+type ApplyObjectResult struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Namespace  string             `json:"namespace,omitempty"`
+	Name       string             `json:"name"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Violations []policy.Violation `json:"violations,omitempty"`
+}
+
+// pruneScope is a resource in a namespace that objects were applied to
+type pruneScope struct {
+	resource  schema.GroupVersionResource
+	kind      schema.GroupVersionKind
+	namespace string
+}
+
+// ApplyArchive server-side applies every manifest in a gzipped tarball, as ApplyFiles does.
+// Only an unreadable archive fails the whole apply.
+func (r *ResourceService) ApplyArchive(ctx context.Context, archive io.Reader, opts ApplyOptions) (*ApplyReport, error) {
+	files, err := manifest.ReadArchive(archive, opts.Limits)
+	if errors.Is(err, manifest.ErrTooLarge) {
+		return nil, apierrors.NewRequestEntityTooLargeError(err.Error())
+	}
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return r.ApplyFiles(ctx, files, opts)
+}
+
+// ApplyFiles server-side applies the objects of files. Namespaces go first, then
+// CustomResourceDefinitions, then everything else in file order. A failed object doesn't
+// stop the others.
+func (r *ResourceService) ApplyFiles(ctx context.Context, files []manifest.File, opts ApplyOptions) (*ApplyReport, error) {
+	var pruneSelector labels.Selector
+	if opts.Prune {
+		if opts.PruneSelector == "" {
+			return nil, apierrors.NewBadRequest("prune requires a label selector")
+		}
+		selector, err := labels.Parse(opts.PruneSelector)
+		if err != nil {
+			return nil, &SelectorError{Selector: opts.PruneSelector, Err: err}
+		}
+		pruneSelector = selector
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+
+	type pending struct {
+		file, index int
+		obj         *unstructured.Unstructured
+	}
+	var queue []pending
+	report := &ApplyReport{Files: make([]ApplyFileResult, len(files))}
+	for i, file := range files {
+		report.Files[i] = ApplyFileResult{Path: file.Path, Note: file.Note}
+		if !file.Manifest() {
+			continue
+		}
+
+		objects, err := manifest.Decode(file.Data)
+		if err != nil {
+			report.Files[i].Error = err.Error()
+			report.Failed++
+			continue
+		}
+		if len(objects) == 0 {
+			report.Files[i].Note = "skipped, no objects"
+			continue
+		}
+		report.Files[i].Objects = make([]ApplyObjectResult, len(objects))
+		for j, obj := range objects {
+			queue = append(queue, pending{file: i, index: j, obj: obj})
+		}
+	}
+	manifest.SortByPriority(queue, func(p pending) string { return p.obj.GetKind() })
+
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := *r.restMapper
+	applied := map[pruneScope]sets.Set[string]{}
+	crdsApplied := false
+	for _, p := range queue {
+		// Custom resources of the archive's own CRDs are only mapped once discovery is refreshed
+		if crdsApplied && manifest.Priority(p.obj.GetKind()) > manifest.Priority("CustomResourceDefinition") {
+			mapper = r.refreshedMapper(mapper)
+			crdsApplied = false
+		}
+
+		result := &report.Files[p.file].Objects[p.index]
+		mapping, err := r.applyObject(ctx, client, mapper, p.obj, opts, result)
+		if err != nil {
+			result.Status, result.Error = ApplyStatusFailed, err.Error()
+			report.Failed++
+			continue
+		}
+		result.Status = ApplyStatusApplied
+		report.Applied++
+
+		if p.obj.GetKind() == "CustomResourceDefinition" {
+			crdsApplied = true
+		}
+		scope := pruneScope{resource: mapping.Resource, kind: mapping.GroupVersionKind, namespace: p.obj.GetNamespace()}
+		if applied[scope] == nil {
+			applied[scope] = sets.New[string]()
+		}
+		applied[scope].Insert(p.obj.GetName())
+	}
+
+	if opts.Prune {
+		report.Pruned = r.prune(ctx, client, applied, pruneSelector)
+		for _, result := range report.Pruned {
+			if result.Status == ApplyStatusFailed {
+				report.Failed++
+			}
+		}
+	}
+	return report, nil
+}
+
+// applyObject server-side applies obj, filling in result, and returns its mapping
+func (r *ResourceService) applyObject(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, opts ApplyOptions, result *ApplyObjectResult) (*meta.RESTMapping, error) {
+	*result = ApplyObjectResult{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Name: obj.GetName()}
+	if obj.GetName() == "" {
+		return nil, fmt.Errorf("metadata.name is required")
+	}
+
+	mapping, err := mapObject(mapper, obj, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	result.Namespace = obj.GetNamespace()
+	if err := r.checkScope(mapping, obj.GetNamespace()); err != nil {
+		return nil, err
+	}
+
+	violations, err := r.evaluatePolicy(obj)
+	var violationErr *policy.ViolationError
+	if errors.As(err, &violationErr) {
+		result.Violations = violationErr.Violations
+		return nil, err
+	}
+	result.Violations = violations
+
+	ri := dynamic.ResourceInterface(client.Resource(mapping.Resource))
+	if namespaced {
+		ri = client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	}
+	err = retry.Do(ctx, "apply", func(int) error {
+		_, err := ri.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: applyFieldManager, Force: opts.Force})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply: %w", err)
+	}
+	return mapping, nil
+}
+
+// mapObject maps obj to its resource, and sets its namespace as the API server would: cleared
+// for cluster-scoped objects and defaulted to namespace for namespaced ones that have none
+func mapObject(mapper meta.RESTMapper, obj *unstructured.Unstructured, namespace string) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", gvk.String(), err)
+	}
+	switch {
+	case mapping.Scope.Name() != meta.RESTScopeNameNamespace:
+		obj.SetNamespace("")
+	case obj.GetNamespace() == "":
+		obj.SetNamespace(namespace)
+	}
+	return mapping, nil
+}
+
+// prune deletes the objects matching selector in each applied scope that were not applied
+func (r *ResourceService) prune(ctx context.Context, client dynamic.Interface, applied map[pruneScope]sets.Set[string], selector labels.Selector) []ApplyObjectResult {
+	var results []ApplyObjectResult
+	for scope, names := range applied {
+		ri := dynamic.ResourceInterface(client.Resource(scope.resource))
+		if scope.namespace != "" {
+			ri = client.Resource(scope.resource).Namespace(scope.namespace)
+		}
+		gvk := scope.kind
+
+		var list *unstructured.UnstructuredList
+		err := retry.Do(ctx, "list", func(int) (err error) {
+			list, err = ri.List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+			return err
+		})
+		if err != nil {
+			results = append(results, ApplyObjectResult{
+				APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Namespace: scope.namespace,
+				Status: ApplyStatusFailed, Error: fmt.Sprintf("failed to list objects to prune: %v", err),
+			})
+			continue
+		}
+
+		for _, item := range list.Items {
+			if names.Has(item.GetName()) {
+				continue
+			}
+			result := ApplyObjectResult{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Namespace: item.GetNamespace(), Name: item.GetName(), Status: ApplyStatusPruned}
+			if err := ri.Delete(ctx, item.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				result.Status, result.Error = ApplyStatusFailed, err.Error()
+			}
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return results
+}
+
+// refreshedMapper rebuilds the REST mapper from discovery, keeping mapper when discovery
+// isn't configured or fails
+func (r *ResourceService) refreshedMapper(mapper meta.RESTMapper) meta.RESTMapper {
+	if r.apiResources == nil {
+		return mapper
+	}
+	groups, err := restmapper.GetAPIGroupResources(r.apiResources.client)
+	if err != nil {
+		return mapper
+	}
+	return restmapper.NewDiscoveryRESTMapper(groups)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"kgent-api/pkg/audit"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AuditKey identifies the object ns/name of resourceOrKindArg in the audit store, by its
+// resource so that kinds and short names key it the same way, and without a namespace when
+// the resource is cluster-scoped. Unknown resources are keyed by the argument as given.
+func (r *ResourceService) AuditKey(resourceOrKindArg string, ns string, name string) audit.Key {
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return audit.Key{Resource: resourceOrKindArg, Namespace: ns, Name: name}
+	}
+	return auditKey(restMapping, ns, name)
+}
+
+// AuditKeyForKind is AuditKey for an object of apiVersion and kind
+func (r *ResourceService) AuditKeyForKind(apiVersion string, kind string, ns string, name string) audit.Key {
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	restMapping, err := (*r.restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return audit.Key{Group: gvk.Group, Resource: kind, Namespace: ns, Name: name}
+	}
+	return auditKey(restMapping, ns, name)
+}
+
+func auditKey(restMapping *meta.RESTMapping, ns string, name string) audit.Key {
+	if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		ns = ""
+	}
+	return audit.Key{Group: restMapping.Resource.Group, Resource: restMapping.Resource.Resource, Namespace: ns, Name: name}
+}
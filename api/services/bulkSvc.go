@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	bulkWorkers = 5
+	bulkTimeout = 60 * time.Second
+)
+
+// BulkRequest describes one action applied to several objects of the same resource type
+type BulkRequest struct {
+	Action      string            `json:"action" binding:"required"`
+	Namespace   string            `json:"ns"`
+	Names       []string          `json:"names" binding:"required"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// BulkResult is the outcome of the action for a single object
+type BulkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// restartableResources lists the workloads that support a rollout restart
+var restartableResources = map[string]bool{
+	"deployments":  true,
+	"statefulsets": true,
+	"daemonsets":   true,
+}
+
+// BulkAction runs the requested action against every named object concurrently with a
+// bounded worker pool. All items share a single deadline so one hung call cannot stall the batch.
+func (r *ResourceService) BulkAction(ctx context.Context, resourceOrKindArg string, req BulkRequest) ([]BulkResult, error) {
+	if len(req.Names) == 0 {
+		return nil, &EmptyArgumentError{Argument: "names"}
+	}
+
+	ns := req.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+
+	restMapper, err := r.restMapperFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	restMapping, err := r.mappingFor(resourceOrKindArg, restMapper)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		return nil, err
+	}
+
+	// verb is the one the action needs the resource to support
+	var verb string
+	var action func(ctx context.Context, ri dynamic.ResourceInterface, name string) error
+	switch req.Action {
+	case "delete":
+		verb = "delete"
+		action = func(ctx context.Context, ri dynamic.ResourceInterface, name string) error {
+			return ri.Delete(ctx, name, metav1.DeleteOptions{})
+		}
+	case "label", "annotate":
+		verb = "patch"
+		field, values := "labels", req.Labels
+		if req.Action == "annotate" {
+			field, values = "annotations", req.Annotations
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s cannot be empty for action %s", field, req.Action)
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{field: values},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build patch: %w", err)
+		}
+		action = func(ctx context.Context, ri dynamic.ResourceInterface, name string) error {
+			_, err := ri.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		}
+	case "restart":
+		if !restartableResources[restMapping.Resource.Resource] {
+			return nil, fmt.Errorf("action restart is not supported for %s", restMapping.Resource.Resource)
+		}
+		verb = "patch"
+		action = func(ctx context.Context, ri dynamic.ResourceInterface, name string) error {
+			_, err := ri.Patch(ctx, name, types.MergePatchType, restartPatch(), metav1.PatchOptions{})
+			return err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bulk action %q, expected one of delete, label, annotate, restart", req.Action)
+	}
+
+	if err := r.checkVerb(resourceOrKindArg, verb); err != nil {
+		return nil, err
+	}
+
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout)
+	defer cancel()
+
+	results := make([]BulkResult, len(req.Names))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(bulkWorkers, len(req.Names)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				name := req.Names[i]
+				result := BulkResult{Name: name, Status: "succeeded"}
+				if err := action(ctx, ri, name); err != nil {
+					result.Status = "failed"
+					result.Error = err.Error()
+				}
+				results[i] = result
+			}
+		}()
+	}
+
+	for i := range req.Names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// restartPatch returns the same pod template annotation patch used by `kubectl rollout restart`
+func restartPatch() []byte {
+	patch, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						"kubectl.kubernetes.io/restartedAt": time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	return patch
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/openapi"
+)
+
+// verbDiscovery serves resources from the fake discovery client, which panics for OpenAPI
+// schemas the verb checks don't need
+type verbDiscovery struct {
+	discovery.DiscoveryInterface
+}
+
+func (verbDiscovery) OpenAPIV3() openapi.Client {
+	return nil
+}
+
+func TestBulkActionChecksVerbs(t *testing.T) {
+	// Discovery serves pods as a read-only resource and configmaps with every verb
+	clientset := fake.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "watch"}},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "watch", "patch", "delete"}},
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		resource string
+		req      BulkRequest
+		// wantVerb is the unsupported verb the action is rejected for
+		wantVerb string
+	}{
+		{name: "delete", resource: "pods", req: BulkRequest{Action: "delete", Names: []string{"web-0"}}, wantVerb: "delete"},
+		{name: "label", resource: "pods", req: BulkRequest{Action: "label", Names: []string{"web-0"}, Labels: map[string]string{"tier": "web"}}, wantVerb: "patch"},
+		{name: "supported", resource: "configmaps", req: BulkRequest{Action: "delete", Names: []string{"web"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{testPod("default", "web-0"), &corev1.ConfigMap{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}, ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}}
+			svc, client := newTestResourceService(t, objects, WithDiscovery(verbDiscovery{clientset.Discovery()}))
+
+			results, err := svc.BulkAction(context.Background(), tt.resource, tt.req)
+			if tt.wantVerb == "" {
+				if err != nil || len(results) != 1 || results[0].Status != "succeeded" {
+					t.Fatalf("BulkAction() = %+v, %v, want it to succeed", results, err)
+				}
+				return
+			}
+
+			var verbErr *UnsupportedVerbError
+			if !errors.As(err, &verbErr) {
+				t.Fatalf("BulkAction() error = %v, want an *UnsupportedVerbError", err)
+			}
+			if verbErr.Verb != tt.wantVerb || !reflect.DeepEqual(verbErr.Supported, []string{"get", "list", "watch"}) {
+				t.Errorf("BulkAction() error = %+v, want %s unsupported with the pod verbs", verbErr, tt.wantVerb)
+			}
+			if actions := client.Actions(); len(actions) != 0 {
+				t.Errorf("BulkAction() sent %v to the API server, want nothing", actions)
+			}
+		})
+	}
+}
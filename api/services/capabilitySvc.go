@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/retry"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// discoveryRefreshInterval limits how often a lookup miss triggers a rediscovery,
+// so newly installed CRDs show up without hammering the API server
+const discoveryRefreshInterval = 30 * time.Second
+
+// APIResourceInfo is what discovery reports about a resource
+type APIResourceInfo struct {
+	Group        string   `json:"group,omitempty"`
+	Version      string   `json:"version"`
+	Resource     string   `json:"resource"`
+	Kind         string   `json:"kind"`
+	SingularName string   `json:"singularName,omitempty"`
+	ShortNames   []string `json:"shortNames,omitempty"`
+	Namespaced   bool     `json:"namespaced"`
+	Verbs        []string `json:"verbs"`
+}
+
+// UnsupportedVerbError reports an operation the resource does not allow
+type UnsupportedVerbError struct {
+	Resource  string
+	Verb      string
+	Supported []string
+}
+
+func (e *UnsupportedVerbError) Error() string {
+	return fmt.Sprintf("%s does not support %s, supported verbs: %s", e.Resource, e.Verb, strings.Join(e.Supported, ", "))
+}
+
+type apiResourceCache struct {
+	client discovery.DiscoveryInterface
+
+	mu        sync.RWMutex
+	resources map[schema.GroupVersionResource]APIResourceInfo
+	loadedAt  time.Time
+}
+
+func newAPIResourceCache(client discovery.DiscoveryInterface) *apiResourceCache {
+	return &apiResourceCache{client: client, resources: map[schema.GroupVersionResource]APIResourceInfo{}}
+}
+
+// load refreshes the cache from discovery. Groups that fail discovery are skipped.
+func (c *apiResourceCache) load() {
+	var lists []*metav1.APIResourceList
+	err := retry.Do(context.Background(), "discovery", func(int) (err error) {
+		_, lists, err = c.client.ServerGroupsAndResources()
+		if err != nil && len(lists) > 0 {
+			// Partial discovery failures are expected with unavailable aggregated APIs
+			return nil
+		}
+		return err
+	})
+	if err != nil && len(lists) == 0 {
+		return
+	}
+
+	resources := map[schema.GroupVersionResource]APIResourceInfo{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			// Subresources such as pods/log are not addressable on their own
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			resources[gvr] = APIResourceInfo{
+				Group:        gv.Group,
+				Version:      gv.Version,
+				Resource:     resource.Name,
+				Kind:         resource.Kind,
+				SingularName: resource.SingularName,
+				ShortNames:   resource.ShortNames,
+				Namespaced:   resource.Namespaced,
+				Verbs:        resource.Verbs,
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.resources = resources
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *apiResourceCache) stale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.loadedAt) > discoveryRefreshInterval
+}
+
+// lookup returns the discovery information for gvr, rediscovering on a miss
+func (c *apiResourceCache) lookup(gvr schema.GroupVersionResource) (APIResourceInfo, bool) {
+	c.mu.RLock()
+	info, ok := c.resources[gvr]
+	c.mu.RUnlock()
+	if ok || !c.stale() {
+		return info, ok
+	}
+
+	c.load()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	info, ok = c.resources[gvr]
+	return info, ok
+}
+
+// all returns every cached resource sorted by group and name
+func (c *apiResourceCache) all() []APIResourceInfo {
+	if c.stale() {
+		c.load()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	resources := make([]APIResourceInfo, 0, len(c.resources))
+	for _, info := range c.resources {
+		resources = append(resources, info)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Group != resources[j].Group {
+			return resources[i].Group < resources[j].Group
+		}
+		if resources[i].Resource != resources[j].Resource {
+			return resources[i].Resource < resources[j].Resource
+		}
+		return resources[i].Version < resources[j].Version
+	})
+	return resources
+}
+
+// checkVerb returns an *UnsupportedVerbError when discovery says the resource does not
+// support verb. Resources unknown to discovery are let through to the API server.
+func (r *ResourceService) checkVerb(resourceOrKindArg string, verb string) error {
+	if r.apiResources == nil {
+		return nil
+	}
+
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return err
+	}
+
+	info, ok := r.apiResources.lookup(restMapping.Resource)
+	if !ok || slices.Contains(info.Verbs, verb) {
+		return nil
+	}
+	return &UnsupportedVerbError{Resource: restMapping.Resource.Resource, Verb: verb, Supported: info.Verbs}
+}
+
+// APIResources lists the resources served by the cluster, limited to those supporting verb when set
+func (r *ResourceService) APIResources(verb string) ([]APIResourceInfo, error) {
+	if r.apiResources == nil {
+		return nil, fmt.Errorf("discovery is not configured")
+	}
+
+	resources := []APIResourceInfo{}
+	for _, info := range r.apiResources.all() {
+		if verb == "" || slices.Contains(info.Verbs, verb) {
+			resources = append(resources, info)
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"kgent-api/pkg/changes"
+	"kgent-api/pkg/stream"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+)
+
+// RecordedResources are the informer-backed resources the change recorder watches
+var RecordedResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "pods"},
+	{Version: "v1", Resource: "nodes"},
+	{Version: "v1", Resource: "services"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Version: "v1", Resource: "persistentvolumeclaims"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"},
+}
+
+// ErrChangeRecorderDisabled is returned when change history is queried without the recorder enabled
+var ErrChangeRecorderDisabled = fmt.Errorf("change recorder is disabled, set CHANGE_RECORDER=true to enable it")
+
+type ChangeService struct {
+	recorder *changes.Recorder
+}
+
+// NewChangeService creates the service. A nil recorder means change recording is disabled.
+func NewChangeService(recorder *changes.Recorder) *ChangeService {
+	return &ChangeService{recorder: recorder}
+}
+
+// Record starts recording changes for every RecordedResources entry not in excluded
+func (s *ChangeService) Record(fact informers.SharedInformerFactory, excluded []string) error {
+	if s.recorder == nil {
+		return ErrChangeRecorderDisabled
+	}
+
+	for _, gvr := range RecordedResources {
+		if slices.Contains(excluded, gvr.Resource) {
+			continue
+		}
+		informer, err := fact.ForResource(gvr)
+		if err != nil {
+			return fmt.Errorf("failed to get informer for %s: %w", gvr.Resource, err)
+		}
+		if err := s.recorder.Watch(gvr, informer.Informer()); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", gvr.Resource, err)
+		}
+	}
+	return nil
+}
+
+// List returns recorded changes in the namespace and resource newer than since, oldest first
+func (s *ChangeService) List(ns string, resource string, since time.Duration) ([]changes.Record, error) {
+	if s.recorder == nil {
+		return nil, ErrChangeRecorderDisabled
+	}
+	return s.recorder.List(changeQuery(ns, resource, since)), nil
+}
+
+// Subscribe buffers new changes in the namespace and resource until the returned function is called
+func (s *ChangeService) Subscribe(ns string, resource string) (*stream.Buffer[changes.Record], func(), error) {
+	if s.recorder == nil {
+		return nil, nil, ErrChangeRecorderDisabled
+	}
+	buffer, cancel := s.recorder.Subscribe(changeQuery(ns, resource, 0))
+	return buffer, cancel, nil
+}
+
+// Resume subscribes like Subscribe, also returning the recorded changes after the one at
+// lastResourceVersion, or found unset when that one is no longer recorded
+func (s *ChangeService) Resume(ns string, resource string, lastResourceVersion string) ([]changes.Record, bool, *stream.Buffer[changes.Record], func(), error) {
+	if s.recorder == nil {
+		return nil, false, nil, nil, ErrChangeRecorderDisabled
+	}
+	missed, found, buffer, cancel := s.recorder.Resume(changeQuery(ns, resource, 0), lastResourceVersion)
+	return missed, found, buffer, cancel, nil
+}
+
+func changeQuery(ns string, resource string, since time.Duration) changes.Query {
+	query := changes.Query{Namespace: ns, Resource: resource}
+	if since > 0 {
+		query.Since = time.Now().Add(-since)
+	}
+	return query
+}
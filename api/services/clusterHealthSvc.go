@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"strings"
+)
+
+// HealthCheck is a single named check reported by a verbose livez/readyz response
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// EndpointHealth is the parsed result of one API server health endpoint. Checks is empty
+// when the server withholds verbose output and only the overall result is known.
+type EndpointHealth struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []HealthCheck `json:"checks"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// ClusterHealth combines the API server's liveness and readiness with etcd health when exposed
+type ClusterHealth struct {
+	Healthy bool           `json:"healthy"`
+	Livez   EndpointHealth `json:"livez"`
+	Readyz  EndpointHealth `json:"readyz"`
+	Etcd    *HealthCheck   `json:"etcd,omitempty"`
+}
+
+// Health queries /livez and /readyz on the API server and reports their per-check results
+func (s *ClusterService) Health(ctx context.Context) *ClusterHealth {
+	health := &ClusterHealth{
+		Livez:  s.endpointHealth(ctx, "/livez"),
+		Readyz: s.endpointHealth(ctx, "/readyz"),
+	}
+	health.Healthy = health.Livez.Healthy && health.Readyz.Healthy
+
+	for _, checks := range [][]HealthCheck{health.Readyz.Checks, health.Livez.Checks} {
+		for i := range checks {
+			if checks[i].Name == "etcd" {
+				health.Etcd = &checks[i]
+				return health
+			}
+		}
+	}
+	return health
+}
+
+// endpointHealth requests the verbose form of a health endpoint and falls back to the plain
+// form when the response carries no per-check lines
+func (s *ClusterService) endpointHealth(ctx context.Context, path string) EndpointHealth {
+	rest := s.client.Discovery().RESTClient()
+
+	// Failing checks make the server answer 500, but the body still holds the verbose report
+	body, err := rest.Get().AbsPath(path).Param("verbose", "").DoRaw(ctx)
+	checks := parseHealthChecks(string(body))
+	if len(checks) > 0 {
+		result := EndpointHealth{Healthy: err == nil, Checks: checks}
+		if err != nil {
+			result.Error = strings.TrimSpace(lastLine(string(body)))
+		}
+		return result
+	}
+
+	body, err = rest.Get().AbsPath(path).DoRaw(ctx)
+	if err != nil {
+		return EndpointHealth{Checks: []HealthCheck{}, Error: err.Error()}
+	}
+	return EndpointHealth{Healthy: strings.TrimSpace(string(body)) == "ok", Checks: []HealthCheck{}}
+}
+
+// parseHealthChecks reads the "[+]name ok" and "[-]name failed: reason" lines of a verbose response
+func parseHealthChecks(body string) []HealthCheck {
+	var checks []HealthCheck
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 4 || (!strings.HasPrefix(line, "[+]") && !strings.HasPrefix(line, "[-]")) {
+			continue
+		}
+
+		check := HealthCheck{Healthy: line[1] == '+'}
+		name, message, _ := strings.Cut(line[3:], " ")
+		check.Name = name
+		if !check.Healthy {
+			check.Message = message
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func lastLine(body string) string {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	return lines[len(lines)-1]
+}
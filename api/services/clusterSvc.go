@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"kgent-api/pkg/capacity"
+	"kgent-api/pkg/clientutil"
+	"kgent-api/pkg/warnings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+type ClusterService struct {
+	client   kubernetes.Interface
+	fact     informers.SharedInformerFactory
+	recorder *warnings.Recorder
+	// throttling records the 429 responses of the clients, for FlowControl
+	throttling *clientutil.Throttling
+}
+
+func NewClusterService(client kubernetes.Interface, fact informers.SharedInformerFactory, recorder *warnings.Recorder, throttling *clientutil.Throttling) *ClusterService {
+	return &ClusterService{client: client, fact: fact, recorder: recorder, throttling: throttling}
+}
+
+// Capacity summarizes allocatable resources against the requests of pods scheduled on each node
+func (s *ClusterService) Capacity(threshold float64) (*capacity.Report, error) {
+	nodes, err := s.fact.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	pods, err := s.fact.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	report := capacity.Summarize(nodes, pods, threshold)
+	return &report, nil
+}
+
+// Deprecations returns the deprecation warnings the API server has sent since startup, most recent first
+func (s *ClusterService) Deprecations() []warnings.Observation {
+	deprecations := []warnings.Observation{}
+	for _, observation := range s.recorder.Recent() {
+		if strings.Contains(strings.ToLower(observation.Message), "deprecated") {
+			deprecations = append(deprecations, observation)
+		}
+	}
+	return deprecations
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/refs"
+	"kgent-api/pkg/retry"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+type ConsumerService struct {
+	client kubernetes.Interface
+	fact   informers.SharedInformerFactory
+}
+
+func NewConsumerService(client kubernetes.Interface, fact informers.SharedInformerFactory) *ConsumerService {
+	return &ConsumerService{client: client, fact: fact}
+}
+
+// Consumer is an object referencing a ConfigMap or Secret, with the paths of its references
+type Consumer struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+// Consumers lists the objects referencing a ConfigMap or Secret, by the kind of the referrer
+type Consumers struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Exists is unset when the object is missing, leaving its consumers with a broken reference
+	Exists    bool                  `json:"exists"`
+	Consumers map[string][]Consumer `json:"consumers"`
+	Count     int                   `json:"count"`
+	// Skipped lists the kinds that could not be checked, and why
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// SecretConsumers finds what references a Secret: pods, workload pod templates, so
+// workloads scaled to zero are found too, ServiceAccounts and Ingress TLS
+func (s *ConsumerService) SecretConsumers(ctx context.Context, ns, name string) (*Consumers, error) {
+	_, err := s.fact.Core().V1().Secrets().Lister().Secrets(ns).Get(name)
+	return s.consumers(ctx, refs.KindSecret, ns, name, err)
+}
+
+// ConfigMapConsumers finds what references a ConfigMap: pods and workload pod templates
+func (s *ConsumerService) ConfigMapConsumers(ctx context.Context, ns, name string) (*Consumers, error) {
+	_, err := s.fact.Core().V1().ConfigMaps().Lister().ConfigMaps(ns).Get(name)
+	return s.consumers(ctx, refs.KindConfigMap, ns, name, err)
+}
+
+// consumers collects the references to an object of kind, getErr being the error of
+// getting it
+func (s *ConsumerService) consumers(ctx context.Context, kind, ns, name string, getErr error) (*Consumers, error) {
+	if name == "" {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("%s name cannot be empty", kind))
+	}
+	if ns == "" {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("a namespace is required to find the consumers of %s %s", kind, name))
+	}
+	if getErr != nil && !apierrors.IsNotFound(getErr) {
+		return nil, fmt.Errorf("failed to get %s %s: %w", kind, name, getErr)
+	}
+
+	result := &Consumers{Kind: kind, Namespace: ns, Name: name, Exists: getErr == nil, Consumers: map[string][]Consumer{}}
+	collect := func(referrerKind, referrerName string, found []refs.Ref) {
+		var paths []string
+		for _, ref := range found {
+			if ref.Kind == kind && ref.Name == name {
+				paths = append(paths, ref.Path)
+			}
+		}
+		if len(paths) > 0 {
+			result.Consumers[referrerKind] = append(result.Consumers[referrerKind], Consumer{Name: referrerName, Paths: paths})
+			result.Count++
+		}
+	}
+
+	pods, err := s.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods {
+		collect("Pod", pod.Name, refs.PodSpec(&pod.Spec, refs.PodSpecPath))
+	}
+
+	apps := s.fact.Apps().V1()
+	deployments, err := apps.Deployments().Lister().Deployments(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments {
+		collect("Deployment", d.Name, refs.PodSpec(&d.Spec.Template.Spec, refs.TemplateSpecPath))
+	}
+	statefulSets, err := apps.StatefulSets().Lister().StatefulSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, sts := range statefulSets {
+		collect("StatefulSet", sts.Name, refs.PodSpec(&sts.Spec.Template.Spec, refs.TemplateSpecPath))
+	}
+	daemonSets, err := apps.DaemonSets().Lister().DaemonSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, ds := range daemonSets {
+		collect("DaemonSet", ds.Name, refs.PodSpec(&ds.Spec.Template.Spec, refs.TemplateSpecPath))
+	}
+	// ReplicaSets and Jobs with a controller are covered by its template
+	replicaSets, err := apps.ReplicaSets().Lister().ReplicaSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	for _, rs := range replicaSets {
+		if metav1.GetControllerOf(rs) == nil {
+			collect("ReplicaSet", rs.Name, refs.PodSpec(&rs.Spec.Template.Spec, refs.TemplateSpecPath))
+		}
+	}
+	jobs, err := s.fact.Batch().V1().Jobs().Lister().Jobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if metav1.GetControllerOf(job) == nil {
+			collect("Job", job.Name, refs.PodSpec(&job.Spec.Template.Spec, refs.TemplateSpecPath))
+		}
+	}
+	cronJobs, err := s.fact.Batch().V1().CronJobs().Lister().CronJobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	for _, cj := range cronJobs {
+		collect("CronJob", cj.Name, refs.PodSpec(&cj.Spec.JobTemplate.Spec.Template.Spec, refs.CronJobSpecPath))
+	}
+
+	// ServiceAccounts and Ingresses aren't cached, and the credentials may not allow listing them
+	if kind == refs.KindSecret {
+		var serviceAccounts *corev1.ServiceAccountList
+		err := retry.Do(ctx, "list", func(int) (err error) {
+			serviceAccounts, err = s.client.CoreV1().ServiceAccounts(ns).List(ctx, metav1.ListOptions{})
+			return err
+		})
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("ServiceAccount: %v", err))
+		} else {
+			for i := range serviceAccounts.Items {
+				collect("ServiceAccount", serviceAccounts.Items[i].Name, refs.ServiceAccount(&serviceAccounts.Items[i]))
+			}
+		}
+	}
+
+	var ingresses *networkingv1.IngressList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		ingresses, err = s.client.NetworkingV1().Ingresses(ns).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("Ingress: %v", err))
+	} else {
+		for i := range ingresses.Items {
+			collect("Ingress", ingresses.Items[i].Name, refs.Ingress(&ingresses.Items[i]))
+		}
+	}
+
+	for _, consumers := range result.Consumers {
+		sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	}
+	return result, nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientretry "k8s.io/client-go/util/retry"
+)
+
+var (
+	// ErrEnvNotFound is returned in strict mode for removed variables the container doesn't have
+	ErrEnvNotFound = errors.New("environment variable not found")
+	// ErrEnvValueFrom is returned for plain values replacing a variable set from a secret,
+	// config map or field reference, unless overridden
+	ErrEnvValueFrom = errors.New("environment variable is set with valueFrom, set overrideValueFrom=true to replace it")
+)
+
+// ContainerEnvRequest is the body accepted when changing a container's environment. Set
+// gives plain values, SetFrom variables taken from secrets, config maps or fields.
+type ContainerEnvRequest struct {
+	Container string            `json:"container"`
+	Set       map[string]string `json:"set"`
+	Remove    []string          `json:"remove"`
+	SetFrom   []EnvVarFrom      `json:"setFrom"`
+}
+
+// EnvVarFrom is a variable taken from a secret, config map or field. The source is given
+// either directly, as in {"name": "DB_URL", "secretKeyRef": {...}}, or wrapped in valueFrom
+// as in a container spec.
+type EnvVarFrom struct {
+	Name             string                        `json:"name"`
+	Value            string                        `json:"value,omitempty"`
+	SecretKeyRef     *corev1.SecretKeySelector     `json:"secretKeyRef,omitempty"`
+	ConfigMapKeyRef  *corev1.ConfigMapKeySelector  `json:"configMapKeyRef,omitempty"`
+	FieldRef         *corev1.ObjectFieldSelector   `json:"fieldRef,omitempty"`
+	ResourceFieldRef *corev1.ResourceFieldSelector `json:"resourceFieldRef,omitempty"`
+	ValueFrom        *corev1.EnvVarSource          `json:"valueFrom,omitempty"`
+}
+
+// source returns the variable's source, nil unless exactly one is given
+func (v EnvVarFrom) source() *corev1.EnvVarSource {
+	flat := &corev1.EnvVarSource{
+		SecretKeyRef:     v.SecretKeyRef,
+		ConfigMapKeyRef:  v.ConfigMapKeyRef,
+		FieldRef:         v.FieldRef,
+		ResourceFieldRef: v.ResourceFieldRef,
+	}
+	sources := 0
+	for _, source := range []*corev1.EnvVarSource{flat, v.ValueFrom} {
+		if source == nil {
+			continue
+		}
+		for _, set := range []bool{source.SecretKeyRef != nil, source.ConfigMapKeyRef != nil,
+			source.FieldRef != nil, source.ResourceFieldRef != nil} {
+			if set {
+				sources++
+			}
+		}
+	}
+	if sources != 1 {
+		return nil
+	}
+	if v.ValueFrom != nil {
+		return v.ValueFrom
+	}
+	return flat
+}
+
+// EnvVar returns the container variable, without a source unless exactly one is given
+func (v EnvVarFrom) EnvVar() corev1.EnvVar {
+	return corev1.EnvVar{Name: v.Name, ValueFrom: v.source()}
+}
+
+// EnvOptions tunes how strictly an environment change is applied
+type EnvOptions struct {
+	// Strict fails when a removed variable doesn't exist
+	Strict bool
+	// OverrideValueFrom lets plain values replace variables set with valueFrom
+	OverrideValueFrom bool
+}
+
+// ContainerEnvChange is the change made to a container's environment, with its new variables
+type ContainerEnvChange struct {
+	Kind      string          `json:"kind"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Container string          `json:"container"`
+	Added     []string        `json:"added"`
+	Updated   []string        `json:"updated"`
+	Removed   []string        `json:"removed"`
+	Env       []corev1.EnvVar `json:"env"`
+}
+
+// SetContainerEnv changes the environment variables of a container in the pod template of a
+// Deployment, StatefulSet or DaemonSet. Untouched variables keep their order, updated ones
+// their place, and new ones are appended. The workload is read, changed and written back,
+// starting over when another write got in between.
+func (w *WorkloadService) SetContainerEnv(ctx context.Context, kind, ns, name string, req ContainerEnvRequest, opts EnvOptions) (*ContainerEnvChange, error) {
+	resource := strings.ToLower(kind)
+	switch resource {
+	case "deployments", "statefulsets", "daemonsets":
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported workload kind %q, expected one of deployments, statefulsets, daemonsets", kind))
+	}
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: resource + " name"}
+	}
+	if req.Container == "" {
+		return nil, &EmptyArgumentError{Argument: "container"}
+	}
+	if err := validateEnvRequest(req); err != nil {
+		return nil, err
+	}
+
+	var change *ContainerEnvChange
+	err := w.updatePodSpec(ctx, resource, ns, name, func(spec *corev1.PodSpec) (bool, error) {
+		container := findContainer(spec, req.Container)
+		if container == nil {
+			return false, fmt.Errorf("%s %s has no container %q: %w", resource, name, req.Container, ErrContainerNotFound)
+		}
+		change = &ContainerEnvChange{Kind: resource, Namespace: ns, Name: name, Container: req.Container,
+			Added: []string{}, Updated: []string{}, Removed: []string{}}
+		env, err := editEnv(container.Env, req, opts, change)
+		if err != nil {
+			return false, err
+		}
+		container.Env = env
+		change.Env = env
+		return len(change.Added)+len(change.Updated)+len(change.Removed) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func validateEnvRequest(req ContainerEnvRequest) error {
+	if len(req.Set) == 0 && len(req.Remove) == 0 && len(req.SetFrom) == 0 {
+		return apierrors.NewBadRequest("set, remove or setFrom is required")
+	}
+	named := map[string]string{}
+	claim := func(name, field string) error {
+		if name == "" {
+			return apierrors.NewBadRequest(fmt.Sprintf("%s has a variable without a name", field))
+		}
+		if other, ok := named[name]; ok {
+			return apierrors.NewBadRequest(fmt.Sprintf("variable %s is in both %s and %s", name, other, field))
+		}
+		named[name] = field
+		return nil
+	}
+	for name := range req.Set {
+		if err := claim(name, "set"); err != nil {
+			return err
+		}
+	}
+	for _, name := range req.Remove {
+		if err := claim(name, "remove"); err != nil {
+			return err
+		}
+	}
+	for _, env := range req.SetFrom {
+		if err := claim(env.Name, "setFrom"); err != nil {
+			return err
+		}
+		if env.source() == nil || env.Value != "" {
+			return apierrors.NewBadRequest(fmt.Sprintf("setFrom variable %s needs exactly one of secretKeyRef, configMapKeyRef, fieldRef or resourceFieldRef and no value", env.Name))
+		}
+	}
+	return nil
+}
+
+// editEnv applies req to env, recording what changed. Every entry of a variable set more
+// than once is changed alike.
+func editEnv(env []corev1.EnvVar, req ContainerEnvRequest, opts EnvOptions, change *ContainerEnvChange) ([]corev1.EnvVar, error) {
+	present := map[string]bool{}
+	for _, variable := range env {
+		present[variable.Name] = true
+	}
+
+	removed := map[string]bool{}
+	for _, name := range req.Remove {
+		if !present[name] {
+			if opts.Strict {
+				return nil, fmt.Errorf("%s: %w", name, ErrEnvNotFound)
+			}
+			continue
+		}
+		removed[name] = true
+		change.Removed = append(change.Removed, name)
+	}
+	from := map[string]corev1.EnvVar{}
+	for _, variable := range req.SetFrom {
+		from[variable.Name] = variable.EnvVar()
+	}
+
+	result := make([]corev1.EnvVar, 0, len(env)+len(req.Set)+len(req.SetFrom))
+	updated := map[string]bool{}
+	for _, variable := range env {
+		if removed[variable.Name] {
+			continue
+		}
+		if value, ok := req.Set[variable.Name]; ok {
+			if variable.ValueFrom != nil && !opts.OverrideValueFrom {
+				return nil, fmt.Errorf("%s: %w", variable.Name, ErrEnvValueFrom)
+			}
+			if variable.ValueFrom != nil || variable.Value != value {
+				updated[variable.Name] = true
+			}
+			variable = corev1.EnvVar{Name: variable.Name, Value: value}
+		} else if source, ok := from[variable.Name]; ok {
+			if !equality.Semantic.DeepEqual(variable, source) {
+				updated[variable.Name] = true
+			}
+			variable = source
+		}
+		result = append(result, variable)
+	}
+
+	names := make([]string, 0, len(req.Set))
+	for name := range req.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !present[name] {
+			result = append(result, corev1.EnvVar{Name: name, Value: req.Set[name]})
+			change.Added = append(change.Added, name)
+		}
+	}
+	for _, variable := range req.SetFrom {
+		if !present[variable.Name] {
+			result = append(result, variable.EnvVar())
+			change.Added = append(change.Added, variable.Name)
+		}
+	}
+	for name := range updated {
+		change.Updated = append(change.Updated, name)
+	}
+	sort.Strings(change.Updated)
+	return result, nil
+}
+
+// updatePodSpec gets a Deployment, StatefulSet or DaemonSet, lets mutate change its pod
+// template spec and updates it when mutate reports a change, starting over from the get
+// when the update conflicts with another write
+func (w *WorkloadService) updatePodSpec(ctx context.Context, resource, ns, name string, mutate func(*corev1.PodSpec) (bool, error)) error {
+	err := clientretry.RetryOnConflict(clientretry.DefaultRetry, func() error {
+		switch resource {
+		case "deployments":
+			deployment, err := w.client.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if changed, err := mutate(&deployment.Spec.Template.Spec); err != nil || !changed {
+				return err
+			}
+			_, err = w.client.AppsV1().Deployments(ns).Update(ctx, deployment, metav1.UpdateOptions{})
+			return err
+		case "statefulsets":
+			sts, err := w.client.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if changed, err := mutate(&sts.Spec.Template.Spec); err != nil || !changed {
+				return err
+			}
+			_, err = w.client.AppsV1().StatefulSets(ns).Update(ctx, sts, metav1.UpdateOptions{})
+			return err
+		default:
+			ds, err := w.client.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if changed, err := mutate(&ds.Spec.Template.Spec); err != nil || !changed {
+				return err
+			}
+			_, err = w.client.AppsV1().DaemonSets(ns).Update(ctx, ds, metav1.UpdateOptions{})
+			return err
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", resource, name, err)
+	}
+	return nil
+}
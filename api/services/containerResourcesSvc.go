@@ -0,0 +1,398 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kgent-api/pkg/retry"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientretry "k8s.io/client-go/util/retry"
+)
+
+// ErrContainerNotFound is returned for containers the pod template doesn't have
+var ErrContainerNotFound = errors.New("container not found")
+
+// ContainerResourcesRequest is the body accepted when setting a container's resources.
+// Quantities are keyed by resource name, an empty quantity removes the resource.
+type ContainerResourcesRequest struct {
+	Container string            `json:"container"`
+	Requests  map[string]string `json:"requests"`
+	Limits    map[string]string `json:"limits"`
+}
+
+// ContainerResourcesChange is the change made to a container's resources, or the change
+// that would be made for dry runs
+type ContainerResourcesChange struct {
+	Kind      string                      `json:"kind"`
+	Namespace string                      `json:"namespace"`
+	Name      string                      `json:"name"`
+	Container string                      `json:"container"`
+	DryRun    bool                        `json:"dryRun"`
+	Old       corev1.ResourceRequirements `json:"old"`
+	New       corev1.ResourceRequirements `json:"new"`
+	// LimitRangeViolations are only returned for dry runs, others fail with them
+	LimitRangeViolations []LimitRangeViolation `json:"limitRangeViolations"`
+}
+
+// LimitRangeViolation is a container constraint of a LimitRange the new resources break,
+// which would keep the rollout's pods from being admitted
+type LimitRangeViolation struct {
+	LimitRange string `json:"limitRange"`
+	Resource   string `json:"resource"`
+	// Constraint is min, max or maxLimitRequestRatio
+	Constraint string `json:"constraint"`
+	Bound      string `json:"bound"`
+	Value      string `json:"value"`
+	Message    string `json:"message"`
+}
+
+// LimitRangeError is returned when the new resources break the LimitRanges of the namespace
+type LimitRangeError struct {
+	Violations []LimitRangeViolation
+}
+
+func (e *LimitRangeError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return fmt.Sprintf("resources rejected by limit ranges: %s", strings.Join(messages, "; "))
+}
+
+// LimitBelowRequestError is returned when a limit would be below the request of its resource
+type LimitBelowRequestError struct {
+	Resource string
+	Request  string
+	Limit    string
+}
+
+func (e *LimitBelowRequestError) Error() string {
+	return fmt.Sprintf("%s limit %s is below the request %s", e.Resource, e.Limit, e.Request)
+}
+
+// SetContainerResources sets the requests and limits of a container in the pod template of
+// a Deployment, StatefulSet or DaemonSet. The resources listed are replaced, the others
+// kept. With dryRun the patch only goes through the API server's validation and admission.
+// The workload is checked at the version it is patched at, starting over from the get when
+// another write got in between.
+func (w *WorkloadService) SetContainerResources(ctx context.Context, kind, ns, name string, req ContainerResourcesRequest, dryRun bool) (*ContainerResourcesChange, error) {
+	resource := strings.ToLower(kind)
+	switch resource {
+	case "deployments", "statefulsets", "daemonsets":
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported workload kind %q, expected one of deployments, statefulsets, daemonsets", kind))
+	}
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: resource + " name"}
+	}
+	if req.Container == "" {
+		return nil, &EmptyArgumentError{Argument: "container"}
+	}
+	if len(req.Requests) == 0 && len(req.Limits) == 0 {
+		return nil, apierrors.NewBadRequest("requests or limits is required")
+	}
+	requests, err := parseQuantities("request", req.Requests)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseQuantities("limit", req.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := metav1.PatchOptions{}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	var change *ContainerResourcesChange
+	err = clientretry.RetryOnConflict(clientretry.DefaultRetry, func() error {
+		spec, resourceVersion, err := w.getPodSpec(ctx, resource, ns, name)
+		if err != nil {
+			return err
+		}
+		container := findContainer(spec, req.Container)
+		if container == nil {
+			return fmt.Errorf("%s %s has no container %q: %w", resource, name, req.Container, ErrContainerNotFound)
+		}
+
+		change = &ContainerResourcesChange{
+			Kind:                 resource,
+			Namespace:            ns,
+			Name:                 name,
+			Container:            req.Container,
+			DryRun:               dryRun,
+			Old:                  container.Resources,
+			LimitRangeViolations: []LimitRangeViolation{},
+		}
+		wanted := *container.Resources.DeepCopy()
+		wanted.Requests = applyQuantities(wanted.Requests, requests)
+		wanted.Limits = applyQuantities(wanted.Limits, limits)
+		if err := checkLimitsAboveRequests(wanted); err != nil {
+			return err
+		}
+
+		violations, err := w.limitRangeViolations(ctx, ns, wanted)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 && !dryRun {
+			return &LimitRangeError{Violations: violations}
+		}
+		change.LimitRangeViolations = append(change.LimitRangeViolations, violations...)
+
+		// The patch only applies to the version checked above, so no write can bring a
+		// limit below its request in between
+		patch, err := resourcesPatch(req.Container, resourceVersion, requests, limits)
+		if err != nil {
+			return err
+		}
+		patched, err := w.patchPodSpec(ctx, resource, ns, name, patch, opts)
+		if err != nil {
+			return fmt.Errorf("failed to patch %s %s: %w", resource, name, err)
+		}
+		if container := findContainer(patched, req.Container); container != nil {
+			change.New = container.Resources
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// parseQuantities parses the quantities of what, keeping nil for the resources to remove
+func parseQuantities(what string, quantities map[string]string) (map[corev1.ResourceName]*resource.Quantity, error) {
+	parsed := make(map[corev1.ResourceName]*resource.Quantity, len(quantities))
+	for name, value := range quantities {
+		if value == "" {
+			parsed[corev1.ResourceName(name)] = nil
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid %s %s %q: %v", name, what, value, err))
+		}
+		if quantity.Sign() < 0 {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid %s %s %q: must not be negative", name, what, value))
+		}
+		parsed[corev1.ResourceName(name)] = &quantity
+	}
+	return parsed, nil
+}
+
+func applyQuantities(list corev1.ResourceList, quantities map[corev1.ResourceName]*resource.Quantity) corev1.ResourceList {
+	if list == nil && len(quantities) > 0 {
+		list = corev1.ResourceList{}
+	}
+	for name, quantity := range quantities {
+		if quantity == nil {
+			delete(list, name)
+		} else {
+			list[name] = *quantity
+		}
+	}
+	return list
+}
+
+func checkLimitsAboveRequests(resources corev1.ResourceRequirements) error {
+	names := make([]string, 0, len(resources.Limits))
+	for name := range resources.Limits {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := resources.Limits[corev1.ResourceName(name)]
+		request, ok := resources.Requests[corev1.ResourceName(name)]
+		if ok && limit.Cmp(request) < 0 {
+			return &LimitBelowRequestError{Resource: name, Request: request.String(), Limit: limit.String()}
+		}
+	}
+	return nil
+}
+
+// limitRangeViolations checks resources against the container constraints of the
+// namespace's LimitRanges like the LimitRanger admission plugin does, a missing request
+// defaulting to the limit. Pod-wide constraints and defaulted values aren't checked.
+func (w *WorkloadService) limitRangeViolations(ctx context.Context, ns string, resources corev1.ResourceRequirements) ([]LimitRangeViolation, error) {
+	var list *corev1.LimitRangeList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		list, err = w.client.CoreV1().LimitRanges(ns).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit ranges: %w", err)
+	}
+
+	requests := resources.Requests.DeepCopy()
+	if requests == nil {
+		requests = corev1.ResourceList{}
+	}
+	for name, limit := range resources.Limits {
+		if _, ok := requests[name]; !ok {
+			requests[name] = limit
+		}
+	}
+
+	var violations []LimitRangeViolation
+	for _, limitRange := range list.Items {
+		violated := func(name corev1.ResourceName, constraint string, bound resource.Quantity, value string, message string) {
+			violations = append(violations, LimitRangeViolation{
+				LimitRange: limitRange.Name,
+				Resource:   string(name),
+				Constraint: constraint,
+				Bound:      bound.String(),
+				Value:      value,
+				Message:    fmt.Sprintf("%s %s, limit range %s", name, message, limitRange.Name),
+			})
+		}
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for name, minimum := range item.Min {
+				if request, ok := requests[name]; ok && request.Cmp(minimum) < 0 {
+					violated(name, "min", minimum, request.String(), fmt.Sprintf("request %s is below the minimum %s", request.String(), minimum.String()))
+				}
+				if limit, ok := resources.Limits[name]; ok && limit.Cmp(minimum) < 0 {
+					violated(name, "min", minimum, limit.String(), fmt.Sprintf("limit %s is below the minimum %s", limit.String(), minimum.String()))
+				}
+			}
+			for name, maximum := range item.Max {
+				if request, ok := requests[name]; ok && request.Cmp(maximum) > 0 {
+					violated(name, "max", maximum, request.String(), fmt.Sprintf("request %s is above the maximum %s", request.String(), maximum.String()))
+				}
+				if limit, ok := resources.Limits[name]; ok && limit.Cmp(maximum) > 0 {
+					violated(name, "max", maximum, limit.String(), fmt.Sprintf("limit %s is above the maximum %s", limit.String(), maximum.String()))
+				}
+			}
+			for name, ratio := range item.MaxLimitRequestRatio {
+				limit, hasLimit := resources.Limits[name]
+				request, hasRequest := requests[name]
+				if !hasLimit || !hasRequest || request.IsZero() {
+					continue
+				}
+				if actual := limit.AsApproximateFloat64() / request.AsApproximateFloat64(); actual > ratio.AsApproximateFloat64() {
+					violated(name, "maxLimitRequestRatio", ratio, fmt.Sprintf("%.2f", actual),
+						fmt.Sprintf("limit to request ratio %.2f is above the maximum %s", actual, ratio.String()))
+				}
+			}
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Resource < violations[j].Resource })
+	return violations, nil
+}
+
+// resourcesPatch builds the strategic merge patch of the container's resources, where the
+// container is merged by name and removed resources are set to null. The patch conflicts
+// unless the workload is still at resourceVersion.
+func resourcesPatch(container, resourceVersion string, requests, limits map[corev1.ResourceName]*resource.Quantity) ([]byte, error) {
+	values := func(quantities map[corev1.ResourceName]*resource.Quantity) map[string]interface{} {
+		result := make(map[string]interface{}, len(quantities))
+		for name, quantity := range quantities {
+			if quantity == nil {
+				result[string(name)] = nil
+			} else {
+				result[string(name)] = quantity.String()
+			}
+		}
+		return result
+	}
+
+	resources := map[string]interface{}{}
+	if len(requests) > 0 {
+		resources["requests"] = values(requests)
+	}
+	if len(limits) > 0 {
+		resources["limits"] = values(limits)
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": resourceVersion},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": container, "resources": resources},
+					},
+				},
+			},
+		},
+	})
+}
+
+func findContainer(spec *corev1.PodSpec, name string) *corev1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == name {
+			return &spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+// getPodSpec gets the pod template spec and resource version of a Deployment, StatefulSet or
+// DaemonSet
+func (w *WorkloadService) getPodSpec(ctx context.Context, resource, ns, name string) (*corev1.PodSpec, string, error) {
+	var spec *corev1.PodSpec
+	var resourceVersion string
+	err := retry.Do(ctx, "get", func(int) error {
+		switch resource {
+		case "deployments":
+			deployment, err := w.client.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			spec, resourceVersion = &deployment.Spec.Template.Spec, deployment.ResourceVersion
+		case "statefulsets":
+			sts, err := w.client.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			spec, resourceVersion = &sts.Spec.Template.Spec, sts.ResourceVersion
+		case "daemonsets":
+			ds, err := w.client.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			spec, resourceVersion = &ds.Spec.Template.Spec, ds.ResourceVersion
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get %s %s: %w", resource, name, err)
+	}
+	return spec, resourceVersion, nil
+}
+
+// patchPodSpec applies a strategic merge patch to a Deployment, StatefulSet or DaemonSet and
+// returns its patched pod template spec
+func (w *WorkloadService) patchPodSpec(ctx context.Context, resource, ns, name string, patch []byte, opts metav1.PatchOptions) (*corev1.PodSpec, error) {
+	switch resource {
+	case "deployments":
+		deployment, err := w.client.AppsV1().Deployments(ns).Patch(ctx, name, types.StrategicMergePatchType, patch, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &deployment.Spec.Template.Spec, nil
+	case "statefulsets":
+		sts, err := w.client.AppsV1().StatefulSets(ns).Patch(ctx, name, types.StrategicMergePatchType, patch, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &sts.Spec.Template.Spec, nil
+	default:
+		ds, err := w.client.AppsV1().DaemonSets(ns).Patch(ctx, name, types.StrategicMergePatchType, patch, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ds.Spec.Template.Spec, nil
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kgent-api/pkg/retry"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// PrinterColumn is a column of a custom resource listing, from the CRD's
+// additionalPrinterColumns or the Name and Age defaults
+type PrinterColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	JSONPath    string `json:"jsonPath"`
+	Description string `json:"description,omitempty"`
+	// Priority above 0 marks columns kubectl only shows with -o wide
+	Priority int64 `json:"priority,omitempty"`
+}
+
+// CRDInstances lists the instances of a custom resource as a table
+type CRDInstances struct {
+	CRD     string                      `json:"crd"`
+	GVR     schema.GroupVersionResource `json:"gvr"`
+	Kind    string                      `json:"kind"`
+	Scope   string                      `json:"scope"`
+	Columns []PrinterColumn             `json:"columns"`
+	Rows    []CRDInstanceRow            `json:"rows"`
+}
+
+// CRDInstanceRow is an instance with a cell per column, in column order
+type CRDInstanceRow struct {
+	Namespace string        `json:"namespace,omitempty"`
+	Name      string        `json:"name"`
+	Cells     []interface{} `json:"cells"`
+	// Errors holds the columns whose JSONPath failed on this instance, by column name
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// defaultPrinterColumns are shown for CRDs that declare none, as kubectl does
+var defaultPrinterColumns = []PrinterColumn{
+	{Name: "Name", Type: "string", JSONPath: ".metadata.name"},
+	{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+}
+
+// ListCRDInstances lists the instances of the CRD named crdName, at its storage version when
+// served, in ns unless the CRD is cluster-scoped. Rows come from a dynamic informer started
+// on first use, and have a cell for each of the version's additionalPrinterColumns.
+func (r *ResourceService) ListCRDInstances(ctx context.Context, crdName string, ns string) (*CRDInstances, error) {
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var crd *unstructured.Unstructured
+	err = retry.Do(ctx, "get", func(int) (err error) {
+		crd, err = client.Resource(crdResource).Get(ctx, crdName, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom resource definition %s: %w", crdName, err)
+	}
+
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+	version, columns, ok := servedVersion(crd)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("custom resource definition %s serves no version", crdName))
+	}
+
+	result := &CRDInstances{
+		CRD:     crdName,
+		GVR:     schema.GroupVersionResource{Group: group, Version: version, Resource: plural},
+		Kind:    kind,
+		Scope:   scope,
+		Columns: columns,
+		Rows:    []CRDInstanceRow{},
+	}
+	mapping := &meta.RESTMapping{Resource: result.GVR, Scope: meta.RESTScopeRoot}
+	if scope == apiextensionsNamespaced {
+		mapping.Scope = meta.RESTScopeNamespace
+	} else {
+		ns = ""
+	}
+	if err := r.checkScope(mapping, ns); err != nil {
+		return nil, err
+	}
+
+	objects, err := r.crdObjects(ctx, mapping, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]*jsonpath.JSONPath, len(columns))
+	parseErrors := make([]error, len(columns))
+	for i, column := range columns {
+		parsed[i] = jsonpath.New(column.Name).AllowMissingKeys(true)
+		parseErrors[i] = parsed[i].Parse(relaxedJSONPath(column.JSONPath))
+	}
+	for _, obj := range r.inScope(objects) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		row := CRDInstanceRow{Namespace: u.GetNamespace(), Name: u.GetName(), Cells: make([]interface{}, len(columns))}
+		for i, column := range columns {
+			value, err := cellValue(parsed[i], parseErrors[i], u.Object)
+			if err != nil {
+				if row.Errors == nil {
+					row.Errors = map[string]string{}
+				}
+				row.Errors[column.Name] = err.Error()
+				continue
+			}
+			row.Cells[i] = value
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	sort.Slice(result.Rows, func(i, j int) bool {
+		if result.Rows[i].Namespace != result.Rows[j].Namespace {
+			return result.Rows[i].Namespace < result.Rows[j].Namespace
+		}
+		return result.Rows[i].Name < result.Rows[j].Name
+	})
+	return result, nil
+}
+
+// apiextensionsNamespaced is the scope of namespaced CRDs
+const apiextensionsNamespaced = "Namespaced"
+
+// crdObjects lists the instances from a dynamic informer, started on first use, or as the
+// caller for identified requests, which the informer's view doesn't apply to
+func (r *ResourceService) crdObjects(ctx context.Context, mapping *meta.RESTMapping, ns string) ([]runtime.Object, error) {
+	if r.identified(ctx) || r.dynamicInformers == nil {
+		return r.listAsCaller(ctx, mapping, ns)
+	}
+	// An informer for every namespace would cache objects outside the scope
+	if ns == "" && mapping.Scope.Name() == meta.RESTScopeNameNamespace && r.scope.Restricted() {
+		return nil, apierrors.NewForbidden(mapping.Resource.GroupResource(), "", fmt.Errorf("instances must be listed in one of %s", r.scope))
+	}
+
+	lister, err := r.dynamicInformers.Ensure(ctx, mapping.Resource, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %w", mapping.Resource.Resource, err)
+	}
+	if ns == "" {
+		return lister.List(labels.Everything())
+	}
+	return lister.ByNamespace(ns).List(labels.Everything())
+}
+
+// servedVersion picks the storage version if it is served, or else the first served
+// version, with its printer columns
+func servedVersion(crd *unstructured.Unstructured) (string, []PrinterColumn, bool) {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var chosen map[string]interface{}
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, _, _ := unstructured.NestedBool(version, "served"); !served {
+			continue
+		}
+		if storage, _, _ := unstructured.NestedBool(version, "storage"); storage || chosen == nil {
+			chosen = version
+		}
+	}
+	if chosen == nil {
+		return "", nil, false
+	}
+
+	name, _, _ := unstructured.NestedString(chosen, "name")
+	declared, _, _ := unstructured.NestedSlice(chosen, "additionalPrinterColumns")
+	if len(declared) == 0 {
+		return name, defaultPrinterColumns, true
+	}
+	// Name always comes first, as in kubectl
+	columns := []PrinterColumn{defaultPrinterColumns[0]}
+	for _, c := range declared {
+		column, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pc := PrinterColumn{}
+		pc.Name, _, _ = unstructured.NestedString(column, "name")
+		pc.Type, _, _ = unstructured.NestedString(column, "type")
+		pc.JSONPath, _, _ = unstructured.NestedString(column, "jsonPath")
+		pc.Description, _, _ = unstructured.NestedString(column, "description")
+		pc.Priority, _, _ = unstructured.NestedInt64(column, "priority")
+		columns = append(columns, pc)
+	}
+	return name, columns, true
+}
+
+// relaxedJSONPath wraps a CRD column path such as .status.phase in braces for the parser
+func relaxedJSONPath(path string) string {
+	if strings.HasPrefix(path, "{") {
+		return path
+	}
+	return "{" + path + "}"
+}
+
+// cellValue evaluates a column on an object. Missing fields give nil, several results are
+// joined with commas.
+func cellValue(path *jsonpath.JSONPath, parseErr error, obj map[string]interface{}) (interface{}, error) {
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid JSONPath: %w", parseErr)
+	}
+	results, err := path.FindResults(obj)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, value := range result {
+			if value.IsValid() && value.CanInterface() {
+				values = append(values, value.Interface())
+			}
+		}
+	}
+	switch len(values) {
+	case 0:
+		return nil, nil
+	case 1:
+		return values[0], nil
+	}
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ","), nil
+}
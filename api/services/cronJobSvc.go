@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/cronschedule"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// CronJobSummary is a CronJob's schedule, when it fires next and how its last Job went
+type CronJobSummary struct {
+	Name               string       `json:"name"`
+	Namespace          string       `json:"namespace"`
+	Schedule           string       `json:"schedule"`
+	TimeZone           string       `json:"timeZone,omitempty"`
+	Suspend            bool         `json:"suspend"`
+	LastScheduleTime   *metav1.Time `json:"lastScheduleTime,omitempty"`
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	Active             int          `json:"active"`
+	// NextRuns are the coming fire times, even while suspended. ScheduleError is set instead
+	// when the schedule or time zone can't be parsed.
+	NextRuns      []time.Time `json:"nextRuns"`
+	ScheduleError string      `json:"scheduleError,omitempty"`
+	// LastJob is the most recently created Job the CronJob owns
+	LastJob *JobSummary `json:"lastJob,omitempty"`
+}
+
+// ListCronJobs returns the CronJobs in the namespace from the informer cache with the next
+// runs times each fires and the Job it last created
+func (j *JobService) ListCronJobs(ns string, runs int) ([]CronJobSummary, error) {
+	cronJobs, err := j.fact.Batch().V1().CronJobs().Lister().CronJobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	jobs, err := j.fact.Batch().V1().Jobs().Lister().Jobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	lastJobs := map[string]*batchv1.Job{}
+	for _, job := range jobs {
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "CronJob" {
+			continue
+		}
+		key := job.Namespace + "/" + string(owner.UID)
+		if last, ok := lastJobs[key]; !ok || job.CreationTimestamp.After(last.CreationTimestamp.Time) {
+			lastJobs[key] = job
+		}
+	}
+
+	now := time.Now()
+	summaries := make([]CronJobSummary, 0, len(cronJobs))
+	for _, cj := range cronJobs {
+		summary := CronJobSummary{
+			Name:               cj.Name,
+			Namespace:          cj.Namespace,
+			Schedule:           cj.Spec.Schedule,
+			Suspend:            cj.Spec.Suspend != nil && *cj.Spec.Suspend,
+			LastScheduleTime:   cj.Status.LastScheduleTime,
+			LastSuccessfulTime: cj.Status.LastSuccessfulTime,
+			Active:             len(cj.Status.Active),
+			NextRuns:           []time.Time{},
+		}
+		if cj.Spec.TimeZone != nil {
+			summary.TimeZone = *cj.Spec.TimeZone
+		}
+		if next, err := cronschedule.Next(summary.Schedule, summary.TimeZone, now, runs); err != nil {
+			summary.ScheduleError = err.Error()
+		} else {
+			summary.NextRuns = next
+		}
+		if job, ok := lastJobs[cj.Namespace+"/"+string(cj.UID)]; ok {
+			last := summarizeJob(job)
+			summary.LastJob = &last
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, k int) bool {
+		if summaries[i].Namespace != summaries[k].Namespace {
+			return summaries[i].Namespace < summaries[k].Namespace
+		}
+		return summaries[i].Name < summaries[k].Name
+	})
+	return summaries, nil
+}
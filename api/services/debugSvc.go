@@ -0,0 +1,29 @@
+package services
+
+import (
+	"fmt"
+
+	"kgent-api/pkg/cachestats"
+)
+
+type DebugService struct {
+	registry *cachestats.Registry
+}
+
+func NewDebugService(registry *cachestats.Registry) *DebugService {
+	return &DebugService{registry: registry}
+}
+
+// Informers reports the sync state and size of every registered informer cache
+func (d *DebugService) Informers() []cachestats.Stats {
+	return d.registry.Stats()
+}
+
+// InformerKeys returns the keys cached by the informer for resource, limited to ns when set
+func (d *DebugService) InformerKeys(resource, ns string) ([]string, error) {
+	keys, ok := d.registry.Keys(resource, ns)
+	if !ok {
+		return nil, fmt.Errorf("no informer is registered for %s", resource)
+	}
+	return keys, nil
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"kgent-api/pkg/retry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Outcomes of a dependent in a DeletePlan
+const (
+	PlanOutcomeDeleted    = "deleted"
+	PlanOutcomeOrphaned   = "orphaned"
+	PlanOutcomeKept       = "kept"
+	PlanOutcomeUnaffected = "unaffected"
+)
+
+// dependentResources are the shared informer resources searched for dependents
+var dependentResources = []schema.GroupVersionResource{
+	corev1.SchemeGroupVersion.WithResource("pods"),
+	corev1.SchemeGroupVersion.WithResource("services"),
+	corev1.SchemeGroupVersion.WithResource("configmaps"),
+	corev1.SchemeGroupVersion.WithResource("secrets"),
+	corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"),
+	appsv1.SchemeGroupVersion.WithResource("deployments"),
+	appsv1.SchemeGroupVersion.WithResource("replicasets"),
+	appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	batchv1.SchemeGroupVersion.WithResource("jobs"),
+	batchv1.SchemeGroupVersion.WithResource("cronjobs"),
+	policyv1.SchemeGroupVersion.WithResource("poddisruptionbudgets"),
+}
+
+// propagationPolicies are the policies a DeletePlan is worked out for
+var propagationPolicies = []metav1.DeletionPropagation{
+	metav1.DeletePropagationBackground,
+	metav1.DeletePropagationForeground,
+	metav1.DeletePropagationOrphan,
+}
+
+// DeletePlan is what deleting an object would take with it under each propagation policy
+type DeletePlan struct {
+	// PlanID is the root's resourceVersion. Deleting with it as planId fails with 412 when the
+	// root has changed since the plan was made.
+	PlanID     string        `json:"planId"`
+	Root       PlanObject    `json:"root"`
+	Dependents []PlanObject  `json:"dependents"`
+	Counts     []PolicyCount `json:"counts"`
+	Notes      []string      `json:"notes,omitempty"`
+}
+
+// PlanObject is the root or a dependent of a DeletePlan
+type PlanObject struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+	// Owner is the owner the dependent was reached through, as kind/name
+	Owner string `json:"owner,omitempty"`
+	Depth int    `json:"depth,omitempty"`
+	// Outcomes says whether the dependent is deleted, orphaned, kept because it has other
+	// owners, or unaffected under each propagation policy
+	Outcomes map[metav1.DeletionPropagation]string `json:"outcomes,omitempty"`
+}
+
+// PolicyCount estimates the objects of each kind a propagation policy deletes and orphans,
+// the root included
+type PolicyCount struct {
+	Policy   metav1.DeletionPropagation `json:"policy"`
+	Deleted  map[string]int             `json:"deleted"`
+	Orphaned map[string]int             `json:"orphaned"`
+}
+
+// dependent is a cached object owning or owned by others
+type dependent struct {
+	object PlanObject
+	owners []metav1.OwnerReference
+}
+
+// DeletePlan walks the ownerReferences pointing at an object, and at its dependents in turn,
+// through the informer caches. The root is read live; dependents of kinds without a synced
+// informer are not found, so counts are estimates.
+func (r *ResourceService) DeletePlan(ctx context.Context, resourceOrKindArg string, ns string, name string) (*DeletePlan, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "resource name"}
+	}
+
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
+	if err != nil {
+		return nil, err
+	}
+	var root *unstructured.Unstructured
+	err = retry.Do(ctx, "get", func(int) (err error) {
+		root, err = ri.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", resourceOrKindArg, name, err)
+	}
+
+	// Namespaced dependents live in their owner's namespace, cluster-scoped owners can have them anywhere
+	objects, known, notes := r.cachedDependents(root.GetNamespace())
+	known.Insert(root.GetUID())
+	children := map[types.UID][]*dependent{}
+	for _, d := range objects {
+		for _, ref := range d.owners {
+			children[ref.UID] = append(children[ref.UID], d)
+		}
+	}
+
+	plan := &DeletePlan{
+		PlanID:     root.GetResourceVersion(),
+		Root:       PlanObject{APIVersion: root.GetAPIVersion(), Kind: root.GetKind(), Namespace: root.GetNamespace(), Name: root.GetName(), UID: root.GetUID()},
+		Dependents: []PlanObject{},
+		Notes:      notes,
+	}
+
+	// Breadth first, so dependents are ordered by depth and reported under the closest path from the root
+	var found []*dependent
+	visited := sets.New[types.UID](root.GetUID())
+	queue := []PlanObject{plan.Root}
+	for len(queue) > 0 {
+		owner := queue[0]
+		queue = queue[1:]
+		for _, child := range children[owner.UID] {
+			if visited.Has(child.object.UID) {
+				continue
+			}
+			visited.Insert(child.object.UID)
+			child.object.Owner = owner.Kind + "/" + owner.Name
+			child.object.Depth = owner.Depth + 1
+			found = append(found, child)
+			queue = append(queue, child.object)
+		}
+	}
+
+	// The garbage collector deletes a dependent once none of its owners remain. Owners that
+	// aren't cached are assumed to remain, owners already gone from the cluster are not.
+	deleted := sets.New[types.UID](root.GetUID())
+	for changed := true; changed; {
+		changed = false
+		for _, d := range found {
+			if deleted.Has(d.object.UID) {
+				continue
+			}
+			remaining := false
+			for _, ref := range d.owners {
+				if !deleted.Has(ref.UID) && (known.Has(ref.UID) || !r.cachedKind(ref)) {
+					remaining = true
+					break
+				}
+			}
+			if !remaining {
+				deleted.Insert(d.object.UID)
+				changed = true
+			}
+		}
+	}
+
+	counts := map[metav1.DeletionPropagation]*PolicyCount{}
+	for _, policy := range propagationPolicies {
+		counts[policy] = &PolicyCount{Policy: policy, Deleted: map[string]int{plan.Root.Kind: 1}, Orphaned: map[string]int{}}
+	}
+	for _, d := range found {
+		cascade := PlanOutcomeKept
+		if deleted.Has(d.object.UID) {
+			cascade = PlanOutcomeDeleted
+			counts[metav1.DeletePropagationBackground].Deleted[d.object.Kind]++
+			counts[metav1.DeletePropagationForeground].Deleted[d.object.Kind]++
+		}
+		orphan := PlanOutcomeUnaffected
+		if d.object.Depth == 1 {
+			orphan = PlanOutcomeOrphaned
+			counts[metav1.DeletePropagationOrphan].Orphaned[d.object.Kind]++
+		}
+		d.object.Outcomes = map[metav1.DeletionPropagation]string{
+			metav1.DeletePropagationBackground: cascade,
+			metav1.DeletePropagationForeground: cascade,
+			metav1.DeletePropagationOrphan:     orphan,
+		}
+		plan.Dependents = append(plan.Dependents, d.object)
+	}
+	for _, policy := range propagationPolicies {
+		plan.Counts = append(plan.Counts, *counts[policy])
+	}
+	return plan, nil
+}
+
+// cachedDependents lists the objects with ownerReferences in ns, or every namespace when ns is
+// empty, from the shared and runtime informers. It also returns the UIDs of every object
+// searched and notes on what could not be searched.
+func (r *ResourceService) cachedDependents(ns string) ([]*dependent, sets.Set[types.UID], []string) {
+	var objects []*dependent
+	var notes []string
+	known := sets.New[types.UID]()
+	add := func(gvk schema.GroupVersionKind, items []runtime.Object) {
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			known.Insert(accessor.GetUID())
+			if len(accessor.GetOwnerReferences()) == 0 {
+				continue
+			}
+			objects = append(objects, &dependent{
+				object: PlanObject{
+					APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind,
+					Namespace: accessor.GetNamespace(), Name: accessor.GetName(), UID: accessor.GetUID(),
+				},
+				owners: accessor.GetOwnerReferences(),
+			})
+		}
+	}
+
+	searched := sets.New[schema.GroupVersionResource]()
+	if r.dynamicInformers != nil {
+		for _, info := range r.dynamicInformers.List() {
+			gvr := schema.GroupVersionResource{Group: info.Group, Version: info.Version, Resource: info.Resource}
+			if searched.Has(gvr) || (ns != "" && info.Namespace != "" && info.Namespace != ns) {
+				continue
+			}
+			lister, ok := r.dynamicInformers.Lister(gvr, ns)
+			if !ok {
+				continue
+			}
+			items, err := listIn(lister, ns)
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("failed to search %s: %v", gvr.Resource, err))
+				continue
+			}
+			if len(items) > 0 {
+				add(items[0].GetObjectKind().GroupVersionKind(), items)
+			}
+			searched.Insert(gvr)
+		}
+	}
+
+	for _, gvr := range dependentResources {
+		if searched.Has(gvr) {
+			continue
+		}
+		informer, err := r.fact.ForResource(gvr)
+		if err != nil || !informer.Informer().HasSynced() {
+			continue
+		}
+		gvk, err := (*r.restMapper).KindFor(gvr)
+		if err != nil {
+			continue
+		}
+		items, err := listIn(informer.Lister(), ns)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("failed to search %s: %v", gvr.Resource, err))
+			continue
+		}
+		add(gvk, items)
+		searched.Insert(gvr)
+	}
+
+	notes = append(notes, "dependents are searched in informer caches only, start informers for other kinds through /api/v1/admin/informers to include them")
+	return objects, known, notes
+}
+
+// cachedKind reports whether objects of the ref's kind are searched, so a missing owner of
+// that kind is known to be gone
+func (r *ResourceService) cachedKind(ref metav1.OwnerReference) bool {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false
+	}
+	mapping, err := (*r.restMapper).RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if err != nil {
+		return false
+	}
+	for _, gvr := range dependentResources {
+		if gvr == mapping.Resource {
+			return true
+		}
+	}
+	if r.dynamicInformers != nil {
+		_, ok := r.dynamicInformers.Lister(mapping.Resource, "")
+		return ok
+	}
+	return false
+}
+
+func listIn(lister cache.GenericLister, ns string) ([]runtime.Object, error) {
+	if ns == "" {
+		return lister.List(labels.Everything())
+	}
+	return lister.ByNamespace(ns).List(labels.Everything())
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/describe"
+	"kgent-api/pkg/retry"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// DescribeResource builds a kubectl-describe style description of the object, including its events
+func (r *ResourceService) DescribeResource(ctx context.Context, resourceOrKindArg string, ns string, name string) (*describe.Description, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "resource name"}
+	}
+
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj *unstructured.Unstructured
+	err = retry.Do(ctx, "get", func(int) (err error) {
+		obj, err = ri.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", resourceOrKindArg, name, err)
+	}
+
+	description, err := describe.Describe(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := r.objectEvents(ctx, obj.GetNamespace(), string(obj.GetUID()))
+	if err != nil {
+		return nil, err
+	}
+	description.Sections = append(description.Sections, events)
+	return description, nil
+}
+
+// objectEvents renders the events recorded for an object as a describe section, oldest first
+func (r *ResourceService) objectEvents(ctx context.Context, ns string, uid string) (describe.Section, error) {
+	section := describe.Section{Title: "Events", Columns: []string{"Type", "Reason", "Age", "From", "Message"}}
+
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return section, err
+	}
+
+	var list *unstructured.UnstructuredList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		list, err = client.Resource(eventsGVR).Namespace(ns).List(ctx, metav1.ListOptions{
+			FieldSelector: "involvedObject.uid=" + uid,
+		})
+		return err
+	})
+	if err != nil {
+		return section, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	events := make([]v1.Event, 0, len(list.Items))
+	for _, item := range list.Items {
+		event := v1.Event{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+
+	for _, event := range events {
+		section.Rows = append(section.Rows, []string{
+			event.Type,
+			event.Reason,
+			age(metav1.NewTime(eventTime(event))),
+			event.Source.Component,
+			event.Message,
+		})
+	}
+	return section, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/restarts"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ReasonCrashLoopBackOff = "CrashLoopBackOff"
+	ReasonImagePullBackOff = "ImagePullBackOff"
+	ReasonErrImagePull     = "ErrImagePull"
+	ReasonOOMKilled        = "OOMKilled"
+	ReasonStuckPending     = "StuckPending"
+)
+
+type DiagnosticsService struct {
+	client   kubernetes.Interface
+	fact     informers.SharedInformerFactory
+	restarts *restarts.Tracker
+}
+
+func NewDiagnosticsService(client kubernetes.Interface, fact informers.SharedInformerFactory, tracker *restarts.Tracker) *DiagnosticsService {
+	return &DiagnosticsService{client: client, fact: fact, restarts: tracker}
+}
+
+// OwnerRef identifies the workload that ultimately owns a pod
+type OwnerRef struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// UnhealthyPod describes a pod that is failing and why
+type UnhealthyPod struct {
+	Namespace    string    `json:"namespace"`
+	Pod          string    `json:"pod"`
+	Reason       string    `json:"reason"`
+	Container    string    `json:"container,omitempty"`
+	RestartCount int32     `json:"restartCount"`
+	Owner        *OwnerRef `json:"owner,omitempty"`
+	LastEvent    string    `json:"lastEvent,omitempty"`
+}
+
+// UnhealthyPods scans the pod cache for crash loops, image pull failures, OOM kills and
+// pods stuck in Pending longer than pendingThreshold. Warning events are only fetched for
+// the pods that are reported.
+func (d *DiagnosticsService) UnhealthyPods(ctx context.Context, ns string, pendingThreshold time.Duration) ([]UnhealthyPod, error) {
+	pods, err := d.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	result := []UnhealthyPod{}
+	for _, pod := range pods {
+		unhealthy, ok := diagnosePod(pod, pendingThreshold)
+		if !ok {
+			continue
+		}
+		unhealthy.Owner = d.rootOwner(pod)
+		unhealthy.LastEvent = d.lastWarningEvent(ctx, pod)
+		result = append(result, unhealthy)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Pod < result[j].Pod
+	})
+	return result, nil
+}
+
+// diagnosePod returns the first failure found on the pod's containers
+func diagnosePod(pod *v1.Pod, pendingThreshold time.Duration) (UnhealthyPod, bool) {
+	unhealthy := UnhealthyPod{Namespace: pod.Namespace, Pod: pod.Name}
+
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		unhealthy.Container = status.Name
+		unhealthy.RestartCount = status.RestartCount
+
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case ReasonCrashLoopBackOff, ReasonImagePullBackOff, ReasonErrImagePull:
+				unhealthy.Reason = waiting.Reason
+				return unhealthy, true
+			}
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.Reason == ReasonOOMKilled {
+			unhealthy.Reason = ReasonOOMKilled
+			return unhealthy, true
+		}
+	}
+
+	if pod.Status.Phase == v1.PodPending && time.Since(pod.CreationTimestamp.Time) > pendingThreshold {
+		return UnhealthyPod{Namespace: pod.Namespace, Pod: pod.Name, Reason: ReasonStuckPending}, true
+	}
+	return UnhealthyPod{}, false
+}
+
+// rootOwner walks controller ownerReferences through ReplicaSets and Jobs using the caches
+func (d *DiagnosticsService) rootOwner(pod *v1.Pod) *OwnerRef {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return nil
+	}
+	owner := &OwnerRef{Kind: ref.Kind, Name: ref.Name}
+
+	switch ref.Kind {
+	case "ReplicaSet":
+		rs, err := d.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(pod.Namespace).Get(ref.Name)
+		if err != nil {
+			return owner
+		}
+		if parent := metav1.GetControllerOf(rs); parent != nil {
+			return &OwnerRef{Kind: parent.Kind, Name: parent.Name}
+		}
+	case "Job":
+		job, err := d.fact.Batch().V1().Jobs().Lister().Jobs(pod.Namespace).Get(ref.Name)
+		if err != nil {
+			return owner
+		}
+		if parent := metav1.GetControllerOf(job); parent != nil {
+			return &OwnerRef{Kind: parent.Kind, Name: parent.Name}
+		}
+	}
+	return owner
+}
+
+// lastWarningEvent returns the message of the most recent warning event for the pod
+func (d *DiagnosticsService) lastWarningEvent(ctx context.Context, pod *v1.Pod) string {
+	events, err := d.client.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Pod,type=Warning", pod.Name),
+	})
+	if err != nil || len(events.Items) == 0 {
+		return ""
+	}
+
+	latest := events.Items[0]
+	for _, event := range events.Items[1:] {
+		if eventTime(event).After(eventTime(latest)) {
+			latest = event
+		}
+	}
+	return latest.Message
+}
+
+// eventTime returns the best available timestamp for a core event
+func eventTime(event v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
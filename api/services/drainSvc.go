@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kgent-api/pkg/drain"
+	"kgent-api/pkg/index"
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/retry"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Outcomes of DrainPodResult.Status
+const (
+	DrainStatusEvicted = "evicted"
+	DrainStatusSkipped = "skipped"
+	// DrainStatusBlocked pods were refused eviction by a PodDisruptionBudget
+	DrainStatusBlocked = "blocked"
+	DrainStatusFailed  = "failed"
+)
+
+// PlanChangedError is returned when a drain's planId no longer matches the node's plan,
+// with the current plan
+type PlanChangedError struct {
+	Plan *drain.Plan
+}
+
+func (e *PlanChangedError) Error() string {
+	return fmt.Sprintf("drain plan of node %s has changed, its planId is now %s", e.Plan.Node, e.Plan.PlanID)
+}
+
+// DrainOptions controls a drain
+type DrainOptions struct {
+	// PlanID fails the drain with a PlanChangedError unless it matches the current plan
+	PlanID string
+	// Force evicts unmanaged pods, which nothing recreates once they are gone
+	Force bool
+	// Scope is the namespaces pods may be evicted from
+	Scope *nsscope.Scope
+}
+
+// DrainResult is the outcome of a drain, pod by pod. Evicted pods terminate after the
+// drain returns, taking their grace period.
+type DrainResult struct {
+	Node   string `json:"node"`
+	PlanID string `json:"planId"`
+	// Cordoned is set when the drain cordoned the node, rather than finding it cordoned
+	Cordoned bool             `json:"cordoned"`
+	Pods     []DrainPodResult `json:"pods"`
+	Evicted  int              `json:"evicted"`
+	Failed   int              `json:"failed"`
+}
+
+// DrainPodResult is what a drain did with one pod
+type DrainPodResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DrainPlan classifies the pods on a node by what draining it would do, and checks whether
+// the other nodes have room for those that move
+func (n *NodeService) DrainPlan(name string) (*drain.Plan, error) {
+	node, err := n.fact.Core().V1().Nodes().Lister().Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", name, err)
+	}
+	objects, err := n.fact.Core().V1().Pods().Informer().GetIndexer().ByIndex(index.NodeIndex, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pods on node %s: %w", name, err)
+	}
+	nodePods := make([]*v1.Pod, 0, len(objects))
+	for _, obj := range objects {
+		if pod, ok := obj.(*v1.Pod); ok {
+			nodePods = append(nodePods, pod)
+		}
+	}
+
+	nodes, err := n.fact.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := n.fact.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	pdbs, err := n.fact.Policy().V1().PodDisruptionBudgets().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod disruption budgets: %w", err)
+	}
+
+	plan := drain.NewPlan(node, nodePods, nodes, pods, pdbs)
+	return &plan, nil
+}
+
+// Drain cordons a node and evicts its pods, except DaemonSet and mirror pods, through the
+// eviction API so PodDisruptionBudgets are honoured. Unmanaged pods fail the drain unless
+// forced. It doesn't wait for evicted pods to terminate.
+func (n *NodeService) Drain(ctx context.Context, name string, opts DrainOptions) (*DrainResult, error) {
+	plan, err := n.DrainPlan(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PlanID != "" && opts.PlanID != plan.PlanID {
+		return nil, &PlanChangedError{Plan: plan}
+	}
+
+	var unmanaged, outOfScope []string
+	for _, pod := range plan.Pods {
+		if pod.Action == drain.ActionDaemonSetSkipped || pod.Action == drain.ActionMirrorSkipped {
+			continue
+		}
+		if pod.Action == drain.ActionUnmanaged && !opts.Force {
+			unmanaged = append(unmanaged, pod.Namespace+"/"+pod.Name)
+		}
+		if !opts.Scope.Allows(pod.Namespace) {
+			outOfScope = append(outOfScope, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	if len(unmanaged) > 0 {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("pods without a controller would be lost, drain with force=true to evict them: %s", strings.Join(unmanaged, ", ")))
+	}
+	if len(outOfScope) > 0 {
+		return nil, apierrors.NewForbidden(v1.Resource("pods"), outOfScope[0],
+			fmt.Errorf("draining %s evicts pods outside %s: %s", name, opts.Scope, strings.Join(outOfScope, ", ")))
+	}
+
+	result := &DrainResult{Node: name, PlanID: plan.PlanID, Pods: make([]DrainPodResult, 0, len(plan.Pods))}
+	node, err := n.fact.Core().V1().Nodes().Lister().Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", name, err)
+	}
+	if !node.Spec.Unschedulable {
+		err := retry.Do(ctx, "patch", func(int) error {
+			_, err := n.client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, []byte(`{"spec":{"unschedulable":true}}`), metav1.PatchOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to cordon node %s: %w", name, err)
+		}
+		result.Cordoned = true
+	}
+
+	for _, pod := range plan.Pods {
+		podResult := DrainPodResult{Namespace: pod.Namespace, Name: pod.Name, Action: pod.Action, Status: DrainStatusEvicted}
+		switch pod.Action {
+		case drain.ActionDaemonSetSkipped, drain.ActionMirrorSkipped:
+			podResult.Status = DrainStatusSkipped
+		default:
+			// Evictions aren't retried, a 429 means a budget refused it rather than overload
+			err := n.client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+				DeleteOptions: &metav1.DeleteOptions{
+					Preconditions: &metav1.Preconditions{UID: (*types.UID)(&pod.UID)},
+				},
+			})
+			switch {
+			case err == nil, apierrors.IsNotFound(err):
+				result.Evicted++
+			case apierrors.IsTooManyRequests(err):
+				podResult.Status, podResult.Error = DrainStatusBlocked, err.Error()
+				result.Failed++
+			default:
+				podResult.Status, podResult.Error = DrainStatusFailed, err.Error()
+				result.Failed++
+			}
+		}
+		result.Pods = append(result.Pods, podResult)
+	}
+	return result, nil
+}
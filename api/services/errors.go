@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// ErrRuntimeInformersDisabled is returned by the runtime informer endpoints when the server
+// runs without a dynamic informer registry
+var ErrRuntimeInformersDisabled = errors.New("runtime informers are not enabled")
+
+// EmptyArgumentError is returned for required arguments that are empty
+type EmptyArgumentError struct {
+	Argument string
+}
+
+func (e *EmptyArgumentError) Error() string {
+	return fmt.Sprintf("%s cannot be empty", e.Argument)
+}
+
+// InvalidArgumentError is returned for arguments that are set but out of the accepted range
+type InvalidArgumentError struct {
+	Argument string
+	Reason   string
+}
+
+func (e *InvalidArgumentError) Error() string {
+	return fmt.Sprintf("%s %s", e.Argument, e.Reason)
+}
+
+// UnknownResourceError is returned for resource arguments that match no resource type of
+// the server
+type UnknownResourceError struct {
+	Resource string
+}
+
+func (e *UnknownResourceError) Error() string {
+	return fmt.Sprintf("the server doesn't have a resource type %q", e.Resource)
+}
+
+// AmbiguousResourceError is returned for resource arguments that match several resource
+// types, with the fully qualified names that tell them apart
+type AmbiguousResourceError struct {
+	Resource   string
+	Candidates []string
+	Err        error
+}
+
+func (e *AmbiguousResourceError) Error() string {
+	return fmt.Sprintf("resource type %q is ambiguous, use one of %s", e.Resource, strings.Join(e.Candidates, ", "))
+}
+
+func (e *AmbiguousResourceError) Unwrap() error {
+	return e.Err
+}
+
+// ambiguousResource turns the ambiguity error of a REST mapper into an AmbiguousResourceError,
+// naming candidates as resource.version.group like kubectl accepts them
+func ambiguousResource(resourceOrKindArg string, err error) error {
+	var candidates []string
+	var resourceErr *meta.AmbiguousResourceError
+	var kindErr *meta.AmbiguousKindError
+	switch {
+	case errors.As(err, &resourceErr):
+		for _, gvr := range resourceErr.MatchingResources {
+			candidates = append(candidates, strings.TrimSuffix(gvr.Resource+"."+gvr.Version+"."+gvr.Group, "."))
+		}
+	case errors.As(err, &kindErr):
+		for _, gvk := range kindErr.MatchingKinds {
+			candidates = append(candidates, strings.TrimSuffix(gvk.Kind+"."+gvk.Version+"."+gvk.Group, "."))
+		}
+	}
+	return &AmbiguousResourceError{Resource: resourceOrKindArg, Candidates: candidates, Err: err}
+}
+
+// SelectorError is returned for label selectors that don't parse
+type SelectorError struct {
+	Selector string
+	Err      error
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("invalid label selector %q: %v", e.Selector, e.Err)
+}
+
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kgent-api/pkg/firehose"
+	"kgent-api/pkg/retry"
+
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// eventPageSize is how many events are fetched per list call in busy namespaces
+const eventPageSize = 500
+
+// Accepted values of EventQuery.GroupBy
+const (
+	GroupByObjectReason = ""
+	GroupByReason       = "reason"
+	GroupByObject       = "object"
+	GroupByNone         = "none"
+)
+
+type EventService struct {
+	client   kubernetes.Interface
+	firehose *firehose.Hub
+}
+
+func NewEventService(client kubernetes.Interface, hub *firehose.Hub) *EventService {
+	return &EventService{client: client, firehose: hub}
+}
+
+// Firehose subscribes to new Warning events in every namespace passing filter until the
+// returned function is called
+func (e *EventService) Firehose(filter firehose.Filter) (*firehose.Subscription, func(), error) {
+	if filter.Cooldown < 0 || filter.Sample < 0 {
+		return nil, nil, apierrors.NewBadRequest("cooldown and sample cannot be negative")
+	}
+	return e.firehose.Subscribe(filter)
+}
+
+// EventQuery selects and groups the events of a namespace
+type EventQuery struct {
+	Namespace string
+	// Type is Normal or Warning, both when empty
+	Type string
+	// Since drops groups last seen longer ago, nothing when zero
+	Since   time.Duration
+	GroupBy string
+	Limit   int
+}
+
+// EventGroup aggregates the events sharing an involved object and reason, or one of them
+// depending on the grouping
+type EventGroup struct {
+	Kind      string    `json:"kind,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Type      string    `json:"type"`
+	Count     int32     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Message is the message of the most recent event in the group
+	Message string `json:"message"`
+}
+
+// eventRecord is an event of either API reduced to what is grouped on
+type eventRecord struct {
+	kind, namespace, name string
+	reason, eventType     string
+	message               string
+	count                 int32
+	first, last           time.Time
+}
+
+// Events lists the events of a namespace grouped as the query asks, most recently seen first,
+// returning at most query.Limit groups along with how many there were in total
+func (e *EventService) Events(ctx context.Context, query EventQuery) ([]EventGroup, int, error) {
+	switch query.GroupBy {
+	case GroupByObjectReason, GroupByReason, GroupByObject, GroupByNone:
+	default:
+		return nil, 0, apierrors.NewBadRequest(fmt.Sprintf("unsupported groupBy %q, expected reason, object or none", query.GroupBy))
+	}
+
+	records, err := e.listEvents(ctx, query.Namespace, query.Type)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups := map[string]*EventGroup{}
+	var order []*EventGroup
+	for i, r := range records {
+		key := groupKey(query.GroupBy, r, i)
+		group, ok := groups[key]
+		if !ok {
+			group = &EventGroup{Type: r.eventType, FirstSeen: r.first, LastSeen: r.last, Message: r.message}
+			if query.GroupBy != GroupByReason {
+				group.Kind, group.Namespace, group.Name = r.kind, r.namespace, r.name
+			}
+			if query.GroupBy != GroupByObject {
+				group.Reason = r.reason
+			}
+			groups[key] = group
+			order = append(order, group)
+		}
+
+		group.Count += r.count
+		if r.first.Before(group.FirstSeen) {
+			group.FirstSeen = r.first
+		}
+		if r.last.After(group.LastSeen) {
+			group.LastSeen = r.last
+			group.Message = r.message
+		}
+		// A group mixing types, such as an object's events of every reason, reports Warning
+		if r.eventType == v1.EventTypeWarning {
+			group.Type = v1.EventTypeWarning
+		}
+	}
+
+	result := make([]EventGroup, 0, len(order))
+	cutoff := time.Now().Add(-query.Since)
+	for _, group := range order {
+		if query.Since > 0 && group.LastSeen.Before(cutoff) {
+			continue
+		}
+		result = append(result, *group)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].LastSeen.After(result[j].LastSeen) })
+
+	total := len(result)
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result, total, nil
+}
+
+func groupKey(groupBy string, r eventRecord, i int) string {
+	object := strings.Join([]string{r.kind, r.namespace, r.name}, "/")
+	switch groupBy {
+	case GroupByReason:
+		return r.reason
+	case GroupByObject:
+		return object
+	case GroupByNone:
+		return fmt.Sprint(i)
+	}
+	return object + "|" + r.reason
+}
+
+// listEvents reads events.k8s.io/v1, which carries series counts, and falls back to core
+// v1 events on clusters that don't serve it
+func (e *EventService) listEvents(ctx context.Context, ns, eventType string) ([]eventRecord, error) {
+	opts := metav1.ListOptions{Limit: eventPageSize}
+	if eventType != "" {
+		opts.FieldSelector = "type=" + eventType
+	}
+
+	var records []eventRecord
+	for {
+		var list *eventsv1.EventList
+		err := retry.Do(ctx, "list", func(int) (err error) {
+			list, err = e.client.EventsV1().Events(ns).List(ctx, opts)
+			return err
+		})
+		if apierrors.IsNotFound(err) && opts.Continue == "" {
+			return e.listCoreEvents(ctx, ns, eventType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
+
+		for _, event := range list.Items {
+			records = append(records, eventsRecord(event))
+		}
+		if opts.Continue = list.Continue; opts.Continue == "" {
+			return records, nil
+		}
+	}
+}
+
+func (e *EventService) listCoreEvents(ctx context.Context, ns, eventType string) ([]eventRecord, error) {
+	opts := metav1.ListOptions{Limit: eventPageSize}
+	if eventType != "" {
+		opts.FieldSelector = "type=" + eventType
+	}
+
+	var records []eventRecord
+	for {
+		var list *v1.EventList
+		err := retry.Do(ctx, "list", func(int) (err error) {
+			list, err = e.client.CoreV1().Events(ns).List(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
+
+		for _, event := range list.Items {
+			records = append(records, coreEventRecord(event))
+		}
+		if opts.Continue = list.Continue; opts.Continue == "" {
+			return records, nil
+		}
+	}
+}
+
+func eventsRecord(event eventsv1.Event) eventRecord {
+	r := eventRecord{
+		kind:      event.Regarding.Kind,
+		namespace: event.Regarding.Namespace,
+		name:      event.Regarding.Name,
+		reason:    event.Reason,
+		eventType: event.Type,
+		message:   event.Note,
+		count:     1,
+	}
+
+	switch {
+	case !event.EventTime.IsZero():
+		r.first = event.EventTime.Time
+	case !event.DeprecatedFirstTimestamp.IsZero():
+		r.first = event.DeprecatedFirstTimestamp.Time
+	default:
+		r.first = event.CreationTimestamp.Time
+	}
+	r.last = r.first
+
+	if event.Series != nil {
+		r.count = event.Series.Count
+		r.last = event.Series.LastObservedTime.Time
+	} else {
+		if event.DeprecatedCount > 0 {
+			r.count = event.DeprecatedCount
+		}
+		if !event.DeprecatedLastTimestamp.IsZero() {
+			r.last = event.DeprecatedLastTimestamp.Time
+		}
+	}
+	return r
+}
+
+func coreEventRecord(event v1.Event) eventRecord {
+	r := eventRecord{
+		kind:      event.InvolvedObject.Kind,
+		namespace: event.InvolvedObject.Namespace,
+		name:      event.InvolvedObject.Name,
+		reason:    event.Reason,
+		eventType: event.Type,
+		message:   event.Message,
+		count:     1,
+		first:     event.FirstTimestamp.Time,
+		last:      eventTime(event),
+	}
+	if r.first.IsZero() {
+		r.first = r.last
+	}
+
+	if event.Series != nil {
+		r.count = event.Series.Count
+		r.last = event.Series.LastObservedTime.Time
+	} else if event.Count > 0 {
+		r.count = event.Count
+	}
+	return r
+}
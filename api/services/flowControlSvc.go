@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/clientutil"
+	"kgent-api/pkg/retry"
+
+	flowcontrolv1 "k8s.io/api/flowcontrol/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FlowControl is the API server's Priority and Fairness configuration, and how often it has
+// throttled kgent-api's own requests
+type FlowControl struct {
+	FlowSchemas    []FlowSchema    `json:"flowSchemas"`
+	PriorityLevels []PriorityLevel `json:"priorityLevels"`
+	// Throttling is the 429 responses kgent-api's clients have received since startup.
+	// Requests made with a caller's bearer token are not included.
+	Throttling ThrottlingSummary `json:"throttling"`
+}
+
+// FlowSchema classifies requests into a priority level
+type FlowSchema struct {
+	Name                string                                  `json:"name"`
+	UID                 string                                  `json:"uid"`
+	PriorityLevel       string                                  `json:"priorityLevel"`
+	MatchingPrecedence  int32                                   `json:"matchingPrecedence"`
+	DistinguisherMethod string                                  `json:"distinguisherMethod,omitempty"`
+	Conditions          []flowcontrolv1.FlowSchemaCondition     `json:"conditions,omitempty"`
+	Rules               []flowcontrolv1.PolicyRulesWithSubjects `json:"rules,omitempty"`
+	// Throttled is the 429 responses kgent-api received for requests in this flow schema
+	Throttled int64 `json:"throttled"`
+}
+
+// PriorityLevel is a share of the API server's concurrency and how requests queue for it
+type PriorityLevel struct {
+	Name string                                `json:"name"`
+	UID  string                                `json:"uid"`
+	Type flowcontrolv1.PriorityLevelEnablement `json:"type"`
+	// NominalConcurrencyShares, LendablePercent and Queuing are unset for the exempt level
+	NominalConcurrencyShares *int32                                              `json:"nominalConcurrencyShares,omitempty"`
+	LendablePercent          *int32                                              `json:"lendablePercent,omitempty"`
+	LimitResponse            string                                              `json:"limitResponse,omitempty"`
+	Queuing                  *flowcontrolv1.QueuingConfiguration                 `json:"queuing,omitempty"`
+	Conditions               []flowcontrolv1.PriorityLevelConfigurationCondition `json:"conditions,omitempty"`
+	// Throttled is the 429 responses kgent-api received for requests at this level
+	Throttled int64 `json:"throttled"`
+}
+
+// ThrottlingSummary is clientutil.ThrottleSummary with priority levels and flow schemas
+// named rather than identified by UID
+type ThrottlingSummary struct {
+	clientutil.ThrottleSummary
+	// Unattributed counts the 429 responses whose UIDs match no current flow schema or
+	// priority level, e.g. because it has since been recreated
+	Unattributed int64 `json:"unattributed"`
+}
+
+// FlowControl lists the FlowSchemas, by matching precedence, and the
+// PriorityLevelConfigurations, with the 429 responses kgent-api received for each
+func (s *ClusterService) FlowControl(ctx context.Context) (*FlowControl, error) {
+	var schemas *flowcontrolv1.FlowSchemaList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		schemas, err = s.client.FlowcontrolV1().FlowSchemas().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flow schemas: %w", err)
+	}
+	var levels *flowcontrolv1.PriorityLevelConfigurationList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		levels, err = s.client.FlowcontrolV1().PriorityLevelConfigurations().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list priority level configurations: %w", err)
+	}
+
+	summary := s.throttling.Summary()
+	result := &FlowControl{
+		FlowSchemas:    make([]FlowSchema, 0, len(schemas.Items)),
+		PriorityLevels: make([]PriorityLevel, 0, len(levels.Items)),
+		Throttling:     ThrottlingSummary{ThrottleSummary: summary},
+	}
+	schemaNames := map[string]int64{}
+	for _, schema := range schemas.Items {
+		fs := FlowSchema{
+			Name:               schema.Name,
+			UID:                string(schema.UID),
+			PriorityLevel:      schema.Spec.PriorityLevelConfiguration.Name,
+			MatchingPrecedence: schema.Spec.MatchingPrecedence,
+			Conditions:         schema.Status.Conditions,
+			Rules:              schema.Spec.Rules,
+			Throttled:          summary.ByFlowSchema[string(schema.UID)],
+		}
+		if schema.Spec.DistinguisherMethod != nil {
+			fs.DistinguisherMethod = string(schema.Spec.DistinguisherMethod.Type)
+		}
+		if fs.Throttled > 0 {
+			schemaNames[fs.Name] = fs.Throttled
+		}
+		result.FlowSchemas = append(result.FlowSchemas, fs)
+	}
+	sort.Slice(result.FlowSchemas, func(i, j int) bool {
+		if result.FlowSchemas[i].MatchingPrecedence != result.FlowSchemas[j].MatchingPrecedence {
+			return result.FlowSchemas[i].MatchingPrecedence < result.FlowSchemas[j].MatchingPrecedence
+		}
+		return result.FlowSchemas[i].Name < result.FlowSchemas[j].Name
+	})
+
+	levelNames := map[string]int64{}
+	var attributed int64
+	for _, level := range levels.Items {
+		pl := PriorityLevel{
+			Name:       level.Name,
+			UID:        string(level.UID),
+			Type:       level.Spec.Type,
+			Conditions: level.Status.Conditions,
+			Throttled:  summary.ByPriorityLevel[string(level.UID)],
+		}
+		if limited := level.Spec.Limited; limited != nil {
+			pl.NominalConcurrencyShares = limited.NominalConcurrencyShares
+			pl.LendablePercent = limited.LendablePercent
+			pl.LimitResponse = string(limited.LimitResponse.Type)
+			pl.Queuing = limited.LimitResponse.Queuing
+		}
+		if pl.Throttled > 0 {
+			levelNames[pl.Name] = pl.Throttled
+			attributed += pl.Throttled
+		}
+		result.PriorityLevels = append(result.PriorityLevels, pl)
+	}
+	sort.Slice(result.PriorityLevels, func(i, j int) bool { return result.PriorityLevels[i].Name < result.PriorityLevels[j].Name })
+
+	result.Throttling.ByPriorityLevel = levelNames
+	result.Throttling.ByFlowSchema = schemaNames
+	result.Throttling.Unattributed = summary.Throttled - attributed
+	return result, nil
+}
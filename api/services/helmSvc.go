@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/helm"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+)
+
+var helmReleaseResource = schema.GroupResource{Group: "helm.sh", Resource: "releases"}
+
+type HelmService struct {
+	fact informers.SharedInformerFactory
+}
+
+func NewHelmService(fact informers.SharedInformerFactory) *HelmService {
+	return &HelmService{fact: fact}
+}
+
+// HelmRelease summarizes a revision of a Helm release
+type HelmRelease struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chartVersion"`
+	AppVersion   string    `json:"appVersion,omitempty"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description,omitempty"`
+	LastDeployed time.Time `json:"lastDeployed"`
+}
+
+// HelmReleaseError reports a release secret that could not be decoded
+type HelmReleaseError struct {
+	Secret string `json:"secret"`
+	Error  string `json:"error"`
+}
+
+// Releases lists the Helm v3 releases in ns from the secret cache, by name. Only the latest
+// revision of each release is returned unless history is set. Secrets that fail to decode
+// are reported rather than failing the list.
+func (h *HelmService) Releases(ns string, history bool) ([]HelmRelease, []HelmReleaseError, error) {
+	releases, errs, err := h.releases(ns, labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summaries := []HelmRelease{}
+	latest := map[string]int{}
+	for _, release := range releases {
+		summary := HelmRelease{
+			Name:         release.Name,
+			Namespace:    release.Namespace,
+			Revision:     release.Version,
+			Chart:        release.Chart.Metadata.Name,
+			ChartVersion: release.Chart.Metadata.Version,
+			AppVersion:   release.Chart.Metadata.AppVersion,
+			Status:       release.Info.Status,
+			Description:  release.Info.Description,
+			LastDeployed: release.Info.LastDeployed.Time,
+		}
+		key := release.Namespace + "/" + release.Name
+		if i, ok := latest[key]; ok && !history {
+			if release.Version > summaries[i].Revision {
+				summaries[i] = summary
+			}
+			continue
+		}
+		latest[key] = len(summaries)
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Revision > b.Revision
+	})
+	return summaries, errs, nil
+}
+
+// Release returns a revision of the named release in ns, the latest when revision is zero
+func (h *HelmService) Release(ns string, name string, revision int) (*helm.Release, error) {
+	releases, errs, err := h.releases(ns, labels.SelectorFromSet(labels.Set{helm.LabelName: name}))
+	if err != nil {
+		return nil, err
+	}
+
+	var found *helm.Release
+	for _, release := range releases {
+		if release.Name != name || (revision > 0 && release.Version != revision) {
+			continue
+		}
+		if found == nil || release.Version > found.Version {
+			found = release
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to decode release %s: %s", name, errs[0].Error)
+	}
+	if revision > 0 {
+		return nil, apierrors.NewNotFound(helmReleaseResource, fmt.Sprintf("%s revision %d", name, revision))
+	}
+	return nil, apierrors.NewNotFound(helmReleaseResource, name)
+}
+
+// releases decodes the release secrets in ns matching selector
+func (h *HelmService) releases(ns string, selector labels.Selector) ([]*helm.Release, []HelmReleaseError, error) {
+	secrets, err := h.fact.Core().V1().Secrets().Lister().Secrets(ns).List(selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var releases []*helm.Release
+	var errs []HelmReleaseError
+	for _, secret := range secrets {
+		if !helm.IsReleaseSecret(secret) {
+			continue
+		}
+		release, err := helm.Decode(secret)
+		if err != nil {
+			errs = append(errs, HelmReleaseError{Secret: secret.Namespace + "/" + secret.Name, Error: err.Error()})
+			continue
+		}
+		// Older releases may not record their namespace
+		if release.Namespace == "" {
+			release.Namespace = secret.Namespace
+		}
+		releases = append(releases, release)
+	}
+	return releases, errs, nil
+}
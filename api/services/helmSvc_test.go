@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"kgent-api/pkg/helm"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// releaseSecret is the secret Helm stores a revision of a release in, its release gzipped
+// and base64 encoded
+func releaseSecret(t *testing.T, ns, name string, revision int, status string) *corev1.Secret {
+	t.Helper()
+	record := fmt.Sprintf(`{"name":%q,"version":%d,"info":{"status":%q,"last_deployed":"2024-03-04T17:30:00Z"},`+
+		`"chart":{"metadata":{"name":"web","version":"1.4.%d","appVersion":"2.11.0"}},"config":{"replicaCount":%d},"manifest":"kind: Deployment\n"}`,
+		name, revision, status, revision, revision)
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(record)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Namespace: ns,
+			Labels:    map[string]string{helm.LabelOwner: "helm", helm.LabelName: name, helm.LabelVersion: fmt.Sprint(revision)},
+		},
+		Type: helm.ReleaseSecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))},
+	}
+}
+
+func newTestHelmService(t *testing.T, objects ...runtime.Object) *HelmService {
+	t.Helper()
+	fact := informers.NewSharedInformerFactory(fake.NewSimpleClientset(objects...), 0)
+	fact.Core().V1().Secrets().Informer()
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	fact.Start(stopCh)
+	fact.WaitForCacheSync(stopCh)
+	return NewHelmService(fact)
+}
+
+func TestHelmReleases(t *testing.T) {
+	corrupt := releaseSecret(t, "prod", "db", 1, "deployed")
+	corrupt.Data["release"] = []byte("H4sI%%%")
+	opaque := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db-password", Namespace: "prod"}, Type: corev1.SecretTypeOpaque}
+	svc := newTestHelmService(t,
+		releaseSecret(t, "prod", "web", 1, "superseded"),
+		releaseSecret(t, "prod", "web", 2, "deployed"),
+		releaseSecret(t, "prod", "api", 1, "failed"),
+		releaseSecret(t, "dev", "web", 5, "deployed"),
+		corrupt, opaque,
+	)
+
+	tests := []struct {
+		name    string
+		ns      string
+		history bool
+		// want are the name and revision of each release listed
+		want []string
+	}{
+		{name: "latest revisions", ns: "prod", want: []string{"api/1", "web/2"}},
+		{name: "history", ns: "prod", history: true, want: []string{"api/1", "web/2", "web/1"}},
+		{name: "all namespaces", want: []string{"dev/web/5", "prod/api/1", "prod/web/2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			releases, errs, err := svc.Releases(tt.ns, tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, release := range releases {
+				if tt.ns == "" {
+					got = append(got, fmt.Sprintf("%s/%s/%d", release.Namespace, release.Name, release.Revision))
+				} else {
+					got = append(got, fmt.Sprintf("%s/%d", release.Name, release.Revision))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Releases() = %v, want %v", got, tt.want)
+			}
+			// The corrupt secret is reported, the opaque one skipped
+			if len(errs) != 1 || errs[0].Secret != "prod/sh.helm.release.v1.db.v1" {
+				t.Errorf("Releases() errors = %+v, want the db release secret", errs)
+			}
+		})
+	}
+}
+
+func TestHelmRelease(t *testing.T) {
+	svc := newTestHelmService(t,
+		releaseSecret(t, "prod", "web", 1, "superseded"),
+		releaseSecret(t, "prod", "web", 2, "deployed"),
+	)
+
+	tests := []struct {
+		name         string
+		release      string
+		revision     int
+		wantRevision int
+		wantErr      func(error) bool
+	}{
+		{name: "latest", release: "web", wantRevision: 2},
+		{name: "revision", release: "web", revision: 1, wantRevision: 1},
+		{name: "missing revision", release: "web", revision: 3, wantErr: apierrors.IsNotFound},
+		{name: "missing release", release: "api", wantErr: apierrors.IsNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := svc.Release("prod", tt.release, tt.revision)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("Release() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.Version != tt.wantRevision || release.Namespace != "prod" {
+				t.Errorf("Release() = %s v%d, want prod v%d", release.Namespace, release.Version, tt.wantRevision)
+			}
+			if release.Config["replicaCount"] != float64(tt.wantRevision) || release.Manifest != "kind: Deployment\n" {
+				t.Errorf("Release() config = %v, manifest = %q, want those of revision %d", release.Config, release.Manifest, tt.wantRevision)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/retry"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrInvalidReplicaRange is returned for a min/max replica range the HPA would reject
+var ErrInvalidReplicaRange = errors.New("minReplicas must be at least 1 and not greater than maxReplicas")
+
+type HPAService struct {
+	client kubernetes.Interface
+}
+
+func NewHPAService(client kubernetes.Interface) *HPAService {
+	return &HPAService{client: client}
+}
+
+// HPAMetric is a metric of any autoscaling/v2 source type with its target and current value
+type HPAMetric struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Container  string `json:"container,omitempty"`
+	Object     string `json:"object,omitempty"`
+	TargetType string `json:"targetType"`
+	Target     string `json:"target"`
+	Current    string `json:"current,omitempty"`
+}
+
+// HPACondition explains why the autoscaler is or isn't scaling
+type HPACondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// HPASummary is the state of a HorizontalPodAutoscaler
+type HPASummary struct {
+	Name            string         `json:"name"`
+	Namespace       string         `json:"namespace"`
+	ScaleTarget     string         `json:"scaleTarget"`
+	MinReplicas     int32          `json:"minReplicas"`
+	MaxReplicas     int32          `json:"maxReplicas"`
+	CurrentReplicas int32          `json:"currentReplicas"`
+	DesiredReplicas int32          `json:"desiredReplicas"`
+	LastScaleTime   *metav1.Time   `json:"lastScaleTime,omitempty"`
+	Metrics         []HPAMetric    `json:"metrics"`
+	Conditions      []HPACondition `json:"conditions"`
+}
+
+// ListHPAs returns the HorizontalPodAutoscalers in the namespace ordered by name
+func (h *HPAService) ListHPAs(ctx context.Context, ns string) ([]HPASummary, error) {
+	var list *autoscalingv2.HorizontalPodAutoscalerList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		list, err = h.client.AutoscalingV2().HorizontalPodAutoscalers(ns).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list horizontalpodautoscalers: %w", err)
+	}
+
+	summaries := make([]HPASummary, 0, len(list.Items))
+	for i := range list.Items {
+		summaries = append(summaries, summarizeHPA(&list.Items[i]))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries, nil
+}
+
+// SetReplicaRange updates the min and max replicas of an HPA. A nil bound keeps its current value.
+func (h *HPAService) SetReplicaRange(ctx context.Context, ns, name string, minReplicas, maxReplicas *int32) (*HPASummary, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "horizontalpodautoscaler name"}
+	}
+
+	var hpa *autoscalingv2.HorizontalPodAutoscaler
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		hpa, err = h.client.AutoscalingV2().HorizontalPodAutoscalers(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get horizontalpodautoscaler %s: %w", name, err)
+	}
+
+	newMin, newMax := int32(1), hpa.Spec.MaxReplicas
+	if hpa.Spec.MinReplicas != nil {
+		newMin = *hpa.Spec.MinReplicas
+	}
+	if minReplicas != nil {
+		newMin = *minReplicas
+	}
+	if maxReplicas != nil {
+		newMax = *maxReplicas
+	}
+	if newMin < 1 || newMax < newMin {
+		return nil, fmt.Errorf("min %d, max %d: %w", newMin, newMax, ErrInvalidReplicaRange)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]int32{"minReplicas": newMin, "maxReplicas": newMax},
+	})
+	if err != nil {
+		return nil, err
+	}
+	hpa, err = h.client.AutoscalingV2().HorizontalPodAutoscalers(ns).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch horizontalpodautoscaler %s: %w", name, err)
+	}
+	summary := summarizeHPA(hpa)
+	return &summary, nil
+}
+
+func summarizeHPA(hpa *autoscalingv2.HorizontalPodAutoscaler) HPASummary {
+	summary := HPASummary{
+		Name:            hpa.Name,
+		Namespace:       hpa.Namespace,
+		ScaleTarget:     hpa.Spec.ScaleTargetRef.Kind + "/" + hpa.Spec.ScaleTargetRef.Name,
+		MinReplicas:     1,
+		MaxReplicas:     hpa.Spec.MaxReplicas,
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+		LastScaleTime:   hpa.Status.LastScaleTime,
+		Metrics:         []HPAMetric{},
+		Conditions:      []HPACondition{},
+	}
+	if hpa.Spec.MinReplicas != nil {
+		summary.MinReplicas = *hpa.Spec.MinReplicas
+	}
+
+	current := map[string]string{}
+	for _, status := range hpa.Status.CurrentMetrics {
+		if metric, value, ok := metricStatus(status); ok {
+			current[metric.key()] = value
+		}
+	}
+	for _, spec := range hpa.Spec.Metrics {
+		metric, ok := metricSpec(spec)
+		if !ok {
+			continue
+		}
+		metric.Current = current[metric.key()]
+		summary.Metrics = append(summary.Metrics, metric)
+	}
+
+	for _, condition := range hpa.Status.Conditions {
+		summary.Conditions = append(summary.Conditions, HPACondition{
+			Type:    string(condition.Type),
+			Status:  string(condition.Status),
+			Reason:  condition.Reason,
+			Message: condition.Message,
+		})
+	}
+	return summary
+}
+
+// key matches a metric spec with its status
+func (m HPAMetric) key() string {
+	return m.Type + "/" + m.Object + "/" + m.Container + "/" + m.Name
+}
+
+func metricSpec(spec autoscalingv2.MetricSpec) (HPAMetric, bool) {
+	metric := HPAMetric{Type: string(spec.Type)}
+	var target autoscalingv2.MetricTarget
+
+	switch {
+	case spec.Type == autoscalingv2.ResourceMetricSourceType && spec.Resource != nil:
+		metric.Name = string(spec.Resource.Name)
+		target = spec.Resource.Target
+	case spec.Type == autoscalingv2.ContainerResourceMetricSourceType && spec.ContainerResource != nil:
+		metric.Name = string(spec.ContainerResource.Name)
+		metric.Container = spec.ContainerResource.Container
+		target = spec.ContainerResource.Target
+	case spec.Type == autoscalingv2.PodsMetricSourceType && spec.Pods != nil:
+		metric.Name = spec.Pods.Metric.Name
+		target = spec.Pods.Target
+	case spec.Type == autoscalingv2.ObjectMetricSourceType && spec.Object != nil:
+		metric.Name = spec.Object.Metric.Name
+		metric.Object = spec.Object.DescribedObject.Kind + "/" + spec.Object.DescribedObject.Name
+		target = spec.Object.Target
+	case spec.Type == autoscalingv2.ExternalMetricSourceType && spec.External != nil:
+		metric.Name = spec.External.Metric.Name
+		target = spec.External.Target
+	default:
+		return metric, false
+	}
+
+	metric.TargetType = string(target.Type)
+	switch {
+	case target.AverageUtilization != nil:
+		metric.Target = fmt.Sprintf("%d%%", *target.AverageUtilization)
+	case target.AverageValue != nil:
+		metric.Target = target.AverageValue.String()
+	case target.Value != nil:
+		metric.Target = target.Value.String()
+	}
+	return metric, true
+}
+
+func metricStatus(status autoscalingv2.MetricStatus) (HPAMetric, string, bool) {
+	metric := HPAMetric{Type: string(status.Type)}
+	var current autoscalingv2.MetricValueStatus
+
+	switch {
+	case status.Type == autoscalingv2.ResourceMetricSourceType && status.Resource != nil:
+		metric.Name = string(status.Resource.Name)
+		current = status.Resource.Current
+	case status.Type == autoscalingv2.ContainerResourceMetricSourceType && status.ContainerResource != nil:
+		metric.Name = string(status.ContainerResource.Name)
+		metric.Container = status.ContainerResource.Container
+		current = status.ContainerResource.Current
+	case status.Type == autoscalingv2.PodsMetricSourceType && status.Pods != nil:
+		metric.Name = status.Pods.Metric.Name
+		current = status.Pods.Current
+	case status.Type == autoscalingv2.ObjectMetricSourceType && status.Object != nil:
+		metric.Name = status.Object.Metric.Name
+		metric.Object = status.Object.DescribedObject.Kind + "/" + status.Object.DescribedObject.Name
+		current = status.Object.Current
+	case status.Type == autoscalingv2.ExternalMetricSourceType && status.External != nil:
+		metric.Name = status.External.Metric.Name
+		current = status.External.Current
+	default:
+		return metric, "", false
+	}
+
+	switch {
+	case current.AverageUtilization != nil:
+		return metric, fmt.Sprintf("%d%%", *current.AverageUtilization), true
+	case current.AverageValue != nil:
+		return metric, current.AverageValue.String(), true
+	case current.Value != nil:
+		return metric, current.Value.String(), true
+	}
+	return metric, "", false
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"kgent-api/pkg/manifest"
+	"kgent-api/pkg/retry"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// identifyWorkers bounds the concurrent lookups of an exists check
+const identifyWorkers = 8
+
+// Scopes of ObjectIdentity.Scope
+const (
+	ScopeNamespaced = "Namespaced"
+	ScopeCluster    = "Cluster"
+)
+
+// ObjectIdentity is what a manifest document would become once created
+type ObjectIdentity struct {
+	// Document is the index of the document in the manifest, from 0. The items of a List
+	// are numbered as documents of their own.
+	Document     int                          `json:"document"`
+	GVK          schema.GroupVersionKind      `json:"gvk"`
+	GVR          *schema.GroupVersionResource `json:"gvr,omitempty"`
+	Scope        string                       `json:"scope,omitempty"`
+	Namespace    string                       `json:"namespace,omitempty"`
+	Name         string                       `json:"name,omitempty"`
+	GenerateName string                       `json:"generateName,omitempty"`
+	// NamespaceDefaulted is set when the namespace wasn't in the document
+	NamespaceDefaulted bool `json:"namespaceDefaulted,omitempty"`
+	// Exists is only set when checked, and for documents with a name
+	Exists *bool  `json:"exists,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IdentifyManifest resolves the resource, scope, namespace and name of every document of a
+// multi-document manifest, defaulting the namespace to ns as an apply would, without creating
+// anything. With checkExists it looks up each named object, concurrently. Only a manifest that
+// can't be parsed fails; documents that can't be mapped carry an error.
+func (r *ResourceService) IdentifyManifest(ctx context.Context, yamlContent string, ns string, checkExists bool) ([]ObjectIdentity, error) {
+	objects, err := manifest.Decode([]byte(yamlContent))
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	if ns == "" {
+		ns = "default"
+	}
+
+	identities := make([]ObjectIdentity, len(objects))
+	mappings := make([]*meta.RESTMapping, len(objects))
+	for i, obj := range objects {
+		identity := ObjectIdentity{
+			Document:     i,
+			GVK:          obj.GroupVersionKind(),
+			Name:         obj.GetName(),
+			GenerateName: obj.GetGenerateName(),
+		}
+		explicit := obj.GetNamespace()
+		mapping, err := mapObject(*r.restMapper, obj, ns)
+		if err != nil {
+			identity.Error = err.Error()
+			identity.Namespace = explicit
+			identities[i] = identity
+			continue
+		}
+		identity.GVR = &mapping.Resource
+		identity.Scope = ScopeCluster
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			identity.Scope = ScopeNamespaced
+			identity.NamespaceDefaulted = explicit == ""
+		}
+		identity.Namespace = obj.GetNamespace()
+		if identity.Name == "" && identity.GenerateName == "" {
+			identity.Error = "metadata.name or metadata.generateName is required"
+		} else if err := r.checkScope(mapping, identity.Namespace); err != nil {
+			identity.Error = err.Error()
+		} else {
+			mappings[i] = mapping
+		}
+		identities[i] = identity
+	}
+
+	if checkExists {
+		if err := r.checkExists(ctx, identities, mappings); err != nil {
+			return nil, err
+		}
+	}
+	return identities, nil
+}
+
+// checkExists sets Exists on the named identities that mapped, looking them up with up to
+// identifyWorkers concurrent requests
+func (r *ResourceService) checkExists(ctx context.Context, identities []ObjectIdentity, mappings []*meta.RESTMapping) error {
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(identifyWorkers, len(identities)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				identity := &identities[i]
+				ri := dynamic.ResourceInterface(client.Resource(mappings[i].Resource))
+				if identity.Scope == ScopeNamespaced {
+					ri = client.Resource(mappings[i].Resource).Namespace(identity.Namespace)
+				}
+				err := retry.Do(ctx, "get", func(int) error {
+					_, err := ri.Get(ctx, identity.Name, metav1.GetOptions{})
+					return err
+				})
+				switch {
+				case err == nil:
+					exists := true
+					identity.Exists = &exists
+				case apierrors.IsNotFound(err):
+					exists := false
+					identity.Exists = &exists
+				default:
+					identity.Error = "failed to check whether the object exists: " + err.Error()
+				}
+			}
+		}()
+	}
+
+	for i := range identities {
+		// Objects named by the server can't exist yet
+		if mappings[i] != nil && identities[i].Name != "" {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// How FindImageConsumers compares image references
+const (
+	// ImageMatchExact matches the same repository and tag, or digest
+	ImageMatchExact = "exact"
+	// ImageMatchRepository matches any tag or digest of the repository
+	ImageMatchRepository = "repository"
+	// ImageMatchDigest matches references pinned to the digest, and standalone pods whose
+	// containers run it whatever tag they name
+	ImageMatchDigest = "digest"
+)
+
+// ImageConsumer is a workload or standalone pod whose pod template references an image
+type ImageConsumer struct {
+	Kind       string           `json:"kind"`
+	Namespace  string           `json:"namespace"`
+	Name       string           `json:"name"`
+	Containers []ImageContainer `json:"containers"`
+}
+
+// ImageContainer is a container referencing the image
+type ImageContainer struct {
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	InitContainer bool   `json:"initContainer"`
+}
+
+// imageRef is an image reference broken down, with Docker Hub defaults filled in so that
+// nginx:1.25 and docker.io/library/nginx:1.25 compare equal
+type imageRef struct {
+	repository string
+	tag        string
+	digest     string
+}
+
+func parseImageRef(image string) imageRef {
+	var ref imageRef
+	if i := strings.Index(image, "@"); i >= 0 {
+		ref.digest = image[i+1:]
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		ref.tag = image[i+1:]
+		image = image[:i]
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+
+	// The first component is a registry only if it looks like a host
+	first, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		image = "docker.io/" + image
+	}
+	if strings.HasPrefix(image, "docker.io/") && strings.Count(image, "/") == 1 {
+		image = "docker.io/library/" + strings.TrimPrefix(image, "docker.io/")
+	}
+	ref.repository = image
+	return ref
+}
+
+// FindImageConsumers lists the Deployments, StatefulSets, DaemonSets, CronJobs and
+// standalone pods in ns, every namespace when empty, whose pod templates reference image.
+// Pods with a controller are left out, their workload or Job is what gets rolled out.
+func (i *ImageService) FindImageConsumers(ns string, image string, match string) ([]ImageConsumer, error) {
+	if image == "" {
+		return nil, apierrors.NewBadRequest("image is required")
+	}
+	want := parseImageRef(image)
+	switch match {
+	case ImageMatchExact, ImageMatchRepository:
+	case ImageMatchDigest:
+		if want.digest == "" {
+			return nil, apierrors.NewBadRequest("match=digest needs an image with a digest, such as nginx@sha256:...")
+		}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unknown match %q, expected exact, repository or digest", match))
+	}
+	matches := func(image string) bool {
+		got := parseImageRef(image)
+		switch match {
+		case ImageMatchRepository:
+			return got.repository == want.repository
+		case ImageMatchDigest:
+			return got.digest == want.digest
+		}
+		if want.digest != "" {
+			return got.repository == want.repository && got.digest == want.digest
+		}
+		return got.repository == want.repository && got.tag == want.tag
+	}
+
+	consumers := []ImageConsumer{}
+	add := func(kind string, meta metav1.ObjectMeta, spec *v1.PodSpec, imageIDs map[string]string) {
+		consumer := ImageConsumer{Kind: kind, Namespace: meta.Namespace, Name: meta.Name}
+		for _, containers := range []struct {
+			list []v1.Container
+			init bool
+		}{{spec.InitContainers, true}, {spec.Containers, false}} {
+			for _, c := range containers.list {
+				if matches(c.Image) || (match == ImageMatchDigest && imageDigest(imageIDs[c.Name]) == want.digest) {
+					consumer.Containers = append(consumer.Containers, ImageContainer{Name: c.Name, Image: c.Image, InitContainer: containers.init})
+				}
+			}
+		}
+		if len(consumer.Containers) > 0 {
+			consumers = append(consumers, consumer)
+		}
+	}
+
+	deployments, err := i.fact.Apps().V1().Deployments().Lister().Deployments(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments {
+		add("Deployment", d.ObjectMeta, &d.Spec.Template.Spec, nil)
+	}
+	statefulSets, err := i.fact.Apps().V1().StatefulSets().Lister().StatefulSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, s := range statefulSets {
+		add("StatefulSet", s.ObjectMeta, &s.Spec.Template.Spec, nil)
+	}
+	daemonSets, err := i.fact.Apps().V1().DaemonSets().Lister().DaemonSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, d := range daemonSets {
+		add("DaemonSet", d.ObjectMeta, &d.Spec.Template.Spec, nil)
+	}
+	cronJobs, err := i.fact.Batch().V1().CronJobs().Lister().CronJobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+	for _, cj := range cronJobs {
+		add("CronJob", cj.ObjectMeta, &cj.Spec.JobTemplate.Spec.Template.Spec, nil)
+	}
+	pods, err := i.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods {
+		if metav1.GetControllerOf(pod) != nil {
+			continue
+		}
+		imageIDs := map[string]string{}
+		for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, status := range statuses {
+				imageIDs[status.Name] = status.ImageID
+			}
+		}
+		add("Pod", pod.ObjectMeta, &pod.Spec, imageIDs)
+	}
+
+	sort.Slice(consumers, func(a, b int) bool {
+		if consumers[a].Kind != consumers[b].Kind {
+			return consumers[a].Kind < consumers[b].Kind
+		}
+		if consumers[a].Namespace != consumers[b].Namespace {
+			return consumers[a].Namespace < consumers[b].Namespace
+		}
+		return consumers[a].Name < consumers[b].Name
+	})
+	return consumers, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+)
+
+type ImageService struct {
+	fact informers.SharedInformerFactory
+}
+
+func NewImageService(fact informers.SharedInformerFactory) *ImageService {
+	return &ImageService{fact: fact}
+}
+
+// ImageUsage is a single container running an image
+type ImageUsage struct {
+	Namespace     string `json:"namespace"`
+	Pod           string `json:"pod"`
+	Container     string `json:"container"`
+	ContainerType string `json:"containerType"`
+	Image         string `json:"image"`
+	ImageID       string `json:"imageID,omitempty"`
+}
+
+// ImageEntry groups every usage of one image. References sharing the same digest
+// are merged, so References lists each tag the digest was pulled as.
+type ImageEntry struct {
+	Repository string       `json:"repository"`
+	Digest     string       `json:"digest,omitempty"`
+	References []string     `json:"references"`
+	Usages     []ImageUsage `json:"usages"`
+}
+
+// ListImages walks the pod cache and groups containers by the image they run.
+// An empty namespace covers all namespaces; imageFilter matches repository substrings.
+func (i *ImageService) ListImages(ns string, imageFilter string) (map[string]*ImageEntry, error) {
+	usages, err := i.imageUsages(ns, imageFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]*ImageEntry{}
+	for _, usage := range usages {
+		repository := imageRepository(usage.Image)
+		digest := imageDigest(usage.ImageID)
+
+		// Identical digests behind different tags collapse into one entry
+		key := usage.Image
+		if digest != "" {
+			key = repository + "@" + digest
+		}
+
+		entry, ok := images[key]
+		if !ok {
+			entry = &ImageEntry{Repository: repository, Digest: digest}
+			images[key] = entry
+		}
+		if !slices.Contains(entry.References, usage.Image) {
+			entry.References = append(entry.References, usage.Image)
+			sort.Strings(entry.References)
+		}
+		entry.Usages = append(entry.Usages, usage)
+	}
+
+	return images, nil
+}
+
+// ListImageIDs returns the resolved image IDs reported in container statuses, for supply-chain auditing
+func (i *ImageService) ListImageIDs(ns string, imageFilter string) (map[string][]ImageUsage, error) {
+	usages, err := i.imageUsages(ns, imageFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	imageIDs := map[string][]ImageUsage{}
+	for _, usage := range usages {
+		// Containers that haven't started yet have no resolved image
+		if usage.ImageID == "" {
+			continue
+		}
+		imageIDs[usage.ImageID] = append(imageIDs[usage.ImageID], usage)
+	}
+	return imageIDs, nil
+}
+
+func (i *ImageService) imageUsages(ns string, imageFilter string) ([]ImageUsage, error) {
+	pods, err := i.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var usages []ImageUsage
+	for _, pod := range pods {
+		for _, usage := range podImageUsages(pod) {
+			if imageFilter != "" && !strings.Contains(imageRepository(usage.Image), imageFilter) {
+				continue
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+// podImageUsages lists the images of init, regular and ephemeral containers along with their resolved IDs
+func podImageUsages(pod *v1.Pod) []ImageUsage {
+	imageIDs := map[string]string{}
+	for _, statuses := range [][]v1.ContainerStatus{
+		pod.Status.InitContainerStatuses,
+		pod.Status.ContainerStatuses,
+		pod.Status.EphemeralContainerStatuses,
+	} {
+		for _, status := range statuses {
+			imageIDs[status.Name] = status.ImageID
+		}
+	}
+
+	var usages []ImageUsage
+	add := func(name, image, containerType string) {
+		usages = append(usages, ImageUsage{
+			Namespace:     pod.Namespace,
+			Pod:           pod.Name,
+			Container:     name,
+			ContainerType: containerType,
+			Image:         image,
+			ImageID:       imageIDs[name],
+		})
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Name, c.Image, "init")
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Name, c.Image, "container")
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		add(c.Name, c.Image, "ephemeral")
+	}
+	return usages
+}
+
+// imageRepository strips the tag and digest from an image reference
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	lastSlash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > lastSlash {
+		image = image[:i]
+	}
+	return image
+}
+
+// imageDigest extracts the digest from a container status imageID such as
+// docker-pullable://nginx@sha256:abc
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kgent-api/pkg/index"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+type IndexService struct {
+	fact informers.SharedInformerFactory
+}
+
+func NewIndexService(fact informers.SharedInformerFactory) *IndexService {
+	return &IndexService{fact: fact}
+}
+
+// indexer returns the cache indexer for the resource. Only pods carry custom indexes.
+func (i *IndexService) indexer(resource string) (cache.Indexer, error) {
+	if resource != "pods" {
+		return nil, fmt.Errorf("no indexes are registered for %s, only pods are indexed", resource)
+	}
+	return i.fact.Core().V1().Pods().Informer().GetIndexer(), nil
+}
+
+// Indexes returns the names of the indexes registered for the resource
+func (i *IndexService) Indexes(resource string) ([]string, error) {
+	indexer, err := i.indexer(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(indexer.GetIndexers()))
+	for name := range indexer.GetIndexers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Query returns the cached objects whose index value equals key. Label indexes are scoped to ns.
+func (i *IndexService) Query(resource, by, key, ns string) ([]interface{}, error) {
+	indexer, err := i.indexer(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := indexer.GetIndexers()[by]; !ok {
+		names, _ := i.Indexes(resource)
+		return nil, fmt.Errorf("unknown index %q, available indexes: %s", by, strings.Join(names, ", "))
+	}
+	if strings.HasPrefix(by, index.LabelIndexPrefix) {
+		key = index.LabelKey(ns, key)
+	}
+
+	objects, err := indexer.ByIndex(by, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %w", by, err)
+	}
+	return objects, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kgent-api/pkg/dyninformer"
+	"kgent-api/pkg/nsinformer"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WithDynamicInformers lets informers for any resource be started at runtime. Lists are
+// served from a running informer's cache before falling back to the shared factory.
+func WithDynamicInformers(registry *dyninformer.Registry) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.dynamicInformers = registry
+	}
+}
+
+// WithInformerShards serves the namespaced resources of the shared factory from the shard
+// of the requested namespace, started on first use when shards are lazy. Namespaces without
+// a shard are listed from the API server. All-namespace lists are too, unless fanOut
+// gathers them from the running shards, leaving out namespaces that have none.
+func WithInformerShards(shards *nsinformer.Registry, fanOut bool) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.shards = shards
+		r.shardFanOut = fanOut
+	}
+}
+
+// shardedObjects lists a resource the shards cache
+func (r *ResourceService) shardedObjects(ctx context.Context, restMapping *meta.RESTMapping, ns string) ([]runtime.Object, error) {
+	if ns == "" {
+		if r.shardFanOut {
+			return r.shards.ListAll(restMapping.Resource)
+		}
+		return r.listAsCaller(ctx, restMapping, ns)
+	}
+
+	lister, err := r.shards.Ensure(ctx, restMapping.Resource, ns)
+	if errors.Is(err, nsinformer.ErrNotSharded) {
+		return r.listAsCaller(ctx, restMapping, ns)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache %s in %s: %w", restMapping.Resource.Resource, ns, err)
+	}
+	return lister.ByNamespace(ns).List(labels.Everything())
+}
+
+// StartInformer starts caching resourceOrKindArg in ns, or in all namespaces when ns is
+// empty. Cluster-scoped resources are always cached as a whole.
+func (r *ResourceService) StartInformer(resourceOrKindArg string, ns string) (*dyninformer.Info, error) {
+	if r.dynamicInformers == nil {
+		return nil, ErrRuntimeInformersDisabled
+	}
+	for _, verb := range []string{"list", "watch"} {
+		if err := r.checkVerb(resourceOrKindArg, verb); err != nil {
+			return nil, err
+		}
+	}
+
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return nil, err
+	}
+	if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		ns = ""
+	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		return nil, err
+	}
+	// An informer for every namespace would cache objects outside the scope
+	if ns == "" && restMapping.Scope.Name() == meta.RESTScopeNameNamespace && r.scope.Restricted() {
+		return nil, apierrors.NewForbidden(restMapping.Resource.GroupResource(), "", fmt.Errorf("informers must be started in one of %s", r.scope))
+	}
+
+	info, err := r.dynamicInformers.Start(restMapping.Resource, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start informer for %s: %w", restMapping.Resource.Resource, err)
+	}
+	return &info, nil
+}
+
+// StopInformer stops the informer for resourceOrKindArg in ns and drops its cache
+func (r *ResourceService) StopInformer(resourceOrKindArg string, ns string) error {
+	if r.dynamicInformers == nil {
+		return ErrRuntimeInformersDisabled
+	}
+
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return err
+	}
+	if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		ns = ""
+	}
+
+	if err := r.dynamicInformers.Stop(restMapping.Resource, ns); err != nil {
+		return fmt.Errorf("failed to stop informer for %s: %w", restMapping.Resource.Resource, err)
+	}
+	return nil
+}
+
+// Informers lists the informers started at runtime with their object counts and age
+func (r *ResourceService) Informers() []dyninformer.Info {
+	if r.dynamicInformers == nil {
+		return []dyninformer.Info{}
+	}
+	return r.dynamicInformers.List()
+}
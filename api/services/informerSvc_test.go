@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"kgent-api/pkg/nsinformer"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestListResourceSharded(t *testing.T) {
+	const namespaces, podsPerNamespace = 20, 100
+	var objects []runtime.Object
+	for n := 0; n < namespaces; n++ {
+		for i := 0; i < podsPerNamespace; i++ {
+			objects = append(objects, testPod(fmt.Sprintf("ns-%d", n), fmt.Sprintf("web-%d", i)))
+		}
+	}
+
+	tests := []struct {
+		name   string
+		lazy   bool
+		fanOut bool
+		ns     string
+		want   int
+		// wantLive is whether the list went to the API server rather than a shard
+		wantLive bool
+	}{
+		{name: "pinned namespace", ns: "ns-1", want: podsPerNamespace},
+		{name: "namespace without a shard", ns: "ns-5", want: podsPerNamespace, wantLive: true},
+		{name: "lazily sharded namespace", lazy: true, ns: "ns-5", want: podsPerNamespace},
+		{name: "all namespaces", want: namespaces * podsPerNamespace, wantLive: true},
+		// Fanned out, only the namespaces with a shard are listed
+		{name: "all namespaces fanned out", fanOut: true, want: 2 * podsPerNamespace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards, err := nsinformer.NewRegistry(fake.NewSimpleClientset(objects...), nsinformer.Options{
+				Namespaces: []string{"ns-1", "ns-2"},
+				Lazy:       tt.lazy,
+				Resources:  []schema.GroupVersionResource{corev1.SchemeGroupVersion.WithResource("pods")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			go shards.Run(ctx)
+			if unsynced := shards.WaitForCacheSync(ctx.Done()); len(unsynced) > 0 {
+				t.Fatalf("shards of %v did not sync", unsynced)
+			}
+
+			svc, client := newTestResourceService(t, objects, WithInformerShards(shards, tt.fanOut))
+			list, err := svc.ListResource(ctx, "pods", tt.ns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != tt.want {
+				t.Errorf("ListResource(%q) = %d pods, want %d", tt.ns, len(list), tt.want)
+			}
+			live := false
+			for _, action := range client.Actions() {
+				live = live || action.GetVerb() == "list"
+			}
+			if live != tt.wantLive {
+				t.Errorf("listed from the API server = %v, want %v", live, tt.wantLive)
+			}
+		})
+	}
+}
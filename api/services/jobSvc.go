@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/retry"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+// retryOfAnnotation records the Job a retry was created from
+const retryOfAnnotation = "kgent.io/retry-of"
+
+// controllerLabels are set by the Job controller and tie pods to a single Job's UID, so they
+// must not be copied into a new Job
+var controllerLabels = []string{
+	"controller-uid",
+	"batch.kubernetes.io/controller-uid",
+	"job-name",
+	"batch.kubernetes.io/job-name",
+}
+
+// ErrJobNotFailed is returned when retrying a Job that has not failed
+var ErrJobNotFailed = errors.New("only failed jobs can be retried")
+
+type JobService struct {
+	client kubernetes.Interface
+	fact   informers.SharedInformerFactory
+}
+
+func NewJobService(client kubernetes.Interface, fact informers.SharedInformerFactory) *JobService {
+	return &JobService{client: client, fact: fact}
+}
+
+// JobSummary is a Job's progress and, once it failed, the reason from its conditions
+type JobSummary struct {
+	Name           string       `json:"name"`
+	Namespace      string       `json:"namespace"`
+	Status         string       `json:"status"`
+	Completions    int32        `json:"completions"`
+	Parallelism    int32        `json:"parallelism"`
+	Active         int32        `json:"active"`
+	Succeeded      int32        `json:"succeeded"`
+	Failed         int32        `json:"failed"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Duration       string       `json:"duration,omitempty"`
+	FailureReason  string       `json:"failureReason,omitempty"`
+	FailureMessage string       `json:"failureMessage,omitempty"`
+	RetryOf        string       `json:"retryOf,omitempty"`
+}
+
+// ListJobs returns the Jobs in the namespace from the informer cache, most recently started first
+func (j *JobService) ListJobs(ns string) ([]JobSummary, error) {
+	jobs, err := j.fact.Batch().V1().Jobs().Lister().Jobs(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	summaries := make([]JobSummary, 0, len(jobs))
+	for _, job := range jobs {
+		summaries = append(summaries, summarizeJob(job))
+	}
+	sort.Slice(summaries, func(i, k int) bool {
+		return jobStart(summaries[i]).After(jobStart(summaries[k]))
+	})
+	return summaries, nil
+}
+
+func jobStart(s JobSummary) time.Time {
+	if s.StartTime == nil {
+		return time.Time{}
+	}
+	return s.StartTime.Time
+}
+
+func summarizeJob(job *batchv1.Job) JobSummary {
+	summary := JobSummary{
+		Name:           job.Name,
+		Namespace:      job.Namespace,
+		Status:         "Running",
+		Completions:    1,
+		Parallelism:    1,
+		Active:         job.Status.Active,
+		Succeeded:      job.Status.Succeeded,
+		Failed:         job.Status.Failed,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		RetryOf:        job.Annotations[retryOfAnnotation],
+	}
+	if job.Spec.Completions != nil {
+		summary.Completions = *job.Spec.Completions
+	}
+	if job.Spec.Parallelism != nil {
+		summary.Parallelism = *job.Spec.Parallelism
+	}
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		summary.Status = "Suspended"
+	}
+
+	end := time.Now()
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			summary.Status = "Complete"
+		case batchv1.JobFailed:
+			summary.Status = "Failed"
+			summary.FailureReason = condition.Reason
+			summary.FailureMessage = condition.Message
+			end = condition.LastTransitionTime.Time
+		}
+	}
+	if job.Status.CompletionTime != nil {
+		end = job.Status.CompletionTime.Time
+	}
+	if job.Status.StartTime != nil {
+		summary.Duration = duration.HumanDuration(end.Sub(job.Status.StartTime.Time))
+	}
+	return summary
+}
+
+// RetryJob creates a new Job from the spec of a failed Job. Fields populated by the Job
+// controller are stripped so the new Job gets its own selector and pods.
+func (j *JobService) RetryJob(ctx context.Context, ns, name string) (*JobSummary, error) {
+	job, err := j.getJob(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	if summarizeJob(job).Status != "Failed" {
+		return nil, fmt.Errorf("job %s: %w", name, ErrJobNotFailed)
+	}
+
+	created, err := j.client.BatchV1().Jobs(ns).Create(ctx, retryJob(job), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retry of job %s: %w", name, err)
+	}
+	summary := summarizeJob(created)
+	return &summary, nil
+}
+
+// retryJob copies a Job for a fresh run. Owner references are dropped as well, so a retry
+// of a CronJob's Job does not count against the CronJob's history limits.
+func retryJob(job *batchv1.Job) *batchv1.Job {
+	// Leave room for the 5 character suffix within the 63 character job-name label limit
+	base := job.Name + "-retry-"
+	if len(base) > 58 {
+		base = base[:58]
+	}
+
+	retried := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: base,
+			Namespace:    job.Namespace,
+			Labels:       withoutControllerLabels(job.Labels),
+			Annotations:  map[string]string{},
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	for key, value := range job.Annotations {
+		if key != "kubectl.kubernetes.io/last-applied-configuration" {
+			retried.Annotations[key] = value
+		}
+	}
+	retried.Annotations[retryOfAnnotation] = job.Name
+
+	// The selector is generated from the controller-uid unless the user manages it
+	if retried.Spec.ManualSelector == nil || !*retried.Spec.ManualSelector {
+		retried.Spec.Selector = nil
+		retried.Spec.Template.Labels = withoutControllerLabels(retried.Spec.Template.Labels)
+	}
+	return retried
+}
+
+func withoutControllerLabels(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		out[key] = value
+	}
+	for _, key := range controllerLabels {
+		delete(out, key)
+	}
+	return out
+}
+
+// ContainerLog is the log of one container of a Job's pod
+type ContainerLog struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Phase     string `json:"phase"`
+	Previous  bool   `json:"previous"`
+	Log       string `json:"log"`
+	Error     string `json:"error,omitempty"`
+}
+
+// JobLogs collects the logs of every pod owned by the Job, oldest pod first. For containers
+// that are waiting to restart after a failure the log of the terminated run is returned.
+func (j *JobService) JobLogs(ctx context.Context, ns, name string, tailLines int64) ([]ContainerLog, error) {
+	job, err := j.getJob(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := j.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var owned []*corev1.Pod
+	for _, pod := range pods {
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.UID == job.UID {
+			owned = append(owned, pod)
+		}
+	}
+	sort.Slice(owned, func(i, k int) bool {
+		return owned[i].CreationTimestamp.Before(&owned[k].CreationTimestamp)
+	})
+
+	logs := []ContainerLog{}
+	for _, pod := range owned {
+		for _, status := range pod.Status.ContainerStatuses {
+			entry := ContainerLog{
+				Pod:       pod.Name,
+				Container: status.Name,
+				Phase:     string(pod.Status.Phase),
+				Previous:  status.State.Waiting != nil && status.LastTerminationState.Terminated != nil,
+			}
+			text, err := j.containerLog(ctx, ns, pod.Name, status.Name, entry.Previous, tailLines)
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.Log = text
+			logs = append(logs, entry)
+		}
+	}
+	return logs, nil
+}
+
+func (j *JobService) containerLog(ctx context.Context, ns, pod, container string, previous bool, tailLines int64) (string, error) {
+	options := &corev1.PodLogOptions{Container: container, Previous: previous}
+	if tailLines > 0 {
+		options.TailLines = &tailLines
+	}
+
+	stream, err := j.client.CoreV1().Pods(ns).GetLogs(pod, options).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	return string(data), err
+}
+
+func (j *JobService) getJob(ctx context.Context, ns, name string) (*batchv1.Job, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "job name"}
+	}
+
+	var job *batchv1.Job
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		job, err = j.client.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job %s: %w", name, err)
+	}
+	return job, nil
+}
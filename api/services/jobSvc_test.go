@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+// failedJob is a Job as the Job controller leaves it after it failed, with a generated selector
+func failedJob(name string) *batchv1.Job {
+	controllerLabels := map[string]string{
+		"controller-uid":                     "6f1d",
+		"batch.kubernetes.io/controller-uid": "6f1d",
+		"job-name":                           name,
+		"batch.kubernetes.io/job-name":       name,
+	}
+	templateLabels := map[string]string{"app": "migrate"}
+	for key, value := range controllerLabels {
+		templateLabels[key] = value
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			UID:             "6f1d",
+			ResourceVersion: "42",
+			Labels:          map[string]string{"app": "migrate", "controller-uid": "6f1d"},
+			Annotations: map[string]string{
+				"team":                             "data",
+				corev1.LastAppliedConfigAnnotation: `{"kind":"Job"}`,
+			},
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "CronJob", Name: "nightly", UID: "c0ff"}},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To[int32](2),
+			Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"batch.kubernetes.io/controller-uid": "6f1d"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: templateLabels},
+				Spec:       corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever, Containers: []corev1.Container{{Name: "migrate", Image: "migrate:1"}}},
+			},
+		},
+		Status: batchv1.JobStatus{
+			Failed:     3,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}},
+		},
+	}
+}
+
+func TestRetryJobSpec(t *testing.T) {
+	manual := failedJob("migrate")
+	manual.Spec.ManualSelector = ptr.To(true)
+	manual.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "migrate"}}
+
+	tests := []struct {
+		name               string
+		job                *batchv1.Job
+		wantGenerateName   string
+		wantSelector       *metav1.LabelSelector
+		wantTemplateLabels map[string]string
+	}{
+		{
+			name:               "generated selector",
+			job:                failedJob("migrate"),
+			wantGenerateName:   "migrate-retry-",
+			wantTemplateLabels: map[string]string{"app": "migrate"},
+		},
+		{
+			name:             "manual selector kept",
+			job:              manual,
+			wantGenerateName: "migrate-retry-",
+			wantSelector:     manual.Spec.Selector,
+			// Manually selected pods keep every template label the selector may match
+			wantTemplateLabels: manual.Spec.Template.Labels,
+		},
+		{
+			name:               "long name",
+			job:                failedJob(strings.Repeat("m", 60)),
+			wantGenerateName:   strings.Repeat("m", 58),
+			wantTemplateLabels: map[string]string{"app": "migrate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retried := retryJob(tt.job)
+
+			if retried.GenerateName != tt.wantGenerateName || retried.Name != "" {
+				t.Errorf("name = %q, generateName = %q, want generateName %q", retried.Name, retried.GenerateName, tt.wantGenerateName)
+			}
+			if retried.UID != "" || retried.ResourceVersion != "" || len(retried.OwnerReferences) != 0 {
+				t.Errorf("uid, resourceVersion or owner references copied: %+v", retried.ObjectMeta)
+			}
+			if !reflect.DeepEqual(retried.Status, batchv1.JobStatus{}) {
+				t.Errorf("status copied: %+v", retried.Status)
+			}
+			if want := map[string]string{"app": "migrate"}; !reflect.DeepEqual(retried.Labels, want) {
+				t.Errorf("labels = %v, want %v", retried.Labels, want)
+			}
+			if want := map[string]string{"team": "data", retryOfAnnotation: tt.job.Name}; !reflect.DeepEqual(retried.Annotations, want) {
+				t.Errorf("annotations = %v, want %v", retried.Annotations, want)
+			}
+			if !reflect.DeepEqual(retried.Spec.Selector, tt.wantSelector) {
+				t.Errorf("selector = %v, want %v", retried.Spec.Selector, tt.wantSelector)
+			}
+			if !reflect.DeepEqual(retried.Spec.Template.Labels, tt.wantTemplateLabels) {
+				t.Errorf("template labels = %v, want %v", retried.Spec.Template.Labels, tt.wantTemplateLabels)
+			}
+			if *retried.Spec.BackoffLimit != 2 || retried.Spec.Template.Spec.Containers[0].Image != "migrate:1" {
+				t.Errorf("spec not copied: %+v", retried.Spec)
+			}
+			if tt.job.Spec.Selector == nil || tt.job.Labels["controller-uid"] == "" {
+				t.Error("retryJob modified the failed job")
+			}
+		})
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	complete := failedJob("report")
+	complete.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+
+	tests := []struct {
+		name    string
+		job     string
+		wantErr func(error) bool
+	}{
+		{name: "failed", job: "migrate"},
+		{name: "complete", job: "report", wantErr: func(err error) bool { return errors.Is(err, ErrJobNotFailed) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(failedJob("migrate"), complete)
+			summary, err := NewJobService(client, nil).RetryJob(context.Background(), "default", tt.job)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("RetryJob() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.RetryOf != tt.job || summary.Status != "Running" {
+				t.Errorf("RetryJob() = %+v, want a running retry of %s", summary, tt.job)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+
+	"kgent-api/pkg/kustomize"
+	"kgent-api/pkg/manifest"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// BuildKustomization builds the kustomization in dir of files and returns the resources as
+// multi-document YAML. Build failures are returned as a *kustomize.BuildError. Without
+// WithKustomize, remote bases are refused.
+func (r *ResourceService) BuildKustomization(files []manifest.File, dir string) ([]byte, error) {
+	builder := r.kustomize
+	if builder == nil {
+		builder, _ = kustomize.NewBuilder(nil)
+	}
+
+	tree := make(map[string][]byte, len(files))
+	for _, file := range files {
+		tree[file.Path] = file.Data
+	}
+	out, err := builder.Build(tree, dir)
+	if errors.Is(err, kustomize.ErrInvalidPath) {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return out, err
+}
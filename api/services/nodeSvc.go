@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kgent-api/pkg/capacity"
+	"kgent-api/pkg/index"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	// legacyNodeRoleLabel is still set by some installers instead of the prefixed labels
+	legacyNodeRoleLabel = "kubernetes.io/role"
+)
+
+// nodeLabelsOfInterest are the labels describing where a node runs and what it is
+var nodeLabelsOfInterest = []string{
+	v1.LabelTopologyRegion,
+	v1.LabelTopologyZone,
+	v1.LabelInstanceTypeStable,
+	v1.LabelArchStable,
+	v1.LabelOSStable,
+	v1.LabelHostname,
+}
+
+type NodeService struct {
+	fact   informers.SharedInformerFactory
+	client kubernetes.Interface
+}
+
+func NewNodeService(fact informers.SharedInformerFactory, client kubernetes.Interface) *NodeService {
+	return &NodeService{fact: fact, client: client}
+}
+
+// NodeSummary is a row of kubectl get nodes -o wide
+type NodeSummary struct {
+	Name             string   `json:"name"`
+	Status           string   `json:"status"`
+	Roles            []string `json:"roles"`
+	Age              string   `json:"age"`
+	Version          string   `json:"version"`
+	InternalIP       string   `json:"internalIP,omitempty"`
+	ExternalIP       string   `json:"externalIP,omitempty"`
+	OSImage          string   `json:"osImage"`
+	KernelVersion    string   `json:"kernelVersion"`
+	ContainerRuntime string   `json:"containerRuntime"`
+}
+
+// NodeDetail is everything about one node, including what is scheduled on it
+type NodeDetail struct {
+	NodeSummary
+	Conditions []v1.NodeCondition `json:"conditions"`
+	// Pressure lists the pressure conditions that are true
+	Pressure    []string          `json:"pressure"`
+	Capacity    v1.ResourceList   `json:"capacity"`
+	Allocatable v1.ResourceList   `json:"allocatable"`
+	NodeInfo    v1.NodeSystemInfo `json:"nodeInfo"`
+	Taints      []v1.Taint        `json:"taints"`
+	Labels      map[string]string `json:"labels"`
+	Images      int               `json:"images"`
+	ImageBytes  int64             `json:"imageBytes"`
+	// Resources are the requests and limits of the node's pods against its allocatable
+	Resources capacity.NodeCapacity `json:"resources"`
+	Pods      []NodePod             `json:"pods"`
+}
+
+// NodePod is a pod scheduled on a node with what it requests
+type NodePod struct {
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Phase     v1.PodPhase        `json:"phase"`
+	Requests  capacity.Resources `json:"requests"`
+}
+
+// Nodes lists every node from the informer cache, sorted by name
+func (n *NodeService) Nodes() ([]NodeSummary, error) {
+	nodes, err := n.fact.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	summaries := make([]NodeSummary, 0, len(nodes))
+	for _, node := range nodes {
+		summaries = append(summaries, nodeSummary(node))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries, nil
+}
+
+// Node describes a node and the pods scheduled on it, found through the pod node index
+func (n *NodeService) Node(name string) (*NodeDetail, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "node name"}
+	}
+
+	node, err := n.fact.Core().V1().Nodes().Lister().Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", name, err)
+	}
+
+	objects, err := n.fact.Core().V1().Pods().Informer().GetIndexer().ByIndex(index.NodeIndex, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pods on node %s: %w", name, err)
+	}
+
+	detail := &NodeDetail{
+		NodeSummary: nodeSummary(node),
+		Conditions:  node.Status.Conditions,
+		Pressure:    []string{},
+		Capacity:    node.Status.Capacity,
+		Allocatable: node.Status.Allocatable,
+		NodeInfo:    node.Status.NodeInfo,
+		Taints:      node.Spec.Taints,
+		Labels:      map[string]string{},
+		Images:      len(node.Status.Images),
+		Pods:        []NodePod{},
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != v1.NodeReady && condition.Status == v1.ConditionTrue {
+			detail.Pressure = append(detail.Pressure, string(condition.Type))
+		}
+	}
+	for _, key := range nodeLabelsOfInterest {
+		if value, ok := node.Labels[key]; ok {
+			detail.Labels[key] = value
+		}
+	}
+	for _, image := range node.Status.Images {
+		detail.ImageBytes += image.SizeBytes
+	}
+
+	pods := make([]*v1.Pod, 0, len(objects))
+	for _, obj := range objects {
+		pod, ok := obj.(*v1.Pod)
+		if !ok {
+			continue
+		}
+		pods = append(pods, pod)
+		if capacity.IsTerminal(pod) {
+			continue
+		}
+		requests, _ := capacity.PodRequestsAndLimits(pod)
+		detail.Pods = append(detail.Pods, NodePod{Namespace: pod.Namespace, Name: pod.Name, Phase: pod.Status.Phase, Requests: requests})
+	}
+	sort.Slice(detail.Pods, func(i, j int) bool {
+		if detail.Pods[i].Namespace != detail.Pods[j].Namespace {
+			return detail.Pods[i].Namespace < detail.Pods[j].Namespace
+		}
+		return detail.Pods[i].Name < detail.Pods[j].Name
+	})
+
+	// Overcommitment isn't reported here, the capacity endpoint flags it against a threshold
+	report := capacity.Summarize([]*v1.Node{node}, pods, 100)
+	detail.Resources = report.Nodes[0]
+	return detail, nil
+}
+
+func nodeSummary(node *v1.Node) NodeSummary {
+	summary := NodeSummary{
+		Name:             node.Name,
+		Status:           nodeStatus(node),
+		Roles:            nodeRoles(node),
+		Age:              duration.HumanDuration(time.Since(node.CreationTimestamp.Time)),
+		Version:          node.Status.NodeInfo.KubeletVersion,
+		OSImage:          node.Status.NodeInfo.OSImage,
+		KernelVersion:    node.Status.NodeInfo.KernelVersion,
+		ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+	}
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case v1.NodeInternalIP:
+			if summary.InternalIP == "" {
+				summary.InternalIP = address.Address
+			}
+		case v1.NodeExternalIP:
+			if summary.ExternalIP == "" {
+				summary.ExternalIP = address.Address
+			}
+		}
+	}
+	return summary
+}
+
+// nodeStatus follows kubectl: the Ready condition, with SchedulingDisabled for cordoned nodes
+func nodeStatus(node *v1.Node) string {
+	status := "Unknown"
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != v1.NodeReady {
+			continue
+		}
+		switch condition.Status {
+		case v1.ConditionTrue:
+			status = "Ready"
+		case v1.ConditionFalse:
+			status = "NotReady"
+		}
+	}
+	if node.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+// nodeRoles derives roles from node-role.kubernetes.io/<role> labels and the legacy kubernetes.io/role
+func nodeRoles(node *v1.Node) []string {
+	roles := sets.New[string]()
+	for key, value := range node.Labels {
+		switch {
+		case strings.HasPrefix(key, nodeRoleLabelPrefix):
+			if role := strings.TrimPrefix(key, nodeRoleLabelPrefix); role != "" {
+				roles.Insert(role)
+			}
+		case key == legacyNodeRoleLabel && value != "":
+			roles.Insert(value)
+		}
+	}
+	return sets.List(roles)
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/refs"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// OrphanFinding is a resource that looks unused, with the reasons it was flagged
+type OrphanFinding struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Age       string   `json:"age"`
+	Reasons   []string `json:"reasons"`
+}
+
+// podRefIndex records which configmaps, secrets and claims pods reference in each namespace
+type podRefIndex struct {
+	configMaps map[string]sets.Set[string]
+	secrets    map[string]sets.Set[string]
+	claims     map[string]sets.Set[string]
+}
+
+func newPodRefIndex(pods []*v1.Pod) *podRefIndex {
+	idx := &podRefIndex{
+		configMaps: map[string]sets.Set[string]{},
+		secrets:    map[string]sets.Set[string]{},
+		claims:     map[string]sets.Set[string]{},
+	}
+	for _, pod := range pods {
+		podRefs := refs.PodSpec(&pod.Spec, refs.PodSpecPath)
+		insertRefs(idx.configMaps, pod.Namespace, refs.Names(podRefs, refs.KindConfigMap))
+		insertRefs(idx.secrets, pod.Namespace, refs.Names(podRefs, refs.KindSecret))
+		insertRefs(idx.claims, pod.Namespace, refs.Names(podRefs, refs.KindPersistentVolumeClaim))
+	}
+	return idx
+}
+
+func insertRefs(index map[string]sets.Set[string], ns string, names []string) {
+	if index[ns] == nil {
+		index[ns] = sets.New[string]()
+	}
+	index[ns].Insert(names...)
+}
+
+func (idx *podRefIndex) has(index map[string]sets.Set[string], ns, name string) bool {
+	return index[ns] != nil && index[ns].Has(name)
+}
+
+// Orphans finds resources that appear unused: empty old ReplicaSets, unreferenced
+// ConfigMaps/Secrets, unmounted PVCs and Services selecting no pods. Nothing is deleted.
+func (d *DiagnosticsService) Orphans(ns string, minReplicaSetAge time.Duration) ([]OrphanFinding, error) {
+	pods, err := d.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	idx := newPodRefIndex(pods)
+
+	findings := []OrphanFinding{}
+
+	replicaSets, err := d.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	for _, rs := range replicaSets {
+		rsAge := time.Since(rs.CreationTimestamp.Time)
+		if rs.Spec.Replicas == nil || *rs.Spec.Replicas != 0 || rs.Status.Replicas != 0 || rsAge < minReplicaSetAge {
+			continue
+		}
+		findings = append(findings, OrphanFinding{
+			Kind: "ReplicaSet", Namespace: rs.Namespace, Name: rs.Name, Age: age(rs.CreationTimestamp),
+			Reasons: []string{
+				"scaled to zero replicas with no running pods",
+				fmt.Sprintf("older than %s", minReplicaSetAge),
+			},
+		})
+	}
+
+	configMaps, err := d.fact.Core().V1().ConfigMaps().Lister().ConfigMaps(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	for _, cm := range configMaps {
+		// Published into every namespace by the control plane
+		if cm.Name == "kube-root-ca.crt" || idx.has(idx.configMaps, cm.Namespace, cm.Name) {
+			continue
+		}
+		findings = append(findings, OrphanFinding{
+			Kind: "ConfigMap", Namespace: cm.Namespace, Name: cm.Name, Age: age(cm.CreationTimestamp),
+			Reasons: []string{"not referenced by any pod volume, projected volume, envFrom or env valueFrom in the namespace"},
+		})
+	}
+
+	secrets, err := d.fact.Core().V1().Secrets().Lister().Secrets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range secrets {
+		// Token and Helm release secrets are consumed by the control plane and tooling, not pods
+		if secret.Type == v1.SecretTypeServiceAccountToken || secret.Type == "helm.sh/release.v1" {
+			continue
+		}
+		if idx.has(idx.secrets, secret.Namespace, secret.Name) {
+			continue
+		}
+		findings = append(findings, OrphanFinding{
+			Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name, Age: age(secret.CreationTimestamp),
+			Reasons: []string{"not referenced by any pod volume, projected volume, envFrom, env valueFrom or imagePullSecrets in the namespace"},
+		})
+	}
+
+	claims, err := d.fact.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistentvolumeclaims: %w", err)
+	}
+	for _, pvc := range claims {
+		if idx.has(idx.claims, pvc.Namespace, pvc.Name) {
+			continue
+		}
+		findings = append(findings, OrphanFinding{
+			Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, Age: age(pvc.CreationTimestamp),
+			Reasons: []string{
+				"not mounted by any pod in the namespace",
+				fmt.Sprintf("claim phase is %s", pvc.Status.Phase),
+			},
+		})
+	}
+
+	services, err := d.fact.Core().V1().Services().Lister().Services(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	for _, svc := range services {
+		// Services without a selector have manually managed endpoints
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		matched := false
+		for _, pod := range pods {
+			if pod.Namespace == svc.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		findings = append(findings, OrphanFinding{
+			Kind: "Service", Namespace: svc.Namespace, Name: svc.Name, Age: age(svc.CreationTimestamp),
+			Reasons: []string{fmt.Sprintf("selector %s matches no pods", selector.String())},
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Kind != findings[j].Kind {
+			return findings[i].Kind < findings[j].Kind
+		}
+		return findings[i].Name < findings[j].Name
+	})
+	return findings, nil
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxOwnerDepth stops the walk on ownerReference cycles, which the API server doesn't prevent
+const maxOwnerDepth = 10
+
+// errUncachedOwner is returned for owners whose kind has no informer, such as operators' custom resources
+var errUncachedOwner = errors.New("owner kind is not cached")
+
+// workloadResources maps the accepted spellings of a workload kind to its resource
+var workloadResources = map[string]string{
+	"deployment": "deployments", "deployments": "deployments", "deploy": "deployments",
+	"replicaset": "replicasets", "replicasets": "replicasets", "rs": "replicasets",
+	"statefulset": "statefulsets", "statefulsets": "statefulsets", "sts": "statefulsets",
+	"daemonset": "daemonsets", "daemonsets": "daemonsets", "ds": "daemonsets",
+	"job": "jobs", "jobs": "jobs",
+	"cronjob": "cronjobs", "cronjobs": "cronjobs", "cj": "cronjobs",
+}
+
+// OwnerLink is one controller in a pod's chain of owners
+type OwnerLink struct {
+	Kind string    `json:"kind"`
+	Name string    `json:"name"`
+	UID  types.UID `json:"uid"`
+	// Missing is set when the owner is still referenced but has been deleted
+	Missing bool `json:"missing,omitempty"`
+}
+
+// PodOwners is the chain of controllers above a pod, its direct owner first
+type PodOwners struct {
+	Pod       string      `json:"pod"`
+	Namespace string      `json:"namespace"`
+	Chain     []OwnerLink `json:"chain"`
+	// Owner is the top of the chain, the workload that ultimately owns the pod
+	Owner *OwnerLink `json:"owner,omitempty"`
+	Note  string     `json:"note,omitempty"`
+}
+
+// PodOwners walks controller ownerReferences from a pod up through ReplicaSets, Jobs and
+// CronJobs using the informer caches. A deleted owner ends the walk with a partial chain.
+func (w *WorkloadService) PodOwners(ns, name string) (*PodOwners, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	pod, err := w.fact.Core().V1().Pods().Lister().Pods(ns).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
+	}
+
+	owners := &PodOwners{Pod: pod.Name, Namespace: pod.Namespace, Chain: []OwnerLink{}}
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		owners.Note = "pod has no controller"
+		return owners, nil
+	}
+
+	for ref != nil && len(owners.Chain) < maxOwnerDepth {
+		link := OwnerLink{Kind: ref.Kind, Name: ref.Name, UID: ref.UID}
+		obj, err := w.cachedOwner(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind(), ns, ref.Name)
+
+		// A different UID means the owner was deleted and recreated under the same name
+		if apierrors.IsNotFound(err) || (err == nil && obj.GetUID() != ref.UID) {
+			link.Missing = true
+			owners.Chain = append(owners.Chain, link)
+			owners.Note = fmt.Sprintf("%s %s has been deleted, owners above it are unknown", ref.Kind, ref.Name)
+			break
+		}
+		if errors.Is(err, errUncachedOwner) {
+			owners.Chain = append(owners.Chain, link)
+			owners.Note = fmt.Sprintf("owners of %s %s are not followed", ref.Kind, ref.Name)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		owners.Chain = append(owners.Chain, link)
+		ref = metav1.GetControllerOf(obj)
+	}
+
+	owners.Owner = &owners.Chain[len(owners.Chain)-1]
+	return owners, nil
+}
+
+// cachedOwner gets a workload from its informer cache
+func (w *WorkloadService) cachedOwner(gk schema.GroupKind, ns, name string) (metav1.Object, error) {
+	var (
+		obj metav1.Object
+		err error
+	)
+	switch gk.String() {
+	case "ReplicaSet.apps":
+		obj, err = w.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(ns).Get(name)
+	case "Deployment.apps":
+		obj, err = w.fact.Apps().V1().Deployments().Lister().Deployments(ns).Get(name)
+	case "StatefulSet.apps":
+		obj, err = w.fact.Apps().V1().StatefulSets().Lister().StatefulSets(ns).Get(name)
+	case "DaemonSet.apps":
+		obj, err = w.fact.Apps().V1().DaemonSets().Lister().DaemonSets(ns).Get(name)
+	case "Job.batch":
+		obj, err = w.fact.Batch().V1().Jobs().Lister().Jobs(ns).Get(name)
+	case "CronJob.batch":
+		obj, err = w.fact.Batch().V1().CronJobs().Lister().CronJobs(ns).Get(name)
+	default:
+		return nil, errUncachedOwner
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// WorkloadPod is a pod owned by a workload
+type WorkloadPod struct {
+	Name      string          `json:"name"`
+	Phase     corev1.PodPhase `json:"phase"`
+	Ready     bool            `json:"ready"`
+	NodeName  string          `json:"nodeName,omitempty"`
+	Restarts  int32           `json:"restarts"`
+	StartTime *metav1.Time    `json:"startTime,omitempty"`
+	Owner     string          `json:"owner"`
+	ownerUID  types.UID
+}
+
+// RevisionPods are the pods of one ReplicaSet of a Deployment
+type RevisionPods struct {
+	Revision   string `json:"revision"`
+	ReplicaSet string `json:"replicaSet"`
+	// Current marks the revision the Deployment is rolling out to
+	Current  bool          `json:"current"`
+	Replicas int32         `json:"replicas"`
+	Ready    int           `json:"ready"`
+	Pods     []WorkloadPod `json:"pods"`
+}
+
+// WorkloadPods lists the pods a workload owns
+type WorkloadPods struct {
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace"`
+	Pods      []WorkloadPod `json:"pods,omitempty"`
+	// Revisions replace Pods when a Deployment's pods are grouped by revision, newest first
+	Revisions []RevisionPods `json:"revisions,omitempty"`
+	Note      string         `json:"note,omitempty"`
+}
+
+// WorkloadPods finds a workload's pods by its selector and keeps those whose controller is the
+// workload, or one of its ReplicaSets or Jobs, since selectors of different workloads may overlap.
+// With byRevision a Deployment's pods are grouped by the revision of their ReplicaSet.
+func (w *WorkloadService) WorkloadPods(kind, ns, name string, byRevision bool) (*WorkloadPods, error) {
+	resource, ok := workloadResources[strings.ToLower(kind)]
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported workload kind %q, expected one of deployments, replicasets, statefulsets, daemonsets, jobs, cronjobs", kind))
+	}
+	if byRevision && resource != "deployments" {
+		return nil, apierrors.NewBadRequest("byRevision is only supported for deployments")
+	}
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: resource + " name"}
+	}
+
+	workload, selector, err := w.cachedWorkload(resource, ns, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", resource, name, err)
+	}
+
+	// Deployments and CronJobs own their pods through ReplicaSets and Jobs
+	owners := map[types.UID]bool{workload.GetUID(): true}
+	var replicaSets []*appsv1.ReplicaSet
+	switch resource {
+	case "deployments":
+		all, err := w.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(ns).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list replicasets: %w", err)
+		}
+		for _, rs := range all {
+			if ref := metav1.GetControllerOf(rs); ref != nil && ref.UID == workload.GetUID() {
+				owners[rs.UID] = true
+				replicaSets = append(replicaSets, rs)
+			}
+		}
+	case "cronjobs":
+		jobs, err := w.fact.Batch().V1().Jobs().Lister().Jobs(ns).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if ref := metav1.GetControllerOf(job); ref != nil && ref.UID == workload.GetUID() {
+				owners[job.UID] = true
+			}
+		}
+	}
+
+	pods, err := w.fact.Core().V1().Pods().Lister().Pods(ns).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	result := &WorkloadPods{Kind: resource, Name: name, Namespace: ns, Pods: []WorkloadPod{}}
+	unowned := 0
+	for _, pod := range pods {
+		ref := metav1.GetControllerOf(pod)
+		if ref == nil || !owners[ref.UID] {
+			unowned++
+			continue
+		}
+		result.Pods = append(result.Pods, WorkloadPod{
+			Name:      pod.Name,
+			Phase:     pod.Status.Phase,
+			Ready:     podConditionTrue(pod, corev1.PodReady),
+			NodeName:  pod.Spec.NodeName,
+			Restarts:  podRestarts(pod),
+			StartTime: pod.Status.StartTime,
+			Owner:     ref.Kind + "/" + ref.Name,
+			ownerUID:  ref.UID,
+		})
+	}
+	sort.Slice(result.Pods, func(i, j int) bool { return result.Pods[i].Name < result.Pods[j].Name })
+
+	// Every pod in the namespace matches for CronJobs, so only over-matching selectors are reported
+	if unowned > 0 && resource != "cronjobs" {
+		result.Note = fmt.Sprintf("%d pods match the selector but are not owned by this %s", unowned, strings.TrimSuffix(resource, "s"))
+	}
+	if byRevision {
+		result.Revisions = groupByRevision(workload.GetAnnotations()[revisionAnnotation], replicaSets, result.Pods)
+		result.Pods = nil
+	}
+	return result, nil
+}
+
+// groupByRevision puts pods under the revision of their ReplicaSet. Every ReplicaSet gets a
+// group, so old revisions scaled to zero but not yet garbage collected still show up.
+func groupByRevision(current string, replicaSets []*appsv1.ReplicaSet, pods []WorkloadPod) []RevisionPods {
+	groups := make([]RevisionPods, 0, len(replicaSets))
+	index := make(map[types.UID]int, len(replicaSets))
+	for _, rs := range replicaSets {
+		revision := rs.Annotations[revisionAnnotation]
+		index[rs.UID] = len(groups)
+		groups = append(groups, RevisionPods{
+			Revision:   revision,
+			ReplicaSet: rs.Name,
+			Current:    revision != "" && revision == current,
+			Replicas:   rs.Status.Replicas,
+			Pods:       []WorkloadPod{},
+		})
+	}
+
+	for _, pod := range pods {
+		i, ok := index[pod.ownerUID]
+		if !ok {
+			continue
+		}
+		groups[i].Pods = append(groups[i].Pods, pod)
+		if pod.Ready {
+			groups[i].Ready++
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, _ := strconv.ParseInt(groups[i].Revision, 10, 64)
+		b, _ := strconv.ParseInt(groups[j].Revision, 10, 64)
+		return a > b
+	})
+	return groups
+}
+
+// cachedWorkload gets a workload and the selector of its pods from the informer caches.
+// CronJobs have no selector, their pods are found by owner alone.
+func (w *WorkloadService) cachedWorkload(resource, ns, name string) (metav1.Object, labels.Selector, error) {
+	var (
+		obj      metav1.Object
+		selector *metav1.LabelSelector
+	)
+	switch resource {
+	case "deployments":
+		d, err := w.fact.Apps().V1().Deployments().Lister().Deployments(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, selector = d, d.Spec.Selector
+	case "replicasets":
+		rs, err := w.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, selector = rs, rs.Spec.Selector
+	case "statefulsets":
+		sts, err := w.fact.Apps().V1().StatefulSets().Lister().StatefulSets(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, selector = sts, sts.Spec.Selector
+	case "daemonsets":
+		ds, err := w.fact.Apps().V1().DaemonSets().Lister().DaemonSets(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, selector = ds, ds.Spec.Selector
+	case "jobs":
+		job, err := w.fact.Batch().V1().Jobs().Lister().Jobs(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		obj, selector = job, job.Spec.Selector
+	case "cronjobs":
+		cj, err := w.fact.Batch().V1().CronJobs().Lister().CronJobs(ns).Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cj, labels.Everything(), nil
+	}
+
+	if selector == nil {
+		return obj, labels.Everything(), nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	return obj, s, nil
+}
+
+// podRestarts sums the restarts of a pod's containers
+func podRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+
+	"kgent-api/pkg/disruption"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+)
+
+type PDBService struct {
+	fact informers.SharedInformerFactory
+}
+
+func NewPDBService(fact informers.SharedInformerFactory) *PDBService {
+	return &PDBService{fact: fact}
+}
+
+// ListBudgets returns every PodDisruptionBudget in the namespace with the pods it covers
+func (p *PDBService) ListBudgets(ns string) ([]disruption.Budget, error) {
+	pdbs, err := p.fact.Policy().V1().PodDisruptionBudgets().Lister().PodDisruptionBudgets(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list poddisruptionbudgets: %w", err)
+	}
+
+	pods, err := p.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	budgets := make([]disruption.Budget, 0, len(pdbs))
+	for _, pdb := range pdbs {
+		budget, err := disruption.Resolve(pdb, pods)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, nil
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 
+	"kgent-api/pkg/clientcache"
+	"kgent-api/pkg/retry"
+
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -11,34 +14,160 @@ import (
 )
 
 type PodLogEventService struct {
-	client *kubernetes.Clientset
+	client kubernetes.Interface
+	// clients holds the clients of requests carrying a clientcache.Identity, when set
+	clients *clientcache.Cache
+}
+
+func NewPodLogEventService(client kubernetes.Interface, clients *clientcache.Cache) *PodLogEventService {
+	return &PodLogEventService{client: client, clients: clients}
+}
+
+// clientFor returns the client of the request's identity, or the shared client without one.
+// Log streams keep the returned client after it is evicted, see package clientcache.
+func (p *PodLogEventService) clientFor(ctx context.Context) (kubernetes.Interface, error) {
+	id, ok := clientcache.FromContext(ctx)
+	if !ok || p.clients == nil {
+		return p.client, nil
+	}
+	bundle, err := p.clients.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clients for request identity: %w", err)
+	}
+	return bundle.Clientset, nil
 }
 
-func NewPodLogEventService(client *kubernetes.Clientset) *PodLogEventService {
-	return &PodLogEventService{client: client}
+// defaultContainerAnnotation names the container kubectl picks when none is given
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// Container types reported by Containers
+const (
+	ContainerTypeInit      = "init"
+	ContainerTypeRegular   = "container"
+	ContainerTypeEphemeral = "ephemeral"
+)
+
+// PodContainer is a container of a pod to pick logs from
+type PodContainer struct {
+	Name string `json:"name"`
+	// Type is init, container or ephemeral
+	Type  string `json:"type"`
+	Image string `json:"image"`
+	// Default is the container logs are read from when none is given
+	Default      bool           `json:"default"`
+	Ready        bool           `json:"ready"`
+	RestartCount int32          `json:"restartCount"`
+	State        ContainerState `json:"state"`
 }
 
-func (p *PodLogEventService) GetLogs(ctx context.Context, ns, podname, container string, tailLine int64) (*rest.Request, error) {
+// GetLogs builds the log request of a container, returning the container it reads. Without a
+// container, the pod's default-container annotation is followed, or its first container picked.
+func (p *PodLogEventService) GetLogs(ctx context.Context, ns, podname, container string, tailLine int64) (*rest.Request, string, error) {
 	if podname == "" {
-		return nil, fmt.Errorf("pod name cannot be empty")
+		return nil, "", &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	client, err := p.clientFor(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if container == "" {
+		pod, err := p.getPod(ctx, client, ns, podname)
+		if err != nil {
+			return nil, "", err
+		}
+		container = defaultContainer(pod)
+	}
+
+	options := &v1.PodLogOptions{Follow: false, TailLines: &tailLine, Container: container}
+	return client.CoreV1().Pods(ns).GetLogs(podname, options), container, nil
+}
+
+// Containers lists the init, regular and ephemeral containers of a pod with their states
+func (p *PodLogEventService) Containers(ctx context.Context, ns, podname string) ([]PodContainer, error) {
+	if podname == "" {
+		return nil, &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	client, err := p.clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := p.getPod(ctx, client, ns, podname)
+	if err != nil {
+		return nil, err
 	}
 
-	// If container is empty, don't specify it in options to get logs from default container
-	options := &v1.PodLogOptions{Follow: false, TailLines: &tailLine}
-	if container != "" {
-		options.Container = container
+	statuses := map[string]v1.ContainerStatus{}
+	for _, list := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses} {
+		for _, status := range list {
+			statuses[status.Name] = status
+		}
 	}
+	defaultName := defaultContainer(pod)
 
-	req := p.client.CoreV1().Pods(ns).GetLogs(podname, options)
-	return req, nil
+	containers := make([]PodContainer, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers)+len(pod.Spec.EphemeralContainers))
+	add := func(name, image, containerType string) {
+		container := PodContainer{Name: name, Type: containerType, Image: image, Default: name == defaultName, State: ContainerState{State: "Waiting"}}
+		if status, ok := statuses[name]; ok {
+			container.Ready = status.Ready
+			container.RestartCount = status.RestartCount
+			container.State = containerState(status.State)
+		}
+		containers = append(containers, container)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Name, c.Image, ContainerTypeInit)
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Name, c.Image, ContainerTypeRegular)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		add(c.Name, c.Image, ContainerTypeEphemeral)
+	}
+	return containers, nil
+}
+
+func (p *PodLogEventService) getPod(ctx context.Context, client kubernetes.Interface, ns, podname string) (*v1.Pod, error) {
+	var pod *v1.Pod
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		pod, err = client.CoreV1().Pods(ns).Get(ctx, podname, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s: %w", podname, err)
+	}
+	return pod, nil
+}
+
+// defaultContainer follows kubectl: the default-container annotation when it names a
+// container of the pod, otherwise the first container
+func defaultContainer(pod *v1.Pod) string {
+	if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == name {
+				return name
+			}
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
 }
 
 func (p *PodLogEventService) GetEvents(ctx context.Context, ns, podname string) ([]string, error) {
 	if podname == "" {
-		return nil, fmt.Errorf("pod name cannot be empty")
+		return nil, &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	client, err := p.clientFor(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	events, err := p.client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{
+	events, err := client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{
 		FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Pod", podname),
 	})
 	if err != nil {
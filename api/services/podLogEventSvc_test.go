@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetLogs(t *testing.T) {
+	pod := testPod("default", "web-0")
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar"})
+	annotated := testPod("default", "web-1")
+	annotated.Spec.Containers = append(annotated.Spec.Containers, corev1.Container{Name: "sidecar"})
+	annotated.Annotations = map[string]string{defaultContainerAnnotation: "sidecar"}
+
+	tests := []struct {
+		name          string
+		pod           string
+		container     string
+		wantContainer string
+		wantErr       func(error) bool
+	}{
+		{name: "first container", pod: "web-0", wantContainer: "web"},
+		{name: "default container annotation", pod: "web-1", wantContainer: "sidecar"},
+		{name: "given container", pod: "web-0", container: "sidecar", wantContainer: "sidecar"},
+		// Without a container the pod is read to pick one
+		{name: "missing pod", pod: "web-9", wantErr: apierrors.IsNotFound},
+		{
+			name:    "empty pod name",
+			wantErr: func(err error) bool { var empty *EmptyArgumentError; return errors.As(err, &empty) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewPodLogEventService(fake.NewSimpleClientset(pod, annotated), nil)
+			req, container, err := svc.GetLogs(context.Background(), "default", tt.pod, tt.container, 100)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("GetLogs() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if container != tt.wantContainer {
+				t.Errorf("GetLogs() container = %q, want %q", container, tt.wantContainer)
+			}
+			if req == nil {
+				t.Error("GetLogs() returned no request")
+			}
+		})
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	event := func(name, podname, eventType, message string) runtime.Object {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: podname, Namespace: "default"},
+			Type:           eventType,
+			Message:        message,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    []string
+	}{
+		{
+			name: "warnings only",
+			objects: []runtime.Object{
+				event("e1", "web-0", corev1.EventTypeNormal, "Pulled image"),
+				event("e2", "web-0", corev1.EventTypeWarning, "Back-off restarting failed container"),
+			},
+			want: []string{"Back-off restarting failed container"},
+		},
+		{name: "no events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.objects...)
+			svc := NewPodLogEventService(client, nil)
+			got, err := svc.GetEvents(context.Background(), "default", "web-0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetEvents() = %q, want %q", got, tt.want)
+			}
+
+			// The fake clientset ignores field selectors, so check the one sent instead
+			var selector string
+			for _, action := range client.Actions() {
+				if list, ok := action.(k8stesting.ListAction); ok && action.GetResource().Resource == "events" {
+					selector = list.GetListRestrictions().Fields.String()
+				}
+			}
+			if want := "involvedObject.kind=Pod,involvedObject.name=web-0"; selector != want {
+				t.Errorf("events listed with field selector %q, want %q", selector, want)
+			}
+		})
+	}
+}
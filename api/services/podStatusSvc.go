@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kgent-api/pkg/retry"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ProbePassing = "Passing"
+	ProbeFailing = "Failing"
+	ProbeUnknown = "Unknown"
+
+	// maxSchedulingEvents bounds how many FailedScheduling messages are reported
+	maxSchedulingEvents = 5
+)
+
+type PodStatusService struct {
+	client kubernetes.Interface
+	fact   informers.SharedInformerFactory
+}
+
+func NewPodStatusService(client kubernetes.Interface, fact informers.SharedInformerFactory) *PodStatusService {
+	return &PodStatusService{client: client, fact: fact}
+}
+
+// PodStatus is everything a pod detail page shows about where a pod is and why
+type PodStatus struct {
+	Name           string             `json:"name"`
+	Namespace      string             `json:"namespace"`
+	Phase          v1.PodPhase        `json:"phase"`
+	Reason         string             `json:"reason,omitempty"`
+	Message        string             `json:"message,omitempty"`
+	Ready          bool               `json:"ready"`
+	QOSClass       v1.PodQOSClass     `json:"qosClass,omitempty"`
+	NodeName       string             `json:"nodeName,omitempty"`
+	PodIP          string             `json:"podIP,omitempty"`
+	StartTime      *metav1.Time       `json:"startTime,omitempty"`
+	Conditions     []v1.PodCondition  `json:"conditions"`
+	InitContainers []ContainerDetail  `json:"initContainers,omitempty"`
+	Containers     []ContainerDetail  `json:"containers"`
+	Taints         []NodeTaints       `json:"taints,omitempty"`
+	Scheduling     *SchedulingFailure `json:"scheduling,omitempty"`
+}
+
+// ContainerDetail is the state of one container and what its probes last reported
+type ContainerDetail struct {
+	Name            string          `json:"name"`
+	Image           string          `json:"image"`
+	Ready           bool            `json:"ready"`
+	Started         *bool           `json:"started,omitempty"`
+	RestartCount    int32           `json:"restartCount"`
+	State           ContainerState  `json:"state"`
+	LastTermination *ContainerState `json:"lastTermination,omitempty"`
+	Readiness       *ProbeResult    `json:"readiness,omitempty"`
+	Liveness        *ProbeResult    `json:"liveness,omitempty"`
+}
+
+// ContainerState flattens v1.ContainerState into the state it is in
+type ContainerState struct {
+	State      string       `json:"state"`
+	Reason     string       `json:"reason,omitempty"`
+	Message    string       `json:"message,omitempty"`
+	ExitCode   *int32       `json:"exitCode,omitempty"`
+	Signal     int32        `json:"signal,omitempty"`
+	StartedAt  *metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+}
+
+// ProbeResult is a probe's status inferred from readiness and Unhealthy events, as the
+// kubelet doesn't publish probe results directly
+type ProbeResult struct {
+	Status      string `json:"status"`
+	LastFailure string `json:"lastFailure,omitempty"`
+}
+
+// NodeTaints lists a node's taints and whether the pod tolerates each of them
+type NodeTaints struct {
+	Node   string       `json:"node"`
+	Taints []TaintMatch `json:"taints"`
+}
+
+type TaintMatch struct {
+	Key       string         `json:"key"`
+	Value     string         `json:"value,omitempty"`
+	Effect    v1.TaintEffect `json:"effect"`
+	Tolerated bool           `json:"tolerated"`
+}
+
+// SchedulingFailure explains why a pod has not been scheduled
+type SchedulingFailure struct {
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Events are the most recent FailedScheduling messages, newest first
+	Events []string `json:"events,omitempty"`
+}
+
+// PodStatus builds the status of a pod from the pod and node caches, with one events query
+// for probe failures and scheduling failures
+func (p *PodStatusService) PodStatus(ctx context.Context, ns, name string) (*PodStatus, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	pod, err := p.fact.Core().V1().Pods().Lister().Pods(ns).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
+	}
+
+	events, err := p.warningEvents(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &PodStatus{
+		Name:       pod.Name,
+		Namespace:  pod.Namespace,
+		Phase:      pod.Status.Phase,
+		Reason:     pod.Status.Reason,
+		Message:    pod.Status.Message,
+		Ready:      podConditionTrue(pod, v1.PodReady),
+		QOSClass:   pod.Status.QOSClass,
+		NodeName:   pod.Spec.NodeName,
+		PodIP:      pod.Status.PodIP,
+		StartTime:  pod.Status.StartTime,
+		Conditions: pod.Status.Conditions,
+		Scheduling: schedulingFailure(pod, events),
+	}
+	status.InitContainers = containerDetails(pod.Spec.InitContainers, pod.Status.InitContainerStatuses, events)
+	status.Containers = containerDetails(pod.Spec.Containers, pod.Status.ContainerStatuses, events)
+
+	status.Taints, err = p.taintMatches(pod)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// warningEvents lists the warning events of this pod, newest first. Events of an earlier
+// pod with the same name are left out by UID.
+func (p *PodStatusService) warningEvents(ctx context.Context, pod *v1.Pod) ([]v1.Event, error) {
+	var list *v1.EventList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		list, err = p.client.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Pod,type=Warning", pod.Name),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	events := make([]v1.Event, 0, len(list.Items))
+	for _, event := range list.Items {
+		if event.InvolvedObject.UID == pod.UID {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).After(eventTime(events[j]))
+	})
+	return events, nil
+}
+
+// taintMatches reports the taints of the pod's node, or of every tainted node while the
+// pod is unscheduled, so taints keeping it off nodes are visible
+func (p *PodStatusService) taintMatches(pod *v1.Pod) ([]NodeTaints, error) {
+	var nodes []*v1.Node
+	if pod.Spec.NodeName != "" {
+		node, err := p.fact.Core().V1().Nodes().Lister().Get(pod.Spec.NodeName)
+		if err != nil {
+			// The node may be gone already, the pod's status is still worth returning
+			return nil, nil
+		}
+		nodes = []*v1.Node{node}
+	} else {
+		all, err := p.fact.Core().V1().Nodes().Lister().List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		nodes = all
+	}
+
+	result := []NodeTaints{}
+	for _, node := range nodes {
+		if len(node.Spec.Taints) == 0 {
+			continue
+		}
+		entry := NodeTaints{Node: node.Name}
+		for i := range node.Spec.Taints {
+			taint := &node.Spec.Taints[i]
+			entry.Taints = append(entry.Taints, TaintMatch{
+				Key:       taint.Key,
+				Value:     taint.Value,
+				Effect:    taint.Effect,
+				Tolerated: toleratesTaint(pod.Spec.Tolerations, taint),
+			})
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Node < result[j].Node })
+	return result, nil
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulingFailure is set while the PodScheduled condition is false
+func schedulingFailure(pod *v1.Pod, events []v1.Event) *SchedulingFailure {
+	var failure *SchedulingFailure
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse {
+			failure = &SchedulingFailure{Reason: condition.Reason, Message: condition.Message}
+		}
+	}
+	if failure == nil {
+		return nil
+	}
+
+	for _, event := range events {
+		if event.Reason == "FailedScheduling" && len(failure.Events) < maxSchedulingEvents {
+			failure.Events = append(failure.Events, event.Message)
+		}
+	}
+	return failure
+}
+
+// containerDetails pairs container specs with their statuses, in spec order
+func containerDetails(containers []v1.Container, statuses []v1.ContainerStatus, events []v1.Event) []ContainerDetail {
+	byName := make(map[string]v1.ContainerStatus, len(statuses))
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+
+	details := make([]ContainerDetail, 0, len(containers))
+	for _, container := range containers {
+		status, ok := byName[container.Name]
+		detail := ContainerDetail{
+			Name:         container.Name,
+			Image:        container.Image,
+			Ready:        status.Ready,
+			Started:      status.Started,
+			RestartCount: status.RestartCount,
+			State:        ContainerState{State: "waiting"},
+		}
+		if ok {
+			detail.State = containerState(status.State)
+			if status.LastTerminationState.Terminated != nil {
+				last := containerState(status.LastTerminationState)
+				detail.LastTermination = &last
+			}
+		}
+
+		if container.ReadinessProbe != nil {
+			detail.Readiness = probeResult(container.ReadinessProbe, "Readiness", status, events)
+		}
+		if container.LivenessProbe != nil {
+			detail.Liveness = probeResult(container.LivenessProbe, "Liveness", status, events)
+		}
+		details = append(details, detail)
+	}
+	return details
+}
+
+func containerState(state v1.ContainerState) ContainerState {
+	switch {
+	case state.Running != nil:
+		return ContainerState{State: "running", StartedAt: &state.Running.StartedAt}
+	case state.Terminated != nil:
+		t := state.Terminated
+		return ContainerState{
+			State:      "terminated",
+			Reason:     t.Reason,
+			Message:    t.Message,
+			ExitCode:   &t.ExitCode,
+			Signal:     t.Signal,
+			StartedAt:  &t.StartedAt,
+			FinishedAt: &t.FinishedAt,
+		}
+	case state.Waiting != nil:
+		return ContainerState{State: "waiting", Reason: state.Waiting.Reason, Message: state.Waiting.Message}
+	}
+	return ContainerState{State: "waiting"}
+}
+
+// probeResult infers a probe's status. A container that isn't running has no probe result.
+// Readiness follows the container's ready flag; liveness is failing while the kubelet keeps
+// reporting failures within the window it counts before restarting the container.
+func probeResult(probe *v1.Probe, kind string, status v1.ContainerStatus, events []v1.Event) *ProbeResult {
+	running := status.State.Running
+	if running == nil {
+		return &ProbeResult{Status: ProbeUnknown}
+	}
+
+	result := &ProbeResult{Status: ProbePassing}
+	if failure, ok := lastProbeFailure(status.Name, kind, running.StartedAt.Time, events); ok {
+		result.LastFailure = failure.Message
+		window := time.Duration(probe.PeriodSeconds*probe.FailureThreshold) * time.Second
+		if kind == "Liveness" && time.Since(eventTime(failure)) <= window {
+			result.Status = ProbeFailing
+		}
+	}
+	if kind == "Readiness" && !status.Ready {
+		result.Status = ProbeFailing
+	}
+	return result
+}
+
+// lastProbeFailure finds the newest Unhealthy event of a container's probe since it started
+func lastProbeFailure(container, kind string, startedAt time.Time, events []v1.Event) (v1.Event, bool) {
+	fieldPath := fmt.Sprintf("{%s}", container)
+	for _, event := range events {
+		if event.Reason != "Unhealthy" || !strings.HasSuffix(event.InvolvedObject.FieldPath, fieldPath) ||
+			!strings.HasPrefix(event.Message, kind+" probe failed") {
+			continue
+		}
+		if eventTime(event).Before(startedAt) {
+			break
+		}
+		return event, true
+	}
+	return v1.Event{}, false
+}
+
+func podConditionTrue(pod *v1.Pod, conditionType v1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// conflict is the error of the API server for a write whose preconditions rv and uid, when
+// set, don't match the stored object
+func conflict(client *dynamicfake.FakeDynamicClient, gvr schema.GroupVersionResource, ns, name, rv, uid string) error {
+	stored, err := client.Tracker().Get(gvr, ns, name)
+	if err != nil {
+		return nil
+	}
+	obj := stored.(metav1.Object)
+	if (rv != "" && rv != obj.GetResourceVersion()) || (uid != "" && uid != string(obj.GetUID())) {
+		return apierrors.NewConflict(gvr.GroupResource(), name,
+			errors.New("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	return nil
+}
+
+// checkPreconditions makes client reject patches like the API server when the preconditions
+// they carry don't match the stored object, as the fake doesn't check them
+func checkPreconditions(client *dynamicfake.FakeDynamicClient) {
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(k8stesting.PatchAction)
+		var patched struct {
+			Metadata struct {
+				ResourceVersion string `json:"resourceVersion"`
+				UID             string `json:"uid"`
+			} `json:"metadata"`
+		}
+		// JSON patches carry their preconditions as operations
+		if patchAction.GetPatchType() == types.JSONPatchType {
+			var ops []struct {
+				Path  string `json:"path"`
+				Value string `json:"value"`
+			}
+			_ = json.Unmarshal(patchAction.GetPatch(), &ops)
+			for _, op := range ops {
+				switch op.Path {
+				case "/metadata/resourceVersion":
+					patched.Metadata.ResourceVersion = op.Value
+				case "/metadata/uid":
+					patched.Metadata.UID = op.Value
+				}
+			}
+		} else {
+			_ = json.Unmarshal(patchAction.GetPatch(), &patched)
+		}
+		err := conflict(client, action.GetResource(), action.GetNamespace(), patchAction.GetName(), patched.Metadata.ResourceVersion, patched.Metadata.UID)
+		return err != nil, nil, err
+	})
+}
+
+// preconditionClient checks the preconditions of deletes like the API server, as the fake
+// drops the delete options before its reactors see them
+type preconditionClient struct {
+	*dynamicfake.FakeDynamicClient
+}
+
+func (c preconditionClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return preconditionResource{ResourceInterface: c.FakeDynamicClient.Resource(gvr), client: c.FakeDynamicClient, gvr: gvr}
+}
+
+type preconditionResource struct {
+	dynamic.ResourceInterface
+	client *dynamicfake.FakeDynamicClient
+	gvr    schema.GroupVersionResource
+	ns     string
+}
+
+func (r preconditionResource) Namespace(ns string) dynamic.ResourceInterface {
+	r.ns = ns
+	r.ResourceInterface = r.client.Resource(r.gvr).Namespace(ns)
+	return r
+}
+
+func (r preconditionResource) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	if pre := opts.Preconditions; pre != nil {
+		var rv, uid string
+		if pre.ResourceVersion != nil {
+			rv = *pre.ResourceVersion
+		}
+		if pre.UID != nil {
+			uid = string(*pre.UID)
+		}
+		if err := conflict(r.client, r.gvr, r.ns, name, rv, uid); err != nil {
+			return err
+		}
+	}
+	return r.ResourceInterface.Delete(ctx, name, opts, subresources...)
+}
+
+func storedPod() *corev1.Pod {
+	pod := testPod("default", "web-0")
+	pod.ResourceVersion = "42"
+	pod.UID = "6f1d"
+	return pod
+}
+
+func TestDeleteResourcePreconditions(t *testing.T) {
+	tests := []struct {
+		name string
+		pre  Preconditions
+		// wantFailed is set when the delete fails its preconditions
+		wantFailed bool
+	}{
+		{name: "none"},
+		{name: "matching resource version", pre: Preconditions{ResourceVersion: "42"}},
+		{name: "matching uid", pre: Preconditions{UID: "6f1d"}},
+		{name: "matching both", pre: Preconditions{ResourceVersion: "42", UID: "6f1d"}},
+		{name: "stale resource version", pre: Preconditions{ResourceVersion: "41"}, wantFailed: true},
+		{name: "recreated object", pre: Preconditions{UID: "0c4e"}, wantFailed: true},
+		{name: "stale resource version, matching uid", pre: Preconditions{ResourceVersion: "41", UID: "6f1d"}, wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, storedPod())
+			svc := NewResourceService(testRESTMapper(), preconditionClient{client}, nil)
+
+			err := svc.DeleteResource(context.Background(), "pods", "default", "web-0", tt.pre)
+			if !tt.wantFailed {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var failed *PreconditionFailedError
+			if !errors.As(err, &failed) {
+				t.Fatalf("DeleteResource() error = %v, want a PreconditionFailedError", err)
+			}
+			if failed.ResourceVersion != "42" || failed.UID != "6f1d" {
+				t.Errorf("current version = %s/%s, want 42/6f1d", failed.ResourceVersion, failed.UID)
+			}
+			if !apierrors.IsConflict(err) {
+				t.Errorf("PreconditionFailedError hides the conflict: %v", err)
+			}
+		})
+	}
+}
+
+func TestPatchResourcePreconditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		patchType  types.PatchType
+		patch      string
+		pre        Preconditions
+		wantFailed bool
+	}{
+		{name: "merge patch", patchType: types.MergePatchType, patch: `{"metadata":{"labels":{"tier":"web"}}}`, pre: Preconditions{ResourceVersion: "42"}},
+		{name: "stale merge patch", patchType: types.MergePatchType, patch: `{"metadata":{"labels":{"tier":"web"}}}`, pre: Preconditions{ResourceVersion: "41"}, wantFailed: true},
+		{name: "stale strategic merge patch", patchType: types.StrategicMergePatchType, patch: `{"metadata":{"labels":{"tier":"web"}}}`, pre: Preconditions{UID: "0c4e"}, wantFailed: true},
+		{name: "json patch", patchType: types.JSONPatchType, patch: `[{"op":"add","path":"/metadata/labels","value":{"tier":"web"}}]`, pre: Preconditions{UID: "6f1d"}},
+		{name: "stale json patch", patchType: types.JSONPatchType, patch: `[{"op":"add","path":"/metadata/labels","value":{"tier":"web"}}]`, pre: Preconditions{ResourceVersion: "41"}, wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, client := newTestResourceService(t, []runtime.Object{storedPod()})
+			checkPreconditions(client)
+
+			obj, err := svc.PatchResource(context.Background(), "pods", "default", "web-0", tt.patchType, []byte(tt.patch), tt.pre)
+			if !tt.wantFailed {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if obj.GetLabels()["tier"] != "web" {
+					t.Errorf("labels = %v, want the patched tier label", obj.GetLabels())
+				}
+				return
+			}
+
+			var failed *PreconditionFailedError
+			if !errors.As(err, &failed) {
+				t.Fatalf("PatchResource() error = %v, want a PreconditionFailedError", err)
+			}
+			if failed.ResourceVersion != "42" || failed.UID != "6f1d" {
+				t.Errorf("current version = %s/%s, want 42/6f1d", failed.ResourceVersion, failed.UID)
+			}
+		})
+	}
+}
+
+func TestWithPreconditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		patchType types.PatchType
+		patch     string
+		pre       Preconditions
+		want      string
+	}{
+		{
+			name:      "merge patch",
+			patchType: types.MergePatchType,
+			patch:     `{"spec":{"replicas":3}}`,
+			pre:       Preconditions{ResourceVersion: "42", UID: "6f1d"},
+			want:      `{"metadata":{"resourceVersion":"42","uid":"6f1d"},"spec":{"replicas":3}}`,
+		},
+		{
+			name:      "merge patch setting metadata",
+			patchType: types.MergePatchType,
+			patch:     `{"metadata":{"labels":{"tier":"web"}}}`,
+			pre:       Preconditions{ResourceVersion: "42"},
+			want:      `{"metadata":{"labels":{"tier":"web"},"resourceVersion":"42"}}`,
+		},
+		{
+			name:      "null patch",
+			patchType: types.StrategicMergePatchType,
+			patch:     `null`,
+			pre:       Preconditions{UID: "6f1d"},
+			want:      `{"metadata":{"uid":"6f1d"}}`,
+		},
+		{
+			name:      "json patch",
+			patchType: types.JSONPatchType,
+			patch:     `[{"op":"remove","path":"/metadata/finalizers"}]`,
+			pre:       Preconditions{ResourceVersion: "42", UID: "6f1d"},
+			want:      `[{"op":"remove","path":"/metadata/finalizers"},{"op":"replace","path":"/metadata/resourceVersion","value":"42"},{"op":"replace","path":"/metadata/uid","value":"6f1d"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withPreconditions(tt.patchType, []byte(tt.patch), tt.pre)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotDoc, wantDoc interface{}
+			if err := json.Unmarshal(got, &gotDoc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantDoc); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotDoc, wantDoc) {
+				t.Errorf("withPreconditions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
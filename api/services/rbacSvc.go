@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kgent-api/pkg/rbac"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RBACService struct {
+	client kubernetes.Interface
+}
+
+func NewRBACService(client kubernetes.Interface) *RBACService {
+	return &RBACService{client: client}
+}
+
+// Subjects returns the users, groups and service accounts allowed to perform verb on resource.
+// resource may carry a group suffix and subresource, e.g. deployments.apps or pods/log.
+func (r *RBACService) Subjects(ctx context.Context, verb, resource, resourceName, namespace string) ([]rbac.Grant, error) {
+	if verb == "" || resource == "" {
+		return nil, &EmptyArgumentError{Argument: "verb and resource"}
+	}
+
+	resource, subresource, _ := strings.Cut(resource, "/")
+	groupResource := schema.ParseGroupResource(resource)
+
+	snapshot, err := r.snapshot(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := rbac.Subjects(snapshot, rbac.Request{
+		Verb:         verb,
+		APIGroup:     groupResource.Group,
+		Resource:     groupResource.Resource,
+		Subresource:  subresource,
+		ResourceName: resourceName,
+		Namespace:    namespace,
+	})
+	if grants == nil {
+		grants = []rbac.Grant{}
+	}
+	return grants, nil
+}
+
+func (r *RBACService) snapshot(ctx context.Context, namespace string) (rbac.Snapshot, error) {
+	var snapshot rbac.Snapshot
+
+	clusterRoles, err := r.client.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to list clusterroles: %w", err)
+	}
+	snapshot.ClusterRoles = clusterRoles.Items
+
+	clusterRoleBindings, err := r.client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to list clusterrolebindings: %w", err)
+	}
+	snapshot.ClusterRoleBindings = clusterRoleBindings.Items
+
+	if namespace == "" {
+		return snapshot, nil
+	}
+
+	roles, err := r.client.RbacV1().Roles(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to list roles: %w", err)
+	}
+	snapshot.Roles = roles.Items
+
+	roleBindings, err := r.client.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to list rolebindings: %w", err)
+	}
+	snapshot.RoleBindings = roleBindings.Items
+
+	return snapshot, nil
+}
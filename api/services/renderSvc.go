@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"kgent-api/pkg/render"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// RenderManifest expands the manifest template with values. With dryRun the rendered
+// object is also submitted as a server-side dry-run create to validate it against the cluster.
+func (r *ResourceService) RenderManifest(ctx context.Context, manifest string, values map[string]interface{}, dryRun bool) (string, error) {
+	if manifest == "" {
+		return "", &EmptyArgumentError{Argument: "template"}
+	}
+
+	rendered, err := render.Render(manifest, values)
+	if err != nil {
+		return "", err
+	}
+
+	if dryRun {
+		if err := r.dryRunCreate(ctx, rendered); err != nil {
+			return rendered, err
+		}
+	}
+	return rendered, nil
+}
+
+// dryRunCreate submits the manifest as a create with dryRun=All, resolving its resource from the object's kind
+func (r *ResourceService) dryRunCreate(ctx context.Context, manifest string) error {
+	obj := &unstructured.Unstructured{}
+	if _, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(manifest), nil, obj); err != nil {
+		return fmt.Errorf("failed to decode rendered YAML: %w", err)
+	}
+
+	gvk := obj.GroupVersionKind()
+	restMapping, err := (*r.restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get RESTMapping for %s: %w", gvk.String(), err)
+	}
+
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return err
+	}
+	opts := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+	if restMapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = "default"
+		}
+		_, err = client.Resource(restMapping.Resource).Namespace(ns).Create(ctx, obj, opts)
+	} else {
+		_, err = client.Resource(restMapping.Resource).Create(ctx, obj, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("dry-run create of %s %s failed: %w", gvk.Kind, obj.GetName(), err)
+	}
+	return nil
+}
@@ -2,34 +2,158 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
+	"kgent-api/pkg/clientcache"
+	"kgent-api/pkg/dyninformer"
+	"kgent-api/pkg/guard"
+	"kgent-api/pkg/kustomize"
+	"kgent-api/pkg/nsinformer"
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/policy"
+	"kgent-api/pkg/retry"
+	"kgent-api/pkg/warnings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 )
 
 type ResourceService struct {
 	restMapper *meta.RESTMapper
-	client     *dynamic.DynamicClient
+	client     dynamic.Interface
 	fact       informers.SharedInformerFactory
+	policy     *policy.Evaluator
+	config     *rest.Config
+	// apiResources caches discovery so unsupported verbs fail before reaching the API server
+	apiResources *apiResourceCache
+	openAPI      *openAPISchemaCache
+	// dynamicInformers are informers for any resource started through the admin API
+	dynamicInformers *dyninformer.Registry
+	// shards cache the namespaced resources per namespace when set
+	shards *nsinformer.Registry
+	// shardFanOut serves all-namespace lists from the running shards rather than the API server
+	shardFanOut bool
+	// clients holds the clients of requests carrying a clientcache.Identity
+	clients *clientcache.Cache
+	// scope limits the namespaces and cluster-scoped resources requests may touch
+	scope *nsscope.Scope
+	// kustomize builds uploaded kustomizations
+	kustomize *kustomize.Builder
+}
+
+type ResourceServiceOptionFunc func(r *ResourceService)
+
+// WithPolicy enables policy evaluation before objects are written to the cluster
+func WithPolicy(evaluator *policy.Evaluator) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.policy = evaluator
+	}
+}
+
+// WithRequestWarnings makes API calls collect server warnings into the request's
+// warnings.Collector, using clients derived from config
+func WithRequestWarnings(config *rest.Config) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.config = config
+	}
 }
 
-func NewResourceService(restMapper *meta.RESTMapper, client *dynamic.DynamicClient, fact informers.SharedInformerFactory) *ResourceService {
-	return &ResourceService{restMapper: restMapper, client: client, fact: fact}
+// WithClientCache makes requests carrying a clientcache.Identity act as that identity,
+// with clients from cache
+func WithClientCache(cache *clientcache.Cache) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.clients = cache
+	}
+}
+
+// WithDiscovery checks operations against the verbs discovery reports for each resource
+// and enables manifest validation against the published OpenAPI v3 schemas
+func WithDiscovery(client discovery.DiscoveryInterface) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.apiResources = newAPIResourceCache(client)
+		r.openAPI = newOpenAPISchemaCache(client.OpenAPIV3())
+	}
+}
+
+// WithNamespaceScope rejects requests for namespaces outside scope, and for cluster-scoped
+// resources unless it allows them, and filters all-namespace lists down to scope
+func WithNamespaceScope(scope *nsscope.Scope) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.scope = scope
+	}
+}
+
+// WithKustomize builds uploaded kustomizations with builder, which decides the remote bases
+// they may use
+func WithKustomize(builder *kustomize.Builder) ResourceServiceOptionFunc {
+	return func(r *ResourceService) {
+		r.kustomize = builder
+	}
+}
+
+func NewResourceService(restMapper *meta.RESTMapper, client dynamic.Interface, fact informers.SharedInformerFactory, optfuncs ...ResourceServiceOptionFunc) *ResourceService {
+	r := &ResourceService{restMapper: restMapper, client: client, fact: fact}
+	for _, optfunc := range optfuncs {
+		optfunc(r)
+	}
+	return r
 }
 
 func (r *ResourceService) ListResource(ctx context.Context, resourceOrKindArg string, ns string) ([]runtime.Object, error) {
-	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err := r.checkVerb(resourceOrKindArg, "list"); err != nil {
+		return nil, err
+	}
+
+	restMapper, err := r.restMapperFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	restMapping, err := r.mappingFor(resourceOrKindArg, restMapper)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		return nil, err
+	}
+	objects, err := r.listObjectsFor(ctx, resourceOrKindArg, restMapping, ns)
 	if err != nil {
 		return nil, err
 	}
+	return r.inScope(objects), nil
+}
+
+func (r *ResourceService) listObjectsFor(ctx context.Context, resourceOrKindArg string, restMapping *meta.RESTMapping, ns string) ([]runtime.Object, error) {
+	// Informer caches hold everything the server can see, so identified requests list as the caller
+	if r.identified(ctx) {
+		return r.listAsCaller(ctx, restMapping, ns)
+	}
+
+	// Prefer an informer started at runtime, which also covers custom resources
+	if r.dynamicInformers != nil {
+		if lister, ok := r.dynamicInformers.Lister(restMapping.Resource, ns); ok {
+			if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+				return lister.List(labels.Everything())
+			}
+			return lister.ByNamespace(ns).List(labels.Everything())
+		}
+	}
+
+	if r.shards != nil && r.shards.Caches(restMapping.Resource) {
+		return r.shardedObjects(ctx, restMapping, ns)
+	}
 
 	informer, err := r.fact.ForResource(restMapping.Resource)
 	if err != nil {
@@ -44,32 +168,274 @@ func (r *ResourceService) ListResource(ctx context.Context, resourceOrKindArg st
 	return list, nil
 }
 
-func (r *ResourceService) DeleteResource(ctx context.Context, resourceOrKindArg string, ns string, name string) error {
+// Preconditions make a write apply only while the object is still at ResourceVersion or is still
+// the object with UID, for optimistic concurrency. Empty fields are not checked.
+type Preconditions struct {
+	ResourceVersion string
+	UID             types.UID
+}
+
+func (p Preconditions) set() bool {
+	return p.ResourceVersion != "" || p.UID != ""
+}
+
+// PreconditionFailedError is returned when the object no longer matches the preconditions of
+// a write, with the object's current resource version and UID
+type PreconditionFailedError struct {
+	ResourceVersion string
+	UID             types.UID
+	Err             error
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("precondition failed: %v", e.Err)
+}
+
+func (e *PreconditionFailedError) Unwrap() error {
+	return e.Err
+}
+
+// preconditionFailed turns the conflict of a write with preconditions into a PreconditionFailedError
+// carrying the current object's version. Other errors are returned unchanged.
+func preconditionFailed(ctx context.Context, ri dynamic.ResourceInterface, name string, pre Preconditions, err error) error {
+	if !pre.set() || !apierrors.IsConflict(err) {
+		return err
+	}
+	failed := &PreconditionFailedError{Err: err}
+	if current, getErr := ri.Get(ctx, name, metav1.GetOptions{}); getErr == nil {
+		failed.ResourceVersion = current.GetResourceVersion()
+		failed.UID = current.GetUID()
+	}
+	return failed
+}
+
+func (r *ResourceService) DeleteResource(ctx context.Context, resourceOrKindArg string, ns string, name string, pre Preconditions) error {
 	if name == "" {
-		return fmt.Errorf("resource name cannot be empty")
+		return &EmptyArgumentError{Argument: "resource name"}
 	}
 
-	ri, err := r.getResourceInterface(resourceOrKindArg, ns, r.client, r.restMapper)
-	if err != nil {
+	if err := r.checkVerb(resourceOrKindArg, "delete"); err != nil {
 		return err
 	}
 
-	err = ri.Delete(ctx, name, metav1.DeleteOptions{})
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
 	if err != nil {
+		return err
+	}
+
+	opts := metav1.DeleteOptions{}
+	if pre.set() {
+		opts.Preconditions = &metav1.Preconditions{}
+		if pre.ResourceVersion != "" {
+			opts.Preconditions.ResourceVersion = &pre.ResourceVersion
+		}
+		if pre.UID != "" {
+			opts.Preconditions.UID = &pre.UID
+		}
+	}
+
+	// Deletes are idempotent: a not found after a retried attempt means an earlier attempt succeeded
+	err = retry.Do(ctx, "delete", func(attempt int) error {
+		err := ri.Delete(ctx, name, opts)
+		if attempt > 1 && apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	if err = preconditionFailed(ctx, ri, name, pre, err); err != nil {
 		return fmt.Errorf("failed to delete %s/%s: %w", resourceOrKindArg, name, err)
 	}
 	return nil
 }
 
-func (r *ResourceService) CreateResource(ctx context.Context, resourceOrKindArg string, yaml string) error {
+// Where GetResource read an object from
+const (
+	ServedFromCache = "cache"
+	ServedFromAPI   = "api"
+)
+
+// GetResource fetches a single object by name, from a synced informer cache when one holds the
+// resource and from the API server otherwise or when live is set. Cached objects can lag
+// behind the server and may have managed fields stripped. It reports where the object came from.
+func (r *ResourceService) GetResource(ctx context.Context, resourceOrKindArg string, ns string, name string, live bool) (*unstructured.Unstructured, string, error) {
+	if name == "" {
+		return nil, "", &EmptyArgumentError{Argument: "resource name"}
+	}
+
+	if err := r.checkVerb(resourceOrKindArg, "get"); err != nil {
+		return nil, "", err
+	}
+
+	// Informer caches hold everything the server can see, so identified requests read as the caller
+	if !live && !r.identified(ctx) {
+		obj, ok, err := r.cachedResource(resourceOrKindArg, ns, name)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get %s/%s: %w", resourceOrKindArg, name, err)
+		}
+		if ok {
+			return obj, ServedFromCache, nil
+		}
+	}
+
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var obj *unstructured.Unstructured
+	err = retry.Do(ctx, "get", func(int) (err error) {
+		obj, err = ri.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get %s/%s: %w", resourceOrKindArg, name, err)
+	}
+	return obj, ServedFromAPI, nil
+}
+
+// cachedResource looks an object up in a synced runtime or shared informer for its resource.
+// It reports false when no such informer exists, leaving the lookup to the API server.
+func (r *ResourceService) cachedResource(resourceOrKindArg string, ns string, name string) (*unstructured.Unstructured, bool, error) {
+	// Unknown resources are reported by the API server path
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return nil, false, nil
+	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		return nil, false, err
+	}
+	namespaced := restMapping.Scope.Name() == meta.RESTScopeNameNamespace
+
+	var lister cache.GenericLister
+	if r.dynamicInformers != nil {
+		lister, _ = r.dynamicInformers.Lister(restMapping.Resource, ns)
+	}
+	if lister == nil && r.shards != nil && r.shards.Caches(restMapping.Resource) {
+		// Reads don't start shards, namespaces without one are read from the API server
+		if lister, _ = r.shards.Lister(restMapping.Resource, ns); lister == nil {
+			return nil, false, nil
+		}
+	}
+	if lister == nil {
+		informer, err := r.fact.ForResource(restMapping.Resource)
+		if err != nil || !informer.Informer().HasSynced() {
+			return nil, false, nil
+		}
+		lister = informer.Lister()
+	}
+
+	var cached runtime.Object
+	if namespaced {
+		cached, err = lister.ByNamespace(ns).Get(name)
+	} else {
+		cached, err = lister.Get(name)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Typed objects are copied out of the cache and lose their apiVersion and kind on the way in
+	if u, ok := cached.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), true, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cached)
+	if err != nil {
+		return nil, false, err
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	obj.SetGroupVersionKind(restMapping.GroupVersionKind)
+	return obj, true, nil
+}
+
+// PatchResource applies a JSON, merge or strategic merge patch to a single object. Patches
+// have no preconditions option, so they are written into the patch: the API server then
+// rejects it with a conflict unless the object still has that resourceVersion and UID.
+func (r *ResourceService) PatchResource(ctx context.Context, resourceOrKindArg string, ns string, name string, patchType types.PatchType, patch []byte, pre Preconditions) (*unstructured.Unstructured, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "resource name"}
+	}
+	if len(patch) == 0 {
+		return nil, &EmptyArgumentError{Argument: "patch body"}
+	}
+
+	if err := r.checkVerb(resourceOrKindArg, "patch"); err != nil {
+		return nil, err
+	}
+
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	if pre.set() {
+		if patch, err = withPreconditions(patchType, patch, pre); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid patch: %v", err))
+		}
+	}
+
+	obj, err := ri.Patch(ctx, name, patchType, patch, metav1.PatchOptions{})
+	if err = preconditionFailed(ctx, ri, name, pre, err); err != nil {
+		return nil, fmt.Errorf("failed to patch %s/%s: %w", resourceOrKindArg, name, err)
+	}
+	return obj, nil
+}
+
+// withPreconditions adds the preconditions to the object metadata the patch sets
+func withPreconditions(patchType types.PatchType, patch []byte, pre Preconditions) ([]byte, error) {
+	if patchType == types.JSONPatchType {
+		var ops []interface{}
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return nil, err
+		}
+		if pre.ResourceVersion != "" {
+			ops = append(ops, map[string]interface{}{"op": "replace", "path": "/metadata/resourceVersion", "value": pre.ResourceVersion})
+		}
+		if pre.UID != "" {
+			ops = append(ops, map[string]interface{}{"op": "replace", "path": "/metadata/uid", "value": string(pre.UID)})
+		}
+		return json.Marshal(ops)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(patch, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	if pre.ResourceVersion != "" {
+		if err := unstructured.SetNestedField(doc, pre.ResourceVersion, "metadata", "resourceVersion"); err != nil {
+			return nil, err
+		}
+	}
+	if pre.UID != "" {
+		if err := unstructured.SetNestedField(doc, string(pre.UID), "metadata", "uid"); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// CreateResource creates the object described by yaml. When policy runs in warn-only
+// mode the violations are returned alongside a successful create.
+func (r *ResourceService) CreateResource(ctx context.Context, resourceOrKindArg string, yaml string) ([]policy.Violation, error) {
 	if yaml == "" {
-		return fmt.Errorf("YAML content cannot be empty")
+		return nil, &EmptyArgumentError{Argument: "YAML content"}
+	}
+
+	if err := r.checkVerb(resourceOrKindArg, "create"); err != nil {
+		return nil, err
 	}
 
 	obj := &unstructured.Unstructured{}
 	_, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(yaml), nil, obj)
 	if err != nil {
-		return fmt.Errorf("failed to decode YAML: %w", err)
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+
+	violations, err := r.evaluatePolicy(obj)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set default namespace if not specified in the YAML
@@ -79,21 +445,47 @@ func (r *ResourceService) CreateResource(ctx context.Context, resourceOrKindArg
 		obj.SetNamespace(namespace)
 	}
 
-	ri, err := r.getResourceInterface(resourceOrKindArg, namespace, r.client, r.restMapper)
+	ri, err := r.getResourceInterface(ctx, resourceOrKindArg, namespace)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	_, err = ri.Create(ctx, obj, metav1.CreateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to create %s: %w", resourceOrKindArg, err)
+		return nil, fmt.Errorf("failed to create %s: %w", resourceOrKindArg, err)
 	}
-	return nil
+	return violations, nil
 }
 
-func (r *ResourceService) GetGVR(resourceOrKindArg string) (*schema.GroupVersionResource, error) {
+// evaluatePolicy runs the configured policy checks, returning violations as warnings
+// in warn-only mode and as a *policy.ViolationError otherwise
+func (r *ResourceService) evaluatePolicy(obj *unstructured.Unstructured) ([]policy.Violation, error) {
+	violations := r.policy.Evaluate(obj)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	if r.policy.WarnOnly() {
+		return violations, nil
+	}
+	return nil, &policy.ViolationError{Violations: violations}
+}
+
+// ResolvedResource is the full mapping of a resource or kind argument
+type ResolvedResource struct {
+	GVR          schema.GroupVersionResource `json:"gvr"`
+	GVK          schema.GroupVersionKind     `json:"gvk"`
+	Scope        string                      `json:"scope"`
+	Namespaced   bool                        `json:"namespaced"`
+	SingularName string                      `json:"singularName"`
+	ShortNames   []string                    `json:"shortNames"`
+	Verbs        []string                    `json:"verbs"`
+}
+
+// ResolveResource maps a resource or kind argument such as "deploy" or "Deployment.apps"
+// to its GVR and GVK, completing names and verbs from discovery when available
+func (r *ResourceService) ResolveResource(resourceOrKindArg string) (*ResolvedResource, error) {
 	if resourceOrKindArg == "" {
-		return nil, fmt.Errorf("resource type cannot be empty")
+		return nil, &EmptyArgumentError{Argument: "resource type"}
 	}
 
 	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
@@ -101,17 +493,125 @@ func (r *ResourceService) GetGVR(resourceOrKindArg string) (*schema.GroupVersion
 		return nil, err
 	}
 
-	return &restMapping.Resource, nil
+	resolved := &ResolvedResource{
+		GVR:        restMapping.Resource,
+		GVK:        restMapping.GroupVersionKind,
+		Scope:      string(restMapping.Scope.Name()),
+		Namespaced: restMapping.Scope.Name() == meta.RESTScopeNameNamespace,
+		ShortNames: []string{},
+		Verbs:      []string{},
+	}
+
+	if r.apiResources != nil {
+		if info, ok := r.apiResources.lookup(restMapping.Resource); ok {
+			resolved.SingularName = info.SingularName
+			if info.ShortNames != nil {
+				resolved.ShortNames = info.ShortNames
+			}
+			resolved.Verbs = info.Verbs
+		}
+	}
+	if resolved.SingularName == "" {
+		resolved.SingularName, _ = (*r.restMapper).ResourceSingularizer(restMapping.Resource.Resource)
+	}
+	return resolved, nil
+}
+
+// dynamicClient returns a client whose server warnings are collected for the current request,
+// falling back to the shared client when the request carries no warnings.Collector. Requests
+// carrying a clientcache.Identity get that identity's client and never the shared one.
+func (r *ResourceService) dynamicClient(ctx context.Context) (dynamic.Interface, error) {
+	client, config := r.client, r.config
+	if id, ok := clientcache.FromContext(ctx); ok && r.clients != nil {
+		bundle, err := r.clients.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clients for request identity: %w", err)
+		}
+		client, config = bundle.Dynamic, bundle.Config
+	}
+
+	collector := warnings.FromContext(ctx)
+	if collector == nil || config == nil {
+		return client, nil
+	}
+
+	// Only the dynamic client is rebuilt, client-go reuses the transport of config
+	config = rest.CopyConfig(config)
+	config.WarningHandler = collector
+	warningClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return client, nil
+	}
+	return warningClient, nil
+}
+
+// restMapperFor returns the REST mapper of the request's identity, which discovers resources
+// as that identity, or the shared mapper for requests carrying no clientcache.Identity
+func (r *ResourceService) restMapperFor(ctx context.Context) (*meta.RESTMapper, error) {
+	if id, ok := clientcache.FromContext(ctx); ok && r.clients != nil {
+		bundle, err := r.clients.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clients for request identity: %w", err)
+		}
+		return &bundle.RESTMapper, nil
+	}
+	return r.restMapper, nil
+}
+
+// identified reports whether the request acts as its own identity rather than as the server
+func (r *ResourceService) identified(ctx context.Context) bool {
+	_, ok := clientcache.FromContext(ctx)
+	return ok && r.clients != nil
+}
+
+// listAsCaller lists from the API server with the request identity's client
+func (r *ResourceService) listAsCaller(ctx context.Context, restMapping *meta.RESTMapping, ns string) ([]runtime.Object, error) {
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ri dynamic.ResourceInterface = client.Resource(restMapping.Resource)
+	if restMapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ri = client.Resource(restMapping.Resource).Namespace(ns)
+	}
+
+	var list *unstructured.UnstructuredList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		list, err = ri.List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s resources: %w", restMapping.Resource.Resource, err)
+	}
+
+	objects := make([]runtime.Object, 0, len(list.Items))
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, nil
 }
 
 // getResourceInterface returns the appropriate dynamic resource interface based on the resource type and namespace
-func (r *ResourceService) getResourceInterface(resourceOrKindArg string, ns string, client dynamic.Interface, restMapper *meta.RESTMapper) (dynamic.ResourceInterface, error) {
+func (r *ResourceService) getResourceInterface(ctx context.Context, resourceOrKindArg string, ns string) (dynamic.ResourceInterface, error) {
 	var ri dynamic.ResourceInterface
 
+	restMapper, err := r.restMapperFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	restMapping, err := r.mappingFor(resourceOrKindArg, restMapper)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get RESTMapping for %s: %w", resourceOrKindArg, err)
 	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		return nil, err
+	}
+
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Determine if resource is namespaced or cluster-scoped
 	if restMapping.Scope.Name() == "namespace" {
@@ -123,10 +623,51 @@ func (r *ResourceService) getResourceInterface(resourceOrKindArg string, ns stri
 	return ri, nil
 }
 
+// GuardedOperation describes a destructive action on objects for the guard rails, naming the
+// resource the same way however it was requested. Cluster-scoped objects are in no namespace.
+func (r *ResourceService) GuardedOperation(action string, resourceOrKindArg string, ns string, names []string) (guard.Operation, error) {
+	restMapping, err := r.mappingFor(resourceOrKindArg, r.restMapper)
+	if err != nil {
+		return guard.Operation{}, err
+	}
+	op := guard.Operation{Action: action, Resource: restMapping.Resource.GroupResource().String(), Names: names}
+	if restMapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		op.Namespace = ns
+	}
+	return op, nil
+}
+
+// checkScope rejects cluster-scoped resources and namespaces outside the namespace scope with
+// a Forbidden error. An empty namespace lists every namespace, filtered with inScope.
+func (r *ResourceService) checkScope(restMapping *meta.RESTMapping, ns string) error {
+	resource := restMapping.Resource.GroupResource()
+	if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		if !r.scope.ClusterScoped() {
+			return apierrors.NewForbidden(resource, "", fmt.Errorf("cluster-scoped resources are disabled while the server is limited to %s", r.scope))
+		}
+		return nil
+	}
+	if ns != "" && !r.scope.Allows(ns) {
+		return apierrors.NewForbidden(resource, "", fmt.Errorf("namespace %s is outside the namespaces the server is limited to: %s", ns, r.scope))
+	}
+	return nil
+}
+
+// inScope drops the objects in namespaces outside the namespace scope
+func (r *ResourceService) inScope(objects []runtime.Object) []runtime.Object {
+	return nsscope.Filter(r.scope, objects, func(obj runtime.Object) string {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return ""
+		}
+		return accessor.GetNamespace()
+	})
+}
+
 // mappingFor finds the REST mapping for a resource
 func (r *ResourceService) mappingFor(resourceOrKindArg string, restMapper *meta.RESTMapper) (*meta.RESTMapping, error) {
 	if resourceOrKindArg == "" {
-		return nil, fmt.Errorf("resource type cannot be empty")
+		return nil, &EmptyArgumentError{Argument: "resource type"}
 	}
 
 	fullySpecifiedGVR, groupResource := schema.ParseResourceArg(resourceOrKindArg)
@@ -136,7 +677,10 @@ func (r *ResourceService) mappingFor(resourceOrKindArg string, restMapper *meta.
 		gvk, _ = (*restMapper).KindFor(*fullySpecifiedGVR)
 	}
 	if gvk.Empty() {
-		gvk, _ = (*restMapper).KindFor(groupResource.WithVersion(""))
+		var err error
+		if gvk, err = (*restMapper).KindFor(groupResource.WithVersion("")); meta.IsAmbiguousError(err) {
+			return nil, ambiguousResource(resourceOrKindArg, err)
+		}
 	}
 	if !gvk.Empty() {
 		return (*restMapper).RESTMapping(gvk.GroupKind(), gvk.Version)
@@ -157,7 +701,10 @@ func (r *ResourceService) mappingFor(resourceOrKindArg string, restMapper *meta.
 	mapping, err := (*restMapper).RESTMapping(groupKind, gvk.Version)
 	if err != nil {
 		if meta.IsNoMatchError(err) {
-			return nil, fmt.Errorf("the server doesn't have a resource type %q", groupResource.Resource)
+			return nil, &UnknownResourceError{Resource: groupResource.Resource}
+		}
+		if meta.IsAmbiguousError(err) {
+			return nil, ambiguousResource(resourceOrKindArg, err)
 		}
 		return nil, err
 	}
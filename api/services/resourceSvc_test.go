@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kgent-api/pkg/clientcache"
+	"kgent-api/pkg/nsscope"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// testRESTMapper maps the core and apps resources the tests use
+func testRESTMapper() *meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	var restMapper meta.RESTMapper = mapper
+	return &restMapper
+}
+
+// newTestResourceService serves objects from a fake clientset, whose shared informers are
+// started and synced, and a fake dynamic client
+func newTestResourceService(t *testing.T, objects []runtime.Object, optfuncs ...ResourceServiceOptionFunc) (*ResourceService, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset(objects...)
+	fact := informers.NewSharedInformerFactory(clientset, 0)
+	for _, gvr := range []schema.GroupVersionResource{
+		corev1.SchemeGroupVersion.WithResource("pods"),
+		appsv1.SchemeGroupVersion.WithResource("deployments"),
+	} {
+		if _, err := fact.ForResource(gvr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	fact.Start(stopCh)
+	fact.WaitForCacheSync(stopCh)
+
+	client := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, objects...)
+	return NewResourceService(testRESTMapper(), client, fact, optfuncs...), client
+}
+
+func testPod(ns, name string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+	}
+}
+
+func TestListResource(t *testing.T) {
+	objects := []runtime.Object{testPod("default", "web-0"), testPod("default", "web-1"), testPod("prod", "api-0")}
+	scope, err := nsscope.New([]string{"default"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		ns       string
+		scope    *nsscope.Scope
+		want     []string
+		wantErr  func(error) bool
+	}{
+		{name: "namespace", resource: "pods", ns: "default", want: []string{"web-0", "web-1"}},
+		{name: "kind", resource: "Pod", ns: "prod", want: []string{"api-0"}},
+		{name: "all namespaces", resource: "pods", want: []string{"web-0", "web-1", "api-0"}},
+		{name: "all namespaces filtered to scope", resource: "pods", scope: scope, want: []string{"web-0", "web-1"}},
+		{name: "namespace outside scope", resource: "pods", ns: "prod", scope: scope, wantErr: apierrors.IsForbidden},
+		{name: "empty namespace", resource: "deployments", ns: "default"},
+		{
+			name:     "unknown resource",
+			resource: "widgets",
+			ns:       "default",
+			wantErr:  func(err error) bool { var unknown *UnknownResourceError; return errors.As(err, &unknown) },
+		},
+		{
+			name:    "empty resource",
+			ns:      "default",
+			wantErr: func(err error) bool { var empty *EmptyArgumentError; return errors.As(err, &empty) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestResourceService(t, objects, WithNamespaceScope(tt.scope))
+			list, err := svc.ListResource(context.Background(), tt.resource, tt.ns)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("ListResource() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]bool{}
+			for _, obj := range list {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[accessor.GetName()] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("ListResource() = %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.want {
+				if !got[name] {
+					t.Errorf("ListResource() = %v, missing %s", got, name)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateResource(t *testing.T) {
+	existing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"}}
+
+	tests := []struct {
+		name     string
+		existing []runtime.Object
+		yaml     string
+		wantNS   string
+		wantErr  func(error) bool
+	}{
+		{
+			name:   "namespace defaulted",
+			yaml:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\ndata:\n  mode: fast\n",
+			wantNS: "default",
+		},
+		{
+			name:   "namespace kept",
+			yaml:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: prod\n",
+			wantNS: "prod",
+		},
+		{
+			name:    "empty",
+			wantErr: func(err error) bool { var empty *EmptyArgumentError; return errors.As(err, &empty) },
+		},
+		{
+			name:    "not a manifest",
+			yaml:    "settings: [",
+			wantErr: func(err error) bool { return err != nil },
+		},
+		{
+			name:     "already exists",
+			existing: []runtime.Object{existing},
+			yaml:     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: default\n",
+			wantErr:  apierrors.IsAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, client := newTestResourceService(t, tt.existing)
+			_, err := svc.CreateResource(context.Background(), "configmaps", tt.yaml)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("CreateResource() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gvr := corev1.SchemeGroupVersion.WithResource("configmaps")
+			created, err := client.Resource(gvr).Namespace(tt.wantNS).Get(context.Background(), "settings", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("created config map not found in %s: %v", tt.wantNS, err)
+			}
+			if created.GetKind() != "ConfigMap" {
+				t.Errorf("created kind = %q, want ConfigMap", created.GetKind())
+			}
+		})
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	tests := []struct {
+		name    string
+		resName string
+		wantErr func(error) bool
+	}{
+		{name: "existing", resName: "web-0"},
+		{name: "not found", resName: "web-9", wantErr: apierrors.IsNotFound},
+		{
+			name:    "empty name",
+			wantErr: func(err error) bool { var empty *EmptyArgumentError; return errors.As(err, &empty) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, client := newTestResourceService(t, []runtime.Object{testPod("default", "web-0")})
+			err := svc.DeleteResource(context.Background(), "pods", "default", tt.resName, Preconditions{})
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("DeleteResource() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gvr := corev1.SchemeGroupVersion.WithResource("pods")
+			if _, err := client.Resource(gvr).Namespace("default").Get(context.Background(), tt.resName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("pod still there after delete: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetResource(t *testing.T) {
+	tests := []struct {
+		name           string
+		resource       string
+		live           bool
+		wantServedFrom string
+	}{
+		{name: "cached", resource: "pods", wantServedFrom: ServedFromCache},
+		{name: "live", resource: "pods", live: true, wantServedFrom: ServedFromAPI},
+		{name: "no informer", resource: "configmaps", wantServedFrom: ServedFromAPI},
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestResourceService(t, []runtime.Object{testPod("default", "web-0"), cm})
+			obj, servedFrom, err := svc.GetResource(context.Background(), tt.resource, "default", "web-0", tt.live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if servedFrom != tt.wantServedFrom {
+				t.Errorf("served from %q, want %q", servedFrom, tt.wantServedFrom)
+			}
+			if obj.GetName() != "web-0" || obj.GetKind() == "" {
+				t.Errorf("GetResource() = %s %s, want web-0 with its kind", obj.GetKind(), obj.GetName())
+			}
+		})
+	}
+}
+
+// identityAPIServer serves discovery with a widgets resource the shared mapper doesn't know,
+// and lists them, recording the Authorization header of each list
+func identityAPIServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var authorizations []string
+	var mu sync.Mutex
+	respond := func(w http.ResponseWriter, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			respond(w, metav1.APIVersions{Versions: []string{"v1"}})
+		case "/apis":
+			respond(w, metav1.APIGroupList{Groups: []metav1.APIGroup{{
+				Name:             "example.com",
+				Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "example.com/v1", Version: "v1"}},
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "example.com/v1", Version: "v1"},
+			}}})
+		case "/api/v1":
+			respond(w, metav1.APIResourceList{GroupVersion: "v1"})
+		case "/apis/example.com/v1":
+			respond(w, metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{
+				{Name: "widgets", SingularName: "widget", Kind: "Widget", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}},
+			}})
+		case "/apis/example.com/v1/namespaces/default/widgets":
+			mu.Lock()
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			mu.Unlock()
+			respond(w, map[string]any{
+				"apiVersion": "example.com/v1",
+				"kind":       "WidgetList",
+				"metadata":   map[string]any{},
+				"items": []any{map[string]any{
+					"apiVersion": "example.com/v1",
+					"kind":       "Widget",
+					"metadata":   map[string]any{"name": "gear", "namespace": "default"},
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &authorizations
+}
+
+// TestListResourceAsIdentity shows identified requests resolve resources with discovery as
+// the identity and list with its token, where the server's mapper doesn't know them
+func TestListResourceAsIdentity(t *testing.T) {
+	server, authorizations := identityAPIServer(t)
+	clients := clientcache.New(&rest.Config{Host: server.URL, BearerToken: "server-token"}, 10, time.Minute)
+	svc, _ := newTestResourceService(t, nil, WithClientCache(clients))
+
+	ctx := clientcache.NewContext(context.Background(), clientcache.Identity{Token: "caller-token"})
+	list, err := svc.ListResource(ctx, "widgets", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("ListResource() = %d widgets, want 1", len(list))
+	}
+	if len(*authorizations) != 1 || (*authorizations)[0] != "Bearer caller-token" {
+		t.Errorf("widgets listed with Authorization %q, want the caller's token only", *authorizations)
+	}
+
+	// Without an identity the server's mapper resolves the name, and knows no widgets
+	var unknownErr *UnknownResourceError
+	if _, err := svc.ListResource(context.Background(), "widgets", "default"); !errors.As(err, &unknownErr) {
+		t.Errorf("ListResource() without an identity error = %v, want an *UnknownResourceError", err)
+	}
+}
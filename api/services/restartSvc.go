@@ -0,0 +1,94 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RestartReport lists the pods that restarted within a window
+type RestartReport struct {
+	Since time.Time `json:"since"`
+	// WindowStart is where observations actually begin, later than Since when the server
+	// started within the window. WindowComplete is unset then, and restarts before
+	// WindowStart are not counted.
+	WindowStart    time.Time       `json:"windowStart"`
+	WindowComplete bool            `json:"windowComplete"`
+	Pods           []RestartingPod `json:"pods"`
+}
+
+// RestartingPod is a pod whose containers restarted within the window
+type RestartingPod struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	// Delta is the restarts of all its containers within the window
+	Delta      int32                 `json:"delta"`
+	Owner      *OwnerRef             `json:"owner,omitempty"`
+	Containers []RestartingContainer `json:"containers"`
+}
+
+// RestartingContainer is a container's restarts within the window and how it last ended
+type RestartingContainer struct {
+	Name     string `json:"name"`
+	Delta    int32  `json:"delta"`
+	Restarts int32  `json:"restarts"`
+	// LastRestartSeen is when the last restart was observed, not when it happened
+	LastRestartSeen time.Time       `json:"lastRestartSeen"`
+	LastTermination *ContainerState `json:"lastTermination,omitempty"`
+}
+
+// Restarts reports the pods in ns, every namespace when empty, whose restart counts went up
+// within the last window, with their owning workload and how each container last terminated
+func (d *DiagnosticsService) Restarts(ns string, window time.Duration) *RestartReport {
+	since := time.Now().Add(-window)
+	report := &RestartReport{Since: since, Pods: []RestartingPod{}}
+	report.WindowStart, report.WindowComplete = d.restarts.Window(since)
+
+	byPod := map[types.UID]*RestartingPod{}
+	var order []types.UID
+	for _, restart := range d.restarts.Since(since, ns) {
+		entry, ok := byPod[restart.UID]
+		if !ok {
+			entry = &RestartingPod{Namespace: restart.Namespace, Pod: restart.Pod}
+			byPod[restart.UID] = entry
+			order = append(order, restart.UID)
+		}
+		entry.Delta += restart.Delta
+		entry.Containers = append(entry.Containers, RestartingContainer{
+			Name:            restart.Container,
+			Delta:           restart.Delta,
+			Restarts:        restart.Restarts,
+			LastRestartSeen: restart.LastSeen,
+		})
+	}
+
+	for _, uid := range order {
+		entry := byPod[uid]
+		// The pod may have been deleted since, its restarts are still worth reporting
+		if pod, err := d.fact.Core().V1().Pods().Lister().Pods(entry.Namespace).Get(entry.Pod); err == nil && pod.UID == uid {
+			entry.Owner = d.rootOwner(pod)
+			for i := range entry.Containers {
+				entry.Containers[i].LastTermination = lastTermination(pod, entry.Containers[i].Name)
+			}
+		}
+		report.Pods = append(report.Pods, *entry)
+	}
+	sort.SliceStable(report.Pods, func(i, j int) bool { return report.Pods[i].Delta > report.Pods[j].Delta })
+	return report
+}
+
+// lastTermination returns how the named container last terminated, if it has
+func lastTermination(pod *v1.Pod, container string) *ContainerState {
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.Name != container || status.LastTerminationState.Terminated == nil {
+				continue
+			}
+			state := containerState(status.LastTerminationState)
+			return &state
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+
+	"kgent-api/pkg/recovery"
+	"kgent-api/pkg/stream"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Why a rollout stream ends
+const (
+	RolloutOutcomeComplete = "complete"
+	RolloutOutcomeStalled  = "stalled"
+	RolloutOutcomePaused   = "paused"
+	RolloutOutcomeDeleted  = "deleted"
+)
+
+// rolloutWatchBuffer is how many changes a rollout watch holds. Changes only prompt a
+// fresh status, so dropping them loses nothing.
+const rolloutWatchBuffer = 16
+
+// RolloutOutcome is why following a rollout with this status is over, empty while it goes on
+func RolloutOutcome(status *RolloutStatus) string {
+	switch {
+	case status.Complete:
+		return RolloutOutcomeComplete
+	case status.Stalled:
+		return RolloutOutcomeStalled
+	case status.Paused:
+		return RolloutOutcomePaused
+	}
+	return ""
+}
+
+// CachedRolloutStatus returns the rollout status of a Deployment from the informer cache
+func (w *WorkloadService) CachedRolloutStatus(ns, name string) (*RolloutStatus, error) {
+	deployment, err := w.fact.Apps().V1().Deployments().Lister().Deployments(ns).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %s: %w", name, err)
+	}
+	return rolloutStatus(deployment), nil
+}
+
+// WatchRollout pushes the kind of object that changed whenever a Deployment, its
+// ReplicaSets or their pods are added, updated or deleted. The returned function removes
+// the informer handlers and must be called once the caller stops reading.
+func (w *WorkloadService) WatchRollout(ns, name string) (*stream.Buffer[string], func(), error) {
+	deployment, err := w.fact.Apps().V1().Deployments().Lister().Deployments(ns).Get(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get deployment %s: %w", name, err)
+	}
+	uid := deployment.UID
+	replicaSets := w.fact.Apps().V1().ReplicaSets().Lister().ReplicaSets(ns)
+
+	ownedReplicaSet := func(rs *appsv1.ReplicaSet) bool {
+		ref := metav1.GetControllerOf(rs)
+		return ref != nil && ref.UID == uid
+	}
+	watches := []struct {
+		kind     string
+		informer cache.SharedIndexInformer
+		owned    func(obj interface{}) bool
+	}{
+		{"deployment", w.fact.Apps().V1().Deployments().Informer(), func(obj interface{}) bool {
+			d, ok := obj.(*appsv1.Deployment)
+			return ok && d.UID == uid
+		}},
+		{"replicaset", w.fact.Apps().V1().ReplicaSets().Informer(), func(obj interface{}) bool {
+			rs, ok := obj.(*appsv1.ReplicaSet)
+			return ok && rs.Namespace == ns && ownedReplicaSet(rs)
+		}},
+		{"pod", w.fact.Core().V1().Pods().Informer(), func(obj interface{}) bool {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || pod.Namespace != ns {
+				return false
+			}
+			ref := metav1.GetControllerOf(pod)
+			if ref == nil || ref.Kind != "ReplicaSet" {
+				return false
+			}
+			// Pods of deleted ReplicaSets are gone from the cache, they no longer matter
+			rs, err := replicaSets.Get(ref.Name)
+			return err == nil && rs.UID == ref.UID && ownedReplicaSet(rs)
+		}},
+	}
+
+	buffer := stream.NewBuffer[string]("rollout", rolloutWatchBuffer)
+	var registrations []func()
+	stop := func() {
+		for _, remove := range registrations {
+			remove()
+		}
+		buffer.Close()
+	}
+	for _, watch := range watches {
+		notify := func(obj interface{}) { buffer.Push(watch.kind) }
+		registration, err := watch.informer.AddEventHandler(recovery.Handler("rollout/"+watch.kind, cache.FilteringResourceEventHandler{
+			FilterFunc: func(obj interface{}) bool {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				return watch.owned(obj)
+			},
+			Handler: cache.ResourceEventHandlerDetailedFuncs{
+				AddFunc: func(obj interface{}, isInInitialList bool) {
+					if !isInInitialList {
+						notify(obj)
+					}
+				},
+				UpdateFunc: func(_, obj interface{}) { notify(obj) },
+				DeleteFunc: notify,
+			},
+		}, nil))
+		if err != nil {
+			stop()
+			return nil, nil, fmt.Errorf("failed to watch %ss of deployment %s: %w", watch.kind, name, err)
+		}
+		informer := watch.informer
+		registrations = append(registrations, func() { _ = informer.RemoveEventHandler(registration) })
+	}
+	return buffer, stop, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"kgent-api/pkg/scheduling"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SchedulingExplanation is why a pod is or isn't scheduled: what the scheduler said in its
+// FailedScheduling events, and a per-node verdict evaluated here
+type SchedulingExplanation struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Scheduled bool   `json:"scheduled"`
+	NodeName  string `json:"nodeName,omitempty"`
+	// Events are the most recent FailedScheduling events, newest first
+	Events []scheduling.FailedScheduling `json:"events"`
+	// Nodes are verdicts evaluated against the node cache, which only approximate the
+	// scheduler: NotEvaluated lists what they leave out
+	Nodes        []scheduling.NodeVerdict `json:"nodes"`
+	Fits         int                      `json:"fits"`
+	Approximate  bool                     `json:"approximate"`
+	NotEvaluated []string                 `json:"notEvaluated"`
+}
+
+// Scheduling explains where a pod can and can't be scheduled. The per-node verdicts cover
+// the common predicates only and are labelled approximate; the events are the scheduler's own.
+func (p *PodStatusService) Scheduling(ctx context.Context, ns, name string) (*SchedulingExplanation, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "pod name"}
+	}
+
+	pod, err := p.fact.Core().V1().Pods().Lister().Pods(ns).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
+	}
+
+	events, err := p.warningEvents(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := p.fact.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	all, err := p.fact.Core().V1().Pods().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	// A scheduled pod's own requests would count against its node
+	pods := make([]*v1.Pod, 0, len(all))
+	for _, other := range all {
+		if other.UID != pod.UID {
+			pods = append(pods, other)
+		}
+	}
+
+	explanation := &SchedulingExplanation{
+		Name:         pod.Name,
+		Namespace:    pod.Namespace,
+		Scheduled:    pod.Spec.NodeName != "",
+		NodeName:     pod.Spec.NodeName,
+		Events:       []scheduling.FailedScheduling{},
+		Nodes:        scheduling.Evaluate(pod, nodes, pods),
+		Approximate:  true,
+		NotEvaluated: scheduling.NotEvaluated,
+	}
+	for _, event := range events {
+		if event.Reason == "FailedScheduling" && len(explanation.Events) < maxSchedulingEvents {
+			explanation.Events = append(explanation.Events, scheduling.ParseFailedScheduling(event.Message))
+		}
+	}
+	for _, verdict := range explanation.Nodes {
+		if verdict.Fits {
+			explanation.Fits++
+		}
+	}
+	return explanation, nil
+}
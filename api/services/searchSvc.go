@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/nsscope"
+	"kgent-api/pkg/retry"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultSearchKinds is the workload set searched when no kinds are requested
+var DefaultSearchKinds = []string{"deployments", "statefulsets", "daemonsets", "pods", "services", "configmaps"}
+
+// SearchResult is a single object matching a search query
+type SearchResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Age       string `json:"age"`
+}
+
+// SearchGroup holds the matches for one resource kind
+type SearchGroup struct {
+	Resource  string         `json:"resource"`
+	Kind      string         `json:"kind"`
+	Items     []SearchResult `json:"items"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// Search matches query against object names and label values across the requested kinds.
+// Queries are case-insensitive substrings unless prefixed with "re:" for a regular expression.
+func (r *ResourceService) Search(ctx context.Context, query string, ns string, kinds []string, limit int) ([]SearchGroup, error) {
+	if query == "" {
+		return nil, &EmptyArgumentError{Argument: "query"}
+	}
+
+	match, err := searchMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kinds) == 0 {
+		kinds = DefaultSearchKinds
+	}
+
+	groups := make([]SearchGroup, len(kinds))
+	var wg sync.WaitGroup
+	for i, kind := range kinds {
+		wg.Add(1)
+		go func(i int, kind string) {
+			defer wg.Done()
+			groups[i] = r.searchKind(ctx, kind, ns, match, limit)
+		}(i, kind)
+	}
+	wg.Wait()
+
+	return groups, nil
+}
+
+func (r *ResourceService) searchKind(ctx context.Context, kind string, ns string, match func(string) bool, limit int) SearchGroup {
+	group := SearchGroup{Resource: kind, Items: []SearchResult{}}
+
+	restMapping, err := r.mappingFor(kind, r.restMapper)
+	if err != nil {
+		group.Error = err.Error()
+		return group
+	}
+	group.Resource = restMapping.Resource.Resource
+	group.Kind = restMapping.GroupVersionKind.Kind
+
+	if restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		ns = metav1.NamespaceAll
+	}
+	if err := r.checkScope(restMapping, ns); err != nil {
+		group.Error = err.Error()
+		return group
+	}
+
+	objects, err := r.listObjects(ctx, restMapping.Resource, ns)
+	if err != nil {
+		group.Error = err.Error()
+		return group
+	}
+	objects = nsscope.Filter(r.scope, objects, func(obj metav1.Object) string { return obj.GetNamespace() })
+
+	for _, obj := range objects {
+		if !match(obj.GetName()) && !matchesLabelValue(obj.GetLabels(), match) {
+			continue
+		}
+		if limit > 0 && len(group.Items) >= limit {
+			group.Truncated = true
+			break
+		}
+		group.Items = append(group.Items, SearchResult{
+			Kind:      group.Kind,
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Age:       age(obj.GetCreationTimestamp()),
+		})
+	}
+
+	return group
+}
+
+// listObjects lists objects from a synced informer when one exists, otherwise from the API server.
+// Identified requests always list from the API server, as the caller.
+func (r *ResourceService) listObjects(ctx context.Context, gvr schema.GroupVersionResource, ns string) ([]metav1.Object, error) {
+	if lister, ok := r.syncedLister(gvr); ok && !r.identified(ctx) {
+		list, err := lister.ByNamespace(ns).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s from cache: %w", gvr.Resource, err)
+		}
+
+		objects := make([]metav1.Object, 0, len(list))
+		for _, item := range list {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			objects = append(objects, obj)
+		}
+		return objects, nil
+	}
+
+	client, err := r.dynamicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var list *unstructured.UnstructuredList
+	err = retry.Do(ctx, "list", func(int) (err error) {
+		list, err = client.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+	}
+
+	objects := make([]metav1.Object, 0, len(list.Items))
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, nil
+}
+
+// syncedLister returns the informer lister for gvr if the factory already has a synced informer for it
+func (r *ResourceService) syncedLister(gvr schema.GroupVersionResource) (cache.GenericLister, bool) {
+	if r.fact == nil {
+		return nil, false
+	}
+
+	informer, err := r.fact.ForResource(gvr)
+	if err != nil || !informer.Informer().HasSynced() {
+		return nil, false
+	}
+	return informer.Lister(), true
+}
+
+func searchMatcher(query string) (func(string) bool, error) {
+	if pattern, ok := strings.CutPrefix(query, "re:"); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	query = strings.ToLower(query)
+	return func(s string) bool {
+		return strings.Contains(strings.ToLower(s), query)
+	}, nil
+}
+
+func matchesLabelValue(objLabels map[string]string, match func(string) bool) bool {
+	for _, value := range objLabels {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// age formats the time since creation the same way kubectl does
+func age(created metav1.Time) string {
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(created.Time))
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// minTokenExpiration is the shortest expiration the TokenRequest API accepts
+const minTokenExpiration = 10 * time.Minute
+
+type ServiceAccountService struct {
+	client        kubernetes.Interface
+	config        *rest.Config
+	maxExpiration time.Duration
+}
+
+func NewServiceAccountService(client kubernetes.Interface, config *rest.Config, maxExpiration time.Duration) *ServiceAccountService {
+	return &ServiceAccountService{client: client, config: config, maxExpiration: maxExpiration}
+}
+
+// IssuedToken is a service account token along with an optional ready-to-use kubeconfig
+type IssuedToken struct {
+	Token               string      `json:"token"`
+	ExpirationTimestamp metav1.Time `json:"expirationTimestamp"`
+	Kubeconfig          string      `json:"kubeconfig,omitempty"`
+}
+
+// CreateToken issues a bound token for the service account through the TokenRequest API.
+// The requested expiration is clamped to the server-side maximum.
+func (s *ServiceAccountService) CreateToken(ctx context.Context, ns, name string, expiration time.Duration, audiences []string, withKubeconfig bool) (*IssuedToken, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "service account name"}
+	}
+
+	if expiration <= 0 || expiration > s.maxExpiration {
+		expiration = s.maxExpiration
+	}
+	if expiration < minTokenExpiration {
+		return nil, &InvalidArgumentError{Argument: "expiration", Reason: fmt.Sprintf("must be at least %s", minTokenExpiration)}
+	}
+
+	seconds := int64(expiration.Seconds())
+	tr, err := s.client.CoreV1().ServiceAccounts(ns).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &seconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token for %s/%s: %w", ns, name, err)
+	}
+
+	issued := &IssuedToken{
+		Token:               tr.Status.Token,
+		ExpirationTimestamp: tr.Status.ExpirationTimestamp,
+	}
+	if withKubeconfig {
+		kubeconfig, err := s.kubeconfig(ns, name, tr.Status.Token)
+		if err != nil {
+			return nil, err
+		}
+		issued.Kubeconfig = kubeconfig
+	}
+	return issued, nil
+}
+
+// kubeconfig assembles a kubeconfig for the current cluster's server and CA with the token embedded
+func (s *ServiceAccountService) kubeconfig(ns, name, token string) (string, error) {
+	caData := s.config.CAData
+	if len(caData) == 0 && s.config.CAFile != "" {
+		data, err := os.ReadFile(s.config.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read cluster CA: %w", err)
+		}
+		caData = data
+	}
+
+	user := fmt.Sprintf("%s-%s", ns, name)
+	config := clientcmdapi.NewConfig()
+	config.Clusters["cluster"] = &clientcmdapi.Cluster{
+		Server:                   s.config.Host,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[user] = &clientcmdapi.AuthInfo{Token: token}
+	config.Contexts[user] = &clientcmdapi.Context{
+		Cluster:   "cluster",
+		AuthInfo:  user,
+		Namespace: ns,
+	}
+	config.CurrentContext = user
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration time.Duration
+		// wantSeconds is the expiration requested from the API server
+		wantSeconds int64
+		wantInvalid bool
+	}{
+		{name: "default", wantSeconds: int64(time.Hour.Seconds())},
+		{name: "within range", expiration: 30 * time.Minute, wantSeconds: int64((30 * time.Minute).Seconds())},
+		{name: "clamped to the maximum", expiration: 24 * time.Hour, wantSeconds: int64(time.Hour.Seconds())},
+		{name: "too short", expiration: time.Minute, wantInvalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"}})
+			var requested int64
+			client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+				requested = *tr.Spec.ExpirationSeconds
+				tr.Status.Token = "token"
+				return true, tr, nil
+			})
+			svc := NewServiceAccountService(client, &rest.Config{Host: "https://cluster.example"}, time.Hour)
+
+			token, err := svc.CreateToken(context.Background(), "default", "deployer", tt.expiration, nil, true)
+			if tt.wantInvalid {
+				var invalidErr *InvalidArgumentError
+				if !errors.As(err, &invalidErr) || invalidErr.Argument != "expiration" {
+					t.Fatalf("CreateToken() error = %v, want an invalid expiration", err)
+				}
+				if requested != 0 {
+					t.Error("CreateToken() requested a token for an invalid expiration")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if requested != tt.wantSeconds {
+				t.Errorf("requested expiration = %ds, want %ds", requested, tt.wantSeconds)
+			}
+			if token.Token != "token" || token.Kubeconfig == "" {
+				t.Errorf("CreateToken() = %+v, want the token and a kubeconfig", token)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"kgent-api/pkg/retry"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+// mountFailureReasons are the pod events of a volume that couldn't be attached or mounted
+var mountFailureReasons = map[string]bool{
+	"FailedMount":        true,
+	"FailedAttachVolume": true,
+}
+
+type StorageService struct {
+	client kubernetes.Interface
+	fact   informers.SharedInformerFactory
+}
+
+func NewStorageService(client kubernetes.Interface, fact informers.SharedInformerFactory) *StorageService {
+	return &StorageService{client: client, fact: fact}
+}
+
+// PersistentVolume is a PersistentVolume and what became of its claim
+type PersistentVolume struct {
+	Name          string                           `json:"name"`
+	Phase         v1.PersistentVolumePhase         `json:"phase"`
+	Claim         *ObjectRef                       `json:"claim,omitempty"`
+	StorageClass  string                           `json:"storageClass,omitempty"`
+	ReclaimPolicy v1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy"`
+	Capacity      string                           `json:"capacity,omitempty"`
+	AccessModes   []v1.PersistentVolumeAccessMode  `json:"accessModes"`
+	// Released volumes outlived their claim and are retained until cleaned up by hand
+	Released bool `json:"released"`
+	// Failed volumes couldn't be reclaimed, Message says why
+	Failed              bool         `json:"failed"`
+	Message             string       `json:"message,omitempty"`
+	LastPhaseTransition *metav1.Time `json:"lastPhaseTransition,omitempty"`
+}
+
+// ObjectRef names a namespaced object
+type ObjectRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// PVCTimeline is the provisioning story of a PersistentVolumeClaim, its entries oldest first
+type PVCTimeline struct {
+	Namespace    string                              `json:"namespace"`
+	Name         string                              `json:"name"`
+	Phase        v1.PersistentVolumeClaimPhase       `json:"phase"`
+	StorageClass string                              `json:"storageClass,omitempty"`
+	VolumeName   string                              `json:"volumeName,omitempty"`
+	Capacity     string                              `json:"capacity,omitempty"`
+	AccessModes  []v1.PersistentVolumeAccessMode     `json:"accessModes"`
+	Entries      []TimelineEntry                     `json:"entries"`
+	Pods         []PVCConsumer                       `json:"pods"`
+	Conditions   []v1.PersistentVolumeClaimCondition `json:"conditions,omitempty"`
+}
+
+// TimelineEntry is one step of a claim's story: its creation, its binding, or an event of
+// the claim or of a pod mounting it
+type TimelineEntry struct {
+	Time time.Time `json:"time"`
+	// Kind and Name are the object the entry is about
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	// Source is the component that reported an event, such as the external provisioner
+	Source string `json:"source,omitempty"`
+	Count  int32  `json:"count,omitempty"`
+}
+
+// PVCConsumer is a pod that mounts the claim
+type PVCConsumer struct {
+	Name          string      `json:"name"`
+	Phase         v1.PodPhase `json:"phase"`
+	Node          string      `json:"node,omitempty"`
+	MountFailures int32       `json:"mountFailures"`
+}
+
+// ListVolumes lists every PersistentVolume, Failed and Released ones first
+func (s *StorageService) ListVolumes(ctx context.Context) ([]PersistentVolume, error) {
+	var list *v1.PersistentVolumeList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		list, err = s.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volumes: %w", err)
+	}
+
+	volumes := make([]PersistentVolume, 0, len(list.Items))
+	for _, pv := range list.Items {
+		volume := PersistentVolume{
+			Name:                pv.Name,
+			Phase:               pv.Status.Phase,
+			StorageClass:        pv.Spec.StorageClassName,
+			ReclaimPolicy:       pv.Spec.PersistentVolumeReclaimPolicy,
+			AccessModes:         pv.Spec.AccessModes,
+			Released:            pv.Status.Phase == v1.VolumeReleased,
+			Failed:              pv.Status.Phase == v1.VolumeFailed,
+			Message:             pv.Status.Message,
+			LastPhaseTransition: pv.Status.LastPhaseTransitionTime,
+		}
+		if pv.Spec.ClaimRef != nil {
+			volume.Claim = &ObjectRef{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+		}
+		if capacity, ok := pv.Spec.Capacity[v1.ResourceStorage]; ok {
+			volume.Capacity = capacity.String()
+		}
+		volumes = append(volumes, volume)
+	}
+
+	rank := func(v PersistentVolume) int {
+		switch {
+		case v.Failed:
+			return 0
+		case v.Released:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(volumes, func(i, j int) bool {
+		if rank(volumes[i]) != rank(volumes[j]) {
+			return rank(volumes[i]) < rank(volumes[j])
+		}
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+// ClaimTimeline assembles the story of the claim ns/name: its creation, the events of its
+// provisioning, when its volume was bound, and the pods mounting it with the attach and
+// mount failures they ran into
+func (s *StorageService) ClaimTimeline(ctx context.Context, ns, name string) (*PVCTimeline, error) {
+	pvc, err := s.fact.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(ns).Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &PVCTimeline{
+		Namespace:   pvc.Namespace,
+		Name:        pvc.Name,
+		Phase:       pvc.Status.Phase,
+		VolumeName:  pvc.Spec.VolumeName,
+		AccessModes: pvc.Status.AccessModes,
+		Conditions:  pvc.Status.Conditions,
+		Entries: []TimelineEntry{{
+			Time: pvc.CreationTimestamp.Time, Kind: "PersistentVolumeClaim", Name: pvc.Name, Reason: "Created",
+		}},
+		Pods: []PVCConsumer{},
+	}
+	if pvc.Spec.StorageClassName != nil {
+		timeline.StorageClass = *pvc.Spec.StorageClassName
+	}
+	if capacity, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		timeline.Capacity = capacity.String()
+	}
+
+	claimEvents, err := s.events(ctx, ns, fmt.Sprintf("involvedObject.kind=PersistentVolumeClaim,involvedObject.name=%s", name))
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range claimEvents {
+		if event.InvolvedObject.UID == pvc.UID {
+			timeline.Entries = append(timeline.Entries, timelineEntry(event))
+		}
+	}
+
+	// Claims don't record when they were bound, their volume's last phase change does
+	if pvc.Spec.VolumeName != "" {
+		var pv *v1.PersistentVolume
+		err := retry.Do(ctx, "get", func(int) (err error) {
+			pv, err = s.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+			return err
+		})
+		// The volume may be gone, the rest of the story is still worth telling
+		if err == nil && pv.Status.Phase == v1.VolumeBound && pv.Status.LastPhaseTransitionTime != nil {
+			timeline.Entries = append(timeline.Entries, TimelineEntry{
+				Time: pv.Status.LastPhaseTransitionTime.Time, Kind: "PersistentVolume", Name: pv.Name, Reason: "Bound",
+				Message: fmt.Sprintf("bound to volume %s", pv.Name),
+			})
+		}
+	}
+
+	pods, err := s.fact.Core().V1().Pods().Lister().Pods(ns).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	consumers := map[string]*PVCConsumer{}
+	for _, pod := range pods {
+		if !mountsClaim(pod, name) {
+			continue
+		}
+		timeline.Pods = append(timeline.Pods, PVCConsumer{Name: pod.Name, Phase: pod.Status.Phase, Node: pod.Spec.NodeName})
+	}
+	for i := range timeline.Pods {
+		consumers[timeline.Pods[i].Name] = &timeline.Pods[i]
+	}
+	if len(consumers) > 0 {
+		podEvents, err := s.events(ctx, ns, "involvedObject.kind=Pod,type=Warning")
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range podEvents {
+			consumer, ok := consumers[event.InvolvedObject.Name]
+			if !ok || !mountFailureReasons[event.Reason] {
+				continue
+			}
+			consumer.MountFailures += max(event.Count, 1)
+			timeline.Entries = append(timeline.Entries, timelineEntry(event))
+		}
+	}
+
+	sort.SliceStable(timeline.Entries, func(i, j int) bool {
+		return timeline.Entries[i].Time.Before(timeline.Entries[j].Time)
+	})
+	sort.Slice(timeline.Pods, func(i, j int) bool { return timeline.Pods[i].Name < timeline.Pods[j].Name })
+	return timeline, nil
+}
+
+func (s *StorageService) events(ctx context.Context, ns, selector string) ([]v1.Event, error) {
+	var list *v1.EventList
+	err := retry.Do(ctx, "list", func(int) (err error) {
+		list, err = s.client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{FieldSelector: selector})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	return list.Items, nil
+}
+
+func timelineEntry(event v1.Event) TimelineEntry {
+	source := event.Source.Component
+	if source == "" {
+		source = event.ReportingController
+	}
+	return TimelineEntry{
+		Time:    eventTime(event),
+		Kind:    event.InvolvedObject.Kind,
+		Name:    event.InvolvedObject.Name,
+		Type:    event.Type,
+		Reason:  event.Reason,
+		Message: event.Message,
+		Source:  source,
+		Count:   event.Count,
+	}
+}
+
+// mountsClaim reports whether the pod mounts the claim, directly or as the claim of one of
+// its generic ephemeral volumes
+func mountsClaim(pod *v1.Pod, claim string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claim {
+			return true
+		}
+		if volume.Ephemeral != nil && pod.Name+"-"+volume.Name == claim {
+			return true
+		}
+	}
+	return false
+}
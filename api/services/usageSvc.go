@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+
+	"kgent-api/pkg/usage"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrUsageHistoryDisabled is returned when usage history is queried without the sampler enabled
+var ErrUsageHistoryDisabled = fmt.Errorf("usage history is disabled, set METRICS_HISTORY=true to enable it")
+
+// UsageHistory is the sampled resource usage of a pod or node, oldest sample first
+type UsageHistory struct {
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	Interval  string         `json:"interval"`
+	Samples   []usage.Sample `json:"samples"`
+}
+
+type UsageService struct {
+	sampler *usage.Sampler
+}
+
+// NewUsageService creates the service. A nil sampler means usage history is disabled.
+func NewUsageService(sampler *usage.Sampler) *UsageService {
+	return &UsageService{sampler: sampler}
+}
+
+// PodHistory returns the usage history of a pod
+func (u *UsageService) PodHistory(ns string, name string) (*UsageHistory, error) {
+	if u.sampler == nil {
+		return nil, ErrUsageHistoryDisabled
+	}
+	samples, ok := u.sampler.PodHistory(ns, name)
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "metrics.k8s.io", Resource: "pods"}, name)
+	}
+	return &UsageHistory{Namespace: ns, Name: name, Interval: u.sampler.Interval().String(), Samples: samples}, nil
+}
+
+// NodeHistory returns the usage history of a node
+func (u *UsageService) NodeHistory(name string) (*UsageHistory, error) {
+	if u.sampler == nil {
+		return nil, ErrUsageHistoryDisabled
+	}
+	samples, ok := u.sampler.NodeHistory(name)
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "metrics.k8s.io", Resource: "nodes"}, name)
+	}
+	return &UsageHistory{Name: name, Interval: u.sampler.Interval().String(), Samples: samples}, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/retry"
+	"kgent-api/pkg/schema"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/openapi"
+)
+
+// openAPITTL bounds how long a group version's schema is reused, so CRD changes are picked up
+const openAPITTL = 5 * time.Minute
+
+// ValidationResult reports whether a manifest could be checked and what is wrong with it.
+// Validated is false when no schema was available, in which case Reason explains why.
+type ValidationResult struct {
+	Validated  bool               `json:"validated"`
+	Reason     string             `json:"reason,omitempty"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+type cachedDocument struct {
+	doc       *schema.Document
+	fetchedAt time.Time
+}
+
+type openAPISchemaCache struct {
+	client openapi.Client
+
+	mu   sync.Mutex
+	docs map[string]cachedDocument
+}
+
+func newOpenAPISchemaCache(client openapi.Client) *openAPISchemaCache {
+	return &openAPISchemaCache{client: client, docs: map[string]cachedDocument{}}
+}
+
+// document returns the OpenAPI v3 document of a group version
+func (c *openAPISchemaCache) document(gv runtimeschema.GroupVersion) (*schema.Document, error) {
+	path := "apis/" + gv.Group + "/" + gv.Version
+	if gv.Group == "" {
+		path = "api/" + gv.Version
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.docs[path]; ok && time.Since(cached.fetchedAt) < openAPITTL {
+		return cached.doc, nil
+	}
+
+	var paths map[string]openapi.GroupVersion
+	err := retry.Do(context.Background(), "discovery", func(int) (err error) {
+		paths, err = c.client.Paths()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OpenAPI paths: %w", err)
+	}
+	groupVersion, ok := paths[path]
+	if !ok {
+		return nil, fmt.Errorf("the API server publishes no OpenAPI v3 schema for %s", gv.String())
+	}
+	var data []byte
+	err = retry.Do(context.Background(), "discovery", func(int) (err error) {
+		data, err = groupVersion.Schema(runtime.ContentTypeJSON)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenAPI schema for %s: %w", gv.String(), err)
+	}
+	doc, err := schema.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.docs[path] = cachedDocument{doc: doc, fetchedAt: time.Now()}
+	return doc, nil
+}
+
+// ValidateManifest checks the manifest against the cluster's OpenAPI v3 schema for its kind
+func (r *ResourceService) ValidateManifest(yaml string) (*ValidationResult, error) {
+	if yaml == "" {
+		return nil, &EmptyArgumentError{Argument: "YAML content"}
+	}
+
+	obj := &unstructured.Unstructured{}
+	if _, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(yaml), nil, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+
+	result := &ValidationResult{Violations: []schema.Violation{}}
+	if r.openAPI == nil {
+		result.Reason = "discovery is not configured"
+		return result, nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	doc, err := r.openAPI.document(gvk.GroupVersion())
+	if err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	root, ok := doc.SchemaFor(gvk)
+	if !ok {
+		result.Reason = fmt.Sprintf("no schema found for %s", gvk.String())
+		return result, nil
+	}
+
+	result.Validated = true
+	result.Violations = append(result.Violations, doc.Validate(obj.Object, root)...)
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"kgent-api/pkg/webhook"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+type WebhookService struct {
+	dispatcher *webhook.Dispatcher
+	fact       informers.SharedInformerFactory
+	// stopCh stops the informers started for subscriptions
+	stopCh <-chan struct{}
+
+	// mu guards started, the resources whose informer was started for a subscription
+	mu      sync.Mutex
+	started map[schema.GroupVersionResource]bool
+}
+
+func NewWebhookService(dispatcher *webhook.Dispatcher, fact informers.SharedInformerFactory, stopCh <-chan struct{}) *WebhookService {
+	return &WebhookService{dispatcher: dispatcher, fact: fact, stopCh: stopCh, started: map[schema.GroupVersionResource]bool{}}
+}
+
+// WebhookRequest is the body accepted when creating a subscription
+type WebhookRequest struct {
+	Group         string   `json:"group"`
+	Version       string   `json:"version" binding:"required"`
+	Resource      string   `json:"resource" binding:"required"`
+	Namespace     string   `json:"namespace"`
+	LabelSelector string   `json:"labelSelector"`
+	Events        []string `json:"events"`
+	URL           string   `json:"url" binding:"required"`
+	Secret        string   `json:"secret"`
+}
+
+// Create stores a subscription and makes sure the informer for its resource is watched and running
+func (w *WebhookService) Create(req WebhookRequest) (*webhook.Subscription, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if _, err := labels.Parse(req.LabelSelector); err != nil {
+		return nil, &SelectorError{Selector: req.LabelSelector, Err: err}
+	}
+
+	gvr := schema.GroupVersionResource{Group: req.Group, Version: req.Version, Resource: req.Resource}
+	informer, err := w.fact.ForResource(gvr)
+	if err != nil {
+		return nil, fmt.Errorf("resource %s cannot be watched: %w", gvr.String(), err)
+	}
+
+	sub := &webhook.Subscription{
+		Resource:      gvr,
+		Namespace:     req.Namespace,
+		LabelSelector: req.LabelSelector,
+		Events:        req.Events,
+		URL:           req.URL,
+		Secret:        req.Secret,
+	}
+	if err := w.dispatcher.Add(sub); err != nil {
+		return nil, err
+	}
+
+	if err := w.dispatcher.Watch(gvr, informer.Informer()); err != nil {
+		w.dispatcher.Remove(sub.ID)
+		return nil, fmt.Errorf("failed to watch %s: %w", gvr.String(), err)
+	}
+	w.run(gvr, informer.Informer())
+	return sub, nil
+}
+
+// run starts the informer of gvr unless it is running already. Starting the factory instead
+// would also start every informer registered on it without being meant to run, such as
+// those looked up by search.
+func (w *WebhookService) run(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started[gvr] {
+		return
+	}
+	w.started[gvr] = true
+	if running, ok := informer.(interface{ HasStarted() bool }); ok && running.HasStarted() {
+		return
+	}
+	go informer.Run(w.stopCh)
+}
+
+func (w *WebhookService) List() []webhook.Subscription {
+	return w.dispatcher.List()
+}
+
+func (w *WebhookService) Delete(id string) error {
+	if !w.dispatcher.Remove(id) {
+		return fmt.Errorf("webhook %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"kgent-api/pkg/index"
+	"kgent-api/pkg/retry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// changeCauseAnnotation is what kubectl rollout history shows for each revision
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+)
+
+type WorkloadService struct {
+	client kubernetes.Interface
+	fact   informers.SharedInformerFactory
+}
+
+func NewWorkloadService(client kubernetes.Interface, fact informers.SharedInformerFactory) *WorkloadService {
+	return &WorkloadService{client: client, fact: fact}
+}
+
+// RestartWorkload triggers a rolling restart of a Deployment, StatefulSet or DaemonSet
+// by patching the pod template the way kubectl rollout restart does
+func (w *WorkloadService) RestartWorkload(ctx context.Context, resource, ns, name string) error {
+	if name == "" {
+		return &EmptyArgumentError{Argument: resource + " name"}
+	}
+
+	var err error
+	switch resource {
+	case "deployments":
+		_, err = w.client.AppsV1().Deployments(ns).Patch(ctx, name, types.MergePatchType, restartPatch(), metav1.PatchOptions{})
+	case "statefulsets":
+		_, err = w.client.AppsV1().StatefulSets(ns).Patch(ctx, name, types.MergePatchType, restartPatch(), metav1.PatchOptions{})
+	case "daemonsets":
+		_, err = w.client.AppsV1().DaemonSets(ns).Patch(ctx, name, types.MergePatchType, restartPatch(), metav1.PatchOptions{})
+	default:
+		return fmt.Errorf("restart is not supported for %s", resource)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restart %s %s: %w", resource, name, err)
+	}
+	return nil
+}
+
+// ErrNoPodOnNode is returned when a DaemonSet has no pod on the requested node
+var ErrNoPodOnNode = errors.New("no pod of the daemonset is running on the node")
+
+// AmbiguousPodsError is returned instead of deleting when several pods of a DaemonSet match a node
+type AmbiguousPodsError struct {
+	Node string
+	Pods []string
+}
+
+func (e *AmbiguousPodsError) Error() string {
+	return fmt.Sprintf("found %d pods on node %s (%s), refusing to delete", len(e.Pods), e.Node, strings.Join(e.Pods, ", "))
+}
+
+// BouncedPod is the pod deleted by a node-scoped restart
+type BouncedPod struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Node      string    `json:"node"`
+	UID       types.UID `json:"uid"`
+}
+
+// RestartDaemonSetOnNode deletes the DaemonSet's pod on a single node so the DaemonSet
+// controller recreates it, leaving the pods on every other node untouched
+func (w *WorkloadService) RestartDaemonSetOnNode(ctx context.Context, ns, name, node string) (*BouncedPod, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "daemonset name"}
+	}
+	if node == "" {
+		return nil, &EmptyArgumentError{Argument: "node"}
+	}
+
+	var ds *appsv1.DaemonSet
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		ds, err = w.client.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daemonset %s: %w", name, err)
+	}
+
+	objects, err := w.fact.Core().V1().Pods().Informer().GetIndexer().ByIndex(index.NodeIndex, node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pods on node %s: %w", node, err)
+	}
+
+	var matched []*corev1.Pod
+	for _, obj := range objects {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.Namespace != ns {
+			continue
+		}
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.UID == ds.UID {
+			matched = append(matched, pod)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("daemonset %s, node %s: %w", name, node, ErrNoPodOnNode)
+	case 1:
+	default:
+		names := make([]string, 0, len(matched))
+		for _, pod := range matched {
+			names = append(names, pod.Name)
+		}
+		return nil, &AmbiguousPodsError{Node: node, Pods: names}
+	}
+
+	pod := matched[0]
+	// The UID precondition keeps a stale cache from deleting a pod that was already replaced
+	err = w.client.CoreV1().Pods(ns).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		Preconditions: metav1.NewUIDPreconditions(string(pod.UID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete pod %s: %w", pod.Name, err)
+	}
+	return &BouncedPod{Name: pod.Name, Namespace: pod.Namespace, Node: node, UID: pod.UID}, nil
+}
+
+// RolloutStatus summarizes a Deployment rollout the way kubectl rollout status reports it
+type RolloutStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Paused    bool   `json:"paused"`
+	Complete  bool   `json:"complete"`
+	// Stalled is set once the rollout has exceeded its progress deadline
+	Stalled             bool   `json:"stalled"`
+	Message             string `json:"message"`
+	Revision            string `json:"revision,omitempty"`
+	ChangeCause         string `json:"changeCause,omitempty"`
+	Generation          int64  `json:"generation"`
+	ObservedGeneration  int64  `json:"observedGeneration"`
+	Replicas            int32  `json:"replicas"`
+	UpdatedReplicas     int32  `json:"updatedReplicas"`
+	ReadyReplicas       int32  `json:"readyReplicas"`
+	AvailableReplicas   int32  `json:"availableReplicas"`
+	UnavailableReplicas int32  `json:"unavailableReplicas"`
+}
+
+// DeploymentRolloutStatus returns the rollout status of a Deployment
+func (w *WorkloadService) DeploymentRolloutStatus(ctx context.Context, ns, name string) (*RolloutStatus, error) {
+	deployment, err := w.getDeployment(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	return rolloutStatus(deployment), nil
+}
+
+// SetDeploymentPaused pauses or resumes a Deployment rollout, reporting whether anything changed.
+// A non-empty changeCause is recorded in the kubernetes.io/change-cause annotation, as kubectl
+// --record does, so the next revision shows it in kubectl rollout history.
+func (w *WorkloadService) SetDeploymentPaused(ctx context.Context, ns, name string, paused bool, changeCause string) (*RolloutStatus, bool, error) {
+	deployment, err := w.getDeployment(ctx, ns, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if deployment.Spec.Paused == paused {
+		return rolloutStatus(deployment), false, nil
+	}
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{"paused": paused},
+	}
+	if changeCause != "" {
+		patch["metadata"] = map[string]interface{}{
+			"annotations": map[string]string{changeCauseAnnotation: changeCause},
+		}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, false, err
+	}
+
+	deployment, err = w.client.AppsV1().Deployments(ns).Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to patch deployment %s: %w", name, err)
+	}
+	return rolloutStatus(deployment), true, nil
+}
+
+// StatefulSetRolloutStatus reports how a StatefulSet's pods split between its current and
+// update revisions, which is what a canary partition is stepped down against
+type StatefulSetRolloutStatus struct {
+	Name               string `json:"name"`
+	Namespace          string `json:"namespace"`
+	Complete           bool   `json:"complete"`
+	Message            string `json:"message"`
+	Strategy           string `json:"strategy"`
+	Partition          int32  `json:"partition"`
+	Replicas           int32  `json:"replicas"`
+	CurrentRevision    string `json:"currentRevision"`
+	UpdateRevision     string `json:"updateRevision"`
+	CurrentReplicas    int32  `json:"currentReplicas"`
+	UpdatedReplicas    int32  `json:"updatedReplicas"`
+	ReadyReplicas      int32  `json:"readyReplicas"`
+	Generation         int64  `json:"generation"`
+	ObservedGeneration int64  `json:"observedGeneration"`
+}
+
+// StatefulSetRolloutStatus returns the rollout status of a StatefulSet
+func (w *WorkloadService) StatefulSetRolloutStatus(ctx context.Context, ns, name string) (*StatefulSetRolloutStatus, error) {
+	sts, err := w.getStatefulSet(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	return statefulSetStatus(sts), nil
+}
+
+// SetStatefulSetPartition sets spec.updateStrategy.rollingUpdate.partition, so only pods
+// with an ordinal at or above it are moved to the update revision
+func (w *WorkloadService) SetStatefulSetPartition(ctx context.Context, ns, name string, partition int32) (*StatefulSetRolloutStatus, error) {
+	sts, err := w.getStatefulSet(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return nil, fmt.Errorf("statefulset %s: %w", name, ErrOnDeleteStrategy)
+	}
+	replicas := statefulSetReplicas(sts)
+	if partition < 0 || partition > replicas {
+		return nil, &InvalidPartitionError{Partition: partition, Replicas: replicas}
+	}
+
+	patch, err := partitionPatch(sts, partition)
+	if err != nil {
+		return nil, err
+	}
+	sts, err = w.client.AppsV1().StatefulSets(ns).Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch statefulset %s: %w", name, err)
+	}
+	return statefulSetStatus(sts), nil
+}
+
+// ErrOnDeleteStrategy is returned when setting the partition of a StatefulSet that is not rolling updated
+var ErrOnDeleteStrategy = errors.New("the OnDelete update strategy has no partition")
+
+// InvalidPartitionError reports a partition outside [0, replicas]
+type InvalidPartitionError struct {
+	Partition int32
+	Replicas  int32
+}
+
+func (e *InvalidPartitionError) Error() string {
+	return fmt.Sprintf("partition %d must be between 0 and %d replicas", e.Partition, e.Replicas)
+}
+
+// partitionPatch builds the JSON patch setting the partition. JSON patch add needs the
+// parent to exist, so the whole rollingUpdate object is added when it is missing.
+func partitionPatch(sts *appsv1.StatefulSet, partition int32) ([]byte, error) {
+	type operation struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}
+
+	op := operation{Op: "add", Path: "/spec/updateStrategy/rollingUpdate/partition", Value: partition}
+	if sts.Spec.UpdateStrategy.RollingUpdate == nil {
+		op = operation{Op: "add", Path: "/spec/updateStrategy/rollingUpdate", Value: map[string]int32{"partition": partition}}
+	}
+	return json.Marshal([]operation{op})
+}
+
+func statefulSetReplicas(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas != nil {
+		return *sts.Spec.Replicas
+	}
+	return 1
+}
+
+func statefulSetStatus(sts *appsv1.StatefulSet) *StatefulSetRolloutStatus {
+	status := &StatefulSetRolloutStatus{
+		Name:               sts.Name,
+		Namespace:          sts.Namespace,
+		Strategy:           string(sts.Spec.UpdateStrategy.Type),
+		Replicas:           statefulSetReplicas(sts),
+		CurrentRevision:    sts.Status.CurrentRevision,
+		UpdateRevision:     sts.Status.UpdateRevision,
+		CurrentReplicas:    sts.Status.CurrentReplicas,
+		UpdatedReplicas:    sts.Status.UpdatedReplicas,
+		ReadyReplicas:      sts.Status.ReadyReplicas,
+		Generation:         sts.Generation,
+		ObservedGeneration: sts.Status.ObservedGeneration,
+	}
+	if rollingUpdate := sts.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		status.Partition = *rollingUpdate.Partition
+	}
+
+	// Pods below the partition stay on the current revision by design
+	wantUpdated := status.Replicas - status.Partition
+	switch {
+	case sts.Generation > sts.Status.ObservedGeneration:
+		status.Message = "waiting for statefulset spec update to be observed"
+	case status.Strategy == string(appsv1.OnDeleteStatefulSetStrategyType):
+		status.Complete = true
+		status.Message = "statefulset uses the OnDelete update strategy, pods are updated when deleted"
+	case status.UpdatedReplicas < wantUpdated:
+		status.Message = fmt.Sprintf("%d of %d pods above the partition are on the update revision", status.UpdatedReplicas, wantUpdated)
+	case status.ReadyReplicas < status.Replicas:
+		status.Message = fmt.Sprintf("%d of %d pods are ready", status.ReadyReplicas, status.Replicas)
+	case status.Partition > 0:
+		status.Complete = true
+		status.Message = fmt.Sprintf("partitioned rollout complete: %d pods on %s, %d on %s", status.UpdatedReplicas, status.UpdateRevision, status.CurrentReplicas, status.CurrentRevision)
+	default:
+		status.Complete = true
+		status.Message = fmt.Sprintf("statefulset %q successfully rolled out", sts.Name)
+	}
+	return status
+}
+
+func (w *WorkloadService) getStatefulSet(ctx context.Context, ns, name string) (*appsv1.StatefulSet, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "statefulset name"}
+	}
+
+	var sts *appsv1.StatefulSet
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		sts, err = w.client.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset %s: %w", name, err)
+	}
+	return sts, nil
+}
+
+func (w *WorkloadService) getDeployment(ctx context.Context, ns, name string) (*appsv1.Deployment, error) {
+	if name == "" {
+		return nil, &EmptyArgumentError{Argument: "deployment name"}
+	}
+
+	var deployment *appsv1.Deployment
+	err := retry.Do(ctx, "get", func(int) (err error) {
+		deployment, err = w.client.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %s: %w", name, err)
+	}
+	return deployment, nil
+}
+
+// rolloutStatus follows the checks of kubectl rollout status
+func rolloutStatus(d *appsv1.Deployment) *RolloutStatus {
+	status := &RolloutStatus{
+		Name:                d.Name,
+		Namespace:           d.Namespace,
+		Paused:              d.Spec.Paused,
+		Revision:            d.Annotations[revisionAnnotation],
+		ChangeCause:         d.Annotations[changeCauseAnnotation],
+		Generation:          d.Generation,
+		ObservedGeneration:  d.Status.ObservedGeneration,
+		Replicas:            d.Status.Replicas,
+		UpdatedReplicas:     d.Status.UpdatedReplicas,
+		ReadyReplicas:       d.Status.ReadyReplicas,
+		AvailableReplicas:   d.Status.AvailableReplicas,
+		UnavailableReplicas: d.Status.UnavailableReplicas,
+	}
+
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+
+	switch {
+	case d.Spec.Paused:
+		status.Message = "deployment is paused, resume it to continue the rollout"
+	case d.Generation > d.Status.ObservedGeneration:
+		status.Message = "waiting for deployment spec update to be observed"
+	case progressDeadlineExceeded(d):
+		status.Stalled = true
+		status.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", d.Name)
+	case d.Status.UpdatedReplicas < desired:
+		status.Message = fmt.Sprintf("%d of %d new replicas have been updated", d.Status.UpdatedReplicas, desired)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	default:
+		status.Complete = true
+		status.Message = fmt.Sprintf("deployment %q successfully rolled out", d.Name)
+	}
+	return status
+}
+
+func progressDeadlineExceeded(d *appsv1.Deployment) bool {
+	for _, condition := range d.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing {
+			return condition.Reason == "ProgressDeadlineExceeded"
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestSetStatefulSetPartition(t *testing.T) {
+	statefulSet := func(strategy appsv1.StatefulSetUpdateStrategy) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](5), UpdateStrategy: strategy},
+		}
+	}
+	rolling := appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType}
+	partitioned := appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: ptr.To[int32](4)},
+	}
+
+	tests := []struct {
+		name      string
+		sts       *appsv1.StatefulSet
+		partition int32
+		// wantPatch is the exact JSON patch sent, none when empty
+		wantPatch string
+		wantErr   func(error) bool
+	}{
+		{
+			name:      "rolling update added",
+			sts:       statefulSet(rolling),
+			partition: 3,
+			wantPatch: `[{"op":"add","path":"/spec/updateStrategy/rollingUpdate","value":{"partition":3}}]`,
+		},
+		{
+			name:      "partition stepped down",
+			sts:       statefulSet(partitioned),
+			partition: 2,
+			wantPatch: `[{"op":"add","path":"/spec/updateStrategy/rollingUpdate/partition","value":2}]`,
+		},
+		{
+			name:      "partition to zero",
+			sts:       statefulSet(partitioned),
+			partition: 0,
+			wantPatch: `[{"op":"add","path":"/spec/updateStrategy/rollingUpdate/partition","value":0}]`,
+		},
+		{
+			name:      "partition of all replicas",
+			sts:       statefulSet(partitioned),
+			partition: 5,
+			wantPatch: `[{"op":"add","path":"/spec/updateStrategy/rollingUpdate/partition","value":5}]`,
+		},
+		{
+			name:      "above replicas",
+			sts:       statefulSet(partitioned),
+			partition: 6,
+			wantErr:   func(err error) bool { var invalid *InvalidPartitionError; return errors.As(err, &invalid) },
+		},
+		{
+			name:      "negative",
+			sts:       statefulSet(partitioned),
+			partition: -1,
+			wantErr:   func(err error) bool { var invalid *InvalidPartitionError; return errors.As(err, &invalid) },
+		},
+		{
+			name:      "OnDelete strategy",
+			sts:       statefulSet(appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}),
+			partition: 1,
+			wantErr:   func(err error) bool { return errors.Is(err, ErrOnDeleteStrategy) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.sts)
+			status, err := NewWorkloadService(client, nil).SetStatefulSetPartition(context.Background(), "default", "db", tt.partition)
+
+			var patches []k8stesting.PatchAction
+			for _, action := range client.Actions() {
+				if patch, ok := action.(k8stesting.PatchAction); ok {
+					patches = append(patches, patch)
+				}
+			}
+
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("SetStatefulSetPartition() error = %v, want a matching error", err)
+				}
+				if len(patches) != 0 {
+					t.Errorf("statefulset patched after a rejected partition: %s", patches[0].GetPatch())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(patches) != 1 {
+				t.Fatalf("got %d patches, want 1", len(patches))
+			}
+			if patches[0].GetPatchType() != types.JSONPatchType {
+				t.Errorf("patch type = %s, want %s", patches[0].GetPatchType(), types.JSONPatchType)
+			}
+			if got := string(patches[0].GetPatch()); got != tt.wantPatch {
+				t.Errorf("patch = %s, want %s", got, tt.wantPatch)
+			}
+			if status.Partition != tt.partition {
+				t.Errorf("status partition = %d, want %d", status.Partition, tt.partition)
+			}
+		})
+	}
+}
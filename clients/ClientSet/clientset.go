@@ -6,6 +6,8 @@
 // For deployment, the GVK is apps/v1/Deployment = clientset.AppsV1().Deployments()
 
 // go run clients/ClientSet/clientset.go --namespace=kube-system
+// go run clients/ClientSet/clientset.go --namespace=kube-system --watch
+// go run clients/ClientSet/clientset.go --namespace=default --demo=deployment
 
 package main
 
@@ -14,38 +16,48 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"kgent-api/pkg/clientutil"
+
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/utils/ptr"
 )
 
+// fieldManager identifies the example's writes in managedFields
+const fieldManager = "clientset-example"
+
+const demoDeployment = "clientset-example"
+
 func main() {
 	// Set up command line flags
-	var kubeconfig *string
 	var namespace *string
 
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
+	connection := clientutil.RegisterFlags(nil)
 	namespace = flag.String("namespace", "kube-system", "namespace to list pods from")
+	watchPods := flag.Bool("watch", false, "after listing, stream pod phase changes until interrupted")
+	demo := flag.String("demo", "", "run a walkthrough instead of listing: deployment creates, scales and deletes a Deployment")
 	flag.Parse()
 
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Build config from the kubeconfig, or the in-cluster config
+	config, err := connection.Config()
 	if err != nil {
-		log.Fatalf("Error building kubeconfig: %v", err)
+		log.Fatalf("Error building config: %v", err)
 	}
 
 	// Create clientset
@@ -54,6 +66,20 @@ func main() {
 		log.Fatalf("Error creating Kubernetes client: %v", err)
 	}
 
+	if *demo != "" {
+		if *demo != "deployment" {
+			log.Fatalf("Unknown demo %q, expected deployment", *demo)
+		}
+		demoCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		demoCtx, cancelDemo := context.WithTimeout(demoCtx, 5*time.Minute)
+		defer cancelDemo()
+		if err := deploymentDemo(demoCtx, clientset, *namespace); err != nil {
+			log.Fatalf("Deployment demo failed: %v", err)
+		}
+		return
+	}
+
 	// Get pods from the specified namespace
 	pods, err := clientset.CoreV1().Pods(*namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
@@ -62,14 +88,178 @@ func main() {
 
 	if len(pods.Items) == 0 {
 		fmt.Printf("No pods found in namespace %s\n", *namespace)
-		return
+	} else {
+		// Display pod information
+		fmt.Printf("Found %d pods in namespace %s:\n", len(pods.Items), *namespace)
+		for _, pod := range pods.Items {
+			printPodInfo(pod)
+		}
 	}
 
-	// Display pod information
-	fmt.Printf("Found %d pods in namespace %s:\n", len(pods.Items), *namespace)
+	if *watchPods {
+		watchCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := watchPodPhases(watchCtx, clientset, *namespace, pods); err != nil {
+			log.Fatalf("Error watching pods: %v", err)
+		}
+	}
+}
+
+// watchPodPhases prints pods being added and deleted and their phase changes, starting
+// after the list. The retry watcher restarts watches the server closes from the last
+// resourceVersion seen.
+func watchPodPhases(ctx context.Context, clientset kubernetes.Interface, namespace string, pods *corev1.PodList) error {
+	phases := map[string]corev1.PodPhase{}
 	for _, pod := range pods.Items {
-		printPodInfo(pod)
+		phases[pod.Name] = pod.Status.Phase
+	}
+
+	w, err := watchtools.NewRetryWatcher(pods.ResourceVersion, &cache.ListWatch{
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return clientset.CoreV1().Pods(namespace).Watch(ctx, options)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	fmt.Printf("Watching pod phases in namespace %s, press Ctrl+C to stop:\n", namespace)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.Done():
+			return fmt.Errorf("watch stopped")
+		case event := <-w.ResultChan():
+			if event.Type == watch.Error {
+				// An expired resourceVersion can't be resumed from
+				return apierrors.FromObject(event.Object)
+			}
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+
+			switch event.Type {
+			case watch.Added:
+				fmt.Printf("%s ADDED    %s (%s)\n", time.Now().Format(time.TimeOnly), pod.Name, pod.Status.Phase)
+			case watch.Modified:
+				if phases[pod.Name] == pod.Status.Phase {
+					continue
+				}
+				fmt.Printf("%s PHASE    %s %s -> %s\n", time.Now().Format(time.TimeOnly), pod.Name, phases[pod.Name], pod.Status.Phase)
+			case watch.Deleted:
+				fmt.Printf("%s DELETED  %s\n", time.Now().Format(time.TimeOnly), pod.Name)
+				delete(phases, pod.Name)
+				continue
+			}
+			phases[pod.Name] = pod.Status.Phase
+		}
+	}
+}
+
+// deploymentDemo walks through the lifecycle of a Deployment with the typed client: create,
+// wait until Available, scale through the scale subresource and delete
+func deploymentDemo(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
+	deployments := clientset.AppsV1().Deployments(namespace)
+	labels := map[string]string{"app": demoDeployment}
+
+	fmt.Printf("1. Creating Deployment %s/%s\n", namespace, demoDeployment)
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: demoDeployment, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "nginx",
+						Image: "nginx:1.27",
+						Ports: []corev1.ContainerPort{{ContainerPort: 80}},
+					}},
+				},
+			},
+		},
+	}
+	created, err := deployments.Create(ctx, deploy, metav1.CreateOptions{FieldManager: fieldManager})
+	switch {
+	case apierrors.IsAlreadyExists(err):
+		// A previous run may have been interrupted, so carry on with the existing object
+		created, err = deployments.Get(ctx, demoDeployment, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get the existing Deployment: %w", err)
+		}
+		fmt.Printf("   Already exists, adopting it (UID %s)\n", created.UID)
+	case err != nil:
+		return fmt.Errorf("failed to create the Deployment: %w", err)
+	default:
+		fmt.Printf("   Created (UID %s)\n", created.UID)
+	}
+
+	fmt.Println("2. Waiting for the Deployment to become Available")
+	if err := waitForDeployment(ctx, clientset, namespace, 1); err != nil {
+		return err
+	}
+
+	fmt.Println("3. Scaling to 3 replicas")
+	scale, err := deployments.GetScale(ctx, demoDeployment, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the scale: %w", err)
+	}
+	scale.Spec.Replicas = 3
+	// The scale carries the resourceVersion it was read at, so a concurrent change fails
+	// with a conflict instead of being overwritten
+	if _, err := deployments.UpdateScale(ctx, demoDeployment, scale, metav1.UpdateOptions{FieldManager: fieldManager}); err != nil {
+		return fmt.Errorf("failed to scale the Deployment: %w", err)
+	}
+	if err := waitForDeployment(ctx, clientset, namespace, 3); err != nil {
+		return err
+	}
+
+	fmt.Println("4. Deleting the Deployment and its pods")
+	err = deployments.Delete(ctx, demoDeployment, metav1.DeleteOptions{PropagationPolicy: ptr.To(metav1.DeletePropagationForeground)})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the Deployment: %w", err)
+	}
+	fmt.Println("   Deleted")
+	return nil
+}
+
+// waitForDeployment watches the demo Deployment until the controller has observed its
+// latest spec and replicas pods are available. UntilWithSync lists first, so a Deployment
+// that is already there counts without waiting for a change.
+func waitForDeployment(ctx context.Context, clientset kubernetes.Interface, namespace string, replicas int32) error {
+	lw := cache.NewListWatchFromClient(clientset.AppsV1().RESTClient(), "deployments", namespace,
+		fields.OneTermEqualSelector("metadata.name", demoDeployment))
+
+	_, err := watchtools.UntilWithSync(ctx, lw, &appsv1.Deployment{}, nil, func(event watch.Event) (bool, error) {
+		if event.Type == watch.Deleted {
+			return false, fmt.Errorf("the Deployment was deleted while waiting")
+		}
+		deploy, ok := event.Object.(*appsv1.Deployment)
+		if !ok {
+			return false, nil
+		}
+		fmt.Printf("   %d/%d replicas available\n", deploy.Status.AvailableReplicas, replicas)
+		return deploy.Status.ObservedGeneration >= deploy.Generation &&
+			deploy.Status.AvailableReplicas == replicas &&
+			deploymentAvailable(deploy), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting for %d available replicas: %w", replicas, err)
+	}
+	return nil
+}
+
+func deploymentAvailable(deploy *appsv1.Deployment) bool {
+	for _, condition := range deploy.Status.Conditions {
+		if condition.Type == appsv1.DeploymentAvailable {
+			return condition.Status == corev1.ConditionTrue
+		}
 	}
+	return false
 }
 
 func printPodInfo(pod corev1.Pod) {
@@ -8,44 +8,50 @@
 // `kubectl api-resources` is a command that uses DiscoveryClient to list all API resources in the cluster.
 
 // go run clients/DiscoveryClient/discoveryclient.go --resources
+// go run clients/DiscoveryClient/discoveryclient.go --find=deploy
+// go run clients/DiscoveryClient/discoveryclient.go --schema=apps/v1/Deployment
 
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"path/filepath"
+	"os"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"kgent-api/pkg/clientutil"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
 )
 
 func main() {
 	// Set up command line flags
-	var kubeconfig *string
 	var showResources *bool
 
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
+	connection := clientutil.RegisterFlags(nil)
 	showResources = flag.Bool("resources", false, "show API resources in addition to groups")
+	find := flag.String("find", "", "search all groups for a kind, resource or short name (case-insensitive) instead of listing groups")
+	schemaArg := flag.String("schema", "", "print the OpenAPI v3 schema of group/version/Kind, e.g. apps/v1/Deployment or v1/Pod")
+	schemaDepth := flag.Int("schema-depth", 3, "how many levels of nested fields --schema prints")
 	flag.Parse()
 
 	// Create a context with timeout
 	_, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Build config from the kubeconfig, or the in-cluster config
+	config, err := connection.Config()
 	if err != nil {
-		log.Fatalf("Error building kubeconfig: %v", err)
+		log.Fatalf("Error building config: %v", err)
 	}
 
 	// Create discovery client
@@ -54,6 +60,23 @@ func main() {
 		log.Fatalf("Error creating discovery client: %v", err)
 	}
 
+	if *find != "" || *schemaArg != "" {
+		ok := true
+		if *find != "" {
+			ok = findResources(discoveryClient, *find) && ok
+		}
+		if *schemaArg != "" {
+			if err := printSchema(discoveryClient, *schemaArg, *schemaDepth); err != nil {
+				fmt.Fprintf(os.Stderr, "Error printing schema of %s: %v\n", *schemaArg, err)
+				ok = false
+			}
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Get server API groups
 	apiGroups, err := discoveryClient.ServerGroups()
 	if err != nil {
@@ -108,3 +131,224 @@ func main() {
 	fmt.Printf("Platform: %s/%s\n", serverVersion.Platform, serverVersion.GoVersion)
 	fmt.Printf("Build Date: %s\n", serverVersion.BuildDate)
 }
+
+// findResources prints the group/versions serving kinds, resources or short names equal to
+// query, ignoring case. Groups that fail discovery, like aggregated APIs whose backing
+// service is down, are reported and skipped. It returns false when nothing matched.
+func findResources(discoveryClient discovery.DiscoveryInterface, query string) bool {
+	_, resourceLists, err := discoveryClient.ServerGroupsAndResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			fmt.Fprintf(os.Stderr, "Error fetching API resources: %v\n", err)
+			return false
+		}
+		for gv, groupErr := range err.(*discovery.ErrGroupDiscoveryFailed).Groups {
+			fmt.Fprintf(os.Stderr, "Warning: skipping unavailable group %s: %v\n", gv, groupErr)
+		}
+	}
+
+	query = strings.ToLower(query)
+	found := false
+	fmt.Printf("=== Resources matching %q ===\n", query)
+	for _, list := range resourceLists {
+		for _, resource := range list.APIResources {
+			// Subresources such as pods/log are not searched
+			if strings.Contains(resource.Name, "/") || !resourceMatches(resource.Name, resource.SingularName, resource.Kind, resource.ShortNames, query) {
+				continue
+			}
+			found = true
+
+			namespaced := "cluster-scoped"
+			if resource.Namespaced {
+				namespaced = "namespaced"
+			}
+			fmt.Printf("%s (%s)\n", list.GroupVersion, namespaced)
+			fmt.Printf("  Resource: %s\n", resource.Name)
+			fmt.Printf("  Kind: %s\n", resource.Kind)
+			if len(resource.ShortNames) > 0 {
+				fmt.Printf("  Short names: %s\n", strings.Join(resource.ShortNames, ", "))
+			}
+			fmt.Printf("  Verbs: %s\n", strings.Join(resource.Verbs, ", "))
+		}
+	}
+	if !found {
+		fmt.Println("No matching resources found.")
+	}
+	return found
+}
+
+func resourceMatches(name, singularName, kind string, shortNames []string, query string) bool {
+	if strings.ToLower(name) == query || strings.ToLower(singularName) == query || strings.ToLower(kind) == query {
+		return true
+	}
+	return slices.ContainsFunc(shortNames, func(shortName string) bool {
+		return strings.ToLower(shortName) == query
+	})
+}
+
+// printSchema prints the fields of a kind from the OpenAPI v3 document of its group/version,
+// following references up to depth levels of nesting
+func printSchema(discoveryClient discovery.DiscoveryInterface, target string, depth int) error {
+	gvk, err := parseGVK(target)
+	if err != nil {
+		return err
+	}
+
+	paths, err := discoveryClient.OpenAPIV3().Paths()
+	if err != nil {
+		return fmt.Errorf("failed to fetch the OpenAPI v3 index: %w", err)
+	}
+	path := "apis/" + gvk.GroupVersion().String()
+	if gvk.Group == "" {
+		path = "api/" + gvk.Version
+	}
+	groupVersion, ok := paths[path]
+	if !ok {
+		return fmt.Errorf("the server publishes no OpenAPI v3 document for %s", gvk.GroupVersion())
+	}
+
+	// An aggregated API whose backing service is down fails here, not on the index
+	data, err := groupVersion.Schema("application/json")
+	if err != nil {
+		return fmt.Errorf("failed to fetch the OpenAPI v3 document of %s, the group may be temporarily unavailable: %w", gvk.GroupVersion(), err)
+	}
+	var doc spec3.OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse the OpenAPI v3 document of %s: %w", gvk.GroupVersion(), err)
+	}
+	if doc.Components == nil {
+		return fmt.Errorf("the OpenAPI v3 document of %s has no schemas", gvk.GroupVersion())
+	}
+
+	name, root := schemaForKind(doc.Components.Schemas, gvk)
+	if root == nil {
+		return fmt.Errorf("no schema for %s in the OpenAPI v3 document of %s", gvk.Kind, gvk.GroupVersion())
+	}
+
+	fmt.Printf("=== %s (%s) ===\n", gvk.Kind, name)
+	if root.Description != "" {
+		fmt.Println(firstSentence(root.Description))
+	}
+	printProperties(doc.Components.Schemas, root, "", depth, map[string]bool{name: true})
+	return nil
+}
+
+// parseGVK parses group/version/Kind, or version/Kind for the core group
+func parseGVK(arg string) (schema.GroupVersionKind, error) {
+	parts := strings.Split(arg, "/")
+	switch len(parts) {
+	case 2:
+		return schema.GroupVersionKind{Version: parts[0], Kind: parts[1]}, nil
+	case 3:
+		return schema.GroupVersionKind{Group: parts[0], Version: parts[1], Kind: parts[2]}, nil
+	}
+	return schema.GroupVersionKind{}, fmt.Errorf("expected group/version/Kind or version/Kind, got %q", arg)
+}
+
+// schemaForKind finds the schema tagged with gvk in x-kubernetes-group-version-kind
+func schemaForKind(schemas map[string]*spec.Schema, gvk schema.GroupVersionKind) (string, *spec.Schema) {
+	for name, s := range schemas {
+		gvks, _ := s.Extensions["x-kubernetes-group-version-kind"].([]interface{})
+		for _, entry := range gvks {
+			m, _ := entry.(map[string]interface{})
+			if m["group"] == gvk.Group && m["version"] == gvk.Version && strings.EqualFold(fmt.Sprint(m["kind"]), gvk.Kind) {
+				return name, s
+			}
+		}
+	}
+	return "", nil
+}
+
+// printProperties prints one line per property with its type, marking required ones, and
+// recurses into objects and arrays of objects. seen guards against recursive schemas such
+// as JSONSchemaProps.
+func printProperties(schemas map[string]*spec.Schema, s *spec.Schema, indent string, depth int, seen map[string]bool) {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := s.Properties[name]
+		refName, resolved := resolveSchema(schemas, &property)
+
+		required := ""
+		if slices.Contains(s.Required, name) {
+			required = " (required)"
+		}
+		fmt.Printf("%s  %s <%s>%s\n", indent, name, schemaType(schemas, resolved), required)
+
+		// Arrays and maps print the fields of their elements
+		nested, nestedRef := resolved, refName
+		if resolved.Items != nil && resolved.Items.Schema != nil {
+			nestedRef, nested = resolveSchema(schemas, resolved.Items.Schema)
+		} else if resolved.AdditionalProperties != nil && resolved.AdditionalProperties.Schema != nil {
+			nestedRef, nested = resolveSchema(schemas, resolved.AdditionalProperties.Schema)
+		}
+		if depth <= 1 || len(nested.Properties) == 0 || seen[nestedRef] {
+			continue
+		}
+		if nestedRef != "" {
+			seen[nestedRef] = true
+		}
+		printProperties(schemas, nested, indent+"  ", depth-1, seen)
+		if nestedRef != "" {
+			delete(seen, nestedRef)
+		}
+	}
+}
+
+// resolveSchema follows $ref, directly or wrapped in allOf as the API server publishes
+// fields with defaults, and returns the referenced schema's name
+func resolveSchema(schemas map[string]*spec.Schema, s *spec.Schema) (string, *spec.Schema) {
+	ref := s.Ref.String()
+	if ref == "" && len(s.AllOf) == 1 {
+		ref = s.AllOf[0].Ref.String()
+	}
+	if ref == "" {
+		return "", s
+	}
+	name := strings.TrimPrefix(ref, "#/components/schemas/")
+	if resolved, ok := schemas[name]; ok {
+		return name, resolved
+	}
+	return name, s
+}
+
+// schemaType describes a schema like kubectl explain does, e.g. string, []Container or map[string]string
+func schemaType(schemas map[string]*spec.Schema, s *spec.Schema) string {
+	switch {
+	case s.Items != nil && s.Items.Schema != nil:
+		refName, item := resolveSchema(schemas, s.Items.Schema)
+		return "[]" + typeName(refName, item)
+	case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+		refName, value := resolveSchema(schemas, s.AdditionalProperties.Schema)
+		return "map[string]" + typeName(refName, value)
+	}
+	return typeName("", s)
+}
+
+func typeName(refName string, s *spec.Schema) string {
+	if refName != "" && len(s.Properties) > 0 {
+		// io.k8s.api.core.v1.Container -> Container
+		return refName[strings.LastIndex(refName, ".")+1:]
+	}
+	if len(s.Type) > 0 {
+		if s.Format != "" {
+			return s.Type[0] + " (" + s.Format + ")"
+		}
+		return s.Type[0]
+	}
+	if refName != "" {
+		return refName[strings.LastIndex(refName, ".")+1:]
+	}
+	return "Object"
+}
+
+func firstSentence(description string) string {
+	if i := strings.Index(description, ". "); i >= 0 {
+		return description[:i+1]
+	}
+	return description
+}
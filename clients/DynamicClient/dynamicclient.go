@@ -1,56 +1,87 @@
 // DynamicClient is a commonly used client that can be used to interact with any resource in the cluster.
-// This is a simple example of how to use the DynamicClient to list resources in a namespace.
+// This is a simple example of how to use the DynamicClient to list, get, create, patch, delete and
+// watch resources of any GVR, which makes it a minimal kubectl.
 
 // GVR: Group Version Resource
 // For pod, the GVR is core/v1/pods
 // For deployment, the GVR is apps/v1/deployments
 
 // go run clients/DynamicClient/dynamicclient.go --namespace=kube-system --group=apps --version=v1 --resource=deployments
+// go run clients/DynamicClient/dynamicclient.go --namespace=default --group= --resource=configmaps --action=create --file=configmaps.yaml
+// go run clients/DynamicClient/dynamicclient.go --namespace=default --group= --resource=configmaps --action=patch --name=demo --patch-file=patch.yaml
+// go run clients/DynamicClient/dynamicclient.go --namespace=default --group= --resource=pods --action=watch
 
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"kgent-api/pkg/clientutil"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// Exit codes, so scripts can tell failures apart
+const (
+	exitFailed    = 1
+	exitUsage     = 2
+	exitNotFound  = 3
+	exitForbidden = 4
+	exitConflict  = 5
+	exitInvalid   = 6
 )
 
+const fieldManager = "dynamicclient-example"
+
+var patchTypes = map[string]types.PatchType{
+	"merge":     types.MergePatchType,
+	"json":      types.JSONPatchType,
+	"strategic": types.StrategicMergePatchType,
+	"apply":     types.ApplyPatchType,
+}
+
 func main() {
 	// Set up command line flags
-	var kubeconfig *string
 	var namespace, group, version, resource *string
 
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
+	connection := clientutil.RegisterFlags(nil)
 
-	namespace = flag.String("namespace", "kube-system", "namespace to list resources from")
+	namespace = flag.String("namespace", "kube-system", "namespace of the resources, empty for cluster-scoped resources")
 	group = flag.String("group", "apps", "API group of the resource")
 	version = flag.String("version", "v1", "API version of the resource")
-	resource = flag.String("resource", "deployments", "resource type to list")
+	resource = flag.String("resource", "deployments", "resource type to act on")
+	action := flag.String("action", "list", "what to do: list, get, create, patch, delete or watch")
+	name := flag.String("name", "", "name of the object for get, patch and delete; limits watch to one object")
+	file := flag.String("file", "", "YAML or JSON manifest for create, may hold several documents; - reads stdin")
+	patchFile := flag.String("patch-file", "", "YAML or JSON patch for patch; - reads stdin")
+	patchType := flag.String("patch-type", "merge", "patch type: merge, json, strategic or apply")
+	gracePeriod := flag.Int64("grace-period", -1, "seconds before a deleted object is removed, -1 for the resource's default")
 
 	flag.Parse()
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Build config from the kubeconfig, or the in-cluster config
+	config, err := connection.Config()
 	if err != nil {
-		log.Fatalf("Error building kubeconfig: %v", err)
+		log.Fatalf("Error building config: %v", err)
 	}
 
 	// Create dynamic client
@@ -66,19 +97,88 @@ func main() {
 		Resource: *resource,
 	}
 
-	// Get resources from the specified namespace
-	resources, err := dynamicClient.Resource(gvr).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+	// Cluster-scoped resources have no namespace
+	var resourceInterface dynamic.ResourceInterface = dynamicClient.Resource(gvr)
+	if *namespace != "" {
+		resourceInterface = dynamicClient.Resource(gvr).Namespace(*namespace)
+	}
+
+	// Watches run until interrupted, everything else gets a timeout
+	if *action == "watch" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		watchResources(ctx, resourceInterface, *name)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch *action {
+	case "list":
+		listResources(ctx, resourceInterface, *resource, *namespace)
+	case "get":
+		requireFlag("name", *name)
+		obj, err := resourceInterface.Get(ctx, *name, metav1.GetOptions{})
+		if err != nil {
+			fail("get", *name, err)
+		}
+		printYAML(obj)
+	case "create":
+		requireFlag("file", *file)
+		if code := createResources(ctx, dynamicClient, gvr, *namespace, *file); code != 0 {
+			os.Exit(code)
+		}
+	case "patch":
+		requireFlag("name", *name)
+		requireFlag("patch-file", *patchFile)
+		pt, ok := patchTypes[*patchType]
+		if !ok {
+			usage("unknown patch type %q, expected merge, json, strategic or apply", *patchType)
+		}
+		patch, err := readPatch(*patchFile)
+		if err != nil {
+			usage("%v", err)
+		}
+		options := metav1.PatchOptions{FieldManager: fieldManager}
+		if pt == types.ApplyPatchType {
+			force := true
+			options.Force = &force
+		}
+		obj, err := resourceInterface.Patch(ctx, *name, pt, patch, options)
+		if err != nil {
+			fail("patch", *name, err)
+		}
+		fmt.Printf("Patched %s %s (resourceVersion %s)\n", *resource, obj.GetName(), obj.GetResourceVersion())
+	case "delete":
+		requireFlag("name", *name)
+		options := metav1.DeleteOptions{}
+		if *gracePeriod >= 0 {
+			options.GracePeriodSeconds = gracePeriod
+		}
+		if err := resourceInterface.Delete(ctx, *name, options); err != nil {
+			fail("delete", *name, err)
+		}
+		fmt.Printf("Deleted %s %s\n", *resource, *name)
+	default:
+		usage("unknown action %q, expected list, get, create, patch, delete or watch", *action)
+	}
+}
+
+// listResources prints the objects of a resource
+func listResources(ctx context.Context, resourceInterface dynamic.ResourceInterface, resource, namespace string) {
+	resources, err := resourceInterface.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		log.Fatalf("Error listing resources: %v", err)
+		fail("list", resource, err)
 	}
 
 	if len(resources.Items) == 0 {
-		fmt.Printf("No %s resources found in namespace %s\n", *resource, *namespace)
+		fmt.Printf("No %s resources found in namespace %s\n", resource, namespace)
 		return
 	}
 
 	// Display resource information
-	fmt.Printf("Found %d %s resources in namespace %s:\n", len(resources.Items), *resource, *namespace)
+	fmt.Printf("Found %d %s resources in namespace %s:\n", len(resources.Items), resource, namespace)
 	for _, item := range resources.Items {
 		fmt.Printf("Resource: %s\n", item.GetName())
 		fmt.Printf("  UID: %s\n", item.GetUID())
@@ -103,3 +203,182 @@ func main() {
 		fmt.Println()
 	}
 }
+
+// createResources creates every document of file, in its own namespace or namespace. All
+// documents are attempted; the exit code of the first failure is returned.
+func createResources(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace, file string) int {
+	data, err := readInput(file)
+	if err != nil {
+		usage("%v", err)
+	}
+
+	code := 0
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
+			return exitInvalid
+		}
+		// Skip empty documents, e.g. after a trailing ---
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = namespace
+		}
+		var resourceInterface dynamic.ResourceInterface = dynamicClient.Resource(gvr)
+		if ns != "" {
+			resourceInterface = dynamicClient.Resource(gvr).Namespace(ns)
+		}
+
+		created, err := resourceInterface.Create(ctx, obj, metav1.CreateOptions{FieldManager: fieldManager})
+		if err != nil {
+			c := report("create", obj.GetName(), err)
+			if code == 0 {
+				code = c
+			}
+			continue
+		}
+		fmt.Printf("Created %s %s (UID %s)\n", gvr.Resource, created.GetName(), created.GetUID())
+	}
+	return code
+}
+
+// readPatch reads a patch, converting YAML to the JSON the API server expects
+func readPatch(file string) ([]byte, error) {
+	data, err := readInput(file)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patch %s: %w", file, err)
+	}
+	return patch, nil
+}
+
+func readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return data, nil
+}
+
+// watchResources prints ADDED, MODIFIED and DELETED lines until ctx is cancelled. Closed
+// watches restart from the last resourceVersion seen, expired ones from the current state.
+func watchResources(ctx context.Context, resourceInterface dynamic.ResourceInterface, name string) {
+	options := metav1.ListOptions{AllowWatchBookmarks: true}
+	if name != "" {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		w, err := resourceInterface.Watch(ctx, options)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				options.ResourceVersion = ""
+				continue
+			}
+			if code := report("watch", name, err); code == exitNotFound || code == exitForbidden {
+				os.Exit(code)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		for event := range w.ResultChan() {
+			switch event.Type {
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					fmt.Fprintln(os.Stderr, "Watch expired, restarting from the current state")
+					options.ResourceVersion = ""
+				} else {
+					fmt.Fprintf(os.Stderr, "Watch error: %v\n", err)
+				}
+			case watch.Bookmark:
+				if obj, ok := event.Object.(*unstructured.Unstructured); ok {
+					options.ResourceVersion = obj.GetResourceVersion()
+				}
+			default:
+				obj, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				options.ResourceVersion = obj.GetResourceVersion()
+				fmt.Printf("%-8s %s resourceVersion=%s\n", event.Type, obj.GetName(), obj.GetResourceVersion())
+			}
+		}
+		w.Stop()
+	}
+}
+
+func printYAML(obj *unstructured.Unstructured) {
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		log.Fatalf("Error printing %s: %v", obj.GetName(), err)
+	}
+	fmt.Print(string(data))
+}
+
+// report prints a readable message for an API error and returns its exit code
+func report(action, name string, err error) int {
+	code := exitFailed
+	message := err.Error()
+	switch {
+	case apierrors.IsNotFound(err):
+		code, message = exitNotFound, "not found"
+	case apierrors.IsForbidden(err):
+		code, message = exitForbidden, "forbidden, check the RBAC permissions of your user: "+err.Error()
+	case apierrors.IsUnauthorized(err):
+		code, message = exitForbidden, "unauthorized, check the credentials in your kubeconfig"
+	case apierrors.IsAlreadyExists(err):
+		code, message = exitConflict, "already exists"
+	case apierrors.IsConflict(err):
+		code, message = exitConflict, "conflict, the object was modified concurrently: "+err.Error()
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), apierrors.IsUnsupportedMediaType(err):
+		code = exitInvalid
+	}
+	if name != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s %s: %s\n", action, name, message)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", action, message)
+	}
+	return code
+}
+
+func fail(action, name string, err error) {
+	os.Exit(report(action, name, err))
+}
+
+func requireFlag(name, value string) {
+	if value == "" {
+		usage("--%s is required for this action", name)
+	}
+}
+
+func usage(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	flag.Usage()
+	os.Exit(exitUsage)
+}
@@ -4,46 +4,52 @@
 // Use HTTP request to list/get/create/update/delete resources in a namespace.
 
 // go run clients/RestClient/restclient.go --namespace=kube-system
+// go run clients/RestClient/restclient.go --action=create --name=restclient-example --protobuf
+// go run clients/RestClient/restclient.go --action=log --name=restclient-example
+// go run clients/RestClient/restclient.go --raw=/api/v1/namespaces
 
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"path/filepath"
+	"strconv"
 	"time"
 
+	"kgent-api/pkg/clientutil"
+
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
 func main() {
 	// Set up command line flags
-	var kubeconfig *string
 	var namespace *string
 
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
+	connection := clientutil.RegisterFlags(nil)
 	namespace = flag.String("namespace", "default", "namespace to list pods from")
+	action := flag.String("action", "list", "what to do with pods: list, create, delete, log or status")
+	name := flag.String("name", "restclient-example", "pod name for create, delete, log and status")
+	tailLines := flag.Int("tail", 20, "number of log lines to fetch with --action=log")
+	protobuf := flag.Bool("protobuf", false, "send and accept protobuf instead of JSON")
+	raw := flag.String("raw", "", "send a GET request to a raw path, e.g. /api/v1/namespaces, and pretty-print the JSON response")
 	flag.Parse()
 
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Build config from the kubeconfig, or the in-cluster config
+	config, err := connection.Config()
 	if err != nil {
-		log.Fatalf("Error building kubeconfig: %v", err)
+		log.Fatalf("Error building config: %v", err)
 	}
 
 	// Set up REST client configuration
@@ -51,12 +57,40 @@ func main() {
 	config.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
 	config.APIPath = "/api"
 
+	// Protobuf is only a content type change: the same scheme codecs serialize both, which
+	// is why typed objects work unchanged. It is smaller and faster to decode than JSON.
+	if *protobuf {
+		config.ContentType = runtime.ContentTypeProtobuf
+		config.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+		fmt.Println("Using protobuf for request and response bodies")
+	}
+
 	// Create REST client
 	restClient, err := rest.RESTClientFor(config)
 	if err != nil {
 		log.Fatalf("Error creating REST client: %v", err)
 	}
 
+	switch {
+	case *raw != "":
+		rawRequest(ctx, restClient, *raw)
+		return
+	case *action == "create":
+		createPod(ctx, restClient, *namespace, *name)
+		return
+	case *action == "delete":
+		deletePod(ctx, restClient, *namespace, *name)
+		return
+	case *action == "log":
+		podLog(ctx, restClient, *namespace, *name, *tailLines)
+		return
+	case *action == "status":
+		podStatus(ctx, restClient, *namespace, *name)
+		return
+	case *action != "list":
+		log.Fatalf("Unknown action %q, expected list, create, delete, log or status", *action)
+	}
+
 	// Execute the REST request
 	result := restClient.Get().
 		Resource("pods").
@@ -115,3 +149,109 @@ func printPodInfo(pod corev1.Pod) {
 
 	fmt.Println()
 }
+
+// createPod POSTs a small pod. Body serializes the typed object with the configured
+// content type, JSON or protobuf.
+func createPod(ctx context.Context, restClient *rest.RESTClient, namespace, name string) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"app": "restclient-example"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    "busybox",
+				Image:   "busybox:1.36",
+				Command: []string{"sh", "-c", "while true; do date; sleep 5; done"},
+			}},
+		},
+	}
+
+	created := &corev1.Pod{}
+	err := restClient.Post().
+		Namespace(namespace).
+		Resource("pods").
+		Body(pod).
+		Do(ctx).
+		Into(created)
+	if err != nil {
+		log.Fatalf("Error creating pod: %v", err)
+	}
+	fmt.Printf("Created pod %s (UID %s)\n", created.Name, created.UID)
+}
+
+// deletePod sends a DELETE for the pod
+func deletePod(ctx context.Context, restClient *rest.RESTClient, namespace, name string) {
+	err := restClient.Delete().
+		Namespace(namespace).
+		Resource("pods").
+		Name(name).
+		Body(&metav1.DeleteOptions{}).
+		Do(ctx).
+		Error()
+	if err != nil {
+		log.Fatalf("Error deleting pod: %v", err)
+	}
+	fmt.Printf("Deleted pod %s\n", name)
+}
+
+// podLog reads the log subresource. Logs are plain text, so the body is read as is
+// instead of being decoded into an object.
+func podLog(ctx context.Context, restClient *rest.RESTClient, namespace, name string, tailLines int) {
+	data, err := restClient.Get().
+		Namespace(namespace).
+		Resource("pods").
+		Name(name).
+		SubResource("log").
+		Param("tailLines", strconv.Itoa(tailLines)).
+		DoRaw(ctx)
+	if err != nil {
+		log.Fatalf("Error fetching log: %v", err)
+	}
+	fmt.Printf("Last %d log lines of pod %s:\n%s", tailLines, name, data)
+}
+
+// podStatus reads the status subresource, which returns the whole pod like a GET of the
+// pod itself but is authorized separately, as pods/status
+func podStatus(ctx context.Context, restClient *rest.RESTClient, namespace, name string) {
+	pod := &corev1.Pod{}
+	err := restClient.Get().
+		Namespace(namespace).
+		Resource("pods").
+		Name(name).
+		SubResource("status").
+		Do(ctx).
+		Into(pod)
+	if err != nil {
+		log.Fatalf("Error fetching status: %v", err)
+	}
+
+	fmt.Printf("Status of pod %s:\n", pod.Name)
+	fmt.Printf("  Phase: %s\n", pod.Status.Phase)
+	if pod.Status.StartTime != nil {
+		fmt.Printf("  Started: %s\n", pod.Status.StartTime)
+	}
+	for _, condition := range pod.Status.Conditions {
+		fmt.Printf("  %s: %s\n", condition.Type, condition.Status)
+	}
+}
+
+// rawRequest GETs an absolute path, ignoring the client's group version, and pretty-prints
+// the JSON response. JSON is requested even with --protobuf, since raw bodies aren't decoded.
+func rawRequest(ctx context.Context, restClient *rest.RESTClient, path string) {
+	data, err := restClient.Get().
+		AbsPath(path).
+		SetHeader("Accept", runtime.ContentTypeJSON).
+		DoRaw(ctx)
+	if err != nil {
+		log.Fatalf("Error requesting %s: %v", path, err)
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, data, "", "  "); err != nil {
+		// Not JSON, e.g. /healthz or /metrics
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(pretty.String())
+}
@@ -0,0 +1,306 @@
+// Package checkpoint persists the last resourceVersion observed per resource, so that after
+// a restart the informer examples can tell objects the initial list merely replays from
+// objects that changed while they were down, instead of reporting everything as added.
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"kgent-api/informer/handlers"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WatchFunc starts a watch, e.g. the Watch method of a dynamic resource client
+type WatchFunc func(options metav1.ListOptions) (watch.Interface, error)
+
+// Store holds the checkpoints of one file
+type Store struct {
+	path string
+
+	mu sync.Mutex
+	// versions are the latest resourceVersions observed by group/version/resource, saved to the file
+	versions map[string]string
+	// resumed are the checkpoints loaded at startup, dropped once found to be too old
+	resumed map[string]string
+	// replayed are the kind/namespace/name keys of objects replayed by an initial list
+	replayed map[string]bool
+	dirty    bool
+}
+
+// Open loads the checkpoints saved at path. A missing file starts without checkpoints.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		versions: map[string]string{},
+		resumed:  map[string]string{},
+		replayed: map[string]bool{},
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.versions); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints in %s: %w", path, err)
+	}
+	for resource, rv := range s.versions {
+		s.resumed[resource] = rv
+	}
+	return s, nil
+}
+
+// Resume returns the checkpoint of gvr, or "" when there is none. A checkpoint the API
+// server can no longer watch from is dropped with a warning, so the initial list is
+// treated as a full resync.
+func (s *Store) Resume(gvr schema.GroupVersionResource, watchFn WatchFunc) string {
+	resource := Key(gvr)
+	s.mu.Lock()
+	rv := s.resumed[resource]
+	s.mu.Unlock()
+	if rv == "" {
+		return ""
+	}
+
+	if err := verify(rv, watchFn); err != nil {
+		log.Printf("Warning: checkpoint %s of %s is unusable, falling back to a full resync: %v", rv, resource, err)
+		s.mu.Lock()
+		delete(s.resumed, resource)
+		s.mu.Unlock()
+		return ""
+	}
+	return rv
+}
+
+// verify starts a short watch from rv, failing when the API server reports it as too old
+func verify(rv string, watchFn WatchFunc) error {
+	timeout := int64(1)
+	w, err := watchFn(metav1.ListOptions{ResourceVersion: rv, TimeoutSeconds: &timeout})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	select {
+	case event, ok := <-w.ResultChan():
+		if ok && event.Type == watch.Error {
+			return apierrors.FromObject(event.Object)
+		}
+	case <-time.After(2 * time.Second):
+	}
+	return nil
+}
+
+// observe records rv as the latest version of resource, ignoring older versions
+func (s *Store) observe(resource, rv string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.versions[resource]; ok && !newer(rv, current) {
+		return
+	}
+	s.versions[resource] = rv
+	s.dirty = true
+}
+
+func (s *Store) markReplayed(key string) {
+	s.mu.Lock()
+	s.replayed[key] = true
+	s.mu.Unlock()
+}
+
+func (s *Store) isReplayed(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replayed[key]
+}
+
+// Save writes the checkpoints if they changed since the last save. The file is replaced
+// atomically so a crash never leaves a truncated file behind.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(s.versions, "", "  ")
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = writeFile(s.path, data)
+	}
+	if err != nil {
+		// Retry on the next save
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	return nil
+}
+
+// writeFile writes data to a temporary file next to path and renames it over path
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Run saves the checkpoints every interval until stopCh is closed. Callers save once more
+// after the informers have stopped.
+func (s *Store) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// Tag returns a sink marking the initial list events of replayed objects as replays
+func (s *Store) Tag(sink handlers.Sink) handlers.Sink {
+	return &tagSink{store: s, sink: sink}
+}
+
+type tagSink struct {
+	store *Store
+	sink  handlers.Sink
+}
+
+func (t *tagSink) Write(event handlers.Event) error {
+	if event.InitialList && t.store.isReplayed(replayKey(event.GVK.Kind, event.Namespace, event.Name)) {
+		event.Replay = true
+	}
+	return t.sink.Write(event)
+}
+
+// Handler wraps an informer handler for one resource. It records the resourceVersion of
+// every object it sees and, when Checkpoint is set, splits the initial list into objects
+// unchanged since the checkpoint, passed on as replayed initial list adds or dropped with
+// Suppress, and objects changed since, passed on as regular adds. Objects deleted while
+// the informer was down are not reported.
+type Handler struct {
+	Store      *Store
+	Resource   schema.GroupVersionResource
+	Kind       string
+	Checkpoint string
+	Suppress   bool
+	Handler    cache.ResourceEventHandler
+}
+
+func (h *Handler) OnAdd(obj interface{}, isInInitialList bool) {
+	rv := h.observe(obj)
+	if !isInInitialList || h.Checkpoint == "" {
+		h.Handler.OnAdd(obj, isInInitialList)
+		return
+	}
+
+	// Changed while the informer was down
+	if newer(rv, h.Checkpoint) {
+		h.Handler.OnAdd(obj, false)
+		return
+	}
+
+	if h.Suppress {
+		return
+	}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		h.Store.markReplayed(replayKey(h.kind(obj), accessor.GetNamespace(), accessor.GetName()))
+	}
+	h.Handler.OnAdd(obj, true)
+}
+
+func (h *Handler) OnUpdate(oldObj, newObj interface{}) {
+	h.observe(newObj)
+	h.Handler.OnUpdate(oldObj, newObj)
+}
+
+func (h *Handler) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		h.observe(tombstone.Obj)
+	} else {
+		h.observe(obj)
+	}
+	h.Handler.OnDelete(obj)
+}
+
+// observe records the object's resourceVersion and returns it
+func (h *Handler) observe(obj interface{}) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	rv := accessor.GetResourceVersion()
+	if rv != "" {
+		h.Store.observe(Key(h.Resource), rv)
+	}
+	return rv
+}
+
+// kind prefers the object's own kind, set on unstructured objects, over the configured one
+func (h *Handler) kind(obj interface{}) string {
+	if o, ok := obj.(runtime.Object); ok {
+		if kind := o.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			return kind
+		}
+	}
+	return h.Kind
+}
+
+// Key is the group/version/resource a checkpoint is saved under, e.g. apps/v1/deployments
+func Key(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Version + "/" + gvr.Resource
+	}
+	return gvr.Group + "/" + gvr.Version + "/" + gvr.Resource
+}
+
+func replayKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// newer reports whether resourceVersion a is newer than b. Resource versions are opaque,
+// but the API server uses etcd revisions; unparsable versions count as newer, so objects
+// are never wrongly reported as replays.
+func newer(a, b string) bool {
+	av, errA := strconv.ParseUint(a, 10, 64)
+	bv, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return a != b
+	}
+	return av > bv
+}
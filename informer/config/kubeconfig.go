@@ -1,10 +1,13 @@
 package config
 
 import (
-	"flag"
+	"os"
 	"path/filepath"
 
+	"kgent-api/pkg/version"
+
 	"github.com/pkg/errors"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -19,29 +22,28 @@ type K8sConfig struct {
 	err            error
 }
 
-func NewK8sConfig() *K8sConfig {
-	var kubeconfig *string
-	var namespace *string
-
-	// Set up default kubeconfig path
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+// NewK8sConfig creates a config for the given kubeconfig file and namespace without touching
+// the global flag set. An empty kubeconfigPath falls back to the first file in $KUBECONFIG,
+// then ~/.kube/config; an empty namespace falls back to $WATCH_NAMESPACE, then "default".
+func NewK8sConfig(kubeconfigPath, namespace string) *K8sConfig {
+	if kubeconfigPath == "" {
+		if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 {
+			kubeconfigPath = paths[0]
+		} else if home := homedir.HomeDir(); home != "" {
+			kubeconfigPath = filepath.Join(home, ".kube", "config")
+		}
 	}
 
-	// Set up default namespace
-	namespace = flag.String("namespace", "default", "namespace to watch resources from")
-
-	// Parse flags if they haven't been parsed yet
-	if !flag.Parsed() {
-		flag.Parse()
+	if namespace == "" {
+		namespace = os.Getenv("WATCH_NAMESPACE")
+	}
+	if namespace == "" {
+		namespace = "default"
 	}
 
 	return &K8sConfig{
-		KubeConfigPath: *kubeconfig,
-		Namespace:      *namespace,
+		KubeConfigPath: kubeconfigPath,
+		Namespace:      namespace,
 	}
 }
 
@@ -61,6 +63,7 @@ func (k *K8sConfig) InitRestConfig() *K8sConfig {
 		}
 	}
 
+	config.UserAgent = version.UserAgent("informer-example")
 	k.Config = config
 	return k
 }
@@ -86,3 +89,18 @@ func (k *K8sConfig) InitClientSet() *kubernetes.Clientset {
 	k.Clientset = clientset
 	return clientset
 }
+
+// Initialize dynamic client for resources without typed clients, such as custom resources
+func (k *K8sConfig) InitDynamicClient() dynamic.Interface {
+	if k.Config == nil {
+		k.err = errors.New("kubernetes config is nil, call InitRestConfig first")
+		return nil
+	}
+
+	client, err := dynamic.NewForConfig(k.Config)
+	if err != nil {
+		k.err = errors.Wrap(err, "failed to create dynamic client")
+		return nil
+	}
+	return client
+}
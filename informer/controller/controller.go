@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"kgent-api/pkg/recovery"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// ReconcileFunc processes the current state of a Pod. pod is nil when the Pod was deleted.
+// Returning an error requeues the key with backoff.
+type ReconcileFunc func(key string, pod *v1.Pod) error
+
+// Controller queues the keys of changed Pods and reconciles them from the lister on worker
+// goroutines, instead of doing the work inside event handlers
+type Controller struct {
+	queue      workqueue.TypedRateLimitingInterface[string]
+	lister     corelisters.PodLister
+	synced     cache.InformerSynced
+	reconcile  ReconcileFunc
+	maxRetries int
+}
+
+// New wires the Pod informer to a rate limited work queue. A key failing maxRetries times
+// in a row is dropped.
+func New(informer coreinformers.PodInformer, reconcile ReconcileFunc, maxRetries int) *Controller {
+	c := &Controller{
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "pods"},
+		),
+		lister:     informer.Lister(),
+		synced:     informer.Informer().HasSynced,
+		reconcile:  reconcile,
+		maxRetries: maxRetries,
+	}
+
+	// Handlers only enqueue keys; the object is read back from the lister when processed,
+	// so several events for the same Pod collapse into one reconcile
+	informer.Informer().AddEventHandler(recovery.Handler("controller", cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	}, nil))
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run waits for the cache to sync and starts workers. When stopCh is closed it stops
+// accepting new keys, lets the workers drain the queue and returns once they are done.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+
+	if !cache.WaitForCacheSync(stopCh, c.synced) {
+		c.queue.ShutDown()
+		return fmt.Errorf("timed out waiting for caches to sync")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.processNextItem() {
+			}
+		}()
+	}
+	log.Printf("Controller started %d workers", workers)
+
+	<-stopCh
+	log.Printf("Controller draining %d queued keys", c.queue.Len())
+	c.queue.ShutDownWithDrain()
+	wg.Wait()
+	return nil
+}
+
+// processNextItem reconciles one key, returning false once the queue is shut down and empty
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	c.handleErr(c.sync(key), key)
+	return true
+}
+
+func (c *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	pod, err := c.lister.Pods(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return c.reconcile(key, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return c.reconcile(key, pod)
+}
+
+// handleErr forgets the key's failure history on success, requeues it with backoff on
+// failure, and drops it once it has been retried maxRetries times
+func (c *Controller) handleErr(err error, key string) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+
+	if c.queue.NumRequeues(key) < c.maxRetries {
+		log.Printf("Error reconciling %s, requeuing: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	c.queue.Forget(key)
+	log.Printf("Dropping %s after %d retries: %v", key, c.maxRetries, err)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// failingReconciler fails the first failures reconciles and records every call
+type failingReconciler struct {
+	mu       sync.Mutex
+	failures int
+	calls    []*v1.Pod
+}
+
+func (r *failingReconciler) reconcile(key string, pod *v1.Pod) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, pod)
+	if len(r.calls) <= r.failures {
+		return errors.New("reconcile failed")
+	}
+	return nil
+}
+
+func (r *failingReconciler) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+func TestControllerRequeue(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		failures   int
+		maxRetries int
+		wantCalls  int
+		// wantDeleted is set when the reconciler gets a nil pod for a deleted key
+		wantDeleted bool
+	}{
+		{name: "success", key: "default/web-0", maxRetries: 3, wantCalls: 1},
+		{name: "requeued until success", key: "default/web-0", failures: 2, maxRetries: 3, wantCalls: 3},
+		{name: "dropped after max retries", key: "default/web-0", failures: 100, maxRetries: 2, wantCalls: 3},
+		{name: "no retries", key: "default/web-0", failures: 100, wantCalls: 1},
+		{name: "deleted pod", key: "default/gone", maxRetries: 3, wantCalls: 1, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fact := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			reconciler := &failingReconciler{failures: tt.failures}
+			c := New(fact.Core().V1().Pods(), reconciler.reconcile, tt.maxRetries)
+
+			// The informer isn't started so only the key under test is queued, its cache is
+			// filled by hand
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"}}
+			if err := fact.Core().V1().Pods().Informer().GetIndexer().Add(pod); err != nil {
+				t.Fatal(err)
+			}
+			c.synced = func() bool { return true }
+
+			stopCh := make(chan struct{})
+			done := make(chan error)
+			go func() { done <- c.Run(1, stopCh) }()
+			c.queue.Add(tt.key)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for reconciler.callCount() < tt.wantCalls && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			// Leave time for a requeue the controller should not do
+			time.Sleep(100 * time.Millisecond)
+			close(stopCh)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if got := reconciler.callCount(); got != tt.wantCalls {
+				t.Errorf("reconciled %d times, want %d", got, tt.wantCalls)
+			}
+			if got := c.queue.NumRequeues(tt.key); got != 0 {
+				t.Errorf("key has %d requeues after the controller finished with it, want it forgotten", got)
+			}
+			for _, pod := range reconciler.calls {
+				if (pod == nil) != tt.wantDeleted {
+					t.Errorf("reconciled pod %v, want deleted = %v", pod, tt.wantDeleted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"kgent-api/pkg/metrics"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	queueDepth = metrics.NewGauge("workqueue_depth",
+		"Current depth of the workqueue.", "name")
+	queueAdds = metrics.NewCounter("workqueue_adds_total",
+		"Total number of adds handled by the workqueue.", "name")
+	queueLatency = metrics.NewHistogram("workqueue_queue_duration_seconds",
+		"How long an item stays in the workqueue before being requested.", nil, "name")
+	workDuration = metrics.NewHistogram("workqueue_work_duration_seconds",
+		"How long processing an item from the workqueue takes.", nil, "name")
+	unfinishedWork = metrics.NewGauge("workqueue_unfinished_work_seconds",
+		"Seconds of work in progress that has not been observed by work_duration.", "name")
+	longestRunning = metrics.NewGauge("workqueue_longest_running_processor_seconds",
+		"Seconds the longest running processor has been running.", "name")
+	queueRetries = metrics.NewCounter("workqueue_retries_total",
+		"Total number of retries handled by the workqueue.", "name")
+)
+
+// RegisterMetrics exposes the depth, latency and retries of named work queues through
+// pkg/metrics. It must be called before the queues are created; later calls are no-ops.
+func RegisterMetrics() {
+	workqueue.SetProvider(metricsProvider{})
+}
+
+type metricsProvider struct{}
+
+func (metricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return gauge{g: queueDepth, name: name}
+}
+
+func (metricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return counter{c: queueAdds, name: name}
+}
+
+func (metricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return histogram{h: queueLatency, name: name}
+}
+
+func (metricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return histogram{h: workDuration, name: name}
+}
+
+func (metricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return gauge{g: unfinishedWork, name: name}
+}
+
+func (metricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return gauge{g: longestRunning, name: name}
+}
+
+func (metricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return counter{c: queueRetries, name: name}
+}
+
+// gauge, counter and histogram bind a pkg/metrics metric to a queue name
+
+type gauge struct {
+	g    *metrics.Gauge
+	name string
+}
+
+func (m gauge) Inc()              { m.g.Inc(m.name) }
+func (m gauge) Dec()              { m.g.Dec(m.name) }
+func (m gauge) Set(value float64) { m.g.Set(value, m.name) }
+
+type counter struct {
+	c    *metrics.Counter
+	name string
+}
+
+func (m counter) Inc() { m.c.Inc(m.name) }
+
+type histogram struct {
+	h    *metrics.Histogram
+	name string
+}
+
+func (m histogram) Observe(value float64) { m.h.Observe(value, m.name) }
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	configMapGVK = v1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK    = v1.SchemeGroupVersion.WithKind("Secret")
+)
+
+// ConfigHandler implements ResourceEventHandler for ConfigMap resources, reporting which
+// keys of data and binaryData were added, removed or changed. Values are never printed,
+// changed keys show a short hash of the old and new value instead.
+type ConfigHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+}
+
+// OnAdd is called when a ConfigMap is added
+func (h *ConfigHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		log.Println("Error: OnAdd received non-ConfigMap object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "ConfigHandler", Event{
+		Action:      "Added",
+		GVK:         configMapGVK,
+		Namespace:   cm.Namespace,
+		Name:        cm.Name,
+		InitialList: isInInitialList,
+		Summary:     map[string]string{"keys": fmt.Sprint(len(cm.Data) + len(cm.BinaryData))},
+	})
+}
+
+// OnUpdate is called when a ConfigMap is modified
+func (h *ConfigHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldCM, ok := oldObj.(*v1.ConfigMap)
+	if !ok {
+		log.Println("Error: OnUpdate received non-ConfigMap object for old object")
+		return
+	}
+
+	newCM, ok := newObj.(*v1.ConfigMap)
+	if !ok {
+		log.Println("Error: OnUpdate received non-ConfigMap object for new object")
+		return
+	}
+
+	if newCM.ResourceVersion == oldCM.ResourceVersion {
+		// No actual change, skip
+		return
+	}
+
+	changes := dataChanges("data", stringValues(oldCM.Data), stringValues(newCM.Data))
+	changes = append(changes, dataChanges("binaryData", oldCM.BinaryData, newCM.BinaryData)...)
+	if len(changes) == 0 {
+		// Only metadata changed
+		return
+	}
+
+	emitDataChanges(h.Sink, h.Caller, "ConfigHandler", configMapGVK, newCM.Namespace, newCM.Name, changes)
+}
+
+// OnDelete is called when a ConfigMap is deleted
+func (h *ConfigHandler) OnDelete(obj interface{}) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-ConfigMap and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		cm, ok = tombstone.Obj.(*v1.ConfigMap)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-ConfigMap object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "ConfigHandler", Event{
+		Action:    "Deleted",
+		GVK:       configMapGVK,
+		Namespace: cm.Namespace,
+		Name:      cm.Name,
+	})
+}
+
+// SecretHandler implements ResourceEventHandler for Secret resources. Like ConfigHandler it
+// reports key changes, but only ever key names and value hashes.
+type SecretHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+}
+
+// OnAdd is called when a Secret is added
+func (h *SecretHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		log.Println("Error: OnAdd received non-Secret object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "SecretHandler", Event{
+		Action:      "Added",
+		GVK:         secretGVK,
+		Namespace:   secret.Namespace,
+		Name:        secret.Name,
+		InitialList: isInInitialList,
+		Summary: map[string]string{
+			"type": string(secret.Type),
+			"keys": fmt.Sprint(len(secret.Data)),
+		},
+	})
+}
+
+// OnUpdate is called when a Secret is modified
+func (h *SecretHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldSecret, ok := oldObj.(*v1.Secret)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Secret object for old object")
+		return
+	}
+
+	newSecret, ok := newObj.(*v1.Secret)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Secret object for new object")
+		return
+	}
+
+	if newSecret.ResourceVersion == oldSecret.ResourceVersion {
+		// No actual change, skip
+		return
+	}
+
+	// stringData is write-only and folded into data by the API server, so data is all there is
+	changes := dataChanges("data", oldSecret.Data, newSecret.Data)
+	if len(changes) == 0 {
+		return
+	}
+
+	emitDataChanges(h.Sink, h.Caller, "SecretHandler", secretGVK, newSecret.Namespace, newSecret.Name, changes)
+}
+
+// OnDelete is called when a Secret is deleted
+func (h *SecretHandler) OnDelete(obj interface{}) {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Secret and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		secret, ok = tombstone.Obj.(*v1.Secret)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Secret object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "SecretHandler", Event{
+		Action:    "Deleted",
+		GVK:       secretGVK,
+		Namespace: secret.Namespace,
+		Name:      secret.Name,
+	})
+}
+
+func emitDataChanges(sink Sink, caller, handler string, gvk schema.GroupVersionKind, namespace, name string, changes []string) {
+	emit(sink, caller, handler, Event{
+		Action:    "Data Changed",
+		GVK:       gvk,
+		Namespace: namespace,
+		Name:      name,
+		Summary:   map[string]string{"changes": fmt.Sprint(len(changes))},
+		Details:   changes,
+	})
+}
+
+func stringValues(data map[string]string) map[string][]byte {
+	values := make(map[string][]byte, len(data))
+	for key, value := range data {
+		values[key] = []byte(value)
+	}
+	return values
+}
+
+// dataChanges lists added, removed and changed keys in sorted order. Values are only
+// ever shown as hashes.
+func dataChanges(field string, oldData, newData map[string][]byte) []string {
+	keys := map[string]struct{}{}
+	for key := range oldData {
+		keys[key] = struct{}{}
+	}
+	for key := range newData {
+		keys[key] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		oldValue, inOld := oldData[key]
+		newValue, inNew := newData[key]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("%s.%s added (%s)", field, key, valueHash(newValue)))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("%s.%s removed", field, key))
+		case valueHash(oldValue) != valueHash(newValue):
+			changes = append(changes, fmt.Sprintf("%s.%s changed (%s -> %s)", field, key, valueHash(oldValue), valueHash(newValue)))
+		}
+	}
+	return changes
+}
+
+// valueHash identifies a value without revealing it
+func valueHash(value []byte) string {
+	sum := sha256.Sum256(value)
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
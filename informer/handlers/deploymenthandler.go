@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var deploymentGVK = appsv1.SchemeGroupVersion.WithKind("Deployment")
+
+// DeploymentHandler implements ResourceEventHandler for Deployment resources, reporting
+// replica and readiness transitions and container image changes
+type DeploymentHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+}
+
+// OnAdd is called when a Deployment is added
+func (h *DeploymentHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	deploy, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		log.Println("Error: OnAdd received non-Deployment object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "DeploymentHandler", Event{
+		Action:      "Added",
+		GVK:         deploymentGVK,
+		Namespace:   deploy.Namespace,
+		Name:        deploy.Name,
+		InitialList: isInInitialList,
+		Summary:     map[string]string{"ready": readyReplicas(deploy)},
+	})
+}
+
+// OnUpdate is called when a Deployment is modified
+func (h *DeploymentHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldDeploy, ok := oldObj.(*appsv1.Deployment)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Deployment object for old object")
+		return
+	}
+
+	newDeploy, ok := newObj.(*appsv1.Deployment)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Deployment object for new object")
+		return
+	}
+
+	if newDeploy.ResourceVersion == oldDeploy.ResourceVersion {
+		// No actual change, skip
+		return
+	}
+
+	changes := deploymentChanges(oldDeploy, newDeploy)
+	if len(changes) == 0 {
+		// Only status bookkeeping changed, nothing worth reporting
+		return
+	}
+
+	emit(h.Sink, h.Caller, "DeploymentHandler", Event{
+		Action:    "Updated",
+		GVK:       deploymentGVK,
+		Namespace: newDeploy.Namespace,
+		Name:      newDeploy.Name,
+		Summary:   map[string]string{"ready": readyReplicas(newDeploy)},
+		Details:   changes,
+	})
+}
+
+// OnDelete is called when a Deployment is deleted
+func (h *DeploymentHandler) OnDelete(obj interface{}) {
+	deploy, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Deployment and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		deploy, ok = tombstone.Obj.(*appsv1.Deployment)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Deployment object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "DeploymentHandler", Event{
+		Action:    "Deleted",
+		GVK:       deploymentGVK,
+		Namespace: deploy.Namespace,
+		Name:      deploy.Name,
+	})
+}
+
+func desiredReplicas(deploy *appsv1.Deployment) int32 {
+	if deploy.Spec.Replicas != nil {
+		return *deploy.Spec.Replicas
+	}
+	return 1
+}
+
+func readyReplicas(deploy *appsv1.Deployment) string {
+	return strconv.Itoa(int(deploy.Status.ReadyReplicas)) + "/" + strconv.Itoa(int(desiredReplicas(deploy)))
+}
+
+// deploymentChanges describes the scaling, readiness and image differences between two versions
+func deploymentChanges(oldDeploy, newDeploy *appsv1.Deployment) []string {
+	var changes []string
+
+	if oldReplicas, newReplicas := desiredReplicas(oldDeploy), desiredReplicas(newDeploy); oldReplicas != newReplicas {
+		changes = append(changes, fmt.Sprintf("Replicas changed: %d -> %d", oldReplicas, newReplicas))
+	}
+	if oldDeploy.Status.ReadyReplicas != newDeploy.Status.ReadyReplicas {
+		changes = append(changes, fmt.Sprintf("Ready replicas changed: %d -> %d", oldDeploy.Status.ReadyReplicas, newDeploy.Status.ReadyReplicas))
+	}
+
+	oldFullyReady := oldDeploy.Status.ReadyReplicas >= desiredReplicas(oldDeploy)
+	newFullyReady := newDeploy.Status.ReadyReplicas >= desiredReplicas(newDeploy)
+	if oldFullyReady && !newFullyReady {
+		changes = append(changes, "Deployment became not ready")
+	} else if !oldFullyReady && newFullyReady {
+		changes = append(changes, "Deployment became ready")
+	}
+
+	return append(changes, imageChanges(oldDeploy, newDeploy)...)
+}
+
+// imageChanges diffs the container images of the pod templates by container name
+func imageChanges(oldDeploy, newDeploy *appsv1.Deployment) []string {
+	oldImages := map[string]string{}
+	for _, container := range oldDeploy.Spec.Template.Spec.Containers {
+		oldImages[container.Name] = container.Image
+	}
+
+	var changes []string
+	for _, container := range newDeploy.Spec.Template.Spec.Containers {
+		oldImage, ok := oldImages[container.Name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("Container %s added with image %s", container.Name, container.Image))
+		case oldImage != container.Image:
+			changes = append(changes, fmt.Sprintf("Container %s image changed: %s -> %s", container.Name, oldImage, container.Image))
+		}
+		delete(oldImages, container.Name)
+	}
+	for _, container := range oldDeploy.Spec.Template.Spec.Containers {
+		if _, removed := oldImages[container.Name]; removed {
+			changes = append(changes, fmt.Sprintf("Container %s removed", container.Name))
+		}
+	}
+	return changes
+}
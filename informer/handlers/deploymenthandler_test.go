@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// recordingSink keeps the events written to it
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// fixtureDeployment is the web Deployment at resourceVersion with replicas desired, ready of
+// them ready, running images by container name
+func fixtureDeployment(resourceVersion string, replicas, ready int32, images ...string) *appsv1.Deployment {
+	var containers []v1.Container
+	for i := 0; i+1 < len(images); i += 2 {
+		containers = append(containers, v1.Container{Name: images[i], Image: images[i+1]})
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: resourceVersion},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: containers}},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func TestDeploymentHandlerOnUpdate(t *testing.T) {
+	old := fixtureDeployment("1", 3, 3, "web", "web:1.0", "proxy", "envoy:1.29")
+
+	tests := []struct {
+		name        string
+		new         *appsv1.Deployment
+		wantDetails []string
+		wantReady   string
+	}{
+		{
+			name: "same resource version",
+			new:  fixtureDeployment("1", 5, 0, "web", "web:2.0"),
+		},
+		{
+			name: "status bookkeeping only",
+			new:  fixtureDeployment("2", 3, 3, "web", "web:1.0", "proxy", "envoy:1.29"),
+		},
+		{
+			name:        "scaled up",
+			new:         fixtureDeployment("2", 5, 3, "web", "web:1.0", "proxy", "envoy:1.29"),
+			wantDetails: []string{"Replicas changed: 3 -> 5", "Deployment became not ready"},
+			wantReady:   "3/5",
+		},
+		{
+			name:        "pod lost",
+			new:         fixtureDeployment("2", 3, 2, "web", "web:1.0", "proxy", "envoy:1.29"),
+			wantDetails: []string{"Ready replicas changed: 3 -> 2", "Deployment became not ready"},
+			wantReady:   "2/3",
+		},
+		{
+			name: "image rollout",
+			new:  fixtureDeployment("2", 3, 3, "web", "web:1.1", "metrics", "exporter:0.9"),
+			wantDetails: []string{
+				"Container web image changed: web:1.0 -> web:1.1",
+				"Container metrics added with image exporter:0.9",
+				"Container proxy removed",
+			},
+			wantReady: "3/3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			handler := &DeploymentHandler{Caller: "test", Sink: sink}
+			handler.OnUpdate(old, tt.new)
+
+			events := sink.Events()
+			if tt.wantDetails == nil {
+				if len(events) != 0 {
+					t.Fatalf("emitted %+v, want no event", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("emitted %d events, want 1", len(events))
+			}
+			event := events[0]
+			if event.Action != "Updated" || event.GVK != deploymentGVK || event.Namespace != "default" || event.Name != "web" {
+				t.Errorf("event = %+v, want an update of default/web", event)
+			}
+			if !reflect.DeepEqual(event.Details, tt.wantDetails) {
+				t.Errorf("details = %q, want %q", event.Details, tt.wantDetails)
+			}
+			if got := event.Summary["ready"]; got != tt.wantReady {
+				t.Errorf("ready = %q, want %q", got, tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestDeploymentChangesBecameReady(t *testing.T) {
+	old := fixtureDeployment("1", 3, 2, "web", "web:1.0")
+	want := []string{"Ready replicas changed: 2 -> 3", "Deployment became ready"}
+	if got := deploymentChanges(old, fixtureDeployment("2", 3, 3, "web", "web:1.0")); !reflect.DeepEqual(got, want) {
+		t.Errorf("deploymentChanges() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/tools/cache"
+)
+
+var jobGVK = batchv1.SchemeGroupVersion.WithKind("Job")
+
+// JobHandler implements ResourceEventHandler for Job resources, reporting when a job
+// completes or fails, with how long it ran and the failure condition's message
+type JobHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+}
+
+// OnAdd is called when a Job is added
+func (h *JobHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		log.Println("Error: OnAdd received non-Job object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "JobHandler", Event{
+		Action:      "Added",
+		GVK:         jobGVK,
+		Namespace:   job.Namespace,
+		Name:        job.Name,
+		InitialList: isInInitialList,
+		Summary:     map[string]string{"status": jobStatus(job)},
+	})
+}
+
+// OnUpdate is called when a Job is modified
+func (h *JobHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldJob, ok := oldObj.(*batchv1.Job)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Job object for old object")
+		return
+	}
+
+	newJob, ok := newObj.(*batchv1.Job)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Job object for new object")
+		return
+	}
+
+	if newJob.ResourceVersion == oldJob.ResourceVersion {
+		// No actual change, skip
+		return
+	}
+
+	// Only the transition into a finished state is reported, not every pod count change
+	oldStatus, newStatus := jobStatus(oldJob), jobStatus(newJob)
+	if oldStatus == newStatus || (newStatus != "Complete" && newStatus != "Failed") {
+		return
+	}
+
+	summary := map[string]string{
+		"succeeded": fmt.Sprint(newJob.Status.Succeeded),
+		"failed":    fmt.Sprint(newJob.Status.Failed),
+	}
+	if ran, ok := jobDuration(newJob); ok {
+		summary["duration"] = duration.HumanDuration(ran)
+	}
+
+	var details []string
+	if condition := jobCondition(newJob, batchv1.JobFailed); newStatus == "Failed" && condition != nil {
+		details = append(details, fmt.Sprintf("%s: %s", condition.Reason, condition.Message))
+	}
+
+	emit(h.Sink, h.Caller, "JobHandler", Event{
+		Action:    newStatus,
+		GVK:       jobGVK,
+		Namespace: newJob.Namespace,
+		Name:      newJob.Name,
+		Summary:   summary,
+		Details:   details,
+	})
+}
+
+// OnDelete is called when a Job is deleted
+func (h *JobHandler) OnDelete(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Job and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		job, ok = tombstone.Obj.(*batchv1.Job)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Job object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "JobHandler", Event{
+		Action:    "Deleted",
+		GVK:       jobGVK,
+		Namespace: job.Namespace,
+		Name:      job.Name,
+		Summary:   map[string]string{"status": jobStatus(job)},
+	})
+}
+
+// jobStatus is Complete or Failed once the job has finished, Running otherwise
+func jobStatus(job *batchv1.Job) string {
+	if jobCondition(job, batchv1.JobComplete) != nil {
+		return "Complete"
+	}
+	if jobCondition(job, batchv1.JobFailed) != nil {
+		return "Failed"
+	}
+	return "Running"
+}
+
+// jobCondition returns the condition of the given type when it is true
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == v1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// jobDuration is the time from start to completion, or to the failure for failed jobs
+func jobDuration(job *batchv1.Job) (time.Duration, bool) {
+	if job.Status.StartTime == nil {
+		return 0, false
+	}
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime.Sub(job.Status.StartTime.Time), true
+	}
+	if c := jobCondition(job, batchv1.JobFailed); c != nil {
+		return c.LastTransitionTime.Sub(job.Status.StartTime.Time), true
+	}
+	return 0, false
+}
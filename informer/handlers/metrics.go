@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	eventsTotal = metrics.NewCounter("kgent_informer_events_total",
+		"Informer events by resource kind and type.", "kind", "type")
+	handlerDuration = metrics.NewHistogram("kgent_informer_handler_duration_seconds",
+		"Time event handlers take to process an event.", nil, "kind", "handler")
+)
+
+// Process wide totals behind the periodic summary line
+var addedTotal, updatedTotal, deletedTotal atomic.Int64
+
+// EventTotals returns the number of add, update and delete events counted so far
+func EventTotals() (adds, updates, deletes int64) {
+	return addedTotal.Load(), updatedTotal.Load(), deletedTotal.Load()
+}
+
+// Instrumented wraps a handler, recording how long it takes per event. With CountEvents
+// set it also counts the events by type; set it on one handler per informer so that
+// each event is counted once.
+type Instrumented struct {
+	Kind        string
+	Handler     cache.ResourceEventHandler
+	CountEvents bool
+}
+
+func (h *Instrumented) OnAdd(obj interface{}, isInInitialList bool) {
+	h.count("added", &addedTotal)
+	defer h.observe(time.Now())
+	h.Handler.OnAdd(obj, isInInitialList)
+}
+
+func (h *Instrumented) OnUpdate(oldObj, newObj interface{}) {
+	h.count("updated", &updatedTotal)
+	defer h.observe(time.Now())
+	h.Handler.OnUpdate(oldObj, newObj)
+}
+
+func (h *Instrumented) OnDelete(obj interface{}) {
+	h.count("deleted", &deletedTotal)
+	defer h.observe(time.Now())
+	h.Handler.OnDelete(obj)
+}
+
+func (h *Instrumented) count(eventType string, total *atomic.Int64) {
+	if !h.CountEvents {
+		return
+	}
+	eventsTotal.Inc(h.Kind, eventType)
+	total.Add(1)
+}
+
+func (h *Instrumented) observe(start time.Time) {
+	handlerDuration.Observe(time.Since(start).Seconds(), h.Kind, handlerName(h.Handler))
+}
+
+// handlerName is the type name of a handler without package or pointer, e.g. PodHandler
+func handlerName(handler cache.ResourceEventHandler) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", handler), "*")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
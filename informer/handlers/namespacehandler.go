@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/tools/cache"
+)
+
+var namespaceGVK = v1.SchemeGroupVersion.WithKind("Namespace")
+
+// NamespaceHandler implements ResourceEventHandler for Namespace resources, reporting
+// creation, deletion and namespaces stuck in Terminating. Stuck namespaces are found on
+// updates and resyncs, so they are reported at the latest one resync period after
+// StuckAfter has passed, and once per namespace.
+type NamespaceHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+	// StuckAfter is how long a namespace may be Terminating before it is reported as stuck
+	StuckAfter time.Duration
+	// Now returns the current time; time.Now when nil
+	Now func() time.Time
+
+	mu    sync.Mutex
+	stuck map[types.UID]bool
+}
+
+func (h *NamespaceHandler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// OnAdd is called when a Namespace is added
+func (h *NamespaceHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		log.Println("Error: OnAdd received non-Namespace object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "NamespaceHandler", Event{
+		Action:      "Added",
+		GVK:         namespaceGVK,
+		Name:        ns.Name,
+		InitialList: isInInitialList,
+		Summary:     map[string]string{"phase": string(ns.Status.Phase)},
+	})
+	h.checkStuck(ns)
+}
+
+// OnUpdate is called when a Namespace is modified, and on every resync
+func (h *NamespaceHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldNs, ok := oldObj.(*v1.Namespace)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Namespace object for old object")
+		return
+	}
+
+	newNs, ok := newObj.(*v1.Namespace)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Namespace object for new object")
+		return
+	}
+
+	// Resyncs carry no change but are when the deletion timestamp gets old enough
+	if newNs.ResourceVersion == oldNs.ResourceVersion {
+		h.checkStuck(newNs)
+		return
+	}
+
+	if changes := namespaceChanges(oldNs, newNs); len(changes) > 0 {
+		emit(h.Sink, h.Caller, "NamespaceHandler", Event{
+			Action:  "Updated",
+			GVK:     namespaceGVK,
+			Name:    newNs.Name,
+			Details: changes,
+		})
+	}
+	h.checkStuck(newNs)
+}
+
+// OnDelete is called when a Namespace is deleted
+func (h *NamespaceHandler) OnDelete(obj interface{}) {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Namespace and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		ns, ok = tombstone.Obj.(*v1.Namespace)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Namespace object")
+			return
+		}
+	}
+
+	h.mu.Lock()
+	delete(h.stuck, ns.UID)
+	h.mu.Unlock()
+
+	event := Event{
+		Action: "Deleted",
+		GVK:    namespaceGVK,
+		Name:   ns.Name,
+	}
+	if ns.DeletionTimestamp != nil {
+		event.Summary = map[string]string{
+			"terminating": duration.HumanDuration(h.now().Sub(ns.DeletionTimestamp.Time)),
+		}
+	}
+	emit(h.Sink, h.Caller, "NamespaceHandler", event)
+}
+
+// checkStuck reports the namespace once when it has been Terminating for longer than StuckAfter
+func (h *NamespaceHandler) checkStuck(ns *v1.Namespace) {
+	if ns.DeletionTimestamp == nil || h.StuckAfter <= 0 {
+		return
+	}
+	terminating := h.now().Sub(ns.DeletionTimestamp.Time)
+	if terminating < h.StuckAfter {
+		return
+	}
+
+	h.mu.Lock()
+	if h.stuck == nil {
+		h.stuck = map[types.UID]bool{}
+	}
+	reported := h.stuck[ns.UID]
+	h.stuck[ns.UID] = true
+	h.mu.Unlock()
+	if reported {
+		return
+	}
+
+	details := []string{fmt.Sprintf("Namespace has been Terminating for %s", duration.HumanDuration(terminating))}
+	if len(ns.Spec.Finalizers) > 0 {
+		details = append(details, fmt.Sprintf("Remaining finalizers: %v", ns.Spec.Finalizers))
+	}
+	for _, condition := range ns.Status.Conditions {
+		if condition.Status == v1.ConditionTrue && condition.Message != "" {
+			details = append(details, fmt.Sprintf("%s: %s", condition.Type, condition.Message))
+		}
+	}
+
+	emit(h.Sink, h.Caller, "NamespaceHandler", Event{
+		Action:  "Stuck",
+		GVK:     namespaceGVK,
+		Name:    ns.Name,
+		Summary: map[string]string{"phase": string(ns.Status.Phase)},
+		Details: details,
+	})
+}
+
+// namespaceChanges describes the phase and deletion differences between two versions
+func namespaceChanges(oldNs, newNs *v1.Namespace) []string {
+	var changes []string
+	if oldNs.Status.Phase != newNs.Status.Phase {
+		changes = append(changes, fmt.Sprintf("Phase changed from %s to %s", oldNs.Status.Phase, newNs.Status.Phase))
+	}
+	if oldNs.DeletionTimestamp == nil && newNs.DeletionTimestamp != nil {
+		changes = append(changes, fmt.Sprintf("Deletion requested at %s", newNs.DeletionTimestamp.Format(time.RFC3339)))
+	}
+	return changes
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var nodeGVK = v1.SchemeGroupVersion.WithKind("Node")
+
+// watchedNodeConditions are reported when their status flips
+var watchedNodeConditions = []v1.NodeConditionType{
+	v1.NodeReady,
+	v1.NodeMemoryPressure,
+	v1.NodeDiskPressure,
+	v1.NodePIDPressure,
+	v1.NodeNetworkUnavailable,
+}
+
+// NodeHandler implements ResourceEventHandler for Node resources, reporting condition
+// flips such as Ready to NotReady and schedulability changes
+type NodeHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+}
+
+// OnAdd is called when a Node is added
+func (h *NodeHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		log.Println("Error: OnAdd received non-Node object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "NodeHandler", Event{
+		Action:      "Added",
+		GVK:         nodeGVK,
+		Name:        node.Name,
+		InitialList: isInInitialList,
+		Summary: map[string]string{
+			"ready":         string(nodeCondition(node, v1.NodeReady)),
+			"unschedulable": fmt.Sprint(node.Spec.Unschedulable),
+		},
+	})
+}
+
+// OnUpdate is called when a Node is modified
+func (h *NodeHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Node object for old object")
+		return
+	}
+
+	newNode, ok := newObj.(*v1.Node)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Node object for new object")
+		return
+	}
+
+	if newNode.ResourceVersion == oldNode.ResourceVersion {
+		// No actual change, skip
+		return
+	}
+
+	// Nodes update their heartbeat constantly, so only report real transitions
+	changes := nodeChanges(oldNode, newNode)
+	if len(changes) == 0 {
+		return
+	}
+
+	emit(h.Sink, h.Caller, "NodeHandler", Event{
+		Action:  "Updated",
+		GVK:     nodeGVK,
+		Name:    newNode.Name,
+		Details: changes,
+	})
+}
+
+// OnDelete is called when a Node is deleted
+func (h *NodeHandler) OnDelete(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Node and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		node, ok = tombstone.Obj.(*v1.Node)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Node object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "NodeHandler", Event{
+		Action: "Deleted",
+		GVK:    nodeGVK,
+		Name:   node.Name,
+	})
+}
+
+// nodeCondition returns the status of a condition, Unknown when the node does not report it
+func nodeCondition(node *v1.Node, conditionType v1.NodeConditionType) v1.ConditionStatus {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return v1.ConditionUnknown
+}
+
+// nodeChanges describes the condition and schedulability differences between two versions
+func nodeChanges(oldNode, newNode *v1.Node) []string {
+	var changes []string
+
+	for _, conditionType := range watchedNodeConditions {
+		oldStatus, newStatus := nodeCondition(oldNode, conditionType), nodeCondition(newNode, conditionType)
+		if oldStatus == newStatus {
+			continue
+		}
+		if conditionType == v1.NodeReady {
+			changes = append(changes, fmt.Sprintf("Node %s -> %s", readiness(oldStatus), readiness(newStatus)))
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s changed: %s -> %s", conditionType, oldStatus, newStatus))
+	}
+
+	if oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable {
+		if newNode.Spec.Unschedulable {
+			changes = append(changes, "Node cordoned (unschedulable)")
+		} else {
+			changes = append(changes, "Node uncordoned (schedulable)")
+		}
+	}
+	return changes
+}
+
+func readiness(status v1.ConditionStatus) string {
+	switch status {
+	case v1.ConditionTrue:
+		return "Ready"
+	case v1.ConditionFalse:
+		return "NotReady"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fixtureNode is node-1 at resourceVersion, with the given condition statuses
+func fixtureNode(resourceVersion string, unschedulable bool, conditions map[v1.NodeConditionType]v1.ConditionStatus) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", ResourceVersion: resourceVersion},
+		Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+	}
+	for _, conditionType := range watchedNodeConditions {
+		if status, ok := conditions[conditionType]; ok {
+			node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: conditionType, Status: status})
+		}
+	}
+	return node
+}
+
+func TestNodeHandlerOnUpdate(t *testing.T) {
+	healthy := map[v1.NodeConditionType]v1.ConditionStatus{
+		v1.NodeReady:          v1.ConditionTrue,
+		v1.NodeMemoryPressure: v1.ConditionFalse,
+		v1.NodeDiskPressure:   v1.ConditionFalse,
+	}
+	old := fixtureNode("1", false, healthy)
+
+	tests := []struct {
+		name        string
+		new         *v1.Node
+		wantDetails []string
+	}{
+		{name: "same resource version", new: fixtureNode("1", true, nil)},
+		// Heartbeats bump the resource version without changing conditions
+		{name: "heartbeat", new: fixtureNode("2", false, healthy)},
+		{
+			name: "not ready",
+			new: fixtureNode("2", false, map[v1.NodeConditionType]v1.ConditionStatus{
+				v1.NodeReady:          v1.ConditionFalse,
+				v1.NodeMemoryPressure: v1.ConditionFalse,
+				v1.NodeDiskPressure:   v1.ConditionFalse,
+			}),
+			wantDetails: []string{"Node Ready -> NotReady"},
+		},
+		{
+			name: "kubelet stopped reporting",
+			new: fixtureNode("2", false, map[v1.NodeConditionType]v1.ConditionStatus{
+				v1.NodeReady:          v1.ConditionUnknown,
+				v1.NodeMemoryPressure: v1.ConditionUnknown,
+				v1.NodeDiskPressure:   v1.ConditionUnknown,
+			}),
+			wantDetails: []string{
+				"Node Ready -> Unknown",
+				"MemoryPressure changed: False -> Unknown",
+				"DiskPressure changed: False -> Unknown",
+			},
+		},
+		{
+			name: "disk pressure",
+			new: fixtureNode("2", false, map[v1.NodeConditionType]v1.ConditionStatus{
+				v1.NodeReady:          v1.ConditionTrue,
+				v1.NodeMemoryPressure: v1.ConditionFalse,
+				v1.NodeDiskPressure:   v1.ConditionTrue,
+			}),
+			wantDetails: []string{"DiskPressure changed: False -> True"},
+		},
+		{
+			name:        "cordoned",
+			new:         fixtureNode("2", true, healthy),
+			wantDetails: []string{"Node cordoned (unschedulable)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			handler := &NodeHandler{Caller: "test", Sink: sink}
+			handler.OnUpdate(old, tt.new)
+
+			events := sink.Events()
+			if tt.wantDetails == nil {
+				if len(events) != 0 {
+					t.Fatalf("emitted %+v, want no event", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("emitted %d events, want 1", len(events))
+			}
+			if event := events[0]; event.Action != "Updated" || event.GVK != nodeGVK || event.Name != "node-1" {
+				t.Errorf("event = %+v, want an update of node-1", event)
+			}
+			if got := events[0].Details; !reflect.DeepEqual(got, tt.wantDetails) {
+				t.Errorf("details = %q, want %q", got, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestNodeChangesUncordoned(t *testing.T) {
+	want := []string{"Node uncordoned (schedulable)"}
+	if got := nodeChanges(fixtureNode("1", true, nil), fixtureNode("2", false, nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("nodeChanges() = %q, want %q", got, want)
+	}
+}
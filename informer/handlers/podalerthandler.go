@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	reasonOOMKilled        = "OOMKilled"
+	reasonCrashLoopBackOff = "CrashLoopBackOff"
+)
+
+// PodAlertHandler implements ResourceEventHandler for Pod resources, emitting an "Alert"
+// event when a container is OOMKilled or keeps crash looping. Each container and reason
+// alerts once per Cooldown, and state is dropped when the pod is deleted.
+type PodAlertHandler struct {
+	Caller string
+	// Sink receives the alerts; stdout when nil
+	Sink Sink
+	// RestartThreshold is the restart count from which CrashLoopBackOff alerts; OOMKills always do
+	RestartThreshold int32
+	// Cooldown is the minimum time between two alerts for the same container and reason
+	Cooldown time.Duration
+	// Now returns the current time; time.Now when nil. Tests can substitute a fake clock.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	pods map[types.UID]*podAlertState
+}
+
+// podAlertState is what the handler remembers about one pod
+type podAlertState struct {
+	restarts map[string]int32
+	// alerted holds the last alert time per "container/reason"
+	alerted map[string]time.Time
+}
+
+func (h *PodAlertHandler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// OnAdd is called when a Pod is added. Existing restarts become the baseline, so only
+// restarts observed while watching alert.
+func (h *PodAlertHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		log.Println("Error: PodAlertHandler OnAdd received non-Pod object")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state := h.state(pod.UID)
+	for _, status := range pod.Status.ContainerStatuses {
+		state.restarts[status.Name] = status.RestartCount
+	}
+}
+
+// OnUpdate is called when a Pod is modified
+func (h *PodAlertHandler) OnUpdate(oldObj, newObj interface{}) {
+	pod, ok := newObj.(*v1.Pod)
+	if !ok {
+		log.Println("Error: PodAlertHandler OnUpdate received non-Pod object")
+		return
+	}
+
+	for _, event := range h.evaluate(pod) {
+		emit(h.Sink, h.Caller, "PodAlertHandler", event)
+	}
+}
+
+// OnDelete is called when a Pod is deleted and forgets its state
+func (h *PodAlertHandler) OnDelete(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		// Check for tombstone
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		pod, ok = tombstone.Obj.(*v1.Pod)
+		if !ok {
+			return
+		}
+	}
+
+	h.mu.Lock()
+	delete(h.pods, pod.UID)
+	h.mu.Unlock()
+}
+
+// Tracked returns the number of pods the handler holds state for
+func (h *PodAlertHandler) Tracked() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.pods)
+}
+
+// state returns the pod's state, creating it. h.mu must be held.
+func (h *PodAlertHandler) state(uid types.UID) *podAlertState {
+	if h.pods == nil {
+		h.pods = map[types.UID]*podAlertState{}
+	}
+	state, ok := h.pods[uid]
+	if !ok {
+		state = &podAlertState{restarts: map[string]int32{}, alerted: map[string]time.Time{}}
+		h.pods[uid] = state
+	}
+	return state
+}
+
+// evaluate updates the restart counts of the pod and returns the alerts that are due
+func (h *PodAlertHandler) evaluate(pod *v1.Pod) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := h.state(pod.UID)
+	now := h.now()
+
+	var alerts []Event
+	for _, status := range pod.Status.ContainerStatuses {
+		previous, seen := state.restarts[status.Name]
+		state.restarts[status.Name] = status.RestartCount
+		restarted := seen && status.RestartCount > previous
+
+		reason := ""
+		switch {
+		case restarted && status.LastTerminationState.Terminated != nil &&
+			status.LastTerminationState.Terminated.Reason == reasonOOMKilled:
+			reason = reasonOOMKilled
+		case status.State.Waiting != nil && status.State.Waiting.Reason == reasonCrashLoopBackOff &&
+			status.RestartCount >= h.RestartThreshold:
+			reason = reasonCrashLoopBackOff
+		default:
+			continue
+		}
+
+		key := status.Name + "/" + reason
+		if last, ok := state.alerted[key]; ok && now.Sub(last) < h.Cooldown {
+			continue
+		}
+		state.alerted[key] = now
+
+		alerts = append(alerts, Event{
+			Time:      now,
+			Action:    "Alert",
+			GVK:       podGVK,
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Summary: map[string]string{
+				"container": status.Name,
+				"reason":    reason,
+				"restarts":  strconv.Itoa(int(status.RestartCount)),
+			},
+			Details: []string{alertMessage(status, reason)},
+		})
+	}
+	return alerts
+}
+
+func alertMessage(status v1.ContainerStatus, reason string) string {
+	if reason == reasonOOMKilled {
+		return fmt.Sprintf("Container %s was OOMKilled (restart %d)", status.Name, status.RestartCount)
+	}
+	message := fmt.Sprintf("Container %s is in CrashLoopBackOff after %d restarts", status.Name, status.RestartCount)
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		message += fmt.Sprintf(", last exit code %d (%s)", terminated.ExitCode, terminated.Reason)
+	}
+	return message
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeClock is a clock tests move by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// alertPod is the web-0 pod with uid, its app container at restarts and, when given, waiting
+// or last terminated for reason
+func alertPod(uid types.UID, restarts int32, waiting, lastTerminated string) *v1.Pod {
+	status := v1.ContainerStatus{Name: "app", RestartCount: restarts}
+	if waiting != "" {
+		status.State.Waiting = &v1.ContainerStateWaiting{Reason: waiting}
+	}
+	if lastTerminated != "" {
+		status.LastTerminationState.Terminated = &v1.ContainerStateTerminated{Reason: lastTerminated, ExitCode: 137}
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default", UID: uid},
+		Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{status}},
+	}
+}
+
+func TestPodAlertHandler(t *testing.T) {
+	// step is an update of the pod after the clock moved by advance, and the reasons it alerts
+	type step struct {
+		advance     time.Duration
+		pod         *v1.Pod
+		wantReasons []string
+	}
+
+	tests := []struct {
+		name  string
+		added *v1.Pod
+		steps []step
+	}{
+		{
+			name:  "OOMKill alerts on the restart",
+			added: alertPod("a", 0, "", ""),
+			steps: []step{
+				{pod: alertPod("a", 1, "", reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+				// The same restart seen again is no new OOMKill
+				{advance: time.Hour, pod: alertPod("a", 1, "", reasonOOMKilled)},
+			},
+		},
+		{
+			name:  "restarts before the handler started are the baseline",
+			added: alertPod("a", 4, "", reasonOOMKilled),
+			steps: []step{{pod: alertPod("a", 4, "", reasonOOMKilled)}},
+		},
+		{
+			name:  "OOMKills within the cooldown are deduplicated",
+			added: alertPod("a", 0, "", ""),
+			steps: []step{
+				{pod: alertPod("a", 1, "", reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+				{advance: 4 * time.Minute, pod: alertPod("a", 2, "", reasonOOMKilled)},
+				{advance: 59 * time.Second, pod: alertPod("a", 3, "", reasonOOMKilled)},
+				{advance: time.Second, pod: alertPod("a", 4, "", reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+			},
+		},
+		{
+			name:  "crash loop below the restart threshold",
+			added: alertPod("a", 0, "", ""),
+			steps: []step{
+				{pod: alertPod("a", 2, reasonCrashLoopBackOff, "Error")},
+				{pod: alertPod("a", 3, reasonCrashLoopBackOff, "Error"), wantReasons: []string{reasonCrashLoopBackOff}},
+				{advance: time.Minute, pod: alertPod("a", 4, reasonCrashLoopBackOff, "Error")},
+				{advance: 5 * time.Minute, pod: alertPod("a", 5, reasonCrashLoopBackOff, "Error"), wantReasons: []string{reasonCrashLoopBackOff}},
+			},
+		},
+		{
+			name:  "reasons have their own cooldown",
+			added: alertPod("a", 2, "", ""),
+			steps: []step{
+				{pod: alertPod("a", 3, reasonCrashLoopBackOff, "Error"), wantReasons: []string{reasonCrashLoopBackOff}},
+				{advance: time.Second, pod: alertPod("a", 4, reasonCrashLoopBackOff, reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+			},
+		},
+		{
+			name:  "a new pod of the same name starts over",
+			added: alertPod("a", 0, "", ""),
+			steps: []step{
+				{pod: alertPod("a", 1, "", reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+				{advance: time.Second, pod: alertPod("b", 0, "", "")},
+				{advance: time.Second, pod: alertPod("b", 1, "", reasonOOMKilled), wantReasons: []string{reasonOOMKilled}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+			sink := &recordingSink{}
+			handler := &PodAlertHandler{Caller: "test", Sink: sink, RestartThreshold: 3, Cooldown: 5 * time.Minute, Now: clock.Now}
+			handler.OnAdd(tt.added, true)
+
+			for i, step := range tt.steps {
+				clock.now = clock.now.Add(step.advance)
+				before := len(sink.Events())
+				handler.OnUpdate(tt.added, step.pod)
+
+				var reasons []string
+				for _, event := range sink.Events()[before:] {
+					reasons = append(reasons, event.Summary["reason"])
+					if !event.Time.Equal(clock.now) {
+						t.Errorf("step %d: alert at %v, want the fake clock's %v", i, event.Time, clock.now)
+					}
+				}
+				if !reflect.DeepEqual(reasons, step.wantReasons) {
+					t.Errorf("step %d: alerted %q, want %q", i, reasons, step.wantReasons)
+				}
+			}
+		})
+	}
+}
+
+func TestPodAlertHandlerForgetsDeletedPods(t *testing.T) {
+	handler := &PodAlertHandler{Sink: &recordingSink{}}
+	handler.OnAdd(alertPod("a", 0, "", ""), true)
+	handler.OnAdd(alertPod("b", 0, "", ""), true)
+	handler.OnDelete(alertPod("a", 0, "", ""))
+	if got := handler.Tracked(); got != 1 {
+		t.Errorf("tracking %d pods after a delete, want 1", got)
+	}
+}
@@ -2,55 +2,56 @@ package handlers
 
 import (
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
+var podGVK = v1.SchemeGroupVersion.WithKind("Pod")
+
 // PodHandler implements ResourceEventHandler for Pod resources
 type PodHandler struct {
 	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
 }
 
 // OnAdd is called when a Pod is added
 func (h *PodHandler) OnAdd(obj interface{}, isInInitialList bool) {
 	pod, ok := obj.(*v1.Pod)
 	if !ok {
-		fmt.Println("Error: OnAdd received non-Pod object")
+		log.Println("Error: OnAdd received non-Pod object")
 		return
 	}
 
-	initialListMsg := ""
-	if isInInitialList {
-		initialListMsg = " (initial list)"
-	}
-
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
-	fmt.Printf("[Caller: %s] [PodHandler] Pod Added%s: %s/%s (phase: %s, containers: %d)\n",
-		caller,
-		initialListMsg,
-		pod.Namespace,
-		pod.Name,
-		pod.Status.Phase,
-		len(pod.Spec.Containers))
+	emit(h.Sink, h.Caller, "PodHandler", Event{
+		Action:      "Added",
+		GVK:         podGVK,
+		Namespace:   pod.Namespace,
+		Name:        pod.Name,
+		InitialList: isInInitialList,
+		Summary: map[string]string{
+			"phase":      string(pod.Status.Phase),
+			"containers": strconv.Itoa(len(pod.Spec.Containers)),
+		},
+	})
 }
 
 // OnUpdate is called when a Pod is modified
 func (h *PodHandler) OnUpdate(oldObj, newObj interface{}) {
 	oldPod, ok := oldObj.(*v1.Pod)
 	if !ok {
-		fmt.Println("Error: OnUpdate received non-Pod object for old object")
+		log.Println("Error: OnUpdate received non-Pod object for old object")
 		return
 	}
 
 	newPod, ok := newObj.(*v1.Pod)
 	if !ok {
-		fmt.Println("Error: OnUpdate received non-Pod object for new object")
+		log.Println("Error: OnUpdate received non-Pod object for new object")
 		return
 	}
 
@@ -59,26 +60,22 @@ func (h *PodHandler) OnUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
+	event := Event{
+		Action:    "Updated",
+		GVK:       podGVK,
+		Namespace: newPod.Namespace,
+		Name:      newPod.Name,
+		Summary:   map[string]string{"rv": newPod.ResourceVersion},
 	}
 
 	// Report meaningful changes
 	if oldPod.Status.Phase != newPod.Status.Phase {
-		fmt.Printf("[Caller: %s] [PodHandler] Pod Phase Changed: %s/%s (%s -> %s)\n",
-			caller,
-			newPod.Namespace,
-			newPod.Name,
-			oldPod.Status.Phase,
-			newPod.Status.Phase)
-	} else {
-		fmt.Printf("[Caller: %s] [PodHandler] Pod Updated: %s/%s (rv: %s)\n",
-			caller,
-			newPod.Namespace,
-			newPod.Name,
-			newPod.ResourceVersion)
+		event.Action = "Phase Changed"
+		event.Summary = map[string]string{
+			"phase": fmt.Sprintf("%s -> %s", oldPod.Status.Phase, newPod.Status.Phase),
+		}
 	}
+	emit(h.Sink, h.Caller, "PodHandler", event)
 }
 
 // OnDelete is called when a Pod is deleted
@@ -88,63 +85,57 @@ func (h *PodHandler) OnDelete(obj interface{}) {
 		// When a delete is observed, the object might be a DeletedFinalStateUnknown
 		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
 		if !ok {
-			fmt.Println("Error: OnDelete received non-Pod and non-DeletedFinalStateUnknown object")
+			log.Println("Error: OnDelete received non-Pod and non-DeletedFinalStateUnknown object")
 			return
 		}
 
 		pod, ok = tombstone.Obj.(*v1.Pod)
 		if !ok {
-			fmt.Println("Error: DeletedFinalStateUnknown contained non-Pod object")
+			log.Println("Error: DeletedFinalStateUnknown contained non-Pod object")
 			return
 		}
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
-	fmt.Printf("[Caller: %s] [PodHandler] Pod Deleted: %s/%s\n",
-		caller,
-		pod.Namespace,
-		pod.Name)
+	emit(h.Sink, h.Caller, "PodHandler", Event{
+		Action:    "Deleted",
+		GVK:       podGVK,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+	})
 }
 
 // NewPodHandler is an alternative handler implementation for pods
 type NewPodHandler struct {
 	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
 }
 
 // OnAdd is called when a Pod is added
 func (h *NewPodHandler) OnAdd(obj interface{}, isInInitialList bool) {
 	pod, ok := obj.(*v1.Pod)
 	if !ok {
-		fmt.Println("Error: NewPodHandler OnAdd received non-Pod object")
+		log.Println("Error: NewPodHandler OnAdd received non-Pod object")
 		return
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
+	event := Event{
+		Action:    "Added",
+		GVK:       podGVK,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Summary:   map[string]string{"created": pod.CreationTimestamp.Format(time.RFC3339)},
 	}
 
-	fmt.Printf("[Caller: %s] [NewPodHandler] Pod Added: %s/%s (created: %s)\n",
-		caller,
-		pod.Namespace,
-		pod.Name,
-		pod.CreationTimestamp.Format(time.RFC3339))
-
 	// Show container information
 	if len(pod.Spec.Containers) > 0 {
-		fmt.Printf("  Containers: ")
-		for i, container := range pod.Spec.Containers {
-			if i > 0 {
-				fmt.Printf(", ")
-			}
-			fmt.Printf("%s (image: %s)", container.Name, container.Image)
+		containers := make([]string, 0, len(pod.Spec.Containers))
+		for _, container := range pod.Spec.Containers {
+			containers = append(containers, fmt.Sprintf("%s (image: %s)", container.Name, container.Image))
 		}
-		fmt.Println()
+		event.Details = []string{"Containers: " + strings.Join(containers, ", ")}
 	}
+	emit(h.Sink, h.Caller, "NewPodHandler", event)
 }
 
 // OnUpdate is called when a Pod is modified
@@ -154,15 +145,12 @@ func (h *NewPodHandler) OnUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
-	fmt.Printf("[Caller: %s] [NewPodHandler] Pod Updated: %s/%s\n",
-		caller,
-		newPod.Namespace,
-		newPod.Name)
+	emit(h.Sink, h.Caller, "NewPodHandler", Event{
+		Action:    "Updated",
+		GVK:       podGVK,
+		Namespace: newPod.Namespace,
+		Name:      newPod.Name,
+	})
 }
 
 // OnDelete is called when a Pod is deleted
@@ -180,13 +168,10 @@ func (h *NewPodHandler) OnDelete(obj interface{}) {
 		}
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
-	fmt.Printf("[Caller: %s] [NewPodHandler] Pod Deleted: %s/%s\n",
-		caller,
-		pod.Namespace,
-		pod.Name)
+	emit(h.Sink, h.Caller, "NewPodHandler", Event{
+		Action:    "Deleted",
+		GVK:       podGVK,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+	})
 }
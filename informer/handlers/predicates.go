@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Predicate decides which events reach a handler wrapped with Filtered
+type Predicate interface {
+	// Object reports whether an added or deleted object passes
+	Object(obj interface{}) bool
+	// Update reports whether an update passes
+	Update(oldObj, newObj interface{}) bool
+}
+
+// ObjectFunc is a predicate on a single object. On its own it filters like
+// cache.FilteringResourceEventHandler: an update into or out of the filter is delivered
+// as an add or a delete. Combined with update predicates it checks the new object.
+type ObjectFunc func(obj interface{}) bool
+
+func (f ObjectFunc) Object(obj interface{}) bool {
+	return f(obj)
+}
+
+func (f ObjectFunc) Update(oldObj, newObj interface{}) bool {
+	return f(newObj)
+}
+
+// UpdateFunc is a predicate comparing both objects of an update. Adds and deletes pass.
+type UpdateFunc func(oldObj, newObj interface{}) bool
+
+func (f UpdateFunc) Object(obj interface{}) bool {
+	return true
+}
+
+func (f UpdateFunc) Update(oldObj, newObj interface{}) bool {
+	return f(oldObj, newObj)
+}
+
+// predicate is a combination of object and update predicates
+type predicate struct {
+	object func(obj interface{}) bool
+	update func(oldObj, newObj interface{}) bool
+}
+
+func (p predicate) Object(obj interface{}) bool {
+	return p.object(obj)
+}
+
+func (p predicate) Update(oldObj, newObj interface{}) bool {
+	return p.update(oldObj, newObj)
+}
+
+// Filtered passes only the events accepted by pred to handler
+func Filtered(pred Predicate, handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	// Object predicates need no update-specific handling
+	if f, ok := pred.(ObjectFunc); ok {
+		return cache.FilteringResourceEventHandler{
+			FilterFunc: func(obj interface{}) bool {
+				return f(unwrap(obj))
+			},
+			Handler: handler,
+		}
+	}
+	return &filtered{pred: pred, handler: handler}
+}
+
+type filtered struct {
+	pred    Predicate
+	handler cache.ResourceEventHandler
+}
+
+func (f *filtered) OnAdd(obj interface{}, isInInitialList bool) {
+	if f.pred.Object(obj) {
+		f.handler.OnAdd(obj, isInInitialList)
+	}
+}
+
+func (f *filtered) OnUpdate(oldObj, newObj interface{}) {
+	if f.pred.Update(oldObj, newObj) {
+		f.handler.OnUpdate(oldObj, newObj)
+	}
+}
+
+func (f *filtered) OnDelete(obj interface{}) {
+	if f.pred.Object(unwrap(obj)) {
+		f.handler.OnDelete(obj)
+	}
+}
+
+// And passes events all predicates pass
+func And(preds ...Predicate) Predicate {
+	if objects, ok := objectFuncs(preds); ok {
+		return ObjectFunc(func(obj interface{}) bool {
+			for _, f := range objects {
+				if !f(obj) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return predicate{
+		object: func(obj interface{}) bool {
+			for _, p := range preds {
+				if !p.Object(obj) {
+					return false
+				}
+			}
+			return true
+		},
+		update: func(oldObj, newObj interface{}) bool {
+			for _, p := range preds {
+				if !p.Update(oldObj, newObj) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Or passes events any predicate passes
+func Or(preds ...Predicate) Predicate {
+	if objects, ok := objectFuncs(preds); ok {
+		return ObjectFunc(func(obj interface{}) bool {
+			for _, f := range objects {
+				if f(obj) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return predicate{
+		object: func(obj interface{}) bool {
+			for _, p := range preds {
+				if p.Object(obj) {
+					return true
+				}
+			}
+			return false
+		},
+		update: func(oldObj, newObj interface{}) bool {
+			for _, p := range preds {
+				if p.Update(oldObj, newObj) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Not passes events pred rejects. Negating an update predicate keeps passing adds and deletes.
+func Not(pred Predicate) Predicate {
+	switch p := pred.(type) {
+	case ObjectFunc:
+		return ObjectFunc(func(obj interface{}) bool {
+			return !p(obj)
+		})
+	case UpdateFunc:
+		return UpdateFunc(func(oldObj, newObj interface{}) bool {
+			return !p(oldObj, newObj)
+		})
+	}
+	return predicate{
+		object: func(obj interface{}) bool {
+			return !pred.Object(obj)
+		},
+		update: func(oldObj, newObj interface{}) bool {
+			return !pred.Update(oldObj, newObj)
+		},
+	}
+}
+
+// objectFuncs returns the predicates as object predicates if they all are
+func objectFuncs(preds []Predicate) ([]ObjectFunc, bool) {
+	objects := make([]ObjectFunc, 0, len(preds))
+	for _, p := range preds {
+		f, ok := p.(ObjectFunc)
+		if !ok {
+			return nil, false
+		}
+		objects = append(objects, f)
+	}
+	return objects, true
+}
+
+// MatchLabels passes objects whose labels match selector
+func MatchLabels(selector labels.Selector) ObjectFunc {
+	return func(obj interface{}) bool {
+		accessor, ok := objectMeta(obj)
+		return ok && selector.Matches(labels.Set(accessor.GetLabels()))
+	}
+}
+
+// InNamespace passes objects in one of the namespaces
+func InNamespace(namespaces ...string) ObjectFunc {
+	return func(obj interface{}) bool {
+		accessor, ok := objectMeta(obj)
+		return ok && slices.Contains(namespaces, accessor.GetNamespace())
+	}
+}
+
+// HasAnnotation passes objects carrying the annotation, whatever its value
+func HasAnnotation(key string) ObjectFunc {
+	return func(obj interface{}) bool {
+		accessor, ok := objectMeta(obj)
+		if !ok {
+			return false
+		}
+		_, found := accessor.GetAnnotations()[key]
+		return found
+	}
+}
+
+// GenerationChanged passes updates that changed metadata.generation, i.e. the spec,
+// skipping status-only updates and resyncs
+func GenerationChanged() UpdateFunc {
+	return func(oldObj, newObj interface{}) bool {
+		oldMeta, ok := objectMeta(oldObj)
+		if !ok {
+			return false
+		}
+		newMeta, ok := objectMeta(newObj)
+		return ok && oldMeta.GetGeneration() != newMeta.GetGeneration()
+	}
+}
+
+// PhaseChanged passes updates that changed status.phase
+func PhaseChanged() UpdateFunc {
+	return func(oldObj, newObj interface{}) bool {
+		oldPhase, ok := phase(oldObj)
+		if !ok {
+			return false
+		}
+		newPhase, ok := phase(newObj)
+		return ok && oldPhase != newPhase
+	}
+}
+
+// phase returns status.phase of the kinds that have one
+func phase(obj interface{}) (string, bool) {
+	switch o := obj.(type) {
+	case *v1.Pod:
+		return string(o.Status.Phase), true
+	case *v1.PersistentVolumeClaim:
+		return string(o.Status.Phase), true
+	case *v1.PersistentVolume:
+		return string(o.Status.Phase), true
+	case *v1.Namespace:
+		return string(o.Status.Phase), true
+	case *unstructured.Unstructured:
+		value, found, err := unstructured.NestedString(o.Object, "status", "phase")
+		return value, found && err == nil
+	}
+	return "", false
+}
+
+func objectMeta(obj interface{}) (metav1.Object, bool) {
+	accessor, err := meta.Accessor(unwrap(obj))
+	return accessor, err == nil
+}
+
+// unwrap returns the last known state of a deleted object
+func unwrap(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
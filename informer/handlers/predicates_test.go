@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// predicatePod is a pod in ns with labels, generation and phase, annotated with annotations
+func predicatePod(ns string, podLabels map[string]string, generation int64, phase v1.PodPhase, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: ns, Labels: podLabels, Generation: generation, Annotations: annotations},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestObjectPredicates(t *testing.T) {
+	web := predicatePod("prod", map[string]string{"app": "web", "tier": "frontend"}, 1, v1.PodRunning, map[string]string{"team": ""})
+
+	tests := []struct {
+		name string
+		pred ObjectFunc
+		obj  interface{}
+		want bool
+	}{
+		{name: "labels match", pred: MatchLabels(labels.SelectorFromSet(labels.Set{"app": "web"})), obj: web, want: true},
+		{name: "labels differ", pred: MatchLabels(labels.SelectorFromSet(labels.Set{"app": "api"})), obj: web},
+		{name: "labels of a tombstone", pred: MatchLabels(labels.SelectorFromSet(labels.Set{"app": "web"})), obj: cache.DeletedFinalStateUnknown{Key: "prod/web-0", Obj: web}, want: true},
+		{name: "labels of a non-object", pred: MatchLabels(labels.Everything()), obj: "prod/web-0"},
+		{name: "in namespace", pred: InNamespace("dev", "prod"), obj: web, want: true},
+		{name: "other namespace", pred: InNamespace("dev"), obj: web},
+		{name: "annotation with empty value", pred: HasAnnotation("team"), obj: web, want: true},
+		{name: "annotation missing", pred: HasAnnotation("owner"), obj: web},
+		{
+			name: "unstructured",
+			pred: InNamespace("prod"),
+			obj:  &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "web", "namespace": "prod"}}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pred.Object(tt.obj); got != tt.want {
+				t.Errorf("Object() = %v, want %v", got, tt.want)
+			}
+			// On its own an object predicate checks the new object of updates
+			if got := tt.pred.Update(nil, tt.obj); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePredicates(t *testing.T) {
+	unstructuredPhase := func(phase string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "data"},
+			"status":   map[string]interface{}{"phase": phase},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		pred   UpdateFunc
+		oldObj interface{}
+		newObj interface{}
+		want   bool
+	}{
+		{
+			name:   "generation changed",
+			pred:   GenerationChanged(),
+			oldObj: predicatePod("prod", nil, 1, v1.PodRunning, nil),
+			newObj: predicatePod("prod", nil, 2, v1.PodRunning, nil),
+			want:   true,
+		},
+		{
+			name:   "status only",
+			pred:   GenerationChanged(),
+			oldObj: predicatePod("prod", nil, 1, v1.PodPending, nil),
+			newObj: predicatePod("prod", nil, 1, v1.PodRunning, nil),
+		},
+		{
+			name:   "phase changed",
+			pred:   PhaseChanged(),
+			oldObj: predicatePod("prod", nil, 1, v1.PodPending, nil),
+			newObj: predicatePod("prod", nil, 1, v1.PodRunning, nil),
+			want:   true,
+		},
+		{
+			name:   "phase unchanged",
+			pred:   PhaseChanged(),
+			oldObj: predicatePod("prod", nil, 1, v1.PodRunning, nil),
+			newObj: predicatePod("prod", nil, 2, v1.PodRunning, nil),
+		},
+		{
+			name:   "phase of unstructured",
+			pred:   PhaseChanged(),
+			oldObj: unstructuredPhase("Pending"),
+			newObj: unstructuredPhase("Bound"),
+			want:   true,
+		},
+		{
+			name:   "kind without a phase",
+			pred:   PhaseChanged(),
+			oldObj: &v1.ConfigMap{},
+			newObj: &v1.ConfigMap{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pred.Update(tt.oldObj, tt.newObj); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+			// Adds and deletes pass update predicates
+			if !tt.pred.Object(tt.newObj) {
+				t.Error("Object() = false, want true")
+			}
+		})
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	inProd := InNamespace("prod")
+	isWeb := MatchLabels(labels.SelectorFromSet(labels.Set{"app": "web"}))
+	specChanged := GenerationChanged()
+
+	prodWeb := predicatePod("prod", map[string]string{"app": "web"}, 1, v1.PodRunning, nil)
+	prodWebNewSpec := predicatePod("prod", map[string]string{"app": "web"}, 2, v1.PodRunning, nil)
+	devWeb := predicatePod("dev", map[string]string{"app": "web"}, 1, v1.PodRunning, nil)
+	devWebNewSpec := predicatePod("dev", map[string]string{"app": "web"}, 2, v1.PodRunning, nil)
+
+	tests := []struct {
+		name       string
+		pred       Predicate
+		obj        interface{}
+		oldObj     interface{}
+		newObj     interface{}
+		wantObject bool
+		wantUpdate bool
+	}{
+		{name: "and of objects", pred: And(inProd, isWeb), obj: prodWeb, oldObj: prodWeb, newObj: prodWeb, wantObject: true, wantUpdate: true},
+		{name: "and of objects, one fails", pred: And(inProd, isWeb), obj: devWeb, oldObj: devWeb, newObj: devWeb},
+		{name: "and with an update predicate", pred: And(inProd, specChanged), obj: prodWeb, oldObj: prodWeb, newObj: prodWebNewSpec, wantObject: true, wantUpdate: true},
+		{name: "and with an update predicate, no spec change", pred: And(inProd, specChanged), obj: prodWeb, oldObj: prodWeb, newObj: prodWeb, wantObject: true},
+		{name: "and with an update predicate, other namespace", pred: And(inProd, specChanged), obj: devWeb, oldObj: devWeb, newObj: devWebNewSpec},
+		{name: "or of objects", pred: Or(InNamespace("dev"), inProd), obj: prodWeb, oldObj: prodWeb, newObj: prodWeb, wantObject: true, wantUpdate: true},
+		{name: "or with an update predicate", pred: Or(inProd, specChanged), obj: devWeb, oldObj: devWeb, newObj: devWebNewSpec, wantObject: true, wantUpdate: true},
+		{name: "or, neither", pred: Or(InNamespace("staging"), Not(specChanged)), obj: devWeb, oldObj: devWeb, newObj: devWebNewSpec, wantObject: true},
+		{name: "not of an object", pred: Not(inProd), obj: devWeb, oldObj: devWeb, newObj: devWeb, wantObject: true, wantUpdate: true},
+		// Negated update predicates still pass adds and deletes
+		{name: "not of an update", pred: Not(specChanged), obj: prodWeb, oldObj: prodWeb, newObj: prodWeb, wantObject: true, wantUpdate: true},
+		{name: "not of a combination", pred: Not(And(inProd, specChanged)), obj: prodWeb, oldObj: prodWeb, newObj: prodWebNewSpec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pred.Object(tt.obj); got != tt.wantObject {
+				t.Errorf("Object() = %v, want %v", got, tt.wantObject)
+			}
+			if got := tt.pred.Update(tt.oldObj, tt.newObj); got != tt.wantUpdate {
+				t.Errorf("Update() = %v, want %v", got, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+// callRecorder records the events delivered to it as "add", "update" and "delete"
+type callRecorder struct {
+	calls []string
+}
+
+func (r *callRecorder) OnAdd(obj interface{}, isInInitialList bool) {
+	r.calls = append(r.calls, "add")
+}
+
+func (r *callRecorder) OnUpdate(oldObj, newObj interface{}) {
+	r.calls = append(r.calls, "update")
+}
+
+func (r *callRecorder) OnDelete(obj interface{}) {
+	r.calls = append(r.calls, "delete")
+}
+
+func TestFiltered(t *testing.T) {
+	prodWeb := predicatePod("prod", nil, 1, v1.PodRunning, nil)
+	prodWebNewSpec := predicatePod("prod", nil, 2, v1.PodRunning, nil)
+	devWeb := predicatePod("dev", nil, 1, v1.PodRunning, nil)
+
+	tests := []struct {
+		name      string
+		pred      Predicate
+		deliver   func(h cache.ResourceEventHandler)
+		wantCalls []string
+	}{
+		{
+			name: "object predicate",
+			pred: InNamespace("prod"),
+			deliver: func(h cache.ResourceEventHandler) {
+				h.OnAdd(prodWeb, false)
+				h.OnAdd(devWeb, false)
+				h.OnDelete(cache.DeletedFinalStateUnknown{Key: "prod/web-0", Obj: prodWeb})
+			},
+			wantCalls: []string{"add", "delete"},
+		},
+		{
+			// Like cache.FilteringResourceEventHandler, moving in or out of the filter is an add or delete
+			name: "object predicate transitions",
+			pred: InNamespace("prod"),
+			deliver: func(h cache.ResourceEventHandler) {
+				h.OnUpdate(devWeb, prodWeb)
+				h.OnUpdate(prodWeb, prodWebNewSpec)
+				h.OnUpdate(prodWeb, devWeb)
+			},
+			wantCalls: []string{"add", "update", "delete"},
+		},
+		{
+			name: "update predicate",
+			pred: GenerationChanged(),
+			deliver: func(h cache.ResourceEventHandler) {
+				h.OnAdd(prodWeb, true)
+				h.OnUpdate(prodWeb, prodWeb)
+				h.OnUpdate(prodWeb, prodWebNewSpec)
+				h.OnDelete(prodWeb)
+			},
+			wantCalls: []string{"add", "update", "delete"},
+		},
+		{
+			name: "combined",
+			pred: And(InNamespace("prod"), GenerationChanged()),
+			deliver: func(h cache.ResourceEventHandler) {
+				h.OnAdd(devWeb, true)
+				h.OnUpdate(prodWeb, prodWeb)
+				h.OnUpdate(prodWeb, prodWebNewSpec)
+				h.OnDelete(cache.DeletedFinalStateUnknown{Key: "dev/web-0", Obj: devWeb})
+			},
+			wantCalls: []string{"update"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &callRecorder{}
+			tt.deliver(Filtered(tt.pred, recorder))
+			if !reflect.DeepEqual(recorder.calls, tt.wantCalls) {
+				t.Errorf("delivered %q, want %q", recorder.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/tools/cache"
+)
+
+var pvcGVK = v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim")
+
+// PVCHandler implements ResourceEventHandler for PersistentVolumeClaim resources, reporting
+// when a claim binds, with its volume and storage class, and warning once about claims
+// Pending for longer than PendingAfter. Like stuck namespaces, long Pending claims are
+// found on updates and resyncs.
+type PVCHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+	// PendingAfter is how long a claim may be Pending before a warning
+	PendingAfter time.Duration
+	// Now returns the current time; time.Now when nil
+	Now func() time.Time
+
+	mu     sync.Mutex
+	warned map[types.UID]bool
+}
+
+func (h *PVCHandler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// OnAdd is called when a PersistentVolumeClaim is added
+func (h *PVCHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	pvc, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		log.Println("Error: OnAdd received non-PersistentVolumeClaim object")
+		return
+	}
+
+	emit(h.Sink, h.Caller, "PVCHandler", Event{
+		Action:      "Added",
+		GVK:         pvcGVK,
+		Namespace:   pvc.Namespace,
+		Name:        pvc.Name,
+		InitialList: isInInitialList,
+		Summary:     pvcSummary(pvc),
+	})
+	h.checkPending(pvc)
+}
+
+// OnUpdate is called when a PersistentVolumeClaim is modified, and on every resync
+func (h *PVCHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldPVC, ok := oldObj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		log.Println("Error: OnUpdate received non-PersistentVolumeClaim object for old object")
+		return
+	}
+
+	newPVC, ok := newObj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		log.Println("Error: OnUpdate received non-PersistentVolumeClaim object for new object")
+		return
+	}
+
+	// Resyncs carry no change but are when a Pending claim gets old enough
+	if newPVC.ResourceVersion == oldPVC.ResourceVersion {
+		h.checkPending(newPVC)
+		return
+	}
+
+	if oldPVC.Status.Phase != v1.ClaimBound && newPVC.Status.Phase == v1.ClaimBound {
+		h.forget(newPVC.UID)
+		emit(h.Sink, h.Caller, "PVCHandler", Event{
+			Action:    "Bound",
+			GVK:       pvcGVK,
+			Namespace: newPVC.Namespace,
+			Name:      newPVC.Name,
+			Summary:   pvcSummary(newPVC),
+			Details: []string{fmt.Sprintf("Phase changed from %s to Bound after %s",
+				oldPVC.Status.Phase, duration.HumanDuration(h.now().Sub(newPVC.CreationTimestamp.Time)))},
+		})
+		return
+	}
+
+	if oldPVC.Status.Phase != newPVC.Status.Phase {
+		emit(h.Sink, h.Caller, "PVCHandler", Event{
+			Action:    "Updated",
+			GVK:       pvcGVK,
+			Namespace: newPVC.Namespace,
+			Name:      newPVC.Name,
+			Summary:   pvcSummary(newPVC),
+			Details:   []string{fmt.Sprintf("Phase changed from %s to %s", oldPVC.Status.Phase, newPVC.Status.Phase)},
+		})
+	}
+	h.checkPending(newPVC)
+}
+
+// OnDelete is called when a PersistentVolumeClaim is deleted
+func (h *PVCHandler) OnDelete(obj interface{}) {
+	pvc, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-PersistentVolumeClaim and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		pvc, ok = tombstone.Obj.(*v1.PersistentVolumeClaim)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-PersistentVolumeClaim object")
+			return
+		}
+	}
+
+	h.forget(pvc.UID)
+	emit(h.Sink, h.Caller, "PVCHandler", Event{
+		Action:    "Deleted",
+		GVK:       pvcGVK,
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		Summary:   pvcSummary(pvc),
+	})
+}
+
+// checkPending warns once when the claim has been Pending for longer than PendingAfter
+func (h *PVCHandler) checkPending(pvc *v1.PersistentVolumeClaim) {
+	if pvc.Status.Phase != v1.ClaimPending || h.PendingAfter <= 0 {
+		return
+	}
+	pending := h.now().Sub(pvc.CreationTimestamp.Time)
+	if pending < h.PendingAfter {
+		return
+	}
+
+	h.mu.Lock()
+	if h.warned == nil {
+		h.warned = map[types.UID]bool{}
+	}
+	warned := h.warned[pvc.UID]
+	h.warned[pvc.UID] = true
+	h.mu.Unlock()
+	if warned {
+		return
+	}
+
+	emit(h.Sink, h.Caller, "PVCHandler", Event{
+		Action:    "Warning",
+		GVK:       pvcGVK,
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		Summary:   pvcSummary(pvc),
+		Details:   []string{fmt.Sprintf("Claim has been Pending for %s", duration.HumanDuration(pending))},
+	})
+}
+
+func (h *PVCHandler) forget(uid types.UID) {
+	h.mu.Lock()
+	delete(h.warned, uid)
+	h.mu.Unlock()
+}
+
+// pvcSummary reports the phase, the bound volume and the storage class of a claim
+func pvcSummary(pvc *v1.PersistentVolumeClaim) map[string]string {
+	summary := map[string]string{"phase": string(pvc.Status.Phase)}
+	if pvc.Spec.VolumeName != "" {
+		summary["volume"] = pvc.Spec.VolumeName
+	}
+	if pvc.Spec.StorageClassName != nil {
+		summary["storageClass"] = *pvc.Spec.StorageClassName
+	}
+	return summary
+}
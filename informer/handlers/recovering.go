@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"kgent-api/pkg/recovery"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// Recovering wraps inner so a panic in it is logged with its stack, counted and passed to
+// onPanic, when set, instead of stopping event delivery for every handler on the informer
+func Recovering(inner cache.ResourceEventHandler, onPanic func(any)) cache.ResourceEventHandler {
+	return recovery.Handler(handlerName(inner), inner, onPanic)
+}
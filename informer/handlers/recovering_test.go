@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// panickingHandler panics on the adds of pods named in panicOn and records the others
+type panickingHandler struct {
+	panicOn map[string]bool
+
+	mu    sync.Mutex
+	added []string
+}
+
+func (h *panickingHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	name := obj.(*v1.Pod).Name
+	if h.panicOn[name] {
+		panic("handler bug on " + name)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.added = append(h.added, name)
+}
+
+func (h *panickingHandler) OnUpdate(oldObj, newObj interface{}) {
+	panic("handler bug on update")
+}
+
+func (h *panickingHandler) OnDelete(obj interface{}) {}
+
+func (h *panickingHandler) Added() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.added...)
+}
+
+func TestRecoveringKeepsEventsFlowing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fact := informers.NewSharedInformerFactory(client, 0)
+	informer := fact.Core().V1().Pods().Informer()
+
+	var mu sync.Mutex
+	var panics []any
+	flaky := &panickingHandler{panicOn: map[string]bool{"pod-1": true, "pod-3": true}}
+	if _, err := informer.AddEventHandler(Recovering(flaky, func(r any) {
+		mu.Lock()
+		defer mu.Unlock()
+		panics = append(panics, r)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// A healthy handler on the same informer keeps receiving events too
+	healthy := &panickingHandler{}
+	if _, err := informer.AddEventHandler(healthy); err != nil {
+		t.Fatal(err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	fact.Start(stopCh)
+	fact.WaitForCacheSync(stopCh)
+
+	for i := 0; i < 5; i++ {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i), Namespace: "default"}}
+		if _, err := client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for (len(healthy.Added()) < 5 || len(flaky.Added()) < 3) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if got := healthy.Added(); len(got) != 5 {
+		t.Errorf("healthy handler got %q, want all 5 pods", got)
+	}
+	if got, want := flaky.Added(), []string{"pod-0", "pod-2", "pod-4"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("panicking handler got %q after its panics, want %q", got, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(panics) != 2 {
+		t.Errorf("onPanic called with %v, want the 2 panics", panics)
+	}
+}
+
+func TestRecoveringRecoversEveryEvent(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-0", Namespace: "default"}}
+	var recovered []any
+	handler := Recovering(&panickingHandler{panicOn: map[string]bool{"pod-0": true}}, func(r any) {
+		recovered = append(recovered, r)
+	})
+
+	handler.OnAdd(pod, false)
+	handler.OnUpdate(pod, pod)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/pod-0", Obj: pod})
+	if len(recovered) != 2 {
+		t.Errorf("recovered %v, want the add and update panics", recovered)
+	}
+
+	// onPanic is optional
+	Recovering(&panickingHandler{}, nil).OnUpdate(pod, pod)
+}
@@ -2,34 +2,31 @@ package handlers
 
 import (
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
+var serviceGVK = v1.SchemeGroupVersion.WithKind("Service")
+
 // ServiceHandler implements ResourceEventHandler for Service resources
 type ServiceHandler struct {
 	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
 }
 
 // OnAdd is called when a Service is added
 func (h *ServiceHandler) OnAdd(obj interface{}, isInInitialList bool) {
 	svc, ok := obj.(*v1.Service)
 	if !ok {
-		fmt.Println("Error: OnAdd received non-Service object")
+		log.Println("Error: OnAdd received non-Service object")
 		return
 	}
 
-	initialListMsg := ""
-	if isInInitialList {
-		initialListMsg = " (initial list)"
-	}
-
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
 	var serviceType string
 	if len(string(svc.Spec.Type)) > 0 {
 		serviceType = string(svc.Spec.Type)
@@ -37,54 +34,58 @@ func (h *ServiceHandler) OnAdd(obj interface{}, isInInitialList bool) {
 		serviceType = "ClusterIP" // Default type
 	}
 
-	fmt.Printf("[Caller: %s] [ServiceHandler] Service Added%s: %s/%s (type: %s)\n",
-		caller,
-		initialListMsg,
-		svc.Namespace,
-		svc.Name,
-		serviceType)
+	event := Event{
+		Action:      "Added",
+		GVK:         serviceGVK,
+		Namespace:   svc.Namespace,
+		Name:        svc.Name,
+		InitialList: isInInitialList,
+		Summary:     map[string]string{"type": serviceType},
+	}
 
 	// Display service ports
 	if len(svc.Spec.Ports) > 0 {
-		fmt.Printf("  Ports: ")
-		for i, port := range svc.Spec.Ports {
-			if i > 0 {
-				fmt.Printf(", ")
-			}
+		ports := make([]string, 0, len(svc.Spec.Ports))
+		for _, port := range svc.Spec.Ports {
+			var b strings.Builder
 			if len(port.Name) > 0 {
-				fmt.Printf("%s:", port.Name)
+				fmt.Fprintf(&b, "%s:", port.Name)
 			}
-			fmt.Printf("%d->", port.Port)
+			fmt.Fprintf(&b, "%d->", port.Port)
 			if port.TargetPort.IntVal != 0 {
-				fmt.Printf("%d", port.TargetPort.IntVal)
+				fmt.Fprintf(&b, "%d", port.TargetPort.IntVal)
 			} else {
-				fmt.Printf("%s", port.TargetPort.StrVal)
+				b.WriteString(port.TargetPort.StrVal)
 			}
-			fmt.Printf("/%s", port.Protocol)
+			fmt.Fprintf(&b, "/%s", port.Protocol)
+			ports = append(ports, b.String())
 		}
-		fmt.Println()
+		event.Details = append(event.Details, "Ports: "+strings.Join(ports, ", "))
 	}
 
 	// Display selector if present
 	if len(svc.Spec.Selector) > 0 {
-		fmt.Println("  Selector:")
+		selector := make([]string, 0, len(svc.Spec.Selector))
 		for k, v := range svc.Spec.Selector {
-			fmt.Printf("    %s: %s\n", k, v)
+			selector = append(selector, k+"="+v)
 		}
+		sort.Strings(selector)
+		event.Details = append(event.Details, "Selector: "+strings.Join(selector, ","))
 	}
+	emit(h.Sink, h.Caller, "ServiceHandler", event)
 }
 
 // OnUpdate is called when a Service is modified
 func (h *ServiceHandler) OnUpdate(oldObj, newObj interface{}) {
 	oldSvc, ok := oldObj.(*v1.Service)
 	if !ok {
-		fmt.Println("Error: OnUpdate received non-Service object for old object")
+		log.Println("Error: OnUpdate received non-Service object for old object")
 		return
 	}
 
 	newSvc, ok := newObj.(*v1.Service)
 	if !ok {
-		fmt.Println("Error: OnUpdate received non-Service object for new object")
+		log.Println("Error: OnUpdate received non-Service object for new object")
 		return
 	}
 
@@ -93,29 +94,27 @@ func (h *ServiceHandler) OnUpdate(oldObj, newObj interface{}) {
 		return
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
+	event := Event{
+		Action:    "Updated",
+		GVK:       serviceGVK,
+		Namespace: newSvc.Namespace,
+		Name:      newSvc.Name,
 	}
 
-	fmt.Printf("[Caller: %s] [ServiceHandler] Service Updated: %s/%s\n",
-		caller,
-		newSvc.Namespace,
-		newSvc.Name)
-
 	// Check for type change
 	if oldSvc.Spec.Type != newSvc.Spec.Type {
-		fmt.Printf("  Service type changed: %s -> %s\n",
+		event.Details = append(event.Details, fmt.Sprintf("Service type changed: %s -> %s",
 			oldSvc.Spec.Type,
-			newSvc.Spec.Type)
+			newSvc.Spec.Type))
 	}
 
 	// Check for ClusterIP change (service re-creation often happens)
 	if oldSvc.Spec.ClusterIP != newSvc.Spec.ClusterIP {
-		fmt.Printf("  ClusterIP changed: %s -> %s\n",
+		event.Details = append(event.Details, fmt.Sprintf("ClusterIP changed: %s -> %s",
 			oldSvc.Spec.ClusterIP,
-			newSvc.Spec.ClusterIP)
+			newSvc.Spec.ClusterIP))
 	}
+	emit(h.Sink, h.Caller, "ServiceHandler", event)
 }
 
 // OnDelete is called when a Service is deleted
@@ -125,24 +124,21 @@ func (h *ServiceHandler) OnDelete(obj interface{}) {
 		// When a delete is observed, the object might be a DeletedFinalStateUnknown
 		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
 		if !ok {
-			fmt.Println("Error: OnDelete received non-Service and non-DeletedFinalStateUnknown object")
+			log.Println("Error: OnDelete received non-Service and non-DeletedFinalStateUnknown object")
 			return
 		}
 
 		svc, ok = tombstone.Obj.(*v1.Service)
 		if !ok {
-			fmt.Println("Error: DeletedFinalStateUnknown contained non-Service object")
+			log.Println("Error: DeletedFinalStateUnknown contained non-Service object")
 			return
 		}
 	}
 
-	caller := h.Caller
-	if caller == "" {
-		caller = "unknown"
-	}
-
-	fmt.Printf("[Caller: %s] [ServiceHandler] Service Deleted: %s/%s\n",
-		caller,
-		svc.Namespace,
-		svc.Name)
+	emit(h.Sink, h.Caller, "ServiceHandler", Event{
+		Action:    "Deleted",
+		GVK:       serviceGVK,
+		Namespace: svc.Namespace,
+		Name:      svc.Name,
+	})
 }
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Event is a structured description of an informer event emitted by the handlers
+type Event struct {
+	Time        time.Time               `json:"time"`
+	Caller      string                  `json:"caller"`
+	Handler     string                  `json:"handler"`
+	Action      string                  `json:"action"`
+	GVK         schema.GroupVersionKind `json:"gvk"`
+	Namespace   string                  `json:"namespace,omitempty"`
+	Name        string                  `json:"name"`
+	InitialList bool                    `json:"initialList,omitempty"`
+	Replay      bool                    `json:"replay,omitempty"`
+	Summary     map[string]string       `json:"summary,omitempty"`
+	Details     []string                `json:"details,omitempty"`
+}
+
+// Sink receives the events emitted by the handlers
+type Sink interface {
+	Write(Event) error
+}
+
+// defaultSink is used by handlers constructed without a Sink
+var defaultSink Sink = NewTextSink(os.Stdout)
+
+// emit fills in the common fields and writes the event to sink, or to stdout when sink is nil
+func emit(sink Sink, caller, handler string, event Event) {
+	if sink == nil {
+		sink = defaultSink
+	}
+	if caller == "" {
+		caller = "unknown"
+	}
+	event.Caller = caller
+	event.Handler = handler
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if err := sink.Write(event); err != nil {
+		log.Printf("Error writing %s event for %s/%s: %v", event.Action, event.Namespace, event.Name, err)
+	}
+}
+
+// TextSink prints events in the human readable format of the examples
+type TextSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTextSink(w io.Writer) *TextSink {
+	return &TextSink{w: w}
+}
+
+func (s *TextSink) Write(event Event) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Caller: %s] [%s] %s %s", event.Caller, event.Handler, event.GVK.Kind, event.Action)
+	if event.Replay {
+		b.WriteString(" (replay)")
+	} else if event.InitialList {
+		b.WriteString(" (initial list)")
+	}
+	fmt.Fprintf(&b, ": %s/%s", event.Namespace, event.Name)
+
+	if len(event.Summary) > 0 {
+		keys := make([]string, 0, len(event.Summary))
+		for key := range event.Summary {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, key+": "+event.Summary[key])
+		}
+		fmt.Fprintf(&b, " (%s)", strings.Join(pairs, ", "))
+	}
+	b.WriteString("\n")
+	for _, line := range event.Details {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+// JSONSink writes one JSON object per line, e.g. to a file
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+func (s *JSONSink) Write(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(event)
+}
+
+// WebhookSink posts each event as JSON to a URL
+type WebhookSink struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *WebhookSink) Write(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// ChannelSink sends events to a Go channel, for consumers in the same process
+type ChannelSink struct {
+	C chan<- Event
+}
+
+func (s ChannelSink) Write(event Event) error {
+	s.C <- event
+	return nil
+}
+
+// AsyncSink decouples the handlers from a slow sink. Events are buffered and written by a
+// single goroutine; when the buffer is full they are dropped and counted instead of
+// blocking the informer's event delivery.
+type AsyncSink struct {
+	next    Sink
+	events  chan Event
+	dropped atomic.Int64
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncSink starts writing buffered events to next
+func NewAsyncSink(next Sink, buffer int) *AsyncSink {
+	s := &AsyncSink{next: next, events: make(chan Event, buffer), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		for event := range s.events {
+			if err := s.next.Write(event); err != nil {
+				log.Printf("Error writing %s event for %s/%s: %v", event.Action, event.Namespace, event.Name, err)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *AsyncSink) Write(event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return nil
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (s *AsyncSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close flushes the buffered events. Later writes are dropped.
+func (s *AsyncSink) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// UnstructuredHandler implements ResourceEventHandler for any resource served by a dynamic
+// informer, such as custom resources
+type UnstructuredHandler struct {
+	Caller string
+	// Sink receives the events; stdout when nil
+	Sink Sink
+	// StatusPaths are dotted field paths, e.g. status.phase, reported with every event
+	StatusPaths []string
+}
+
+// OnAdd is called when an object is added
+func (h *UnstructuredHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		log.Println("Error: OnAdd received non-Unstructured object")
+		return
+	}
+
+	event := h.event("Added", u)
+	event.InitialList = isInInitialList
+	emit(h.Sink, h.Caller, "UnstructuredHandler", event)
+}
+
+// OnUpdate is called when an object is modified
+func (h *UnstructuredHandler) OnUpdate(oldObj, newObj interface{}) {
+	oldU, ok := oldObj.(*unstructured.Unstructured)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Unstructured object for old object")
+		return
+	}
+
+	newU, ok := newObj.(*unstructured.Unstructured)
+	if !ok {
+		log.Println("Error: OnUpdate received non-Unstructured object for new object")
+		return
+	}
+
+	if newU.GetResourceVersion() == oldU.GetResourceVersion() {
+		// No actual change, skip
+		return
+	}
+
+	action := "Updated"
+	if oldU.GetGeneration() != newU.GetGeneration() {
+		action = "Spec Changed"
+	}
+	emit(h.Sink, h.Caller, "UnstructuredHandler", h.event(action, newU))
+}
+
+// OnDelete is called when an object is deleted
+func (h *UnstructuredHandler) OnDelete(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		// When a delete is observed, the object might be a DeletedFinalStateUnknown
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			log.Println("Error: OnDelete received non-Unstructured and non-DeletedFinalStateUnknown object")
+			return
+		}
+
+		u, ok = tombstone.Obj.(*unstructured.Unstructured)
+		if !ok {
+			log.Println("Error: DeletedFinalStateUnknown contained non-Unstructured object")
+			return
+		}
+	}
+
+	emit(h.Sink, h.Caller, "UnstructuredHandler", h.event("Deleted", u))
+}
+
+func (h *UnstructuredHandler) event(action string, u *unstructured.Unstructured) Event {
+	summary := map[string]string{"generation": strconv.FormatInt(u.GetGeneration(), 10)}
+	for _, path := range h.StatusPaths {
+		value, found, err := unstructured.NestedFieldNoCopy(u.Object, strings.Split(path, ".")...)
+		switch {
+		case err != nil:
+			summary[path] = "<" + err.Error() + ">"
+		case !found:
+			summary[path] = "<none>"
+		default:
+			summary[path] = fmt.Sprint(value)
+		}
+	}
+
+	return Event{
+		Action:    action,
+		GVK:       u.GroupVersionKind(),
+		Namespace: u.GetNamespace(),
+		Name:      u.GetName(),
+		Summary:   summary,
+	}
+}
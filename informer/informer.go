@@ -3,26 +3,44 @@
 // and react to those changes with event handlers.
 
 // go run informer.go --type=all --namespace=default
+// go run informer.go --type=factory --label-selector=app=web --field-selector=status.phase=Running
+// go run informer.go --type=dynamic --gvr=cert-manager.io/v1/certificates --status-path=status.notAfter
 
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"kgent-api/informer/checkpoint"
 	"kgent-api/informer/config"
+	"kgent-api/informer/controller"
 	"kgent-api/informer/handlers"
+	"kgent-api/pkg/metrics"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -30,26 +48,30 @@ import (
 
 // basicInformer demonstrates the simplest informer setup with a single handler
 // It uses the low-level cache.NewInformerWithOptions API
-func basicInformer(lw *cache.ListWatch, stopCh <-chan struct{}) {
+func basicInformer(lw *cache.ListWatch, sink handlers.Sink, stopCh <-chan struct{}) {
 	fmt.Println("Running basic informer example...")
 
+	// Wrap the handler for metrics and checkpoints, as addHandlers does for shared informers
+	pods := v1.SchemeGroupVersion.WithResource("pods")
+	handler := wrapHandler(pods, "Pod", resume(pods), &handlers.PodHandler{Caller: "basicInformer", Sink: sink}, true)
+
 	// Configure the informer with our ListWatch and handler
 	options := cache.InformerOptions{
-		ListerWatcher: lw,                                            // Tells the informer what resources to watch
-		ObjectType:    &v1.Pod{},                                     // Type of object to watch (Pod)
-		ResyncPeriod:  time.Minute * 30,                              // How often to resync (full relist)
-		Handler:       &handlers.PodHandler{Caller: "basicInformer"}, // Event handler
+		ListerWatcher: lw,               // Tells the informer what resources to watch
+		ObjectType:    &v1.Pod{},        // Type of object to watch (Pod)
+		ResyncPeriod:  time.Minute * 30, // How often to resync (full relist)
+		Handler:       handler,          // Event handler
 	}
 
 	// Create a new informer with these options
 	_, informer := cache.NewInformerWithOptions(options)
 
 	// Run the informer
-	go informer.Run(stopCh)
+	runInformer(informer.Run, stopCh)
 
 	// Wait for the informer to sync its cache
-	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
-		log.Fatal("Timed out waiting for caches to sync in basicInformer")
+	if err := waitForCacheSync("basicInformer", stopCh, map[string]cache.InformerSynced{"pods": informer.HasSynced}); err != nil {
+		log.Fatal(err)
 	}
 
 	fmt.Println("Basic informer cache has synced and is running\n")
@@ -58,7 +80,7 @@ func basicInformer(lw *cache.ListWatch, stopCh <-chan struct{}) {
 // sharedInformer demonstrates how to use a SharedInformer with multiple handlers
 // SharedInformers allow multiple controllers to watch the same resources
 // without duplicating API traffic or caching
-func sharedInformer(lw *cache.ListWatch, stopCh <-chan struct{}) {
+func sharedInformer(lw *cache.ListWatch, sink handlers.Sink, stopCh <-chan struct{}) {
 	fmt.Println("Running shared informer example...")
 
 	// Create a shared informer that watches Pods
@@ -69,15 +91,17 @@ func sharedInformer(lw *cache.ListWatch, stopCh <-chan struct{}) {
 	)
 
 	// Add multiple event handlers to the same informer
-	sharedInformer.AddEventHandler(&handlers.PodHandler{Caller: "sharedInformer"})
-	sharedInformer.AddEventHandler(&handlers.NewPodHandler{Caller: "sharedInformer"})
+	addHandlers(sharedInformer, v1.SchemeGroupVersion.WithResource("pods"), "Pod",
+		&handlers.PodHandler{Caller: "sharedInformer", Sink: sink},
+		&handlers.NewPodHandler{Caller: "sharedInformer", Sink: sink},
+	)
 
 	// Run the informer
-	go sharedInformer.Run(stopCh)
+	runInformer(sharedInformer.Run, stopCh)
 
 	// Wait for the informer to sync its cache
-	if !cache.WaitForCacheSync(stopCh, sharedInformer.HasSynced) {
-		log.Fatal("Timed out waiting for caches to sync in sharedInformer")
+	if err := waitForCacheSync("sharedInformer", stopCh, map[string]cache.InformerSynced{"pods": sharedInformer.HasSynced}); err != nil {
+		log.Fatal(err)
 	}
 
 	fmt.Println("Shared informer cache has synced and is running\n")
@@ -86,34 +110,92 @@ func sharedInformer(lw *cache.ListWatch, stopCh <-chan struct{}) {
 // sharedInformerFactory demonstrates how to use a SharedInformerFactory
 // The factory creates informers for multiple resource types
 // and manages their lifecycle
-func sharedInformerFactory(client *kubernetes.Clientset, namespace string, stopCh <-chan struct{}) {
+func sharedInformerFactory(client *kubernetes.Clientset, namespace string, tweak func(*metav1.ListOptions), sink handlers.Sink, alerts *handlers.PodAlertHandler, watchConfig, phaseChanges bool, stuckAfter time.Duration, stopCh <-chan struct{}) {
 	fmt.Println("Running shared informer factory example...")
 
 	// Create a shared informer factory for the specified namespace
 	factory := informers.NewSharedInformerFactoryWithOptions(
-		client,                             // Kubernetes client
-		time.Minute*10,                     // Resync period
-		informers.WithNamespace(namespace), // Only watch resources in this namespace
+		client,                                // Kubernetes client
+		time.Minute*10,                        // Resync period
+		informers.WithNamespace(namespace),    // Only watch resources in this namespace
+		informers.WithTweakListOptions(tweak), // Apply the label and field selectors
 	)
 
 	// Get informers for specific resource types from the factory
 	podInformer := factory.Core().V1().Pods()
-	podInformer.Informer().AddEventHandler(&handlers.PodHandler{Caller: "sharedInformerFactory"})
-	podInformer.Informer().AddEventHandler(&handlers.NewPodHandler{Caller: "sharedInformerFactory"})
+	podHandlers := []cache.ResourceEventHandler{
+		&handlers.PodHandler{Caller: "sharedInformerFactory", Sink: sink},
+		&handlers.NewPodHandler{Caller: "sharedInformerFactory", Sink: sink},
+		alerts,
+	}
+	// Predicates narrow an existing handler instead of writing a new one, here to phase
+	// transitions of pods not opted out with the kgent.io/ignore annotation
+	if phaseChanges {
+		podHandlers = append(podHandlers, handlers.Filtered(
+			handlers.And(handlers.Not(handlers.HasAnnotation("kgent.io/ignore")), handlers.PhaseChanged()),
+			&handlers.PodHandler{Caller: "sharedInformerFactory (phase changes)", Sink: sink},
+		))
+	}
+	addHandlers(podInformer.Informer(), v1.SchemeGroupVersion.WithResource("pods"), "Pod", podHandlers...)
 
 	svcInformer := factory.Core().V1().Services()
-	svcInformer.Informer().AddEventHandler(&handlers.ServiceHandler{Caller: "sharedInformerFactory"})
+	addHandlers(svcInformer.Informer(), v1.SchemeGroupVersion.WithResource("services"), "Service", &handlers.ServiceHandler{Caller: "sharedInformerFactory", Sink: sink})
 
-	// Start all informers in the factory
-	factory.Start(stopCh)
+	// Deployments, nodes and the other workloads come from a second factory, since the pod
+	// selectors don't apply to them. Nodes and namespaces are cluster-scoped, so the namespace
+	// option is ignored for them
+	workloadFactory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		time.Minute*10,
+		informers.WithNamespace(namespace),
+	)
+
+	deployInformer := workloadFactory.Apps().V1().Deployments()
+	addHandlers(deployInformer.Informer(), appsv1.SchemeGroupVersion.WithResource("deployments"), "Deployment", &handlers.DeploymentHandler{Caller: "sharedInformerFactory", Sink: sink})
+
+	nodeInformer := workloadFactory.Core().V1().Nodes()
+	addHandlers(nodeInformer.Informer(), v1.SchemeGroupVersion.WithResource("nodes"), "Node", &handlers.NodeHandler{Caller: "sharedInformerFactory", Sink: sink})
+
+	// Stuck namespaces and long Pending claims are caught on resyncs, so they show up
+	// within one resync period after stuckAfter
+	nsInformer := workloadFactory.Core().V1().Namespaces()
+	addHandlers(nsInformer.Informer(), v1.SchemeGroupVersion.WithResource("namespaces"), "Namespace", &handlers.NamespaceHandler{Caller: "sharedInformerFactory", Sink: sink, StuckAfter: stuckAfter})
+
+	jobInformer := workloadFactory.Batch().V1().Jobs()
+	addHandlers(jobInformer.Informer(), batchv1.SchemeGroupVersion.WithResource("jobs"), "Job", &handlers.JobHandler{Caller: "sharedInformerFactory", Sink: sink})
+
+	pvcInformer := workloadFactory.Core().V1().PersistentVolumeClaims()
+	addHandlers(pvcInformer.Informer(), v1.SchemeGroupVersion.WithResource("persistentvolumeclaims"), "PersistentVolumeClaim", &handlers.PVCHandler{Caller: "sharedInformerFactory", Sink: sink, PendingAfter: stuckAfter})
 
 	// Wait for caches to sync
-	cachesSynced := []cache.InformerSynced{
-		podInformer.Informer().HasSynced,
-		svcInformer.Informer().HasSynced,
+	cachesSynced := map[string]cache.InformerSynced{
+		"pods":                   podInformer.Informer().HasSynced,
+		"services":               svcInformer.Informer().HasSynced,
+		"deployments":            deployInformer.Informer().HasSynced,
+		"nodes":                  nodeInformer.Informer().HasSynced,
+		"namespaces":             nsInformer.Informer().HasSynced,
+		"jobs":                   jobInformer.Informer().HasSynced,
+		"persistentvolumeclaims": pvcInformer.Informer().HasSynced,
 	}
-	if !cache.WaitForCacheSync(stopCh, cachesSynced...) {
-		log.Fatal("Timed out waiting for caches to sync in sharedInformerFactory")
+
+	// Optionally track config drift; only key names and value hashes are reported
+	if watchConfig {
+		cmInformer := workloadFactory.Core().V1().ConfigMaps()
+		addHandlers(cmInformer.Informer(), v1.SchemeGroupVersion.WithResource("configmaps"), "ConfigMap", &handlers.ConfigHandler{Caller: "sharedInformerFactory", Sink: sink})
+
+		secretInformer := workloadFactory.Core().V1().Secrets()
+		addHandlers(secretInformer.Informer(), v1.SchemeGroupVersion.WithResource("secrets"), "Secret", &handlers.SecretHandler{Caller: "sharedInformerFactory", Sink: sink})
+
+		cachesSynced["configmaps"] = cmInformer.Informer().HasSynced
+		cachesSynced["secrets"] = secretInformer.Informer().HasSynced
+	}
+
+	// Start all informers in the factories
+	startFactory(factory, stopCh)
+	startFactory(workloadFactory, stopCh)
+
+	if err := waitForCacheSync("sharedInformerFactory", stopCh, cachesSynced); err != nil {
+		log.Fatal(err)
 		return
 	}
 
@@ -122,7 +204,7 @@ func sharedInformerFactory(client *kubernetes.Clientset, namespace string, stopC
 
 // sharedInformerFactoryLister demonstrates how to use Listers with SharedInformerFactory
 // Listers provide a cached, indexed access to resources for better performance
-func sharedInformerFactoryLister(client *kubernetes.Clientset, namespace string, stopCh <-chan struct{}) {
+func sharedInformerFactoryLister(client *kubernetes.Clientset, namespace string, tweak func(*metav1.ListOptions), sink handlers.Sink, stopCh <-chan struct{}) {
 	fmt.Println("Running shared informer factory with lister example...")
 
 	// Create a shared informer factory
@@ -130,18 +212,19 @@ func sharedInformerFactoryLister(client *kubernetes.Clientset, namespace string,
 		client,
 		time.Minute*10,
 		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(tweak),
 	)
 
 	// Get pod informer from the factory
 	podInformer := factory.Core().V1().Pods()
-	podInformer.Informer().AddEventHandler(&handlers.PodHandler{Caller: "sharedInformerFactoryLister"})
+	addHandlers(podInformer.Informer(), v1.SchemeGroupVersion.WithResource("pods"), "Pod", &handlers.PodHandler{Caller: "sharedInformerFactoryLister", Sink: sink})
 
 	// Start informers
-	factory.Start(stopCh)
+	startFactory(factory, stopCh)
 
 	// Wait for caches to sync
-	if !cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced) {
-		log.Fatal("Timed out waiting for caches to sync in sharedInformerFactoryLister")
+	if err := waitForCacheSync("sharedInformerFactoryLister", stopCh, map[string]cache.InformerSynced{"pods": podInformer.Informer().HasSynced}); err != nil {
+		log.Fatal(err)
 		return
 	}
 
@@ -162,7 +245,7 @@ func sharedInformerFactoryLister(client *kubernetes.Clientset, namespace string,
 // sharedInformerFactoryForResource demonstrates using generic ForResource
 // method when informers for specific types are not available
 // create a informer using GVR
-func sharedInformerFactoryForResource(client *kubernetes.Clientset, namespace string, stopCh <-chan struct{}) {
+func sharedInformerFactoryForResource(client *kubernetes.Clientset, namespace string, tweak func(*metav1.ListOptions), sink handlers.Sink, stopCh <-chan struct{}) {
 	fmt.Println("Running shared informer factory for generic resource example...")
 
 	// Create a shared informer factory
@@ -170,6 +253,7 @@ func sharedInformerFactoryForResource(client *kubernetes.Clientset, namespace st
 		client,
 		time.Minute*10,
 		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(tweak),
 	)
 
 	// Define the resource to watch using GroupVersionResource
@@ -188,33 +272,49 @@ func sharedInformerFactoryForResource(client *kubernetes.Clientset, namespace st
 	}
 
 	// Add event handler with basic event functions
-	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	// Write a structured event for each key to the sink
+	emitGeneric := func(action, key string) {
+		ns, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return
+		}
+		sink.Write(handlers.Event{
+			Time:      time.Now(),
+			Caller:    "sharedInformerFactoryForResource",
+			Handler:   "Generic handler",
+			Action:    action,
+			GVK:       v1.SchemeGroupVersion.WithKind("Pod"),
+			Namespace: ns,
+			Name:      name,
+		})
+	}
+	addHandlers(informer.Informer(), gvr, "Pod", cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(obj)
 			if err == nil {
-				fmt.Printf("[Generic handler] Pod added: %s\n", key)
+				emitGeneric("Added", key)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(newObj)
 			if err == nil {
-				fmt.Printf("[Generic handler] Pod updated: %s\n", key)
+				emitGeneric("Updated", key)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			if err == nil {
-				fmt.Printf("[Generic handler] Pod deleted: %s\n", key)
+				emitGeneric("Deleted", key)
 			}
 		},
 	})
 
 	// Start the informers
-	factory.Start(stopCh)
+	startFactory(factory, stopCh)
 
 	// Wait for caches to sync
-	if !cache.WaitForCacheSync(stopCh, informer.Informer().HasSynced) {
-		log.Fatal("Timed out waiting for caches to sync in sharedInformerFactoryForResource")
+	if err := waitForCacheSync("sharedInformerFactoryForResource", stopCh, map[string]cache.InformerSynced{gvr.Resource: informer.Informer().HasSynced}); err != nil {
+		log.Fatal(err)
 		return
 	}
 
@@ -232,14 +332,328 @@ func sharedInformerFactoryForResource(client *kubernetes.Clientset, namespace st
 	fmt.Println("\n")
 }
 
+// dynamicInformer demonstrates watching any resource, such as a custom resource,
+// with a dynamic informer. Objects arrive as *unstructured.Unstructured,
+// so no generated clientset or types are needed
+func dynamicInformer(client dynamic.Interface, disco discovery.DiscoveryInterface, gvr schema.GroupVersionResource, namespace string, tweak func(*metav1.ListOptions), statusPaths []string, sink handlers.Sink, stopCh <-chan struct{}) {
+	fmt.Printf("Running dynamic informer example for %s...\n", gvr.String())
+
+	// The CRD may not be installed yet, so wait until discovery serves the resource
+	if !waitForResource(disco, gvr, stopCh) {
+		return
+	}
+
+	// Create a dynamic informer factory with the same namespace and selectors as the typed examples
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+		client,
+		time.Minute*10,
+		namespace,
+		tweak,
+	)
+
+	informer := factory.ForResource(gvr)
+	addHandlers(informer.Informer(), gvr, gvr.Resource, &handlers.UnstructuredHandler{
+		Caller:      "dynamicInformer",
+		Sink:        sink,
+		StatusPaths: statusPaths,
+	})
+
+	// Start the informers
+	startFactory(factory, stopCh)
+
+	// Wait for caches to sync
+	if err := waitForCacheSync("dynamicInformer", stopCh, map[string]cache.InformerSynced{gvr.Resource: informer.Informer().HasSynced}); err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	fmt.Print("Dynamic informer cache has synced and is running\n\n")
+}
+
+// waitForResource polls discovery until gvr is served, returning false if stopCh closes first
+func waitForResource(disco discovery.DiscoveryInterface, gvr schema.GroupVersionResource, stopCh <-chan struct{}) bool {
+	for {
+		resources, err := disco.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err == nil {
+			for _, resource := range resources.APIResources {
+				if resource.Name == gvr.Resource {
+					return true
+				}
+			}
+		}
+
+		fmt.Printf("Resource %s is not served yet, retrying discovery in 5s...\n", gvr.String())
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// parseGVR parses group/version/resource, or version/resource for the core group
+func parseGVR(arg string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(arg, "/")
+	switch {
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}, nil
+	case len(parts) == 3 && parts[1] != "" && parts[2] != "":
+		return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+	default:
+		return schema.GroupVersionResource{}, fmt.Errorf("expected group/version/resource, got %q", arg)
+	}
+}
+
+// newSink returns the event sink selected by the --output flag and a function releasing it
+func newSink(output, file, webhookURL string) (handlers.Sink, func(), error) {
+	switch output {
+	case "text":
+		return handlers.NewTextSink(os.Stdout), func() {}, nil
+	case "json":
+		if file == "" {
+			return handlers.NewJSONSink(os.Stdout), func() {}, nil
+		}
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		return handlers.NewJSONSink(f), func() { f.Close() }, nil
+	case "webhook":
+		if webhookURL == "" {
+			return nil, nil, fmt.Errorf("--webhook-url is required with --output=webhook")
+		}
+		return handlers.NewWebhookSink(webhookURL), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown output %q, expected text, json or webhook", output)
+	}
+}
+
+// podController demonstrates the workqueue pattern used by real controllers
+// Event handlers only enqueue keys, and workers reconcile them from the lister
+// so a failed reconcile is retried with backoff instead of being lost
+func podController(client *kubernetes.Clientset, namespace string, tweak func(*metav1.ListOptions), workers, maxRetries int, stopCh <-chan struct{}) {
+	fmt.Println("Running workqueue controller example...")
+
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		time.Minute*10,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(tweak),
+	)
+
+	// The reconciler fails until a pod has an IP, showing how errors are requeued
+	reconcile := func(key string, pod *v1.Pod) error {
+		if pod == nil {
+			fmt.Printf("[controller] Pod %s deleted\n", key)
+			return nil
+		}
+		if pod.Status.PodIP == "" {
+			return fmt.Errorf("pod %s has no IP yet", key)
+		}
+		fmt.Printf("[controller] Pod %s is %s at %s\n", key, pod.Status.Phase, pod.Status.PodIP)
+		return nil
+	}
+
+	ctrl := controller.New(factory.Core().V1().Pods(), reconcile, maxRetries)
+	startFactory(factory, stopCh)
+
+	// Run returns once the controller has drained its queue after stopCh closes
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		if err := ctrl.Run(workers, stopCh); err != nil {
+			log.Printf("Controller stopped: %v", err)
+		}
+	}()
+}
+
+// running tracks the goroutines of every started informer, so shutdown can wait for them
+var running sync.WaitGroup
+
+// syncTimeout bounds how long an example waits for its caches, set by --sync-timeout
+var syncTimeout = 60 * time.Second
+
+// runInformer runs a standalone informer until stopCh closes
+func runInformer(run func(stopCh <-chan struct{}), stopCh <-chan struct{}) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		run(stopCh)
+	}()
+}
+
+// startFactory starts a factory's informers and shuts them down once stopCh closes.
+// Shutdown blocks until every informer goroutine of the factory has exited.
+func startFactory(factory interface {
+	Start(stopCh <-chan struct{})
+	Shutdown()
+}, stopCh <-chan struct{}) {
+	factory.Start(stopCh)
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		<-stopCh
+		factory.Shutdown()
+	}()
+}
+
+// addHandlers registers each handler on the informer, timing every handler and counting
+// each event once for --metrics-addr and the summary line
+func addHandlers(informer cache.SharedInformer, gvr schema.GroupVersionResource, kind string, hs ...cache.ResourceEventHandler) {
+	resumeFrom := resume(gvr)
+	for i, h := range hs {
+		informer.AddEventHandler(wrapHandler(gvr, kind, resumeFrom, h, i == 0))
+	}
+}
+
+// Checkpointing, enabled by --checkpoint-file
+var (
+	checkpoints     *checkpoint.Store
+	checkpointWatch func(gvr schema.GroupVersionResource) checkpoint.WatchFunc
+	suppressReplays bool
+)
+
+// checkpointInterval is how often checkpoints are saved while running
+const checkpointInterval = 10 * time.Second
+
+// resume returns the usable checkpoint of gvr, or "" for a full resync
+func resume(gvr schema.GroupVersionResource) string {
+	if checkpoints == nil {
+		return ""
+	}
+	return checkpoints.Resume(gvr, checkpointWatch(gvr))
+}
+
+// wrapHandler recovers panics in h, instruments it and, with --checkpoint-file, tells
+// replayed objects apart
+func wrapHandler(gvr schema.GroupVersionResource, kind, resumeFrom string, h cache.ResourceEventHandler, countEvents bool) cache.ResourceEventHandler {
+	// A panicking handler must not stop delivery to the other handlers of the informer
+	h = &handlers.Instrumented{Kind: kind, Handler: handlers.Recovering(h, nil), CountEvents: countEvents}
+	if checkpoints == nil {
+		return h
+	}
+	return &checkpoint.Handler{
+		Store:      checkpoints,
+		Resource:   gvr,
+		Kind:       kind,
+		Checkpoint: resumeFrom,
+		Suppress:   suppressReplays,
+		Handler:    h,
+	}
+}
+
+// serveMetrics serves the event, handler and workqueue metrics at addr until stopCh closes
+func serveMetrics(addr string, stopCh <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error serving metrics on %s: %v", addr, err)
+		}
+	}()
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		<-stopCh
+		server.Close()
+	}()
+}
+
+// printSummary prints the rate of add, update and delete events every interval
+func printSummary(interval time.Duration, stopCh <-chan struct{}) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastAdds, lastUpdates, lastDeletes := handlers.EventTotals()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+			adds, updates, deletes := handlers.EventTotals()
+			seconds := interval.Seconds()
+			fmt.Printf("[summary] events/sec over the last %s: added=%.2f updated=%.2f deleted=%.2f\n", interval,
+				float64(adds-lastAdds)/seconds, float64(updates-lastUpdates)/seconds, float64(deletes-lastDeletes)/seconds)
+			lastAdds, lastUpdates, lastDeletes = adds, updates, deletes
+		}
+	}()
+}
+
+// waitForCacheSync waits until every cache has synced, giving up after syncTimeout.
+// A cache that never syncs usually means RBAC denies list or watch, so instead of
+// hanging the error names the caches that did not sync.
+func waitForCacheSync(example string, stopCh <-chan struct{}, caches map[string]cache.InformerSynced) error {
+	timeout := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stopCh:
+		case <-done:
+			return
+		case <-time.After(syncTimeout):
+		}
+		close(timeout)
+	}()
+
+	synced := make([]cache.InformerSynced, 0, len(caches))
+	for _, hasSynced := range caches {
+		synced = append(synced, hasSynced)
+	}
+	if cache.WaitForCacheSync(timeout, synced...) {
+		return nil
+	}
+
+	var pending []string
+	for name, hasSynced := range caches {
+		if !hasSynced() {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return fmt.Errorf("%s: caches for %s did not sync within %s, check that the credentials may list and watch them",
+		example, strings.Join(pending, ", "), syncTimeout)
+}
+
 func main() {
 	// Parse command line flags
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file (default $KUBECONFIG or ~/.kube/config)")
+	namespaceFlag := flag.String("namespace", "", "Namespace to watch resources from (default $WATCH_NAMESPACE or default)")
 	exampleType := flag.String("type", "all",
-		"Type of informer example to run: basic, shared, factory, lister, resource, all")
+		"Type of informer example to run: basic, shared, factory, lister, resource, controller, dynamic, all")
+	workers := flag.Int("workers", 2, "Number of worker goroutines for the controller example")
+	maxRetries := flag.Int("max-retries", 5, "Retries before the controller example drops a key")
+	output := flag.String("output", "text", "Where handler events go: text, json or webhook")
+	outputFile := flag.String("output-file", "", "File JSON lines are appended to with --output=json (default stdout)")
+	webhookURL := flag.String("webhook-url", "", "URL each event is posted to with --output=webhook")
+	gvrArg := flag.String("gvr", "", "Resource watched by the dynamic example as group/version/resource, e.g. cert-manager.io/v1/certificates")
+	statusPaths := flag.String("status-path", "", "Comma separated fields printed by the dynamic example, e.g. status.phase,status.readyReplicas")
+	alertRestarts := flag.Int("alert-restarts", 3, "Restart count from which CrashLoopBackOff pods raise an alert")
+	alertCooldown := flag.Duration("alert-cooldown", 10*time.Minute, "Minimum time between repeated alerts for the same container")
+	watchConfig := flag.Bool("watch-config", false, "Report ConfigMap and Secret key changes in the factory example")
+	stuckAfter := flag.Duration("stuck-after", 15*time.Minute, "How long a namespace may be Terminating or a claim Pending before the factory example reports it")
+	phaseChanges := flag.Bool("phase-changes", false, "Also report pod phase transitions in the factory example through a filtered handler")
+	syncTimeoutFlag := flag.Duration("sync-timeout", syncTimeout, "How long to wait for informer caches to sync before giving up")
+	labelSelector := flag.String("label-selector", "", "Only watch pods matching this label selector, e.g. app=web")
+	metricsAddr := flag.String("metrics-addr", "", "Address serving Prometheus metrics at /metrics, e.g. :9090 (disabled when empty)")
+	summaryInterval := flag.Duration("summary-interval", 0, "Print the event rate every interval, e.g. 30s (disabled when 0)")
+	checkpointFile := flag.String("checkpoint-file", "", "JSON file the last seen resourceVersion per resource is kept in across restarts")
+	replay := flag.String("replay", "tag", "What to do with objects the initial list replays unchanged since the checkpoint: tag or suppress")
+	fieldSelector := flag.String("field-selector", "", "Only watch pods matching this field selector, e.g. spec.nodeName=worker-1")
+
 	flag.Parse()
+	syncTimeout = *syncTimeoutFlag
 
 	// Initialize Kubernetes client
-	kubeConfig := config.NewK8sConfig()
+	kubeConfig := config.NewK8sConfig(*kubeconfig, *namespaceFlag)
 	clientset := kubeConfig.InitRestConfig().InitClientSet()
 	namespace := kubeConfig.Namespace
 
@@ -248,12 +662,66 @@ func main() {
 		log.Fatalf("Error initializing Kubernetes client: %v", err)
 	}
 
-	// Create a ListWatch for pods in the specified namespace
-	lw := cache.NewListWatchFromClient(
+	// Events are buffered so a slow sink never blocks informer event delivery
+	target, closeTarget, err := newSink(*output, *outputFile, *webhookURL)
+	if err != nil {
+		log.Fatalf("Error configuring output: %v", err)
+	}
+	buffered := handlers.NewAsyncSink(target, 1024)
+	var sink handlers.Sink = buffered
+
+	// With a checkpoint, objects the initial list replays are tagged or dropped
+	if *checkpointFile != "" {
+		if *replay != "tag" && *replay != "suppress" {
+			log.Fatalf("Invalid --replay %q, expected tag or suppress", *replay)
+		}
+		store, err := checkpoint.Open(*checkpointFile)
+		if err != nil {
+			log.Fatalf("Error loading checkpoints: %v", err)
+		}
+		checkpoints = store
+		suppressReplays = *replay == "suppress"
+		sink = store.Tag(sink)
+
+		dynamicClient := kubeConfig.InitDynamicClient()
+		checkpointWatch = func(gvr schema.GroupVersionResource) checkpoint.WatchFunc {
+			return func(options metav1.ListOptions) (watch.Interface, error) {
+				w, err := dynamicClient.Resource(gvr).Namespace(namespace).Watch(context.Background(), options)
+				// Cluster-scoped resources such as nodes have no namespaced path
+				if apierrors.IsNotFound(err) {
+					return dynamicClient.Resource(gvr).Watch(context.Background(), options)
+				}
+				return w, err
+			}
+		}
+	}
+
+	// OOMKills and crash loops raise alerts through the same sink, e.g. to a webhook
+	alerts := &handlers.PodAlertHandler{
+		Caller:           "sharedInformerFactory",
+		Sink:             sink,
+		RestartThreshold: int32(*alertRestarts),
+		Cooldown:         *alertCooldown,
+	}
+
+	// Fail fast on invalid selectors instead of on the first list call
+	if _, err := labels.Parse(*labelSelector); err != nil {
+		log.Fatalf("Invalid --label-selector: %v", err)
+	}
+	if _, err := fields.ParseSelector(*fieldSelector); err != nil {
+		log.Fatalf("Invalid --field-selector: %v", err)
+	}
+	tweak := func(options *metav1.ListOptions) {
+		options.LabelSelector = *labelSelector
+		options.FieldSelector = *fieldSelector
+	}
+
+	// Create a ListWatch for the selected pods in the specified namespace
+	lw := cache.NewFilteredListWatchFromClient(
 		clientset.CoreV1().RESTClient(),
 		"pods",
 		namespace,
-		fields.Everything(),
+		tweak,
 	)
 
 	// Setup signal handling for graceful shutdown
@@ -261,24 +729,57 @@ func main() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
+	// Queue metrics are only recorded for queues created after registering the provider
+	controller.RegisterMetrics()
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr, stopCh)
+	}
+	if *summaryInterval > 0 {
+		printSummary(*summaryInterval, stopCh)
+	}
+	if checkpoints != nil {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			checkpoints.Run(checkpointInterval, stopCh)
+		}()
+	}
+
 	// Run the requested informer example(s)
 	switch *exampleType {
 	case "basic":
-		basicInformer(lw, stopCh)
+		basicInformer(lw, sink, stopCh)
 	case "shared":
-		sharedInformer(lw, stopCh)
+		sharedInformer(lw, sink, stopCh)
 	case "factory":
-		sharedInformerFactory(clientset, namespace, stopCh)
+		sharedInformerFactory(clientset, namespace, tweak, sink, alerts, *watchConfig, *phaseChanges, *stuckAfter, stopCh)
 	case "lister":
-		sharedInformerFactoryLister(clientset, namespace, stopCh)
+		sharedInformerFactoryLister(clientset, namespace, tweak, sink, stopCh)
 	case "resource":
-		sharedInformerFactoryForResource(clientset, namespace, stopCh)
+		sharedInformerFactoryForResource(clientset, namespace, tweak, sink, stopCh)
+	case "dynamic":
+		gvr, err := parseGVR(*gvrArg)
+		if err != nil {
+			log.Fatalf("Invalid --gvr: %v", err)
+		}
+		var paths []string
+		if *statusPaths != "" {
+			paths = strings.Split(*statusPaths, ",")
+		}
+		// Run in the background since it may wait for the CRD to be installed
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			dynamicInformer(kubeConfig.InitDynamicClient(), clientset.Discovery(), gvr, namespace, tweak, paths, sink, stopCh)
+		}()
+	case "controller":
+		podController(clientset, namespace, tweak, *workers, *maxRetries, stopCh)
 	case "all":
-		basicInformer(lw, stopCh)
-		sharedInformer(lw, stopCh)
-		sharedInformerFactory(clientset, namespace, stopCh)
-		sharedInformerFactoryLister(clientset, namespace, stopCh)
-		sharedInformerFactoryForResource(clientset, namespace, stopCh)
+		basicInformer(lw, sink, stopCh)
+		sharedInformer(lw, sink, stopCh)
+		sharedInformerFactory(clientset, namespace, tweak, sink, alerts, *watchConfig, *phaseChanges, *stuckAfter, stopCh)
+		sharedInformerFactoryLister(clientset, namespace, tweak, sink, stopCh)
+		sharedInformerFactoryForResource(clientset, namespace, tweak, sink, stopCh)
 	default:
 		log.Fatalf("Unknown informer type: %s", *exampleType)
 	}
@@ -290,7 +791,18 @@ func main() {
 	fmt.Println("\nReceived termination signal. Shutting down informers...")
 	close(stopCh)
 
-	// Allow time for graceful shutdown
-	time.Sleep(2 * time.Second)
+	// Wait for every informer to stop; the controller drains its queue first
+	running.Wait()
+
+	if checkpoints != nil {
+		if err := checkpoints.Save(); err != nil {
+			log.Println(err)
+		}
+	}
+	buffered.Close()
+	closeTarget()
+	if dropped := buffered.Dropped(); dropped > 0 {
+		fmt.Printf("Dropped %d events because the output could not keep up.\n", dropped)
+	}
 	fmt.Println("All informers stopped.")
 }
@@ -0,0 +1,120 @@
+// Package apierror defines the body of every error response: a code clients can program
+// against, the message meant for people, details specific to the failure and the ID of the
+// request, to find it in the logs.
+package apierror
+
+import "net/http"
+
+// Code classifies a failure. Codes are part of the API and never change meaning.
+type Code string
+
+const (
+	// ResourceNotFound is returned for objects that don't exist
+	ResourceNotFound Code = "ResourceNotFound"
+	// MappingNotFound is returned for resource types the server doesn't have
+	MappingNotFound Code = "MappingNotFound"
+	// MappingAmbiguous is returned for resource names matching several resource types,
+	// details.candidates lists them
+	MappingAmbiguous Code = "MappingAmbiguous"
+	// SelectorInvalid is returned for label selectors that don't parse
+	SelectorInvalid Code = "SelectorInvalid"
+	// InvalidArgument is returned for missing or malformed parameters and bodies
+	InvalidArgument Code = "InvalidArgument"
+	// Invalid is returned for objects the API server rejects as invalid, and for limits
+	// below requests
+	Invalid Code = "Invalid"
+	// TemplateInvalid is returned for templates that don't render, details.line and
+	// details.column locate the error
+	TemplateInvalid Code = "TemplateInvalid"
+	// BuildFailed is returned for kustomizations that don't build
+	BuildFailed Code = "BuildFailed"
+	// PolicyViolation is returned for objects the policies reject, details.violations
+	// lists why
+	PolicyViolation Code = "PolicyViolation"
+	// LimitRangeViolation is returned for container resources the LimitRanges of the
+	// namespace don't allow, details.violations lists why
+	LimitRangeViolation Code = "LimitRangeViolation"
+	// AlreadyExists is returned when creating an object that exists
+	AlreadyExists Code = "AlreadyExists"
+	// Conflict is returned for writes that lost a race or don't apply to the current state
+	Conflict Code = "Conflict"
+	// PreconditionFailed is returned for writes whose resourceVersion or UID no longer
+	// match, details.resourceVersion and details.uid are the current ones
+	PreconditionFailed Code = "PreconditionFailed"
+	// ConfirmationRequired is returned for guarded operations without a valid confirm token
+	ConfirmationRequired Code = "ConfirmationRequired"
+	// Unauthorized is returned for requests without valid credentials
+	Unauthorized Code = "Unauthorized"
+	// Forbidden is returned for requests the server or the API server doesn't allow,
+	// including namespaces outside the namespace scope
+	Forbidden Code = "Forbidden"
+	// MethodNotAllowed is returned for verbs a resource doesn't support, details.verbs
+	// lists those it does
+	MethodNotAllowed Code = "MethodNotAllowed"
+	// ReadOnly is returned for writes while the server is read-only
+	ReadOnly Code = "ReadOnly"
+	// PayloadTooLarge is returned for bodies and archives over the size limits
+	PayloadTooLarge Code = "PayloadTooLarge"
+	// Timeout is returned for requests that didn't finish in time
+	Timeout Code = "Timeout"
+	// Unavailable is returned when a feature is disabled or a dependency is down
+	Unavailable Code = "Unavailable"
+	// Internal is returned for everything else
+	Internal Code = "Internal"
+)
+
+// APIError is the body of error responses
+type APIError struct {
+	Code      Code           `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"requestId,omitempty"`
+	// Error repeats Message for clients of the former {"error": "..."} bodies.
+	//
+	// Deprecated: read Message, Error will be removed in the next release.
+	Error string `json:"error"`
+}
+
+// New creates an APIError
+func New(code Code, message string) *APIError {
+	return &APIError{Code: code, Message: message, Error: message}
+}
+
+// With adds a detail to the error and returns it
+func (e *APIError) With(key string, value any) *APIError {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// CodeForStatus is the code of failures only known by their HTTP status
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return ResourceNotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusPreconditionFailed:
+		return PreconditionFailed
+	case http.StatusRequestEntityTooLarge:
+		return PayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return Invalid
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return Timeout
+	default:
+		return Internal
+	}
+}
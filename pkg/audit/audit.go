@@ -0,0 +1,38 @@
+// Package audit records security-relevant operations performed through kgent-api.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Entry is a single audited operation
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	Caller    string            `json:"caller"`
+	Group     string            `json:"group,omitempty"`
+	Resource  string            `json:"resource,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	DryRun    bool              `json:"dryRun,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Details   map[string]string `json:"details,omitempty"`
+	// BodyDigest is the Digest of the request body that carried the operation
+	BodyDigest string `json:"bodyDigest,omitempty"`
+}
+
+// Log writes an entry to the process log as a single JSON line prefixed with [audit]
+func Log(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[audit] failed to encode entry for %s: %v", entry.Action, err)
+		return
+	}
+	log.Printf("[audit] %s", data)
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+)
+
+var indexedObjects = metrics.NewGauge("kgent_audit_indexed_objects",
+	"Objects with recent audit entries kept in memory.")
+
+// Key identifies the object entries are about. Namespace is empty for cluster-scoped objects.
+type Key struct {
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// Store logs entries like Log and keeps the recent ones in memory, indexed by the object
+// they are about, so what was done to an object can be looked up. It keeps at most
+// maxPerObject entries for each of at most maxObjects objects, none older than retention.
+type Store struct {
+	maxPerObject int
+	maxObjects   int
+	retention    time.Duration
+
+	mu      sync.Mutex
+	objects map[Key][]Entry
+}
+
+// NewStore creates a store with the given bounds
+func NewStore(maxPerObject, maxObjects int, retention time.Duration) *Store {
+	return &Store{
+		maxPerObject: max(maxPerObject, 1),
+		maxObjects:   max(maxObjects, 1),
+		retention:    retention,
+		objects:      map[Key][]Entry{},
+	}
+}
+
+// Digest returns the SHA-256 of a request body as recorded in Entry.BodyDigest, or empty
+// for an empty body
+func Digest(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Log writes the entry to the process log and, when it names an object, indexes it. A nil
+// store only logs.
+func (s *Store) Log(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	Log(entry)
+	if s == nil || entry.Resource == "" || entry.Name == "" {
+		return
+	}
+
+	key := Key{Group: entry.Group, Resource: entry.Resource, Namespace: entry.Namespace, Name: entry.Name}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.trim(append(s.objects[key], entry), entry.Time)
+	s.objects[key] = entries
+	if len(s.objects) > s.maxObjects {
+		s.evict(entry.Time)
+	}
+	indexedObjects.Set(float64(len(s.objects)))
+}
+
+// Query returns up to limit entries about the object, newest first. Zero limit returns all
+// of them.
+func (s *Store) Query(key Key, limit int) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.trim(s.objects[key], time.Now())
+	result := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		result = append(result, entries[i])
+	}
+	return result
+}
+
+// trim drops the entries past the retention or the per-object limit, oldest first
+func (s *Store) trim(entries []Entry, now time.Time) []Entry {
+	drop := max(len(entries)-s.maxPerObject, 0)
+	if s.retention > 0 {
+		cutoff := now.Add(-s.retention)
+		for drop < len(entries) && entries[drop].Time.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return entries
+	}
+	return append(entries[:0:0], entries[drop:]...)
+}
+
+// evict forgets the objects whose entries have all expired and, while there are still too
+// many, those whose last entry is the oldest. The caller holds mu.
+func (s *Store) evict(now time.Time) {
+	type object struct {
+		key  Key
+		last time.Time
+	}
+	var remaining []object
+	for key, entries := range s.objects {
+		if entries = s.trim(entries, now); len(entries) == 0 {
+			delete(s.objects, key)
+			continue
+		}
+		remaining = append(remaining, object{key: key, last: entries[len(entries)-1].Time})
+	}
+	if excess := len(remaining) - s.maxObjects; excess > 0 {
+		sort.Slice(remaining, func(i, j int) bool { return remaining[i].last.Before(remaining[j].last) })
+		for _, o := range remaining[:excess] {
+			delete(s.objects, o.key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package cachestats keeps a registry of started informers so their sync state and cache
+// contents can be inspected, which the informer factories do not expose themselves.
+package cachestats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Stats describes one registered informer
+type Stats struct {
+	Group                   string     `json:"group,omitempty"`
+	Version                 string     `json:"version"`
+	Resource                string     `json:"resource"`
+	HasSynced               bool       `json:"hasSynced"`
+	Objects                 int        `json:"objects"`
+	LastSyncResourceVersion string     `json:"lastSyncResourceVersion"`
+	LastEvent               *time.Time `json:"lastEvent,omitempty"`
+}
+
+type entry struct {
+	gvr       schema.GroupVersionResource
+	informer  cache.SharedIndexInformer
+	lastEvent atomic.Int64
+}
+
+func (e *entry) touch() {
+	e.lastEvent.Store(time.Now().UnixNano())
+}
+
+// Registry tracks informers by resource
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[schema.GroupVersionResource]*entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: map[schema.GroupVersionResource]*entry{}}
+}
+
+// Register records the informer and tracks when it last delivered an event
+func (r *Registry) Register(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[gvr]; ok {
+		return nil
+	}
+	e := &entry{gvr: gvr, informer: informer}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { e.touch() },
+		UpdateFunc: func(interface{}, interface{}) { e.touch() },
+		DeleteFunc: func(interface{}) { e.touch() },
+	})
+	if err != nil {
+		return err
+	}
+	r.entries[gvr] = e
+	return nil
+}
+
+// Stats returns the state of every registered informer ordered by resource
+func (r *Registry) Stats() []Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]Stats, 0, len(r.entries))
+	for _, e := range r.entries {
+		s := Stats{
+			Group:                   e.gvr.Group,
+			Version:                 e.gvr.Version,
+			Resource:                e.gvr.Resource,
+			HasSynced:               e.informer.HasSynced(),
+			Objects:                 len(e.informer.GetStore().ListKeys()),
+			LastSyncResourceVersion: e.informer.LastSyncResourceVersion(),
+		}
+		if nanos := e.lastEvent.Load(); nanos > 0 {
+			t := time.Unix(0, nanos)
+			s.LastEvent = &t
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Resource != stats[j].Resource {
+			return stats[i].Resource < stats[j].Resource
+		}
+		return stats[i].Group < stats[j].Group
+	})
+	return stats
+}
+
+// Keys returns the sorted cache keys of the informer for resource, limited to ns when set.
+// The resource may be given as a plain name or as resource.group.
+func (r *Registry) Keys(resource, ns string) ([]string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for gvr, e := range r.entries {
+		if resource != gvr.Resource && resource != gvr.GroupResource().String() {
+			continue
+		}
+
+		keys := []string{}
+		for _, key := range e.informer.GetStore().ListKeys() {
+			if ns == "" || strings.HasPrefix(key, ns+"/") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys, true
+	}
+	return nil, false
+}
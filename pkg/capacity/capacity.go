@@ -0,0 +1,180 @@
+// Package capacity computes node allocatable versus scheduled pod requests without metrics-server.
+package capacity
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Resources is a cpu/memory pair normalized to millicores and binary bytes
+type Resources struct {
+	CPU    resource.Quantity `json:"cpu"`
+	Memory resource.Quantity `json:"memory"`
+}
+
+// NewResources returns zero cpu and memory in their normalized formats
+func NewResources() Resources {
+	return Resources{
+		CPU:    *resource.NewMilliQuantity(0, resource.DecimalSI),
+		Memory: *resource.NewQuantity(0, resource.BinarySI),
+	}
+}
+
+func (r *Resources) add(list v1.ResourceList) {
+	if q, ok := list[v1.ResourceCPU]; ok {
+		r.CPU.Add(q)
+	}
+	if q, ok := list[v1.ResourceMemory]; ok {
+		r.Memory.Add(q)
+	}
+}
+
+// Utilization is the percentage of allocatable consumed by requests or limits
+type Utilization struct {
+	CPU    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+}
+
+// NodeCapacity summarizes one node's allocatable resources and what is scheduled on it
+type NodeCapacity struct {
+	Name           string      `json:"name"`
+	Allocatable    Resources   `json:"allocatable"`
+	AllocatablePod int64       `json:"allocatablePods"`
+	Requests       Resources   `json:"requests"`
+	Limits         Resources   `json:"limits"`
+	PodCount       int         `json:"podCount"`
+	RequestPercent Utilization `json:"requestPercent"`
+	LimitPercent   Utilization `json:"limitPercent"`
+	OverCommitted  bool        `json:"overCommitted"`
+}
+
+// Free returns allocatable minus requested resources
+func (n *NodeCapacity) Free() Resources {
+	free := Resources{CPU: n.Allocatable.CPU.DeepCopy(), Memory: n.Allocatable.Memory.DeepCopy()}
+	free.CPU.Sub(n.Requests.CPU)
+	free.Memory.Sub(n.Requests.Memory)
+	return free
+}
+
+// Report is the per-node and cluster-wide capacity summary
+type Report struct {
+	Nodes     []NodeCapacity `json:"nodes"`
+	Cluster   NodeCapacity   `json:"cluster"`
+	Threshold float64        `json:"threshold"`
+}
+
+// PodRequestsAndLimits returns the effective requests and limits of a pod the way the scheduler
+// computes them: the larger of the summed app containers and any single init container, plus overhead
+func PodRequestsAndLimits(pod *v1.Pod) (requests, limits Resources) {
+	requests, limits = NewResources(), NewResources()
+	for _, c := range pod.Spec.Containers {
+		requests.add(c.Resources.Requests)
+		limits.add(c.Resources.Limits)
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		maxQuantity(&requests.CPU, c.Resources.Requests, v1.ResourceCPU)
+		maxQuantity(&requests.Memory, c.Resources.Requests, v1.ResourceMemory)
+		maxQuantity(&limits.CPU, c.Resources.Limits, v1.ResourceCPU)
+		maxQuantity(&limits.Memory, c.Resources.Limits, v1.ResourceMemory)
+	}
+
+	requests.add(pod.Spec.Overhead)
+	limits.add(pod.Spec.Overhead)
+	return requests, limits
+}
+
+func maxQuantity(current *resource.Quantity, list v1.ResourceList, name v1.ResourceName) {
+	if q, ok := list[name]; ok && q.Cmp(*current) > 0 {
+		current.Sub(*current)
+		current.Add(q)
+	}
+}
+
+// IsTerminal reports whether a pod no longer holds resources on its node
+func IsTerminal(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// Summarize attributes every non-terminal pod, DaemonSet pods included, to the node it is
+// scheduled on and flags nodes whose cpu or memory requests exceed threshold percent
+func Summarize(nodes []*v1.Node, pods []*v1.Pod, threshold float64) Report {
+	byNode := map[string]*NodeCapacity{}
+	for _, node := range nodes {
+		nc := &NodeCapacity{
+			Name:        node.Name,
+			Allocatable: NewResources(),
+			Requests:    NewResources(),
+			Limits:      NewResources(),
+		}
+		nc.Allocatable.add(node.Status.Allocatable)
+		if q, ok := node.Status.Allocatable[v1.ResourcePods]; ok {
+			nc.AllocatablePod = q.Value()
+		}
+		byNode[node.Name] = nc
+	}
+
+	for _, pod := range pods {
+		nc, ok := byNode[pod.Spec.NodeName]
+		if !ok || IsTerminal(pod) {
+			continue
+		}
+		requests, limits := PodRequestsAndLimits(pod)
+		nc.Requests.CPU.Add(requests.CPU)
+		nc.Requests.Memory.Add(requests.Memory)
+		nc.Limits.CPU.Add(limits.CPU)
+		nc.Limits.Memory.Add(limits.Memory)
+		nc.PodCount++
+	}
+
+	report := Report{
+		Nodes:     []NodeCapacity{},
+		Threshold: threshold,
+		Cluster: NodeCapacity{
+			Name:        "cluster",
+			Allocatable: NewResources(),
+			Requests:    NewResources(),
+			Limits:      NewResources(),
+		},
+	}
+	for _, nc := range byNode {
+		finalize(nc, threshold)
+		report.Nodes = append(report.Nodes, *nc)
+
+		report.Cluster.Allocatable.CPU.Add(nc.Allocatable.CPU)
+		report.Cluster.Allocatable.Memory.Add(nc.Allocatable.Memory)
+		report.Cluster.AllocatablePod += nc.AllocatablePod
+		report.Cluster.Requests.CPU.Add(nc.Requests.CPU)
+		report.Cluster.Requests.Memory.Add(nc.Requests.Memory)
+		report.Cluster.Limits.CPU.Add(nc.Limits.CPU)
+		report.Cluster.Limits.Memory.Add(nc.Limits.Memory)
+		report.Cluster.PodCount += nc.PodCount
+	}
+	finalize(&report.Cluster, threshold)
+
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		return report.Nodes[i].Name < report.Nodes[j].Name
+	})
+	return report
+}
+
+func finalize(nc *NodeCapacity, threshold float64) {
+	nc.RequestPercent = Utilization{
+		CPU:    percent(nc.Requests.CPU, nc.Allocatable.CPU),
+		Memory: percent(nc.Requests.Memory, nc.Allocatable.Memory),
+	}
+	nc.LimitPercent = Utilization{
+		CPU:    percent(nc.Limits.CPU, nc.Allocatable.CPU),
+		Memory: percent(nc.Limits.Memory, nc.Allocatable.Memory),
+	}
+	nc.OverCommitted = nc.RequestPercent.CPU > threshold || nc.RequestPercent.Memory > threshold
+}
+
+func percent(used, total resource.Quantity) float64 {
+	if total.IsZero() {
+		return 0
+	}
+	return float64(used.MilliValue()) / float64(total.MilliValue()) * 100
+}
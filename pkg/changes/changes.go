@@ -0,0 +1,235 @@
+// Package changes records compact add/update/delete history for informer-backed resources
+// in a bounded in-memory ring buffer, with live subscriptions for tailing.
+package changes
+
+import (
+	"sync"
+	"time"
+
+	"kgent-api/pkg/objdiff"
+	"kgent-api/pkg/recovery"
+	"kgent-api/pkg/stream"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Action is the kind of change observed
+type Action string
+
+const (
+	ActionAdded   Action = "added"
+	ActionUpdated Action = "updated"
+	ActionDeleted Action = "deleted"
+)
+
+// DefaultExcluded are high-churn resources that would crowd everything else out of the buffer
+var DefaultExcluded = []string{"events", "leases", "endpointslices", "endpoints"}
+
+// Record is one observed change. Changes are only set for updates.
+type Record struct {
+	Time            time.Time        `json:"time"`
+	Group           string           `json:"group,omitempty"`
+	Version         string           `json:"version"`
+	Resource        string           `json:"resource"`
+	Namespace       string           `json:"namespace,omitempty"`
+	Name            string           `json:"name"`
+	Action          Action           `json:"action"`
+	ResourceVersion string           `json:"resourceVersion"`
+	Changes         []objdiff.Change `json:"changes,omitempty"`
+	// ChangesTruncated is set when the update changed more fields than Changes holds
+	ChangesTruncated bool `json:"changesTruncated,omitempty"`
+}
+
+// Query filters records. Zero values match everything.
+type Query struct {
+	Namespace string
+	Resource  string
+	Since     time.Time
+}
+
+func (q Query) matches(record Record) bool {
+	return (q.Namespace == "" || record.Namespace == q.Namespace) &&
+		(q.Resource == "" || record.Resource == q.Resource) &&
+		!record.Time.Before(q.Since)
+}
+
+// DefaultSubscriptionBuffer is how many records a subscription holds for a slow reader
+const DefaultSubscriptionBuffer = 64
+
+// Recorder keeps the most recent changes up to a fixed capacity
+type Recorder struct {
+	mu                 sync.RWMutex
+	records            []Record
+	next               int
+	full               bool
+	subscriptionBuffer int
+	subscribers        map[*stream.Buffer[Record]]Query
+	diffOptions        objdiff.Options
+}
+
+// NewRecorder keeps up to capacity records. Each subscription buffers up to subscriptionBuffer
+// records, DefaultSubscriptionBuffer when zero, before dropping its oldest. Updates are
+// diffed with diffOptions, and the data of Secrets is always redacted.
+func NewRecorder(capacity int, subscriptionBuffer int, diffOptions objdiff.Options) *Recorder {
+	if subscriptionBuffer <= 0 {
+		subscriptionBuffer = DefaultSubscriptionBuffer
+	}
+	return &Recorder{
+		records:            make([]Record, capacity),
+		subscriptionBuffer: subscriptionBuffer,
+		subscribers:        map[*stream.Buffer[Record]]Query{},
+		diffOptions:        diffOptions,
+	}
+}
+
+// Watch registers handlers on the informer that record changes to gvr. Objects delivered
+// as part of the informer's initial list are not recorded, nor are updates that only change the status.
+func (r *Recorder) Watch(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) error {
+	_, err := informer.AddEventHandler(recovery.Handler("changes/"+gvr.Resource, cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				r.observe(gvr, ActionAdded, obj, nil, false)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			changes, truncated := r.diff(gvr, oldObj, newObj)
+			if len(changes) > 0 {
+				r.observe(gvr, ActionUpdated, newObj, changes, truncated)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			r.observe(gvr, ActionDeleted, obj, nil, false)
+		},
+	}, nil))
+	return err
+}
+
+func (r *Recorder) observe(gvr schema.GroupVersionResource, action Action, obj interface{}, changes []objdiff.Change, truncated bool) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	r.Add(Record{
+		Time:             time.Now(),
+		Group:            gvr.Group,
+		Version:          gvr.Version,
+		Resource:         gvr.Resource,
+		Namespace:        accessor.GetNamespace(),
+		Name:             accessor.GetName(),
+		Action:           action,
+		ResourceVersion:  accessor.GetResourceVersion(),
+		Changes:          changes,
+		ChangesTruncated: truncated,
+	})
+}
+
+// Add stores a record, overwriting the oldest once the buffer is full, and fans it out to subscribers
+func (r *Recorder) Add(record Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.records) > 0 {
+		r.records[r.next] = record
+		r.next = (r.next + 1) % len(r.records)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+
+	// Slow subscribers drop their oldest records rather than blocking informer delivery
+	for buffer, query := range r.subscribers {
+		if query.matches(record) {
+			buffer.Push(record)
+		}
+	}
+}
+
+// List returns the stored records matching the query, oldest first
+func (r *Recorder) List(query Query) []Record {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ordered := r.records[:r.next]
+	if r.full {
+		ordered = append(append([]Record{}, r.records[r.next:]...), r.records[:r.next]...)
+	}
+
+	records := []Record{}
+	for _, record := range ordered {
+		if query.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Subscribe returns a buffer receiving new records matching the query. The returned
+// function unsubscribes and must be called once the caller stops reading.
+func (r *Recorder) Subscribe(query Query) (*stream.Buffer[Record], func()) {
+	buffer := stream.NewBuffer[Record]("changes", r.subscriptionBuffer)
+
+	r.mu.Lock()
+	r.subscribers[buffer] = query
+	r.mu.Unlock()
+
+	return buffer, func() {
+		r.mu.Lock()
+		delete(r.subscribers, buffer)
+		r.mu.Unlock()
+		buffer.Close()
+	}
+}
+
+// Resume subscribes like Subscribe, also returning the stored records matching the query
+// that came after the record at resourceVersion, so a client that saw it misses nothing in
+// between. found is unset when no stored record is at resourceVersion, because it has been
+// overwritten or never existed; the client then has to re-list.
+func (r *Recorder) Resume(query Query, resourceVersion string) (missed []Record, found bool, buffer *stream.Buffer[Record], cancel func()) {
+	buffer = stream.NewBuffer[Record]("changes", r.subscriptionBuffer)
+
+	// Both happen under the lock so no record falls between the replay and the subscription
+	r.mu.Lock()
+	ordered := r.records[:r.next]
+	if r.full {
+		ordered = append(append([]Record{}, r.records[r.next:]...), r.records[:r.next]...)
+	}
+	missed = []Record{}
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if ordered[i].ResourceVersion == resourceVersion {
+			found = true
+			for _, record := range ordered[i+1:] {
+				if query.matches(record) {
+					missed = append(missed, record)
+				}
+			}
+			break
+		}
+	}
+	r.subscribers[buffer] = query
+	r.mu.Unlock()
+
+	return missed, found, buffer, func() {
+		r.mu.Lock()
+		delete(r.subscribers, buffer)
+		r.mu.Unlock()
+		buffer.Close()
+	}
+}
+
+// toUnstructured converts typed or unstructured objects to a plain map
+func toUnstructured(obj interface{}) map[string]interface{} {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return u.UnstructuredContent()
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil
+	}
+	return content
+}
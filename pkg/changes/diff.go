@@ -0,0 +1,32 @@
+package changes
+
+import (
+	"kgent-api/pkg/objdiff"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// secretPaths are redacted in the diffs of Secrets, which show which keys changed but not
+// their values
+var secretPaths = []objdiff.Path{{"data"}, {"stringData"}}
+
+// diff returns the changes between two versions of an object of gvr, and whether they were
+// capped. It is empty when only the status changed, which is not worth a record.
+func (r *Recorder) diff(gvr schema.GroupVersionResource, oldObj, newObj interface{}) ([]objdiff.Change, bool) {
+	opts := r.diffOptions
+	if gvr.Group == "" && gvr.Resource == "secrets" {
+		opts.Redact = append(opts.Redact[:len(opts.Redact):len(opts.Redact)], secretPaths...)
+	}
+	changes, truncated := objdiff.Diff(toUnstructured(oldObj), toUnstructured(newObj), opts)
+	for _, change := range changes {
+		if change.Path != "status" && !hasPrefix(change.Path, "status") {
+			return changes, truncated
+		}
+	}
+	return nil, false
+}
+
+// hasPrefix reports whether path is under field
+func hasPrefix(path, field string) bool {
+	return len(path) > len(field) && path[:len(field)] == field && (path[len(field)] == '.' || path[len(field)] == '[')
+}
@@ -0,0 +1,193 @@
+// Package clientcache keeps the clients built for a request identity, such as a caller's
+// bearer token or an impersonated user, so requests from the same caller reuse them instead
+// of creating a clientset per request.
+//
+// Evicted bundles are dropped from the cache, not closed: a request that fetched a bundle
+// before it expired, like a log stream, keeps using it and the garbage collector reclaims
+// it once the last request is done. client-go shares transports between configs with the
+// same TLS settings, so dropping a bundle leaves no connections behind.
+package clientcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+var (
+	hitsTotal = metrics.NewCounter("kgent_client_cache_hits_total",
+		"Requests served with a cached client bundle.")
+	missesTotal = metrics.NewCounter("kgent_client_cache_misses_total",
+		"Requests that had to build a client bundle.")
+	evictionsTotal = metrics.NewCounter("kgent_client_cache_evictions_total",
+		"Client bundles removed from the cache, by reason.", "reason")
+	entries = metrics.NewGauge("kgent_client_cache_entries",
+		"Client bundles currently cached.")
+)
+
+// Identity is who a request acts as. A Token replaces the server's own credentials,
+// User and Groups impersonate a user with them; both may be set.
+type Identity struct {
+	Token  string
+	User   string
+	Groups []string
+}
+
+// Hash identifies the identity without keeping its token in the cache's keys
+func (id Identity) Hash() string {
+	groups := append([]string(nil), id.Groups...)
+	sort.Strings(groups)
+	sum := sha256.Sum256([]byte(strings.Join([]string{id.Token, id.User, strings.Join(groups, "\x00")}, "\x01")))
+	return hex.EncodeToString(sum[:])
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity the request acts as
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity carried by ctx, if any
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+// Bundle holds the clients for one identity
+type Bundle struct {
+	// Config is the identity's config, for clients the bundle doesn't hold
+	Config    *rest.Config
+	Clientset kubernetes.Interface
+	Dynamic   dynamic.Interface
+	// RESTMapper discovers resources as the identity on first use
+	RESTMapper meta.RESTMapper
+}
+
+type entry struct {
+	key     string
+	bundle  *Bundle
+	expires time.Time
+}
+
+// Cache is an LRU of client bundles with a maximum size and a time to live, counted from
+// when the bundle was built. It is safe for concurrent use.
+type Cache struct {
+	base    *rest.Config
+	maxSize int
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+// New creates a cache building bundles from base, holding at most maxSize of them for ttl
+func New(base *rest.Config, maxSize int, ttl time.Duration) *Cache {
+	return &Cache{
+		base:    base,
+		maxSize: maxSize,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+// Get returns the bundle for id, building and caching it when it is missing or expired
+func (c *Cache) Get(id Identity) (*Bundle, error) {
+	key := id.Hash()
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry)
+		if now.Before(e.expires) {
+			c.order.MoveToFront(elem)
+			hitsTotal.Inc()
+			return e.bundle, nil
+		}
+		c.remove(elem, "expired")
+	}
+	missesTotal.Inc()
+
+	// Building only creates clients, discovery waits for the first mapping
+	bundle, err := newBundle(c.configFor(id))
+	if err != nil {
+		return nil, err
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, bundle: bundle, expires: now.Add(c.ttl)})
+
+	for c.order.Len() > c.maxSize {
+		c.remove(c.order.Back(), "size")
+	}
+	entries.Set(float64(c.order.Len()))
+	return bundle, nil
+}
+
+// Len returns how many bundles are cached, including expired ones not yet removed
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(elem *list.Element, reason string) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+	evictionsTotal.Inc(reason)
+	entries.Set(float64(c.order.Len()))
+}
+
+// configFor derives the config for id. A token drops every credential of the base config,
+// so requests never fall back to the server's own permissions. The transport wrappers are
+// kept, they record metrics and throttling and never add credentials.
+func (c *Cache) configFor(id Identity) *rest.Config {
+	var config *rest.Config
+	if id.Token != "" {
+		config = rest.AnonymousClientConfig(c.base)
+		config.BearerToken = id.Token
+		config.WrapTransport = c.base.WrapTransport
+	} else {
+		config = rest.CopyConfig(c.base)
+	}
+	if id.User != "" {
+		config.Impersonate = rest.ImpersonationConfig{UserName: id.User, Groups: id.Groups}
+	}
+	return config
+}
+
+func newBundle(config *rest.Config) (*Bundle, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return &Bundle{
+		Config:     config,
+		Clientset:  clientset,
+		Dynamic:    dynamicClient,
+		RESTMapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery())),
+	}, nil
+}
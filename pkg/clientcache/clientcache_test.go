@@ -0,0 +1,179 @@
+package clientcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// fakeClock is a clock tests move forward by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestCache(maxSize int, ttl time.Duration) (*Cache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache := New(&rest.Config{Host: "https://127.0.0.1:6443", BearerToken: "server-token"}, maxSize, ttl)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func mustGet(t *testing.T, cache *Cache, id Identity) *Bundle {
+	t.Helper()
+	bundle, err := cache.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestGetReusesSameIdentity(t *testing.T) {
+	cache, _ := newTestCache(10, time.Minute)
+
+	first := mustGet(t, cache, Identity{User: "jane", Groups: []string{"dev", "oncall"}})
+	// Groups are an unordered set
+	if again := mustGet(t, cache, Identity{User: "jane", Groups: []string{"oncall", "dev"}}); again != first {
+		t.Error("Get() built a new bundle for the same identity")
+	}
+	if other := mustGet(t, cache, Identity{User: "jane", Groups: []string{"dev"}}); other == first {
+		t.Error("Get() reused the bundle of another identity")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+	cache, _ := newTestCache(10, time.Minute)
+
+	bundles := make([]*Bundle, 32)
+	var wg sync.WaitGroup
+	for i := range bundles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bundles[i], _ = cache.Get(Identity{Token: "caller-token"})
+		}()
+	}
+	wg.Wait()
+
+	for _, bundle := range bundles {
+		if bundle == nil || bundle != bundles[0] {
+			t.Fatal("concurrent Get() calls for one identity got different bundles")
+		}
+	}
+}
+
+func TestGetEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := newTestCache(3, time.Hour)
+	ids := make([]Identity, 4)
+	for i := range ids {
+		ids[i] = Identity{User: fmt.Sprintf("user-%d", i)}
+	}
+
+	first := mustGet(t, cache, ids[0])
+	mustGet(t, cache, ids[1])
+	mustGet(t, cache, ids[2])
+	// user-0 is used again, so user-1 is now the least recently used
+	mustGet(t, cache, ids[0])
+	mustGet(t, cache, ids[3])
+
+	if cache.Len() != 3 {
+		t.Errorf("Len() = %d, want the maximum of 3", cache.Len())
+	}
+	for i, want := range []bool{true, false, true, true} {
+		if _, cached := cache.items[ids[i].Hash()]; cached != want {
+			t.Errorf("%s cached = %v, want %v", ids[i].User, cached, want)
+		}
+	}
+	if mustGet(t, cache, ids[0]) != first {
+		t.Error("Get() rebuilt the bundle of a recently used identity")
+	}
+}
+
+func TestGetExpires(t *testing.T) {
+	cache, clock := newTestCache(10, time.Minute)
+	id := Identity{Token: "caller-token"}
+
+	first := mustGet(t, cache, id)
+	clock.now = clock.now.Add(59 * time.Second)
+	if mustGet(t, cache, id) != first {
+		t.Error("Get() rebuilt a bundle before its time to live")
+	}
+	// The time to live counts from when the bundle was built, not from its last use
+	clock.now = clock.now.Add(time.Second)
+	second := mustGet(t, cache, id)
+	if second == first {
+		t.Error("Get() returned an expired bundle")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want the expired bundle replaced", cache.Len())
+	}
+	if mustGet(t, cache, id) != second {
+		t.Error("Get() rebuilt the bundle that replaced the expired one")
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	wrapped := 0
+	base := &rest.Config{
+		Host:        "https://127.0.0.1:6443",
+		BearerToken: "server-token",
+		QPS:         50,
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			wrapped++
+			return rt
+		},
+	}
+	cache := New(base, 10, time.Minute)
+
+	tests := []struct {
+		name      string
+		id        Identity
+		wantToken string
+		wantUser  string
+	}{
+		{name: "token", id: Identity{Token: "caller-token"}, wantToken: "caller-token"},
+		{name: "impersonation", id: Identity{User: "jane", Groups: []string{"dev"}}, wantToken: "server-token", wantUser: "jane"},
+		{name: "token impersonating", id: Identity{Token: "caller-token", User: "jane"}, wantToken: "caller-token", wantUser: "jane"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := cache.configFor(tt.id)
+			if config.BearerToken != tt.wantToken || config.Impersonate.UserName != tt.wantUser {
+				t.Errorf("config token = %q, user = %q, want %q, %q", config.BearerToken, config.Impersonate.UserName, tt.wantToken, tt.wantUser)
+			}
+			if config.QPS != base.QPS {
+				t.Errorf("config QPS = %v, want the base %v", config.QPS, base.QPS)
+			}
+			// The throttling and metrics wrappers see every identity's requests
+			wrapped = 0
+			if _, err := rest.TransportFor(config); err != nil {
+				t.Fatal(err)
+			}
+			if wrapped != 1 {
+				t.Errorf("transport wrapped %d times, want the base wrapper kept", wrapped)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext() found an identity in an empty context")
+	}
+	want := Identity{Token: "caller-token"}
+	if got, ok := FromContext(NewContext(context.Background(), want)); !ok || got.Token != want.Token {
+		t.Errorf("FromContext() = %+v, %v, want %+v", got, ok, want)
+	}
+}
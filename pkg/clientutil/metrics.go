@@ -0,0 +1,45 @@
+package clientutil
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+var rateLimiterDuration = metrics.NewHistogram("kgent_client_rate_limiter_duration_seconds",
+	"How long requests to the API server waited on the client-side rate limiter.",
+	[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}, "verb", "host")
+
+var throttledRequests = metrics.NewCounter("kgent_client_throttled_requests_total",
+	"Requests to the API server answered with 429 Too Many Requests, retried by client-go.", "verb", "host")
+
+// RegisterMetrics exposes how long client-go requests wait on their rate limiter, and how
+// many the API server throttles, through pkg/metrics, showing when QPS and burst are too low
+// or API Priority and Fairness is queuing requests. client-go accepts the first
+// registration only, so it must be called before clients are created.
+func RegisterMetrics() {
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RateLimiterLatency: rateLimiterLatency{},
+		RequestResult:      requestResult{},
+	})
+}
+
+type rateLimiterLatency struct{}
+
+func (rateLimiterLatency) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	rateLimiterDuration.Observe(latency.Seconds(), verb, u.Host)
+}
+
+type requestResult struct{}
+
+func (requestResult) Increment(_ context.Context, code string, method string, host string) {
+	if code == strconv.Itoa(http.StatusTooManyRequests) {
+		throttledRequests.Inc(method, host)
+	}
+}
@@ -0,0 +1,121 @@
+package clientutil
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+)
+
+// API Priority and Fairness headers naming the flow schema and priority level a request was
+// classified into
+const (
+	flowSchemaHeader    = "X-Kubernetes-PF-FlowSchema-UID"
+	priorityLevelHeader = "X-Kubernetes-PF-PriorityLevel-UID"
+)
+
+var retryAfterSeconds = metrics.NewHistogram("kgent_client_retry_after_seconds",
+	"Retry-After values of the 429 responses the API server sent kgent-api's clients.",
+	[]float64{0, 1, 2, 5, 10, 30, 60}, "host")
+
+// Throttling records the 429 responses the API server sends, which client-go retries
+// silently after the Retry-After delay, so API Priority and Fairness throttling shows up as
+// slowness with no error. Its transport wrapper must be installed on every client config.
+type Throttling struct {
+	mu      sync.Mutex
+	summary ThrottleSummary
+}
+
+// ThrottleSummary is the 429 responses received since startup
+type ThrottleSummary struct {
+	Since     time.Time  `json:"since"`
+	Throttled int64      `json:"throttled"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+	// LastRetryAfter and MaxRetryAfter are in seconds
+	LastRetryAfter int64 `json:"lastRetryAfterSeconds"`
+	MaxRetryAfter  int64 `json:"maxRetryAfterSeconds"`
+	// ByPriorityLevel and ByFlowSchema count responses by the UID the API server classified
+	// the request into, when it said
+	ByPriorityLevel map[string]int64 `json:"byPriorityLevel"`
+	ByFlowSchema    map[string]int64 `json:"byFlowSchema"`
+}
+
+func NewThrottling() *Throttling {
+	return &Throttling{summary: ThrottleSummary{
+		Since:           time.Now(),
+		ByPriorityLevel: map[string]int64{},
+		ByFlowSchema:    map[string]int64{},
+	}}
+}
+
+// WrapTransport is a transport.WrapperFunc recording the 429 responses of rt
+func (t *Throttling) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			t.observe(req.URL.Host, resp.Header)
+		}
+		return resp, err
+	})
+}
+
+func (t *Throttling) observe(host string, header http.Header) {
+	retryAfter, ok := parseRetryAfter(header.Get("Retry-After"))
+	if ok {
+		retryAfterSeconds.Observe(float64(retryAfter), host)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.summary.Throttled++
+	t.summary.LastSeen = &now
+	if ok {
+		t.summary.LastRetryAfter = retryAfter
+		t.summary.MaxRetryAfter = max(t.summary.MaxRetryAfter, retryAfter)
+	}
+	if uid := header.Get(priorityLevelHeader); uid != "" {
+		t.summary.ByPriorityLevel[uid]++
+	}
+	if uid := header.Get(flowSchemaHeader); uid != "" {
+		t.summary.ByFlowSchema[uid]++
+	}
+}
+
+// Summary returns a copy of the 429 responses recorded so far
+func (t *Throttling) Summary() ThrottleSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	summary := t.summary
+	summary.ByPriorityLevel = make(map[string]int64, len(t.summary.ByPriorityLevel))
+	for uid, count := range t.summary.ByPriorityLevel {
+		summary.ByPriorityLevel[uid] = count
+	}
+	summary.ByFlowSchema = make(map[string]int64, len(t.summary.ByFlowSchema))
+	for uid, count := range t.summary.ByFlowSchema {
+		summary.ByFlowSchema[uid] = count
+	}
+	return summary
+}
+
+// parseRetryAfter reads a Retry-After header in seconds, or as an HTTP date
+func parseRetryAfter(value string) (int64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return seconds, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(int64(time.Until(date).Seconds()), 0), true
+	}
+	return 0, false
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,50 @@
+// Package cronschedule computes when a CronJob fires next from its schedule and time zone,
+// the way the CronJob controller interprets them.
+package cronschedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Next returns the n times schedule fires after from. timeZone is an IANA time zone name,
+// UTC when empty, as for the CronJob controller running in UTC. Invalid schedules and time
+// zones are returned as errors, including those the parser would panic on.
+func Next(schedule string, timeZone string, from time.Time, n int) (times []time.Time, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			times, err = nil, fmt.Errorf("invalid schedule %q: %v", schedule, r)
+		}
+	}()
+
+	location := time.UTC
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+	// The API server rejects TZ prefixes alongside timeZone, but older ones let them through
+	if timeZone != "" && (strings.HasPrefix(schedule, "TZ=") || strings.HasPrefix(schedule, "CRON_TZ=")) {
+		return nil, fmt.Errorf("schedule %q sets a time zone already given by timeZone", schedule)
+	}
+
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+
+	times = make([]time.Time, 0, n)
+	next := from.In(location)
+	for len(times) < n {
+		next = parsed.Next(next)
+		// Schedules that never fire, such as February 30th, give the zero time
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next)
+	}
+	return times, nil
+}
@@ -0,0 +1,218 @@
+// Package describe renders kubectl-describe style summaries of objects from a registry of
+// per-kind describers, with a generic fallback for kinds that have none.
+package describe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Field is a single key/value line in a section
+type Field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Section is a titled block of fields and, for tabular data such as events, rows
+type Section struct {
+	Title   string     `json:"title"`
+	Fields  []Field    `json:"fields,omitempty"`
+	Columns []string   `json:"columns,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+}
+
+// Description is the structured form of a describe output
+type Description struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace,omitempty"`
+	Sections  []Section `json:"sections"`
+}
+
+// Describer produces the sections for one kind
+type Describer func(obj *unstructured.Unstructured) ([]Section, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[schema.GroupKind]Describer{}
+)
+
+// Register installs a describer for a group kind, replacing any existing one
+func Register(gk schema.GroupKind, describer Describer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[gk] = describer
+}
+
+// Describe builds the description of obj using the registered describer for its kind,
+// or the generic describer otherwise
+func Describe(obj *unstructured.Unstructured) (*Description, error) {
+	gk := obj.GroupVersionKind().GroupKind()
+
+	registryMu.RLock()
+	describer, ok := registry[gk]
+	registryMu.RUnlock()
+	if !ok {
+		describer = Generic
+	}
+
+	sections, err := describer(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s %s: %w", gk.Kind, obj.GetName(), err)
+	}
+
+	return &Description{
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Sections:  append([]Section{metadataSection(obj)}, sections...),
+	}, nil
+}
+
+// Text renders a description as aligned kubectl-describe style text
+func (d *Description) Text() string {
+	var b strings.Builder
+	for _, section := range d.Sections {
+		if section.Title != "" {
+			fmt.Fprintf(&b, "%s:\n", section.Title)
+		}
+
+		width := 0
+		for _, field := range section.Fields {
+			width = max(width, len(field.Key))
+		}
+		for _, field := range section.Fields {
+			fmt.Fprintf(&b, "  %-*s  %s\n", width+1, field.Key+":", field.Value)
+		}
+
+		if len(section.Columns) > 0 {
+			writeTable(&b, section.Columns, section.Rows)
+		}
+	}
+	return b.String()
+}
+
+func writeTable(b *strings.Builder, columns []string, rows [][]string) {
+	if len(rows) == 0 {
+		b.WriteString("  <none>\n")
+		return
+	}
+
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = len(column)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], len(cell))
+			}
+		}
+	}
+
+	writeRow := func(cells []string) {
+		b.WriteString(" ")
+		for i, cell := range cells {
+			if i < len(widths) {
+				fmt.Fprintf(b, " %-*s", widths[i], cell)
+			}
+		}
+		b.WriteString("\n")
+	}
+	writeRow(columns)
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
+
+func metadataSection(obj *unstructured.Unstructured) Section {
+	fields := []Field{{Key: "Name", Value: obj.GetName()}}
+	if obj.GetNamespace() != "" {
+		fields = append(fields, Field{Key: "Namespace", Value: obj.GetNamespace()})
+	}
+	fields = append(fields,
+		Field{Key: "Kind", Value: obj.GetKind()},
+		Field{Key: "Labels", Value: formatMap(obj.GetLabels())},
+		Field{Key: "Annotations", Value: formatMap(obj.GetAnnotations())},
+		Field{Key: "Creation Timestamp", Value: obj.GetCreationTimestamp().String()},
+	)
+	return Section{Fields: fields}
+}
+
+// Generic describes any object from its spec scalars and status conditions
+func Generic(obj *unstructured.Unstructured) ([]Section, error) {
+	var sections []Section
+
+	if spec, ok := obj.Object["spec"].(map[string]interface{}); ok {
+		section := Section{Title: "Spec"}
+		keys := make([]string, 0, len(spec))
+		for key := range spec {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			section.Fields = append(section.Fields, Field{Key: key, Value: summarize(spec[key])})
+		}
+		sections = append(sections, section)
+	}
+
+	if conditions := Conditions(obj); len(conditions.Rows) > 0 {
+		sections = append(sections, conditions)
+	}
+	return sections, nil
+}
+
+// Conditions renders status.conditions as a table
+func Conditions(obj *unstructured.Unstructured) Section {
+	section := Section{Title: "Conditions", Columns: []string{"Type", "Status", "Reason", "Message"}}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		row := make([]string, 0, 4)
+		for _, key := range []string{"type", "status", "reason", "message"} {
+			value, _ := condition[key].(string)
+			row = append(row, value)
+		}
+		section.Rows = append(section.Rows, row)
+	}
+	return section
+}
+
+// summarize renders scalars as-is and collapses nested values to a short description
+func summarize(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return fmt.Sprintf("<object with %d fields>", len(v))
+	case []interface{}:
+		return fmt.Sprintf("<list of %d items>", len(v))
+	case nil:
+		return "<none>"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func formatMap(m map[string]string) string {
+	if len(m) == 0 {
+		return "<none>"
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+m[key])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,227 @@
+package describe
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func init() {
+	Register(schema.GroupKind{Kind: "Pod"}, describePod)
+	Register(schema.GroupKind{Kind: "Service"}, describeService)
+	Register(schema.GroupKind{Kind: "Node"}, describeNode)
+	Register(schema.GroupKind{Kind: "PersistentVolumeClaim"}, describePVC)
+	Register(schema.GroupKind{Group: "apps", Kind: "Deployment"}, describeDeployment)
+}
+
+func describePod(obj *unstructured.Unstructured) ([]Section, error) {
+	pod := &v1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+		return nil, err
+	}
+
+	overview := Section{Fields: []Field{
+		{Key: "Node", Value: pod.Spec.NodeName},
+		{Key: "Service Account", Value: pod.Spec.ServiceAccountName},
+		{Key: "Status", Value: string(pod.Status.Phase)},
+		{Key: "IP", Value: pod.Status.PodIP},
+		{Key: "QoS Class", Value: string(pod.Status.QOSClass)},
+	}}
+
+	statuses := map[string]v1.ContainerStatus{}
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		statuses[status.Name] = status
+	}
+
+	sections := []Section{overview}
+	for _, group := range []struct {
+		title      string
+		containers []v1.Container
+	}{
+		{"Init Containers", pod.Spec.InitContainers},
+		{"Containers", pod.Spec.Containers},
+	} {
+		if len(group.containers) == 0 {
+			continue
+		}
+		section := Section{Title: group.title}
+		for _, c := range group.containers {
+			status := statuses[c.Name]
+			section.Fields = append(section.Fields,
+				Field{Key: c.Name + " Image", Value: c.Image},
+				Field{Key: c.Name + " State", Value: containerState(status.State)},
+				Field{Key: c.Name + " Ready", Value: fmt.Sprintf("%t", status.Ready)},
+				Field{Key: c.Name + " Restart Count", Value: fmt.Sprintf("%d", status.RestartCount)},
+				Field{Key: c.Name + " Requests", Value: formatResourceList(c.Resources.Requests)},
+				Field{Key: c.Name + " Limits", Value: formatResourceList(c.Resources.Limits)},
+			)
+		}
+		sections = append(sections, section)
+	}
+
+	volumes := Section{Title: "Volumes", Columns: []string{"Name", "Type"}}
+	for _, volume := range pod.Spec.Volumes {
+		volumes.Rows = append(volumes.Rows, []string{volume.Name, volumeType(volume)})
+	}
+	sections = append(sections, volumes, Conditions(obj))
+	return sections, nil
+}
+
+func describeDeployment(obj *unstructured.Unstructured) ([]Section, error) {
+	deploy := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deploy); err != nil {
+		return nil, err
+	}
+
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+
+	selector := map[string]string{}
+	if deploy.Spec.Selector != nil {
+		selector = deploy.Spec.Selector.MatchLabels
+	}
+
+	images := make([]string, 0, len(deploy.Spec.Template.Spec.Containers))
+	for _, c := range deploy.Spec.Template.Spec.Containers {
+		images = append(images, c.Name+"="+c.Image)
+	}
+
+	overview := Section{Fields: []Field{
+		{Key: "Selector", Value: formatMap(selector)},
+		{Key: "Replicas", Value: fmt.Sprintf("%d desired | %d updated | %d total | %d available | %d unavailable",
+			replicas, deploy.Status.UpdatedReplicas, deploy.Status.Replicas, deploy.Status.AvailableReplicas, deploy.Status.UnavailableReplicas)},
+		{Key: "Strategy Type", Value: string(deploy.Spec.Strategy.Type)},
+		{Key: "Paused", Value: fmt.Sprintf("%t", deploy.Spec.Paused)},
+		{Key: "Images", Value: strings.Join(images, ", ")},
+	}}
+	return []Section{overview, Conditions(obj)}, nil
+}
+
+func describeService(obj *unstructured.Unstructured) ([]Section, error) {
+	svc := &v1.Service{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, svc); err != nil {
+		return nil, err
+	}
+
+	overview := Section{Fields: []Field{
+		{Key: "Type", Value: string(svc.Spec.Type)},
+		{Key: "Selector", Value: formatMap(svc.Spec.Selector)},
+		{Key: "IP", Value: svc.Spec.ClusterIP},
+		{Key: "Session Affinity", Value: string(svc.Spec.SessionAffinity)},
+	}}
+
+	ports := Section{Title: "Ports", Columns: []string{"Name", "Port", "TargetPort", "NodePort", "Protocol"}}
+	for _, port := range svc.Spec.Ports {
+		ports.Rows = append(ports.Rows, []string{
+			port.Name,
+			fmt.Sprintf("%d", port.Port),
+			port.TargetPort.String(),
+			fmt.Sprintf("%d", port.NodePort),
+			string(port.Protocol),
+		})
+	}
+	return []Section{overview, ports}, nil
+}
+
+func describeNode(obj *unstructured.Unstructured) ([]Section, error) {
+	node := &v1.Node{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, node); err != nil {
+		return nil, err
+	}
+
+	taints := make([]string, 0, len(node.Spec.Taints))
+	for _, taint := range node.Spec.Taints {
+		taints = append(taints, taint.ToString())
+	}
+
+	info := node.Status.NodeInfo
+	overview := Section{Fields: []Field{
+		{Key: "Unschedulable", Value: fmt.Sprintf("%t", node.Spec.Unschedulable)},
+		{Key: "Taints", Value: strings.Join(taints, ", ")},
+		{Key: "Kubelet Version", Value: info.KubeletVersion},
+		{Key: "Container Runtime", Value: info.ContainerRuntimeVersion},
+		{Key: "OS Image", Value: info.OSImage},
+		{Key: "Architecture", Value: info.Architecture},
+	}}
+	resources := Section{Title: "Resources", Fields: []Field{
+		{Key: "Capacity", Value: formatResourceList(node.Status.Capacity)},
+		{Key: "Allocatable", Value: formatResourceList(node.Status.Allocatable)},
+	}}
+	return []Section{overview, resources, Conditions(obj)}, nil
+}
+
+func describePVC(obj *unstructured.Unstructured) ([]Section, error) {
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pvc); err != nil {
+		return nil, err
+	}
+
+	storageClass := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+	accessModes := make([]string, 0, len(pvc.Spec.AccessModes))
+	for _, mode := range pvc.Spec.AccessModes {
+		accessModes = append(accessModes, string(mode))
+	}
+
+	overview := Section{Fields: []Field{
+		{Key: "StorageClass", Value: storageClass},
+		{Key: "Status", Value: string(pvc.Status.Phase)},
+		{Key: "Volume", Value: pvc.Spec.VolumeName},
+		{Key: "Capacity", Value: formatResourceList(pvc.Status.Capacity)},
+		{Key: "Requested", Value: formatResourceList(pvc.Spec.Resources.Requests)},
+		{Key: "Access Modes", Value: strings.Join(accessModes, ", ")},
+	}}
+	return []Section{overview, Conditions(obj)}, nil
+}
+
+func containerState(state v1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "Running since " + state.Running.StartedAt.String()
+	case state.Waiting != nil:
+		return "Waiting: " + state.Waiting.Reason
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated: %s (exit code %d)", state.Terminated.Reason, state.Terminated.ExitCode)
+	default:
+		return "<unknown>"
+	}
+}
+
+func volumeType(volume v1.Volume) string {
+	switch {
+	case volume.ConfigMap != nil:
+		return "ConfigMap " + volume.ConfigMap.Name
+	case volume.Secret != nil:
+		return "Secret " + volume.Secret.SecretName
+	case volume.PersistentVolumeClaim != nil:
+		return "PersistentVolumeClaim " + volume.PersistentVolumeClaim.ClaimName
+	case volume.EmptyDir != nil:
+		return "EmptyDir"
+	case volume.HostPath != nil:
+		return "HostPath " + volume.HostPath.Path
+	case volume.Projected != nil:
+		return "Projected"
+	default:
+		return "Other"
+	}
+}
+
+func formatResourceList(list v1.ResourceList) string {
+	if len(list) == 0 {
+		return "<none>"
+	}
+	values := make(map[string]string, len(list))
+	for name, q := range list {
+		values[string(name)] = q.String()
+	}
+	return formatMap(values)
+}
@@ -0,0 +1,112 @@
+// Package disruption resolves PodDisruptionBudgets against pods so PDB listings and
+// node drains report blocking budgets the same way.
+package disruption
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Budget is the resolved state of a PodDisruptionBudget
+type Budget struct {
+	Namespace          string   `json:"namespace"`
+	Name               string   `json:"name"`
+	Selector           string   `json:"selector"`
+	MinAvailable       string   `json:"minAvailable,omitempty"`
+	MaxUnavailable     string   `json:"maxUnavailable,omitempty"`
+	CurrentHealthy     int32    `json:"currentHealthy"`
+	DesiredHealthy     int32    `json:"desiredHealthy"`
+	ExpectedPods       int32    `json:"expectedPods"`
+	DisruptionsAllowed int32    `json:"disruptionsAllowed"`
+	Pods               []string `json:"pods"`
+	BlocksDisruptions  bool     `json:"blocksDisruptions"`
+	Reason             string   `json:"reason,omitempty"`
+}
+
+// Selector converts the budget's label selector. A nil selector matches nothing, and an
+// empty one matches every pod in the namespace, mirroring the disruption controller.
+func Selector(pdb *policyv1.PodDisruptionBudget) (labels.Selector, error) {
+	if pdb.Spec.Selector == nil {
+		return labels.Nothing(), nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector on PodDisruptionBudget %s/%s: %w", pdb.Namespace, pdb.Name, err)
+	}
+	return selector, nil
+}
+
+// CoveredPods returns the pods in the budget's namespace matched by its selector
+func CoveredPods(pdb *policyv1.PodDisruptionBudget, pods []*v1.Pod) ([]*v1.Pod, error) {
+	selector, err := Selector(pdb)
+	if err != nil {
+		return nil, err
+	}
+
+	var covered []*v1.Pod
+	for _, pod := range pods {
+		if pod.Namespace == pdb.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+			covered = append(covered, pod)
+		}
+	}
+	return covered, nil
+}
+
+// MatchingBudgets returns the budgets that cover a pod
+func MatchingBudgets(pod *v1.Pod, pdbs []*policyv1.PodDisruptionBudget) []*policyv1.PodDisruptionBudget {
+	var matching []*policyv1.PodDisruptionBudget
+	for _, pdb := range pdbs {
+		if pdb.Namespace != pod.Namespace {
+			continue
+		}
+		selector, err := Selector(pdb)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			matching = append(matching, pdb)
+		}
+	}
+	return matching
+}
+
+// Resolve builds the budget status and flags budgets that permanently block disruptions:
+// no disruptions allowed even though every expected pod is healthy, which stalls node drains
+func Resolve(pdb *policyv1.PodDisruptionBudget, pods []*v1.Pod) (Budget, error) {
+	covered, err := CoveredPods(pdb, pods)
+	if err != nil {
+		return Budget{}, err
+	}
+
+	budget := Budget{
+		Namespace:          pdb.Namespace,
+		Name:               pdb.Name,
+		CurrentHealthy:     pdb.Status.CurrentHealthy,
+		DesiredHealthy:     pdb.Status.DesiredHealthy,
+		ExpectedPods:       pdb.Status.ExpectedPods,
+		DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+		Pods:               []string{},
+	}
+	if pdb.Spec.Selector != nil {
+		budget.Selector = metav1.FormatLabelSelector(pdb.Spec.Selector)
+	}
+	if pdb.Spec.MinAvailable != nil {
+		budget.MinAvailable = pdb.Spec.MinAvailable.String()
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		budget.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+	}
+	for _, pod := range covered {
+		budget.Pods = append(budget.Pods, pod.Name)
+	}
+
+	if pdb.Status.DisruptionsAllowed == 0 && pdb.Status.ExpectedPods > 0 && pdb.Status.CurrentHealthy >= pdb.Status.ExpectedPods {
+		budget.BlocksDisruptions = true
+		budget.Reason = fmt.Sprintf("all %d pods are healthy but no disruptions are allowed; minAvailable/maxUnavailable leaves no room for evictions", pdb.Status.ExpectedPods)
+	}
+	return budget, nil
+}
@@ -0,0 +1,211 @@
+// Package drain plans node drains: what happens to each pod on the node, which budgets
+// stand in the way, and whether the rest of the cluster has room for what moves.
+package drain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"kgent-api/pkg/capacity"
+	"kgent-api/pkg/disruption"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// What a drain does with a pod
+const (
+	ActionEvict            = "will-evict"
+	ActionBlockedByPDB     = "blocked-by-pdb"
+	ActionDaemonSetSkipped = "daemonset-skipped"
+	ActionMirrorSkipped    = "mirror-pod-skipped"
+	// ActionUnmanaged pods have no controller to recreate them, so evicting them needs force
+	ActionUnmanaged = "unmanaged"
+)
+
+// Plan is what draining a node would do
+type Plan struct {
+	Node string `json:"node"`
+	// PlanID identifies the pods and what happens to each. Draining with it fails when the
+	// plan has changed since.
+	PlanID string    `json:"planId"`
+	Pods   []PodPlan `json:"pods"`
+	// Counts is the number of pods per action
+	Counts   map[string]int `json:"counts"`
+	Capacity Fit            `json:"capacity"`
+}
+
+// PodPlan is what a drain does with one pod
+type PodPlan struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	Action    string `json:"action"`
+	// Owner is the kind and name of the pod's controller
+	Owner    string             `json:"owner,omitempty"`
+	Requests capacity.Resources `json:"requests"`
+	// Budgets are the PodDisruptionBudgets covering the pod
+	Budgets []Budget `json:"budgets,omitempty"`
+}
+
+// Budget is a PodDisruptionBudget covering a pod
+type Budget struct {
+	Name               string `json:"name"`
+	DisruptionsAllowed int32  `json:"disruptionsAllowed"`
+}
+
+// Fit is whether the pods leaving the node fit in the free allocatable of the other
+// schedulable nodes. It is a simple bin check on cpu, memory and pod count that ignores
+// taints, affinity and topology spread, so a fit is no guarantee.
+type Fit struct {
+	Fits bool `json:"fits"`
+	// Moving are the requests of the pods leaving the node
+	Moving capacity.Resources `json:"moving"`
+	// Free is the free allocatable of the other schedulable nodes
+	Free  capacity.Resources `json:"free"`
+	Nodes int                `json:"nodes"`
+	// Unplaced are the pods that found no node
+	Unplaced []string `json:"unplaced,omitempty"`
+}
+
+// NewPlan plans draining node, with nodePods the pods scheduled on it, and pods, nodes and
+// pdbs everything in the cluster
+func NewPlan(node *v1.Node, nodePods []*v1.Pod, nodes []*v1.Node, pods []*v1.Pod, pdbs []*policyv1.PodDisruptionBudget) Plan {
+	plan := Plan{Node: node.Name, Pods: []PodPlan{}, Counts: map[string]int{}}
+	var moving []*v1.Pod
+	for _, pod := range nodePods {
+		pp := PodPlan{Namespace: pod.Namespace, Name: pod.Name, UID: string(pod.UID)}
+		owner := metav1.GetControllerOf(pod)
+		if owner != nil {
+			pp.Owner = owner.Kind + "/" + owner.Name
+		}
+		if !capacity.IsTerminal(pod) {
+			pp.Requests, _ = capacity.PodRequestsAndLimits(pod)
+		}
+
+		blocked := false
+		for _, pdb := range disruption.MatchingBudgets(pod, pdbs) {
+			pp.Budgets = append(pp.Budgets, Budget{Name: pdb.Name, DisruptionsAllowed: pdb.Status.DisruptionsAllowed})
+			if pdb.Status.DisruptionsAllowed == 0 {
+				blocked = true
+			}
+		}
+
+		switch _, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]; {
+		case mirror:
+			pp.Action = ActionMirrorSkipped
+		case owner != nil && owner.Kind == "DaemonSet":
+			pp.Action = ActionDaemonSetSkipped
+		case owner == nil:
+			pp.Action = ActionUnmanaged
+		case blocked && !capacity.IsTerminal(pod):
+			pp.Action = ActionBlockedByPDB
+		default:
+			pp.Action = ActionEvict
+		}
+		if pp.Action != ActionMirrorSkipped && pp.Action != ActionDaemonSetSkipped && !capacity.IsTerminal(pod) {
+			moving = append(moving, pod)
+		}
+
+		plan.Pods = append(plan.Pods, pp)
+		plan.Counts[pp.Action]++
+	}
+	sort.Slice(plan.Pods, func(i, j int) bool {
+		if plan.Pods[i].Namespace != plan.Pods[j].Namespace {
+			return plan.Pods[i].Namespace < plan.Pods[j].Namespace
+		}
+		return plan.Pods[i].Name < plan.Pods[j].Name
+	})
+
+	plan.Capacity = fit(node.Name, moving, nodes, pods)
+	plan.PlanID = planID(plan)
+	return plan
+}
+
+// fit places the moving pods, largest first, on the first schedulable node other than
+// the drained one with room for them
+func fit(drained string, moving []*v1.Pod, nodes []*v1.Node, pods []*v1.Pod) Fit {
+	var candidates []*v1.Node
+	for _, node := range nodes {
+		if node.Name != drained && schedulable(node) {
+			candidates = append(candidates, node)
+		}
+	}
+	report := capacity.Summarize(candidates, pods, 100)
+
+	type bin struct {
+		free  capacity.Resources
+		slots int64
+	}
+	bins := make([]*bin, 0, len(report.Nodes))
+	result := Fit{Fits: true, Nodes: len(report.Nodes), Moving: capacity.NewResources()}
+	result.Free = report.Cluster.Free()
+	for i := range report.Nodes {
+		bins = append(bins, &bin{free: report.Nodes[i].Free(), slots: report.Nodes[i].AllocatablePod - int64(report.Nodes[i].PodCount)})
+	}
+
+	type request struct {
+		name     string
+		requests capacity.Resources
+	}
+	requests := make([]request, 0, len(moving))
+	for _, pod := range moving {
+		r, _ := capacity.PodRequestsAndLimits(pod)
+		requests = append(requests, request{name: pod.Namespace + "/" + pod.Name, requests: r})
+		result.Moving.CPU.Add(r.CPU)
+		result.Moving.Memory.Add(r.Memory)
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		if c := requests[i].requests.CPU.Cmp(requests[j].requests.CPU); c != 0 {
+			return c > 0
+		}
+		return requests[i].requests.Memory.Cmp(requests[j].requests.Memory) > 0
+	})
+
+	for _, r := range requests {
+		placed := false
+		for _, b := range bins {
+			if b.slots < 1 || b.free.CPU.Cmp(r.requests.CPU) < 0 || b.free.Memory.Cmp(r.requests.Memory) < 0 {
+				continue
+			}
+			b.free.CPU.Sub(r.requests.CPU)
+			b.free.Memory.Sub(r.requests.Memory)
+			b.slots--
+			placed = true
+			break
+		}
+		if !placed {
+			result.Fits = false
+			result.Unplaced = append(result.Unplaced, r.name)
+		}
+	}
+	return result
+}
+
+// schedulable reports whether new pods can land on node: ready and not cordoned
+func schedulable(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// planID hashes the pods, their actions and whether they fit elsewhere, so it changes when
+// a pod comes, goes or is handled differently, or the fit flips, but not with every shift
+// in capacity
+func planID(plan Plan) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s fits=%t\n", plan.Node, plan.Capacity.Fits)
+	for _, pp := range plan.Pods {
+		fmt.Fprintf(h, "%s/%s/%s=%s\n", pp.Namespace, pp.Name, pp.UID, pp.Action)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,239 @@
+// Package dyninformer runs dynamic informers that are started and stopped at runtime, so
+// caches for arbitrary resources, including custom resources, only live while they are used.
+package dyninformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	ErrAlreadyRunning = errors.New("informer is already running")
+	ErrNotRunning     = errors.New("informer is not running")
+)
+
+// Info describes a running informer
+type Info struct {
+	Group      string    `json:"group,omitempty"`
+	Version    string    `json:"version"`
+	Resource   string    `json:"resource"`
+	Namespace  string    `json:"namespace,omitempty"`
+	HasSynced  bool      `json:"hasSynced"`
+	Objects    int       `json:"objects"`
+	StartedAt  time.Time `json:"startedAt"`
+	Age        string    `json:"age"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+type key struct {
+	gvr schema.GroupVersionResource
+	// namespace is empty for informers watching all namespaces
+	namespace string
+}
+
+type entry struct {
+	informer   cache.SharedIndexInformer
+	stop       chan struct{}
+	startedAt  time.Time
+	lastAccess atomic.Int64
+}
+
+func (e *entry) touch() {
+	e.lastAccess.Store(time.Now().UnixNano())
+}
+
+func (e *entry) info(k key) Info {
+	return Info{
+		Group:      k.gvr.Group,
+		Version:    k.gvr.Version,
+		Resource:   k.gvr.Resource,
+		Namespace:  k.namespace,
+		HasSynced:  e.informer.HasSynced(),
+		Objects:    len(e.informer.GetStore().ListKeys()),
+		StartedAt:  e.startedAt,
+		Age:        duration.HumanDuration(time.Since(e.startedAt)),
+		LastAccess: time.Unix(0, e.lastAccess.Load()),
+	}
+}
+
+// Registry owns the stop channel of every informer it started
+type Registry struct {
+	client      dynamic.Interface
+	idleTimeout time.Duration
+	transform   cache.TransformFunc
+
+	mu      sync.Mutex
+	entries map[key]*entry
+}
+
+// NewRegistry creates a registry whose informers are stopped after idleTimeout without
+// being queried, or never when idleTimeout is zero. transform, when set, is applied to
+// objects before they are cached.
+func NewRegistry(client dynamic.Interface, idleTimeout time.Duration, transform cache.TransformFunc) *Registry {
+	return &Registry{
+		client:      client,
+		idleTimeout: idleTimeout,
+		transform:   transform,
+		entries:     map[key]*entry{},
+	}
+}
+
+// Start runs an informer for gvr in namespace, or in all namespaces when namespace is empty.
+// It returns without waiting for the cache to sync.
+func (r *Registry) Start(gvr schema.GroupVersionResource, namespace string) (Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := key{gvr: gvr, namespace: namespace}
+	if _, ok := r.entries[k]; ok {
+		return Info{}, ErrAlreadyRunning
+	}
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(r.client, gvr, namespace, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil).Informer()
+	if r.transform != nil {
+		if err := informer.SetTransform(r.transform); err != nil {
+			return Info{}, err
+		}
+	}
+
+	e := &entry{informer: informer, stop: make(chan struct{}), startedAt: time.Now()}
+	e.touch()
+	r.entries[k] = e
+	go informer.Run(e.stop)
+	return e.info(k), nil
+}
+
+// Stop stops the informer for gvr in namespace, releasing its cache
+func (r *Registry) Stop(gvr schema.GroupVersionResource, namespace string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := key{gvr: gvr, namespace: namespace}
+	e, ok := r.entries[k]
+	if !ok {
+		return ErrNotRunning
+	}
+	close(e.stop)
+	delete(r.entries, k)
+	return nil
+}
+
+// Lister returns a lister for gvr in namespace from a synced informer watching that
+// namespace or all namespaces, and marks the informer as used
+func (r *Registry) Lister(gvr schema.GroupVersionResource, namespace string) (cache.GenericLister, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ns := range []string{namespace, ""} {
+		e, ok := r.entries[key{gvr: gvr, namespace: ns}]
+		if !ok || !e.informer.HasSynced() {
+			continue
+		}
+		e.touch()
+		return cache.NewGenericLister(e.informer.GetIndexer(), gvr.GroupResource()), true
+	}
+	return nil, false
+}
+
+// Ensure returns a lister for gvr in namespace like Lister, starting an informer for
+// namespace first when none is running and waiting until it has synced or ctx is done
+func (r *Registry) Ensure(ctx context.Context, gvr schema.GroupVersionResource, namespace string) (cache.GenericLister, error) {
+	if lister, ok := r.Lister(gvr, namespace); ok {
+		return lister, nil
+	}
+	if _, err := r.Start(gvr, namespace); err != nil && !errors.Is(err, ErrAlreadyRunning) {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[key{gvr: gvr, namespace: namespace}]
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrNotRunning
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), e.informer.HasSynced) {
+		return nil, fmt.Errorf("informer for %s did not sync: %w", gvr, ctx.Err())
+	}
+	e.touch()
+	return cache.NewGenericLister(e.informer.GetIndexer(), gvr.GroupResource()), nil
+}
+
+// List describes the running informers ordered by resource and namespace
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]Info, 0, len(r.entries))
+	for k, e := range r.entries {
+		infos = append(infos, e.info(k))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Namespace < b.Namespace
+	})
+	return infos
+}
+
+// Run stops idle informers until ctx is cancelled, then stops all of them
+func (r *Registry) Run(ctx context.Context) {
+	interval := time.Minute
+	if r.idleTimeout > 0 && r.idleTimeout < interval {
+		interval = r.idleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.stopAll()
+			return
+		case <-ticker.C:
+			if r.idleTimeout > 0 {
+				r.evictIdle(time.Now().Add(-r.idleTimeout))
+			}
+		}
+	}
+}
+
+func (r *Registry) evictIdle(cutoff time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, e := range r.entries {
+		if time.Unix(0, e.lastAccess.Load()).Before(cutoff) {
+			log.Printf("Stopping informer for %s in %q after %s without queries", k.gvr, k.namespace, r.idleTimeout)
+			close(e.stop)
+			delete(r.entries, k)
+		}
+	}
+}
+
+func (r *Registry) stopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, e := range r.entries {
+		close(e.stop)
+		delete(r.entries, k)
+	}
+}
@@ -0,0 +1,235 @@
+// Package firehose streams new Warning events from every namespace to subscribers, each
+// with its own reason filter, cooldown for repeats and sampling. The events informer only
+// runs while someone is subscribed, since caching every event of a large cluster is costly.
+package firehose
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+	"kgent-api/pkg/recovery"
+	"kgent-api/pkg/stream"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxCooldownKeys bounds the (object, reason) pairs a subscription remembers before it
+// forgets those whose cooldown is over
+const maxCooldownKeys = 4096
+
+// Reasons events are suppressed for, labelling kgent_event_firehose_suppressed_total
+const (
+	SuppressedCooldown = "cooldown"
+	SuppressedSample   = "sample"
+)
+
+var suppressedEvents = metrics.NewCounter("kgent_event_firehose_suppressed_total",
+	"Warning events not sent to firehose subscribers, by why they were suppressed.", "reason")
+
+// Event is a Warning event as sent to subscribers
+type Event struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace,omitempty"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	Source    string    `json:"source,omitempty"`
+}
+
+// key identifies the repeats of an event
+func (e Event) key() string {
+	return e.Kind + "/" + e.Namespace + "/" + e.Name + "|" + e.Reason
+}
+
+// Filter selects what a subscription receives
+type Filter struct {
+	// Reasons only lets events with these reasons through, every reason when empty
+	Reasons []string
+	// Namespaces only lets events in namespaces it allows through, every namespace when nil
+	Namespaces func(namespace string) bool
+	// Cooldown sends one event per object and reason within the window, every one when zero
+	Cooldown time.Duration
+	// Sample sends every Sample-th event passing the other filters, every one when below 2
+	Sample int
+}
+
+// Hub fans the events informer out to subscriptions
+type Hub struct {
+	client    kubernetes.Interface
+	namespace string
+	size      int
+
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	stop          chan struct{}
+}
+
+// NewHub watches events in namespace, every namespace when empty, buffering up to size
+// events for each subscriber
+func NewHub(client kubernetes.Interface, namespace string, size int) *Hub {
+	return &Hub{client: client, namespace: namespace, size: size, subscriptions: map[*Subscription]struct{}{}}
+}
+
+// Subscribe starts receiving the events passing filter. The returned function unsubscribes
+// and must be called once the caller stops reading.
+func (h *Hub) Subscribe(filter Filter) (*Subscription, func(), error) {
+	s := &Subscription{
+		filter:   filter,
+		reasons:  sets.New(filter.Reasons...),
+		lastSent: map[string]time.Time{},
+		buffer:   stream.NewBuffer[Event]("events-firehose", h.size),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop == nil {
+		if err := h.start(); err != nil {
+			s.buffer.Close()
+			return nil, nil, err
+		}
+	}
+	h.subscriptions[s] = struct{}{}
+
+	return s, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscriptions[s]; !ok {
+			return
+		}
+		delete(h.subscriptions, s)
+		s.buffer.Close()
+		if len(h.subscriptions) == 0 {
+			close(h.stop)
+			h.stop = nil
+		}
+	}, nil
+}
+
+// start runs a new informer for Warning events, called with mu held
+func (h *Hub) start() error {
+	informer := coreinformers.NewFilteredEventInformer(h.client, h.namespace, 0, cache.Indexers{},
+		func(options *metav1.ListOptions) {
+			options.FieldSelector = "type=" + corev1.EventTypeWarning
+		})
+	_, err := informer.AddEventHandler(recovery.Handler("firehose/events", cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if event, ok := obj.(*corev1.Event); ok && !isInInitialList {
+				h.publish(event)
+			}
+		},
+		// Repeats of an event update it with a higher count rather than creating another
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			previous, ok := oldObj.(*corev1.Event)
+			event, ok2 := newObj.(*corev1.Event)
+			if ok && ok2 && eventCount(event) > eventCount(previous) {
+				h.publish(event)
+			}
+		},
+	}, nil))
+	if err != nil {
+		return fmt.Errorf("failed to watch events: %w", err)
+	}
+
+	h.stop = make(chan struct{})
+	go informer.Run(h.stop)
+	return nil
+}
+
+func (h *Hub) publish(event *corev1.Event) {
+	e := Event{
+		Time:      eventTime(event),
+		Namespace: event.InvolvedObject.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     eventCount(event),
+		Source:    event.Source.Component,
+	}
+	if e.Source == "" {
+		e.Source = event.ReportingController
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscriptions {
+		s.offer(e)
+	}
+}
+
+// Subscription is one subscriber's filtered view of the events
+type Subscription struct {
+	filter  Filter
+	reasons sets.Set[string]
+	buffer  *stream.Buffer[Event]
+
+	// lastSent and passed are only touched by the hub, with its lock held
+	lastSent map[string]time.Time
+	passed   int
+}
+
+// Buffer holds the events waiting to be sent, dropping the oldest when the subscriber is slow
+func (s *Subscription) Buffer() *stream.Buffer[Event] {
+	return s.buffer
+}
+
+func (s *Subscription) offer(e Event) {
+	if s.reasons.Len() > 0 && !s.reasons.Has(e.Reason) {
+		return
+	}
+	if s.filter.Namespaces != nil && !s.filter.Namespaces(e.Namespace) {
+		return
+	}
+
+	now := time.Now()
+	if s.filter.Cooldown > 0 {
+		key := e.key()
+		if last, ok := s.lastSent[key]; ok && now.Sub(last) < s.filter.Cooldown {
+			suppressedEvents.Inc(SuppressedCooldown)
+			return
+		}
+		if len(s.lastSent) >= maxCooldownKeys {
+			for k, last := range s.lastSent {
+				if now.Sub(last) >= s.filter.Cooldown {
+					delete(s.lastSent, k)
+				}
+			}
+		}
+		s.lastSent[key] = now
+	}
+
+	s.passed++
+	if s.filter.Sample > 1 && s.passed%s.filter.Sample != 0 {
+		suppressedEvents.Inc(SuppressedSample)
+		return
+	}
+	s.buffer.Push(e)
+}
+
+func eventCount(event *corev1.Event) int32 {
+	if event.Series != nil {
+		return event.Series.Count
+	}
+	return event.Count
+}
+
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
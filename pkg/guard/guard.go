@@ -0,0 +1,163 @@
+// Package guard implements guard rails for destructive operations in protected namespaces.
+// Such operations must first be planned, which returns a confirmation token, and then be
+// repeated with that token. Tokens are an HMAC over the operation and a short expiry, so a
+// token only confirms the exact operation that was planned, for a limited time.
+package guard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Destructive actions
+const (
+	ActionDelete           = "delete"
+	ActionDeleteCollection = "delete-collection"
+	ActionScaleToZero      = "scale-to-zero"
+	ActionRemoveFinalizers = "remove-finalizers"
+)
+
+var (
+	// ErrConfirmationRequired is returned when a guarded operation carries no token
+	ErrConfirmationRequired = errors.New("confirmation required: plan the operation with plan=true and repeat it with the returned confirm token")
+	// ErrInvalidToken is returned when a token was not issued for the operation
+	ErrInvalidToken = errors.New("confirmation token does not match the operation")
+	// ErrTokenExpired is returned when a token was issued for the operation but has expired
+	ErrTokenExpired = errors.New("confirmation token has expired, plan the operation again")
+)
+
+// Operation describes a destructive operation. A token confirms one operation exactly.
+type Operation struct {
+	Action    string   `json:"action"`
+	Resource  string   `json:"resource"`
+	Namespace string   `json:"namespace,omitempty"`
+	Names     []string `json:"names"`
+}
+
+// Describe says what op will do, for plans
+func (op Operation) Describe() string {
+	target := op.Resource + " " + strings.Join(op.Names, ", ")
+	if op.Namespace != "" {
+		target += " in namespace " + op.Namespace
+	}
+
+	switch op.Action {
+	case ActionDelete, ActionDeleteCollection:
+		if op.Resource == "namespaces" {
+			return "delete " + target + " and every object in them"
+		}
+		return "delete " + target
+	case ActionScaleToZero:
+		return "scale " + target + " to zero replicas, stopping all of their pods"
+	case ActionRemoveFinalizers:
+		return "remove the finalizers of " + target + ", letting them be deleted before their cleanup has run"
+	}
+	return op.Action + " " + target
+}
+
+// Guard decides which operations need confirmation and issues and verifies their tokens.
+// A nil Guard disables the guard rails.
+type Guard struct {
+	protected []string
+	key       []byte
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// New guards the namespaces matching the path.Match patterns in protected. Tokens are signed
+// with key and valid for ttl; without a key a random one is used, so tokens are only valid on
+// the replica that issued them and until it restarts.
+func New(protected []string, key []byte, ttl time.Duration) (*Guard, error) {
+	for _, pattern := range protected {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid protected namespace pattern %q: %w", pattern, err)
+		}
+	}
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate confirmation key: %w", err)
+		}
+	}
+	return &Guard{protected: protected, key: key, ttl: ttl, now: time.Now}, nil
+}
+
+// Protected reports whether operations in namespace need confirmation
+func (g *Guard) Protected(namespace string) bool {
+	if g == nil || namespace == "" {
+		return false
+	}
+	for _, pattern := range g.protected {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Requires reports whether op needs confirmation: it acts in a protected namespace or deletes one
+func (g *Guard) Requires(op Operation) bool {
+	if op.Resource == "namespaces" {
+		for _, name := range op.Names {
+			if g.Protected(name) {
+				return true
+			}
+		}
+	}
+	return g.Protected(op.Namespace)
+}
+
+// Token issues a confirmation token for op and returns when it expires
+func (g *Guard) Token(op Operation) (string, time.Time) {
+	expires := g.now().Add(g.ttl).Truncate(time.Second)
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + base64.RawURLEncoding.EncodeToString(g.sign(op, expiry)), expires
+}
+
+// Verify checks that token was issued for op and has not expired
+func (g *Guard) Verify(op Operation, token string) error {
+	if token == "" {
+		return ErrConfirmationRequired
+	}
+
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, g.sign(op, expiry)) {
+		return ErrInvalidToken
+	}
+
+	// The expiry is covered by the signature, so it can be trusted once the signature matches
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if g.now().After(time.Unix(expires, 0)) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// sign computes the HMAC of op and expiry, with names sorted so their order doesn't matter
+func (g *Guard) sign(op Operation, expiry string) []byte {
+	op.Names = slices.Sorted(slices.Values(op.Names))
+	descriptor, _ := json.Marshal(op)
+
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(descriptor)
+	mac.Write([]byte{0})
+	mac.Write([]byte(expiry))
+	return mac.Sum(nil)
+}
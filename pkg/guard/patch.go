@@ -0,0 +1,67 @@
+package guard
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PatchAction reports the destructive action a patch performs, scaling to zero replicas or
+// removing finalizers, or "" for any other patch. Merge patches replace the finalizers list
+// as a whole, so setting it at all counts as removing finalizers.
+func PatchAction(patchType types.PatchType, patch []byte) string {
+	if patchType == types.JSONPatchType {
+		return jsonPatchAction(patch)
+	}
+
+	var doc struct {
+		Metadata map[string]json.RawMessage `json:"metadata"`
+		Spec     struct {
+			Replicas *int64 `json:"replicas"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(patch, &doc); err != nil {
+		return ""
+	}
+
+	if finalizers, ok := doc.Metadata["finalizers"]; ok {
+		if patchType == types.MergePatchType || emptyList(finalizers) {
+			return ActionRemoveFinalizers
+		}
+	}
+	if _, ok := doc.Metadata["$deleteFromPrimitiveList/finalizers"]; ok {
+		return ActionRemoveFinalizers
+	}
+	if doc.Spec.Replicas != nil && *doc.Spec.Replicas == 0 {
+		return ActionScaleToZero
+	}
+	return ""
+}
+
+func jsonPatchAction(patch []byte) string {
+	var ops []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return ""
+	}
+
+	for _, op := range ops {
+		switch {
+		case (op.Op == "remove" || op.Op == "replace" || op.Op == "move") && (op.Path == "/metadata/finalizers" || strings.HasPrefix(op.Path, "/metadata/finalizers/")):
+			return ActionRemoveFinalizers
+		case (op.Op == "add" || op.Op == "replace") && op.Path == "/spec/replicas" && string(op.Value) == "0":
+			return ActionScaleToZero
+		}
+	}
+	return ""
+}
+
+// emptyList reports whether a JSON value is null or an empty list
+func emptyList(value json.RawMessage) bool {
+	var list []interface{}
+	return json.Unmarshal(value, &list) == nil && len(list) == 0
+}
@@ -0,0 +1,135 @@
+// Package helm decodes the Helm v3 release records stored in secrets, without depending on
+// the Helm SDK. A release secret holds the release as JSON, usually gzipped, base64 encoded
+// in its "release" key.
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ReleaseSecretType is the type of the secrets Helm v3 stores releases in
+const ReleaseSecretType = "helm.sh/release.v1"
+
+// Labels Helm sets on release secrets
+const (
+	LabelOwner   = "owner"
+	LabelName    = "name"
+	LabelVersion = "version"
+	LabelStatus  = "status"
+)
+
+// maxReleaseSize bounds a decompressed release, guarding against gzip bombs
+const maxReleaseSize = 64 << 20
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Release is the part of a Helm release record the API reports
+type Release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      Info   `json:"info"`
+	Chart     Chart  `json:"chart"`
+	// Config holds the values supplied by the user, without the chart defaults
+	Config   map[string]interface{} `json:"config"`
+	Manifest string                 `json:"manifest"`
+}
+
+// Info describes a release's deployment
+type Info struct {
+	FirstDeployed Time   `json:"first_deployed"`
+	LastDeployed  Time   `json:"last_deployed"`
+	Deleted       Time   `json:"deleted"`
+	Description   string `json:"description"`
+	Status        string `json:"status"`
+}
+
+// Chart is the chart a release was installed from
+type Chart struct {
+	Metadata ChartMetadata `json:"metadata"`
+}
+
+// ChartMetadata identifies a chart and the version of the app it deploys
+type ChartMetadata struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion"`
+}
+
+// Time is a timestamp as Helm writes it, an RFC 3339 string or "" when unset
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` || string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	return json.Unmarshal(data, &t.Time)
+}
+
+// IsReleaseSecret reports whether secret holds a Helm v3 release
+func IsReleaseSecret(secret *corev1.Secret) bool {
+	return secret.Type == ReleaseSecretType
+}
+
+// Decode reads the release out of a release secret
+func Decode(secret *corev1.Secret) (*Release, error) {
+	if !IsReleaseSecret(secret) {
+		return nil, fmt.Errorf("secret %s/%s is of type %q, not a Helm release", secret.Namespace, secret.Name, secret.Type)
+	}
+	payload, ok := secret.Data["release"]
+	if !ok || len(payload) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no release data", secret.Namespace, secret.Name)
+	}
+
+	release, err := DecodeRelease(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode release in secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	return release, nil
+}
+
+// DecodeRelease decodes the base64 encoded, optionally gzipped JSON of a release
+func DecodeRelease(payload []byte) (*Release, error) {
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+	n, err := base64.StdEncoding.Decode(data, bytes.TrimSpace(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	data = data[:n]
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip: %w", err)
+		}
+		defer reader.Close()
+
+		data, err = io.ReadAll(io.LimitReader(reader, maxReleaseSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip: %w", err)
+		}
+		if len(data) > maxReleaseSize {
+			return nil, fmt.Errorf("release exceeds %d bytes", maxReleaseSize)
+		}
+	}
+
+	release := &Release{}
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, fmt.Errorf("invalid release JSON: %w", err)
+	}
+	if release.Name == "" {
+		return nil, fmt.Errorf("release has no name")
+	}
+	return release, nil
+}
@@ -0,0 +1,221 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fixtureRelease is a release record as Helm 3 writes it, with the chart templates, values
+// and hooks the API doesn't decode
+func fixtureRelease() map[string]interface{} {
+	return map[string]interface{}{
+		"name": "web",
+		"info": map[string]interface{}{
+			"first_deployed": "2024-03-01T09:00:00.123456789Z",
+			"last_deployed":  "2024-03-04T17:30:00Z",
+			"deleted":        "",
+			"description":    "Upgrade complete",
+			"status":         "deployed",
+			"notes":          "Visit http://web.example.com",
+		},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":       "web",
+				"version":    "1.4.2",
+				"appVersion": "2.11.0",
+				"apiVersion": "v2",
+				"type":       "application",
+			},
+			"templates": []interface{}{
+				map[string]interface{}{"name": "templates/deployment.yaml", "data": "YXBpVmVyc2lvbjogYXBwcy92MQ=="},
+			},
+			"values": map[string]interface{}{"replicaCount": 1, "image": map[string]interface{}{"tag": "2.11.0"}},
+		},
+		"config":   map[string]interface{}{"replicaCount": 3},
+		"manifest": "---\n# Source: web/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"hooks": []interface{}{
+			map[string]interface{}{"name": "web-migrate", "kind": "Job", "events": []string{"pre-upgrade"}},
+		},
+		"version":   7,
+		"namespace": "prod",
+	}
+}
+
+// encodeRelease encodes a release record like the Helm secrets driver, gzipped then base64
+// encoded
+func encodeRelease(t *testing.T, release interface{}, gzipped bool) []byte {
+	t.Helper()
+	data, err := json.Marshal(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gzipped {
+		var buf bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = buf.Bytes()
+	}
+	return []byte(base64.StdEncoding.EncodeToString(data))
+}
+
+// fixtureSecret is the release secret Helm stores revision 7 of release web in
+func fixtureSecret(payload []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh.helm.release.v1.web.v7",
+			Namespace: "prod",
+			Labels:    map[string]string{LabelOwner: "helm", LabelName: "web", LabelVersion: "7", LabelStatus: "deployed"},
+		},
+		Type: ReleaseSecretType,
+		Data: map[string][]byte{"release": payload},
+	}
+}
+
+func TestDecode(t *testing.T) {
+	release, err := Decode(fixtureSecret(encodeRelease(t, fixtureRelease(), true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Name != "web" || release.Namespace != "prod" || release.Version != 7 {
+		t.Errorf("release = %s/%s v%d, want prod/web v7", release.Namespace, release.Name, release.Version)
+	}
+	want := ChartMetadata{Name: "web", Version: "1.4.2", AppVersion: "2.11.0"}
+	if release.Chart.Metadata != want {
+		t.Errorf("chart = %+v, want %+v", release.Chart.Metadata, want)
+	}
+	if release.Info.Status != "deployed" || release.Info.Description != "Upgrade complete" {
+		t.Errorf("info = %+v, want deployed, Upgrade complete", release.Info)
+	}
+	if want := time.Date(2024, 3, 4, 17, 30, 0, 0, time.UTC); !release.Info.LastDeployed.Equal(want) {
+		t.Errorf("last deployed = %v, want %v", release.Info.LastDeployed, want)
+	}
+	if want := time.Date(2024, 3, 1, 9, 0, 0, 123456789, time.UTC); !release.Info.FirstDeployed.Equal(want) {
+		t.Errorf("first deployed = %v, want %v", release.Info.FirstDeployed, want)
+	}
+	if !release.Info.Deleted.IsZero() {
+		t.Errorf("deleted = %v, want unset", release.Info.Deleted)
+	}
+	// The user supplied values only, not the chart defaults
+	if len(release.Config) != 1 || release.Config["replicaCount"] != float64(3) {
+		t.Errorf("config = %v, want replicaCount 3", release.Config)
+	}
+	if !strings.Contains(release.Manifest, "kind: Deployment") {
+		t.Errorf("manifest = %q, want the rendered deployment", release.Manifest)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	noName := fixtureRelease()
+	delete(noName, "name")
+
+	tests := []struct {
+		name    string
+		secret  func() *corev1.Secret
+		wantErr string
+	}{
+		{
+			name: "not a release secret",
+			secret: func() *corev1.Secret {
+				secret := fixtureSecret(encodeRelease(t, fixtureRelease(), true))
+				secret.Type = corev1.SecretTypeOpaque
+				return secret
+			},
+			wantErr: "not a Helm release",
+		},
+		{
+			name:    "no release data",
+			secret:  func() *corev1.Secret { return fixtureSecret(nil) },
+			wantErr: "has no release data",
+		},
+		{
+			name:    "invalid base64",
+			secret:  func() *corev1.Secret { return fixtureSecret([]byte("H4sI%%%")) },
+			wantErr: "invalid base64",
+		},
+		{
+			name: "truncated gzip",
+			secret: func() *corev1.Secret {
+				data, _ := base64.StdEncoding.DecodeString(string(encodeRelease(t, fixtureRelease(), true)))
+				return fixtureSecret([]byte(base64.StdEncoding.EncodeToString(data[:len(data)/2])))
+			},
+			wantErr: "invalid gzip",
+		},
+		{
+			name:    "invalid JSON",
+			secret:  func() *corev1.Secret { return fixtureSecret([]byte(base64.StdEncoding.EncodeToString([]byte("{")))) },
+			wantErr: "invalid release JSON",
+		},
+		{
+			name:    "no name",
+			secret:  func() *corev1.Secret { return fixtureSecret(encodeRelease(t, noName, true)) },
+			wantErr: "release has no name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.secret())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), "prod/sh.helm.release.v1.web.v7") {
+				t.Errorf("Decode() error = %v, want it to name the secret", err)
+			}
+		})
+	}
+}
+
+func TestDecodeRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload func() []byte
+	}{
+		{name: "gzipped", payload: func() []byte { return encodeRelease(t, fixtureRelease(), true) }},
+		// Releases written before Helm compressed them
+		{name: "plain JSON", payload: func() []byte { return encodeRelease(t, fixtureRelease(), false) }},
+		{name: "trailing newline", payload: func() []byte { return append(encodeRelease(t, fixtureRelease(), true), '\n') }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := DecodeRelease(tt.payload())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.Name != "web" || release.Version != 7 {
+				t.Errorf("DecodeRelease() = %s v%d, want web v7", release.Name, release.Version)
+			}
+		})
+	}
+}
+
+func TestDecodeReleaseTooLarge(t *testing.T) {
+	// A gzip bomb, a small payload expanding past the limit
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(make([]byte, maxReleaseSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := DecodeRelease([]byte(base64.StdEncoding.EncodeToString(buf.Bytes())))
+	if err == nil || !strings.Contains(err.Error(), "release exceeds") {
+		t.Fatalf("DecodeRelease() error = %v, want the size limit exceeded", err)
+	}
+}
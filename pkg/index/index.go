@@ -0,0 +1,60 @@
+// Package index defines the custom cache indexers registered on informers so lookups such
+// as "pods on a node" or "pods with app=web" avoid scanning the whole cache.
+package index
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// NodeIndex indexes pods by spec.nodeName
+	NodeIndex = "node"
+	// LabelIndexPrefix prefixes label indexes, e.g. "label:app", keyed by LabelKey
+	LabelIndexPrefix = "label:"
+)
+
+// LabelIndexName returns the index name for a label key
+func LabelIndexName(labelKey string) string {
+	return LabelIndexPrefix + labelKey
+}
+
+// LabelKey returns the index key of a label value within a namespace
+func LabelKey(namespace, value string) string {
+	return namespace + "/" + value
+}
+
+// PodIndexers returns the node index plus one namespace-scoped index per label key
+func PodIndexers(labelKeys []string) cache.Indexers {
+	indexers := cache.Indexers{NodeIndex: podNode}
+	for _, key := range labelKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			indexers[LabelIndexName(key)] = podLabel(key)
+		}
+	}
+	return indexers
+}
+
+func podNode(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+func podLabel(labelKey string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		pod, ok := obj.(*v1.Pod)
+		if !ok {
+			return nil, nil
+		}
+		value, ok := pod.Labels[labelKey]
+		if !ok {
+			return nil, nil
+		}
+		return []string{LabelKey(pod.Namespace, value)}, nil
+	}
+}
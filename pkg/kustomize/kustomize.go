@@ -0,0 +1,200 @@
+// Package kustomize builds kustomizations uploaded to the server, in memory. Remote bases
+// are refused unless their host is allowed, since fetching them makes the server reach out
+// to wherever a request points it.
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// root is where the uploaded tree is placed in the in-memory filesystem
+const root = "/kustomize"
+
+// ErrRemoteNotAllowed is returned for a kustomization referring to a remote base or
+// resource on a host that isn't allowed
+var ErrRemoteNotAllowed = errors.New("remote reference not allowed")
+
+// ErrInvalidPath is returned for a file or directory outside the uploaded tree
+var ErrInvalidPath = errors.New("invalid path")
+
+// BuildError is a kustomize build failure, its message as kustomize reported it
+type BuildError struct {
+	Err error
+}
+
+func (e *BuildError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// Builder builds kustomizations
+type Builder struct {
+	allowedHosts []string
+}
+
+// NewBuilder refuses remote bases and resources except those on allowedHosts, matched as
+// path.Match patterns against the host name
+func NewBuilder(allowedHosts []string) (*Builder, error) {
+	for _, pattern := range allowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+		}
+	}
+	return &Builder{allowedHosts: allowedHosts}, nil
+}
+
+// Build runs the kustomization in dir, relative to the root of files, which map paths to
+// their content, and returns the resources as multi-document YAML. Failures of the build
+// itself are returned as a *BuildError.
+func (b *Builder) Build(files map[string][]byte, dir string) ([]byte, error) {
+	tree := map[string][]byte{}
+	for name, data := range files {
+		clean, err := cleanPath(name)
+		if err != nil {
+			return nil, err
+		}
+		tree[clean] = data
+	}
+	dir, err := cleanPath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := b.checkRemotes(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remote bases are cloned to disk, so builds needing them can't run in memory
+	fs, base := filesys.MakeFsInMemory(), root
+	if remote {
+		tmp, err := os.MkdirTemp("", "kustomize-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create build directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		fs, base = filesys.MakeFsOnDisk(), tmp
+	}
+	for name, data := range tree {
+		target := filepath.Join(base, filepath.FromSlash(name))
+		if err := fs.MkdirAll(filepath.Dir(target)); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := fs.WriteFile(target, data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, filepath.Join(base, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, &BuildError{Err: err}
+	}
+	out, err := resources.AsYaml()
+	if err != nil {
+		return nil, &BuildError{Err: err}
+	}
+	return out, nil
+}
+
+// checkRemotes fails with a *BuildError on the remote references of the tree's
+// kustomizations to hosts that aren't allowed, and reports whether there are any.
+// References made by remote bases themselves are only known once fetched, and aren't checked.
+func (b *Builder) checkRemotes(tree map[string][]byte) (bool, error) {
+	remote := false
+	for name, data := range tree {
+		if !slices.Contains(konfig.RecognizedKustomizationFileNames(), path.Base(name)) {
+			continue
+		}
+
+		var kustomization struct {
+			Resources  []string `json:"resources"`
+			Bases      []string `json:"bases"`
+			Components []string `json:"components"`
+		}
+		if err := yaml.Unmarshal(data, &kustomization); err != nil {
+			return false, &BuildError{Err: fmt.Errorf("invalid %s: %w", name, err)}
+		}
+
+		for _, ref := range slices.Concat(kustomization.Resources, kustomization.Bases, kustomization.Components) {
+			host, ok := remoteHost(ref)
+			if !ok || inTree(tree, path.Join(path.Dir(name), ref)) {
+				continue
+			}
+			if !b.allowed(host) {
+				return false, &BuildError{Err: fmt.Errorf("%w: %s refers to %s, on host %s", ErrRemoteNotAllowed, name, ref, host)}
+			}
+			remote = true
+		}
+	}
+	return remote, nil
+}
+
+func (b *Builder) allowed(host string) bool {
+	for _, pattern := range b.allowedHosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost returns the host of a reference to a remote base or resource, in any of the
+// forms kustomize accepts: URLs, git@host:repo and host/org/repo
+func remoteHost(ref string) (string, bool) {
+	ref = strings.TrimPrefix(ref, "git::")
+	if strings.Contains(ref, "://") {
+		u, err := url.Parse(ref)
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+		return u.Hostname(), true
+	}
+	if rest, ok := strings.CutPrefix(ref, "git@"); ok {
+		host, _, _ := strings.Cut(rest, ":")
+		return host, true
+	}
+	host, _, found := strings.Cut(ref, "/")
+	if !found || strings.HasPrefix(host, ".") || !strings.Contains(host, ".") {
+		return "", false
+	}
+	return host, true
+}
+
+// inTree reports whether name is a file or directory of the tree, making a reference
+// that looks like a remote one local
+func inTree(tree map[string][]byte, name string) bool {
+	name = path.Clean(name)
+	for file := range tree {
+		if file == name || strings.HasPrefix(file, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath makes name relative to the tree's root, refusing paths that leave it
+func cleanPath(name string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %s leaves the kustomization root", ErrInvalidPath, name)
+	}
+	if clean == "" {
+		clean = "."
+	}
+	return clean, nil
+}
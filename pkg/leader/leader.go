@@ -0,0 +1,123 @@
+// Package leader runs background components on a single replica using a Lease based
+// leader election. When disabled the local replica always acts as leader.
+package leader
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var isLeader = metrics.NewGauge("kgent_leader_is_leader",
+	"Whether this replica runs the background components (1) or not (0).")
+
+// Options configures the election
+type Options struct {
+	Enabled   bool
+	Namespace string
+	LeaseName string
+	Identity  string
+}
+
+// Elector tracks whether this replica currently holds the lease
+type Elector struct {
+	client  kubernetes.Interface
+	opts    Options
+	leading atomic.Bool
+
+	mu     sync.RWMutex
+	leader string
+}
+
+func NewElector(client kubernetes.Interface, opts Options) *Elector {
+	return &Elector{client: client, opts: opts}
+}
+
+func (e *Elector) Enabled() bool {
+	return e.opts.Enabled
+}
+
+func (e *Elector) Identity() string {
+	return e.opts.Identity
+}
+
+// IsLeader reports whether this replica is currently running the background components
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// Leader returns the identity of the last observed leader
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.leading.Store(leading)
+	if leading {
+		isLeader.Set(1)
+	} else {
+		isLeader.Set(0)
+	}
+}
+
+// Run blocks until ctx is cancelled, calling start with a leadership-scoped context whenever
+// this replica becomes leader. The lease is released on cancellation so another replica
+// can take over immediately. Losing the lease for any other reason exits the process, so
+// background work never keeps running on a replica that is no longer leader.
+func (e *Elector) Run(ctx context.Context, start func(ctx context.Context)) {
+	if !e.opts.Enabled {
+		e.mu.Lock()
+		e.leader = e.opts.Identity
+		e.mu.Unlock()
+
+		e.setLeading(true)
+		start(ctx)
+		<-ctx.Done()
+		e.setLeading(false)
+		return
+	}
+
+	e.setLeading(false)
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: e.opts.LeaseName, Namespace: e.opts.Namespace},
+			Client:     e.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: e.opts.Identity},
+		},
+		Name:            e.opts.LeaseName,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("Acquired leader lease %s/%s as %s", e.opts.Namespace, e.opts.LeaseName, e.opts.Identity)
+				e.setLeading(true)
+				start(ctx)
+			},
+			OnStoppedLeading: func() {
+				e.setLeading(false)
+				if ctx.Err() == nil {
+					log.Fatalf("Lost leader lease %s/%s", e.opts.Namespace, e.opts.LeaseName)
+				}
+				log.Printf("Released leader lease %s/%s", e.opts.Namespace, e.opts.LeaseName)
+			},
+			OnNewLeader: func(identity string) {
+				e.mu.Lock()
+				e.leader = identity
+				e.mu.Unlock()
+			},
+		},
+	})
+}
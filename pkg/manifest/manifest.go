@@ -0,0 +1,191 @@
+// Package manifest reads Kubernetes manifests out of gzipped tarballs, such as the output of
+// kustomize build or a Git checkout, entirely in memory and within size limits.
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Default limits of ReadArchive
+const (
+	DefaultMaxFileSize  = 1 << 20
+	DefaultMaxTotalSize = 20 << 20
+	DefaultMaxFiles     = 1000
+)
+
+// ErrTooLarge is returned when an archive exceeds its limits
+var ErrTooLarge = errors.New("archive exceeds size limits")
+
+// Limits bound what ReadArchive extracts. Zero fields take the defaults.
+type Limits struct {
+	// MaxFileSize bounds each manifest file
+	MaxFileSize int64
+	// MaxTotalSize bounds all manifest files together
+	MaxTotalSize int64
+	MaxFiles     int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultMaxFileSize
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultMaxTotalSize
+	}
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxFiles
+	}
+	return l
+}
+
+// File is a regular file of an archive. Data is only read for manifests; other files carry
+// a Note saying why they were skipped.
+type File struct {
+	Path string
+	Data []byte
+	Note string
+}
+
+// Manifest reports whether the file holds manifests
+func (f File) Manifest() bool {
+	return f.Note == ""
+}
+
+// ReadArchive extracts the regular files of a gzipped tarball, in archive order. Only .yaml,
+// .yml and .json files are read; an archive with files over the limits fails with ErrTooLarge.
+func ReadArchive(r io.Reader, limits Limits) ([]File, error) {
+	return readArchive(r, limits, func(name string) string {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return "skipped, not a YAML or JSON file"
+		}
+		if strings.HasPrefix(path.Base(name), ".") {
+			return "skipped, hidden file"
+		}
+		return ""
+	})
+}
+
+// ReadTree extracts every regular file of a gzipped tarball, in archive order, for builds
+// that read files other than manifests, such as kustomize generators
+func ReadTree(r io.Reader, limits Limits) ([]File, error) {
+	return readArchive(r, limits, func(string) string { return "" })
+}
+
+// readArchive extracts the regular files of a gzipped tarball, reading those skip returns
+// no note for
+func readArchive(r io.Reader, limits Limits, skip func(name string) string) ([]File, error) {
+	limits = limits.withDefaults()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	var files []File
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if len(files) >= limits.MaxFiles {
+			return nil, fmt.Errorf("%w: more than %d files", ErrTooLarge, limits.MaxFiles)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if note := skip(name); note != "" {
+			files = append(files, File{Path: name, Note: note})
+			continue
+		}
+
+		if header.Size > limits.MaxFileSize {
+			return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrTooLarge, name, header.Size, limits.MaxFileSize)
+		}
+		if total += header.Size; total > limits.MaxTotalSize {
+			return nil, fmt.Errorf("%w: files exceed %d bytes", ErrTooLarge, limits.MaxTotalSize)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, File{Path: name, Data: data})
+	}
+}
+
+// Decode parses the YAML documents or JSON objects of a manifest file. Empty documents are
+// skipped and List objects expanded into their items.
+func Decode(data []byte) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for i := 1; ; i++ {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", i, err)
+			}
+			for j := range list.Items {
+				objects = append(objects, &list.Items[j])
+			}
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("document %d: apiVersion and kind are required", i)
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// kindPriority orders the kinds others depend on first, everything else after
+var kindPriority = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+}
+
+// Priority is the apply order of a kind, lower first
+func Priority(kind string) int {
+	if p, ok := kindPriority[kind]; ok {
+		return p
+	}
+	return len(kindPriority)
+}
+
+// SortByPriority orders objects for applying: Namespaces, then CustomResourceDefinitions, then
+// the rest, each in their original order
+func SortByPriority[T any](items []T, kind func(T) string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return Priority(kind(items[i])) < Priority(kind(items[j]))
+	})
+}
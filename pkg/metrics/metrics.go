@@ -0,0 +1,241 @@
+// Package metrics is a minimal registry of labelled counters, gauges and histograms rendered in the
+// Prometheus text exposition format, enough for kgent-api's own operational metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metric struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	// buckets are the sorted upper bounds of a histogram
+	buckets []float64
+
+	mu         sync.Mutex
+	values     map[string]float64
+	histograms map[string]*histogram
+}
+
+// histogram holds cumulative bucket counts for one label set
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*metric{}
+)
+
+func register(name, help, kind string, labelNames []string) *metric {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, ok := registry[name]; ok {
+		return existing
+	}
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     map[string]float64{},
+		histograms: map[string]*histogram{},
+	}
+	registry[name] = m
+	return m
+}
+
+// key renders the label set, in declaration order, as it appears in the exposition
+func (m *metric) key(labelValues []string) string {
+	if len(m.labelNames) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(m.labelNames))
+	for i, name := range m.labelNames {
+		value := ""
+		if i < len(labelValues) {
+			value = labelValues[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *metric) add(delta float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] += delta
+	m.mu.Unlock()
+}
+
+func (m *metric) set(value float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] = value
+	m.mu.Unlock()
+}
+
+// Counter is a monotonically increasing value per label set
+type Counter struct{ m *metric }
+
+// NewCounter registers a counter. Registering the same name twice returns the same counter.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{m: register(name, help, "counter", labelNames)}
+}
+
+// Inc adds one to the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+// Add adds delta, which must not be negative, to the counter for the given label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.m.add(delta, labelValues)
+}
+
+// Gauge is a value per label set that can go up and down
+type Gauge struct{ m *metric }
+
+// NewGauge registers a gauge. Registering the same name twice returns the same gauge.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{m: register(name, help, "gauge", labelNames)}
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.m.set(value, labelValues)
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.m.add(1, labelValues)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.m.add(-1, labelValues)
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.m.add(delta, labelValues)
+}
+
+// DefBuckets are the default histogram buckets in seconds, the same as the Prometheus client's
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets per label set
+type Histogram struct{ m *metric }
+
+// NewHistogram registers a histogram with the given bucket upper bounds, DefBuckets when
+// empty. Registering the same name twice returns the same histogram.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	m := register(name, help, "histogram", labelNames)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets == nil {
+		if len(buckets) == 0 {
+			buckets = DefBuckets
+		}
+		m.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(m.buckets)
+	}
+	return &Histogram{m: m}
+}
+
+// Observe records value for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.m.key(labelValues)
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+
+	hist, ok := h.m.histograms[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.m.buckets))}
+		h.m.histograms[key] = hist
+	}
+	for i, bound := range h.m.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += value
+	hist.count++
+}
+
+// withLabel adds a label to a rendered label set
+func withLabel(key, name, value string) string {
+	label := fmt.Sprintf("%s=%q", name, value)
+	if key == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + label + "}"
+}
+
+// writeHistograms renders the buckets, sum and count of every label set; m.mu must be held
+func (m *metric) writeHistograms(w io.Writer) {
+	keys := make([]string, 0, len(m.histograms))
+	for key := range m.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hist := m.histograms[key]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, withLabel(key, "le", strconv.FormatFloat(bound, 'g', -1, 64)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, withLabel(key, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", m.name, key, hist.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, key, hist.count)
+	}
+}
+
+// Handler serves every registered metric in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registryMu.Lock()
+		names := make([]string, 0, len(registry))
+		for name := range registry {
+			names = append(names, name)
+		}
+		registryMu.Unlock()
+		sort.Strings(names)
+
+		for _, name := range names {
+			registryMu.Lock()
+			m := registry[name]
+			registryMu.Unlock()
+
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+			m.mu.Lock()
+			if m.kind == "histogram" {
+				m.writeHistograms(w)
+				m.mu.Unlock()
+				continue
+			}
+			keys := make([]string, 0, len(m.values))
+			for key := range m.values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(w, "%s%s %g\n", m.name, key, m.values[key])
+			}
+			m.mu.Unlock()
+		}
+	})
+}
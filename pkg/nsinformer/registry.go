@@ -0,0 +1,447 @@
+// Package nsinformer shards the shared informer caches by namespace, running a typed
+// informer factory per namespace instead of one for the whole cluster. On very large
+// clusters a cluster-wide pod cache alone takes gigabytes; shards only hold the namespaces
+// that are pinned or actually queried, and lazily started ones are stopped when left idle.
+package nsinformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	// ErrNotSharded is returned for namespaces that have no shard and aren't started lazily
+	ErrNotSharded = errors.New("namespace is not cached")
+	// ErrNotCached is returned for resources the shards don't cache
+	ErrNotCached = errors.New("resource is not cached per namespace")
+)
+
+var activeShards = metrics.NewGauge("kgent_informer_shards",
+	"Namespaces with a running informer shard.")
+
+// Options configures the shards
+type Options struct {
+	// Namespaces get a shard at startup that is never stopped
+	Namespaces []string
+	// Lazy starts a shard for any other namespace on its first query
+	Lazy bool
+	// IdleTimeout stops lazily started shards that were not queried for that long, never
+	// when zero
+	IdleTimeout time.Duration
+	// Resources are the namespaced resources every shard caches
+	Resources []schema.GroupVersionResource
+	// FactoryOptions are applied to every shard's factory, after its namespace
+	FactoryOptions []informers.SharedInformerOption
+	// Indexers are added to the informer of their resource in every shard
+	Indexers map[schema.GroupVersionResource]cache.Indexers
+}
+
+// Info describes a running shard
+type Info struct {
+	Namespace  string    `json:"namespace"`
+	Pinned     bool      `json:"pinned"`
+	HasSynced  bool      `json:"hasSynced"`
+	Objects    int       `json:"objects"`
+	StartedAt  time.Time `json:"startedAt"`
+	Age        string    `json:"age"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+type shard struct {
+	factory    informers.SharedInformerFactory
+	informers  map[schema.GroupVersionResource]informers.GenericInformer
+	stop       chan struct{}
+	pinned     bool
+	startedAt  time.Time
+	lastAccess atomic.Int64
+}
+
+func (s *shard) touch() {
+	s.lastAccess.Store(time.Now().UnixNano())
+}
+
+func (s *shard) hasSynced() bool {
+	for _, informer := range s.informers {
+		if !informer.Informer().HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *shard) info(namespace string) Info {
+	objects := 0
+	for _, informer := range s.informers {
+		objects += len(informer.Informer().GetStore().ListKeys())
+	}
+	return Info{
+		Namespace:  namespace,
+		Pinned:     s.pinned,
+		HasSynced:  s.hasSynced(),
+		Objects:    objects,
+		StartedAt:  s.startedAt,
+		Age:        duration.HumanDuration(time.Since(s.startedAt)),
+		LastAccess: time.Unix(0, s.lastAccess.Load()),
+	}
+}
+
+// registration is an event handler added to the informer of its resource in every shard,
+// the running ones and those started later
+type registration struct {
+	registry *Registry
+	gvr      schema.GroupVersionResource
+	handler  cache.ResourceEventHandler
+	resync   time.Duration
+	// shards holds the handler's registration with each running shard; guarded by the
+	// registry's mu
+	shards map[*shard]cache.ResourceEventHandlerRegistration
+}
+
+// HasSynced reports whether the handler has been delivered the initial list of every
+// running shard
+func (h *registration) HasSynced() bool {
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+
+	for _, registration := range h.shards {
+		if !registration.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry owns the factory and stop channel of every shard
+type Registry struct {
+	client  kubernetes.Interface
+	options Options
+	cached  map[schema.GroupVersionResource]bool
+
+	mu       sync.Mutex
+	shards   map[string]*shard
+	handlers map[schema.GroupVersionResource][]*registration
+}
+
+// NewRegistry creates the shards of options.Namespaces, without waiting for them to sync
+func NewRegistry(client kubernetes.Interface, options Options) (*Registry, error) {
+	r := &Registry{
+		client:   client,
+		options:  options,
+		cached:   map[schema.GroupVersionResource]bool{},
+		shards:   map[string]*shard{},
+		handlers: map[schema.GroupVersionResource][]*registration{},
+	}
+	for _, gvr := range options.Resources {
+		r.cached[gvr] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, namespace := range options.Namespaces {
+		if _, err := r.start(namespace, true); err != nil {
+			r.stopAllLocked()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Caches reports whether shards cache gvr
+func (r *Registry) Caches(gvr schema.GroupVersionResource) bool {
+	return r.cached[gvr]
+}
+
+// start runs a shard for namespace; the caller holds mu
+func (r *Registry) start(namespace string, pinned bool) (*shard, error) {
+	options := append([]informers.SharedInformerOption{informers.WithNamespace(namespace)}, r.options.FactoryOptions...)
+	factory := informers.NewSharedInformerFactoryWithOptions(r.client, 0, options...)
+
+	s := &shard{
+		factory:   factory,
+		informers: map[schema.GroupVersionResource]informers.GenericInformer{},
+		stop:      make(chan struct{}),
+		pinned:    pinned,
+		startedAt: time.Now(),
+	}
+	for _, gvr := range r.options.Resources {
+		informer, err := factory.ForResource(gvr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create informer for %s in %s: %w", gvr.Resource, namespace, err)
+		}
+		s.informers[gvr] = informer
+	}
+	for gvr, indexers := range r.options.Indexers {
+		if informer, ok := s.informers[gvr]; ok {
+			if err := informer.Informer().AddIndexers(indexers); err != nil {
+				return nil, fmt.Errorf("failed to add indexers for %s in %s: %w", gvr.Resource, namespace, err)
+			}
+		}
+	}
+	for gvr, handlers := range r.handlers {
+		for _, h := range handlers {
+			registration, err := s.informers[gvr].Informer().AddEventHandlerWithResyncPeriod(h.handler, h.resync)
+			if err != nil {
+				r.forgetLocked(s)
+				return nil, fmt.Errorf("failed to add event handler for %s in %s: %w", gvr.Resource, namespace, err)
+			}
+			h.shards[s] = registration
+		}
+	}
+	s.touch()
+	factory.Start(s.stop)
+	r.shards[namespace] = s
+	activeShards.Set(float64(len(r.shards)))
+	return s, nil
+}
+
+// WaitForCacheSync waits for the pinned shards to sync until stopCh is closed, returning
+// the namespaces that haven't
+func (r *Registry) WaitForCacheSync(stopCh <-chan struct{}) []string {
+	r.mu.Lock()
+	pinned := map[string]*shard{}
+	for namespace, s := range r.shards {
+		if s.pinned {
+			pinned[namespace] = s
+		}
+	}
+	r.mu.Unlock()
+
+	var unsynced []string
+	for namespace, s := range pinned {
+		for _, synced := range s.factory.WaitForCacheSync(stopCh) {
+			if !synced {
+				unsynced = append(unsynced, namespace)
+				break
+			}
+		}
+	}
+	sort.Strings(unsynced)
+	return unsynced
+}
+
+// Lister returns a lister for gvr in namespace from a synced shard, and marks the shard
+// as used. It never starts a shard.
+func (r *Registry) Lister(gvr schema.GroupVersionResource, namespace string) (cache.GenericLister, bool) {
+	if !r.cached[gvr] {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.shards[namespace]
+	if !ok || !s.informers[gvr].Informer().HasSynced() {
+		return nil, false
+	}
+	s.touch()
+	return s.informers[gvr].Lister(), true
+}
+
+// Ensure returns a lister for gvr in namespace like Lister, starting the namespace's shard
+// first when it is started lazily and waiting until gvr has synced or ctx is done
+func (r *Registry) Ensure(ctx context.Context, gvr schema.GroupVersionResource, namespace string) (cache.GenericLister, error) {
+	informer, err := r.ensure(ctx, gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (r *Registry) ensure(ctx context.Context, gvr schema.GroupVersionResource, namespace string) (informers.GenericInformer, error) {
+	if !r.cached[gvr] {
+		return nil, ErrNotCached
+	}
+
+	r.mu.Lock()
+	s, ok := r.shards[namespace]
+	if !ok {
+		if !r.options.Lazy || namespace == "" {
+			r.mu.Unlock()
+			return nil, ErrNotSharded
+		}
+		var err error
+		if s, err = r.start(namespace, false); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		log.Printf("Started informer shard for namespace %s", namespace)
+	}
+	s.touch()
+	r.mu.Unlock()
+
+	informer := s.informers[gvr]
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		return nil, fmt.Errorf("informer for %s in %s did not sync: %w", gvr.Resource, namespace, ctx.Err())
+	}
+	return informer, nil
+}
+
+// each calls fn with the informer of gvr in every running shard, synced or not, without
+// marking the shards as used
+func (r *Registry) each(gvr schema.GroupVersionResource, fn func(cache.SharedIndexInformer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.shards {
+		fn(s.informers[gvr].Informer())
+	}
+}
+
+// addHandler adds handler to the informer of gvr in every running shard and in the shards
+// started later
+func (r *Registry) addHandler(gvr schema.GroupVersionResource, handler cache.ResourceEventHandler, resync time.Duration) (*registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := &registration{
+		registry: r,
+		gvr:      gvr,
+		handler:  handler,
+		resync:   resync,
+		shards:   map[*shard]cache.ResourceEventHandlerRegistration{},
+	}
+	for namespace, s := range r.shards {
+		registration, err := s.informers[gvr].Informer().AddEventHandlerWithResyncPeriod(handler, resync)
+		if err != nil {
+			r.removeHandlerLocked(h)
+			return nil, fmt.Errorf("failed to add event handler for %s in %s: %w", gvr.Resource, namespace, err)
+		}
+		h.shards[s] = registration
+	}
+	r.handlers[gvr] = append(r.handlers[gvr], h)
+	return h, nil
+}
+
+// removeHandler removes a handler added by addHandler from every shard
+func (r *Registry) removeHandler(h *registration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removeHandlerLocked(h)
+}
+
+// removeHandlerLocked is removeHandler; the caller holds mu
+func (r *Registry) removeHandlerLocked(h *registration) error {
+	var errs []error
+	for s, registration := range h.shards {
+		errs = append(errs, s.informers[h.gvr].Informer().RemoveEventHandler(registration))
+		delete(h.shards, s)
+	}
+	r.handlers[h.gvr] = slices.DeleteFunc(r.handlers[h.gvr], func(other *registration) bool { return other == h })
+	return errors.Join(errs...)
+}
+
+// ListAll lists gvr across every synced shard. Namespaces without a running shard are
+// missing from the result.
+func (r *Registry) ListAll(gvr schema.GroupVersionResource) ([]runtime.Object, error) {
+	if !r.cached[gvr] {
+		return nil, ErrNotCached
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var objects []runtime.Object
+	for namespace, s := range r.shards {
+		informer := s.informers[gvr]
+		if !informer.Informer().HasSynced() {
+			continue
+		}
+		s.touch()
+		list, err := informer.Lister().ByNamespace(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s in %s: %w", gvr.Resource, namespace, err)
+		}
+		objects = append(objects, list...)
+	}
+	return objects, nil
+}
+
+// List describes the running shards ordered by namespace
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]Info, 0, len(r.shards))
+	for namespace, s := range r.shards {
+		infos = append(infos, s.info(namespace))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Namespace < infos[j].Namespace })
+	return infos
+}
+
+// Run stops idle lazily started shards until ctx is cancelled, then stops all of them
+func (r *Registry) Run(ctx context.Context) {
+	interval := time.Minute
+	if r.options.IdleTimeout > 0 && r.options.IdleTimeout < interval {
+		interval = r.options.IdleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.mu.Lock()
+			r.stopAllLocked()
+			r.mu.Unlock()
+			return
+		case <-ticker.C:
+			if r.options.IdleTimeout > 0 {
+				r.evictIdle(time.Now().Add(-r.options.IdleTimeout))
+			}
+		}
+	}
+}
+
+func (r *Registry) evictIdle(cutoff time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for namespace, s := range r.shards {
+		if !s.pinned && time.Unix(0, s.lastAccess.Load()).Before(cutoff) {
+			log.Printf("Stopping informer shard for namespace %s after %s without queries", namespace, r.options.IdleTimeout)
+			r.stopLocked(namespace, s)
+		}
+	}
+	activeShards.Set(float64(len(r.shards)))
+}
+
+// stopAllLocked stops every shard; the caller holds mu
+func (r *Registry) stopAllLocked() {
+	for namespace, s := range r.shards {
+		r.stopLocked(namespace, s)
+	}
+	activeShards.Set(0)
+}
+
+// stopLocked stops the shard of namespace; the caller holds mu
+func (r *Registry) stopLocked(namespace string, s *shard) {
+	close(s.stop)
+	s.factory.Shutdown()
+	delete(r.shards, namespace)
+	r.forgetLocked(s)
+}
+
+// forgetLocked drops the handler registrations with s; the caller holds mu
+func (r *Registry) forgetLocked(s *shard) {
+	for _, handlers := range r.handlers {
+		for _, h := range handlers {
+			delete(h.shards, s)
+		}
+	}
+}
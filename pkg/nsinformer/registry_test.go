@@ -0,0 +1,225 @@
+package nsinformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	fixtureNamespaces       = 40
+	fixturePodsPerNamespace = 100
+)
+
+var podsResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+// fixtureClientset holds fixturePodsPerNamespace pods in each of fixtureNamespaces
+// namespaces, ns-0 to ns-39
+func fixtureClientset() *fake.Clientset {
+	objects := make([]runtime.Object, 0, fixtureNamespaces*fixturePodsPerNamespace)
+	for n := 0; n < fixtureNamespaces; n++ {
+		for i := 0; i < fixturePodsPerNamespace; i++ {
+			objects = append(objects, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("web-%d", i),
+				Namespace: fmt.Sprintf("ns-%d", n),
+			}})
+		}
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+func newTestRegistry(t *testing.T, client *fake.Clientset, options Options) *Registry {
+	t.Helper()
+	options.Resources = append(options.Resources, podsResource)
+	registry, err := NewRegistry(client, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		registry.stopAllLocked()
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if unsynced := registry.WaitForCacheSync(stopCh); len(unsynced) > 0 {
+		t.Fatalf("shards of %v did not sync", unsynced)
+	}
+	return registry
+}
+
+// watchedNamespaces counts the pod watches opened on client by namespace
+func watchedNamespaces(client *fake.Clientset) map[string]int {
+	watches := map[string]int{}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "watch" && action.GetResource() == podsResource {
+			watches[action.GetNamespace()]++
+		}
+	}
+	return watches
+}
+
+// TestRegistryCachesOnlyShardedNamespaces shows the trade-off sharding makes: the shards
+// hold the pinned namespaces only, a fraction of the cluster-wide cache, at the cost of a
+// watch per namespace
+func TestRegistryCachesOnlyShardedNamespaces(t *testing.T) {
+	client := fixtureClientset()
+	registry := newTestRegistry(t, client, Options{Namespaces: []string{"ns-1", "ns-2"}})
+
+	infos := registry.List()
+	if len(infos) != 2 {
+		t.Fatalf("List() = %+v, want the shards of ns-1 and ns-2", infos)
+	}
+	cached := 0
+	for _, info := range infos {
+		if !info.Pinned || !info.HasSynced || info.Objects != fixturePodsPerNamespace {
+			t.Errorf("shard %+v, want it pinned and synced with %d pods", info, fixturePodsPerNamespace)
+		}
+		cached += info.Objects
+	}
+	total := fixtureNamespaces * fixturePodsPerNamespace
+	t.Logf("%d of %d pods cached in %d shards", cached, total, len(infos))
+	if cached != 2*fixturePodsPerNamespace {
+		t.Errorf("shards cache %d pods, want the %d of their namespaces out of %d", cached, 2*fixturePodsPerNamespace, total)
+	}
+
+	watches := watchedNamespaces(client)
+	if len(watches) != 2 || watches["ns-1"] != 1 || watches["ns-2"] != 1 {
+		t.Errorf("pod watches by namespace = %v, want one each for ns-1 and ns-2", watches)
+	}
+}
+
+func TestRegistryLister(t *testing.T) {
+	registry := newTestRegistry(t, fixtureClientset(), Options{Namespaces: []string{"ns-1"}, Lazy: true})
+
+	lister, ok := registry.Lister(podsResource, "ns-1")
+	if !ok {
+		t.Fatal("Lister(ns-1) found no shard")
+	}
+	pods, err := lister.ByNamespace("ns-1").List(labels.Everything())
+	if err != nil || len(pods) != fixturePodsPerNamespace {
+		t.Errorf("Lister(ns-1) lists %d pods, %v, want %d", len(pods), err, fixturePodsPerNamespace)
+	}
+
+	// Reads never start a shard, even when lazy
+	if _, ok := registry.Lister(podsResource, "ns-3"); ok {
+		t.Error("Lister(ns-3) found a shard that was never started")
+	}
+	if _, ok := registry.Lister(corev1.SchemeGroupVersion.WithResource("configmaps"), "ns-1"); ok {
+		t.Error("Lister() found a shard for configmaps, which aren't cached")
+	}
+	if len(registry.List()) != 1 {
+		t.Errorf("List() = %+v, want ns-1 only", registry.List())
+	}
+}
+
+func TestRegistryEnsure(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   Options
+		namespace string
+		resource  string
+		wantErr   error
+		// wantShards are the shards running afterwards
+		wantShards int
+	}{
+		{name: "pinned", options: Options{Namespaces: []string{"ns-1"}}, namespace: "ns-1", resource: "pods", wantShards: 1},
+		{name: "lazily started", options: Options{Namespaces: []string{"ns-1"}, Lazy: true}, namespace: "ns-3", resource: "pods", wantShards: 2},
+		{name: "not lazy", options: Options{Namespaces: []string{"ns-1"}}, namespace: "ns-3", resource: "pods", wantErr: ErrNotSharded, wantShards: 1},
+		{name: "all namespaces", options: Options{Lazy: true}, resource: "pods", wantErr: ErrNotSharded},
+		{name: "not cached", options: Options{Lazy: true}, namespace: "ns-3", resource: "configmaps", wantErr: ErrNotCached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fixtureClientset()
+			registry := newTestRegistry(t, client, tt.options)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			lister, err := registry.Ensure(ctx, corev1.SchemeGroupVersion.WithResource(tt.resource), tt.namespace)
+			if got := len(registry.List()); got != tt.wantShards {
+				t.Errorf("%d shards running, want %d", got, tt.wantShards)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Ensure() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A lazily started shard has synced by the time Ensure returns
+			pods, err := lister.ByNamespace(tt.namespace).List(labels.Everything())
+			if err != nil || len(pods) != fixturePodsPerNamespace {
+				t.Errorf("Ensure(%s) lists %d pods, %v, want %d", tt.namespace, len(pods), err, fixturePodsPerNamespace)
+			}
+		})
+	}
+}
+
+// TestRegistryListAll shows fanned out lists leave out the namespaces without a shard
+func TestRegistryListAll(t *testing.T) {
+	registry := newTestRegistry(t, fixtureClientset(), Options{Namespaces: []string{"ns-1", "ns-2", "ns-3"}})
+
+	pods, err := registry.ListAll(podsResource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces := map[string]int{}
+	for _, pod := range pods {
+		namespaces[pod.(*corev1.Pod).Namespace]++
+	}
+	if len(pods) != 3*fixturePodsPerNamespace || len(namespaces) != 3 {
+		t.Errorf("ListAll() = %d pods in %v, want %d in ns-1, ns-2 and ns-3", len(pods), namespaces, 3*fixturePodsPerNamespace)
+	}
+
+	if _, err := registry.ListAll(corev1.SchemeGroupVersion.WithResource("configmaps")); !errors.Is(err, ErrNotCached) {
+		t.Errorf("ListAll(configmaps) error = %v, want %v", err, ErrNotCached)
+	}
+}
+
+func TestRegistryEvictIdle(t *testing.T) {
+	registry := newTestRegistry(t, fixtureClientset(), Options{Namespaces: []string{"ns-1"}, Lazy: true, IdleTimeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, namespace := range []string{"ns-2", "ns-3"} {
+		if _, err := registry.Ensure(ctx, podsResource, namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ns-3 is queried after the cutoff, ns-1 is pinned however idle
+	registry.shards["ns-1"].lastAccess.Store(time.Now().Add(-time.Hour).UnixNano())
+	registry.shards["ns-2"].lastAccess.Store(time.Now().Add(-time.Hour).UnixNano())
+	registry.evictIdle(time.Now().Add(-time.Minute))
+
+	var running []string
+	for _, info := range registry.List() {
+		running = append(running, info.Namespace)
+	}
+	if fmt.Sprint(running) != "[ns-1 ns-3]" {
+		t.Errorf("shards running after eviction = %v, want [ns-1 ns-3]", running)
+	}
+	if _, ok := registry.Lister(podsResource, "ns-2"); ok {
+		t.Error("Lister(ns-2) still serves an evicted shard")
+	}
+
+	// An evicted namespace is started again on its next query
+	lister, err := registry.Ensure(ctx, podsResource, "ns-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods, _ := lister.ByNamespace("ns-2").List(labels.Everything()); len(pods) != fixturePodsPerNamespace {
+		t.Errorf("restarted shard lists %d pods, want %d", len(pods), fixturePodsPerNamespace)
+	}
+}
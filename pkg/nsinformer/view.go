@@ -0,0 +1,521 @@
+package nsinformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/informers/apps"
+	appsv1informers "k8s.io/client-go/informers/apps/v1"
+	"k8s.io/client-go/informers/batch"
+	batchv1informers "k8s.io/client-go/informers/batch/v1"
+	"k8s.io/client-go/informers/core"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/informers/policy"
+	policyv1informers "k8s.io/client-go/informers/policy/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1listers "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// viewSyncTimeout bounds how long a read through the view waits for a lazily started shard
+// to sync. Reads that give up fall back to what the shard has cached so far.
+var viewSyncTimeout = 10 * time.Second
+
+// errReadOnly is returned by the writes and settings the shards' caches don't take from readers
+var errReadOnly = errors.New("the informers of sharded resources are managed by their shards")
+
+// Factory returns a factory that serves the resources the shards cache from the shards and
+// every other one from fact, so readers of a shared factory don't need fact to cache the
+// namespaced resources of every namespace too. A read of one namespace starts its shard when
+// shards are started lazily and reads it alone; reads across namespaces see the running
+// shards and don't keep them from being stopped. Event handlers are added to every shard,
+// including those started later.
+func (r *Registry) Factory(fact informers.SharedInformerFactory) informers.SharedInformerFactory {
+	v := &view{SharedInformerFactory: fact, informers: map[schema.GroupVersionResource]*informer{}}
+	for _, gvr := range r.options.Resources {
+		v.informers[gvr] = &informer{registry: r, gvr: gvr, indexer: &indexer{registry: r, gvr: gvr}}
+	}
+	return v
+}
+
+// view is the factory Factory returns
+type view struct {
+	informers.SharedInformerFactory
+	informers map[schema.GroupVersionResource]*informer
+}
+
+func (v *view) ForResource(gvr schema.GroupVersionResource) (informers.GenericInformer, error) {
+	if i, ok := v.informers[gvr]; ok {
+		return genericInformer{i}, nil
+	}
+	return v.SharedInformerFactory.ForResource(gvr)
+}
+
+func (v *view) Core() core.Interface {
+	return coreGroup{v.SharedInformerFactory.Core(), v}
+}
+
+func (v *view) Apps() apps.Interface {
+	return appsGroup{v.SharedInformerFactory.Apps(), v}
+}
+
+func (v *view) Batch() batch.Interface {
+	return batchGroup{v.SharedInformerFactory.Batch(), v}
+}
+
+func (v *view) Policy() policy.Interface {
+	return policyGroup{v.SharedInformerFactory.Policy(), v}
+}
+
+type coreGroup struct {
+	core.Interface
+	view *view
+}
+
+func (g coreGroup) V1() corev1informers.Interface {
+	return coreV1{g.Interface.V1(), g.view}
+}
+
+type coreV1 struct {
+	corev1informers.Interface
+	view *view
+}
+
+func (v coreV1) Pods() corev1informers.PodInformer {
+	if i, ok := v.view.informers[corev1.SchemeGroupVersion.WithResource("pods")]; ok {
+		return podInformer{i}
+	}
+	return v.Interface.Pods()
+}
+
+func (v coreV1) Services() corev1informers.ServiceInformer {
+	if i, ok := v.view.informers[corev1.SchemeGroupVersion.WithResource("services")]; ok {
+		return serviceInformer{i}
+	}
+	return v.Interface.Services()
+}
+
+func (v coreV1) ConfigMaps() corev1informers.ConfigMapInformer {
+	if i, ok := v.view.informers[corev1.SchemeGroupVersion.WithResource("configmaps")]; ok {
+		return configMapInformer{i}
+	}
+	return v.Interface.ConfigMaps()
+}
+
+func (v coreV1) Secrets() corev1informers.SecretInformer {
+	if i, ok := v.view.informers[corev1.SchemeGroupVersion.WithResource("secrets")]; ok {
+		return secretInformer{i}
+	}
+	return v.Interface.Secrets()
+}
+
+func (v coreV1) PersistentVolumeClaims() corev1informers.PersistentVolumeClaimInformer {
+	if i, ok := v.view.informers[corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims")]; ok {
+		return persistentVolumeClaimInformer{i}
+	}
+	return v.Interface.PersistentVolumeClaims()
+}
+
+type appsGroup struct {
+	apps.Interface
+	view *view
+}
+
+func (g appsGroup) V1() appsv1informers.Interface {
+	return appsV1{g.Interface.V1(), g.view}
+}
+
+type appsV1 struct {
+	appsv1informers.Interface
+	view *view
+}
+
+func (v appsV1) Deployments() appsv1informers.DeploymentInformer {
+	if i, ok := v.view.informers[appsv1.SchemeGroupVersion.WithResource("deployments")]; ok {
+		return deploymentInformer{i}
+	}
+	return v.Interface.Deployments()
+}
+
+func (v appsV1) ReplicaSets() appsv1informers.ReplicaSetInformer {
+	if i, ok := v.view.informers[appsv1.SchemeGroupVersion.WithResource("replicasets")]; ok {
+		return replicaSetInformer{i}
+	}
+	return v.Interface.ReplicaSets()
+}
+
+func (v appsV1) StatefulSets() appsv1informers.StatefulSetInformer {
+	if i, ok := v.view.informers[appsv1.SchemeGroupVersion.WithResource("statefulsets")]; ok {
+		return statefulSetInformer{i}
+	}
+	return v.Interface.StatefulSets()
+}
+
+func (v appsV1) DaemonSets() appsv1informers.DaemonSetInformer {
+	if i, ok := v.view.informers[appsv1.SchemeGroupVersion.WithResource("daemonsets")]; ok {
+		return daemonSetInformer{i}
+	}
+	return v.Interface.DaemonSets()
+}
+
+type batchGroup struct {
+	batch.Interface
+	view *view
+}
+
+func (g batchGroup) V1() batchv1informers.Interface {
+	return batchV1{g.Interface.V1(), g.view}
+}
+
+type batchV1 struct {
+	batchv1informers.Interface
+	view *view
+}
+
+func (v batchV1) Jobs() batchv1informers.JobInformer {
+	if i, ok := v.view.informers[batchv1.SchemeGroupVersion.WithResource("jobs")]; ok {
+		return jobInformer{i}
+	}
+	return v.Interface.Jobs()
+}
+
+func (v batchV1) CronJobs() batchv1informers.CronJobInformer {
+	if i, ok := v.view.informers[batchv1.SchemeGroupVersion.WithResource("cronjobs")]; ok {
+		return cronJobInformer{i}
+	}
+	return v.Interface.CronJobs()
+}
+
+type policyGroup struct {
+	policy.Interface
+	view *view
+}
+
+func (g policyGroup) V1() policyv1informers.Interface {
+	return policyV1{g.Interface.V1(), g.view}
+}
+
+type policyV1 struct {
+	policyv1informers.Interface
+	view *view
+}
+
+func (v policyV1) PodDisruptionBudgets() policyv1informers.PodDisruptionBudgetInformer {
+	if i, ok := v.view.informers[policyv1.SchemeGroupVersion.WithResource("poddisruptionbudgets")]; ok {
+		return podDisruptionBudgetInformer{i}
+	}
+	return v.Interface.PodDisruptionBudgets()
+}
+
+// The typed informers of the sharded resources, whose listers read the shards
+type (
+	genericInformer               struct{ *informer }
+	podInformer                   struct{ *informer }
+	serviceInformer               struct{ *informer }
+	configMapInformer             struct{ *informer }
+	secretInformer                struct{ *informer }
+	persistentVolumeClaimInformer struct{ *informer }
+	deploymentInformer            struct{ *informer }
+	replicaSetInformer            struct{ *informer }
+	statefulSetInformer           struct{ *informer }
+	daemonSetInformer             struct{ *informer }
+	jobInformer                   struct{ *informer }
+	cronJobInformer               struct{ *informer }
+	podDisruptionBudgetInformer   struct{ *informer }
+)
+
+func (i genericInformer) Lister() cache.GenericLister {
+	return cache.NewGenericLister(i.indexer, i.gvr.GroupResource())
+}
+
+func (i podInformer) Lister() corev1listers.PodLister {
+	return corev1listers.NewPodLister(i.indexer)
+}
+
+func (i serviceInformer) Lister() corev1listers.ServiceLister {
+	return corev1listers.NewServiceLister(i.indexer)
+}
+
+func (i configMapInformer) Lister() corev1listers.ConfigMapLister {
+	return corev1listers.NewConfigMapLister(i.indexer)
+}
+
+func (i secretInformer) Lister() corev1listers.SecretLister {
+	return corev1listers.NewSecretLister(i.indexer)
+}
+
+func (i persistentVolumeClaimInformer) Lister() corev1listers.PersistentVolumeClaimLister {
+	return corev1listers.NewPersistentVolumeClaimLister(i.indexer)
+}
+
+func (i deploymentInformer) Lister() appsv1listers.DeploymentLister {
+	return appsv1listers.NewDeploymentLister(i.indexer)
+}
+
+func (i replicaSetInformer) Lister() appsv1listers.ReplicaSetLister {
+	return appsv1listers.NewReplicaSetLister(i.indexer)
+}
+
+func (i statefulSetInformer) Lister() appsv1listers.StatefulSetLister {
+	return appsv1listers.NewStatefulSetLister(i.indexer)
+}
+
+func (i daemonSetInformer) Lister() appsv1listers.DaemonSetLister {
+	return appsv1listers.NewDaemonSetLister(i.indexer)
+}
+
+func (i jobInformer) Lister() batchv1listers.JobLister {
+	return batchv1listers.NewJobLister(i.indexer)
+}
+
+func (i cronJobInformer) Lister() batchv1listers.CronJobLister {
+	return batchv1listers.NewCronJobLister(i.indexer)
+}
+
+func (i podDisruptionBudgetInformer) Lister() policyv1listers.PodDisruptionBudgetLister {
+	return policyv1listers.NewPodDisruptionBudgetLister(i.indexer)
+}
+
+// informer is the SharedIndexInformer of a sharded resource. It is never run itself, the
+// registry runs the informers of the shards.
+type informer struct {
+	registry *Registry
+	gvr      schema.GroupVersionResource
+	indexer  *indexer
+}
+
+func (i *informer) Informer() cache.SharedIndexInformer {
+	return i
+}
+
+func (i *informer) AddEventHandler(handler cache.ResourceEventHandler) (cache.ResourceEventHandlerRegistration, error) {
+	return i.registry.addHandler(i.gvr, handler, 0)
+}
+
+func (i *informer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) (cache.ResourceEventHandlerRegistration, error) {
+	return i.registry.addHandler(i.gvr, handler, resyncPeriod)
+}
+
+func (i *informer) RemoveEventHandler(handle cache.ResourceEventHandlerRegistration) error {
+	h, ok := handle.(*registration)
+	if !ok || h.registry != i.registry || h.gvr != i.gvr {
+		return fmt.Errorf("registration was not added to the informer of %s", i.gvr.Resource)
+	}
+	return i.registry.removeHandler(h)
+}
+
+func (i *informer) GetStore() cache.Store {
+	return i.indexer
+}
+
+func (i *informer) GetIndexer() cache.Indexer {
+	return i.indexer
+}
+
+// GetController returns nil, there is no single controller behind the shards
+func (i *informer) GetController() cache.Controller {
+	return nil
+}
+
+// Run does nothing, the registry runs the shards
+func (i *informer) Run(stopCh <-chan struct{}) {}
+
+// HasStarted reports the informer as running so callers don't run it
+func (i *informer) HasStarted() bool {
+	return true
+}
+
+// HasSynced reports whether every running shard has synced
+func (i *informer) HasSynced() bool {
+	synced := true
+	i.registry.each(i.gvr, func(informer cache.SharedIndexInformer) {
+		synced = synced && informer.HasSynced()
+	})
+	return synced
+}
+
+// LastSyncResourceVersion returns an empty version, each shard has its own
+func (i *informer) LastSyncResourceVersion() string {
+	return ""
+}
+
+func (i *informer) SetWatchErrorHandler(cache.WatchErrorHandler) error {
+	return errReadOnly
+}
+
+func (i *informer) SetTransform(cache.TransformFunc) error {
+	return errReadOnly
+}
+
+func (i *informer) IsStopped() bool {
+	return false
+}
+
+// AddIndexers fails, indexers are set for every shard through Options.Indexers
+func (i *informer) AddIndexers(cache.Indexers) error {
+	return errReadOnly
+}
+
+// indexer reads the caches of a sharded resource. Reads of one namespace, by key or by the
+// namespace index, go to that namespace's shard; every other read merges the running ones.
+type indexer struct {
+	registry *Registry
+	gvr      schema.GroupVersionResource
+}
+
+// shard returns the indexer of gvr in namespace, or nil if the namespace has no shard
+func (x *indexer) shard(namespace string) (cache.Indexer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), viewSyncTimeout)
+	defer cancel()
+
+	informer, err := x.registry.ensure(ctx, x.gvr, namespace)
+	if errors.Is(err, ErrNotSharded) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return informer.Informer().GetIndexer(), nil
+}
+
+// merge collects the values read from every running shard
+func (x *indexer) merge(read func(cache.Indexer) ([]interface{}, error)) ([]interface{}, error) {
+	var items []interface{}
+	var errs []error
+	x.registry.each(x.gvr, func(informer cache.SharedIndexInformer) {
+		list, err := read(informer.GetIndexer())
+		items = append(items, list...)
+		errs = append(errs, err)
+	})
+	return items, errors.Join(errs...)
+}
+
+func (x *indexer) Add(interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Update(interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Delete(interface{}) error {
+	return errReadOnly
+}
+
+func (x *indexer) Replace([]interface{}, string) error {
+	return errReadOnly
+}
+
+func (x *indexer) Resync() error {
+	return nil
+}
+
+func (x *indexer) List() []interface{} {
+	items, _ := x.merge(func(shard cache.Indexer) ([]interface{}, error) {
+		return shard.List(), nil
+	})
+	return items
+}
+
+func (x *indexer) ListKeys() []string {
+	var keys []string
+	x.registry.each(x.gvr, func(informer cache.SharedIndexInformer) {
+		keys = append(keys, informer.GetStore().ListKeys()...)
+	})
+	return keys
+}
+
+func (x *indexer) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return x.GetByKey(key)
+}
+
+func (x *indexer) GetByKey(key string) (interface{}, bool, error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	shard, err := x.shard(namespace)
+	if shard == nil || err != nil {
+		return nil, false, err
+	}
+	return shard.GetByKey(key)
+}
+
+func (x *indexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	if indexName == cache.NamespaceIndex {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		shard, err := x.shard(accessor.GetNamespace())
+		if shard == nil || err != nil {
+			return nil, err
+		}
+		return shard.Index(indexName, obj)
+	}
+	return x.merge(func(shard cache.Indexer) ([]interface{}, error) {
+		return shard.Index(indexName, obj)
+	})
+}
+
+func (x *indexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	var errs []error
+	x.registry.each(x.gvr, func(informer cache.SharedIndexInformer) {
+		list, err := informer.GetIndexer().IndexKeys(indexName, indexedValue)
+		keys = append(keys, list...)
+		errs = append(errs, err)
+	})
+	return keys, errors.Join(errs...)
+}
+
+func (x *indexer) ListIndexFuncValues(indexName string) []string {
+	values := sets.New[string]()
+	x.registry.each(x.gvr, func(informer cache.SharedIndexInformer) {
+		values.Insert(informer.GetIndexer().ListIndexFuncValues(indexName)...)
+	})
+	return sets.List(values)
+}
+
+func (x *indexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	if indexName == cache.NamespaceIndex {
+		shard, err := x.shard(indexedValue)
+		if shard == nil || err != nil {
+			return nil, err
+		}
+		return shard.ByIndex(indexName, indexedValue)
+	}
+	return x.merge(func(shard cache.Indexer) ([]interface{}, error) {
+		return shard.ByIndex(indexName, indexedValue)
+	})
+}
+
+// GetIndexers returns the indexers every shard has, the namespace index and those of
+// Options.Indexers
+func (x *indexer) GetIndexers() cache.Indexers {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, indexFunc := range x.registry.options.Indexers[x.gvr] {
+		indexers[name] = indexFunc
+	}
+	return indexers
+}
+
+func (x *indexer) AddIndexers(cache.Indexers) error {
+	return errReadOnly
+}
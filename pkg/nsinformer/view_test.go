@@ -0,0 +1,206 @@
+package nsinformer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestFactoryListers(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   Options
+		namespace string
+		wantPods  int
+		// wantShards are the shards running after the reads
+		wantShards int
+	}{
+		{name: "pinned", options: Options{Namespaces: []string{"ns-1", "ns-2"}}, namespace: "ns-1", wantPods: fixturePodsPerNamespace, wantShards: 2},
+		{name: "not sharded", options: Options{Namespaces: []string{"ns-1", "ns-2"}}, namespace: "ns-3", wantShards: 2},
+		{name: "lazily started", options: Options{Namespaces: []string{"ns-1", "ns-2"}, Lazy: true}, namespace: "ns-3", wantPods: fixturePodsPerNamespace, wantShards: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fixtureClientset()
+			registry := newTestRegistry(t, client, tt.options)
+			lister := registry.Factory(informers.NewSharedInformerFactory(client, 0)).Core().V1().Pods().Lister()
+
+			pods, err := lister.Pods(tt.namespace).List(labels.Everything())
+			if err != nil || len(pods) != tt.wantPods {
+				t.Errorf("Pods(%s).List() = %d pods, %v, want %d", tt.namespace, len(pods), err, tt.wantPods)
+			}
+			pod, err := lister.Pods(tt.namespace).Get("web-3")
+			if tt.wantPods > 0 && (err != nil || pod.Namespace != tt.namespace) {
+				t.Errorf("Pods(%s).Get(web-3) = %v, %v, want the pod", tt.namespace, pod, err)
+			}
+			if tt.wantPods == 0 && !apierrors.IsNotFound(err) {
+				t.Errorf("Pods(%s).Get(web-3) error = %v, want not found", tt.namespace, err)
+			}
+
+			// Lists across namespaces see every running shard
+			if got := len(registry.List()); got != tt.wantShards {
+				t.Errorf("%d shards running, want %d", got, tt.wantShards)
+			}
+			all, err := lister.List(labels.Everything())
+			if err != nil || len(all) != tt.wantShards*fixturePodsPerNamespace {
+				t.Errorf("List() = %d pods, %v, want %d", len(all), err, tt.wantShards*fixturePodsPerNamespace)
+			}
+		})
+	}
+}
+
+func TestFactoryIndexers(t *testing.T) {
+	byName := cache.Indexers{"name": func(obj interface{}) ([]string, error) {
+		return []string{obj.(*corev1.Pod).Name}, nil
+	}}
+	registry := newTestRegistry(t, fixtureClientset(), Options{
+		Namespaces: []string{"ns-1", "ns-2"},
+		Indexers:   map[schema.GroupVersionResource]cache.Indexers{podsResource: byName},
+	})
+	indexer := registry.Factory(informers.NewSharedInformerFactory(fixtureClientset(), 0)).Core().V1().Pods().Informer().GetIndexer()
+
+	var names []string
+	for name := range indexer.GetIndexers() {
+		names = append(names, name)
+	}
+	if len(names) != 2 || indexer.GetIndexers()["name"] == nil || indexer.GetIndexers()[cache.NamespaceIndex] == nil {
+		t.Errorf("GetIndexers() = %v, want the namespace and name indexes", names)
+	}
+
+	pods, err := indexer.ByIndex("name", "web-3")
+	if err != nil || len(pods) != 2 {
+		t.Errorf("ByIndex(name, web-3) = %d pods, %v, want one from each shard", len(pods), err)
+	}
+	pods, err = indexer.ByIndex(cache.NamespaceIndex, "ns-2")
+	if err != nil || len(pods) != fixturePodsPerNamespace {
+		t.Errorf("ByIndex(namespace, ns-2) = %d pods, %v, want %d", len(pods), err, fixturePodsPerNamespace)
+	}
+	if err := indexer.Add(&corev1.Pod{}); err == nil {
+		t.Error("Add() wrote to a shard's cache")
+	}
+}
+
+// podEvents counts the pod events a handler got by namespace
+type podEvents struct {
+	mu      sync.Mutex
+	initial map[string]int
+	added   map[string]int
+}
+
+func (e *podEvents) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if isInInitialList {
+				e.initial[obj.(*corev1.Pod).Namespace]++
+			} else {
+				e.added[obj.(*corev1.Pod).Namespace]++
+			}
+		},
+	}
+}
+
+func (e *podEvents) counts() (initial, added map[string]int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.initial), maps.Clone(e.added)
+}
+
+func TestFactoryEventHandlers(t *testing.T) {
+	client := fixtureClientset()
+	registry := newTestRegistry(t, client, Options{Namespaces: []string{"ns-1"}, Lazy: true})
+	pods := registry.Factory(informers.NewSharedInformerFactory(client, 0)).Core().V1().Pods()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events := &podEvents{initial: map[string]int{}, added: map[string]int{}}
+	registration, err := pods.Informer().AddEventHandler(events.handler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A shard started after the handler was added delivers to it too
+	if _, err := pods.Lister().Pods("ns-2").List(labels.Everything()); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), registration.HasSynced) {
+		t.Fatal("handler did not sync")
+	}
+	if initial, _ := events.counts(); !reflect.DeepEqual(initial, map[string]int{"ns-1": fixturePodsPerNamespace, "ns-2": fixturePodsPerNamespace}) {
+		t.Errorf("initial list = %v, want %d pods in ns-1 and ns-2", initial, fixturePodsPerNamespace)
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-0", Namespace: "ns-2"}}
+	if _, err := client.CoreV1().Pods("ns-2").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, added := events.counts(); added["ns-2"] == 1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("handler did not get the pod added to ns-2")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := pods.Informer().RemoveEventHandler(registration); err != nil {
+		t.Fatal(err)
+	}
+	registry.mu.Lock()
+	handlers := len(registry.handlers[podsResource])
+	registry.mu.Unlock()
+	if handlers != 0 {
+		t.Errorf("%d handlers left after RemoveEventHandler(), want none", handlers)
+	}
+}
+
+func TestFactoryKeepsNamespacedResourcesOffTheSharedFactory(t *testing.T) {
+	client := fixtureClientset()
+	registry := newTestRegistry(t, client, Options{Namespaces: []string{"ns-1"}})
+	shared := informers.NewSharedInformerFactory(client, 0)
+	fact := registry.Factory(shared)
+
+	if _, err := fact.Core().V1().Pods().Lister().Pods("ns-1").List(labels.Everything()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fact.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fact.ForResource(podsResource); err != nil {
+		t.Fatal(err)
+	}
+	// Resources the shards don't cache come from the shared factory
+	configMaps, err := fact.ForResource(corev1.SchemeGroupVersion.WithResource("configmaps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configMaps.Informer() != shared.Core().V1().ConfigMaps().Informer() {
+		t.Error("ForResource(configmaps) is not the shared factory's informer")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	fact.Start(stopCh)
+	var started []string
+	for informerType := range shared.WaitForCacheSync(stopCh) {
+		started = append(started, informerType.String())
+	}
+	if fmt.Sprint(started) != "[*v1.ConfigMap]" {
+		t.Errorf("shared factory informers = %v, want [*v1.ConfigMap]", started)
+	}
+}
@@ -0,0 +1,121 @@
+// Package nsscope limits the server to a set of namespaces, whatever its credentials allow,
+// for deployments serving a single team.
+package nsscope
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Scope is the set of namespaces requests may target. A nil Scope allows every namespace
+// and cluster-scoped resources.
+type Scope struct {
+	allowed       []string
+	denied        []string
+	clusterScoped bool
+}
+
+// New allows the namespaces matching a path.Match pattern in allowed, every namespace when
+// allowed is empty, except those matching one in denied. Cluster-scoped resources are only
+// allowed with clusterScoped. It returns nil when neither list is set, leaving the server
+// unrestricted.
+func New(allowed []string, denied []string, clusterScoped bool) (*Scope, error) {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range slices.Concat(allowed, denied) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+	}
+	return &Scope{allowed: allowed, denied: denied, clusterScoped: clusterScoped}, nil
+}
+
+// Restricted reports whether the scope limits anything
+func (s *Scope) Restricted() bool {
+	return s != nil
+}
+
+// Allows reports whether requests may target namespace
+func (s *Scope) Allows(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	if namespace == "" || matchesAny(s.denied, namespace) {
+		return false
+	}
+	return len(s.allowed) == 0 || matchesAny(s.allowed, namespace)
+}
+
+// ClusterScoped reports whether requests may target cluster-scoped resources
+func (s *Scope) ClusterScoped() bool {
+	return s == nil || s.clusterScoped
+}
+
+// Single returns the namespace when exactly one, named without wildcards, is allowed
+func (s *Scope) Single() (string, bool) {
+	if s == nil || len(s.allowed) != 1 || isPattern(s.allowed[0]) || !s.Allows(s.allowed[0]) {
+		return "", false
+	}
+	return s.allowed[0], true
+}
+
+// Default is the namespace of requests that don't name one: "default" when allowed,
+// otherwise the first allowed namespace named without wildcards
+func (s *Scope) Default() string {
+	if s.Allows("default") {
+		return "default"
+	}
+	for _, namespace := range s.allowed {
+		if !isPattern(namespace) && s.Allows(namespace) {
+			return namespace
+		}
+	}
+	return "default"
+}
+
+// String describes the scope for error messages
+func (s *Scope) String() string {
+	if s == nil {
+		return "all namespaces"
+	}
+	description := "all namespaces"
+	if len(s.allowed) > 0 {
+		description = "namespaces " + strings.Join(s.allowed, ", ")
+	}
+	if len(s.denied) > 0 {
+		description += " except " + strings.Join(s.denied, ", ")
+	}
+	return description
+}
+
+// Filter keeps the items whose namespace, as returned by namespaceOf, is allowed.
+// Items in no namespace are kept only when cluster-scoped resources are allowed.
+func Filter[T any](s *Scope, items []T, namespaceOf func(T) string) []T {
+	if s == nil {
+		return items
+	}
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		namespace := namespaceOf(item)
+		if (namespace == "" && s.clusterScoped) || s.Allows(namespace) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func matchesAny(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isPattern(namespace string) bool {
+	return strings.ContainsAny(namespace, `*?[\`)
+}
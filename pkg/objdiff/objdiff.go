@@ -0,0 +1,260 @@
+// Package objdiff compares two versions of an unstructured object field by field, so
+// clients are sent what changed rather than both objects. Volatile paths are ignored,
+// secret values redacted, and the size of a diff is capped.
+package objdiff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Defaults of Options
+const (
+	DefaultMaxChanges     = 50
+	DefaultMaxValueLength = 256
+)
+
+// Redacted replaces the values of redacted paths
+const Redacted = "<redacted>"
+
+// DefaultIgnore are paths that change on every write, or with every status heartbeat,
+// without saying anything about the object
+var DefaultIgnore = []string{
+	"metadata.resourceVersion",
+	"metadata.generation",
+	"metadata.managedFields",
+	`metadata.annotations["kubectl.kubernetes.io/last-applied-configuration"]`,
+	"status.observedGeneration",
+	"status.conditions[*].lastTransitionTime",
+	"status.conditions[*].lastUpdateTime",
+	"status.conditions[*].lastHeartbeatTime",
+	"status.conditions[*].lastProbeTime",
+}
+
+// Change is a changed leaf field. Old is nil for added fields and New for removed ones.
+type Change struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Options controls a diff
+type Options struct {
+	// Ignore are paths left out of the diff with everything under them, e.g.
+	// "metadata.managedFields" or "status.conditions[*].lastTransitionTime"
+	Ignore []Path
+	// Redact are paths whose changes are reported with Redacted in place of their values
+	Redact []Path
+	// MaxChanges caps the changes returned, DefaultMaxChanges when zero
+	MaxChanges int
+	// MaxValueLength truncates longer values to a string, DefaultMaxValueLength when zero
+	MaxValueLength int
+}
+
+// Path is a parsed path pattern. Segments are map keys, "[n]" list indexes, "*" any key
+// and "[*]" any index.
+type Path []string
+
+// ParsePath parses a dotted path such as spec.containers[*].image. Keys containing dots are
+// quoted in brackets: metadata.labels["app.kubernetes.io/name"].
+func ParsePath(path string) (Path, error) {
+	var segments Path
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if strings.HasPrefix(path[i:], `["`) {
+				end = strings.Index(path[i:], `"]`)
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated key in path %q", path)
+				}
+				segments = append(segments, path[i+2:i+end])
+				i += end + 2
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in path %q", path)
+			}
+			index := path[i+1 : i+end]
+			if _, err := strconv.Atoi(index); err != nil && index != "*" {
+				return nil, fmt.Errorf("invalid index %q in path %q", index, path)
+			}
+			segments = append(segments, "["+index+"]")
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			segments = append(segments, path[i:i+end])
+			i += end
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return segments, nil
+}
+
+// ParsePaths parses every path, failing on the first invalid one
+func ParsePaths(paths []string) ([]Path, error) {
+	parsed := make([]Path, 0, len(paths))
+	for _, path := range paths {
+		p, err := ParsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// covers reports whether path is p or under it
+func (p Path) covers(path []string) bool {
+	if len(path) < len(p) {
+		return false
+	}
+	for i, segment := range p {
+		switch {
+		case segment == path[i]:
+		case segment == "*" && !isIndex(path[i]):
+		case segment == "[*]" && isIndex(path[i]):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isIndex(segment string) bool {
+	return strings.HasPrefix(segment, "[")
+}
+
+// String renders segments as a dotted path, quoting keys that would be ambiguous
+func (p Path) String() string {
+	var b strings.Builder
+	for _, segment := range p {
+		switch {
+		case isIndex(segment):
+			b.WriteString(segment)
+		case strings.ContainsAny(segment, `.[]"`):
+			b.WriteString(`["` + segment + `"]`)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(segment)
+		}
+	}
+	return b.String()
+}
+
+// Diff returns the changed leaf fields between two objects, ordered by path, and whether
+// more changes than MaxChanges were found. Lists are compared index by index, so an
+// element inserted at the front shows up as a change of every element after it.
+func Diff(oldObj, newObj map[string]interface{}, opts Options) ([]Change, bool) {
+	if opts.MaxChanges <= 0 {
+		opts.MaxChanges = DefaultMaxChanges
+	}
+	if opts.MaxValueLength <= 0 {
+		opts.MaxValueLength = DefaultMaxValueLength
+	}
+	d := &differ{opts: opts, changes: []Change{}}
+	d.diff(nil, oldObj, newObj)
+	return d.changes, d.truncated
+}
+
+type differ struct {
+	opts      Options
+	changes   []Change
+	truncated bool
+}
+
+func (d *differ) diff(path []string, oldValue, newValue interface{}) {
+	if d.truncated || d.matches(d.opts.Ignore, path) || reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make([]string, 0, len(oldMap)+len(newMap))
+		for key := range oldMap {
+			keys = append(keys, key)
+		}
+		for key := range newMap {
+			if _, ok := oldMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			d.diff(append(path[:len(path):len(path)], key), oldMap[key], newMap[key])
+		}
+		return
+	}
+
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList && newIsList {
+		for i := 0; i < max(len(oldList), len(newList)); i++ {
+			var oldItem, newItem interface{}
+			if i < len(oldList) {
+				oldItem = oldList[i]
+			}
+			if i < len(newList) {
+				newItem = newList[i]
+			}
+			d.diff(append(path[:len(path):len(path)], "["+strconv.Itoa(i)+"]"), oldItem, newItem)
+		}
+		return
+	}
+
+	if len(d.changes) >= d.opts.MaxChanges {
+		d.truncated = true
+		return
+	}
+	change := Change{Path: Path(path).String(), Old: d.value(oldValue), New: d.value(newValue)}
+	if d.matches(d.opts.Redact, path) {
+		change.Old, change.New = redact(oldValue), redact(newValue)
+	}
+	d.changes = append(d.changes, change)
+}
+
+func (d *differ) matches(paths []Path, path []string) bool {
+	for _, p := range paths {
+		if p.covers(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// value replaces values rendering longer than MaxValueLength with a truncated string
+func (d *differ) value(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, bool, int64, float64:
+		return value
+	}
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprintf("%v", value)
+	}
+	if len(s) > d.opts.MaxValueLength {
+		return s[:d.opts.MaxValueLength] + "..."
+	}
+	return value
+}
+
+// redact keeps whether a value was set, not what it was
+func redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return Redacted
+}
@@ -0,0 +1,383 @@
+package objdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    Path
+		wantErr bool
+	}{
+		{path: "metadata.resourceVersion", want: Path{"metadata", "resourceVersion"}},
+		{path: "status.conditions[*].lastTransitionTime", want: Path{"status", "conditions", "[*]", "lastTransitionTime"}},
+		{path: "spec.containers[0].image", want: Path{"spec", "containers", "[0]", "image"}},
+		{path: `metadata.labels["app.kubernetes.io/name"]`, want: Path{"metadata", "labels", "app.kubernetes.io/name"}},
+		{path: "data.*", want: Path{"data", "*"}},
+		{path: "", wantErr: true},
+		{path: "spec.containers[0", wantErr: true},
+		{path: "spec.containers[first]", wantErr: true},
+		{path: `metadata.labels["app`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePath(%q) = %v, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+			if got.String() != tt.path {
+				t.Errorf("ParsePath(%q).String() = %q, want it back", tt.path, got.String())
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	pod := func(image string, ready string, labels map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web-0", "resourceVersion": image + ready, "labels": labels},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "web", "image": image}},
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": ready, "lastTransitionTime": ready + "-time"},
+				},
+			},
+		}
+	}
+	ignore, err := ParsePaths(DefaultIgnore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretData, _ := ParsePath("data.*")
+
+	tests := []struct {
+		name          string
+		oldObj        map[string]interface{}
+		newObj        map[string]interface{}
+		opts          Options
+		want          []Change
+		wantTruncated bool
+	}{
+		{
+			name:   "volatile paths ignored",
+			oldObj: pod("nginx:1.24", "False", nil),
+			newObj: pod("nginx:1.25", "True", nil),
+			opts:   Options{Ignore: ignore},
+			want: []Change{
+				{Path: "spec.containers[0].image", Old: "nginx:1.24", New: "nginx:1.25"},
+				{Path: "status.conditions[0].status", Old: "False", New: "True"},
+			},
+		},
+		{
+			name:   "label added with a dotted key",
+			oldObj: pod("nginx", "True", map[string]interface{}{"app": "web"}),
+			newObj: pod("nginx", "True", map[string]interface{}{"app": "web", "app.kubernetes.io/name": "web"}),
+			opts:   Options{Ignore: ignore},
+			want:   []Change{{Path: `metadata.labels["app.kubernetes.io/name"]`, Old: nil, New: "web"}},
+		},
+		{
+			name:   "list element removed",
+			oldObj: map[string]interface{}{"args": []interface{}{"--a", "--b"}},
+			newObj: map[string]interface{}{"args": []interface{}{"--a"}},
+			want:   []Change{{Path: "args[1]", Old: "--b", New: nil}},
+		},
+		{
+			name:   "redacted",
+			oldObj: map[string]interface{}{"data": map[string]interface{}{"password": "aGVsbG8="}},
+			newObj: map[string]interface{}{"data": map[string]interface{}{"password": "d29ybGQ=", "token": "dG9r"}},
+			opts:   Options{Redact: []Path{secretData}},
+			want: []Change{
+				{Path: "data.password", Old: Redacted, New: Redacted},
+				{Path: "data.token", Old: nil, New: Redacted},
+			},
+		},
+		{
+			name:   "long values truncated",
+			oldObj: map[string]interface{}{"script": strings.Repeat("a", 12)},
+			newObj: map[string]interface{}{"script": strings.Repeat("b", 12)},
+			opts:   Options{MaxValueLength: 4},
+			want:   []Change{{Path: "script", Old: "aaaa...", New: "bbbb..."}},
+		},
+		{
+			name:          "capped",
+			oldObj:        map[string]interface{}{"a": 1.0, "b": 1.0, "c": 1.0},
+			newObj:        map[string]interface{}{"a": 2.0, "b": 2.0, "c": 2.0},
+			opts:          Options{MaxChanges: 2},
+			want:          []Change{{Path: "a", Old: 1.0, New: 2.0}, {Path: "b", Old: 1.0, New: 2.0}},
+			wantTruncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := Diff(tt.oldObj, tt.newObj, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) || truncated != tt.wantTruncated {
+				t.Errorf("Diff() = %+v, truncated %v, want %+v, truncated %v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+// keys are the map keys of generated objects, some needing quoting in paths
+var keys = []string{"a", "b", "spec", "items", "status", "app.kubernetes.io/name", "k[8]s"}
+
+// randomValue generates a value nested up to depth levels of maps and lists
+func randomValue(rng *rand.Rand, depth int) interface{} {
+	kind := rng.Intn(8)
+	if depth == 0 {
+		kind = rng.Intn(5)
+	}
+	switch kind {
+	case 0:
+		return nil
+	case 1:
+		return rng.Intn(2) == 0
+	case 2:
+		return int64(rng.Intn(4))
+	case 3:
+		return float64(rng.Intn(4)) / 2
+	case 4:
+		return strconv.Itoa(rng.Intn(4))
+	case 5:
+		list := make([]interface{}, rng.Intn(4))
+		for i := range list {
+			list[i] = randomValue(rng, depth-1)
+		}
+		return list
+	default:
+		return randomMap(rng, depth-1)
+	}
+}
+
+func randomMap(rng *rand.Rand, depth int) map[string]interface{} {
+	m := map[string]interface{}{}
+	for i := rng.Intn(len(keys)); i > 0; i-- {
+		m[keys[rng.Intn(len(keys))]] = randomValue(rng, depth)
+	}
+	return m
+}
+
+// mutate returns a copy of value with some of its nested values changed, added or removed
+func mutate(rng *rand.Rand, value interface{}, depth int) interface{} {
+	if rng.Intn(6) == 0 {
+		return randomValue(rng, depth)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			switch rng.Intn(5) {
+			case 0:
+				// removed
+			case 1:
+				m[key] = mutate(rng, item, depth-1)
+			default:
+				m[key] = item
+			}
+		}
+		if rng.Intn(3) == 0 {
+			m[keys[rng.Intn(len(keys))]] = randomValue(rng, depth-1)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(v)+1)
+		for _, item := range v {
+			if rng.Intn(4) == 0 {
+				item = mutate(rng, item, depth-1)
+			}
+			list = append(list, item)
+		}
+		switch rng.Intn(3) {
+		case 0:
+			list = append(list, randomValue(rng, depth-1))
+		case 1:
+			if len(list) > 0 {
+				list = list[:len(list)-1]
+			}
+		}
+		return list
+	}
+	return value
+}
+
+// lookup returns the value at a path of segments in obj, nil when it isn't there
+func lookup(obj interface{}, path Path) interface{} {
+	for _, segment := range path {
+		switch v := obj.(type) {
+		case map[string]interface{}:
+			obj = v[segment]
+		case []interface{}:
+			i, err := strconv.Atoi(strings.Trim(segment, "[]"))
+			if err != nil || !isIndex(segment) || i >= len(v) {
+				return nil
+			}
+			obj = v[i]
+		default:
+			return nil
+		}
+	}
+	return obj
+}
+
+// prune drops null fields and trailing null list elements, which the diff treats as unset
+func prune(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for key, item := range v {
+			if item != nil {
+				m[key] = prune(item)
+			}
+		}
+		return m
+	case []interface{}:
+		for len(v) > 0 && v[len(v)-1] == nil {
+			v = v[:len(v)-1]
+		}
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = prune(item)
+		}
+		return list
+	}
+	return value
+}
+
+// checkDiff checks the properties every diff has, whatever the objects
+func checkDiff(t *testing.T, oldObj, newObj map[string]interface{}) {
+	t.Helper()
+	opts := Options{MaxChanges: 1 << 20, MaxValueLength: 1 << 20}
+	changes, truncated := Diff(oldObj, newObj, opts)
+	if truncated {
+		t.Fatalf("Diff() truncated %d changes below the cap", len(changes))
+	}
+	if same := reflect.DeepEqual(prune(oldObj), prune(newObj)); same != (len(changes) == 0) {
+		t.Fatalf("Diff() = %d changes for objects equal %v", len(changes), same)
+	}
+
+	seen := map[string]bool{}
+	for _, change := range changes {
+		if seen[change.Path] {
+			t.Errorf("Diff() reports %s twice", change.Path)
+		}
+		seen[change.Path] = true
+		if reflect.DeepEqual(change.Old, change.New) {
+			t.Errorf("Diff() reports %s unchanged at %v", change.Path, change.Old)
+		}
+	}
+
+	// Swapping the objects swaps old and new
+	reversed, _ := Diff(newObj, oldObj, opts)
+	if len(reversed) != len(changes) {
+		t.Fatalf("Diff() reversed = %d changes, want %d", len(reversed), len(changes))
+	}
+	for i, change := range reversed {
+		if change.Path != changes[i].Path || !reflect.DeepEqual(change.Old, changes[i].New) || !reflect.DeepEqual(change.New, changes[i].Old) {
+			t.Errorf("Diff() reversed = %+v, want %+v swapped", change, changes[i])
+		}
+	}
+
+	// Capping keeps the first changes and flags the rest
+	for _, maxChanges := range []int{1, len(changes) / 2, len(changes)} {
+		if maxChanges == 0 {
+			continue
+		}
+		capped, truncated := Diff(oldObj, newObj, Options{MaxChanges: maxChanges, MaxValueLength: opts.MaxValueLength})
+		if !reflect.DeepEqual(capped, changes[:len(capped)]) || len(capped) > maxChanges {
+			t.Errorf("Diff() capped to %d = %+v, want the first of %+v", maxChanges, capped, changes)
+		}
+		if truncated != (len(changes) > maxChanges) {
+			t.Errorf("Diff() capped to %d of %d changes, truncated = %v", maxChanges, len(changes), truncated)
+		}
+	}
+}
+
+// TestDiffRandom generates nested objects and mutations of them, checking each diff reports
+// exactly the values at its paths in both objects
+func TestDiffRandom(t *testing.T) {
+	ignore, err := ParsePaths([]string{"status", "spec.items[*].a", `*["app.kubernetes.io/name"]`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		oldObj := randomMap(rng, 4)
+		newObj := mutate(rng, oldObj, 4)
+		newMap, ok := newObj.(map[string]interface{})
+		if !ok {
+			newMap = map[string]interface{}{"a": newObj}
+		}
+
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			checkDiff(t, oldObj, newMap)
+
+			changes, _ := Diff(oldObj, newMap, Options{MaxChanges: 1 << 20, MaxValueLength: 1 << 20})
+			for _, change := range changes {
+				path, err := ParsePath(change.Path)
+				if err != nil {
+					t.Fatalf("Diff() path %q doesn't parse: %v", change.Path, err)
+				}
+				if old := lookup(oldObj, path); !reflect.DeepEqual(change.Old, old) {
+					t.Errorf("%s old = %v, want %v", change.Path, change.Old, old)
+				}
+				if new := lookup(newMap, path); !reflect.DeepEqual(change.New, new) {
+					t.Errorf("%s new = %v, want %v", change.Path, change.New, new)
+				}
+			}
+
+			// Nothing under an ignored path is reported
+			ignored, _ := Diff(oldObj, newMap, Options{Ignore: ignore, MaxChanges: 1 << 20})
+			for _, change := range ignored {
+				path, _ := ParsePath(change.Path)
+				for _, p := range ignore {
+					if p.covers(path) {
+						t.Errorf("Diff() reports %s, ignored by %s", change.Path, p)
+					}
+				}
+			}
+		})
+		if t.Failed() {
+			t.Logf("old: %v\nnew: %v", oldObj, newMap)
+			return
+		}
+	}
+}
+
+func FuzzDiff(f *testing.F) {
+	f.Add(`{}`, `{}`)
+	f.Add(`{"a":1}`, `{"a":2}`)
+	f.Add(`{"spec":{"items":[1,2,3]}}`, `{"spec":{"items":[1,3]}}`)
+	f.Add(`{"spec":{"items":[{"a":1},{"b":[null]}]}}`, `{"spec":{"items":"none"}}`)
+	f.Add(`{"metadata":{"labels":{"app.kubernetes.io/name":"web"}}}`, `{"metadata":{"labels":null}}`)
+
+	f.Fuzz(func(t *testing.T, oldJSON, newJSON string) {
+		var oldObj, newObj map[string]interface{}
+		if json.Unmarshal([]byte(oldJSON), &oldObj) != nil || json.Unmarshal([]byte(newJSON), &newObj) != nil {
+			return
+		}
+		checkDiff(t, oldObj, newObj)
+		if changes, _ := Diff(oldObj, oldObj, Options{}); len(changes) != 0 {
+			t.Errorf("Diff() of an object with itself = %+v", changes)
+		}
+	})
+}
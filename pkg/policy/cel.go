@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CELRule is a custom rule whose expression must evaluate to true for the object to pass.
+// The manifest is available to the expression as `object`.
+type CELRule struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+	// Path is reported on violations since the expression itself has no location
+	Path string `json:"path"`
+}
+
+// CELCheck evaluates a compiled CEL expression against objects
+type CELCheck struct {
+	rule    CELRule
+	program cel.Program
+}
+
+// NewCELCheck compiles a CEL rule into a check
+func NewCELCheck(rule CELRule) (*CELCheck, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("CEL rule name cannot be empty")
+	}
+
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(rule.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to compile CEL rule %s: %w", rule.Name, issues.Err())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build CEL program for rule %s: %w", rule.Name, err)
+	}
+
+	return &CELCheck{rule: rule, program: program}, nil
+}
+
+func (c *CELCheck) Name() string {
+	return c.rule.Name
+}
+
+func (c *CELCheck) PolicyCheck(obj *unstructured.Unstructured) []Violation {
+	path := c.rule.Path
+	if path == "" {
+		path = "$"
+	}
+
+	out, _, err := c.program.Eval(map[string]interface{}{"object": obj.Object})
+	if err != nil {
+		return []Violation{{
+			Rule:    c.rule.Name,
+			Path:    path,
+			Message: fmt.Sprintf("failed to evaluate expression: %v", err),
+		}}
+	}
+
+	passed, ok := out.Value().(bool)
+	if !ok {
+		return []Violation{{
+			Rule:    c.rule.Name,
+			Path:    path,
+			Message: fmt.Sprintf("expression returned %T instead of bool", out.Value()),
+		}}
+	}
+	if passed {
+		return nil
+	}
+
+	message := c.rule.Message
+	if message == "" {
+		message = fmt.Sprintf("expression %q evaluated to false", c.rule.Expression)
+	}
+	return []Violation{{Rule: c.rule.Name, Path: path, Message: message}}
+}
@@ -0,0 +1,158 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func init() {
+	Register(CheckFunc{RuleName: "latestTag", Fn: checkLatestTag})
+	Register(CheckFunc{RuleName: "resourceLimits", Fn: checkResourceLimits})
+	Register(CheckFunc{RuleName: "hostPath", Fn: checkHostPath})
+	Register(CheckFunc{RuleName: "privileged", Fn: checkPrivileged})
+}
+
+// podSpecFields returns the field path of the pod spec embedded in common workload kinds
+func podSpecFields(obj *unstructured.Unstructured) []string {
+	switch obj.GetKind() {
+	case "Pod":
+		return []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		return []string{"spec", "template", "spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+}
+
+// container is a container entry along with its JSON path
+type container struct {
+	path   string
+	fields map[string]interface{}
+}
+
+// containers collects regular, init and ephemeral containers of the object's pod spec
+func containers(obj *unstructured.Unstructured) []container {
+	specFields := podSpecFields(obj)
+	if specFields == nil {
+		return nil
+	}
+
+	var result []container
+	for _, key := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		fields := append(append([]string{}, specFields...), key)
+		list, found, err := unstructured.NestedSlice(obj.Object, fields...)
+		if err != nil || !found {
+			continue
+		}
+		for i, item := range list {
+			c, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			result = append(result, container{
+				path:   fmt.Sprintf("%s[%d]", strings.Join(fields, "."), i),
+				fields: c,
+			})
+		}
+	}
+	return result
+}
+
+// imageTag returns the tag portion of an image reference, ignoring registry ports and digests
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	lastSlash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > lastSlash {
+		return image[i+1:]
+	}
+	return ""
+}
+
+func checkLatestTag(obj *unstructured.Unstructured) []Violation {
+	var violations []Violation
+	for _, c := range containers(obj) {
+		image, _, _ := unstructured.NestedString(c.fields, "image")
+		if image == "" || strings.Contains(image, "@") {
+			continue
+		}
+		if tag := imageTag(image); tag == "" || tag == "latest" {
+			violations = append(violations, Violation{
+				Rule:    "latestTag",
+				Path:    c.path + ".image",
+				Message: fmt.Sprintf("image %q must use a pinned tag or digest instead of latest", image),
+			})
+		}
+	}
+	return violations
+}
+
+func checkResourceLimits(obj *unstructured.Unstructured) []Violation {
+	var violations []Violation
+	for _, c := range containers(obj) {
+		// Ephemeral containers cannot declare resources
+		if strings.Contains(c.path, "ephemeralContainers") {
+			continue
+		}
+		for _, kind := range []string{"requests", "limits"} {
+			values, _, _ := unstructured.NestedMap(c.fields, "resources", kind)
+			for _, name := range []string{"cpu", "memory"} {
+				if _, ok := values[name]; ok {
+					continue
+				}
+				violations = append(violations, Violation{
+					Rule:    "resourceLimits",
+					Path:    fmt.Sprintf("%s.resources.%s.%s", c.path, kind, name),
+					Message: fmt.Sprintf("container must set %s %s", name, kind),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func checkHostPath(obj *unstructured.Unstructured) []Violation {
+	specFields := podSpecFields(obj)
+	if specFields == nil {
+		return nil
+	}
+
+	fields := append(append([]string{}, specFields...), "volumes")
+	volumes, _, _ := unstructured.NestedSlice(obj.Object, fields...)
+
+	var violations []Violation
+	for i, item := range volumes {
+		volume, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := volume["hostPath"]; ok {
+			violations = append(violations, Violation{
+				Rule:    "hostPath",
+				Path:    fmt.Sprintf("%s[%d].hostPath", strings.Join(fields, "."), i),
+				Message: "hostPath volumes are not allowed",
+			})
+		}
+	}
+	return violations
+}
+
+func checkPrivileged(obj *unstructured.Unstructured) []Violation {
+	var violations []Violation
+	for _, c := range containers(obj) {
+		privileged, _, _ := unstructured.NestedBool(c.fields, "securityContext", "privileged")
+		if privileged {
+			violations = append(violations, Violation{
+				Rule:    "privileged",
+				Path:    c.path + ".securityContext.privileged",
+				Message: "privileged containers are not allowed",
+			})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,165 @@
+// Package policy evaluates manifests against admission-style rules before they
+// are sent to the cluster.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Violation describes a single rule failure found in a manifest
+type Violation struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Check is a single policy rule evaluated against an object
+type Check interface {
+	Name() string
+	PolicyCheck(obj *unstructured.Unstructured) []Violation
+}
+
+// CheckFunc adapts a plain function to the Check interface
+type CheckFunc struct {
+	RuleName string
+	Fn       func(obj *unstructured.Unstructured) []Violation
+}
+
+func (c CheckFunc) Name() string {
+	return c.RuleName
+}
+
+func (c CheckFunc) PolicyCheck(obj *unstructured.Unstructured) []Violation {
+	return c.Fn(obj)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Check{}
+)
+
+// Register adds a named check to the registry so it can be enabled from config
+func Register(check Check) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[check.Name()] = check
+}
+
+// Registered returns the names of all registered checks
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config controls which checks run and how violations are handled
+type Config struct {
+	// WarnOnly reports violations without blocking the request
+	WarnOnly bool `json:"warnOnly"`
+	// Checks toggles registered checks by name
+	Checks map[string]bool `json:"checks"`
+	// CEL holds custom expression rules
+	CEL []CELRule `json:"cel"`
+}
+
+// LoadConfig reads a policy config file in YAML or JSON format
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy config: %w", err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse policy config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Evaluator runs the enabled checks against objects
+type Evaluator struct {
+	checks   []Check
+	warnOnly bool
+}
+
+// NewEvaluator builds an evaluator from config, compiling any CEL rules
+func NewEvaluator(cfg *Config) (*Evaluator, error) {
+	e := &Evaluator{}
+	if cfg == nil {
+		return e, nil
+	}
+	e.warnOnly = cfg.WarnOnly
+
+	registryMu.RLock()
+	for name, enabled := range cfg.Checks {
+		if !enabled {
+			continue
+		}
+		check, ok := registry[name]
+		if !ok {
+			registryMu.RUnlock()
+			return nil, fmt.Errorf("unknown policy check %q, available: %s", name, strings.Join(Registered(), ", "))
+		}
+		e.checks = append(e.checks, check)
+	}
+	registryMu.RUnlock()
+
+	// Keep evaluation order stable so responses are deterministic
+	sort.Slice(e.checks, func(i, j int) bool {
+		return e.checks[i].Name() < e.checks[j].Name()
+	})
+
+	for _, rule := range cfg.CEL {
+		check, err := NewCELCheck(rule)
+		if err != nil {
+			return nil, err
+		}
+		e.checks = append(e.checks, check)
+	}
+
+	return e, nil
+}
+
+// WarnOnly reports whether violations should be returned as warnings
+func (e *Evaluator) WarnOnly() bool {
+	return e != nil && e.warnOnly
+}
+
+// Evaluate runs every enabled check and collects the violations
+func (e *Evaluator) Evaluate(obj *unstructured.Unstructured) []Violation {
+	if e == nil {
+		return nil
+	}
+
+	var violations []Violation
+	for _, check := range e.checks {
+		violations = append(violations, check.PolicyCheck(obj)...)
+	}
+	return violations
+}
+
+// ViolationError is returned when an object is rejected by policy
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (v *ViolationError) Error() string {
+	rules := make([]string, 0, len(v.Violations))
+	for _, violation := range v.Violations {
+		rules = append(rules, fmt.Sprintf("%s at %s", violation.Rule, violation.Path))
+	}
+	return fmt.Sprintf("object rejected by policy: %s", strings.Join(rules, "; "))
+}
@@ -0,0 +1,194 @@
+// Package preflight checks at startup that the API server can be reached, that discovery
+// works and that the credentials may list and watch what the informers cache, so a bad
+// kubeconfig or missing RBAC fails with a hint instead of hanging in a cache sync.
+package preflight
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Names of the checks
+const (
+	CheckAPIServer   = "api-server"
+	CheckDiscovery   = "discovery"
+	CheckPermissions = "permissions"
+)
+
+// Result is the outcome of one check. Hint says how to fix a failure.
+type Result struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Message  string        `json:"message"`
+	Hint     string        `json:"hint,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report holds the results of every check, in the order they ran
+type Report struct {
+	Time    time.Time `json:"time"`
+	Results []Result  `json:"results"`
+}
+
+// Passed reports whether every check passed
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if !result.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// Reachable reports whether the API server could be reached
+func (r *Report) Reachable() bool {
+	for _, result := range r.Results {
+		if result.Name == CheckAPIServer {
+			return result.OK
+		}
+	}
+	return false
+}
+
+// Run checks the API server, discovery and the list and watch permissions on resources in
+// namespace, or cluster-wide when it is empty. Each check is bounded by timeout. Discovery
+// and permissions are skipped when the API server can't be reached.
+func Run(ctx context.Context, client kubernetes.Interface, timeout time.Duration, namespace string, resources []schema.GroupVersionResource) *Report {
+	report := &Report{Time: time.Now()}
+	run := func(name string, check func(ctx context.Context) Result) Result {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		start := time.Now()
+		result := check(ctx)
+		result.Name, result.Duration = name, time.Since(start).Round(time.Millisecond)
+		report.Results = append(report.Results, result)
+		return result
+	}
+
+	if !run(CheckAPIServer, func(ctx context.Context) Result { return checkAPIServer(ctx, client) }).OK {
+		for _, name := range []string{CheckDiscovery, CheckPermissions} {
+			report.Results = append(report.Results, Result{Name: name, Message: "skipped, the API server is unreachable"})
+		}
+		return report
+	}
+	run(CheckDiscovery, func(ctx context.Context) Result { return checkDiscovery(ctx, client.Discovery()) })
+	run(CheckPermissions, func(ctx context.Context) Result { return checkPermissions(ctx, client, namespace, resources) })
+	return report
+}
+
+func checkAPIServer(ctx context.Context, client kubernetes.Interface) Result {
+	body, err := client.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return Result{Message: fmt.Sprintf("failed to get the API server version: %v", err), Hint: hintFor(err)}
+	}
+
+	var info struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil || info.GitVersion == "" {
+		return Result{OK: true, Message: "reachable"}
+	}
+	return Result{OK: true, Message: "reachable, Kubernetes " + info.GitVersion}
+}
+
+// checkDiscovery lists the API groups and their resources. Groups failing discovery, often
+// an aggregated API whose backing service is down, are reported but don't fail the check.
+func checkDiscovery(ctx context.Context, client discovery.DiscoveryInterface) Result {
+	type outcome struct {
+		resources []*metav1.APIResourceList
+		err       error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		_, resources, err := client.ServerGroupsAndResources()
+		done <- outcome{resources, err}
+	}()
+
+	var o outcome
+	select {
+	case <-ctx.Done():
+		return Result{Message: "discovery timed out", Hint: hintFor(ctx.Err())}
+	case o = <-done:
+	}
+
+	var groupErr *discovery.ErrGroupDiscoveryFailed
+	switch {
+	case errors.As(o.err, &groupErr):
+		var groups []string
+		for gv := range groupErr.Groups {
+			groups = append(groups, gv.String())
+		}
+		return Result{
+			OK:      true,
+			Message: fmt.Sprintf("%d group versions found, discovery failed for %s", len(o.resources), strings.Join(groups, ", ")),
+			Hint:    "check the APIServices of those groups with kubectl get apiservices, their resources can't be used until they are available",
+		}
+	case o.err != nil:
+		return Result{Message: fmt.Sprintf("discovery failed: %v", o.err), Hint: hintFor(o.err)}
+	}
+	return Result{OK: true, Message: fmt.Sprintf("%d group versions found", len(o.resources))}
+}
+
+// checkPermissions asks the API server whether the credentials may list and watch each resource
+func checkPermissions(ctx context.Context, client kubernetes.Interface, namespace string, resources []schema.GroupVersionResource) Result {
+	var missing []string
+	for _, gvr := range resources {
+		for _, verb := range []string{"list", "watch"} {
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Group: gvr.Group, Resource: gvr.Resource},
+				},
+			}
+			review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				return Result{Message: fmt.Sprintf("failed to review access to %s: %v", gvr.Resource, err), Hint: hintFor(err)}
+			}
+			if !review.Status.Allowed {
+				missing = append(missing, verb+" "+gvr.GroupResource().String())
+			}
+		}
+	}
+
+	where := "in all namespaces"
+	if namespace != "" {
+		where = "in namespace " + namespace
+	}
+	if len(missing) > 0 {
+		return Result{
+			Message: "not allowed to " + strings.Join(missing, ", ") + " " + where,
+			Hint:    "bind the identity in the kubeconfig or the pod's service account to a role granting list and watch on those resources " + where + ", the informer caches can't sync without them",
+		}
+	}
+	return Result{OK: true, Message: fmt.Sprintf("allowed to list and watch %d resources %s", len(resources), where)}
+}
+
+// hintFor suggests a fix for a failed request
+func hintFor(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var netErr net.Error
+	switch {
+	case apierrors.IsUnauthorized(err):
+		return "the API server rejected the credentials, refresh the token or client certificate in the kubeconfig or check that KUBECONFIG points at the right cluster"
+	case apierrors.IsForbidden(err):
+		return "the credentials lack access, bind them to a role allowing this request"
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), strings.Contains(err.Error(), "x509:"):
+		return "the API server's certificate is not trusted, check certificate-authority-data and the server address in the kubeconfig"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr), strings.Contains(err.Error(), "connection refused"), strings.Contains(err.Error(), "no such host"):
+		return "the API server could not be reached, check the server address in the kubeconfig and that the network path to it is open (VPN, firewall, proxy settings)"
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+// Package profiling serves net/http/pprof and expvar on a dedicated listener, away from the
+// API router and its middlewares so long CPU profiles are not cut short.
+package profiling
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Handler returns a mux with the pprof handlers under /debug/pprof/ and expvar at /debug/vars
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Publish exposes a value computed on each /debug/vars request
+func Publish(name string, value func() any) {
+	expvar.Publish(name, expvar.Func(value))
+}
+
+// Serve listens on addr until the listener fails. No write timeout is set so CPU profiles
+// and traces can run for as long as requested.
+func Serve(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,130 @@
+// Package rawproxy forwards requests to arbitrary Kubernetes API server paths with the
+// server's own credentials, for the aggregated APIs and subresources that have no endpoint
+// of their own. Responses, watches included, are streamed back as they arrive.
+package rawproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// DefaultDenied keeps secrets out of reach of the proxy unless the deny list is overridden,
+// on the legacy watch paths too
+var DefaultDenied = []string{
+	"/api/v1/secrets",
+	"/api/v1/namespaces/*/secrets",
+	"/api/v1/watch/secrets",
+	"/api/v1/watch/namespaces/*/secrets",
+}
+
+// DeniedError is returned for paths the proxy doesn't allow
+type DeniedError struct {
+	Path string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("path %s is not allowed through the proxy", e.Path)
+}
+
+// strippedHeaders are client headers never forwarded: the caller's own credentials must not
+// reach the API server, and impersonation would let callers act as anyone
+var strippedHeaders = []string{"Authorization", "Cookie", "Impersonate-User", "Impersonate-Group", "Impersonate-Uid"}
+
+// Proxy forwards requests to the API server, restricted to the allowed paths
+type Proxy struct {
+	proxy   *httputil.ReverseProxy
+	allowed []string
+	denied  []string
+}
+
+// New builds a proxy to the API server of config. Paths are matched against allowed and
+// denied patterns segment by segment, with path.Match wildcards per segment; a pattern also
+// matches every path below it. Denied patterns win, and an empty allow list allows any path.
+func New(config *rest.Config, allowed, denied []string) (*Proxy, error) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build API server transport: %w", err)
+	}
+	host, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API server URL: %w", err)
+	}
+
+	for _, pattern := range append(append([]string{}, allowed...), denied...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &Proxy{
+		proxy: &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				// SetURL keeps any path prefix of the server URL, such as a cluster proxy's
+				r.SetURL(host)
+				for _, header := range strippedHeaders {
+					r.Out.Header.Del(header)
+				}
+			},
+			Transport: transport,
+			// Flush every write so watch events reach the caller as they happen
+			FlushInterval: -1,
+		},
+		allowed: allowed,
+		denied:  denied,
+	}, nil
+}
+
+// Allowed reports whether apiPath may be proxied
+func (p *Proxy) Allowed(apiPath string) bool {
+	for _, pattern := range p.denied {
+		if matches(pattern, apiPath) {
+			return false
+		}
+	}
+	if len(p.allowed) == 0 {
+		return true
+	}
+	for _, pattern := range p.allowed {
+		if matches(pattern, apiPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Forward sends r to apiPath on the API server, keeping its method, query and body. Hop-by-hop
+// headers are dropped both ways. Paths are cleaned before they are checked, so dot segments
+// cannot escape the deny list. A *DeniedError is returned, with nothing written to w, for
+// paths that are not allowed.
+func (p *Proxy) Forward(w http.ResponseWriter, r *http.Request, apiPath string) error {
+	apiPath = path.Clean("/" + apiPath)
+	if !p.Allowed(apiPath) {
+		return &DeniedError{Path: apiPath}
+	}
+
+	out := r.Clone(r.Context())
+	out.URL = &url.URL{Path: apiPath, RawQuery: r.URL.RawQuery}
+	p.proxy.ServeHTTP(w, out)
+	return nil
+}
+
+// matches reports whether every segment of pattern matches the leading segments of apiPath
+func matches(pattern, apiPath string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(apiPath, "/"), "/")
+	if len(pathSegments) < len(patternSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if ok, _ := path.Match(segment, pathSegments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package rawproxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		path    string
+		want    bool
+	}{
+		{name: "secrets", path: "/api/v1/secrets"},
+		{name: "namespaced secrets", path: "/api/v1/namespaces/default/secrets"},
+		{name: "a secret", path: "/api/v1/namespaces/default/secrets/db-password"},
+		{name: "watched secrets", path: "/api/v1/watch/secrets"},
+		{name: "watched namespaced secrets", path: "/api/v1/watch/namespaces/default/secrets"},
+		{name: "a watched secret", path: "/api/v1/watch/namespaces/default/secrets/db-password"},
+		{name: "configmaps", path: "/api/v1/namespaces/default/configmaps", want: true},
+		{name: "watched configmaps", path: "/api/v1/watch/namespaces/default/configmaps", want: true},
+		{name: "a namespace named secrets", path: "/api/v1/namespaces/secrets", want: true},
+		{name: "allowed", allowed: []string{"/apis/metrics.k8s.io"}, path: "/apis/metrics.k8s.io/v1beta1/nodes", want: true},
+		{name: "not allowed", allowed: []string{"/apis/metrics.k8s.io"}, path: "/metrics"},
+		{name: "denied over allowed", allowed: []string{"/api/v1"}, path: "/api/v1/watch/secrets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &Proxy{allowed: tt.allowed, denied: DefaultDenied}
+			if got := proxy.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	var forwarded []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"ConfigMapList"}`))
+	}))
+	defer server.Close()
+
+	proxy, err := New(&rest.Config{Host: server.URL}, nil, DefaultDenied)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "allowed", path: "api/v1/namespaces/default/configmaps", wantPath: "/api/v1/namespaces/default/configmaps"},
+		{name: "denied", path: "api/v1/watch/namespaces/default/secrets", wantErr: true},
+		{name: "dot segments", path: "api/v1/namespaces/default/configmaps/../../../watch/secrets", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = nil
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/raw/"+tt.path+"?watch=true", nil)
+			req.Header.Set("Authorization", "Bearer caller")
+			rec := httptest.NewRecorder()
+
+			err := proxy.Forward(rec, req, tt.path)
+			if tt.wantErr {
+				var deniedErr *DeniedError
+				if !errors.As(err, &deniedErr) {
+					t.Fatalf("Forward() error = %v, want a *DeniedError", err)
+				}
+				if len(forwarded) != 0 || rec.Body.Len() != 0 {
+					t.Errorf("Forward() reached the API server or wrote %q for a denied path", rec.Body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(forwarded) != 1 {
+				t.Fatalf("API server got %d requests, want 1", len(forwarded))
+			}
+			out := forwarded[0]
+			if out.URL.Path != tt.wantPath || out.URL.RawQuery != "watch=true" {
+				t.Errorf("forwarded to %s, want %s?watch=true", out.URL, tt.wantPath)
+			}
+			if auth := out.Header.Get("Authorization"); auth != "" {
+				t.Errorf("forwarded the caller's Authorization %q", auth)
+			}
+			if rec.Body.String() != `{"kind":"ConfigMapList"}` {
+				t.Errorf("body = %q, want the API server's", rec.Body)
+			}
+		})
+	}
+}
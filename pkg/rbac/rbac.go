@@ -0,0 +1,181 @@
+// Package rbac answers "who can do this" by statically evaluating RBAC rules and bindings.
+// It does not issue SubjectAccessReviews, so authorizers other than RBAC are not considered.
+package rbac
+
+import (
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Request is the access being checked
+type Request struct {
+	Verb         string
+	APIGroup     string
+	Resource     string
+	Subresource  string
+	ResourceName string
+	// Namespace is empty for cluster-wide access, which only ClusterRoleBindings can grant
+	Namespace string
+}
+
+// Grant is a subject allowed to perform the request together with what grants it
+type Grant struct {
+	Subject rbacv1.Subject `json:"subject"`
+	Binding BindingRef     `json:"binding"`
+	Role    rbacv1.RoleRef `json:"role"`
+}
+
+// BindingRef identifies the RoleBinding or ClusterRoleBinding granting access
+type BindingRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Snapshot is the RBAC state evaluated by Subjects
+type Snapshot struct {
+	Roles               []rbacv1.Role
+	ClusterRoles        []rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+}
+
+// Subjects returns every subject allowed to perform the request
+func Subjects(snapshot Snapshot, req Request) []Grant {
+	clusterRoleRules := resolveClusterRoles(snapshot.ClusterRoles)
+
+	roleRules := map[string][]rbacv1.PolicyRule{}
+	for _, role := range snapshot.Roles {
+		roleRules[role.Namespace+"/"+role.Name] = role.Rules
+	}
+
+	var grants []Grant
+	for _, binding := range snapshot.ClusterRoleBindings {
+		if binding.RoleRef.Kind != "ClusterRole" || !RulesAllow(clusterRoleRules[binding.RoleRef.Name], req) {
+			continue
+		}
+		ref := BindingRef{Kind: "ClusterRoleBinding", Name: binding.Name}
+		for _, subject := range binding.Subjects {
+			grants = append(grants, Grant{Subject: subject, Binding: ref, Role: binding.RoleRef})
+		}
+	}
+
+	if req.Namespace == "" {
+		return grants
+	}
+
+	for _, binding := range snapshot.RoleBindings {
+		if binding.Namespace != req.Namespace {
+			continue
+		}
+
+		var rules []rbacv1.PolicyRule
+		switch binding.RoleRef.Kind {
+		case "ClusterRole":
+			rules = clusterRoleRules[binding.RoleRef.Name]
+		case "Role":
+			rules = roleRules[binding.Namespace+"/"+binding.RoleRef.Name]
+		}
+		if !RulesAllow(rules, req) {
+			continue
+		}
+
+		ref := BindingRef{Kind: "RoleBinding", Name: binding.Name, Namespace: binding.Namespace}
+		for _, subject := range binding.Subjects {
+			// Service accounts without a namespace default to the binding's namespace
+			if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+				subject.Namespace = binding.Namespace
+			}
+			grants = append(grants, Grant{Subject: subject, Binding: ref, Role: binding.RoleRef})
+		}
+	}
+	return grants
+}
+
+// resolveClusterRoles returns the rules of each ClusterRole, expanding aggregation rules
+// by collecting the rules of every ClusterRole matched by the aggregation selectors
+func resolveClusterRoles(clusterRoles []rbacv1.ClusterRole) map[string][]rbacv1.PolicyRule {
+	rules := map[string][]rbacv1.PolicyRule{}
+	for _, role := range clusterRoles {
+		rules[role.Name] = append([]rbacv1.PolicyRule{}, role.Rules...)
+	}
+
+	for _, role := range clusterRoles {
+		if role.AggregationRule == nil {
+			continue
+		}
+		for _, labelSelector := range role.AggregationRule.ClusterRoleSelectors {
+			selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+			if err != nil {
+				continue
+			}
+			for _, other := range clusterRoles {
+				if other.Name == role.Name || !selector.Matches(labels.Set(other.Labels)) {
+					continue
+				}
+				rules[role.Name] = append(rules[role.Name], other.Rules...)
+			}
+		}
+	}
+	return rules
+}
+
+// RulesAllow reports whether any rule allows the request
+func RulesAllow(rules []rbacv1.PolicyRule, req Request) bool {
+	for _, rule := range rules {
+		if RuleAllows(rule, req) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleAllows reports whether a single rule allows the request, following the RBAC
+// authorizer's wildcard semantics for verbs, API groups, resources and resource names
+func RuleAllows(rule rbacv1.PolicyRule, req Request) bool {
+	// Non-resource rules never match resource requests
+	if len(rule.Resources) == 0 {
+		return false
+	}
+	return verbMatches(rule, req.Verb) &&
+		apiGroupMatches(rule, req.APIGroup) &&
+		resourceMatches(rule, req.Resource, req.Subresource) &&
+		resourceNameMatches(rule, req.ResourceName)
+}
+
+func verbMatches(rule rbacv1.PolicyRule, verb string) bool {
+	return slices.Contains(rule.Verbs, rbacv1.VerbAll) || slices.Contains(rule.Verbs, verb)
+}
+
+func apiGroupMatches(rule rbacv1.PolicyRule, group string) bool {
+	return slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) || slices.Contains(rule.APIGroups, group)
+}
+
+func resourceMatches(rule rbacv1.PolicyRule, resource, subresource string) bool {
+	combined := resource
+	if subresource != "" {
+		combined = resource + "/" + subresource
+	}
+
+	for _, ruleResource := range rule.Resources {
+		if ruleResource == rbacv1.ResourceAll || ruleResource == combined {
+			return true
+		}
+		// "*/scale" matches the scale subresource of any resource
+		if subresource != "" && strings.HasPrefix(ruleResource, "*/") && ruleResource[2:] == subresource {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceNameMatches(rule rbacv1.PolicyRule, name string) bool {
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+	return name != "" && slices.Contains(rule.ResourceNames, name)
+}
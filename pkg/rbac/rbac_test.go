@@ -0,0 +1,226 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRuleAllows(t *testing.T) {
+	tests := []struct {
+		name string
+		rule rbacv1.PolicyRule
+		req  Request
+		want bool
+	}{
+		{
+			name: "exact match",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			req:  Request{Verb: "delete", Resource: "pods"},
+			want: true,
+		},
+		{
+			name: "other verb",
+			rule: rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			req:  Request{Verb: "delete", Resource: "pods"},
+		},
+		{
+			name: "verb wildcard",
+			rule: rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			req:  Request{Verb: "delete", Resource: "pods"},
+			want: true,
+		},
+		{
+			name: "other api group",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{"apps"}, Resources: []string{"pods"}},
+			req:  Request{Verb: "delete", Resource: "pods"},
+		},
+		{
+			name: "api group wildcard",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{"*"}, Resources: []string{"deployments"}},
+			req:  Request{Verb: "delete", APIGroup: "apps", Resource: "deployments"},
+			want: true,
+		},
+		{
+			name: "resource wildcard",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{"apps"}, Resources: []string{"*"}},
+			req:  Request{Verb: "delete", APIGroup: "apps", Resource: "deployments"},
+			want: true,
+		},
+		{
+			name: "resource wildcard covers subresources",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"apps"}, Resources: []string{"*"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "deployments", Subresource: "scale"},
+			want: true,
+		},
+		{
+			name: "resource without its subresource",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "deployments", Subresource: "scale"},
+		},
+		{
+			name: "subresource",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "deployments", Subresource: "scale"},
+			want: true,
+		},
+		{
+			name: "subresource without the resource",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "deployments"},
+		},
+		{
+			name: "subresource of any resource",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"*"}, Resources: []string{"*/scale"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "statefulsets", Subresource: "scale"},
+			want: true,
+		},
+		{
+			name: "other subresource of any resource",
+			rule: rbacv1.PolicyRule{Verbs: []string{"update"}, APIGroups: []string{"*"}, Resources: []string{"*/scale"}},
+			req:  Request{Verb: "update", APIGroup: "apps", Resource: "statefulsets", Subresource: "status"},
+		},
+		{
+			name: "resource name",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web-0"}},
+			req:  Request{Verb: "delete", Resource: "pods", ResourceName: "web-0"},
+			want: true,
+		},
+		{
+			name: "other resource name",
+			rule: rbacv1.PolicyRule{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web-0"}},
+			req:  Request{Verb: "delete", Resource: "pods", ResourceName: "web-1"},
+		},
+		{
+			name: "resource names never match unnamed requests",
+			rule: rbacv1.PolicyRule{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web-0"}},
+			req:  Request{Verb: "list", Resource: "pods"},
+		},
+		{
+			name: "non-resource rule",
+			rule: rbacv1.PolicyRule{Verbs: []string{"*"}, NonResourceURLs: []string{"*"}},
+			req:  Request{Verb: "get", Resource: "pods"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RuleAllows(tt.rule, tt.req); got != tt.want {
+				t.Errorf("RuleAllows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubjects(t *testing.T) {
+	deletePods := []rbacv1.PolicyRule{{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	readPods := []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	alice := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"}
+	admins := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "admins"}
+	deployer := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer"}
+
+	snapshot := Snapshot{
+		Roles: []rbacv1.Role{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-deleter", Namespace: "prod"}, Rules: deletePods},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-deleter", Namespace: "dev"}, Rules: readPods},
+		},
+		ClusterRoles: []rbacv1.ClusterRole{
+			{ObjectMeta: metav1.ObjectMeta{Name: "reader"}, Rules: readPods},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "aggregated"},
+				AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"aggregate-to-aggregated": "true"}},
+				}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "deleter", Labels: map[string]string{"aggregate-to-aggregated": "true"}},
+				Rules:      deletePods,
+			},
+		},
+		RoleBindings: []rbacv1.RoleBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "prod"},
+				Subjects:   []rbacv1.Subject{deployer},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-deleter"},
+			},
+			{
+				// The Role of the same name in dev doesn't allow deletes
+				ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "dev"},
+				Subjects:   []rbacv1.Subject{deployer},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-deleter"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "readers", Namespace: "prod"},
+				Subjects:   []rbacv1.Subject{alice},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "aggregated", Namespace: "staging"},
+				Subjects:   []rbacv1.Subject{alice},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"},
+			},
+		},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+				Subjects:   []rbacv1.Subject{admins},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want []Grant
+	}{
+		{
+			name: "cluster-wide access only comes from cluster role bindings",
+			req:  Request{Verb: "delete", Resource: "pods"},
+			want: []Grant{
+				{Subject: admins, Binding: BindingRef{Kind: "ClusterRoleBinding", Name: "admins"}, Role: rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"}},
+			},
+		},
+		{
+			name: "role binding defaults the service account namespace",
+			req:  Request{Verb: "delete", Resource: "pods", Namespace: "prod"},
+			want: []Grant{
+				{Subject: admins, Binding: BindingRef{Kind: "ClusterRoleBinding", Name: "admins"}, Role: rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"}},
+				{
+					Subject: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "prod"},
+					Binding: BindingRef{Kind: "RoleBinding", Name: "deployer", Namespace: "prod"},
+					Role:    rbacv1.RoleRef{Kind: "Role", Name: "pod-deleter"},
+				},
+			},
+		},
+		{
+			name: "roles are looked up in the binding's namespace",
+			req:  Request{Verb: "delete", Resource: "pods", Namespace: "dev"},
+			want: []Grant{
+				{Subject: admins, Binding: BindingRef{Kind: "ClusterRoleBinding", Name: "admins"}, Role: rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"}},
+			},
+		},
+		{
+			name: "aggregated cluster role bound in a namespace",
+			req:  Request{Verb: "delete", Resource: "pods", Namespace: "staging"},
+			want: []Grant{
+				{Subject: admins, Binding: BindingRef{Kind: "ClusterRoleBinding", Name: "admins"}, Role: rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"}},
+				{Subject: alice, Binding: BindingRef{Kind: "RoleBinding", Name: "aggregated", Namespace: "staging"}, Role: rbacv1.RoleRef{Kind: "ClusterRole", Name: "aggregated"}},
+			},
+		},
+		{
+			name: "nobody",
+			req:  Request{Verb: "deletecollection", Resource: "pods", Namespace: "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Subjects(snapshot, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subjects() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
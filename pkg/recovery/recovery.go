@@ -0,0 +1,56 @@
+// Package recovery keeps informers delivering events when an event handler panics. An
+// unrecovered panic stops the informer's delivery goroutine, and with it every handler
+// registered on that informer.
+package recovery
+
+import (
+	"log"
+	"runtime/debug"
+
+	"kgent-api/pkg/metrics"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+var panicsTotal = metrics.NewCounter("kgent_informer_handler_panics_total",
+	"Panics recovered in informer event handlers.", "handler")
+
+// Handler wraps inner so that a panic while handling an event is logged with its stack,
+// counted under name and passed to onPanic, when set. The event is dropped and the
+// informer keeps delivering the next ones.
+func Handler(name string, inner cache.ResourceEventHandler, onPanic func(any)) cache.ResourceEventHandler {
+	return &handler{name: name, inner: inner, onPanic: onPanic}
+}
+
+type handler struct {
+	name    string
+	inner   cache.ResourceEventHandler
+	onPanic func(any)
+}
+
+func (h *handler) OnAdd(obj interface{}, isInInitialList bool) {
+	defer h.recover("add")
+	h.inner.OnAdd(obj, isInInitialList)
+}
+
+func (h *handler) OnUpdate(oldObj, newObj interface{}) {
+	defer h.recover("update")
+	h.inner.OnUpdate(oldObj, newObj)
+}
+
+func (h *handler) OnDelete(obj interface{}) {
+	defer h.recover("delete")
+	h.inner.OnDelete(obj)
+}
+
+func (h *handler) recover(event string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	log.Printf("Recovered panic in %s handler %s: %v\n%s", event, h.name, r, debug.Stack())
+	panicsTotal.Inc(h.name)
+	if h.onPanic != nil {
+		h.onPanic(r)
+	}
+}
@@ -0,0 +1,168 @@
+// Package refs walks objects for the ConfigMaps, Secrets and PersistentVolumeClaims they
+// reference, with the path of each reference, so consumers of an object can be listed and
+// unreferenced objects found the same way.
+package refs
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Kinds of the objects references point at
+const (
+	KindConfigMap             = "ConfigMap"
+	KindSecret                = "Secret"
+	KindPersistentVolumeClaim = "PersistentVolumeClaim"
+)
+
+// Ref is a reference to an object in the referrer's namespace
+type Ref struct {
+	Kind string
+	Name string
+	// Path is where the reference is in the referrer, e.g. spec.containers[0].envFrom[1]
+	Path string
+}
+
+// Paths where pod specs sit in the objects that hold them
+const (
+	PodSpecPath      = "spec"
+	TemplateSpecPath = "spec.template.spec"
+	CronJobSpecPath  = "spec.jobTemplate.spec.template.spec"
+)
+
+// PodSpec returns the references of a pod spec found at path: volumes, including projected
+// sources, envFrom, env valueFrom and imagePullSecrets of every kind of container
+func PodSpec(spec *v1.PodSpec, path string) []Ref {
+	var refs []Ref
+	add := func(kind, name, format string, args ...any) {
+		if name != "" {
+			refs = append(refs, Ref{Kind: kind, Name: name, Path: path + "." + fmt.Sprintf(format, args...)})
+		}
+	}
+
+	for i, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			add(KindConfigMap, volume.ConfigMap.Name, "volumes[%d].configMap", i)
+		}
+		if volume.Secret != nil {
+			add(KindSecret, volume.Secret.SecretName, "volumes[%d].secret", i)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			add(KindPersistentVolumeClaim, volume.PersistentVolumeClaim.ClaimName, "volumes[%d].persistentVolumeClaim", i)
+		}
+		if volume.CSI != nil && volume.CSI.NodePublishSecretRef != nil {
+			add(KindSecret, volume.CSI.NodePublishSecretRef.Name, "volumes[%d].csi.nodePublishSecretRef", i)
+		}
+		if volume.Projected != nil {
+			for j, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(KindConfigMap, source.ConfigMap.Name, "volumes[%d].projected.sources[%d].configMap", i, j)
+				}
+				if source.Secret != nil {
+					add(KindSecret, source.Secret.Name, "volumes[%d].projected.sources[%d].secret", i, j)
+				}
+			}
+		}
+	}
+
+	for i, ref := range spec.ImagePullSecrets {
+		add(KindSecret, ref.Name, "imagePullSecrets[%d]", i)
+	}
+
+	for i := range spec.InitContainers {
+		refs = append(refs, container(&spec.InitContainers[i], fmt.Sprintf("%s.initContainers[%d]", path, i))...)
+	}
+	for i := range spec.Containers {
+		refs = append(refs, container(&spec.Containers[i], fmt.Sprintf("%s.containers[%d]", path, i))...)
+	}
+	for i := range spec.EphemeralContainers {
+		c := v1.Container(spec.EphemeralContainers[i].EphemeralContainerCommon)
+		refs = append(refs, container(&c, fmt.Sprintf("%s.ephemeralContainers[%d]", path, i))...)
+	}
+	return refs
+}
+
+func container(c *v1.Container, path string) []Ref {
+	var refs []Ref
+	for i, envFrom := range c.EnvFrom {
+		if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name != "" {
+			refs = append(refs, Ref{Kind: KindConfigMap, Name: envFrom.ConfigMapRef.Name, Path: fmt.Sprintf("%s.envFrom[%d]", path, i)})
+		}
+		if envFrom.SecretRef != nil && envFrom.SecretRef.Name != "" {
+			refs = append(refs, Ref{Kind: KindSecret, Name: envFrom.SecretRef.Name, Path: fmt.Sprintf("%s.envFrom[%d]", path, i)})
+		}
+	}
+	for i, env := range c.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && ref.Name != "" {
+			refs = append(refs, Ref{Kind: KindConfigMap, Name: ref.Name, Path: fmt.Sprintf("%s.env[%d].valueFrom.configMapKeyRef", path, i)})
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil && ref.Name != "" {
+			refs = append(refs, Ref{Kind: KindSecret, Name: ref.Name, Path: fmt.Sprintf("%s.env[%d].valueFrom.secretKeyRef", path, i)})
+		}
+	}
+	return refs
+}
+
+// ServiceAccount returns the Secrets a ServiceAccount references, as image pull secrets
+// and in its secrets list
+func ServiceAccount(sa *v1.ServiceAccount) []Ref {
+	var refs []Ref
+	for i, ref := range sa.ImagePullSecrets {
+		if ref.Name != "" {
+			refs = append(refs, Ref{Kind: KindSecret, Name: ref.Name, Path: fmt.Sprintf("imagePullSecrets[%d]", i)})
+		}
+	}
+	for i, ref := range sa.Secrets {
+		// Secrets of other namespaces can't be mounted, the reference is meaningless
+		if ref.Name != "" && (ref.Namespace == "" || ref.Namespace == sa.Namespace) {
+			refs = append(refs, Ref{Kind: KindSecret, Name: ref.Name, Path: fmt.Sprintf("secrets[%d]", i)})
+		}
+	}
+	return refs
+}
+
+// Ingress returns the TLS Secrets of an Ingress, and the ConfigMaps and Secrets its default
+// and path backends name as resource backends
+func Ingress(ingress *networkingv1.Ingress) []Ref {
+	var refs []Ref
+	for i, tls := range ingress.Spec.TLS {
+		if tls.SecretName != "" {
+			refs = append(refs, Ref{Kind: KindSecret, Name: tls.SecretName, Path: fmt.Sprintf("spec.tls[%d].secretName", i)})
+		}
+	}
+	backend := func(b *networkingv1.IngressBackend, path string) {
+		// Resource backends of other API groups are custom resources, not core objects
+		if b == nil || b.Resource == nil || (b.Resource.APIGroup != nil && *b.Resource.APIGroup != "") || b.Resource.Name == "" {
+			return
+		}
+		if b.Resource.Kind == KindConfigMap || b.Resource.Kind == KindSecret {
+			refs = append(refs, Ref{Kind: b.Resource.Kind, Name: b.Resource.Name, Path: path + ".resource"})
+		}
+	}
+	backend(ingress.Spec.DefaultBackend, "spec.defaultBackend")
+	for i, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for j := range rule.HTTP.Paths {
+			backend(&rule.HTTP.Paths[j].Backend, fmt.Sprintf("spec.rules[%d].http.paths[%d].backend", i, j))
+		}
+	}
+	return refs
+}
+
+// Names returns the names of the objects of kind among refs
+func Names(refs []Ref, kind string) []string {
+	var names []string
+	for _, ref := range refs {
+		if ref.Kind == kind {
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
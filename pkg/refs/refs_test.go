@@ -0,0 +1,205 @@
+package refs
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1.PodSpec
+		path string
+		want []Ref
+	}{
+		{
+			name: "volumes",
+			spec: v1.PodSpec{Volumes: []v1.Volume{
+				{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "web-config"}}}},
+				{Name: "tls", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "web-tls"}}},
+				{Name: "data", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "web-data"}}},
+				{Name: "vault", VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{Driver: "secrets-store.csi.k8s.io", NodePublishSecretRef: &v1.LocalObjectReference{Name: "vault-creds"}}}},
+				{Name: "scratch", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			}},
+			path: PodSpecPath,
+			want: []Ref{
+				{Kind: KindConfigMap, Name: "web-config", Path: "spec.volumes[0].configMap"},
+				{Kind: KindSecret, Name: "web-tls", Path: "spec.volumes[1].secret"},
+				{Kind: KindPersistentVolumeClaim, Name: "web-data", Path: "spec.volumes[2].persistentVolumeClaim"},
+				{Kind: KindSecret, Name: "vault-creds", Path: "spec.volumes[3].csi.nodePublishSecretRef"},
+			},
+		},
+		{
+			name: "projected sources",
+			spec: v1.PodSpec{Volumes: []v1.Volume{{Name: "bundle", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{ServiceAccountToken: &v1.ServiceAccountTokenProjection{Path: "token"}},
+				{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: "ca-bundle"}}},
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "client-cert"}}},
+			}}}}}},
+			path: TemplateSpecPath,
+			want: []Ref{
+				{Kind: KindConfigMap, Name: "ca-bundle", Path: "spec.template.spec.volumes[0].projected.sources[1].configMap"},
+				{Kind: KindSecret, Name: "client-cert", Path: "spec.template.spec.volumes[0].projected.sources[2].secret"},
+			},
+		},
+		{
+			name: "envFrom",
+			spec: v1.PodSpec{Containers: []v1.Container{{Name: "web", EnvFrom: []v1.EnvFromSource{
+				{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "web-env"}}},
+				{Prefix: "DB_", SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "db-creds"}}},
+			}}}},
+			path: PodSpecPath,
+			want: []Ref{
+				{Kind: KindConfigMap, Name: "web-env", Path: "spec.containers[0].envFrom[0]"},
+				{Kind: KindSecret, Name: "db-creds", Path: "spec.containers[0].envFrom[1]"},
+			},
+		},
+		{
+			name: "env valueFrom",
+			spec: v1.PodSpec{Containers: []v1.Container{{Name: "web", Env: []v1.EnvVar{
+				{Name: "MODE", Value: "production"},
+				{Name: "LOG_LEVEL", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "web-env"}, Key: "level"}}},
+				{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				{Name: "PASSWORD", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "db-creds"}, Key: "password"}}},
+			}}}},
+			path: PodSpecPath,
+			want: []Ref{
+				{Kind: KindConfigMap, Name: "web-env", Path: "spec.containers[0].env[1].valueFrom.configMapKeyRef"},
+				{Kind: KindSecret, Name: "db-creds", Path: "spec.containers[0].env[3].valueFrom.secretKeyRef"},
+			},
+		},
+		{
+			name: "imagePullSecrets",
+			spec: v1.PodSpec{ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}, {Name: ""}, {Name: "mirror"}}},
+			path: CronJobSpecPath,
+			want: []Ref{
+				{Kind: KindSecret, Name: "registry", Path: "spec.jobTemplate.spec.template.spec.imagePullSecrets[0]"},
+				{Kind: KindSecret, Name: "mirror", Path: "spec.jobTemplate.spec.template.spec.imagePullSecrets[2]"},
+			},
+		},
+		{
+			name: "init and ephemeral containers",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{{Name: "migrate", EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "db-creds"}}}}}},
+				Containers:     []v1.Container{{Name: "web"}},
+				EphemeralContainers: []v1.EphemeralContainer{{EphemeralContainerCommon: v1.EphemeralContainerCommon{
+					Name:    "debugger",
+					EnvFrom: []v1.EnvFromSource{{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "debug-env"}}}},
+				}}},
+			},
+			path: PodSpecPath,
+			want: []Ref{
+				{Kind: KindSecret, Name: "db-creds", Path: "spec.initContainers[0].envFrom[0]"},
+				{Kind: KindConfigMap, Name: "debug-env", Path: "spec.ephemeralContainers[0].envFrom[0]"},
+			},
+		},
+		{
+			name: "empty names",
+			spec: v1.PodSpec{
+				Volumes:    []v1.Volume{{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{}}}},
+				Containers: []v1.Container{{Name: "web", EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{}}}}},
+			},
+			path: PodSpecPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodSpec(&tt.spec, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PodSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceAccount(t *testing.T) {
+	sa := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+		Secrets: []v1.ObjectReference{
+			{Name: "deployer-token"},
+			{Name: "shared-token", Namespace: "default"},
+			{Name: "elsewhere", Namespace: "kube-system"},
+		},
+	}
+	want := []Ref{
+		{Kind: KindSecret, Name: "registry", Path: "imagePullSecrets[0]"},
+		{Kind: KindSecret, Name: "deployer-token", Path: "secrets[0]"},
+		{Kind: KindSecret, Name: "shared-token", Path: "secrets[1]"},
+	}
+	if got := ServiceAccount(sa); !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceAccount() = %+v, want %+v", got, want)
+	}
+}
+
+func TestIngress(t *testing.T) {
+	serviceBackend := networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web", Port: networkingv1.ServiceBackendPort{Number: 80}}}
+	resourceBackend := func(group *string, kind, name string) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Resource: &v1.TypedLocalObjectReference{APIGroup: group, Kind: kind, Name: name}}
+	}
+
+	tests := []struct {
+		name string
+		spec networkingv1.IngressSpec
+		want []Ref
+	}{
+		{
+			name: "tls",
+			spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"web.example.com"}, SecretName: "web-tls"},
+				{Hosts: []string{"default.example.com"}},
+			}},
+			want: []Ref{{Kind: KindSecret, Name: "web-tls", Path: "spec.tls[0].secretName"}},
+		},
+		{
+			name: "backends",
+			spec: networkingv1.IngressSpec{
+				DefaultBackend: ptr.To(resourceBackend(nil, "ConfigMap", "maintenance-page")),
+				Rules: []networkingv1.IngressRule{
+					{Host: "web.example.com"},
+					{Host: "static.example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{
+						{Path: "/", Backend: serviceBackend},
+						{Path: "/assets", Backend: resourceBackend(ptr.To(""), "Secret", "signed-assets")},
+						{Path: "/bucket", Backend: resourceBackend(ptr.To("k8s.example.com"), "ConfigMap", "not-core")},
+						{Path: "/other", Backend: resourceBackend(nil, "Service", "web")},
+					}}}},
+				},
+			},
+			want: []Ref{
+				{Kind: KindConfigMap, Name: "maintenance-page", Path: "spec.defaultBackend.resource"},
+				{Kind: KindSecret, Name: "signed-assets", Path: "spec.rules[1].http.paths[1].backend.resource"},
+			},
+		},
+		{
+			name: "service backends only",
+			spec: networkingv1.IngressSpec{DefaultBackend: &serviceBackend},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Ingress(&networkingv1.Ingress{Spec: tt.spec}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ingress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	refs := []Ref{
+		{Kind: KindConfigMap, Name: "web-env"},
+		{Kind: KindSecret, Name: "db-creds"},
+		{Kind: KindConfigMap, Name: "ca-bundle"},
+	}
+	if got := Names(refs, KindConfigMap); !reflect.DeepEqual(got, []string{"web-env", "ca-bundle"}) {
+		t.Errorf("Names(ConfigMap) = %v, want [web-env ca-bundle]", got)
+	}
+	if got := Names(refs, KindPersistentVolumeClaim); got != nil {
+		t.Errorf("Names(PersistentVolumeClaim) = %v, want none", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Package render expands Go templates in manifests, with the sprig function library,
+// before they are created or validated.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// templateName is the name reported in template error messages
+const templateName = "manifest"
+
+// errorPosition matches the "template: manifest:LINE:COL: message" prefix of parse and exec errors;
+// parse errors carry only the line
+var errorPosition = regexp.MustCompile(`^template: ` + templateName + `:(\d+)(?::(\d+))?: (.*)$`)
+
+// Error is a template error with the position it was raised at. Column is 0 when unknown.
+type Error struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("template error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("template error at line %d: %s", e.Line, e.Message)
+}
+
+// Render executes the manifest template with values available as .Values.
+// Referencing a missing key is an error rather than rendering "<no value>".
+func Render(manifest string, values map[string]interface{}) (string, error) {
+	tmpl, err := template.New(templateName).
+		Funcs(sprig.TxtFuncMap()).
+		Option("missingkey=error").
+		Parse(manifest)
+	if err != nil {
+		return "", positioned(err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, map[string]interface{}{"Values": values}); err != nil {
+		return "", positioned(err)
+	}
+	return out.String(), nil
+}
+
+// positioned converts a text/template error into an *Error when its position can be parsed
+func positioned(err error) error {
+	match := errorPosition.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	column, _ := strconv.Atoi(match[2])
+	return &Error{Line: line, Column: column, Message: match[3]}
+}
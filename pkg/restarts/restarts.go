@@ -0,0 +1,200 @@
+// Package restarts keeps the recent history of container restart counts, which pods only
+// report as a total since creation, so restarts can be attributed to a time window. Only
+// changes of the counts are kept, a bounded number per container, and pods are forgotten
+// when they are deleted.
+package restarts
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+	"kgent-api/pkg/recovery"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+var trackedPods = metrics.NewGauge("kgent_restart_tracker_pods",
+	"Pods whose restart counts are tracked.")
+
+// sample is a restart count and when it was first seen
+type sample struct {
+	time  time.Time
+	count int32
+}
+
+// podHistory is the restart count history of each container of a pod. The first sample of
+// a container is its count when the pod was first seen, the baseline restarts are counted from.
+type podHistory struct {
+	namespace  string
+	name       string
+	containers map[string][]sample
+}
+
+// Tracker follows the restart counts of pods through the pod informer
+type Tracker struct {
+	started    time.Time
+	retention  time.Duration
+	maxSamples int
+
+	mu   sync.RWMutex
+	pods map[types.UID]*podHistory
+}
+
+// Restart is how often a container restarted within a window
+type Restart struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	UID       types.UID `json:"-"`
+	Container string    `json:"container"`
+	// Delta is the restarts within the window, Restarts the total since the pod was created
+	Delta    int32     `json:"delta"`
+	Restarts int32     `json:"restarts"`
+	LastSeen time.Time `json:"lastRestartSeen"`
+}
+
+// NewTracker keeps the changes of the last retention, at most maxSamples per container
+func NewTracker(retention time.Duration, maxSamples int) *Tracker {
+	return &Tracker{
+		started:    time.Now(),
+		retention:  retention,
+		maxSamples: max(maxSamples, 2),
+		pods:       map[types.UID]*podHistory{},
+	}
+}
+
+// Watch follows the pods of the informer
+func (t *Tracker) Watch(informer cache.SharedIndexInformer) error {
+	_, err := informer.AddEventHandler(recovery.Handler("restarts", cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				t.observe(pod)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if pod, ok := newObj.(*v1.Pod); ok {
+				t.observe(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				t.forget(pod.UID)
+			}
+		},
+	}, nil))
+	return err
+}
+
+func (t *Tracker) observe(pod *v1.Pod) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	history, ok := t.pods[pod.UID]
+	if !ok {
+		history = &podHistory{namespace: pod.Namespace, name: pod.Name, containers: map[string][]sample{}}
+		t.pods[pod.UID] = history
+		trackedPods.Set(float64(len(t.pods)))
+	}
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			samples := history.containers[status.Name]
+			if n := len(samples); n > 0 && samples[n-1].count == status.RestartCount {
+				continue
+			}
+			history.containers[status.Name] = t.trim(append(samples, sample{time: now, count: status.RestartCount}), now)
+		}
+	}
+}
+
+// trim drops samples past the retention or the sample limit, keeping the newest dropped
+// one as the baseline of what remains
+func (t *Tracker) trim(samples []sample, now time.Time) []sample {
+	cutoff := now.Add(-t.retention)
+	drop := 0
+	for drop+1 < len(samples) && samples[drop+1].time.Before(cutoff) {
+		drop++
+	}
+	drop = max(drop, len(samples)-t.maxSamples)
+	if drop == 0 {
+		return samples
+	}
+	return append(samples[:0], samples[drop:]...)
+}
+
+func (t *Tracker) forget(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pods, uid)
+	trackedPods.Set(float64(len(t.pods)))
+}
+
+// Since returns the containers of pods in namespace, every namespace when empty, whose
+// restart count went up after since, most restarts first. Restarts from before the tracker
+// or the pod were first seen are not counted; see Window.
+func (t *Tracker) Since(since time.Time, namespace string) []Restart {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	restarts := []Restart{}
+	for uid, history := range t.pods {
+		if namespace != "" && history.namespace != namespace {
+			continue
+		}
+		for container, samples := range history.containers {
+			baseline := samples[0]
+			for _, s := range samples[1:] {
+				if s.time.After(since) {
+					break
+				}
+				baseline = s
+			}
+			last := samples[len(samples)-1]
+			if last.count <= baseline.count {
+				continue
+			}
+			restarts = append(restarts, Restart{
+				Namespace: history.namespace,
+				Pod:       history.name,
+				UID:       uid,
+				Container: container,
+				Delta:     last.count - baseline.count,
+				Restarts:  last.count,
+				LastSeen:  last.time,
+			})
+		}
+	}
+	sort.Slice(restarts, func(i, j int) bool {
+		if restarts[i].Delta != restarts[j].Delta {
+			return restarts[i].Delta > restarts[j].Delta
+		}
+		if restarts[i].Namespace != restarts[j].Namespace {
+			return restarts[i].Namespace < restarts[j].Namespace
+		}
+		if restarts[i].Pod != restarts[j].Pod {
+			return restarts[i].Pod < restarts[j].Pod
+		}
+		return restarts[i].Container < restarts[j].Container
+	})
+	return restarts
+}
+
+// Window is the part of the window since since that the tracker actually covers: from
+// when it started or its retention, whichever is later. Complete is unset when that
+// doesn't reach back to since.
+func (t *Tracker) Window(since time.Time) (start time.Time, complete bool) {
+	earliest := t.started
+	if retained := time.Now().Add(-t.retention); retained.After(earliest) {
+		earliest = retained
+	}
+	if since.Before(earliest) {
+		return earliest, false
+	}
+	return since, true
+}
@@ -0,0 +1,112 @@
+// Package retry retries idempotent Kubernetes API calls that failed for transient reasons,
+// such as server timeouts, throttling and dropped connections, with exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"kgent-api/pkg/metrics"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+const (
+	maxAttempts    = 4
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 2 * time.Second
+)
+
+var retriesTotal = metrics.NewCounter("kgent_api_retries_total",
+	"Kubernetes API calls retried after a transient error.", "operation", "reason")
+
+// Counter counts the retries made while serving a single request
+type Counter struct {
+	n atomic.Int64
+}
+
+// Count returns the retries made so far. It is safe to call on a nil Counter.
+func (c *Counter) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n.Load()
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the counter
+func NewContext(ctx context.Context, c *Counter) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the counter carried by ctx, or nil
+func FromContext(ctx context.Context) *Counter {
+	c, _ := ctx.Value(contextKey{}).(*Counter)
+	return c
+}
+
+// Do calls fn until it succeeds or fails with an error that is not transient. It gives up
+// after maxAttempts, or earlier when the next wait would run past the deadline of ctx, and
+// returns the last error. fn must be safe to repeat. attempt starts at 1.
+func Do(ctx context.Context, operation string, fn func(attempt int) error) error {
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+
+		reason, wait, ok := classify(err)
+		if !ok || attempt == maxAttempts {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		retriesTotal.Inc(operation, reason)
+		if counter := FromContext(ctx); counter != nil {
+			counter.n.Add(1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// classify reports whether err is transient, why, and how long the server asked to wait
+func classify(err error) (reason string, wait time.Duration, retriable bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "", 0, false
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	switch {
+	case apierrors.IsTooManyRequests(err):
+		return "too_many_requests", wait, true
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err):
+		return "server_timeout", wait, true
+	case utilnet.IsConnectionReset(err), utilnet.IsConnectionRefused(err), utilnet.IsProbableEOF(err):
+		return "network", 0, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "network", 0, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,64 @@
+package scheduling
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// failedSchedulingPattern matches the start of the scheduler's FailedScheduling message,
+// e.g. "0/5 nodes are available: 3 Insufficient cpu, 2 node(s) had untolerated taint {a: b}."
+var failedSchedulingPattern = regexp.MustCompile(`^(\d+)/(\d+) nodes are available:?\s*(.*)$`)
+
+// reasonPattern matches one "<count> <reason>" item of the message
+var reasonPattern = regexp.MustCompile(`^(\d+) (.+)$`)
+
+// FailedScheduling is a FailedScheduling event message broken down into node counts
+type FailedScheduling struct {
+	Message   string `json:"message"`
+	Available int    `json:"available"`
+	Total     int    `json:"total"`
+	// Reasons are the predicates nodes failed, with how many nodes failed each
+	Reasons []ReasonCount `json:"reasons"`
+	// Preemption is what the scheduler said about preempting other pods, if anything
+	Preemption string `json:"preemption,omitempty"`
+	// Parsed is unset when the message wasn't in the expected format, leaving only Message
+	Parsed bool `json:"parsed"`
+}
+
+// ReasonCount is a predicate failure and the number of nodes failing it
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Nodes  int    `json:"nodes"`
+}
+
+// ParseFailedScheduling breaks down a FailedScheduling message. Messages in other formats,
+// such as volume binding errors, are returned unparsed.
+func ParseFailedScheduling(message string) FailedScheduling {
+	result := FailedScheduling{Message: message, Reasons: []ReasonCount{}}
+
+	main, preemption, _ := strings.Cut(message, " preemption: ")
+	result.Preemption = strings.TrimSpace(preemption)
+	match := failedSchedulingPattern.FindStringSubmatch(strings.TrimSpace(main))
+	if match == nil {
+		return result
+	}
+	result.Available, _ = strconv.Atoi(match[1])
+	result.Total, _ = strconv.Atoi(match[2])
+
+	// Reasons are separated by ", ", which taint lists and the like also contain, so a
+	// fragment not starting with a count continues the previous reason
+	body := strings.TrimSuffix(strings.TrimSpace(match[3]), ".")
+	for _, fragment := range strings.Split(body, ", ") {
+		if m := reasonPattern.FindStringSubmatch(fragment); m != nil {
+			nodes, _ := strconv.Atoi(m[1])
+			result.Reasons = append(result.Reasons, ReasonCount{Reason: m[2], Nodes: nodes})
+			continue
+		}
+		if n := len(result.Reasons); n > 0 {
+			result.Reasons[n-1].Reason += ", " + fragment
+		}
+	}
+	result.Parsed = true
+	return result
+}
@@ -0,0 +1,62 @@
+package scheduling
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFailedScheduling(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    FailedScheduling
+	}{
+		{
+			name:    "reasons",
+			message: "0/5 nodes are available: 3 Insufficient cpu, 2 node(s) didn't match Pod's node affinity/selector.",
+			want: FailedScheduling{
+				Total: 5,
+				Reasons: []ReasonCount{
+					{Reason: "Insufficient cpu", Nodes: 3},
+					{Reason: "node(s) didn't match Pod's node affinity/selector", Nodes: 2},
+				},
+				Parsed: true,
+			},
+		},
+		{
+			name:    "taints containing the separator",
+			message: "1/4 nodes are available: 3 node(s) had untolerated taint {gpu: true}, {dedicated: db}.",
+			want: FailedScheduling{
+				Available: 1,
+				Total:     4,
+				Reasons:   []ReasonCount{{Reason: "node(s) had untolerated taint {gpu: true}, {dedicated: db}", Nodes: 3}},
+				Parsed:    true,
+			},
+		},
+		{
+			name: "preemption",
+			message: "0/3 nodes are available: 3 Insufficient memory. preemption: 0/3 nodes are available: " +
+				"3 No preemption victims found for incoming pod.",
+			want: FailedScheduling{
+				Total:      3,
+				Reasons:    []ReasonCount{{Reason: "Insufficient memory", Nodes: 3}},
+				Preemption: "0/3 nodes are available: 3 No preemption victims found for incoming pod.",
+				Parsed:     true,
+			},
+		},
+		{
+			name:    "other format",
+			message: "running PreBind plugin \"VolumeBinding\": binding volumes: timed out waiting for the condition",
+			want:    FailedScheduling{Reasons: []ReasonCount{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Message = tt.message
+			if got := ParseFailedScheduling(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFailedScheduling() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Package scheduling explains why a pod does or doesn't fit on nodes. It covers the common
+// scheduler predicates only and is an approximation: pod affinity, topology spread, volume
+// topology, host ports and scheduler plugins are not evaluated.
+package scheduling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kgent-api/pkg/capacity"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// Predicates evaluated, naming what blocks a node in NodeVerdict.Blocked
+const (
+	PredicateNodeName      = "NodeName"
+	PredicateUnschedulable = "NodeUnschedulable"
+	PredicateNodeSelector  = "NodeSelector"
+	PredicateNodeAffinity  = "NodeAffinity"
+	PredicateTaint         = "TaintToleration"
+	PredicateResources     = "NodeResourcesFit"
+)
+
+// NotEvaluated lists what the scheduler checks that this package doesn't
+var NotEvaluated = []string{
+	"inter-pod affinity and anti-affinity",
+	"topology spread constraints",
+	"volume binding and topology",
+	"host ports",
+	"extended resources",
+	"scheduler plugins and extenders",
+}
+
+// NodeVerdict is whether a pod fits on a node, and what keeps it off otherwise
+type NodeVerdict struct {
+	Node    string    `json:"node"`
+	Fits    bool      `json:"fits"`
+	Blocked []Blocker `json:"blocked,omitempty"`
+}
+
+// Blocker is a predicate a node fails, with why
+type Blocker struct {
+	Predicate string `json:"predicate"`
+	Reason    string `json:"reason"`
+}
+
+// Evaluate checks pod against every node, pods being those already scheduled, whose
+// requests take up room. Verdicts are sorted with fitting nodes first, then by name.
+func Evaluate(pod *v1.Pod, nodes []*v1.Node, pods []*v1.Pod) []NodeVerdict {
+	report := capacity.Summarize(nodes, pods, 100)
+	usage := make(map[string]capacity.NodeCapacity, len(report.Nodes))
+	for _, nc := range report.Nodes {
+		usage[nc.Name] = nc
+	}
+	requests, _ := capacity.PodRequestsAndLimits(pod)
+
+	verdicts := make([]NodeVerdict, 0, len(nodes))
+	for _, node := range nodes {
+		var blocked []Blocker
+		blocked = append(blocked, nodeName(pod, node)...)
+		blocked = append(blocked, unschedulable(pod, node)...)
+		blocked = append(blocked, nodeSelector(pod, node)...)
+		blocked = append(blocked, nodeAffinity(pod, node)...)
+		blocked = append(blocked, taints(pod, node)...)
+		blocked = append(blocked, resources(requests, usage[node.Name])...)
+		verdicts = append(verdicts, NodeVerdict{Node: node.Name, Fits: len(blocked) == 0, Blocked: blocked})
+	}
+	sort.Slice(verdicts, func(i, j int) bool {
+		if verdicts[i].Fits != verdicts[j].Fits {
+			return verdicts[i].Fits
+		}
+		return verdicts[i].Node < verdicts[j].Node
+	})
+	return verdicts
+}
+
+func nodeName(pod *v1.Pod, node *v1.Node) []Blocker {
+	if pod.Spec.NodeName != "" && pod.Spec.NodeName != node.Name {
+		return []Blocker{{PredicateNodeName, fmt.Sprintf("pod is bound to node %s", pod.Spec.NodeName)}}
+	}
+	return nil
+}
+
+func unschedulable(pod *v1.Pod, node *v1.Node) []Blocker {
+	if !node.Spec.Unschedulable {
+		return nil
+	}
+	// Cordoned nodes carry this taint, which pods like DaemonSet pods tolerate
+	taint := &v1.Taint{Key: v1.TaintNodeUnschedulable, Effect: v1.TaintEffectNoSchedule}
+	if tolerates(pod, taint) {
+		return nil
+	}
+	return []Blocker{{PredicateUnschedulable, "node is cordoned"}}
+}
+
+func nodeSelector(pod *v1.Pod, node *v1.Node) []Blocker {
+	var blocked []Blocker
+	keys := make([]string, 0, len(pod.Spec.NodeSelector))
+	for key := range pod.Spec.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		want := pod.Spec.NodeSelector[key]
+		got, ok := node.Labels[key]
+		switch {
+		case !ok:
+			blocked = append(blocked, Blocker{PredicateNodeSelector, fmt.Sprintf("label mismatch: node has no label %s, nodeSelector wants %s=%s", key, key, want)})
+		case got != want:
+			blocked = append(blocked, Blocker{PredicateNodeSelector, fmt.Sprintf("label mismatch: node has %s=%s, nodeSelector wants %s=%s", key, got, key, want)})
+		}
+	}
+	return blocked
+}
+
+// nodeAffinity checks the required node affinity: the node must match one of the terms,
+// and a term matches when all its expressions and fields do
+func nodeAffinity(pod *v1.Pod, node *v1.Node) []Blocker {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+
+	var mismatches []string
+	for _, term := range terms {
+		mismatch := termMismatch(term, node)
+		if mismatch == "" {
+			return nil
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	if len(mismatches) == 0 {
+		return []Blocker{{PredicateNodeAffinity, "required node affinity has no terms, which matches no node"}}
+	}
+	return []Blocker{{PredicateNodeAffinity, "label mismatch: node matches no required node affinity term: " + strings.Join(mismatches, "; ")}}
+}
+
+var operators = map[v1.NodeSelectorOperator]selection.Operator{
+	v1.NodeSelectorOpIn:           selection.In,
+	v1.NodeSelectorOpNotIn:        selection.NotIn,
+	v1.NodeSelectorOpExists:       selection.Exists,
+	v1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	v1.NodeSelectorOpGt:           selection.GreaterThan,
+	v1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// termMismatch returns the first expression of term node doesn't match, empty when it
+// matches them all
+func termMismatch(term v1.NodeSelectorTerm, node *v1.Node) string {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return "empty term"
+	}
+	for _, expr := range term.MatchExpressions {
+		if !matches(expr, labels.Set(node.Labels)) {
+			return describe(expr)
+		}
+	}
+	for _, expr := range term.MatchFields {
+		// metadata.name is the only field the scheduler supports
+		if expr.Key != "metadata.name" || !matches(expr, labels.Set{"metadata.name": node.Name}) {
+			return describe(expr)
+		}
+	}
+	return ""
+}
+
+func matches(expr v1.NodeSelectorRequirement, set labels.Set) bool {
+	op, ok := operators[expr.Operator]
+	if !ok {
+		return false
+	}
+	requirement, err := labels.NewRequirement(expr.Key, op, expr.Values)
+	if err != nil {
+		return false
+	}
+	return requirement.Matches(set)
+}
+
+func describe(expr v1.NodeSelectorRequirement) string {
+	if len(expr.Values) == 0 {
+		return fmt.Sprintf("%s %s", expr.Key, expr.Operator)
+	}
+	return fmt.Sprintf("%s %s [%s]", expr.Key, expr.Operator, strings.Join(expr.Values, ", "))
+}
+
+// taints reports the NoSchedule and NoExecute taints the pod doesn't tolerate.
+// PreferNoSchedule taints only lower a node's score.
+func taints(pod *v1.Pod, node *v1.Node) []Blocker {
+	var blocked []Blocker
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule || tolerates(pod, taint) {
+			continue
+		}
+		description := taint.Key
+		if taint.Value != "" {
+			description += "=" + taint.Value
+		}
+		blocked = append(blocked, Blocker{PredicateTaint, fmt.Sprintf("blocked by taint %s:%s", description, taint.Effect)})
+	}
+	return blocked
+}
+
+func tolerates(pod *v1.Pod, taint *v1.Taint) bool {
+	for i := range pod.Spec.Tolerations {
+		if pod.Spec.Tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// resources compares the pod's requests with what is left of the node's allocatable once
+// the requests of its pods are taken
+func resources(requests capacity.Resources, nc capacity.NodeCapacity) []Blocker {
+	var blocked []Blocker
+	free := nc.Free()
+	if requests.CPU.Cmp(free.CPU) > 0 {
+		blocked = append(blocked, Blocker{PredicateResources, fmt.Sprintf("insufficient cpu: requests %s, %s free of %s allocatable", requests.CPU.String(), free.CPU.String(), nc.Allocatable.CPU.String())})
+	}
+	if requests.Memory.Cmp(free.Memory) > 0 {
+		blocked = append(blocked, Blocker{PredicateResources, fmt.Sprintf("insufficient memory: requests %s, %s free of %s allocatable", requests.Memory.String(), free.Memory.String(), nc.Allocatable.Memory.String())})
+	}
+	if nc.AllocatablePod > 0 && int64(nc.PodCount) >= nc.AllocatablePod {
+		blocked = append(blocked, Blocker{PredicateResources, fmt.Sprintf("too many pods: %d of %d", nc.PodCount, nc.AllocatablePod)})
+	}
+	return blocked
+}
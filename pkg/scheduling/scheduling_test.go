@@ -0,0 +1,255 @@
+package scheduling
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fixtureNode is a node with 4 cpu, 8Gi of memory and room for 110 pods allocatable
+func fixtureNode(name string, labels map[string]string, taints ...v1.Taint) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       v1.NodeSpec{Taints: taints},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("4"),
+			v1.ResourceMemory: resource.MustParse("8Gi"),
+			v1.ResourcePods:   resource.MustParse("110"),
+		}},
+	}
+}
+
+// fixturePod is a pod requesting cpu and memory, scheduled on node when it is set
+func fixturePod(name, node, cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Name: "web",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(cpu),
+					v1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func requiredAffinity(terms ...v1.NodeSelectorTerm) *v1.Affinity {
+	return &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: terms},
+	}}
+}
+
+func TestEvaluate(t *testing.T) {
+	gpuTaint := v1.Taint{Key: "gpu", Value: "true", Effect: v1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name  string
+		pod   func(*v1.Pod)
+		nodes []*v1.Node
+		pods  []*v1.Pod
+		// want are the blockers of node-a, nil when the pod fits
+		want []Blocker
+	}{
+		{name: "fits", nodes: []*v1.Node{fixtureNode("node-a", nil)}},
+		{
+			name:  "bound to another node",
+			pod:   func(pod *v1.Pod) { pod.Spec.NodeName = "node-b" },
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			want:  []Blocker{{PredicateNodeName, "pod is bound to node node-b"}},
+		},
+		{
+			name: "cordoned",
+			nodes: func() []*v1.Node {
+				node := fixtureNode("node-a", nil)
+				node.Spec.Unschedulable = true
+				return []*v1.Node{node}
+			}(),
+			want: []Blocker{{PredicateUnschedulable, "node is cordoned"}},
+		},
+		{
+			name: "cordoned, tolerated",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Tolerations = []v1.Toleration{{Key: v1.TaintNodeUnschedulable, Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule}}
+			},
+			nodes: func() []*v1.Node {
+				node := fixtureNode("node-a", nil)
+				node.Spec.Unschedulable = true
+				return []*v1.Node{node}
+			}(),
+		},
+		{
+			name:  "node selector label missing",
+			pod:   func(pod *v1.Pod) { pod.Spec.NodeSelector = map[string]string{"disk": "ssd"} },
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			want:  []Blocker{{PredicateNodeSelector, "label mismatch: node has no label disk, nodeSelector wants disk=ssd"}},
+		},
+		{
+			name:  "node selector label differs",
+			pod:   func(pod *v1.Pod) { pod.Spec.NodeSelector = map[string]string{"disk": "ssd"} },
+			nodes: []*v1.Node{fixtureNode("node-a", map[string]string{"disk": "hdd"})},
+			want:  []Blocker{{PredicateNodeSelector, "label mismatch: node has disk=hdd, nodeSelector wants disk=ssd"}},
+		},
+		{
+			name:  "node selector matches",
+			pod:   func(pod *v1.Pod) { pod.Spec.NodeSelector = map[string]string{"disk": "ssd"} },
+			nodes: []*v1.Node{fixtureNode("node-a", map[string]string{"disk": "ssd", "zone": "a"})},
+		},
+		{
+			name: "node affinity matches a term",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Affinity = requiredAffinity(
+					v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"b"}}}},
+					v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a", "c"}}}},
+				)
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", map[string]string{"zone": "a"})},
+		},
+		{
+			name: "node affinity matches no term",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Affinity = requiredAffinity(
+					v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"b", "c"}}}},
+					v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "spot", Operator: v1.NodeSelectorOpDoesNotExist}}},
+				)
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", map[string]string{"zone": "a", "spot": "true"})},
+			want:  []Blocker{{PredicateNodeAffinity, "label mismatch: node matches no required node affinity term: zone In [b, c]; spot DoesNotExist"}},
+		},
+		{
+			name: "node affinity numeric comparison",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Affinity = requiredAffinity(v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{
+					{Key: "cores", Operator: v1.NodeSelectorOpGt, Values: []string{"8"}},
+				}})
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", map[string]string{"cores": "4"})},
+			want:  []Blocker{{PredicateNodeAffinity, "label mismatch: node matches no required node affinity term: cores Gt [8]"}},
+		},
+		{
+			name: "node affinity match fields",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Affinity = requiredAffinity(v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{
+					{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{"node-b"}},
+				}})
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			want:  []Blocker{{PredicateNodeAffinity, "label mismatch: node matches no required node affinity term: metadata.name In [node-b]"}},
+		},
+		{
+			name:  "node affinity without terms",
+			pod:   func(pod *v1.Pod) { pod.Spec.Affinity = requiredAffinity() },
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			want:  []Blocker{{PredicateNodeAffinity, "required node affinity has no terms, which matches no node"}},
+		},
+		{
+			name:  "untolerated taint",
+			nodes: []*v1.Node{fixtureNode("node-a", nil, gpuTaint)},
+			want:  []Blocker{{PredicateTaint, "blocked by taint gpu=true:NoSchedule"}},
+		},
+		{
+			name: "tolerated taint",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Tolerations = []v1.Toleration{{Key: "gpu", Operator: v1.TolerationOpEqual, Value: "true", Effect: v1.TaintEffectNoSchedule}}
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", nil, gpuTaint)},
+		},
+		{
+			name: "toleration of another value",
+			pod: func(pod *v1.Pod) {
+				pod.Spec.Tolerations = []v1.Toleration{{Key: "gpu", Operator: v1.TolerationOpEqual, Value: "false", Effect: v1.TaintEffectNoSchedule}}
+			},
+			nodes: []*v1.Node{fixtureNode("node-a", nil, gpuTaint)},
+			want:  []Blocker{{PredicateTaint, "blocked by taint gpu=true:NoSchedule"}},
+		},
+		{
+			name: "prefer no schedule taint",
+			nodes: []*v1.Node{fixtureNode("node-a", nil,
+				v1.Taint{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule})},
+		},
+		{
+			name: "no execute taint",
+			nodes: []*v1.Node{fixtureNode("node-a", nil,
+				v1.Taint{Key: "node.kubernetes.io/not-ready", Effect: v1.TaintEffectNoExecute})},
+			want: []Blocker{{PredicateTaint, "blocked by taint node.kubernetes.io/not-ready:NoExecute"}},
+		},
+		{
+			name:  "insufficient cpu",
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			pods:  []*v1.Pod{fixturePod("db-0", "node-a", "3500m", "1Gi")},
+			want:  []Blocker{{PredicateResources, "insufficient cpu: requests 1, 500m free of 4 allocatable"}},
+		},
+		{
+			name:  "insufficient memory",
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			pods:  []*v1.Pod{fixturePod("db-0", "node-a", "100m", "7Gi")},
+			want:  []Blocker{{PredicateResources, "insufficient memory: requests 2Gi, 1Gi free of 8Gi allocatable"}},
+		},
+		{
+			name:  "requests of pods on other nodes",
+			nodes: []*v1.Node{fixtureNode("node-a", nil)},
+			pods:  []*v1.Pod{fixturePod("db-0", "node-b", "4", "8Gi")},
+		},
+		{
+			name: "too many pods",
+			nodes: func() []*v1.Node {
+				node := fixtureNode("node-a", nil)
+				node.Status.Allocatable[v1.ResourcePods] = resource.MustParse("1")
+				return []*v1.Node{node}
+			}(),
+			pods: []*v1.Pod{fixturePod("db-0", "node-a", "100m", "128Mi")},
+			want: []Blocker{{PredicateResources, "too many pods: 1 of 1"}},
+		},
+		{
+			name:  "every predicate failing",
+			pod:   func(pod *v1.Pod) { pod.Spec.NodeSelector = map[string]string{"disk": "ssd"} },
+			nodes: []*v1.Node{fixtureNode("node-a", nil, gpuTaint)},
+			pods:  []*v1.Pod{fixturePod("db-0", "node-a", "4", "1Gi")},
+			want: []Blocker{
+				{PredicateNodeSelector, "label mismatch: node has no label disk, nodeSelector wants disk=ssd"},
+				{PredicateTaint, "blocked by taint gpu=true:NoSchedule"},
+				{PredicateResources, "insufficient cpu: requests 1, 0 free of 4 allocatable"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := fixturePod("web-0", "", "1", "2Gi")
+			if tt.pod != nil {
+				tt.pod(pod)
+			}
+			verdicts := Evaluate(pod, tt.nodes, tt.pods)
+			if len(verdicts) != 1 || verdicts[0].Node != "node-a" {
+				t.Fatalf("Evaluate() = %+v, want a verdict for node-a", verdicts)
+			}
+			if got := verdicts[0]; got.Fits != (tt.want == nil) || !reflect.DeepEqual(got.Blocked, tt.want) {
+				t.Errorf("Evaluate() = fits %v, blocked %+v, want blocked %+v", got.Fits, got.Blocked, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSortsFittingNodesFirst(t *testing.T) {
+	taint := v1.Taint{Key: "dedicated", Value: "db", Effect: v1.TaintEffectNoSchedule}
+	nodes := []*v1.Node{
+		fixtureNode("node-c", nil),
+		fixtureNode("node-a", nil, taint),
+		fixtureNode("node-d", nil),
+		fixtureNode("node-b", nil, taint),
+	}
+
+	var got []string
+	for _, verdict := range Evaluate(fixturePod("web-0", "", "1", "2Gi"), nodes, nil) {
+		got = append(got, verdict.Node)
+	}
+	if want := []string{"node-c", "node-d", "node-a", "node-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate() nodes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+// Package schema performs structural validation of unstructured objects against the
+// OpenAPI v3 documents the API server publishes per group version, including CRD schemas.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const refPrefix = "#/components/schemas/"
+
+// Document is the part of an OpenAPI v3 document needed for validation
+type Document struct {
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Schema is a subset of an OpenAPI v3 schema object with the Kubernetes extensions
+type Schema struct {
+	Type                 string                `json:"type"`
+	Format               string                `json:"format"`
+	Properties           map[string]*Schema    `json:"properties"`
+	Items                *Schema               `json:"items"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties"`
+	Ref                  string                `json:"$ref"`
+	AllOf                []*Schema             `json:"allOf"`
+	Required             []string              `json:"required"`
+	Enum                 []interface{}         `json:"enum"`
+	GroupVersionKinds    []GroupVersionKind    `json:"x-kubernetes-group-version-kind"`
+	PreserveUnknown      bool                  `json:"x-kubernetes-preserve-unknown-fields"`
+	IntOrString          bool                  `json:"x-kubernetes-int-or-string"`
+}
+
+// AdditionalProperties is either a boolean or a schema for map values
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *AdditionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// GroupVersionKind is the x-kubernetes-group-version-kind extension entry
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// Violation is a validation failure at a JSON path
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Parse decodes an OpenAPI v3 document in JSON form
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// SchemaFor returns the root schema of the kind
+func (d *Document) SchemaFor(gvk runtimeschema.GroupVersionKind) (*Schema, bool) {
+	for _, s := range d.Components.Schemas {
+		for _, candidate := range s.GroupVersionKinds {
+			if candidate.Group == gvk.Group && candidate.Version == gvk.Version && candidate.Kind == gvk.Kind {
+				return s, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Validate checks the object against the schema, reporting type mismatches, missing
+// required fields, values outside an enum and fields the schema does not declare
+func (d *Document) Validate(obj map[string]interface{}, root *Schema) []Violation {
+	v := &validator{doc: d}
+	v.validate("", obj, root, 0)
+	sort.Slice(v.violations, func(i, j int) bool {
+		return v.violations[i].Path < v.violations[j].Path
+	})
+	return v.violations
+}
+
+// maxDepth guards against recursive schemas such as JSONSchemaProps
+const maxDepth = 64
+
+type validator struct {
+	doc        *Document
+	violations []Violation
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "."
+	}
+	v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve follows $ref and single-entry allOf wrappers, merging the Kubernetes extensions
+func (v *validator) resolve(s *Schema) *Schema {
+	for i := 0; s != nil && i < maxDepth; i++ {
+		switch {
+		case s.Ref != "":
+			target := v.doc.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+			if target == nil {
+				return nil
+			}
+			s = target
+		case len(s.AllOf) == 1 && s.Type == "" && s.Properties == nil:
+			wrapper := s
+			s = v.resolve(s.AllOf[0])
+			if s != nil && (wrapper.PreserveUnknown || wrapper.IntOrString) {
+				merged := *s
+				merged.PreserveUnknown = merged.PreserveUnknown || wrapper.PreserveUnknown
+				merged.IntOrString = merged.IntOrString || wrapper.IntOrString
+				s = &merged
+			}
+			return s
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+func (v *validator) validate(path string, value interface{}, s *Schema, depth int) {
+	s = v.resolve(s)
+	if s == nil || value == nil || depth > maxDepth {
+		return
+	}
+
+	if s.IntOrString {
+		switch value.(type) {
+		case string, int64, float64:
+		default:
+			v.fail(path, "expected integer or string, got %s", typeName(value))
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		v.fail(path, "unsupported value %v", value)
+	}
+
+	switch s.Type {
+	case "object":
+		v.validateObject(path, value, s, depth)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "expected array, got %s", typeName(value))
+			return
+		}
+		for i, item := range items {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), item, s.Items, depth+1)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			v.fail(path, "expected string, got %s", typeName(value))
+		}
+	case "integer":
+		switch n := value.(type) {
+		case int64:
+		case float64:
+			if n != math.Trunc(n) {
+				v.fail(path, "expected integer, got %v", n)
+			}
+		default:
+			v.fail(path, "expected integer, got %s", typeName(value))
+		}
+	case "number":
+		switch value.(type) {
+		case int64, float64:
+		default:
+			v.fail(path, "expected number, got %s", typeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "expected boolean, got %s", typeName(value))
+		}
+	case "":
+		// Untyped schemas (RawExtension, JSON) accept anything unless they declare properties
+		if s.Properties != nil {
+			v.validateObject(path, value, s, depth)
+		}
+	}
+}
+
+func (v *validator) validateObject(path string, value interface{}, s *Schema, depth int) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(path, "expected object, got %s", typeName(value))
+		return
+	}
+
+	for _, name := range s.Required {
+		if _, ok := fields[name]; !ok {
+			v.fail(path+"."+name, "required field is missing")
+		}
+	}
+
+	for name, field := range fields {
+		fieldPath := path + "." + name
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(fieldPath, field, prop, depth+1)
+			continue
+		}
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Allowed {
+			v.validate(fieldPath, field, s.AdditionalProperties.Schema, depth+1)
+			continue
+		}
+		if !s.PreserveUnknown && len(s.Properties) > 0 {
+			v.fail(fieldPath, "unknown field %q", name)
+		}
+	}
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,114 @@
+// Package stream buffers events between producers that must never block, such as informer
+// handlers, and the streaming connections of clients that may read slowly. Each subscription
+// gets a bounded ring buffer that drops its oldest events on overflow and counts them, so the
+// client can be told what it missed and whether to re-list.
+package stream
+
+import (
+	"sync"
+
+	"kgent-api/pkg/metrics"
+)
+
+var (
+	bufferedEvents = metrics.NewGauge("kgent_stream_buffered_events",
+		"Events waiting in subscription buffers to be sent to streaming clients.", "stream")
+	bufferCapacity = metrics.NewGauge("kgent_stream_buffer_capacity",
+		"Total capacity of the subscription buffers of streaming clients.", "stream")
+	droppedEvents = metrics.NewCounter("kgent_stream_dropped_events_total",
+		"Events dropped from full subscription buffers because the client read too slowly.", "stream")
+)
+
+// Buffer is the bounded FIFO of one subscription. Push never blocks: once the buffer is full
+// each new event overwrites the oldest one.
+type Buffer[T any] struct {
+	name string
+
+	mu      sync.Mutex
+	items   []T
+	head    int
+	size    int
+	dropped int
+	closed  bool
+
+	// ready holds a signal while the buffer has events or drops to report
+	ready chan struct{}
+}
+
+// NewBuffer returns a buffer holding up to capacity events for a subscription to the stream
+// called name, which labels the metrics. Close must be called once the subscription ends.
+func NewBuffer[T any](name string, capacity int) *Buffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	bufferCapacity.Add(float64(capacity), name)
+	return &Buffer[T]{name: name, items: make([]T, capacity), ready: make(chan struct{}, 1)}
+}
+
+// Push appends an event, dropping the oldest one when the buffer is full
+func (b *Buffer[T]) Push(item T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	tail := (b.head + b.size) % len(b.items)
+	b.items[tail] = item
+	if b.size == len(b.items) {
+		b.head = (b.head + 1) % len(b.items)
+		b.dropped++
+		droppedEvents.Inc(b.name)
+	} else {
+		b.size++
+		bufferedEvents.Inc(b.name)
+	}
+
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready is signalled when events or drops are waiting to be drained
+func (b *Buffer[T]) Ready() <-chan struct{} {
+	return b.ready
+}
+
+// Drain empties the buffer, returning its events oldest first along with how many events
+// were dropped since the previous Drain
+func (b *Buffer[T]) Drain() ([]T, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	items := make([]T, b.size)
+	for i := range items {
+		items[i] = b.items[(b.head+i)%len(b.items)]
+	}
+	clear(b.items)
+	bufferedEvents.Add(-float64(b.size), b.name)
+
+	dropped := b.dropped
+	b.head, b.size, b.dropped = 0, 0, 0
+	return items, dropped
+}
+
+// Len returns how many events are waiting
+func (b *Buffer[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Close releases the buffer's share of the metrics. Later pushes are ignored.
+func (b *Buffer[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	bufferedEvents.Add(-float64(b.size), b.name)
+	bufferCapacity.Add(-float64(len(b.items)), b.name)
+	b.size = 0
+}
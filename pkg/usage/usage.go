@@ -0,0 +1,289 @@
+// Package usage samples pod and node resource usage from the metrics API into short
+// per-object histories, for sparklines without a metrics backend. Every series is a ring
+// of fixed size, and series of deleted objects are dropped.
+package usage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"kgent-api/pkg/metrics"
+	"kgent-api/pkg/recovery"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MinInterval is the shortest interval between polls. metrics-server scrapes every 15
+// seconds by default, so polling faster mostly returns the same samples.
+const MinInterval = 5 * time.Second
+
+var (
+	podMetricsResource  = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+	nodeMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}
+)
+
+var (
+	failedPolls = metrics.NewCounter("kgent_usage_history_failed_polls_total",
+		"Metrics API polls of the usage history sampler that failed, by resource.", "resource")
+	seriesGauge = metrics.NewGauge("kgent_usage_history_series",
+		"Usage histories held by the sampler, by resource.", "resource")
+)
+
+// Sample is the usage of a pod, summed over its containers, or of a node at a point in time
+type Sample struct {
+	Time time.Time `json:"time"`
+	// CPU is in millicores
+	CPU int64 `json:"cpuMillicores"`
+	// Memory is the working set in bytes
+	Memory int64 `json:"memoryBytes"`
+}
+
+// series is a ring of the most recent samples of an object
+type series struct {
+	samples []Sample
+	next    int
+	full    bool
+	// seen is the poll that last returned the object
+	seen int
+}
+
+func (s *series) add(sample Sample) {
+	// metrics-server only has a new sample once per scrape
+	if last, ok := s.last(); ok && !sample.Time.After(last.Time) {
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+func (s *series) last() (Sample, bool) {
+	if !s.full && s.next == 0 {
+		return Sample{}, false
+	}
+	return s.samples[(s.next+len(s.samples)-1)%len(s.samples)], true
+}
+
+// list returns the samples oldest first
+func (s *series) list() []Sample {
+	if !s.full {
+		return append([]Sample(nil), s.samples[:s.next]...)
+	}
+	return append(append([]Sample(nil), s.samples[s.next:]...), s.samples[:s.next]...)
+}
+
+// Sampler polls the metrics API and keeps the history of each pod and node
+type Sampler struct {
+	client    dynamic.Interface
+	namespace string
+	nodes     bool
+	interval  time.Duration
+	size      int
+
+	mu        sync.RWMutex
+	pods      map[string]*series
+	nodeUsage map[string]*series
+	polls     int
+}
+
+// NewSampler polls every interval, no shorter than MinInterval, keeping the last size
+// samples of each pod in namespace, every namespace when empty, and of each node when
+// nodes is set. Polls go through client, sharing its rate limits with every other request.
+func NewSampler(client dynamic.Interface, namespace string, nodes bool, interval time.Duration, size int) *Sampler {
+	return &Sampler{
+		client:    client,
+		namespace: namespace,
+		nodes:     nodes,
+		interval:  max(interval, MinInterval),
+		size:      max(size, 1),
+		pods:      map[string]*series{},
+		nodeUsage: map[string]*series{},
+	}
+}
+
+// WatchPods drops the history of pods as the pod informer sees them deleted
+func (s *Sampler) WatchPods(informer cache.SharedIndexInformer) error {
+	return s.watch("pods", informer)
+}
+
+// WatchNodes drops the history of nodes as the node informer sees them deleted
+func (s *Sampler) WatchNodes(informer cache.SharedIndexInformer) error {
+	return s.watch("nodes", informer)
+}
+
+func (s *Sampler) watch(resource string, informer cache.SharedIndexInformer) error {
+	_, err := informer.AddEventHandler(recovery.Handler("usage/"+resource, cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return
+			}
+			s.forget(resource, accessor.GetNamespace(), accessor.GetName())
+		},
+	}, nil))
+	return err
+}
+
+func (s *Sampler) forget(resource string, namespace string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch resource {
+	case "pods":
+		delete(s.pods, namespace+"/"+name)
+		seriesGauge.Set(float64(len(s.pods)), "pods")
+	case "nodes":
+		delete(s.nodeUsage, name)
+		seriesGauge.Set(float64(len(s.nodeUsage)), "nodes")
+	}
+}
+
+// Run polls until ctx is done
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sampler) poll(ctx context.Context) {
+	pods, err := s.client.Resource(podMetricsResource).Namespace(s.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		failedPolls.Inc("pods")
+		log.Printf("Failed to poll pod metrics: %v", err)
+	}
+	var nodes *unstructured.UnstructuredList
+	if s.nodes {
+		nodes, err = s.client.Resource(nodeMetricsResource).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			failedPolls.Inc("nodes")
+			log.Printf("Failed to poll node metrics: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls++
+	if pods != nil {
+		for _, item := range pods.Items {
+			sample := sampleOf(item)
+			for _, container := range nestedSlice(item.Object, "containers") {
+				cpu, memory := usageOf(container)
+				sample.CPU += cpu
+				sample.Memory += memory
+			}
+			s.record(s.pods, item.GetNamespace()+"/"+item.GetName(), sample)
+		}
+		s.expire(s.pods)
+		seriesGauge.Set(float64(len(s.pods)), "pods")
+	}
+	if nodes != nil {
+		for _, item := range nodes.Items {
+			sample := sampleOf(item)
+			sample.CPU, sample.Memory = usageOf(item.Object)
+			s.record(s.nodeUsage, item.GetName(), sample)
+		}
+		s.expire(s.nodeUsage)
+		seriesGauge.Set(float64(len(s.nodeUsage)), "nodes")
+	}
+}
+
+// record adds a sample to the history under key, called with mu held
+func (s *Sampler) record(histories map[string]*series, key string, sample Sample) {
+	history, ok := histories[key]
+	if !ok {
+		history = &series{samples: make([]Sample, s.size)}
+		histories[key] = history
+	}
+	history.seen = s.polls
+	history.add(sample)
+}
+
+// expire drops the histories of objects missing from the last size polls, whose samples
+// would all be stale, in case a delete event was missed. Called with mu held.
+func (s *Sampler) expire(histories map[string]*series) {
+	for key, history := range histories {
+		if s.polls-history.seen >= s.size {
+			delete(histories, key)
+		}
+	}
+}
+
+// PodHistory returns the samples of a pod, oldest first
+func (s *Sampler) PodHistory(namespace string, name string) ([]Sample, bool) {
+	return s.history(s.pods, namespace+"/"+name)
+}
+
+// NodeHistory returns the samples of a node, oldest first
+func (s *Sampler) NodeHistory(name string) ([]Sample, bool) {
+	return s.history(s.nodeUsage, name)
+}
+
+func (s *Sampler) history(histories map[string]*series, key string) ([]Sample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history, ok := histories[key]
+	if !ok {
+		return nil, false
+	}
+	return history.list(), true
+}
+
+// Interval is the time between polls
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+// sampleOf starts a sample at the time of a metrics object, or now when it has none
+func sampleOf(item unstructured.Unstructured) Sample {
+	timestamp, _, _ := unstructured.NestedString(item.Object, "timestamp")
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return Sample{Time: t}
+	}
+	return Sample{Time: time.Now().Truncate(time.Second)}
+}
+
+// usageOf reads the cpu and memory of the usage field of a metrics object or container
+func usageOf(obj map[string]interface{}) (int64, int64) {
+	var cpu, memory int64
+	if v, _, _ := unstructured.NestedString(obj, "usage", "cpu"); v != "" {
+		if q, err := resource.ParseQuantity(v); err == nil {
+			cpu = q.MilliValue()
+		}
+	}
+	if v, _, _ := unstructured.NestedString(obj, "usage", "memory"); v != "" {
+		if q, err := resource.ParseQuantity(v); err == nil {
+			memory = q.Value()
+		}
+	}
+	return cpu, memory
+}
+
+func nestedSlice(obj map[string]interface{}, field string) []map[string]interface{} {
+	items, _, _ := unstructured.NestedSlice(obj, field)
+	var maps []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
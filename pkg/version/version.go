@@ -0,0 +1,44 @@
+// Package version holds the build version of kgent-api, set at build time with
+//
+//	go build -ldflags "-X kgent-api/pkg/version.Version=v1.2.3 -X kgent-api/pkg/version.GitCommit=$(git rev-parse HEAD)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var (
+	// Version is the release, "dev" for builds without -ldflags
+	Version = "dev"
+	// GitCommit is the commit the binary was built from
+	GitCommit = ""
+	// BuildDate is when the binary was built, e.g. in RFC 3339
+	BuildDate = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the version of the running build
+func Get() Info {
+	return Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// UserAgent identifies a kgent-api feature to the API server, e.g. "kgent-api/v1.2.3 (informers)",
+// so audit logs and API server metrics can attribute its requests
+func UserAgent(feature string) string {
+	return fmt.Sprintf("kgent-api/%s (%s)", Version, feature)
+}
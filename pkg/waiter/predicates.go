@@ -0,0 +1,52 @@
+package waiter
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConditionStatus holds when status.conditions has a condition of conditionType with status,
+// such as Available=True. Conditions reported for an older generation are ignored when the
+// condition carries observedGeneration.
+func ConditionStatus(conditionType, status string) Predicate {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		if err != nil {
+			return false, err
+		}
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+			if observed, found, _ := unstructured.NestedInt64(condition, "observedGeneration"); found && observed < obj.GetGeneration() {
+				return false, nil
+			}
+			return condition["status"] == status, nil
+		}
+		return false, nil
+	}
+}
+
+// GenerationObserved holds once status.observedGeneration has caught up with metadata.generation,
+// so the status describes the latest spec. Objects without observedGeneration never satisfy it.
+func GenerationObserved() Predicate {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		observed, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+		if err != nil || !found {
+			return false, err
+		}
+		return observed >= obj.GetGeneration(), nil
+	}
+}
+
+// All holds when every predicate holds, evaluated in order
+func All(predicates ...Predicate) Predicate {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		for _, predicate := range predicates {
+			if ok, err := predicate(obj); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
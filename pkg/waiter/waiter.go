@@ -0,0 +1,129 @@
+// Package waiter watches a single object through the dynamic client until a predicate holds,
+// the object is deleted, or the context ends. Waits are built on watchtools.UntilWithSync, so
+// the current state is checked first and expired watches are relisted transparently.
+package waiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+var (
+	// ErrDeleted is returned when the object is deleted while waiting for a predicate
+	ErrDeleted = errors.New("object was deleted while waiting")
+	// ErrTimeout is returned when the context ends before the wait is over
+	ErrTimeout = errors.New("timed out waiting for the condition")
+)
+
+// Predicate reports whether the wait is over. An error ends the wait with that error.
+type Predicate func(obj *unstructured.Unstructured) (bool, error)
+
+// Waiter waits on objects of any resource
+type Waiter struct {
+	client dynamic.Interface
+}
+
+func New(client dynamic.Interface) *Waiter {
+	return &Waiter{client: client}
+}
+
+// For waits until predicate holds for the object and returns the object that satisfied it.
+// The object must exist or be created while waiting; ns is empty for cluster-scoped resources.
+func (w *Waiter) For(ctx context.Context, gvr schema.GroupVersionResource, ns, name string, predicate Predicate) (*unstructured.Unstructured, error) {
+	var satisfied *unstructured.Unstructured
+	_, err := watchtools.UntilWithSync(ctx, w.listWatch(ctx, gvr, ns, name), &unstructured.Unstructured{}, nil, func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Deleted:
+			return false, fmt.Errorf("%s %s: %w", gvr.Resource, name, ErrDeleted)
+		case watch.Added, watch.Modified:
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				return false, nil
+			}
+			done, err := predicate(obj)
+			if done {
+				satisfied = obj
+			}
+			return done, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, translate(ctx, err)
+	}
+	return satisfied, nil
+}
+
+// ForDeletion waits until the object no longer exists, returning at once when it is already
+// gone. With a uid, an object recreated under the same name counts as the original's deletion.
+func (w *Waiter) ForDeletion(ctx context.Context, gvr schema.GroupVersionResource, ns, name string, uid types.UID) error {
+	gone := func(obj *unstructured.Unstructured) bool {
+		return uid != "" && obj.GetUID() != uid
+	}
+
+	precondition := func(store cache.Store) (bool, error) {
+		items := store.List()
+		if len(items) == 0 {
+			return true, nil
+		}
+		obj, ok := items[0].(*unstructured.Unstructured)
+		return ok && gone(obj), nil
+	}
+
+	_, err := watchtools.UntilWithSync(ctx, w.listWatch(ctx, gvr, ns, name), &unstructured.Unstructured{}, precondition, func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Deleted:
+			return true, nil
+		case watch.Added, watch.Modified:
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			return ok && gone(obj), nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return translate(ctx, err)
+	}
+	return nil
+}
+
+// listWatch lists and watches only the named object, for as long as ctx lasts
+func (w *Waiter) listWatch(ctx context.Context, gvr schema.GroupVersionResource, ns, name string) cache.ListerWatcher {
+	ri := w.client.Resource(gvr).Namespace(ns)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return ri.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			// Bookmarks keep the resourceVersion fresh, so a dropped watch resumes rather than relists
+			options.AllowWatchBookmarks = true
+			return ri.Watch(ctx, options)
+		},
+	}
+}
+
+// translate reports an ended context as ErrTimeout, keeping the context's own error
+func translate(ctx context.Context, err error) error {
+	if wait.Interrupted(err) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("%w: %w", ErrTimeout, ctxErr)
+		}
+		return ErrTimeout
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+package waiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+// deployment is the web Deployment at resourceVersion, with uid, whose status has observed
+// observedGeneration of generation 2
+func deployment(resourceVersion string, uid types.UID, observedGeneration int64) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"namespace":       "default",
+			"uid":             string(uid),
+			"resourceVersion": resourceVersion,
+			"generation":      int64(2),
+		},
+		"status": map[string]interface{}{"observedGeneration": observedGeneration},
+	}}
+	return obj
+}
+
+// newFakeClient serves objects and hands every watch it opens to the test through the
+// returned channel, so events can be sent on it
+func newFakeClient(objects ...runtime.Object) (*dynamicfake.FakeDynamicClient, <-chan *watch.FakeWatcher) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deploymentsGVR: "DeploymentList"}, objects...)
+	watches := make(chan *watch.FakeWatcher, 10)
+	client.PrependWatchReactor("deployments", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watches <- w
+		return true, w, nil
+	})
+	return client, watches
+}
+
+// nextWatch returns the next watch opened. When none is it fails the test and returns a
+// watch nobody reads, as it runs outside the test goroutine.
+func nextWatch(t *testing.T, watches <-chan *watch.FakeWatcher) *watch.FakeWatcher {
+	t.Helper()
+	select {
+	case w := <-watches:
+		return w
+	case <-time.After(5 * time.Second):
+		t.Error("no watch opened")
+		return watch.NewFakeWithChanSize(10, false)
+	}
+}
+
+func TestFor(t *testing.T) {
+	expired := &metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired, Message: "too old resource version"}
+
+	tests := []struct {
+		name    string
+		initial *unstructured.Unstructured
+		// events sends watch events, returning when the wait must be over
+		events  func(t *testing.T, client *dynamicfake.FakeDynamicClient, watches <-chan *watch.FakeWatcher)
+		timeout time.Duration
+		wantRV  string
+		wantErr func(error) bool
+	}{
+		{
+			name:    "already satisfied",
+			initial: deployment("1", "a", 2),
+			wantRV:  "1",
+		},
+		{
+			name:    "satisfied by an update",
+			initial: deployment("1", "a", 1),
+			events: func(t *testing.T, _ *dynamicfake.FakeDynamicClient, watches <-chan *watch.FakeWatcher) {
+				w := nextWatch(t, watches)
+				w.Modify(deployment("2", "a", 1))
+				w.Modify(deployment("3", "a", 2))
+			},
+			wantRV: "3",
+		},
+		{
+			name:    "bookmarks are skipped",
+			initial: deployment("1", "a", 1),
+			events: func(t *testing.T, _ *dynamicfake.FakeDynamicClient, watches <-chan *watch.FakeWatcher) {
+				w := nextWatch(t, watches)
+				bookmark := &unstructured.Unstructured{}
+				bookmark.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+				bookmark.SetResourceVersion("5")
+				w.Action(watch.Bookmark, bookmark)
+				w.Modify(deployment("6", "a", 2))
+			},
+			wantRV: "6",
+		},
+		{
+			name:    "deleted",
+			initial: deployment("1", "a", 1),
+			events: func(t *testing.T, _ *dynamicfake.FakeDynamicClient, watches <-chan *watch.FakeWatcher) {
+				nextWatch(t, watches).Delete(deployment("2", "a", 1))
+			},
+			wantErr: func(err error) bool { return errors.Is(err, ErrDeleted) },
+		},
+		{
+			// An expired watch is relisted, and the list holds the object as it is now
+			name:    "relisted after an error event",
+			initial: deployment("1", "a", 1),
+			events: func(t *testing.T, client *dynamicfake.FakeDynamicClient, watches <-chan *watch.FakeWatcher) {
+				w := nextWatch(t, watches)
+				if _, err := client.Resource(deploymentsGVR).Namespace("default").Update(context.Background(), deployment("7", "a", 2), metav1.UpdateOptions{}); err != nil {
+					t.Error(err)
+				}
+				w.Error(expired)
+			},
+			wantRV: "7",
+		},
+		{
+			name:    "timeout",
+			initial: deployment("1", "a", 1),
+			timeout: 50 * time.Millisecond,
+			wantErr: func(err error) bool { return errors.Is(err, ErrTimeout) && errors.Is(err, context.DeadlineExceeded) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, watches := newFakeClient(tt.initial)
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 10 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if tt.events != nil {
+				go tt.events(t, client, watches)
+			}
+			obj, err := New(client).For(ctx, deploymentsGVR, "default", "web", GenerationObserved())
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("For() error = %v, want a matching error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.GetResourceVersion() != tt.wantRV {
+				t.Errorf("For() = resourceVersion %s, want %s", obj.GetResourceVersion(), tt.wantRV)
+			}
+		})
+	}
+}
+
+func TestForDeletion(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial []runtime.Object
+		uid     types.UID
+		events  func(t *testing.T, watches <-chan *watch.FakeWatcher)
+	}{
+		{name: "already gone"},
+		{
+			name:    "deleted",
+			initial: []runtime.Object{deployment("1", "a", 2)},
+			events: func(t *testing.T, watches <-chan *watch.FakeWatcher) {
+				w := nextWatch(t, watches)
+				w.Modify(deployment("2", "a", 2))
+				w.Delete(deployment("2", "a", 2))
+			},
+		},
+		{name: "recreated before the wait", initial: []runtime.Object{deployment("1", "b", 2)}, uid: "a"},
+		{
+			name:    "recreated while waiting",
+			initial: []runtime.Object{deployment("1", "a", 2)},
+			uid:     "a",
+			events: func(t *testing.T, watches <-chan *watch.FakeWatcher) {
+				nextWatch(t, watches).Modify(deployment("3", "b", 2))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, watches := newFakeClient(tt.initial...)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			if tt.events != nil {
+				go tt.events(t, watches)
+			}
+			if err := New(client).ForDeletion(ctx, deploymentsGVR, "default", "web", tt.uid); err != nil {
+				t.Fatalf("ForDeletion() = %v", err)
+			}
+		})
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	withConditions := func(generation int64, conditions ...interface{}) *unstructured.Unstructured {
+		obj := deployment("1", "a", generation)
+		if err := unstructured.SetNestedSlice(obj.Object, conditions, "status", "conditions"); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	condition := func(conditionType, status string, observedGeneration int64) interface{} {
+		c := map[string]interface{}{"type": conditionType, "status": status}
+		if observedGeneration > 0 {
+			c["observedGeneration"] = observedGeneration
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		predicate Predicate
+		obj       *unstructured.Unstructured
+		want      bool
+	}{
+		{name: "condition true", predicate: ConditionStatus("Available", "True"), obj: withConditions(2, condition("Available", "True", 0)), want: true},
+		{name: "condition false", predicate: ConditionStatus("Available", "True"), obj: withConditions(2, condition("Available", "False", 0))},
+		{name: "condition missing", predicate: ConditionStatus("Available", "True"), obj: withConditions(2, condition("Progressing", "True", 0))},
+		{name: "condition of an older generation", predicate: ConditionStatus("Available", "True"), obj: withConditions(2, condition("Available", "True", 1))},
+		{name: "condition of the current generation", predicate: ConditionStatus("Available", "True"), obj: withConditions(2, condition("Available", "True", 2)), want: true},
+		{name: "generation observed", predicate: GenerationObserved(), obj: deployment("1", "a", 2), want: true},
+		{name: "generation not observed", predicate: GenerationObserved(), obj: deployment("1", "a", 1)},
+		{
+			name:      "all",
+			predicate: All(GenerationObserved(), ConditionStatus("Available", "True")),
+			obj:       withConditions(2, condition("Available", "True", 0)),
+			want:      true,
+		},
+		{
+			name:      "all, one failing",
+			predicate: All(GenerationObserved(), ConditionStatus("Available", "True")),
+			obj:       withConditions(1, condition("Available", "True", 0)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.predicate(tt.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("predicate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package warnings captures the Warning headers the API server attaches to responses,
+// such as deprecated API versions, so they can be returned to callers instead of dropped.
+package warnings
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// Observation is a distinct warning message and how often it has been seen
+type Observation struct {
+	Message   string    `json:"message"`
+	Agent     string    `json:"agent,omitempty"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Recorder counts every warning received by the process. It keeps at most maxEntries
+// distinct messages and evicts the least recently seen one when full.
+type Recorder struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*Observation
+}
+
+func NewRecorder(maxEntries int) *Recorder {
+	return &Recorder{maxEntries: maxEntries, entries: map[string]*Observation{}}
+}
+
+// HandleWarningHeader implements rest.WarningHandler. Only code 299 carries API warnings.
+func (r *Recorder) HandleWarningHeader(code int, agent string, message string) {
+	if code != 299 || message == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := r.entries[message]; ok {
+		entry.Count++
+		entry.LastSeen = now
+		return
+	}
+
+	if len(r.entries) >= r.maxEntries {
+		r.evictOldest()
+	}
+	r.entries[message] = &Observation{Message: message, Agent: agent, Count: 1, FirstSeen: now, LastSeen: now}
+}
+
+func (r *Recorder) evictOldest() {
+	var oldest *Observation
+	for _, entry := range r.entries {
+		if oldest == nil || entry.LastSeen.Before(oldest.LastSeen) {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		delete(r.entries, oldest.Message)
+	}
+}
+
+// Recent returns the recorded warnings, most recently seen first
+func (r *Recorder) Recent() []Observation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	observations := make([]Observation, 0, len(r.entries))
+	for _, entry := range r.entries {
+		observations = append(observations, *entry)
+	}
+	sort.Slice(observations, func(i, j int) bool {
+		return observations[i].LastSeen.After(observations[j].LastSeen)
+	})
+	return observations
+}
+
+// Collector gathers the warnings raised while serving a single request and forwards
+// them to the next handler, typically the process-wide Recorder
+type Collector struct {
+	mu       sync.Mutex
+	next     rest.WarningHandler
+	messages []string
+}
+
+func NewCollector(next rest.WarningHandler) *Collector {
+	return &Collector{next: next}
+}
+
+// HandleWarningHeader implements rest.WarningHandler
+func (c *Collector) HandleWarningHeader(code int, agent string, message string) {
+	if c.next != nil {
+		c.next.HandleWarningHeader(code, agent, message)
+	}
+	if code != 299 || message == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !slices.Contains(c.messages, message) {
+		c.messages = append(c.messages, message)
+	}
+}
+
+// Warnings returns the distinct messages collected so far. It is safe to call on a nil Collector.
+func (c *Collector) Warnings() []string {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.messages)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the collector
+func NewContext(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the collector carried by ctx, or nil
+func FromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(contextKey{}).(*Collector)
+	return c
+}
@@ -0,0 +1,314 @@
+// Package webhook delivers informer events to subscribed HTTP endpoints. Events are queued
+// without blocking informer delivery and posted by a pool of workers with retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kgent-api/pkg/metrics"
+	"kgent-api/pkg/recovery"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Event types a subscription can filter on
+const (
+	EventAdded   = "added"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body when the subscription has a secret
+const SignatureHeader = "X-Kgent-Signature"
+
+const (
+	maxAttempts    = 4
+	initialBackoff = time.Second
+)
+
+var (
+	deliveriesTotal = metrics.NewCounter("kgent_webhook_deliveries_total",
+		"Webhook delivery attempts by outcome.", "outcome")
+	deadLetterTotal = metrics.NewCounter("kgent_webhook_dead_letter_total",
+		"Webhook events dropped after exhausting retries.")
+	droppedTotal = metrics.NewCounter("kgent_webhook_queue_dropped_total",
+		"Webhook events dropped because the delivery queue was full.")
+)
+
+// Subscription selects the events posted to URL
+type Subscription struct {
+	ID            string                      `json:"id"`
+	Resource      schema.GroupVersionResource `json:"resource"`
+	Namespace     string                      `json:"namespace,omitempty"`
+	LabelSelector string                      `json:"labelSelector,omitempty"`
+	Events        []string                    `json:"events"`
+	URL           string                      `json:"url"`
+	Secret        string                      `json:"-"`
+	CreatedAt     time.Time                   `json:"createdAt"`
+
+	selector labels.Selector
+}
+
+func (s *Subscription) matches(gvr schema.GroupVersionResource, event string, obj metav1.Object) bool {
+	return s.Resource == gvr &&
+		(len(s.Events) == 0 || slices.Contains(s.Events, event)) &&
+		(s.Namespace == "" || s.Namespace == obj.GetNamespace()) &&
+		s.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// Payload is the JSON body posted for each event
+type Payload struct {
+	SubscriptionID string                      `json:"subscriptionId"`
+	Type           string                      `json:"type"`
+	Resource       schema.GroupVersionResource `json:"resource"`
+	Namespace      string                      `json:"namespace,omitempty"`
+	Name           string                      `json:"name"`
+	Time           time.Time                   `json:"time"`
+	Object         interface{}                 `json:"object"`
+}
+
+type delivery struct {
+	url     string
+	secret  string
+	payload Payload
+}
+
+// Dispatcher holds the subscriptions and the delivery queue
+type Dispatcher struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*Subscription
+	watched       map[schema.GroupVersionResource]bool
+
+	queue  chan delivery
+	client *http.Client
+	// running is set while Run is active; events are not queued otherwise
+	running atomic.Bool
+}
+
+// NewDispatcher creates a dispatcher whose queue holds up to queueSize pending deliveries
+func NewDispatcher(queueSize int) *Dispatcher {
+	return &Dispatcher{
+		subscriptions: map[string]*Subscription{},
+		watched:       map[schema.GroupVersionResource]bool{},
+		queue:         make(chan delivery, queueSize),
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Add validates and stores a subscription, assigning its ID
+func (d *Dispatcher) Add(sub *Subscription) error {
+	selector, err := labels.Parse(sub.LabelSelector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+	for _, event := range sub.Events {
+		if event != EventAdded && event != EventUpdated && event != EventDeleted {
+			return fmt.Errorf("unsupported event type %q, expected one of added, updated, deleted", event)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate subscription id: %w", err)
+	}
+	sub.ID = hex.EncodeToString(id)
+	sub.CreatedAt = time.Now()
+	sub.selector = selector
+
+	d.mu.Lock()
+	d.subscriptions[sub.ID] = sub
+	d.mu.Unlock()
+	return nil
+}
+
+// List returns all subscriptions ordered by creation time
+func (d *Dispatcher) List() []Subscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	subs := make([]Subscription, 0, len(d.subscriptions))
+	for _, sub := range d.subscriptions {
+		subs = append(subs, *sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+// Remove deletes a subscription, reporting whether it existed
+func (d *Dispatcher) Remove(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.subscriptions[id]
+	delete(d.subscriptions, id)
+	return ok
+}
+
+// Watch registers event handlers on the informer for gvr once; later calls are no-ops
+func (d *Dispatcher) Watch(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.watched[gvr] {
+		return nil
+	}
+	_, err := informer.AddEventHandler(recovery.Handler("webhook/"+gvr.Resource, cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				d.enqueue(gvr, EventAdded, obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			d.enqueue(gvr, EventUpdated, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			d.enqueue(gvr, EventDeleted, obj)
+		},
+	}, nil))
+	if err != nil {
+		return err
+	}
+	d.watched[gvr] = true
+	return nil
+}
+
+// enqueue queues a delivery for every matching subscription, dropping it if the queue is full
+func (d *Dispatcher) enqueue(gvr schema.GroupVersionResource, event string, obj interface{}) {
+	if !d.running.Load() {
+		return
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, sub := range d.subscriptions {
+		if !sub.matches(gvr, event, accessor) {
+			continue
+		}
+
+		select {
+		case d.queue <- delivery{
+			url:    sub.URL,
+			secret: sub.Secret,
+			payload: Payload{
+				SubscriptionID: sub.ID,
+				Type:           event,
+				Resource:       gvr,
+				Namespace:      accessor.GetNamespace(),
+				Name:           accessor.GetName(),
+				Time:           time.Now(),
+				Object:         obj,
+			},
+		}:
+		default:
+			droppedTotal.Inc()
+		}
+	}
+}
+
+// Run starts workers delivering queued events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context, workers int) {
+	d.running.Store(true)
+	defer d.running.Store(false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item := <-d.queue:
+					d.deliver(ctx, item)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver posts the payload, retrying failures with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, item delivery) {
+	body, err := json.Marshal(item.payload)
+	if err != nil {
+		deadLetterTotal.Inc()
+		return
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = d.post(ctx, item, body)
+		if err == nil {
+			deliveriesTotal.Inc("success")
+			return
+		}
+		deliveriesTotal.Inc("failure")
+
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	deadLetterTotal.Inc()
+	log.Printf("webhook %s: giving up on %s after %d attempts: %v", item.payload.SubscriptionID, item.url, maxAttempts, err)
+}
+
+func (d *Dispatcher) post(ctx context.Context, item delivery, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, item.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if item.secret != "" {
+		mac := hmac.New(sha256.New, []byte(item.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
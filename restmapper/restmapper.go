@@ -7,82 +7,164 @@
 // Use it together with DiscoveryClient to handle GVR and GVK conversions.
 
 // go run restmapper/restmapper.go --namespace=kube-system --resource=pods
+// go run restmapper/restmapper.go --output=json pods deploy.apps Ingress
+// go run restmapper/restmapper.go --all --output=yaml
+// go run restmapper/restmapper.go --namespace=kube-system --watch pods
 
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"kgent-api/pkg/clientutil"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/restmapper"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
 )
 
 func main() {
 	// Set up command line flags
-	var kubeconfig *string
 	var namespace *string
 	var resourceArg *string
 
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"),
-			"absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
+	connection := clientutil.RegisterFlags(nil)
 	namespace = flag.String("namespace", "default", "namespace to list resources from")
 	resourceArg = flag.String("resource", "pods", "resource type or kind to list (e.g. pods, deployments.apps, Pod, Deployment)")
+	output := flag.String("output", "text", "output format of the mappings: text, json or yaml")
+	all := flag.Bool("all", false, "print every mapping the RESTMapper knows instead of resolving resources")
+	cacheTTL := flag.Duration("cache-ttl", 6*time.Hour, "how long discovery results are cached in ~/.kube/cache/discovery")
+	noCache := flag.Bool("no-cache", false, "query discovery directly instead of using the discovery cache")
+	watchFlag := flag.Bool("watch", false, "after listing the resource, watch it and print events until interrupted")
 	flag.Parse()
 
+	if *output != "text" && *output != "json" && *output != "yaml" {
+		log.Fatalf("Unknown output format %q, expected text, json or yaml", *output)
+	}
+
+	// Resource args after the flags are resolved one by one, --resource when there are none
+	resourceArgs := flag.Args()
+	if len(resourceArgs) == 0 {
+		resourceArgs = []string{*resourceArg}
+	}
+	if *watchFlag && (*all || len(resourceArgs) != 1) {
+		log.Fatalf("--watch needs exactly one resource")
+	}
+
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Build config from kubeconfig file
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	// Build config from the kubeconfig, or the in-cluster config
+	config, err := connection.Config()
 	if err != nil {
-		log.Fatalf("Error building kubeconfig: %v", err)
+		log.Fatalf("Error building config: %v", err)
 	}
 
-	// Create clientset for discovery
-	clientset, err := kubernetes.NewForConfig(config)
+	clients, err := clientutil.NewClients(config)
 	if err != nil {
-		log.Fatalf("Error creating Kubernetes client: %v", err)
+		log.Fatalf("Error creating clients: %v", err)
 	}
+	dynamicClient := clients.Dynamic
 
-	// Create dynamic client for resource access
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		log.Fatalf("Error creating dynamic client: %v", err)
+	// Discovery results are cached on disk like kubectl does, since fetching them is slow on
+	// clusters with many CRDs
+	discoveryClient := clients.Discovery
+	var cachedDiscovery *disk.CachedDiscoveryClient
+	if !*noCache {
+		cacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache")
+		cachedDiscovery, err = disk.NewCachedDiscoveryClientForConfig(config,
+			discoveryCacheDir(filepath.Join(cacheDir, "discovery"), config.Host), filepath.Join(cacheDir, "http"), *cacheTTL)
+		if err != nil {
+			log.Fatalf("Error creating cached discovery client: %v", err)
+		}
+		discoveryClient = cachedDiscovery
 	}
 
 	// Initialize REST mapper
-	restMapper := InitRestMapper(clientset)
+	restMapper, groupResources := InitRestMapper(discoveryClient)
 
-	// Get REST mapping for the requested resource
-	restMapping, err := mappingFor(*resourceArg, &restMapper)
-	if err != nil {
-		log.Fatalf("Error getting REST mapping for %s: %v", *resourceArg, err)
+	if *all {
+		if err := printMappings(allMappings(restMapper, groupResources), *output, true); err != nil {
+			log.Fatalf("Error printing mappings: %v", err)
+		}
+		return
 	}
 
-	if restMapping == nil {
-		log.Fatalf("Could not find REST mapping for resource: %s", *resourceArg)
+	// Get REST mappings for the requested resources, reporting the ones that don't resolve
+	// without giving up on the others
+	resolved := map[string]*meta.RESTMapping{}
+	failures := map[string]error{}
+	resolve := func(args []string) {
+		for _, arg := range args {
+			mapping, err := mappingFor(arg, &restMapper)
+			if err != nil {
+				failures[arg] = err
+				continue
+			}
+			delete(failures, arg)
+			resolved[arg] = mapping
+		}
 	}
+	resolve(resourceArgs)
 
-	fmt.Printf("Resource Mapping Information:\n")
-	fmt.Printf("  GVR: %s\n", restMapping.Resource)
-	fmt.Printf("  GVK: %s\n", restMapping.GroupVersionKind)
-	fmt.Printf("  Scope: %s\n\n", restMapping.Scope.Name())
+	// A resource missing from the cache may have been installed since, so refresh it once
+	if len(failures) > 0 && cachedDiscovery != nil && !cachedDiscovery.Fresh() {
+		fmt.Fprintln(os.Stderr, "Refreshing the discovery cache")
+		cachedDiscovery.Invalidate()
+		restMapper, groupResources = InitRestMapper(discoveryClient)
+		var retry []string
+		for arg := range failures {
+			retry = append(retry, arg)
+		}
+		resolve(retry)
+	}
+
+	index := indexResources(groupResources)
+	var records []mappingRecord
+	var restMapping *meta.RESTMapping
+	for _, arg := range resourceArgs {
+		if err, ok := failures[arg]; ok {
+			fmt.Fprintf(os.Stderr, "Error getting REST mapping for %s: %v\n", arg, err)
+			continue
+		}
+		restMapping = resolved[arg]
+		records = append(records, newMappingRecord(arg, restMapping, index))
+	}
+
+	if err := printMappings(records, *output, false); err != nil {
+		log.Fatalf("Error printing mappings: %v", err)
+	}
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+
+	// Listing only makes sense for a single resource printed as text, or to start a watch
+	if (*output != "text" && !*watchFlag) || len(resourceArgs) != 1 {
+		return
+	}
 
 	// Create a resource interface
 	var resourceInterface dynamic.ResourceInterface
@@ -102,7 +184,6 @@ func main() {
 
 	if len(resources.Items) == 0 {
 		fmt.Println("No resources found.")
-		return
 	}
 
 	// Display resources
@@ -122,17 +203,238 @@ func main() {
 			}
 		}
 	}
+
+	if *watchFlag {
+		watchCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("\nWatching %s, press Ctrl+C to stop:\n", restMapping.Resource.Resource)
+		watchResource(watchCtx, resourceInterface, resources.GetResourceVersion())
+	}
 }
 
-// InitRestMapper initializes a REST mapper from discovery client
-func InitRestMapper(clientSet *kubernetes.Clientset) meta.RESTMapper {
-	gr, err := restmapper.GetAPIGroupResources(clientSet.Discovery())
+// watchResource prints the events of resourceInterface from resourceVersion until ctx is
+// cancelled. Watches the server closes are restarted from the last resourceVersion seen;
+// when that version has expired, the watch resumes from a fresh list's version instead.
+func watchResource(ctx context.Context, resourceInterface dynamic.ResourceInterface, resourceVersion string) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		w, err := resourceInterface.Watch(ctx, metav1.ListOptions{
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				resourceVersion = currentResourceVersion(ctx, resourceInterface)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Error starting watch, retrying in %s: %v\n", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		for event := range w.ResultChan() {
+			switch event.Type {
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				fmt.Fprintf(os.Stderr, "Watch error: %v\n", err)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					resourceVersion = currentResourceVersion(ctx, resourceInterface)
+				}
+			case watch.Bookmark:
+				if obj, ok := event.Object.(*unstructured.Unstructured); ok {
+					resourceVersion = obj.GetResourceVersion()
+				}
+			default:
+				obj, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				resourceVersion = obj.GetResourceVersion()
+				name := obj.GetName()
+				if obj.GetNamespace() != "" {
+					name = obj.GetNamespace() + "/" + name
+				}
+				fmt.Printf("%s %-8s %s (resourceVersion %s)\n", time.Now().Format(time.TimeOnly), event.Type, name, resourceVersion)
+			}
+		}
+		w.Stop()
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Watch closed by the server, resuming from resourceVersion %s\n", resourceVersion)
+		}
+	}
+}
+
+// currentResourceVersion returns the resourceVersion of a fresh list, or "" when listing
+// fails, in which case the watch starts with the current state
+func currentResourceVersion(ctx context.Context, resourceInterface dynamic.ResourceInterface) string {
+	list, err := resourceInterface.List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing to resume the watch: %v\n", err)
+		return ""
+	}
+	return list.GetResourceVersion()
+}
+
+// InitRestMapper initializes a REST mapper from discovery client. The discovered group
+// resources are returned as well, since the mapper doesn't expose verbs and short names.
+func InitRestMapper(discoveryClient discovery.DiscoveryInterface) (meta.RESTMapper, []*restmapper.APIGroupResources) {
+	gr, err := restmapper.GetAPIGroupResources(discoveryClient)
 	if err != nil {
 		log.Fatalf("Error getting API group resources: %v", err)
 	}
 
 	mapper := restmapper.NewDiscoveryRESTMapper(gr)
-	return mapper
+	return mapper, gr
+}
+
+// discoveryCacheDir returns the directory kubectl caches discovery for host in, e.g.
+// ~/.kube/cache/discovery/example.com_6443
+func discoveryCacheDir(parentDir, host string) string {
+	schemelessHost := strings.Replace(strings.Replace(host, "https://", "", 1), "http://", "", 1)
+	return filepath.Join(parentDir, unsafeCacheChars.ReplaceAllString(schemelessHost, "_"))
+}
+
+var unsafeCacheChars = regexp.MustCompile(`[^(\w/.)]`)
+
+// mappingRecord is the printed form of a REST mapping
+type mappingRecord struct {
+	// Arg is the resource argument the mapping was resolved from, empty with --all
+	Arg        string   `json:"arg,omitempty"`
+	Group      string   `json:"group"`
+	Version    string   `json:"version"`
+	Resource   string   `json:"resource"`
+	Kind       string   `json:"kind"`
+	Scope      string   `json:"scope"`
+	Verbs      []string `json:"verbs,omitempty"`
+	ShortNames []string `json:"shortNames,omitempty"`
+}
+
+func (r mappingRecord) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+}
+
+func (r mappingRecord) gvk() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
+// indexResources indexes the discovered resources by group, version and resource
+func indexResources(groupResources []*restmapper.APIGroupResources) map[schema.GroupVersionResource]metav1.APIResource {
+	index := map[schema.GroupVersionResource]metav1.APIResource{}
+	for _, group := range groupResources {
+		for version, resources := range group.VersionedResources {
+			for _, resource := range resources {
+				index[schema.GroupVersionResource{Group: group.Group.Name, Version: version, Resource: resource.Name}] = resource
+			}
+		}
+	}
+	return index
+}
+
+func newMappingRecord(arg string, mapping *meta.RESTMapping, index map[schema.GroupVersionResource]metav1.APIResource) mappingRecord {
+	resource := index[mapping.Resource]
+	return mappingRecord{
+		Arg:        arg,
+		Group:      mapping.Resource.Group,
+		Version:    mapping.Resource.Version,
+		Resource:   mapping.Resource.Resource,
+		Kind:       mapping.GroupVersionKind.Kind,
+		Scope:      string(mapping.Scope.Name()),
+		Verbs:      resource.Verbs,
+		ShortNames: resource.ShortNames,
+	}
+}
+
+// allMappings resolves every discovered resource through the mapper, like kubectl
+// api-resources but for all served versions. Subresources have no mapping and are skipped.
+func allMappings(mapper meta.RESTMapper, groupResources []*restmapper.APIGroupResources) []mappingRecord {
+	var records []mappingRecord
+	for _, group := range groupResources {
+		for version, resources := range group.VersionedResources {
+			for _, resource := range resources {
+				if strings.Contains(resource.Name, "/") {
+					continue
+				}
+				mapping, err := mapper.RESTMapping(schema.GroupKind{Group: group.Group.Name, Kind: resource.Kind}, version)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: no mapping for %s in %s/%s: %v\n", resource.Name, group.Group.Name, version, err)
+					continue
+				}
+				record := newMappingRecord("", mapping, nil)
+				record.Resource = resource.Name
+				record.Verbs = resource.Verbs
+				record.ShortNames = resource.ShortNames
+				records = append(records, record)
+			}
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Version < b.Version
+	})
+	return records
+}
+
+// printMappings writes the records to stdout as text, or as a JSON or YAML list. The text
+// form of --all is a table like kubectl api-resources.
+func printMappings(records []mappingRecord, output string, table bool) error {
+	if records == nil {
+		records = []mappingRecord{}
+	}
+
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(records)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		if table {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSHORTNAMES\tAPIVERSION\tNAMESPACED\tKIND\tVERBS")
+			for _, r := range records {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\n", r.Resource, strings.Join(r.ShortNames, ","),
+					r.gvk().GroupVersion(), r.Scope == string(meta.RESTScopeNameNamespace), r.Kind, strings.Join(r.Verbs, ","))
+			}
+			return w.Flush()
+		}
+		for _, r := range records {
+			fmt.Printf("Resource Mapping Information for %s:\n", r.Arg)
+			fmt.Printf("  GVR: %s\n", r.gvr())
+			fmt.Printf("  GVK: %s\n", r.gvk())
+			fmt.Printf("  Scope: %s\n", r.Scope)
+			if len(r.Verbs) > 0 {
+				fmt.Printf("  Verbs: %s\n", strings.Join(r.Verbs, ", "))
+			}
+			if len(r.ShortNames) > 0 {
+				fmt.Printf("  Short names: %s\n", strings.Join(r.ShortNames, ", "))
+			}
+			fmt.Println()
+		}
+	}
+	return nil
 }
 
 // mappingFor gets the REST mapping for a resource or kind argument
@@ -144,7 +446,7 @@ func mappingFor(resourceOrKindArg string, restMapper *meta.RESTMapper) (*meta.RE
 		var err error
 		gvk, err = (*restMapper).KindFor(*fullySpecifiedGVR)
 		if err != nil {
-			fmt.Printf("Warning: Could not get kind for GVR %s: %v\n", fullySpecifiedGVR, err)
+			fmt.Fprintf(os.Stderr, "Warning: Could not get kind for GVR %s: %v\n", fullySpecifiedGVR, err)
 		}
 	}
 
@@ -152,7 +454,7 @@ func mappingFor(resourceOrKindArg string, restMapper *meta.RESTMapper) (*meta.RE
 		var err error
 		gvk, err = (*restMapper).KindFor(groupResource.WithVersion(""))
 		if err != nil {
-			fmt.Printf("Warning: Could not get kind for group resource %s: %v\n", groupResource, err)
+			fmt.Fprintf(os.Stderr, "Warning: Could not get kind for group resource %s: %v\n", groupResource, err)
 		}
 	}
 
@@ -161,7 +463,7 @@ func mappingFor(resourceOrKindArg string, restMapper *meta.RESTMapper) (*meta.RE
 	}
 
 	// Try a direct mapping as fallback
-	fmt.Printf("Trying direct mapping for group: %s, resource: %s\n", groupResource.Group, groupResource.Resource)
+	fmt.Fprintf(os.Stderr, "Trying direct mapping for group: %s, resource: %s\n", groupResource.Group, groupResource.Resource)
 	return (*restMapper).RESTMapping(schema.GroupKind{
 		Group: groupResource.Group,
 		Kind:  groupResource.Resource,